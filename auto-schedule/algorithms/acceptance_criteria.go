@@ -2,6 +2,7 @@ package algorithms
 
 import (
 	asmodel "emcontroller/auto-schedule/model"
+	"emcontroller/models"
 )
 
 type AppType int
@@ -19,6 +20,11 @@ func Acceptable(clouds map[string]asmodel.Cloud, apps map[string]asmodel.Applica
 		return false
 	}
 
+	// check the placement of replicas
+	if !replicaAcc(apps, soln) {
+		return false
+	}
+
 	// Maybe there will be other aspects in the future
 
 	// all checks passed
@@ -29,36 +35,84 @@ func Acceptable(clouds map[string]asmodel.Cloud, apps map[string]asmodel.Applica
 func depAcc(clouds map[string]asmodel.Cloud, apps map[string]asmodel.Application, soln asmodel.Solution) bool {
 
 	for appName, app := range apps {
-		for _, dep := range app.Dependencies {
-			depAppName := dep.AppName
+		// only the accepted applications need to be checked
+		if !soln.AppsSolution[appName].Accepted {
+			continue
+		}
 
+		for _, dep := range app.Dependencies {
 			// If an application is accepted, all its dependent applications should be accepted.
-			if soln.AppsSolution[appName].Accepted && !soln.AppsSolution[depAppName].Accepted {
+			// If a dependent application has multiple replicas, at least one of its replicas should be accepted and reachable.
+			if !depReachable(clouds, soln, appName, dep, asmodel.DepReplicaNames(apps, dep.AppName)) {
 				return false
 			}
+		}
+	}
 
-			// We presume that every application needs to send network requests to all its dependent applications, so the network RTT should not be too large.
-			// This check is only needed when this pair of applications are accepted.
-			if soln.AppsSolution[appName].Accepted && soln.AppsSolution[depAppName].Accepted {
-				srcVmName := soln.AppsSolution[appName].K8sNodeName
-				dstVmName := soln.AppsSolution[depAppName].K8sNodeName
-				// If 2 applications are deployed on the same VM, we think that the RTT between them is 0, so this check will not be needed in that condition.
-				if srcVmName != dstVmName {
-					srcCloudName := soln.AppsSolution[appName].TargetCloudName
-					dstCloudName := soln.AppsSolution[depAppName].TargetCloudName
-
-					// If the RTT is too large, this solution is not acceptable.
-					if clouds[srcCloudName].NetState[dstCloudName].Rtt > maxAccRttMs {
-						return false
-					}
+	// all clouds passed
+	return true
+}
 
-				}
+// Check whether an accepted application can reach at least one of the accepted replicas of its dependent application.
+func depReachable(clouds map[string]asmodel.Cloud, soln asmodel.Solution, appName string, dep models.Dependency, depReplicaNames []string) bool {
+	// the dependency can set its own RTT limit, otherwise we use the default one.
+	maxRttMs := maxAccRttMs
+	if dep.MaxRttMs > 0 {
+		maxRttMs = dep.MaxRttMs
+	}
 
-			}
+	for _, depAppName := range depReplicaNames {
+		if !soln.AppsSolution[depAppName].Accepted {
+			continue
+		}
 
+		// We presume that every application needs to send network requests to all its dependent applications, so the network RTT should not be too large.
+		// This check is only needed when this pair of applications are accepted.
+		srcVmName := soln.AppsSolution[appName].K8sNodeName
+		dstVmName := soln.AppsSolution[depAppName].K8sNodeName
+		// If 2 applications are deployed on the same VM, we think that the RTT between them is 0 and the bandwidth between them is unlimited, so this check will not be needed in that condition.
+		if srcVmName == dstVmName {
+			return true
+		}
+
+		srcCloudName := soln.AppsSolution[appName].TargetCloudName
+		dstCloudName := soln.AppsSolution[depAppName].TargetCloudName
+		netState := clouds[srcCloudName].NetState[dstCloudName]
+		// If the RTT is too large or the bandwidth is too small, this replica is not reachable.
+		if netState.Rtt <= maxRttMs && netState.BandwidthMbps >= dep.MinBandwidthMbps {
+			return true
 		}
 	}
+	return false
+}
 
-	// all clouds passed
+// Check whether a solution is acceptable in terms of the placement of replicas.
+// The accepted replicas of an application should be on different Kubernetes nodes, and if required, on different clouds.
+func replicaAcc(apps map[string]asmodel.Application, soln asmodel.Solution) bool {
+	for _, replicaNames := range asmodel.ReplicaGroups(apps) {
+		if len(replicaNames) < 2 {
+			continue
+		}
+
+		usedNodes := make(map[string]struct{})
+		usedClouds := make(map[string]struct{})
+		for _, replicaName := range replicaNames {
+			replicaSoln := soln.AppsSolution[replicaName]
+			if !replicaSoln.Accepted {
+				continue
+			}
+			if _, exist := usedNodes[replicaSoln.K8sNodeName]; exist {
+				return false
+			}
+			usedNodes[replicaSoln.K8sNodeName] = struct{}{}
+
+			if apps[replicaName].ReplicaSpread == asmodel.ReplicaSpreadCloud {
+				if _, exist := usedClouds[replicaSoln.TargetCloudName]; exist {
+					return false
+				}
+				usedClouds[replicaSoln.TargetCloudName] = struct{}{}
+			}
+		}
+	}
 	return true
 }
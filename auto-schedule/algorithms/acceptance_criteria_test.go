@@ -464,3 +464,187 @@ func TestInnerDepAcc(t *testing.T) {
 	}
 
 }
+
+func TestInnerReplicaAcc(t *testing.T) {
+	apps := asmodel.ExpandReplicas(map[string]asmodel.Application{
+		"app1": {Name: "app1", Priority: 5, Replicas: 1},
+		"app2": {Name: "app2", Priority: 5, Replicas: 2},
+		"app3": {Name: "app3", Priority: 5, Replicas: 2, ReplicaSpread: asmodel.ReplicaSpreadCloud},
+	})
+
+	testCases := []struct {
+		name           string
+		soln           asmodel.Solution
+		expectedResult bool
+	}{
+		{
+			name: "replicas on different nodes",
+			soln: asmodel.Solution{
+				AppsSolution: map[string]asmodel.SingleAppSolution{
+					"app1":   {Accepted: true, TargetCloudName: "NOKIA4", K8sNodeName: "node1"},
+					"app2#0": {Accepted: true, TargetCloudName: "NOKIA4", K8sNodeName: "node1"},
+					"app2#1": {Accepted: true, TargetCloudName: "NOKIA4", K8sNodeName: "node2"},
+					"app3#0": {Accepted: true, TargetCloudName: "NOKIA4", K8sNodeName: "node1"},
+					"app3#1": {Accepted: true, TargetCloudName: "NOKIA7", K8sNodeName: "node3"},
+				},
+			},
+			expectedResult: true,
+		},
+		{
+			name: "replicas on the same node",
+			soln: asmodel.Solution{
+				AppsSolution: map[string]asmodel.SingleAppSolution{
+					"app1":   {Accepted: true, TargetCloudName: "NOKIA4", K8sNodeName: "node1"},
+					"app2#0": {Accepted: true, TargetCloudName: "NOKIA4", K8sNodeName: "node1"},
+					"app2#1": {Accepted: true, TargetCloudName: "NOKIA4", K8sNodeName: "node1"},
+					"app3#0": {Accepted: false},
+					"app3#1": {Accepted: false},
+				},
+			},
+			expectedResult: false,
+		},
+		{
+			name: "replicas on the same cloud with cloud spread",
+			soln: asmodel.Solution{
+				AppsSolution: map[string]asmodel.SingleAppSolution{
+					"app1":   {Accepted: false},
+					"app2#0": {Accepted: true, TargetCloudName: "NOKIA4", K8sNodeName: "node1"},
+					"app2#1": {Accepted: false},
+					"app3#0": {Accepted: true, TargetCloudName: "NOKIA4", K8sNodeName: "node1"},
+					"app3#1": {Accepted: true, TargetCloudName: "NOKIA4", K8sNodeName: "node2"},
+				},
+			},
+			expectedResult: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Logf("test: %s", testCase.name)
+		actualResult := replicaAcc(apps, testCase.soln)
+		assert.Equal(t, testCase.expectedResult, actualResult, fmt.Sprintf("%s: result is not expected", testCase.name))
+	}
+}
+
+func TestInnerDepAccReplicas(t *testing.T) {
+	clouds := map[string]asmodel.Cloud{
+		"NOKIA4": {Name: "NOKIA4", NetState: map[string]models.NetworkState{"NOKIA4": {Rtt: 1}, "NOKIA7": {Rtt: maxAccRttMs + 1}}},
+		"NOKIA7": {Name: "NOKIA7", NetState: map[string]models.NetworkState{"NOKIA4": {Rtt: maxAccRttMs + 1}, "NOKIA7": {Rtt: 1}}},
+	}
+	apps := asmodel.ExpandReplicas(map[string]asmodel.Application{
+		"app1": {Name: "app1", Priority: 5, Replicas: 1, Dependencies: []models.Dependency{{AppName: "app2"}}},
+		"app2": {Name: "app2", Priority: 5, Replicas: 2},
+	})
+
+	testCases := []struct {
+		name           string
+		soln           asmodel.Solution
+		expectedResult bool
+	}{
+		{
+			name: "one replica reachable",
+			soln: asmodel.Solution{
+				AppsSolution: map[string]asmodel.SingleAppSolution{
+					"app1":   {Accepted: true, TargetCloudName: "NOKIA4", K8sNodeName: "node1"},
+					"app2#0": {Accepted: true, TargetCloudName: "NOKIA7", K8sNodeName: "node3"},
+					"app2#1": {Accepted: true, TargetCloudName: "NOKIA4", K8sNodeName: "node2"},
+				},
+			},
+			expectedResult: true,
+		},
+		{
+			name: "only unreachable replica accepted",
+			soln: asmodel.Solution{
+				AppsSolution: map[string]asmodel.SingleAppSolution{
+					"app1":   {Accepted: true, TargetCloudName: "NOKIA4", K8sNodeName: "node1"},
+					"app2#0": {Accepted: true, TargetCloudName: "NOKIA7", K8sNodeName: "node3"},
+					"app2#1": {Accepted: false},
+				},
+			},
+			expectedResult: false,
+		},
+		{
+			name: "no replica accepted",
+			soln: asmodel.Solution{
+				AppsSolution: map[string]asmodel.SingleAppSolution{
+					"app1":   {Accepted: true, TargetCloudName: "NOKIA4", K8sNodeName: "node1"},
+					"app2#0": {Accepted: false},
+					"app2#1": {Accepted: false},
+				},
+			},
+			expectedResult: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Logf("test: %s", testCase.name)
+		actualResult := depAcc(clouds, apps, testCase.soln)
+		assert.Equal(t, testCase.expectedResult, actualResult, fmt.Sprintf("%s: result is not expected", testCase.name))
+	}
+}
+
+func TestInnerDepAccNetRequirements(t *testing.T) {
+	clouds := map[string]asmodel.Cloud{
+		"NOKIA4": {Name: "NOKIA4", NetState: map[string]models.NetworkState{"NOKIA4": {Rtt: 1, BandwidthMbps: 1000}, "NOKIA7": {Rtt: 50, BandwidthMbps: 100}}},
+		"NOKIA7": {Name: "NOKIA7", NetState: map[string]models.NetworkState{"NOKIA4": {Rtt: 50, BandwidthMbps: 100}, "NOKIA7": {Rtt: 1, BandwidthMbps: 1000}}},
+	}
+	crossCloudSoln := asmodel.Solution{
+		AppsSolution: map[string]asmodel.SingleAppSolution{
+			"app1": {Accepted: true, TargetCloudName: "NOKIA4", K8sNodeName: "node1"},
+			"app2": {Accepted: true, TargetCloudName: "NOKIA7", K8sNodeName: "node2"},
+		},
+	}
+	sameVmSoln := asmodel.Solution{
+		AppsSolution: map[string]asmodel.SingleAppSolution{
+			"app1": {Accepted: true, TargetCloudName: "NOKIA4", K8sNodeName: "node1"},
+			"app2": {Accepted: true, TargetCloudName: "NOKIA4", K8sNodeName: "node1"},
+		},
+	}
+
+	testCases := []struct {
+		name           string
+		dep            models.Dependency
+		soln           asmodel.Solution
+		expectedResult bool
+	}{
+		{
+			name:           "no requirements",
+			dep:            models.Dependency{AppName: "app2"},
+			soln:           crossCloudSoln,
+			expectedResult: true,
+		},
+		{
+			name:           "requirements met",
+			dep:            models.Dependency{AppName: "app2", MinBandwidthMbps: 100, MaxRttMs: 50},
+			soln:           crossCloudSoln,
+			expectedResult: true,
+		},
+		{
+			name:           "rtt too large",
+			dep:            models.Dependency{AppName: "app2", MaxRttMs: 20},
+			soln:           crossCloudSoln,
+			expectedResult: false,
+		},
+		{
+			name:           "bandwidth too small",
+			dep:            models.Dependency{AppName: "app2", MinBandwidthMbps: 500},
+			soln:           crossCloudSoln,
+			expectedResult: false,
+		},
+		{
+			name:           "same vm",
+			dep:            models.Dependency{AppName: "app2", MinBandwidthMbps: 5000, MaxRttMs: 0.1},
+			soln:           sameVmSoln,
+			expectedResult: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Logf("test: %s", testCase.name)
+		apps := map[string]asmodel.Application{
+			"app1": {Name: "app1", Priority: 5, Dependencies: []models.Dependency{testCase.dep}},
+			"app2": {Name: "app2", Priority: 5},
+		}
+		actualResult := depAcc(clouds, apps, testCase.soln)
+		assert.Equal(t, testCase.expectedResult, actualResult, fmt.Sprintf("%s: result is not expected", testCase.name))
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/astaxie/beego"
 	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	asmodel "emcontroller/auto-schedule/model"
 	"emcontroller/models"
@@ -41,16 +42,20 @@ func GcASVms() {
 	copy(vmsToDelete, autoVms)
 
 	// We delete all auto-scheduling kubernetes nodes and auto-scheduling VMs which do not have any Kubernetes applications running.
+	// The applications of all tenants are checked, and the pods of DaemonSets and the static pods are not applications.
 	for _, node := range autoK8sNodes {
-		podsOnNode, err := models.ListPodsOnNode(models.KubernetesNamespace, node.Name)
+		podsOnNode, err := models.ListPodsOnNode(metav1.NamespaceAll, node.Name)
 		if err != nil {
 			outErr := fmt.Errorf("List pods on Kubernetes node [%s], error: %w", node.Name, err)
 			beego.Error(outErr)
 			return
 		}
-		if len(podsOnNode) > 0 { // if this node has applications running, we remove it from the delete list.
-			models.RemoveNodeFromList(&k8sNodesToDelete, node.Name)
-			models.RemoveVmFromList(&vmsToDelete, node.Name)
+		for _, pod := range podsOnNode {
+			if !models.IsNodeBoundPod(pod) { // if this node has applications running, we remove it from the delete list.
+				models.RemoveNodeFromList(&k8sNodesToDelete, node.Name)
+				models.RemoveVmFromList(&vmsToDelete, node.Name)
+				break
+			}
 		}
 	}
 
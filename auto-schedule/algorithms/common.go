@@ -1,6 +1,8 @@
 package algorithms
 
 import (
+	"context"
+	"math"
 	"sync"
 
 	asmodel "emcontroller/auto-schedule/model"
@@ -35,6 +37,25 @@ var (
 	ScheMu sync.Mutex
 )
 
+// The iterative algorithms implement this, so that they can stop iterating when the scheduling job is cancelled.
+type ContextSetter interface {
+	SetContext(ctx context.Context)
+}
+
+// Embedded in the iterative algorithms to implement ContextSetter. Without a context, an algorithm is never cancelled.
+type cancellable struct {
+	ctx context.Context
+}
+
+func (c *cancellable) SetContext(ctx context.Context) {
+	c.ctx = ctx
+}
+
+// Whether the algorithm should stop iterating. The result is discarded when the job is cancelled, so the algorithm can return any solution.
+func (c *cancellable) cancelled() bool {
+	return c.ctx != nil && c.ctx.Err() != nil
+}
+
 // SchedulingAlgorithm is the interface that all algorithms should implement
 type SchedulingAlgorithm interface {
 	Schedule(clouds map[string]asmodel.Cloud, apps map[string]asmodel.Application, appsOrder []string) (asmodel.Solution, error)
@@ -46,13 +67,16 @@ type SchedulingAlgorithm interface {
 // 3. Check whether this solution is acceptable.
 // If this solution passes the above 3 things, we return the refined solution.
 func RefineSoln(clouds map[string]asmodel.Cloud, apps map[string]asmodel.Application, appsOrder []string, soln asmodel.Solution) (asmodel.Solution, bool) {
+	// 0. the applications with fixed placements stay where they are
+	soln = pinFixedApps(apps, soln)
+	movable := movableApps(apps)
 	// 1. give the solution node names
-	solnWithVm, vmAcceptable := allocateVms(clouds, apps, appsOrder, soln)
+	solnWithVm, vmAcceptable := allocateVms(clouds, movable, appsOrder, soln)
 	if !vmAcceptable {
 		return asmodel.Solution{}, false
 	}
 	// 2. Allocate CPU cores
-	solnWithCpu, cpuAcceptable := allocateCpus(clouds, apps, appsOrder, solnWithVm)
+	solnWithCpu, cpuAcceptable := allocateCpus(clouds, movable, appsOrder, solnWithVm)
 	if !cpuAcceptable {
 		return asmodel.Solution{}, false
 	}
@@ -62,3 +86,75 @@ func RefineSoln(clouds map[string]asmodel.Cloud, apps map[string]asmodel.Applica
 	}
 	return solnWithCpu, true
 }
+
+// Put the applications with fixed placements at their placements in the solution, whatever the algorithm decides for them.
+func pinFixedApps(apps map[string]asmodel.Application, soln asmodel.Solution) asmodel.Solution {
+	pinned := asmodel.SolutionCopy(soln)
+	for appName, app := range apps {
+		if !app.Fixed() {
+			continue
+		}
+		placement := app.FixedPlacements[0]
+		pinned.AppsSolution[appName] = asmodel.SingleAppSolution{
+			Accepted:         true,
+			TargetCloudName:  placement.TargetCloudName,
+			K8sNodeName:      placement.K8sNodeName,
+			AllocatedCpuCore: placement.AllocatedCpuCore,
+		}
+	}
+	return pinned
+}
+
+// The applications without fixed placements, to which VMs and CPUs are allocated. The resources of the fixed ones are already in use in the clouds.
+func movableApps(apps map[string]asmodel.Application) map[string]asmodel.Application {
+	movable := make(map[string]asmodel.Application, len(apps))
+	for appName, app := range apps {
+		if !app.Fixed() {
+			movable[appName] = app
+		}
+	}
+	return movable
+}
+
+// The weight of the fitness value contributed by an application. An application with multiple replicas is expanded into replicas, and all its replicas together contribute as much as an application with 1 replica.
+func replicaFitnessWeight(app asmodel.Application) float64 {
+	if len(app.ReplicaOf) != 0 && app.Replicas > 1 {
+		return 1 / float64(app.Replicas)
+	}
+	return 1
+}
+
+// The RTT from an application to its dependent application. If the dependent application has multiple replicas, the application will access the nearest accepted replica.
+func depRtt(clouds map[string]asmodel.Cloud, apps map[string]asmodel.Application, chromosome asmodel.Solution, thisAppName string, depAppName string) float64 {
+	depReplicaNames := asmodel.DepReplicaNames(apps, depAppName)
+	var candidates []string
+	for _, name := range depReplicaNames {
+		if chromosome.AppsSolution[name].Accepted {
+			candidates = append(candidates, name)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = depReplicaNames
+	}
+	if len(candidates) == 0 {
+		candidates = []string{depAppName}
+	}
+
+	thisCloudName := chromosome.AppsSolution[thisAppName].TargetCloudName
+	thisNodeName := chromosome.AppsSolution[thisAppName].K8sNodeName
+
+	minRtt := math.MaxFloat64
+	for _, name := range candidates {
+		// RTT from this application to this replica of the dependent application. We consider the RTT inside a same VM as 0.
+		var thisRtt float64
+		if thisNodeName == chromosome.AppsSolution[name].K8sNodeName {
+			thisRtt = 0
+		} else {
+			thisRtt = clouds[thisCloudName].NetState[chromosome.AppsSolution[name].TargetCloudName].Rtt
+		}
+		if thisRtt < minRtt {
+			minRtt = thisRtt
+		}
+	}
+	return minRtt
+}
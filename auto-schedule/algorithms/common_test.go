@@ -1 +1,81 @@
 package algorithms
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	asmodel "emcontroller/auto-schedule/model"
+	"emcontroller/models"
+)
+
+func TestInnerRefineSolnFixedApps(t *testing.T) {
+	nodeRes := asmodel.GenericResources{CpuCore: 4, Memory: 8192, Storage: 50}
+	clouds := map[string]asmodel.Cloud{
+		"NOKIA4": {Name: "NOKIA4", K8sNodes: []asmodel.K8sNode{{Name: "node1", ResidualResources: nodeRes}}, NetState: map[string]models.NetworkState{"NOKIA4": {Rtt: 1}, "NOKIA7": {Rtt: 50}}},
+		"NOKIA7": {Name: "NOKIA7", K8sNodes: []asmodel.K8sNode{{Name: "node2", ResidualResources: nodeRes}}, NetState: map[string]models.NetworkState{"NOKIA4": {Rtt: 50}, "NOKIA7": {Rtt: 1}}},
+	}
+	// app1 depends on the application "dep", which is not scheduled and stays on node2
+	apps := map[string]asmodel.Application{
+		"app1": {Name: "app1", Priority: 5, Resources: asmodel.AppResources{GenericResources: asmodel.GenericResources{CpuCore: 1, Memory: 100, Storage: 1}}, Dependencies: []models.Dependency{{AppName: "dep", MaxRttMs: 20}}},
+		"dep":  {Name: "dep", Priority: asmodel.MinPriority, FixedPlacements: []asmodel.ReplicaSolution{{TargetCloudName: "NOKIA7", K8sNodeName: "node2", AllocatedCpuCore: 2}}},
+	}
+	appsOrder := []string{"app1", "dep"}
+	fixedSoln := asmodel.SingleAppSolution{Accepted: true, TargetCloudName: "NOKIA7", K8sNodeName: "node2", AllocatedCpuCore: 2}
+
+	testCases := []struct {
+		name               string
+		soln               asmodel.Solution
+		expectedAcceptable bool
+		expectedApp1Node   string
+	}{
+		{
+			name: "near the fixed dependency",
+			soln: asmodel.Solution{AppsSolution: map[string]asmodel.SingleAppSolution{
+				"app1": {Accepted: true, TargetCloudName: "NOKIA7"},
+				"dep":  {Accepted: true, TargetCloudName: "NOKIA7"},
+			}},
+			expectedAcceptable: true,
+			expectedApp1Node:   "node2",
+		},
+		{
+			// the algorithm moves or rejects the fixed dependency, but it stays where it is
+			name: "fixed dependency moved by the algorithm",
+			soln: asmodel.Solution{AppsSolution: map[string]asmodel.SingleAppSolution{
+				"app1": {Accepted: true, TargetCloudName: "NOKIA7"},
+				"dep":  {Accepted: false},
+			}},
+			expectedAcceptable: true,
+			expectedApp1Node:   "node2",
+		},
+		{
+			name: "too far from the fixed dependency",
+			soln: asmodel.Solution{AppsSolution: map[string]asmodel.SingleAppSolution{
+				"app1": {Accepted: true, TargetCloudName: "NOKIA4"},
+				"dep":  {Accepted: true, TargetCloudName: "NOKIA4"},
+			}},
+			expectedAcceptable: false,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		for _, refine := range []struct {
+			name string
+			fn   func(map[string]asmodel.Cloud, map[string]asmodel.Application, []string, asmodel.Solution) (asmodel.Solution, bool)
+		}{{"RefineSoln", RefineSoln}, {"CmpRefineSoln", CmpRefineSoln}} {
+			refined, acceptable := refine.fn(clouds, apps, appsOrder, testCase.soln)
+			assert.Equal(t, testCase.expectedAcceptable, acceptable, fmt.Sprintf("%s, %s: acceptable is not expected", testCase.name, refine.name))
+			if !acceptable {
+				continue
+			}
+			assert.Equal(t, fixedSoln, refined.AppsSolution["dep"], fmt.Sprintf("%s, %s: the fixed application is moved", testCase.name, refine.name))
+			assert.Equal(t, testCase.expectedApp1Node, refined.AppsSolution["app1"].K8sNodeName, fmt.Sprintf("%s, %s: node of app1 is not expected", testCase.name, refine.name))
+			// The resources of the fixed application are already in use in the node, so they are not allocated again. CmpRefineSoln allocates CPUs randomly.
+			if refine.name == "RefineSoln" {
+				assert.Equal(t, float64(1), refined.AppsSolution["app1"].AllocatedCpuCore, fmt.Sprintf("%s: CPU of app1 is not expected", testCase.name))
+			}
+		}
+	}
+}
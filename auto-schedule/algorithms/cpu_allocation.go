@@ -30,9 +30,35 @@ func allocateCpus(clouds map[string]asmodel.Cloud, apps map[string]asmodel.Appli
 		solnWithCpu.Absorb(solnWithCpuThisCloud)
 	}
 
+	equalizeReplicasCpu(apps, solnWithCpu)
+
 	return solnWithCpu, true
 }
 
+// All replicas of an application are deployed by one Kubernetes deployment, so they must have the same CPU. We give all replicas the minimum CPU among them, which can certainly be allocated on every VM.
+func equalizeReplicasCpu(apps map[string]asmodel.Application, solnWithCpu asmodel.Solution) {
+	for _, replicaNames := range asmodel.ReplicaGroups(apps) {
+		if len(replicaNames) < 2 {
+			continue
+		}
+
+		minCpu := math.MaxFloat64
+		for _, replicaName := range replicaNames {
+			if replicaSoln := solnWithCpu.AppsSolution[replicaName]; replicaSoln.Accepted && replicaSoln.AllocatedCpuCore < minCpu {
+				minCpu = replicaSoln.AllocatedCpuCore
+			}
+		}
+
+		for _, replicaName := range replicaNames {
+			replicaSoln := solnWithCpu.AppsSolution[replicaName]
+			if replicaSoln.Accepted {
+				replicaSoln.AllocatedCpuCore = minCpu
+				solnWithCpu.AppsSolution[replicaName] = replicaSoln
+			}
+		}
+	}
+}
+
 // allocate cpus in one cloud
 func allocateCpusOneCloud(cloud asmodel.Cloud, apps map[string]asmodel.Application, appsOrder []string, solnWithVm asmodel.Solution) (asmodel.Solution, bool) {
 	// For every cloud, at first, we find out the applications scheduled on it.
@@ -161,17 +187,18 @@ func vmCpuWeightedAllocation(vm asmodel.K8sNode, appsThisVm map[string]asmodel.A
 
 		// do the Step 1.1 described in the following comments
 		for appName, allocatedCpu := range cpuAllocScheme {
-			if allocatedCpu <= cpuCoreStep {
+			appMinCpu := minCpuOfApp(remainingApps[appName])
+			if allocatedCpu <= appMinCpu {
 
 				minCpuFound = true
 
 				// the CPU allocation of this application is decided
 				thisAppSoln := solnWithCpuThisVm.AppsSolution[appName]
-				thisAppSoln.AllocatedCpuCore = cpuCoreStep
+				thisAppSoln.AllocatedCpuCore = appMinCpu
 				solnWithCpuThisVm.AppsSolution[appName] = thisAppSoln
 
-				delete(remainingApps, appName)                  // no need to handle this application later
-				vmCopy.ResidualResources.CpuCore -= cpuCoreStep // subtract the CPU allocated to this app from the VM
+				delete(remainingApps, appName)                // no need to handle this application later
+				vmCopy.ResidualResources.CpuCore -= appMinCpu // subtract the CPU allocated to this app from the VM
 			}
 		}
 
@@ -320,9 +347,13 @@ func distrCpuNextApp(vm asmodel.K8sNode, apps map[string]asmodel.Application, ap
 	return thisAppName, allocatedCpus
 }
 
-// When the code reaches here, the VM's CPUs should be certainly enough if every application is allocated cpuCoreStep CPU.
+// When the code reaches here, the VM's CPUs should be certainly enough if every application is allocated its minimum CPU.
 func checkValidDistriCpu(vm asmodel.K8sNode, apps map[string]asmodel.Application) {
-	if vm.ResidualResources.CpuCore < float64(len(apps))*cpuCoreStep {
+	var sumMinCpu float64
+	for _, app := range apps {
+		sumMinCpu += minCpuOfApp(app)
+	}
+	if vm.ResidualResources.CpuCore < sumMinCpu {
 		panic(fmt.Sprintf("vm.ResidualResources.CpuCore [%f] is not enough for the minimum CPU of [%d] applications.", vm.ResidualResources.CpuCore, len(apps)))
 	}
 }
@@ -37,6 +37,8 @@ type Amaga struct {
 
 	// record the best fitness value in every iteration, to show the evolution trend of the populations
 	BestFitnessEachIter []float64
+
+	cancellable // stop iterating when the scheduling job is cancelled
 }
 
 func NewAmaga(chromosomesCount int, iterationCount int, crossoverProbability float64, mutationProbability float64, stopNoUpdateIteration int) *Amaga {
@@ -64,6 +66,9 @@ func (a *Amaga) Schedule(clouds map[string]asmodel.Cloud, apps map[string]asmode
 
 	// No. 1 iteration to No. m.IterationCount iteration
 	for iteration := 1; iteration <= a.IterationCount; iteration++ {
+		if a.cancelled() {
+			break
+		}
 
 		currentPopulation = a.crossoverOperator(clouds, apps, appsOrder, currentPopulation)
 
@@ -14,13 +14,16 @@ import (
 
 // the function to refine solutions in the algorithms for comparison.
 func CmpRefineSoln(clouds map[string]asmodel.Cloud, apps map[string]asmodel.Application, appsOrder []string, soln asmodel.Solution) (asmodel.Solution, bool) {
+	// 0. the applications with fixed placements stay where they are
+	soln = pinFixedApps(apps, soln)
+	movable := movableApps(apps)
 	// 1. give the solution node names
-	solnWithVm, vmAcceptable := cmpAllocateVms(clouds, apps, appsOrder, soln)
+	solnWithVm, vmAcceptable := cmpAllocateVms(clouds, movable, appsOrder, soln)
 	if !vmAcceptable {
 		return asmodel.Solution{}, false
 	}
 	// 2. Allocate CPU cores
-	solnWithCpu, cpuAcceptable := cmpAllocateCpus(clouds, apps, appsOrder, solnWithVm)
+	solnWithCpu, cpuAcceptable := cmpAllocateCpus(clouds, movable, appsOrder, solnWithVm)
 	if !cpuAcceptable {
 		return asmodel.Solution{}, false
 	}
@@ -40,6 +40,8 @@ type Diktyoga struct {
 
 	// record the best fitness value in every iteration, to show the evolution trend of the populations
 	BestFitnessEachIter []float64
+
+	cancellable // stop iterating when the scheduling job is cancelled
 }
 
 func NewDiktyoga(chromosomesCount int, iterationCount int, crossoverProbability float64, mutationProbability float64, stopNoUpdateIteration int) *Diktyoga {
@@ -95,6 +97,9 @@ func (d *Diktyoga) Schedule(clouds map[string]asmodel.Cloud, apps map[string]asm
 
 	// No. 1 iteration to No. m.IterationCount iteration
 	for iteration := 1; iteration <= d.IterationCount; iteration++ {
+		if d.cancelled() {
+			break
+		}
 
 		currentPopulation = d.crossoverOperator(clouds, apps, appsOrder, currentPopulation)
 
@@ -219,7 +224,7 @@ func (d *Diktyoga) Fitness(clouds map[string]asmodel.Cloud, apps map[string]asmo
 	var fitnessValue float64
 
 	for appName, _ := range apps {
-		fitnessValue += d.fitnessOneApp(clouds, apps, chromosome, appName)
+		fitnessValue += d.fitnessOneApp(clouds, apps, chromosome, appName) * replicaFitnessWeight(apps[appName])
 	}
 
 	return fitnessValue
@@ -238,21 +243,8 @@ func (d *Diktyoga) fitnessOneApp(clouds map[string]asmodel.Cloud, apps map[strin
 
 		netPart := d.MaxReachableRtt * d.AvgDepNum // the base network part of fitness.
 		for _, dep := range apps[thisAppName].Dependencies {
-			depAppName := dep.AppName
-
 			// calculate the network part of the fitness value of this dependency
-			thisCloudName := chromosome.AppsSolution[thisAppName].TargetCloudName
-			depCloudName := chromosome.AppsSolution[depAppName].TargetCloudName
-			thisNodeName := chromosome.AppsSolution[thisAppName].K8sNodeName
-			depNodeName := chromosome.AppsSolution[depAppName].K8sNodeName
-
-			var thisRtt float64 // RTT from this application to the dependent application
-
-			if thisNodeName == depNodeName { // We consider the RTT inside a same VM as 0.
-				thisRtt = 0
-			} else {
-				thisRtt = clouds[thisCloudName].NetState[depCloudName].Rtt
-			}
+			thisRtt := depRtt(clouds, apps, chromosome, thisAppName, dep.AppName) // RTT from this application to the dependent application
 
 			netPart -= thisRtt
 			/**
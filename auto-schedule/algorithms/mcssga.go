@@ -58,6 +58,8 @@ type Mcssga struct {
 
 	// record the best fitness value in every iteration, to show the evolution trend of the populations
 	BestFitnessEachIter []float64
+
+	cancellable // stop iterating when the scheduling job is cancelled
 }
 
 func NewMcssga(chromosomesCount int, iterationCount int, crossoverProbability float64, mutationProbability float64, stopNoUpdateIteration int, exTimeOneCpu float64) *Mcssga {
@@ -130,6 +132,9 @@ func (m *Mcssga) Schedule(clouds map[string]asmodel.Cloud, apps map[string]asmod
 
 	// No. 1 iteration to No. m.IterationCount iteration
 	for iteration := 1; iteration <= m.IterationCount; iteration++ {
+		if m.cancelled() {
+			break
+		}
 
 		currentPopulation = m.crossoverOperator(clouds, apps, appsOrder, currentPopulation)
 
@@ -504,7 +509,7 @@ func (m *Mcssga) Fitness(clouds map[string]asmodel.Cloud, apps map[string]asmode
 // calculate the fitness value contributed by an application
 func (m *Mcssga) fitnessOneApp(clouds map[string]asmodel.Cloud, apps map[string]asmodel.Application, chromosome asmodel.Solution, thisAppName string) float64 {
 	thisPri := apps[thisAppName].Priority // the fitness values should be weighted by applications' priorities.
-	return m.fitnessOneAppNonPri(clouds, apps, chromosome, thisAppName) * float64(thisPri)
+	return m.fitnessOneAppNonPri(clouds, apps, chromosome, thisAppName) * float64(thisPri) * replicaFitnessWeight(apps[thisAppName])
 }
 
 // calculate the fitness value contributed by an application without the consideration of its priority
@@ -531,21 +536,8 @@ func (m *Mcssga) fitnessOneAppNonPri(clouds map[string]asmodel.Cloud, apps map[s
 
 		netPart := m.MaxReachableRtt * m.AvgDepNum // the base network part of fitness.
 		for _, dep := range apps[thisAppName].Dependencies {
-			depAppName := dep.AppName
-
 			// calculate the network part of the fitness value of this dependency
-			thisCloudName := chromosome.AppsSolution[thisAppName].TargetCloudName
-			depCloudName := chromosome.AppsSolution[depAppName].TargetCloudName
-			thisNodeName := chromosome.AppsSolution[thisAppName].K8sNodeName
-			depNodeName := chromosome.AppsSolution[depAppName].K8sNodeName
-
-			var thisRtt float64 // RTT from this application to the dependent application
-
-			if thisNodeName == depNodeName { // We consider the RTT inside a same VM as 0.
-				thisRtt = 0
-			} else {
-				thisRtt = clouds[thisCloudName].NetState[depCloudName].Rtt
-			}
+			thisRtt := depRtt(clouds, apps, chromosome, thisAppName, dep.AppName) // RTT from this application to the dependent application
 
 			netPart -= thisRtt
 			/**
@@ -40,6 +40,8 @@ type Mtdp struct {
 	BestFitnessRecords    []float64
 	BestSolnRecords       []asmodel.Solution
 	BestFitnessEachIter   []float64
+
+	cancellable // stop iterating when the scheduling job is cancelled
 }
 
 func NewMtdp(chromosomesCount, iterationCount int, crossoverProbability, mutationProbability float64, stopNoUpdateIteration int) *Mtdp {
@@ -62,6 +64,9 @@ func (m *Mtdp) Schedule(clouds map[string]asmodel.Cloud, apps map[string]asmodel
 	currentPopulation := m.selectionOperator(clouds, apps, initPopulation)
 
 	for iter := 1; iter <= m.IterationCount; iter++ {
+		if m.cancelled() {
+			break
+		}
 		currentPopulation = m.crossoverOperator(clouds, apps, appsOrder, currentPopulation)
 		currentPopulation = m.mutationOperator(clouds, apps, appsOrder, currentPopulation)
 		currentPopulation = m.selectionOperator(clouds, apps, currentPopulation)
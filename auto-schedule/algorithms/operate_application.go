@@ -6,8 +6,13 @@ import asmodel "emcontroller/auto-schedule/model"
 func findAppsOneCloud(cloud asmodel.Cloud, apps map[string]asmodel.Application, soln asmodel.Solution) map[string]asmodel.Application {
 	appsThisCloud := make(map[string]asmodel.Application)
 	for appName, appSoln := range soln.AppsSolution {
+		app, exist := apps[appName]
+		// the solution may have applications not to handle, e.g., the applications with fixed placements
+		if !exist {
+			continue
+		}
 		if appSoln.Accepted && appSoln.TargetCloudName == cloud.Name {
-			appsThisCloud[appName] = asmodel.AppCopy(apps[appName])
+			appsThisCloud[appName] = asmodel.AppCopy(app)
 		}
 	}
 	return appsThisCloud
@@ -66,7 +71,7 @@ func calcNeededRes(apps map[string]asmodel.Application, appNames []string, minCp
 	for _, appName := range appNames {
 		var cpuToOccupy float64
 		if minCpu {
-			cpuToOccupy = cpuCoreStep
+			cpuToOccupy = minCpuOfApp(apps[appName])
 		} else {
 			cpuToOccupy = apps[appName].Resources.CpuCore
 		}
@@ -77,3 +82,13 @@ func calcNeededRes(apps map[string]asmodel.Application, appNames []string, minCp
 	}
 	return neededRes
 }
+
+// CPU is a soft resource, so we think that cpuCoreStep CPU is the minimum requirement for each application.
+// For an application with multiple containers requesting CPU, every container needs at least cpuCoreStep CPU.
+func minCpuOfApp(app asmodel.Application) float64 {
+	minCpu := cpuCoreStep * float64(app.CpuContainerNum())
+	if minCpu < cpuCoreStep {
+		return cpuCoreStep
+	}
+	return minCpu
+}
@@ -473,6 +473,22 @@ func TestInnerCalcNeededRes(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "multiple containers minCpu",
+			apps: map[string]asmodel.Application{
+				"app1": {Name: "app1", Priority: 5, ContainerCpus: []float64{2, 1, 0}, Resources: asmodel.AppResources{GenericResources: asmodel.GenericResources{CpuCore: 3, Memory: 100, Storage: 5}}},
+				"app2": {Name: "app2", Priority: 5, ContainerCpus: []float64{0}, Resources: asmodel.AppResources{GenericResources: asmodel.GenericResources{CpuCore: 0, Memory: 100, Storage: 5}}},
+			},
+			appNames: []string{"app1", "app2"},
+			minCpu:   true,
+			expectedResult: asmodel.AppResources{
+				GenericResources: asmodel.GenericResources{
+					CpuCore: 3,
+					Memory:  200,
+					Storage: 10,
+				},
+			},
+		},
 	}
 
 	for i, testCase := range testCases {
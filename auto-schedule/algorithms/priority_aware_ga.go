@@ -73,6 +73,8 @@ type PriorityAwareGA struct {
 
 	// record the best fitness in each iteration
 	BestFitnessEachIter []float64
+
+	cancellable // stop iterating when the scheduling job is cancelled
 }
 
 // Constructor
@@ -153,6 +155,9 @@ func (p *PriorityAwareGA) Schedule(
 
 	// 3. GA loop
 	for iteration := 1; iteration <= p.IterationCount; iteration++ {
+		if p.cancelled() {
+			break
+		}
 		currentPopulation = p.crossoverOperator(clouds, apps, appsOrder, currentPopulation)
 		currentPopulation = p.mutationOperator(clouds, apps, appsOrder, currentPopulation)
 		currentPopulation = p.selectionOperator(clouds, apps, currentPopulation)
@@ -467,7 +472,7 @@ func (p *PriorityAwareGA) Fitness(
 			priWeight = 1.0 + PriorityBonusScale*t
 		}
 
-		baseServiceFitness += nonPriFit * priWeight
+		baseServiceFitness += nonPriFit * priWeight * replicaFitnessWeight(apps[appName])
 	}
 
 	if totalApps == 0 {
@@ -578,19 +583,7 @@ func (p *PriorityAwareGA) fitnessOneAppNonPri(
 		// network part
 		netPart := p.MaxReachableRtt * p.AvgDepNum
 		for _, dep := range apps[thisAppName].Dependencies {
-			depAppName := dep.AppName
-
-			thisCloudName := chromosome.AppsSolution[thisAppName].TargetCloudName
-			depCloudName := chromosome.AppsSolution[depAppName].TargetCloudName
-			thisNodeName := chromosome.AppsSolution[thisAppName].K8sNodeName
-			depNodeName := chromosome.AppsSolution[depAppName].K8sNodeName
-
-			var thisRtt float64
-			if thisNodeName == depNodeName {
-				thisRtt = 0
-			} else {
-				thisRtt = clouds[thisCloudName].NetState[depCloudName].Rtt
-			}
+			thisRtt := depRtt(clouds, apps, chromosome, thisAppName, dep.AppName)
 
 			netPart -= thisRtt
 		}
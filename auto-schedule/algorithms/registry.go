@@ -0,0 +1,240 @@
+package algorithms
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// The types of the parameters of scheduling algorithms.
+type ParamType string
+
+const (
+	ParamTypeInt   ParamType = "int"
+	ParamTypeFloat ParamType = "float"
+
+	// the names of the parameters shared by the genetic algorithms
+	ChromosomesCountParam      string = "chromosomesCount"
+	IterationCountParam        string = "iterationCount"
+	CrossoverProbabilityParam  string = "crossoverProbability"
+	MutationProbabilityParam   string = "mutationProbability"
+	StopNoUpdateIterationParam string = "stopNoUpdateIteration"
+	ExTimeOneCpuParam          string = "expAppCompuTimeOneCpu"
+
+	// the algorithm used when users do not choose one
+	DefaultAlgoName string = McssgaName
+)
+
+// The schema of a parameter of a scheduling algorithm.
+type ParamSpec struct {
+	Name        string    `json:"name"`
+	Type        ParamType `json:"type"`
+	Default     float64   `json:"default"`
+	Min         float64   `json:"min"`
+	Max         float64   `json:"max"`
+	Description string    `json:"description"`
+}
+
+// check whether a value is valid for this parameter
+func (ps ParamSpec) Validate(value float64) error {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("parameter [%s] should be a finite number, but it is [%g]", ps.Name, value)
+	}
+	if ps.Type == ParamTypeInt && value != math.Trunc(value) {
+		return fmt.Errorf("parameter [%s] should be an integer, but it is [%g]", ps.Name, value)
+	}
+	if value < ps.Min || value > ps.Max {
+		return fmt.Errorf("parameter [%s] should be in the range [%g, %g], but it is [%g]", ps.Name, ps.Min, ps.Max, value)
+	}
+	return nil
+}
+
+// The parameters of a scheduling algorithm. key: parameter name.
+type AlgoParams map[string]float64
+
+func (p AlgoParams) Int(name string) int {
+	return int(p[name])
+}
+
+func (p AlgoParams) Float(name string) float64 {
+	return p[name]
+}
+
+// The description of a registered scheduling algorithm, which is shown to users.
+type AlgoInfo struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Params      []ParamSpec `json:"params"`
+}
+
+// A factory creates an instance of a scheduling algorithm with validated parameters, in which all parameters in the schema are set.
+type AlgoFactory func(params AlgoParams) SchedulingAlgorithm
+
+type registeredAlgo struct {
+	info    AlgoInfo
+	factory AlgoFactory
+}
+
+var (
+	algoRegistry   map[string]registeredAlgo = make(map[string]registeredAlgo)
+	algoRegistryMu sync.RWMutex
+)
+
+// Register a scheduling algorithm. Registering 2 algorithms with the same name is a programming error, so it panics.
+func RegisterAlgorithm(info AlgoInfo, factory AlgoFactory) {
+	algoRegistryMu.Lock()
+	defer algoRegistryMu.Unlock()
+	if _, exist := algoRegistry[info.Name]; exist {
+		panic(fmt.Sprintf("scheduling algorithm [%s] is registered twice", info.Name))
+	}
+	for _, spec := range info.Params {
+		if err := spec.Validate(spec.Default); err != nil {
+			panic(fmt.Sprintf("scheduling algorithm [%s], invalid default value: %s", info.Name, err.Error()))
+		}
+	}
+	algoRegistry[info.Name] = registeredAlgo{info: info, factory: factory}
+}
+
+// list all registered scheduling algorithms, sorted by name
+func ListAlgorithms() []AlgoInfo {
+	algoRegistryMu.RLock()
+	defer algoRegistryMu.RUnlock()
+	var infos []AlgoInfo
+	for _, algo := range algoRegistry {
+		infos = append(infos, algo.info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// get the description of a registered scheduling algorithm
+func GetAlgorithm(name string) (AlgoInfo, bool) {
+	algoRegistryMu.RLock()
+	defer algoRegistryMu.RUnlock()
+	algo, exist := algoRegistry[name]
+	return algo.info, exist
+}
+
+// Merge the parameters set by users into the default parameters of an algorithm, and validate them.
+func ResolveAlgoParams(name string, overrides map[string]float64) (AlgoParams, error) {
+	info, exist := GetAlgorithm(name)
+	if !exist {
+		return nil, fmt.Errorf("scheduling algorithm [%s] is not registered", name)
+	}
+
+	params := make(AlgoParams)
+	specs := make(map[string]ParamSpec)
+	for _, spec := range info.Params {
+		specs[spec.Name] = spec
+		params[spec.Name] = spec.Default
+	}
+
+	var errs []error
+	for paramName, value := range overrides {
+		spec, exist := specs[paramName]
+		if !exist {
+			errs = append(errs, fmt.Errorf("scheduling algorithm [%s] has no parameter [%s]", name, paramName))
+			continue
+		}
+		if err := spec.Validate(value); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		params[paramName] = value
+	}
+	if len(errs) != 0 {
+		sort.Slice(errs, func(i, j int) bool {
+			return errs[i].Error() < errs[j].Error()
+		})
+		return nil, fmt.Errorf("invalid parameters of scheduling algorithm [%s]: %v", name, errs)
+	}
+
+	return params, nil
+}
+
+// Create an instance of a registered algorithm. The parameters set by users override the default ones.
+func NewAlgorithm(name string, overrides map[string]float64) (SchedulingAlgorithm, AlgoParams, error) {
+	params, err := ResolveAlgoParams(name, overrides)
+	if err != nil {
+		return nil, nil, err
+	}
+	algoRegistryMu.RLock()
+	factory := algoRegistry[name].factory
+	algoRegistryMu.RUnlock()
+	return factory(params), params, nil
+}
+
+// the parameters shared by the genetic algorithms, with the values that we used before the registry
+func gaParamSpecs() []ParamSpec {
+	return []ParamSpec{
+		{Name: ChromosomesCountParam, Type: ParamTypeInt, Default: 200, Min: 2, Max: 100000, Description: "number of chromosomes in each generation"},
+		{Name: IterationCountParam, Type: ParamTypeInt, Default: 5000, Min: 1, Max: 1000000, Description: "maximum number of iterations"},
+		{Name: CrossoverProbabilityParam, Type: ParamTypeFloat, Default: 0.7, Min: 0, Max: 1, Description: "probability of crossover"},
+		{Name: MutationProbabilityParam, Type: ParamTypeFloat, Default: 0.019, Min: 0, Max: 1, Description: "probability of mutation"},
+		{Name: StopNoUpdateIterationParam, Type: ParamTypeInt, Default: 200, Min: 1, Max: 1000000, Description: "stop when the best solution is not updated in this number of iterations"},
+	}
+}
+
+func exTimeOneCpuParamSpec() ParamSpec {
+	return ParamSpec{Name: ExTimeOneCpuParam, Type: ParamTypeFloat, Default: DefaultExpAppCompuTimeOneCpu, Min: 0, Max: math.MaxFloat64, Description: "expected computation time of an application with one CPU core, unit: ms"}
+}
+
+func init() {
+	RegisterAlgorithm(AlgoInfo{
+		Name:        McssgaName,
+		Description: "Multi-Cloud Service Scheduling Genetic Algorithm",
+		Params:      append(gaParamSpecs(), exTimeOneCpuParamSpec()),
+	}, func(p AlgoParams) SchedulingAlgorithm {
+		return NewMcssga(p.Int(ChromosomesCountParam), p.Int(IterationCountParam), p.Float(CrossoverProbabilityParam), p.Float(MutationProbabilityParam), p.Int(StopNoUpdateIterationParam), p.Float(ExTimeOneCpuParam))
+	})
+	RegisterAlgorithm(AlgoInfo{
+		Name:        CompRandName,
+		Description: "completely random scheduling, for comparison",
+	}, func(p AlgoParams) SchedulingAlgorithm {
+		return NewCompRand()
+	})
+	RegisterAlgorithm(AlgoInfo{
+		Name:        BERandName,
+		Description: "best-effort random scheduling, for comparison",
+	}, func(p AlgoParams) SchedulingAlgorithm {
+		return NewBERand()
+	})
+	RegisterAlgorithm(AlgoInfo{
+		Name:        AmpgaName,
+		Description: "AMPGA, for comparison",
+		Params:      gaParamSpecs(),
+	}, func(p AlgoParams) SchedulingAlgorithm {
+		return NewAmpga(p.Int(ChromosomesCountParam), p.Int(IterationCountParam), p.Float(CrossoverProbabilityParam), p.Float(MutationProbabilityParam), p.Int(StopNoUpdateIterationParam))
+	})
+	RegisterAlgorithm(AlgoInfo{
+		Name:        AmagaName,
+		Description: "AMAGA, for comparison",
+		Params:      gaParamSpecs(),
+	}, func(p AlgoParams) SchedulingAlgorithm {
+		return NewAmaga(p.Int(ChromosomesCountParam), p.Int(IterationCountParam), p.Float(CrossoverProbabilityParam), p.Float(MutationProbabilityParam), p.Int(StopNoUpdateIterationParam))
+	})
+	RegisterAlgorithm(AlgoInfo{
+		Name:        DiktyogaName,
+		Description: "Diktyo GA, for comparison",
+		Params:      gaParamSpecs(),
+	}, func(p AlgoParams) SchedulingAlgorithm {
+		return NewDiktyoga(p.Int(ChromosomesCountParam), p.Int(IterationCountParam), p.Float(CrossoverProbabilityParam), p.Float(MutationProbabilityParam), p.Int(StopNoUpdateIterationParam))
+	})
+	RegisterAlgorithm(AlgoInfo{
+		Name:        MTDPName,
+		Description: "Minimizing Total Data Center Power",
+		Params:      gaParamSpecs(),
+	}, func(p AlgoParams) SchedulingAlgorithm {
+		return NewMtdp(p.Int(ChromosomesCountParam), p.Int(IterationCountParam), p.Float(CrossoverProbabilityParam), p.Float(MutationProbabilityParam), p.Int(StopNoUpdateIterationParam))
+	})
+	RegisterAlgorithm(AlgoInfo{
+		Name:        PriorityAwareName,
+		Description: "priority-aware genetic algorithm",
+		Params:      append(gaParamSpecs(), exTimeOneCpuParamSpec()),
+	}, func(p AlgoParams) SchedulingAlgorithm {
+		return NewPriorityAwareGA(p.Int(ChromosomesCountParam), p.Int(IterationCountParam), p.Float(CrossoverProbabilityParam), p.Float(MutationProbabilityParam), p.Int(StopNoUpdateIterationParam), p.Float(ExTimeOneCpuParam))
+	})
+}
@@ -0,0 +1,106 @@
+package algorithms
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListAlgorithms(t *testing.T) {
+	var names []string
+	for _, info := range ListAlgorithms() {
+		names = append(names, info.Name)
+	}
+	assert.Equal(t, []string{AmagaName, AmpgaName, BERandName, CompRandName, DiktyogaName, MTDPName, McssgaName, PriorityAwareName}, names)
+}
+
+func TestNewAlgorithm(t *testing.T) {
+	testCases := []struct {
+		name           string
+		algoName       string
+		overrides      map[string]float64
+		expectedErr    bool
+		expectedParams AlgoParams
+	}{
+		{
+			name:     "default parameters",
+			algoName: McssgaName,
+			expectedParams: AlgoParams{
+				ChromosomesCountParam:      200,
+				IterationCountParam:        5000,
+				CrossoverProbabilityParam:  0.7,
+				MutationProbabilityParam:   0.019,
+				StopNoUpdateIterationParam: 200,
+				ExTimeOneCpuParam:          DefaultExpAppCompuTimeOneCpu,
+			},
+		},
+		{
+			name:      "override parameters",
+			algoName:  AmpgaName,
+			overrides: map[string]float64{IterationCountParam: 100, MutationProbabilityParam: 0.1},
+			expectedParams: AlgoParams{
+				ChromosomesCountParam:      200,
+				IterationCountParam:        100,
+				CrossoverProbabilityParam:  0.7,
+				MutationProbabilityParam:   0.1,
+				StopNoUpdateIterationParam: 200,
+			},
+		},
+		{
+			name:           "algorithm without parameters",
+			algoName:       CompRandName,
+			expectedParams: AlgoParams{},
+		},
+		{
+			name:        "unknown algorithm",
+			algoName:    "NotExist",
+			expectedErr: true,
+		},
+		{
+			name:        "unknown parameter",
+			algoName:    CompRandName,
+			overrides:   map[string]float64{IterationCountParam: 100},
+			expectedErr: true,
+		},
+		{
+			name:        "int parameter with float value",
+			algoName:    MTDPName,
+			overrides:   map[string]float64{ChromosomesCountParam: 100.5},
+			expectedErr: true,
+		},
+		{
+			name:        "parameter out of range",
+			algoName:    PriorityAwareName,
+			overrides:   map[string]float64{CrossoverProbabilityParam: 1.5},
+			expectedErr: true,
+		},
+		{
+			name:        "parameter is NaN",
+			algoName:    McssgaName,
+			overrides:   map[string]float64{ExTimeOneCpuParam: math.NaN()},
+			expectedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Logf("test: %s", testCase.name)
+		algo, params, err := NewAlgorithm(testCase.algoName, testCase.overrides)
+		if testCase.expectedErr {
+			t.Logf("error: %v", err)
+			assert.NotNil(t, err)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.NotNil(t, algo)
+		assert.Equal(t, testCase.expectedParams, params)
+	}
+}
+
+func TestRegisterAlgorithmTwice(t *testing.T) {
+	assert.Panics(t, func() {
+		RegisterAlgorithm(AlgoInfo{Name: McssgaName}, func(p AlgoParams) SchedulingAlgorithm {
+			return NewCompRand()
+		})
+	})
+}
@@ -234,8 +234,22 @@ func resAccOneCloudDedicatedVms(cloud asmodel.Cloud, apps map[string]asmodel.App
 
 	// group the max-priority applications according to their dependencies. The applications with dependencies should be in the same group, and we will create one dedicated VM for one group.
 	maxPriAppsGroups := groupByDep(maxPriApps)
+	// different replicas of an application cannot be on one dedicated VM.
+	for _, group := range maxPriAppsGroups {
+		for i := range group {
+			if hasReplicaSibling(apps, group[:i], group[i]) {
+				return asmodel.Solution{}, false
+			}
+		}
+	}
 	simulatedCloud := asmodel.CloudCopy(cloud) // avoid changing the original cloud variable
 	dedicatedVmsToCreate := getDedicatedVmsToCreate(&simulatedCloud, apps, maxPriAppsGroups)
+	// on the clouds with flavors, no flavor may be able to meet the dedicated VM
+	for _, vm := range dedicatedVmsToCreate {
+		if !cloud.VmShapeAvailable(vm) {
+			return asmodel.Solution{}, false
+		}
+	}
 
 	// put the app scheduling vm information into the solution
 	for i := 0; i < len(maxPriAppsGroups); i++ {
@@ -293,6 +307,10 @@ func getDedVmOneGroup(cloud asmodel.Cloud, apps map[string]asmodel.Application,
 		Ram:     models.CalcVmTotalRamMiB(neededAvailRes.Memory),
 		Storage: models.CalcVmTotalStorGiB(neededAvailRes.Storage),
 	}
+	// On the clouds with flavors, we use the smallest flavor that can meet the needed resources. If no flavor can meet them, the VM is kept as it is, and the caller will find it cannot be created.
+	if len(cloud.Flavors) > 0 {
+		deDVmToCreate.VCpu, deDVmToCreate.Ram, _ = cloud.FitFlavor(deDVmToCreate.VCpu, deDVmToCreate.Ram)
+	}
 
 	return deDVmToCreate
 }
@@ -317,7 +335,7 @@ func isResEnough(vm asmodel.K8sNode, app asmodel.Application, minCpu bool) bool
 	// CPU is a soft resource, so we think that cpuCoreStep CPU is the minimum requirement for each application.
 	// In some conditions, the occupied CPU can be considered as the minimum requirement.
 	if minCpu {
-		cpuToOccupy = cpuCoreStep
+		cpuToOccupy = minCpuOfApp(app)
 	}
 
 	return vm.ResidualResources.CpuCore >= cpuToOccupy &&
@@ -333,7 +351,7 @@ func subRes(vm *asmodel.K8sNode, app asmodel.Application, minCpu bool) {
 	// CPU is a soft resource, so we think that cpuCoreStep CPU is the minimum requirement for each application.
 	// In some conditions, the occupied CPU can be considered as the minimum requirement.
 	if minCpu {
-		cpuToOccupy = cpuCoreStep
+		cpuToOccupy = minCpuOfApp(app)
 	}
 	vm.ResidualResources.CpuCore -= cpuToOccupy
 	vm.ResidualResources.Memory -= app.Resources.Memory
@@ -345,8 +363,8 @@ func vmResMeetAllRestApps(vm asmodel.K8sNode, apps map[string]asmodel.Applicatio
 
 	var appNamesToThisVm []string // the application names that are scheduled to this VM
 
-	// we loop until the resources of this VM is used up.
-	for isResEnough(vm, apps[*curAppName], minCpu) {
+	// we loop until the resources of this VM is used up, or until the next application is a replica of an application that already has a replica on this VM.
+	for isResEnough(vm, apps[*curAppName], minCpu) && !hasReplicaSibling(apps, appNamesToThisVm, *curAppName) {
 		// simulate deploying this application on this VM.
 		subRes(&vm, apps[*curAppName], minCpu)
 		appNamesToThisVm = append(appNamesToThisVm, *curAppName)
@@ -362,3 +380,13 @@ func vmResMeetAllRestApps(vm asmodel.K8sNode, apps map[string]asmodel.Applicatio
 
 	return appNamesToThisVm, false // this means that the rest of the VM's resources can not meet the next application, so the resources are not enough
 }
+
+// check whether any of the applications is another replica of the same application as the current one. Different replicas of an application should not be on the same VM.
+func hasReplicaSibling(apps map[string]asmodel.Application, appNames []string, curAppName string) bool {
+	for _, appName := range appNames {
+		if apps[appName].IsReplicaSibling(apps[curAppName]) {
+			return true
+		}
+	}
+	return false
+}
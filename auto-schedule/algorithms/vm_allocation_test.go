@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
 	"github.com/stretchr/testify/assert"
 
 	asmodel "emcontroller/auto-schedule/model"
@@ -204,6 +205,27 @@ func TestInnerGetDedVmOneGroup(t *testing.T) {
 				Storage: models.CalcVmTotalStorGiB(6 + 15 + 15),
 			},
 		},
+		{
+			name: "case3 flavors",
+			cloud: func() asmodel.Cloud {
+				cloud := asmodel.CloudCopy(clouds["nokia4"])
+				cloud.Flavors = []flavors.Flavor{
+					{Name: "m1.medium", VCPUs: 4, RAM: 8192},
+					{Name: "m1.xlarge", VCPUs: 16, RAM: 16384},
+					{Name: "m1.huge", VCPUs: 32, RAM: 65536},
+				}
+				return cloud
+			}(),
+			apps:     apps,
+			appGroup: []string{"app5", "app8", "app4"},
+			expectedResult: models.IaasVm{
+				Name:    "auto-sched-nokia4-0",
+				Cloud:   "NOKIA4",
+				VCpu:    16,
+				Ram:     16384,
+				Storage: models.CalcVmTotalStorGiB(6 + 15 + 15),
+			},
+		},
 	}
 
 	for i, testCase := range testCases {
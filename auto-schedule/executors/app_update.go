@@ -0,0 +1,49 @@
+package executors
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/astaxie/beego"
+
+	"emcontroller/auto-schedule/algorithms"
+	"emcontroller/models"
+)
+
+// Updating or rolling back an application changes its pod template, including the placement and the CPU of an auto-scheduled application.
+// Auto-scheduling and migration read and change them too, so the change of the Deployment needs the scheduling lock.
+// Waiting for the application running can take up to WaitForTimeOut, so it is done after the lock is released, and does not block scheduling, migration or cleanup.
+
+// run a change of an application with the scheduling lock.
+func changeAppWithScheLock(change func() ([]string, error, int)) ([]string, error, int) {
+	if !algorithms.ScheMu.TryLock() {
+		outErr := fmt.Errorf("Another task of Scheduling, Migration or Cleanup is running. Please try later.")
+		beego.Error(outErr)
+		return nil, outErr, http.StatusLocked
+	}
+	defer algorithms.ScheMu.Unlock()
+
+	return change()
+}
+
+// UpdateAppAndWait updates an application with the scheduling lock, and waits for it running without the lock.
+func UpdateAppAndWait(app models.K8sApp) (models.AppUpdateResult, error, int) {
+	changes, err, statusCode := changeAppWithScheLock(func() ([]string, error, int) {
+		return models.UpdateApplication(app)
+	})
+	if err != nil {
+		return models.AppUpdateResult{}, err, statusCode
+	}
+	return models.WaitForAppUpdated(models.AppNamespace(app.Namespace), app.Name, changes)
+}
+
+// RollbackAppAndWait rolls back an application with the scheduling lock, and waits for it running without the lock.
+func RollbackAppAndWait(namespace, appName string) (models.AppUpdateResult, error, int) {
+	changes, err, statusCode := changeAppWithScheLock(func() ([]string, error, int) {
+		return models.RollbackApplication(namespace, appName)
+	})
+	if err != nil {
+		return models.AppUpdateResult{}, err, statusCode
+	}
+	return models.WaitForAppUpdated(namespace, appName, changes)
+}
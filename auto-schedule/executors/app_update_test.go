@@ -0,0 +1,49 @@
+package executors
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"emcontroller/auto-schedule/algorithms"
+)
+
+func TestInnerChangeAppWithScheLock(t *testing.T) {
+	// the change runs with the lock, and the lock is released after it, so that the waiting does not block scheduling.
+	var lockedInChange bool
+	changes, err, statusCode := changeAppWithScheLock(func() ([]string, error, int) {
+		lockedInChange = !algorithms.ScheMu.TryLock()
+		return []string{"image changed"}, nil, http.StatusOK
+	})
+	assert.True(t, lockedInChange, "the change should run with the scheduling lock")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, []string{"image changed"}, changes)
+	if assert.True(t, algorithms.ScheMu.TryLock(), "the scheduling lock should be released after the change") {
+		algorithms.ScheMu.Unlock()
+	}
+
+	// the lock is released when the change fails
+	_, err, statusCode = changeAppWithScheLock(func() ([]string, error, int) {
+		return nil, fmt.Errorf("not found"), http.StatusNotFound
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusNotFound, statusCode)
+	if assert.True(t, algorithms.ScheMu.TryLock(), "the scheduling lock should be released after the failed change") {
+		algorithms.ScheMu.Unlock()
+	}
+
+	// another task holds the lock
+	algorithms.ScheMu.Lock()
+	var called bool
+	_, err, statusCode = changeAppWithScheLock(func() ([]string, error, int) {
+		called = true
+		return nil, nil, http.StatusOK
+	})
+	algorithms.ScheMu.Unlock()
+	assert.False(t, called)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusLocked, statusCode)
+}
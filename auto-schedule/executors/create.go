@@ -1,9 +1,11 @@
 package executors
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"sort"
+	"strconv"
 
 	"github.com/astaxie/beego"
 
@@ -12,22 +14,48 @@ import (
 	"emcontroller/models"
 )
 
-// algoName is the name of the scheduling algorithm to use.
-func CreateAutoScheduleApps(apps []models.K8sApp, algoName string, exTimeOneCpu float64) ([]models.AppInfo, error, int) {
+// algoName is the name of the scheduling algorithm to use, and algoParams overrides its default parameters.
+func CreateAutoScheduleApps(apps []models.K8sApp, algoName string, algoParams map[string]float64, exTimeOneCpu float64) ([]models.AppInfo, error, int) {
+	return createAutoScheduleApps(context.Background(), nil, apps, algoName, algoParams, exTimeOneCpu)
+}
+
+// The steps of CreateAutoScheduleApps. If job is not nil, the phases and partial results are recorded in it, and ctx can be used to cancel the job.
+func createAutoScheduleApps(ctx context.Context, job *ScheduleJob, apps []models.K8sApp, algoName string, algoParams map[string]float64, exTimeOneCpu float64) ([]models.AppInfo, error, int) {
+	plan, err, statusCode := scheduleApps(ctx, apps, algoName, algoParams, exTimeOneCpu)
+	if err != nil {
+		outErr := fmt.Errorf("Schedule applications, Error: [%w]", err)
+		beego.Error(outErr)
+		return []models.AppInfo{}, outErr, statusCode
+	}
 
+	job.update(func(j *ScheduleJob) {
+		solnCopy := asmodel.SolutionCopy(plan.Solution)
+		j.Solution = &solnCopy
+		j.AlgoName = plan.AlgoName
+		j.AlgoParams = plan.AlgoParams
+	})
+
+	return deploySolution(ctx, job, apps, plan.Solution)
+}
+
+// Work out the scheduling solution of the applications, without changing anything in the clouds or in Kubernetes.
+func scheduleApps(ctx context.Context, apps []models.K8sApp, algoName string, algoParams map[string]float64, exTimeOneCpu float64) (SchedulePlan, error, int) {
 	// we only accept the valid applications, or otherwise we will have too much unnecessary workload
 	if errs := ValidateAutoScheduleApps(apps); len(errs) != 0 {
 		outErr := fmt.Errorf("The input applicatios are invalid, Error: [%w]", models.HandleErrSlice(errs))
 		beego.Error(outErr)
-		return []models.AppInfo{}, outErr, http.StatusBadRequest
+		return SchedulePlan{}, outErr, http.StatusBadRequest
+	}
+	if err, statusCode := checkAppsStorageClasses(apps); err != nil {
+		return SchedulePlan{}, err, statusCode
 	}
 
 	// make the asmodel.Cloud structure as the input of Schedule function
-	cloudsForScheduling, err := asmodel.GenerateClouds(models.Clouds)
+	cloudsForScheduling, err := asmodel.GenerateClouds(models.ListIaas())
 	if err != nil {
 		outErr := fmt.Errorf("Generate input clouds for auto-scheduling, Error: [%w]", err)
 		beego.Error(outErr)
-		return []models.AppInfo{}, outErr, http.StatusInternalServerError
+		return SchedulePlan{}, outErr, http.StatusInternalServerError
 	}
 
 	// make the asmodel.Application structure as the input of Schedule function
@@ -35,57 +63,36 @@ func CreateAutoScheduleApps(apps []models.K8sApp, algoName string, exTimeOneCpu
 	if err != nil {
 		outErr := fmt.Errorf("Generate input applications for auto-scheduling, Error: [%w]", err)
 		beego.Error(outErr)
-		return []models.AppInfo{}, outErr, http.StatusInternalServerError
+		return SchedulePlan{}, outErr, http.StatusInternalServerError
 	}
+	// the applications with multiple replicas are scheduled as multiple applications, one for each replica.
+	replicasForScheduling := asmodel.ExpandReplicas(appsForScheduling)
 	// In some steps of scheduling, we need a fixed order of applications.
-	appsOrder := algorithms.GenerateAppsOrder(appsForScheduling)
+	appsOrder := algorithms.GenerateAppsOrder(replicasForScheduling)
 
 	// Whether this order is fixed or random does not affect the performance of algorithms, because the applications are generated randomly, which will not be changed by a fixed order. However, when we fix the order here, the comparison between different algorithms can have the same input, because apps order is one input parameter.
 	sort.Strings(appsOrder)
 
-	// the parameters for genetic algorithms
-	var chromosomesCount int = 200
-	var iterationCount int = 5000
-	var crossoverProbability float64 = 0.7
-	var mutationProbability float64 = 0.019
-	var stopNoUpdateIteration int = 200
-
-	// call the Scheduling method according to the input parameter "algo"
-
-	// create algorithm instances, and put them in a map
-	mcssgaInstance := algorithms.NewMcssga(chromosomesCount, iterationCount, crossoverProbability, mutationProbability, stopNoUpdateIteration, exTimeOneCpu)
-	var allAlgos map[string]algorithms.SchedulingAlgorithm = make(map[string]algorithms.SchedulingAlgorithm)
-	allAlgos[algorithms.McssgaName] = mcssgaInstance
-	allAlgos[algorithms.CompRandName] = algorithms.NewCompRand()
-	allAlgos[algorithms.BERandName] = algorithms.NewBERand()
-	allAlgos[algorithms.AmpgaName] = algorithms.NewAmpga(chromosomesCount, iterationCount, crossoverProbability, mutationProbability, stopNoUpdateIteration)
-	allAlgos[algorithms.AmagaName] = algorithms.NewAmaga(chromosomesCount, iterationCount, crossoverProbability, mutationProbability, stopNoUpdateIteration)
-	allAlgos[algorithms.DiktyogaName] = algorithms.NewDiktyoga(chromosomesCount, iterationCount, crossoverProbability, mutationProbability, stopNoUpdateIteration)
-
-	// select the algorithm to use according to the input parameter algoName
-	beego.Info(fmt.Sprintf("Looking for the algorithm \"%s\".", algoName))
-	var algoToUse algorithms.SchedulingAlgorithm
-	var algoNameToUse string = algoName
-	if algo, exist := allAlgos[algoName]; exist {
-		beego.Info(fmt.Sprintf("Algorithm \"%s\" is found.", algoName))
-		algoToUse = algo
-	} else { // if we cannot find the input algoName, we use MCASSGA algorithm by default.
-		algoNameToUse = algorithms.McssgaName
-		beego.Info(fmt.Sprintf("Algorithm \"%s\" is not found, so we use \"%s\" by default.", algoName, algoNameToUse))
-		algoToUse = mcssgaInstance
+	algoToUse, algoNameToUse, resolvedParams, mcssgaInstance, err := chooseAlgorithm(algoName, algoParams, exTimeOneCpu)
+	if err != nil {
+		outErr := fmt.Errorf("Choose the scheduling algorithm, Error: [%w]", err)
+		beego.Error(outErr)
+		return SchedulePlan{}, outErr, http.StatusBadRequest
 	}
 
-	solution, err := algoToUse.Schedule(cloudsForScheduling, appsForScheduling, appsOrder)
+	replicasSolution, err := scheduleWithCtx(ctx, algoToUse, cloudsForScheduling, replicasForScheduling, appsOrder)
 	if err != nil {
 		outErr := fmt.Errorf("Run the Schedule method of %s, Error: [%w]", algoNameToUse, err)
 		beego.Error(outErr)
-		return []models.AppInfo{}, outErr, http.StatusInternalServerError
+		return SchedulePlan{}, outErr, http.StatusInternalServerError
 	}
 
-	// If we did not use Mcssga to schedule apps, now its max rtt has not been set, so we should set it now to calculate the fitness value in the following log.
+	// If we did not use Mcssga to schedule apps, now its max rtt has not been set, so we should set it now to calculate the fitness value.
 	mcssgaInstance.SetMaxReaRtt(cloudsForScheduling)
-	mcssgaInstance.SetAvgDepNum(appsForScheduling)
-	beego.Info(fmt.Sprintf("The algorithm works out the solution: %s\nIts fitness value is %g.", models.JsonString(solution), mcssgaInstance.Fitness(cloudsForScheduling, appsForScheduling, solution)))
+	mcssgaInstance.SetAvgDepNum(replicasForScheduling)
+	fitness := mcssgaInstance.Fitness(cloudsForScheduling, replicasForScheduling, replicasSolution)
+	solution := asmodel.CollapseReplicas(appsForScheduling, replicasSolution)
+	beego.Info(fmt.Sprintf("The algorithm works out the solution: %s\nIts fitness value is %g.", models.JsonString(solution), fitness))
 
 	//// This part is for debug ----------------------------
 	//
@@ -115,21 +122,69 @@ func CreateAutoScheduleApps(apps []models.K8sApp, algoName string, exTimeOneCpu
 	//return acceptedApps, nil, http.StatusCreated
 	//// This part is for debug ----------------------------
 
-	/**
-	TODO:
-	migration: I set a lock, migration and deployment (or multiple deployments) cannot be done at the same time. When doing migration, we skip the resources occupied by the applications to be migrated, and count them as the VM resources. When the resources are not enough, the rolling update may be blocked, because the new pods cannot be created. Maybe I can make a dependency topo-sort to avoid it.
-	I will put the migration into the next paper.
-	*/
+	return newSchedulePlan(apps, appsForScheduling, algoNameToUse, resolvedParams, solution, fitness), nil, http.StatusOK
+}
+
+// Create the instance of the algorithm with the input name from the registry. If the name is not found, the default algorithm is used.
+// The parameters set by users override the default ones of the algorithm, and exTimeOneCpu is used if the algorithm has this parameter and users do not override it.
+// An MCSSGA instance is also returned, because we use its fitness function to evaluate the solutions of all algorithms.
+func chooseAlgorithm(algoName string, algoParams map[string]float64, exTimeOneCpu float64) (algorithms.SchedulingAlgorithm, string, algorithms.AlgoParams, *algorithms.Mcssga, error) {
+	beego.Info(fmt.Sprintf("Looking for the algorithm \"%s\".", algoName))
+	algoNameToUse := algoName
+	algoInfo, exist := algorithms.GetAlgorithm(algoName)
+	if exist {
+		beego.Info(fmt.Sprintf("Algorithm \"%s\" is found.", algoName))
+	} else { // if we cannot find the input algoName, we use the default algorithm.
+		algoNameToUse = algorithms.DefaultAlgoName
+		beego.Info(fmt.Sprintf("Algorithm \"%s\" is not found, so we use \"%s\" by default.", algoName, algoNameToUse))
+		algoInfo, _ = algorithms.GetAlgorithm(algoNameToUse)
+	}
+
+	overrides := make(map[string]float64)
+	for _, spec := range algoInfo.Params {
+		if spec.Name == algorithms.ExTimeOneCpuParam {
+			overrides[spec.Name] = exTimeOneCpu
+		}
+	}
+	for name, value := range algoParams {
+		overrides[name] = value
+	}
+
+	algoToUse, resolvedParams, err := algorithms.NewAlgorithm(algoNameToUse, overrides)
+	if err != nil {
+		return nil, "", nil, nil, err
+	}
+	beego.Info(fmt.Sprintf("Use algorithm \"%s\" with parameters %s.", algoNameToUse, models.JsonString(resolvedParams)))
+
+	evaluator, _, err := algorithms.NewAlgorithm(algorithms.McssgaName, map[string]float64{algorithms.ExTimeOneCpuParam: exTimeOneCpu})
+	if err != nil {
+		return nil, "", nil, nil, fmt.Errorf("create the MCSSGA instance to calculate fitness values, Error: [%w]", err)
+	}
+
+	return algoToUse, algoNameToUse, resolvedParams, evaluator.(*algorithms.Mcssga), nil
+}
+
+// Create the VMs in the solution, add them to Kubernetes, and deploy the accepted applications.
+// The applications already running are migrated by StartMigrateJob instead.
+func deploySolution(ctx context.Context, job *ScheduleJob, apps []models.K8sApp, solution asmodel.Solution) ([]models.AppInfo, error, int) {
+	if err, statusCode := createSolutionVms(ctx, job, solution); err != nil {
+		return []models.AppInfo{}, err, statusCode
+	}
 
-	// create the VMs and add them to Kubernetes
-	if _, err := models.AddNewVms(solution.VmsToCreate); err != nil {
-		outErr := fmt.Errorf("Add new auto-scheduling VMs, Error: [%w]", err)
+	// save the information for migration into the applications, and then add the auto-scheduling information into the applications to deploy.
+	appsWithInfo, err := addMigrationInfoToApps(apps)
+	if err != nil {
+		outErr := fmt.Errorf("Add migration information to applications, Error: [%w]", err)
 		beego.Error(outErr)
 		return []models.AppInfo{}, outErr, http.StatusInternalServerError
 	}
+	appsToDeploy := addScheInfoToApps(appsWithInfo, solution)
 
-	// add the auto-scheduling information into the applications to deploy.
-	appsToDeploy := addScheInfoToApps(apps, solution)
+	if err := checkCancelled(ctx, JobPhaseDeployingApps); err != nil {
+		beego.Error(err)
+		return []models.AppInfo{}, err, http.StatusConflict
+	}
+	job.setPhase(JobPhaseDeployingApps)
 
 	// deploy applications, and wait for them running.
 	createdAppsInfo, err := models.CreateAppsWait(appsToDeploy)
@@ -142,6 +197,71 @@ func CreateAutoScheduleApps(apps []models.K8sApp, algoName string, exTimeOneCpu
 	return createdAppsInfo, nil, http.StatusCreated
 }
 
+// create the VMs in the solution and add them to Kubernetes.
+func createSolutionVms(ctx context.Context, job *ScheduleJob, solution asmodel.Solution) (error, int) {
+	// This is what models.AddNewVms does, but we do it in 2 phases to record the progress of the job.
+	if err := checkCancelled(ctx, JobPhaseCreatingVms); err != nil {
+		beego.Error(err)
+		return err, http.StatusConflict
+	}
+	job.setPhase(JobPhaseCreatingVms)
+	// the new VMs join Kubernetes by cloud-init if their clouds support it, so that the controller does not hold SSH sessions to them.
+	vmsToCreate := models.JoinByCloudInitIfSupported(solution.VmsToCreate)
+	beego.Info(fmt.Sprintf("Create new VMs [%s].", models.JsonString(vmsToCreate)))
+	createdVms, err := models.CreateVms(vmsToCreate)
+	job.update(func(j *ScheduleJob) {
+		j.CreatedVms = createdVms
+	})
+	if err != nil {
+		outErr := fmt.Errorf("Add new auto-scheduling VMs, Create new VMs [%s], Error: [%w]", models.JsonString(vmsToCreate), err)
+		beego.Error(outErr)
+		return outErr, http.StatusInternalServerError
+	}
+
+	if err := checkCancelled(ctx, JobPhaseJoiningNodes); err != nil {
+		beego.Error(err)
+		return err, http.StatusConflict
+	}
+	job.setPhase(JobPhaseJoiningNodes)
+	beego.Info(fmt.Sprintf("Add new VMs [%s] to Kubernetes cluster.", models.JsonString(createdVms)))
+	if errs := models.AddNodes(createdVms); len(errs) != 0 {
+		outErr := fmt.Errorf("Add new auto-scheduling VMs [%s] to Kubernetes cluster, Error: [%w]", models.JsonString(createdVms), models.HandleErrSlice(errs))
+		beego.Error(outErr)
+		return outErr, http.StatusInternalServerError
+	}
+
+	return nil, http.StatusOK
+}
+
+// Run the Schedule method of an algorithm, and return an error when ctx is cancelled.
+// The algorithm may change the input clouds and applications, so even if it is cancelled, we wait for it to exit before returning, so that it does not run after the scheduling lock is released. The iterative algorithms stop at the next iteration when ctx is cancelled.
+func scheduleWithCtx(ctx context.Context, algo algorithms.SchedulingAlgorithm, clouds map[string]asmodel.Cloud, apps map[string]asmodel.Application, appsOrder []string) (asmodel.Solution, error) {
+	if setter, ok := algo.(algorithms.ContextSetter); ok {
+		setter.SetContext(ctx)
+	}
+
+	type scheResult struct {
+		solution asmodel.Solution
+		err      error
+	}
+	resultCh := make(chan scheResult, 1)
+	go func() {
+		solution, err := algo.Schedule(clouds, apps, appsOrder)
+		resultCh <- scheResult{solution: solution, err: err}
+	}()
+
+	select {
+	case result := <-resultCh:
+		if ctx.Err() != nil { // the algorithm may stop early because of the cancellation
+			return asmodel.Solution{}, fmt.Errorf("the job is cancelled in phase [%s]: %w", JobPhaseScheduling, ctx.Err())
+		}
+		return result.solution, result.err
+	case <-ctx.Done():
+		<-resultCh
+		return asmodel.Solution{}, fmt.Errorf("the job is cancelled in phase [%s]: %w", JobPhaseScheduling, ctx.Err())
+	}
+}
+
 // After scheduling applications, we should use this functions to add the scheduling information to applications.
 func addScheInfoToApps(apps []models.K8sApp, scheSoln asmodel.Solution) []models.K8sApp {
 	var appsWithScheInfo []models.K8sApp
@@ -152,14 +272,62 @@ func addScheInfoToApps(apps []models.K8sApp, scheSoln asmodel.Solution) []models
 			continue
 		}
 
-		// add node name
-		app.NodeName = scheSoln.AppsSolution[app.Name].K8sNodeName
-		// configure allocated CPU
-		app.Containers[0].Resources.Requests.CPU = fmt.Sprintf("%.0f", scheSoln.AppsSolution[app.Name].AllocatedCpuCore)
-		app.Containers[0].Resources.Limits.CPU = fmt.Sprintf("%.0f", scheSoln.AppsSolution[app.Name].AllocatedCpuCore)
+		// add node name. An application with multiple replicas is deployed with only its accepted replicas, one on each node.
+		placements := scheSoln.AppsSolution[app.Name].ReplicaPlacements()
+		app.Replicas = int32(len(placements))
+		if len(placements) == 1 {
+			app.NodeName = placements[0].K8sNodeName
+		} else {
+			app.NodeName = ""
+			app.ReplicaNodeNames = nil
+			for _, placement := range placements {
+				app.ReplicaNodeNames = append(app.ReplicaNodeNames, placement.K8sNodeName)
+			}
+		}
+		// configure allocated CPU, which is the same for all replicas, and is split to the containers in proportion to their requests.
+		containers := make([]models.K8sContainer, len(app.Containers))
+		copy(containers, app.Containers)
+		containerCpus := asmodel.SplitCpu(scheSoln.AppsSolution[app.Name].AllocatedCpuCore, requestedContainerCpus(containers))
+		for i := range containers {
+			cpu := asmodel.ContainerCpuQuantity(containerCpus[i])
+			if len(cpu) == 0 { // the containers without CPU keep their resources
+				continue
+			}
+			containers[i].Resources.Requests.CPU = cpu
+			containers[i].Resources.Limits.CPU = cpu
+		}
+		app.Containers = containers
 
 		appsWithScheInfo = append(appsWithScheInfo, app)
 	}
 
 	return appsWithScheInfo
 }
+
+// the requested CPU cores of every container. The CPU of auto-scheduling applications is already validated as integers.
+func requestedContainerCpus(containers []models.K8sContainer) []float64 {
+	cpus := make([]float64, len(containers))
+	for i, container := range containers {
+		cpus[i], _ = strconv.ParseFloat(container.Resources.Requests.CPU, 64)
+	}
+	return cpus
+}
+
+// Put the information needed to schedule the applications again into them, which will be saved in the annotations of their deployments and used for migration.
+func addMigrationInfoToApps(apps []models.K8sApp) ([]models.K8sApp, error) {
+	appsForScheduling, err := asmodel.GenerateApplications(apps)
+	if err != nil {
+		return nil, fmt.Errorf("Generate applications for auto-scheduling, Error: [%w]", err)
+	}
+
+	var appsWithInfo []models.K8sApp
+	for _, app := range apps {
+		info, err := asmodel.GenAutoScheduleInfo(appsForScheduling[app.Name])
+		if err != nil {
+			return nil, err
+		}
+		app.AutoScheduleInfo = info
+		appsWithInfo = append(appsWithInfo, app)
+	}
+	return appsWithInfo, nil
+}
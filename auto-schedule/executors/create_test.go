@@ -1,8 +1,12 @@
 package executors
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -621,6 +625,75 @@ func TestInnerAddScheInfoToApps(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "multiple containers",
+			apps: []models.K8sApp{
+				{
+					Name:          "sidecar-app",
+					Replicas:      1,
+					Priority:      5,
+					AutoScheduled: true,
+					Containers: []models.K8sContainer{
+						{Name: "main", Image: "nginx", Resources: models.K8sResReq{Limits: models.K8sResList{CPU: "4"}, Requests: models.K8sResList{CPU: "4"}}},
+						{Name: "log", Image: "fluent-bit", Resources: models.K8sResReq{Limits: models.K8sResList{CPU: "2"}, Requests: models.K8sResList{CPU: "2"}}},
+					},
+				},
+			},
+			scheSoln: asmodel.Solution{
+				AppsSolution: map[string]asmodel.SingleAppSolution{
+					"sidecar-app": {Accepted: true, TargetCloudName: "nokia4", K8sNodeName: "auto-sched-nokia4-0", AllocatedCpuCore: 4},
+				},
+			},
+			expectedResult: []models.K8sApp{
+				{
+					Name:          "sidecar-app",
+					Replicas:      1,
+					NodeName:      "auto-sched-nokia4-0",
+					Priority:      5,
+					AutoScheduled: true,
+					Containers: []models.K8sContainer{
+						{Name: "main", Image: "nginx", Resources: models.K8sResReq{Limits: models.K8sResList{CPU: "3"}, Requests: models.K8sResList{CPU: "3"}}},
+						{Name: "log", Image: "fluent-bit", Resources: models.K8sResReq{Limits: models.K8sResList{CPU: "1"}, Requests: models.K8sResList{CPU: "1"}}},
+					},
+				},
+			},
+		},
+		{
+			// no container gets the CPU "0"
+			name: "fewer cores than containers",
+			apps: []models.K8sApp{
+				{
+					Name:          "sidecar-app",
+					Replicas:      1,
+					Priority:      5,
+					AutoScheduled: true,
+					Containers: []models.K8sContainer{
+						{Name: "main", Image: "nginx", Resources: models.K8sResReq{Limits: models.K8sResList{CPU: "2"}, Requests: models.K8sResList{CPU: "2"}}},
+						{Name: "log", Image: "fluent-bit", Resources: models.K8sResReq{Limits: models.K8sResList{CPU: "2"}, Requests: models.K8sResList{CPU: "2"}}},
+						{Name: "init", Image: "busybox"},
+					},
+				},
+			},
+			scheSoln: asmodel.Solution{
+				AppsSolution: map[string]asmodel.SingleAppSolution{
+					"sidecar-app": {Accepted: true, TargetCloudName: "nokia4", K8sNodeName: "auto-sched-nokia4-0", AllocatedCpuCore: 1},
+				},
+			},
+			expectedResult: []models.K8sApp{
+				{
+					Name:          "sidecar-app",
+					Replicas:      1,
+					NodeName:      "auto-sched-nokia4-0",
+					Priority:      5,
+					AutoScheduled: true,
+					Containers: []models.K8sContainer{
+						{Name: "main", Image: "nginx", Resources: models.K8sResReq{Limits: models.K8sResList{CPU: "1"}, Requests: models.K8sResList{CPU: "1"}}},
+						{Name: "log", Image: "fluent-bit", Resources: models.K8sResReq{Limits: models.K8sResList{CPU: "1m"}, Requests: models.K8sResList{CPU: "1m"}}},
+						{Name: "init", Image: "busybox"},
+					},
+				},
+			},
+		},
 	}
 
 	for i, testCase := range testCases {
@@ -629,3 +702,33 @@ func TestInnerAddScheInfoToApps(t *testing.T) {
 		assert.Equal(t, testCase.expectedResult, actualResult, fmt.Sprintf("%s: result is not expected", testCase.name))
 	}
 }
+
+// an iterative algorithm that only stops when it is cancelled, and takes some time to exit
+type endlessAlgo struct {
+	ctx    context.Context
+	exited atomic.Bool
+}
+
+func (a *endlessAlgo) SetContext(ctx context.Context) {
+	a.ctx = ctx
+}
+
+func (a *endlessAlgo) Schedule(clouds map[string]asmodel.Cloud, apps map[string]asmodel.Application, appsOrder []string) (asmodel.Solution, error) {
+	for a.ctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	a.exited.Store(true)
+	return asmodel.GenEmptySoln(), nil
+}
+
+func TestInnerScheduleWithCtx(t *testing.T) {
+	algo := &endlessAlgo{}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	_, err := scheduleWithCtx(ctx, algo, map[string]asmodel.Cloud{}, map[string]asmodel.Application{}, []string{})
+	assert.True(t, errors.Is(err, context.Canceled), fmt.Sprintf("error: %v", err))
+	// the algorithm should not run after the scheduling lock is released
+	assert.True(t, algo.exited.Load(), "the algorithm is still running after scheduleWithCtx returns")
+}
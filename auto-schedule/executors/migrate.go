@@ -0,0 +1,303 @@
+package executors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/astaxie/beego"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"emcontroller/auto-schedule/algorithms"
+	asmodel "emcontroller/auto-schedule/model"
+	"emcontroller/models"
+)
+
+// Create a job to migrate a group of running auto-scheduling applications, and run it in the background.
+// Migration works in 3 steps:
+// 1. read the auto-scheduling information of the applications from the annotations of their deployments;
+// 2. schedule the applications again, and the resources occupied by them are treated as free. The running applications that they depend on but are not migrated stay where they are, and they are in the scheduling with fixed placements, so that the dependencies on them are still considered;
+// 3. move the applications to their new Kubernetes nodes by rolling updates, and the applications are moved after the ones they depend on.
+func StartMigrateJob(namespace string, appNames []string, algoName string, algoParams map[string]float64, exTimeOneCpu float64) (ScheduleJob, error, int) {
+	if !algorithms.ScheMu.TryLock() {
+		outErr := fmt.Errorf("Another task of Scheduling, Migration or Cleanup is running. Please try later.")
+		beego.Error(outErr)
+		return ScheduleJob{}, outErr, http.StatusLocked
+	}
+
+	// We read the applications here, so that users can get the error at once.
+	infos, pods, depPods, err, statusCode := readMigratingApps(namespace, appNames)
+	if err != nil {
+		algorithms.ScheMu.Unlock()
+		outErr := fmt.Errorf("Read the applications to migrate, Error: [%w]", err)
+		beego.Error(outErr)
+		return ScheduleJob{}, outErr, statusCode
+	}
+	if _, _, _, _, err := chooseAlgorithm(algoName, algoParams, exTimeOneCpu); err != nil {
+		algorithms.ScheMu.Unlock()
+		outErr := fmt.Errorf("Choose the scheduling algorithm, Error: [%w]", err)
+		beego.Error(outErr)
+		return ScheduleJob{}, outErr, http.StatusBadRequest
+	}
+
+	job := &ScheduleJob{
+		Namespace: namespace,
+		AlgoName:  algoName,
+		Phase:     JobPhaseScheduling,
+	}
+	return runScheduleJob(job, func(ctx context.Context, job *ScheduleJob) ([]models.AppInfo, error, int) {
+		return migrateApps(ctx, job, namespace, infos, pods, depPods, algoName, algoParams, exTimeOneCpu)
+	}), nil, http.StatusAccepted
+}
+
+// Read the auto-scheduling information and the pods of the applications to migrate, and the pods of the running applications that they depend on but are not migrated.
+func readMigratingApps(namespace string, appNames []string) (map[string]asmodel.Application, []apiv1.Pod, map[string][]apiv1.Pod, error, int) {
+	if len(appNames) == 0 {
+		return nil, nil, nil, fmt.Errorf("no applications to migrate"), http.StatusBadRequest
+	}
+
+	infos := make(map[string]asmodel.Application)
+	var pods []apiv1.Pod
+	for _, appName := range appNames {
+		if _, exist := infos[appName]; exist {
+			continue
+		}
+
+		deployName := appName + models.DeploymentSuffix
+		deployment, err := models.GetDeployment(namespace, deployName)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("get deployment %s/%s, Error: [%w]", namespace, deployName, err), http.StatusInternalServerError
+		}
+		if deployment == nil {
+			return nil, nil, nil, fmt.Errorf("application [%s/%s] not found", namespace, appName), http.StatusNotFound
+		}
+
+		info, exist := deployment.Annotations[models.AutoScheduleInfoAnno]
+		if !exist {
+			return nil, nil, nil, fmt.Errorf("application [%s] has no annotation [%s], so it was not deployed by auto-scheduling and cannot be migrated", appName, models.AutoScheduleInfoAnno), http.StatusBadRequest
+		}
+		app, err := asmodel.ParseAutoScheduleInfo(info)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("parse the annotation [%s] of application [%s], Error: [%w]", models.AutoScheduleInfoAnno, appName, err), http.StatusInternalServerError
+		}
+		infos[appName] = app
+
+		selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("get the selector of deployment %s/%s, Error: [%w]", namespace, deployName, err), http.StatusInternalServerError
+		}
+		appPods, err := models.ListPods(namespace, metav1.ListOptions{LabelSelector: selector.String()})
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("list the pods of application [%s], Error: [%w]", appName, err), http.StatusInternalServerError
+		}
+		pods = append(pods, appPods...)
+	}
+
+	// the pods of the dependencies not migrated, whose placements are fixed in the scheduling
+	depPods := make(map[string][]apiv1.Pod)
+	for _, info := range infos {
+		for _, dependency := range info.Dependencies {
+			depName := dependency.AppName
+			if _, exist := infos[depName]; exist {
+				continue
+			}
+			if _, exist := depPods[depName]; exist {
+				continue
+			}
+			deployName := depName + models.DeploymentSuffix
+			deployment, err := models.GetDeployment(namespace, deployName)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("get deployment %s/%s of dependency [%s], Error: [%w]", namespace, deployName, depName, err), http.StatusInternalServerError
+			}
+			if deployment == nil {
+				beego.Info(fmt.Sprintf("Application [%s] depends on application [%s], which is not running, so this dependency is not considered in migration.", info.Name, depName))
+				continue
+			}
+			selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("get the selector of deployment %s/%s, Error: [%w]", namespace, deployName, err), http.StatusInternalServerError
+			}
+			appPods, err := models.ListPods(namespace, metav1.ListOptions{LabelSelector: selector.String()})
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("list the pods of dependency [%s], Error: [%w]", depName, err), http.StatusInternalServerError
+			}
+			depPods[depName] = appPods
+		}
+	}
+
+	return infos, pods, depPods, nil, http.StatusOK
+}
+
+// Generate the applications to schedule in migration.
+// The applications that are not migrated stay where they are, so the migrating applications keep the dependencies on them only if they are in fixedApps, i.e., running in the clouds.
+func genMigratingApps(infos map[string]asmodel.Application, fixedApps map[string]asmodel.Application) map[string]asmodel.Application {
+	apps := make(map[string]asmodel.Application)
+	for appName, info := range infos {
+		app := asmodel.AppCopy(info)
+		app.Dependencies = nil
+		for _, dependency := range info.Dependencies {
+			_, migrated := infos[dependency.AppName]
+			_, fixed := fixedApps[dependency.AppName]
+			if migrated || fixed {
+				app.Dependencies = append(app.Dependencies, dependency)
+			} else {
+				beego.Info(fmt.Sprintf("Application [%s] depends on application [%s], which is not migrated and not running in the clouds, so this dependency is not considered in migration.", appName, dependency.AppName))
+			}
+		}
+		apps[appName] = app
+	}
+	return apps
+}
+
+// Generate the applications with fixed placements from the pods of the dependencies not migrated.
+func genFixedDepApps(clouds map[string]asmodel.Cloud, depPods map[string][]apiv1.Pod) map[string]asmodel.Application {
+	fixedApps := make(map[string]asmodel.Application)
+	for depName, pods := range depPods {
+		if app, ok := asmodel.GenFixedApp(clouds, depName, pods); ok {
+			fixedApps[depName] = app
+		}
+	}
+	return fixedApps
+}
+
+// The order to move the applications. The applications in one group can be moved at the same time, and every group is moved after the groups before it.
+// The dependencies on the applications not migrated do not affect the order.
+func migrationOrder(apps map[string]asmodel.Application) ([][]string, bool) {
+	appMap := make(map[string]models.K8sApp)
+	for appName, app := range apps {
+		var dependencies []models.Dependency
+		for _, dependency := range app.Dependencies {
+			if _, exist := apps[dependency.AppName]; exist {
+				dependencies = append(dependencies, dependency)
+			}
+		}
+		appMap[appName] = models.K8sApp{
+			Name:         appName,
+			Dependencies: dependencies,
+		}
+	}
+	order, hasCycle := TopoSort(appMap)
+	for _, group := range order {
+		sort.Strings(group)
+	}
+	return order, hasCycle
+}
+
+// The steps of a migration job.
+func migrateApps(ctx context.Context, job *ScheduleJob, namespace string, infos map[string]asmodel.Application, pods []apiv1.Pod, depPods map[string][]apiv1.Pod, algoName string, algoParams map[string]float64, exTimeOneCpu float64) ([]models.AppInfo, error, int) {
+	cloudsForScheduling, err := asmodel.GenerateClouds(models.ListIaas())
+	if err != nil {
+		outErr := fmt.Errorf("Generate input clouds for migration, Error: [%w]", err)
+		beego.Error(outErr)
+		return []models.AppInfo{}, outErr, http.StatusInternalServerError
+	}
+	// we simulate to remove the migrating applications from the clouds.
+	asmodel.FreePodsResources(cloudsForScheduling, pods)
+
+	fixedApps := genFixedDepApps(cloudsForScheduling, depPods)
+	apps := genMigratingApps(infos, fixedApps)
+	order, hasCycle := migrationOrder(apps)
+	if hasCycle {
+		outErr := fmt.Errorf("The applications to migrate have circular dependencies, the cycles are not in the following applications %+v.", order)
+		beego.Error(outErr)
+		return []models.AppInfo{}, outErr, http.StatusBadRequest
+	}
+
+	// the dependencies not migrated are scheduled together with fixed placements.
+	appsToSchedule := asmodel.AppMapCopy(apps)
+	for appName, app := range fixedApps {
+		appsToSchedule[appName] = app
+	}
+
+	// the applications with multiple replicas are scheduled as multiple applications, one for each replica.
+	replicas := asmodel.ExpandReplicas(appsToSchedule)
+	appsOrder := algorithms.GenerateAppsOrder(replicas)
+	sort.Strings(appsOrder)
+
+	algoToUse, algoNameToUse, resolvedParams, mcssgaInstance, err := chooseAlgorithm(algoName, algoParams, exTimeOneCpu)
+	if err != nil {
+		outErr := fmt.Errorf("Choose the scheduling algorithm for migration, Error: [%w]", err)
+		beego.Error(outErr)
+		return []models.AppInfo{}, outErr, http.StatusBadRequest
+	}
+	replicasSolution, err := scheduleWithCtx(ctx, algoToUse, cloudsForScheduling, replicas, appsOrder)
+	if err != nil {
+		outErr := fmt.Errorf("Run the Schedule method of %s for migration, Error: [%w]", algoNameToUse, err)
+		beego.Error(outErr)
+		return []models.AppInfo{}, outErr, http.StatusInternalServerError
+	}
+	mcssgaInstance.SetMaxReaRtt(cloudsForScheduling)
+	mcssgaInstance.SetAvgDepNum(replicas)
+	solution := asmodel.CollapseReplicas(appsToSchedule, replicasSolution)
+	// the applications not migrated are not in the result
+	for appName := range fixedApps {
+		delete(solution.AppsSolution, appName)
+	}
+	beego.Info(fmt.Sprintf("The algorithm works out the migration solution: %s\nIts fitness value is %g.", models.JsonString(solution), mcssgaInstance.Fitness(cloudsForScheduling, replicas, replicasSolution)))
+
+	job.update(func(j *ScheduleJob) {
+		solnCopy := asmodel.SolutionCopy(solution)
+		j.Solution = &solnCopy
+		j.AlgoName = algoNameToUse
+		j.AlgoParams = resolvedParams
+	})
+
+	if err, statusCode := createSolutionVms(ctx, job, solution); err != nil {
+		return []models.AppInfo{}, err, statusCode
+	}
+
+	if err := checkCancelled(ctx, JobPhaseMigratingApps); err != nil {
+		beego.Error(err)
+		return []models.AppInfo{}, err, http.StatusConflict
+	}
+	job.setPhase(JobPhaseMigratingApps)
+
+	var migratedAppsInfo []models.AppInfo
+	for _, group := range order {
+		for _, appName := range group {
+			appSoln := solution.AppsSolution[appName]
+			// If an application is rejected, there are not enough resources for it on other clouds, so it stays where it is.
+			if !appSoln.Accepted {
+				beego.Info(fmt.Sprintf("Application [%s] is rejected in migration, so it is not moved.", appName))
+				continue
+			}
+
+			var nodeNames []string
+			for _, placement := range appSoln.ReplicaPlacements() {
+				nodeNames = append(nodeNames, placement.K8sNodeName)
+			}
+			var containerCpus []string
+			for _, cpu := range asmodel.SplitCpu(appSoln.AllocatedCpuCore, apps[appName].ContainerCpus) {
+				containerCpus = append(containerCpus, asmodel.ContainerCpuQuantity(cpu))
+			}
+			if err, statusCode := models.MigrateApplication(namespace, appName, nodeNames, containerCpus); err != nil {
+				outErr := fmt.Errorf("Migrate application [%s] to nodes %v, Error: [%w]", appName, nodeNames, err)
+				beego.Error(outErr)
+				return migratedAppsInfo, outErr, statusCode
+			}
+		}
+
+		// the applications in the next group may depend on this group, so we wait for this group running.
+		for _, appName := range group {
+			if !solution.AppsSolution[appName].Accepted {
+				continue
+			}
+			if err := models.WaitForAppRunning(models.WaitForTimeOut, 10, namespace, appName); err != nil {
+				outErr := fmt.Errorf("Wait for migrated application [%s] running, Error: [%w]", appName, err)
+				beego.Error(outErr)
+				return migratedAppsInfo, outErr, http.StatusInternalServerError
+			}
+			appInfo, err, statusCode := models.GetApplication(namespace, appName)
+			if err != nil {
+				outErr := fmt.Errorf("After migration, get application [%s], Error: [%w]", appName, err)
+				beego.Error(outErr)
+				return migratedAppsInfo, outErr, statusCode
+			}
+			migratedAppsInfo = append(migratedAppsInfo, appInfo)
+		}
+	}
+
+	return migratedAppsInfo, nil, http.StatusOK
+}
@@ -0,0 +1,68 @@
+package executors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	asmodel "emcontroller/auto-schedule/model"
+	"emcontroller/models"
+)
+
+func TestInnerMigrationOrder(t *testing.T) {
+	fixedApps := map[string]asmodel.Application{
+		"app-not-migrated": {Name: "app-not-migrated", FixedPlacements: []asmodel.ReplicaSolution{{TargetCloudName: "cloud1", K8sNodeName: "node1", AllocatedCpuCore: 1}}},
+	}
+
+	testCases := []struct {
+		name             string
+		infos            map[string]asmodel.Application
+		expectedDeps     map[string][]models.Dependency
+		expectedOrder    [][]string
+		expectedHasCycle bool
+	}{
+		{
+			name: "dependencies on running applications not migrated are kept, and the others are dropped",
+			infos: map[string]asmodel.Application{
+				"app1": {Name: "app1", Dependencies: []models.Dependency{{AppName: "app2"}, {AppName: "app-not-migrated"}}},
+				"app2": {Name: "app2", Dependencies: []models.Dependency{{AppName: "app3"}, {AppName: "app-not-running"}}},
+				"app3": {Name: "app3"},
+				"app4": {Name: "app4", Dependencies: []models.Dependency{{AppName: "app-not-migrated"}}},
+			},
+			expectedDeps: map[string][]models.Dependency{
+				"app1": {{AppName: "app2"}, {AppName: "app-not-migrated"}},
+				"app2": {{AppName: "app3"}},
+				"app3": nil,
+				"app4": {{AppName: "app-not-migrated"}},
+			},
+			expectedOrder:    [][]string{{"app3", "app4"}, {"app2"}, {"app1"}},
+			expectedHasCycle: false,
+		},
+		{
+			name: "circular dependencies",
+			infos: map[string]asmodel.Application{
+				"app1": {Name: "app1", Dependencies: []models.Dependency{{AppName: "app2"}}},
+				"app2": {Name: "app2", Dependencies: []models.Dependency{{AppName: "app1"}}},
+				"app3": {Name: "app3"},
+			},
+			expectedDeps: map[string][]models.Dependency{
+				"app1": {{AppName: "app2"}},
+				"app2": {{AppName: "app1"}},
+				"app3": nil,
+			},
+			expectedOrder:    [][]string{{"app3"}},
+			expectedHasCycle: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Logf("test: %s", testCase.name)
+		apps := genMigratingApps(testCase.infos, fixedApps)
+		for appName, expectedDeps := range testCase.expectedDeps {
+			assert.Equal(t, expectedDeps, apps[appName].Dependencies, "dependencies of %s", appName)
+		}
+		order, hasCycle := migrationOrder(apps)
+		assert.Equal(t, testCase.expectedOrder, order)
+		assert.Equal(t, testCase.expectedHasCycle, hasCycle)
+	}
+}
@@ -0,0 +1,226 @@
+package executors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/astaxie/beego"
+	apiv1 "k8s.io/api/core/v1"
+
+	"emcontroller/auto-schedule/algorithms"
+	asmodel "emcontroller/auto-schedule/model"
+	"emcontroller/models"
+)
+
+// binary-floating-point data is not accurate, so we allow a delta when checking whether the residual resources are negative
+const planResDelta float64 = 0.0001
+
+// A scheduling plan is the result of running a scheduling algorithm without deploying anything.
+// Users can check the plan, and then apply it, and the applied plan is deployed exactly as it is, without scheduling again.
+type SchedulePlan struct {
+	Apps         []models.K8sApp       `json:"apps"`
+	AlgoName     string                `json:"algoName"`
+	AlgoParams   algorithms.AlgoParams `json:"algoParams,omitempty"`
+	Solution     asmodel.Solution      `json:"solution"`
+	Fitness      float64               `json:"fitness"`
+	AcceptedApps []string              `json:"acceptedApps"`
+	RejectedApps []string              `json:"rejectedApps"`
+	// key: application name, value: the persistent storage (GiB) of the application, which is provided by the storage classes but not the target nodes. Only the applications with PVCs are in it.
+	PersistentStorage map[string]float64 `json:"persistentStorage,omitempty"`
+}
+
+func newSchedulePlan(apps []models.K8sApp, appsForScheduling map[string]asmodel.Application, algoName string, algoParams algorithms.AlgoParams, solution asmodel.Solution, fitness float64) SchedulePlan {
+	plan := SchedulePlan{
+		Apps:         apps,
+		AlgoName:     algoName,
+		AlgoParams:   algoParams,
+		Solution:     solution,
+		Fitness:      fitness,
+		AcceptedApps: []string{},
+		RejectedApps: []string{},
+	}
+	for _, app := range apps {
+		if persistentStorage := appsForScheduling[app.Name].Resources.PersistentStorage; persistentStorage > 0 {
+			if plan.PersistentStorage == nil {
+				plan.PersistentStorage = make(map[string]float64)
+			}
+			plan.PersistentStorage[app.Name] = persistentStorage
+		}
+		if solution.AppsSolution[app.Name].Accepted {
+			plan.AcceptedApps = append(plan.AcceptedApps, app.Name)
+		} else {
+			plan.RejectedApps = append(plan.RejectedApps, app.Name)
+		}
+	}
+	sort.Strings(plan.AcceptedApps)
+	sort.Strings(plan.RejectedApps)
+	return plan
+}
+
+// Run the scheduling algorithm and return the plan, without creating VMs or deploying applications.
+// The clouds may be changed by other tasks during scheduling, so this also needs the scheduling lock.
+func PlanAutoScheduleApps(apps []models.K8sApp, algoName string, algoParams map[string]float64, exTimeOneCpu float64) (SchedulePlan, error, int) {
+	if !algorithms.ScheMu.TryLock() {
+		outErr := fmt.Errorf("Another task of Scheduling, Migration or Cleanup is running. Please try later.")
+		beego.Error(outErr)
+		return SchedulePlan{}, outErr, http.StatusLocked
+	}
+	defer algorithms.ScheMu.Unlock()
+
+	return scheduleApps(context.Background(), apps, algoName, algoParams, exTimeOneCpu)
+}
+
+// Check whether a plan sent by users can be applied. The plan may be edited by users, so we do not trust it.
+func ValidateSchedulePlan(plan SchedulePlan) []error {
+	var errs []error
+
+	if errs := ValidateAutoScheduleApps(plan.Apps); len(errs) != 0 {
+		return errs
+	}
+
+	if plan.Solution.AppsSolution == nil {
+		return append(errs, fmt.Errorf("the plan has no appsSolution"))
+	}
+
+	appNames := make(map[string]struct{})
+	for _, app := range plan.Apps {
+		appNames[app.Name] = struct{}{}
+		appSoln, exist := plan.Solution.AppsSolution[app.Name]
+		if !exist {
+			errs = append(errs, fmt.Errorf("application [%s] has no solution in the plan", app.Name))
+			continue
+		}
+		if !appSoln.Accepted {
+			continue
+		}
+		if len(appSoln.TargetCloudName) == 0 {
+			errs = append(errs, fmt.Errorf("application [%s] is accepted, but its targetCloudName is empty", app.Name))
+		} else if _, exist := models.GetIaas(appSoln.TargetCloudName); !exist {
+			errs = append(errs, fmt.Errorf("the target cloud [%s] of application [%s] does not exist", appSoln.TargetCloudName, app.Name))
+		}
+		if len(appSoln.K8sNodeName) == 0 {
+			errs = append(errs, fmt.Errorf("application [%s] is accepted, but its k8sNodeName is empty", app.Name))
+		}
+		if appSoln.AllocatedCpuCore <= 0 {
+			errs = append(errs, fmt.Errorf("application [%s] is accepted, but its allocatedCpuCore %g is not positive", app.Name, appSoln.AllocatedCpuCore))
+		}
+		errs = append(errs, validateReplicasSoln(app, appSoln)...)
+	}
+
+	for appName := range plan.Solution.AppsSolution {
+		if _, exist := appNames[appName]; !exist {
+			errs = append(errs, fmt.Errorf("the solution of application [%s] is in the plan, but the application is not", appName))
+		}
+	}
+
+	for _, vm := range plan.Solution.VmsToCreate {
+		if _, exist := models.GetIaas(vm.Cloud); !exist {
+			errs = append(errs, fmt.Errorf("the cloud [%s] of the VM [%s] to create does not exist", vm.Cloud, vm.Name))
+		}
+	}
+
+	return errs
+}
+
+// A plan is made on the clouds at the time of planning, and the clouds may have changed when it is applied, so we check it again with the current clouds:
+// every Kubernetes node in the plan should exist on its target cloud or be a VM to create there, and the nodes should still have enough resources for the applications placed on them.
+func validatePlanResources(clouds map[string]asmodel.Cloud, plan SchedulePlan) []error {
+	var errs []error
+
+	apps, err := asmodel.GenerateApplications(plan.Apps)
+	if err != nil {
+		return append(errs, fmt.Errorf("generate the applications of the plan for auto-scheduling, Error: [%w]", err))
+	}
+
+	// the cloud and the residual resources of every node that the plan can use
+	type planNode struct {
+		cloudName string
+		residual  asmodel.GenericResources
+	}
+	nodes := make(map[string]planNode)
+	for cloudName, cloud := range clouds {
+		for _, node := range cloud.K8sNodes {
+			nodes[node.Name] = planNode{cloudName: cloudName, residual: node.ResidualResources}
+		}
+	}
+	for _, vm := range plan.Solution.VmsToCreate {
+		if _, exist := nodes[vm.Name]; exist {
+			errs = append(errs, fmt.Errorf("the VM [%s] to create is already a Kubernetes node", vm.Name))
+			continue
+		}
+		nodes[vm.Name] = planNode{cloudName: vm.Cloud, residual: asmodel.GenK8sNodeFromPods(vm, []apiv1.Pod{}).ResidualResources}
+	}
+
+	// subtract the resources of the applications from the nodes where they are placed
+	usedNodes := make(map[string]struct{})
+	for _, app := range plan.Apps {
+		for _, placement := range plan.Solution.AppsSolution[app.Name].ReplicaPlacements() {
+			node, exist := nodes[placement.K8sNodeName]
+			if !exist {
+				errs = append(errs, fmt.Errorf("the Kubernetes node [%s] of application [%s] does not exist and is not in the VMs to create", placement.K8sNodeName, app.Name))
+				continue
+			}
+			if node.cloudName != placement.TargetCloudName {
+				errs = append(errs, fmt.Errorf("the Kubernetes node [%s] of application [%s] is on cloud [%s], but the target cloud is [%s]", placement.K8sNodeName, app.Name, node.cloudName, placement.TargetCloudName))
+				continue
+			}
+			usedNodes[placement.K8sNodeName] = struct{}{}
+			node.residual.CpuCore -= placement.AllocatedCpuCore
+			node.residual.Memory -= apps[app.Name].Resources.Memory
+			node.residual.Storage -= apps[app.Name].Resources.Storage
+			nodes[placement.K8sNodeName] = node
+		}
+	}
+
+	var usedNodeNames []string
+	for nodeName := range usedNodes {
+		usedNodeNames = append(usedNodeNames, nodeName)
+	}
+	sort.Strings(usedNodeNames)
+	for _, nodeName := range usedNodeNames {
+		residual := nodes[nodeName].residual
+		if residual.CpuCore < -planResDelta || residual.Memory < -planResDelta || residual.Storage < -planResDelta {
+			errs = append(errs, fmt.Errorf("the Kubernetes node [%s] does not have enough resources for the applications placed on it, the residual resources after placing them are CPU %g, memory %g MiB and storage %g GiB", nodeName, residual.CpuCore, residual.Memory, residual.Storage))
+		}
+	}
+
+	return errs
+}
+
+// Check the solutions of the replicas of an accepted application.
+func validateReplicasSoln(app models.K8sApp, appSoln asmodel.SingleAppSolution) []error {
+	var errs []error
+	if len(appSoln.Replicas) == 0 {
+		return errs
+	}
+
+	if int32(len(appSoln.Replicas)) > app.Replicas {
+		errs = append(errs, fmt.Errorf("application [%s] has [%d] replicas, but [%d] replicas are in its solution", app.Name, app.Replicas, len(appSoln.Replicas)))
+	}
+	usedNodes := make(map[string]struct{})
+	usedClouds := make(map[string]struct{})
+	for i, replica := range appSoln.Replicas {
+		if _, exist := models.GetIaas(replica.TargetCloudName); !exist {
+			errs = append(errs, fmt.Errorf("the target cloud [%s] of replica [%d] of application [%s] does not exist", replica.TargetCloudName, i, app.Name))
+		}
+		if len(replica.K8sNodeName) == 0 {
+			errs = append(errs, fmt.Errorf("the k8sNodeName of replica [%d] of application [%s] is empty", i, app.Name))
+		} else if _, exist := usedNodes[replica.K8sNodeName]; exist {
+			errs = append(errs, fmt.Errorf("more than one replica of application [%s] are on node [%s]", app.Name, replica.K8sNodeName))
+		}
+		usedNodes[replica.K8sNodeName] = struct{}{}
+		if app.ReplicaSpread == asmodel.ReplicaSpreadCloud {
+			if _, exist := usedClouds[replica.TargetCloudName]; exist {
+				errs = append(errs, fmt.Errorf("more than one replica of application [%s] are on cloud [%s], but its replicaSpread is [%s]", app.Name, replica.TargetCloudName, app.ReplicaSpread))
+			}
+			usedClouds[replica.TargetCloudName] = struct{}{}
+		}
+		// all replicas are deployed by one deployment, so they have the same CPU
+		if replica.AllocatedCpuCore != appSoln.AllocatedCpuCore {
+			errs = append(errs, fmt.Errorf("the allocatedCpuCore %g of replica [%d] of application [%s] is different from the allocatedCpuCore %g of the application", replica.AllocatedCpuCore, i, app.Name, appSoln.AllocatedCpuCore))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,318 @@
+package executors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	asmodel "emcontroller/auto-schedule/model"
+	"emcontroller/models"
+)
+
+func TestValidateSchedulePlan(t *testing.T) {
+	planApp := func(name string) models.K8sApp {
+		return models.K8sApp{
+			Name:          name,
+			Priority:      5,
+			Replicas:      1,
+			AutoScheduled: true,
+			Containers: []models.K8sContainer{
+				{
+					Name:  name,
+					Image: "172.27.15.31:5000/nginx:1.17.1",
+					Resources: models.K8sResReq{
+						Limits: models.K8sResList{
+							Memory:  "100Mi",
+							CPU:     "2",
+							Storage: "2Gi",
+						},
+						Requests: models.K8sResList{
+							Memory:  "100Mi",
+							CPU:     "2",
+							Storage: "2Gi",
+						},
+					},
+				},
+			},
+		}
+	}
+
+	replicaPlanApp := func(name string, replicas int32) models.K8sApp {
+		app := planApp(name)
+		app.Replicas = replicas
+		return app
+	}
+
+	oldClouds := models.Clouds
+	defer func() {
+		models.Clouds = oldClouds
+	}()
+	models.Clouds = map[string]models.Iaas{
+		"nokia4": &models.Proxmox{Name: "nokia4"},
+	}
+
+	testCases := []struct {
+		name           string
+		plan           SchedulePlan
+		expectedErrNum int
+	}{
+		{
+			name: "valid plan",
+			plan: SchedulePlan{
+				Apps: []models.K8sApp{planApp("app1"), planApp("app2")},
+				Solution: asmodel.Solution{
+					AppsSolution: map[string]asmodel.SingleAppSolution{
+						"app1": {Accepted: true, TargetCloudName: "nokia4", K8sNodeName: "auto-sched-nokia4-0", AllocatedCpuCore: 2},
+						"app2": {Accepted: false},
+					},
+					VmsToCreate: []models.IaasVm{
+						{Name: "auto-sched-nokia4-0", Cloud: "nokia4"},
+					},
+				},
+			},
+			expectedErrNum: 0,
+		},
+		{
+			name: "no appsSolution",
+			plan: SchedulePlan{
+				Apps: []models.K8sApp{planApp("app1")},
+			},
+			expectedErrNum: 1,
+		},
+		{
+			name: "application without solution and solution without application",
+			plan: SchedulePlan{
+				Apps: []models.K8sApp{planApp("app1")},
+				Solution: asmodel.Solution{
+					AppsSolution: map[string]asmodel.SingleAppSolution{
+						"app2": {Accepted: false},
+					},
+				},
+			},
+			expectedErrNum: 2,
+		},
+		{
+			name: "accepted application with incomplete solution",
+			plan: SchedulePlan{
+				Apps: []models.K8sApp{planApp("app1")},
+				Solution: asmodel.Solution{
+					AppsSolution: map[string]asmodel.SingleAppSolution{
+						"app1": {Accepted: true},
+					},
+				},
+			},
+			expectedErrNum: 3,
+		},
+		{
+			name: "unknown clouds",
+			plan: SchedulePlan{
+				Apps: []models.K8sApp{planApp("app1")},
+				Solution: asmodel.Solution{
+					AppsSolution: map[string]asmodel.SingleAppSolution{
+						"app1": {Accepted: true, TargetCloudName: "nokia100", K8sNodeName: "auto-sched-nokia100-0", AllocatedCpuCore: 2},
+					},
+					VmsToCreate: []models.IaasVm{
+						{Name: "auto-sched-nokia100-0", Cloud: "nokia100"},
+					},
+				},
+			},
+			expectedErrNum: 2,
+		},
+		{
+			name: "valid replicas",
+			plan: SchedulePlan{
+				Apps: []models.K8sApp{replicaPlanApp("app1", 3)},
+				Solution: asmodel.Solution{
+					AppsSolution: map[string]asmodel.SingleAppSolution{
+						"app1": {Accepted: true, TargetCloudName: "nokia4", K8sNodeName: "node1", AllocatedCpuCore: 2, Replicas: []asmodel.ReplicaSolution{
+							{TargetCloudName: "nokia4", K8sNodeName: "node1", AllocatedCpuCore: 2},
+							{TargetCloudName: "nokia4", K8sNodeName: "node2", AllocatedCpuCore: 2},
+						}},
+					},
+				},
+			},
+			expectedErrNum: 0,
+		},
+		{
+			name: "invalid replicas",
+			plan: SchedulePlan{
+				Apps: []models.K8sApp{replicaPlanApp("app1", 2)},
+				Solution: asmodel.Solution{
+					AppsSolution: map[string]asmodel.SingleAppSolution{
+						"app1": {Accepted: true, TargetCloudName: "nokia4", K8sNodeName: "node1", AllocatedCpuCore: 2, Replicas: []asmodel.ReplicaSolution{
+							{TargetCloudName: "nokia4", K8sNodeName: "node1", AllocatedCpuCore: 2},
+							{TargetCloudName: "nokia4", K8sNodeName: "node1", AllocatedCpuCore: 1},
+							{TargetCloudName: "nokia100", K8sNodeName: "node3", AllocatedCpuCore: 2},
+						}},
+					},
+				},
+			},
+			expectedErrNum: 4,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Logf("test: %s", testCase.name)
+		errs := ValidateSchedulePlan(testCase.plan)
+		for _, err := range errs {
+			t.Logf("error: %s", err.Error())
+		}
+		assert.Len(t, errs, testCase.expectedErrNum)
+	}
+}
+
+func TestInnerValidatePlanResources(t *testing.T) {
+	planApp := func(name string, replicas int32) models.K8sApp {
+		return models.K8sApp{
+			Name:          name,
+			Priority:      5,
+			Replicas:      replicas,
+			AutoScheduled: true,
+			Containers: []models.K8sContainer{
+				{
+					Name:  name,
+					Image: "172.27.15.31:5000/nginx:1.17.1",
+					Resources: models.K8sResReq{
+						Requests: models.K8sResList{
+							Memory:  "100Mi",
+							CPU:     "2",
+							Storage: "2Gi",
+						},
+					},
+				},
+			},
+		}
+	}
+
+	clouds := map[string]asmodel.Cloud{
+		"nokia4": {Name: "nokia4", K8sNodes: []asmodel.K8sNode{
+			{Name: "node1", ResidualResources: asmodel.GenericResources{CpuCore: 4, Memory: 1000, Storage: 10}},
+			{Name: "node2", ResidualResources: asmodel.GenericResources{CpuCore: 1, Memory: 1000, Storage: 10}},
+		}},
+		"nokia7": {Name: "nokia7", K8sNodes: []asmodel.K8sNode{
+			{Name: "node3", ResidualResources: asmodel.GenericResources{CpuCore: 4, Memory: 1000, Storage: 10}},
+		}},
+	}
+	newVm := models.IaasVm{Name: "auto-sched-nokia4-0", Cloud: "nokia4", VCpu: 4, Ram: 4096, Storage: 50}
+
+	testCases := []struct {
+		name           string
+		apps           []models.K8sApp
+		solution       asmodel.Solution
+		expectedErrNum int
+	}{
+		{
+			name: "existing node and VM to create",
+			apps: []models.K8sApp{planApp("app1", 1), planApp("app2", 1), planApp("app3", 1)},
+			solution: asmodel.Solution{
+				AppsSolution: map[string]asmodel.SingleAppSolution{
+					"app1": {Accepted: true, TargetCloudName: "nokia4", K8sNodeName: "node1", AllocatedCpuCore: 2},
+					"app2": {Accepted: true, TargetCloudName: "nokia4", K8sNodeName: "auto-sched-nokia4-0", AllocatedCpuCore: 2},
+					"app3": {Accepted: false},
+				},
+				VmsToCreate: []models.IaasVm{newVm},
+			},
+			expectedErrNum: 0,
+		},
+		{
+			name: "node not existing",
+			apps: []models.K8sApp{planApp("app1", 1)},
+			solution: asmodel.Solution{
+				AppsSolution: map[string]asmodel.SingleAppSolution{
+					"app1": {Accepted: true, TargetCloudName: "nokia4", K8sNodeName: "node9", AllocatedCpuCore: 2},
+				},
+			},
+			expectedErrNum: 1,
+		},
+		{
+			name: "node on another cloud",
+			apps: []models.K8sApp{planApp("app1", 1)},
+			solution: asmodel.Solution{
+				AppsSolution: map[string]asmodel.SingleAppSolution{
+					"app1": {Accepted: true, TargetCloudName: "nokia7", K8sNodeName: "node1", AllocatedCpuCore: 2},
+				},
+			},
+			expectedErrNum: 1,
+		},
+		{
+			name: "VM to create already a node",
+			apps: []models.K8sApp{planApp("app1", 1)},
+			solution: asmodel.Solution{
+				AppsSolution: map[string]asmodel.SingleAppSolution{
+					"app1": {Accepted: true, TargetCloudName: "nokia4", K8sNodeName: "node1", AllocatedCpuCore: 2},
+				},
+				VmsToCreate: []models.IaasVm{{Name: "node1", Cloud: "nokia4", VCpu: 4, Ram: 4096, Storage: 50}},
+			},
+			expectedErrNum: 1,
+		},
+		{
+			// the resources of node1 were enough when the plan was made, but some are used by others now
+			name: "CPU not enough",
+			apps: []models.K8sApp{planApp("app1", 1), planApp("app2", 1)},
+			solution: asmodel.Solution{
+				AppsSolution: map[string]asmodel.SingleAppSolution{
+					"app1": {Accepted: true, TargetCloudName: "nokia4", K8sNodeName: "node1", AllocatedCpuCore: 2},
+					"app2": {Accepted: true, TargetCloudName: "nokia4", K8sNodeName: "node1", AllocatedCpuCore: 3},
+				},
+			},
+			expectedErrNum: 1,
+		},
+		{
+			name: "one replica on a node without enough CPU",
+			apps: []models.K8sApp{planApp("app1", 3)},
+			solution: asmodel.Solution{
+				AppsSolution: map[string]asmodel.SingleAppSolution{
+					"app1": {Accepted: true, TargetCloudName: "nokia4", K8sNodeName: "node1", AllocatedCpuCore: 2, Replicas: []asmodel.ReplicaSolution{
+						{TargetCloudName: "nokia4", K8sNodeName: "node1", AllocatedCpuCore: 2},
+						{TargetCloudName: "nokia4", K8sNodeName: "node2", AllocatedCpuCore: 2},
+						{TargetCloudName: "nokia7", K8sNodeName: "node3", AllocatedCpuCore: 2},
+					}},
+				},
+			},
+			expectedErrNum: 1,
+		},
+		{
+			name: "storage not enough",
+			apps: []models.K8sApp{planApp("app1", 1), planApp("app2", 1), planApp("app3", 1), planApp("app4", 1), planApp("app5", 1), planApp("app6", 1)},
+			solution: asmodel.Solution{
+				AppsSolution: map[string]asmodel.SingleAppSolution{
+					"app1": {Accepted: true, TargetCloudName: "nokia7", K8sNodeName: "node3", AllocatedCpuCore: 0.5},
+					"app2": {Accepted: true, TargetCloudName: "nokia7", K8sNodeName: "node3", AllocatedCpuCore: 0.5},
+					"app3": {Accepted: true, TargetCloudName: "nokia7", K8sNodeName: "node3", AllocatedCpuCore: 0.5},
+					"app4": {Accepted: true, TargetCloudName: "nokia7", K8sNodeName: "node3", AllocatedCpuCore: 0.5},
+					"app5": {Accepted: true, TargetCloudName: "nokia7", K8sNodeName: "node3", AllocatedCpuCore: 0.5},
+					"app6": {Accepted: true, TargetCloudName: "nokia7", K8sNodeName: "node3", AllocatedCpuCore: 0.5},
+				},
+			},
+			expectedErrNum: 1,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		errs := validatePlanResources(clouds, SchedulePlan{Apps: testCase.apps, Solution: testCase.solution})
+		for _, err := range errs {
+			t.Logf("error: %s", err.Error())
+		}
+		assert.Len(t, errs, testCase.expectedErrNum, testCase.name)
+	}
+}
+
+func TestInnerNewSchedulePlan(t *testing.T) {
+	apps := []models.K8sApp{{Name: "app1"}, {Name: "app2"}, {Name: "app3"}}
+	appsForScheduling := map[string]asmodel.Application{
+		"app1": {Name: "app1", Resources: asmodel.AppResources{GenericResources: asmodel.GenericResources{CpuCore: 1}, PersistentStorage: 10.5}},
+		"app2": {Name: "app2", Resources: asmodel.AppResources{GenericResources: asmodel.GenericResources{CpuCore: 1}}},
+		"app3": {Name: "app3", Resources: asmodel.AppResources{PersistentStorage: 20}},
+	}
+	solution := asmodel.Solution{AppsSolution: map[string]asmodel.SingleAppSolution{
+		"app1": {Accepted: true},
+		"app2": {Accepted: true},
+		"app3": {Accepted: false},
+	}}
+
+	plan := newSchedulePlan(apps, appsForScheduling, "Mcssga", nil, solution, 1)
+	assert.Equal(t, []string{"app1", "app2"}, plan.AcceptedApps)
+	assert.Equal(t, []string{"app3"}, plan.RejectedApps)
+	assert.Equal(t, map[string]float64{"app1": 10.5, "app3": 20}, plan.PersistentStorage)
+}
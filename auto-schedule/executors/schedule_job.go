@@ -0,0 +1,366 @@
+package executors
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"emcontroller/auto-schedule/algorithms"
+	asmodel "emcontroller/auto-schedule/model"
+	"emcontroller/models"
+)
+
+// The phases of a scheduling job. A job goes through the phases in this order, and finally ends in one of the 3 terminal phases.
+type JobPhase string
+
+const (
+	JobPhaseScheduling    JobPhase = "Scheduling"
+	JobPhaseCreatingVms   JobPhase = "CreatingVms"
+	JobPhaseJoiningNodes  JobPhase = "JoiningNodes"
+	JobPhaseDeployingApps JobPhase = "DeployingApps"
+	JobPhaseMigratingApps JobPhase = "MigratingApps" // only for migration jobs, instead of "DeployingApps"
+	JobPhaseSucceeded     JobPhase = "Succeeded"
+	JobPhaseFailed        JobPhase = "Failed"
+	JobPhaseCancelled     JobPhase = "Cancelled"
+
+	DefaultScheJobDir string = "schedule-jobs/" // the folder to save the records of scheduling jobs, so that they can survive a restart of multi-cloud manager.
+
+	scheJobIdPrefix string = "sj-"
+)
+
+// A scheduling job created by an asynchronous request to deploy an application group.
+// The partial results are filled in when the job goes through the phases, so users can check them before the job finishes.
+type ScheduleJob struct {
+	ID         string                `json:"id"`
+	Namespace  string                `json:"namespace"` // the namespace of the tenant of the applications
+	AlgoName   string                `json:"algoName"`
+	AlgoParams algorithms.AlgoParams `json:"algoParams,omitempty"` // set after the algorithm is chosen
+	Phase      JobPhase              `json:"phase"`
+	Solution   *asmodel.Solution     `json:"solution,omitempty"`   // set after the phase "Scheduling"
+	CreatedVms []models.IaasVm       `json:"createdVms,omitempty"` // set after the phase "CreatingVms"
+	Apps       []models.AppInfo      `json:"apps,omitempty"`       // set after the phase "DeployingApps"
+	Error      string                `json:"error,omitempty"`
+	StatusCode int                   `json:"statusCode"` // the HTTP status code that the synchronous API would have returned
+	CreateTime time.Time             `json:"createTime"`
+	UpdateTime time.Time             `json:"updateTime"`
+	cancel     context.CancelFunc    // not saved, because a job loaded from the disk is not running.
+}
+
+// Whether the job has finished, no matter successfully or not.
+func (j ScheduleJob) Finished() bool {
+	return j.Phase == JobPhaseSucceeded || j.Phase == JobPhaseFailed || j.Phase == JobPhaseCancelled
+}
+
+var (
+	scheJobs   map[string]*ScheduleJob = make(map[string]*ScheduleJob)
+	scheJobsMu sync.RWMutex            // the map in golang is not safe for concurrent read/write
+	scheJobDir string                  = DefaultScheJobDir
+)
+
+// Load the records of the scheduling jobs from the disk. This should be called once when multi-cloud manager starts.
+// The jobs which were not finished before the restart cannot continue, so we mark them as failed, and then the users polling them will not wait forever.
+func InitScheduleJobs() error {
+	scheJobDir = beego.AppConfig.DefaultString("scheduleJobDir", DefaultScheJobDir)
+	return loadScheduleJobs(scheJobDir)
+}
+
+func loadScheduleJobs(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		outErr := fmt.Errorf("Create the folder [%s] of scheduling jobs, error: %w", dir, err)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, scheJobIdPrefix+"*.json"))
+	if err != nil {
+		outErr := fmt.Errorf("List the records of scheduling jobs in [%s], error: %w", dir, err)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	scheJobsMu.Lock()
+	defer scheJobsMu.Unlock()
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			beego.Error(fmt.Sprintf("Read the record of scheduling job [%s], error: %s. We skip it.", file, err.Error()))
+			continue
+		}
+		var job ScheduleJob
+		if err := json.Unmarshal(content, &job); err != nil {
+			beego.Error(fmt.Sprintf("json.Unmarshal the record of scheduling job [%s], error: %s. We skip it.", file, err.Error()))
+			continue
+		}
+		if !job.Finished() {
+			beego.Info(fmt.Sprintf("Scheduling job [%s] was in phase [%s] when multi-cloud manager stopped, so we mark it as [%s].", job.ID, job.Phase, JobPhaseFailed))
+			job.Error = fmt.Sprintf("interrupted in phase [%s] by a restart of multi-cloud manager", job.Phase)
+			job.Phase = JobPhaseFailed
+			job.StatusCode = http.StatusInternalServerError
+			job.UpdateTime = time.Now()
+			if err := saveScheduleJob(dir, job); err != nil {
+				beego.Error(fmt.Sprintf("Save the record of scheduling job [%s], error: %s", job.ID, err.Error()))
+			}
+		}
+		scheJobs[job.ID] = &job
+	}
+
+	beego.Info(fmt.Sprintf("%d scheduling jobs are loaded from [%s].", len(scheJobs), dir))
+	return nil
+}
+
+// write the record of a job into a file. We write a temporary file and rename it, so that a crash will not leave a broken record.
+func saveScheduleJob(dir string, job ScheduleJob) error {
+	content, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("json.Marshal scheduling job [%s], error: %w", job.ID, err)
+	}
+	path := filepath.Join(dir, job.ID+".json")
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0644); err != nil {
+		return fmt.Errorf("write file [%s], error: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("rename file [%s] to [%s], error: %w", tmpPath, path, err)
+	}
+	return nil
+}
+
+func genScheJobId() string {
+	randBytes := make([]byte, 4)
+	if _, err := rand.Read(randBytes); err != nil {
+		beego.Error(fmt.Sprintf("Generate random bytes for the ID of scheduling job, error: %s", err.Error()))
+	}
+	return fmt.Sprintf("%s%d-%s", scheJobIdPrefix, time.Now().UnixNano(), hex.EncodeToString(randBytes))
+}
+
+// change a job and save it. All changes of a running job should be done through this method.
+// The method can be called on a nil job, in which case it does nothing, so that the synchronous functions can share the code with jobs.
+func (j *ScheduleJob) update(f func(job *ScheduleJob)) {
+	if j == nil {
+		return
+	}
+	scheJobsMu.Lock()
+	defer scheJobsMu.Unlock()
+	f(j)
+	j.UpdateTime = time.Now()
+	if err := saveScheduleJob(scheJobDir, *j); err != nil {
+		beego.Error(fmt.Sprintf("Save the record of scheduling job [%s], error: %s", j.ID, err.Error()))
+	}
+}
+
+func (j *ScheduleJob) setPhase(phase JobPhase) {
+	beego.Info(fmt.Sprintf("Scheduling job [%s] goes into phase [%s].", j.idOrSync(), phase))
+	j.update(func(job *ScheduleJob) {
+		job.Phase = phase
+	})
+}
+
+func (j *ScheduleJob) idOrSync() string {
+	if j == nil {
+		return "synchronous"
+	}
+	return j.ID
+}
+
+// Create a scheduling job for the applications and run it in the background.
+// Scheduling, migration, and cleanup cannot be done at the same time, so if another task is running, no job will be created.
+func StartScheduleJob(apps []models.K8sApp, algoName string, algoParams map[string]float64, exTimeOneCpu float64) (ScheduleJob, error, int) {
+	if !algorithms.ScheMu.TryLock() {
+		outErr := fmt.Errorf("Another task of Scheduling, Migration or Cleanup is running. Please try later.")
+		beego.Error(outErr)
+		return ScheduleJob{}, outErr, http.StatusLocked
+	}
+
+	// We validate the applications here, so that users can get the error at once.
+	if errs := ValidateAutoScheduleApps(apps); len(errs) != 0 {
+		algorithms.ScheMu.Unlock()
+		outErr := fmt.Errorf("The input applicatios are invalid, Error: [%w]", models.HandleErrSlice(errs))
+		beego.Error(outErr)
+		return ScheduleJob{}, outErr, http.StatusBadRequest
+	}
+	if err, statusCode := checkAppsStorageClasses(apps); err != nil {
+		algorithms.ScheMu.Unlock()
+		return ScheduleJob{}, err, statusCode
+	}
+	if _, _, _, _, err := chooseAlgorithm(algoName, algoParams, exTimeOneCpu); err != nil {
+		algorithms.ScheMu.Unlock()
+		outErr := fmt.Errorf("Choose the scheduling algorithm, Error: [%w]", err)
+		beego.Error(outErr)
+		return ScheduleJob{}, outErr, http.StatusBadRequest
+	}
+
+	job := &ScheduleJob{
+		Namespace: AppsNamespace(apps),
+		AlgoName:  algoName,
+		Phase:     JobPhaseScheduling,
+	}
+	return runScheduleJob(job, func(ctx context.Context, job *ScheduleJob) ([]models.AppInfo, error, int) {
+		return createAutoScheduleApps(ctx, job, apps, algoName, algoParams, exTimeOneCpu)
+	}), nil, http.StatusAccepted
+}
+
+// Create a job to deploy a plan made before, and run it in the background. The plan is not scheduled again.
+func StartApplyPlanJob(plan SchedulePlan) (ScheduleJob, error, int) {
+	if !algorithms.ScheMu.TryLock() {
+		outErr := fmt.Errorf("Another task of Scheduling, Migration or Cleanup is running. Please try later.")
+		beego.Error(outErr)
+		return ScheduleJob{}, outErr, http.StatusLocked
+	}
+
+	if errs := ValidateSchedulePlan(plan); len(errs) != 0 {
+		algorithms.ScheMu.Unlock()
+		outErr := fmt.Errorf("The input plan is invalid, Error: [%w]", models.HandleErrSlice(errs))
+		beego.Error(outErr)
+		return ScheduleJob{}, outErr, http.StatusBadRequest
+	}
+
+	// the clouds may have changed since the plan was made
+	clouds, err := asmodel.GenerateClouds(models.ListIaas())
+	if err != nil {
+		algorithms.ScheMu.Unlock()
+		outErr := fmt.Errorf("Generate the clouds to check the plan, Error: [%w]", err)
+		beego.Error(outErr)
+		return ScheduleJob{}, outErr, http.StatusInternalServerError
+	}
+	if errs := validatePlanResources(clouds, plan); len(errs) != 0 {
+		algorithms.ScheMu.Unlock()
+		outErr := fmt.Errorf("The input plan cannot be applied to the current clouds, please make a new plan, Error: [%w]", models.HandleErrSlice(errs))
+		beego.Error(outErr)
+		return ScheduleJob{}, outErr, http.StatusConflict
+	}
+	if err, statusCode := checkAppsStorageClasses(plan.Apps); err != nil {
+		algorithms.ScheMu.Unlock()
+		return ScheduleJob{}, err, statusCode
+	}
+
+	solnCopy := asmodel.SolutionCopy(plan.Solution)
+	job := &ScheduleJob{
+		Namespace:  AppsNamespace(plan.Apps),
+		AlgoName:   plan.AlgoName,
+		AlgoParams: plan.AlgoParams,
+		Phase:      JobPhaseCreatingVms,
+		Solution:   &solnCopy,
+	}
+	return runScheduleJob(job, func(ctx context.Context, job *ScheduleJob) ([]models.AppInfo, error, int) {
+		return deploySolution(ctx, job, plan.Apps, plan.Solution)
+	}), nil, http.StatusAccepted
+}
+
+// Register the job and run it in the background. The caller must have locked algorithms.ScheMu, and the lock is released when the job ends.
+func runScheduleJob(job *ScheduleJob, run func(ctx context.Context, job *ScheduleJob) ([]models.AppInfo, error, int)) ScheduleJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	job.ID = genScheJobId()
+	job.StatusCode = http.StatusAccepted
+	job.CreateTime = now
+	job.UpdateTime = now
+	job.cancel = cancel
+
+	scheJobsMu.Lock()
+	scheJobs[job.ID] = job
+	if err := saveScheduleJob(scheJobDir, *job); err != nil {
+		beego.Error(fmt.Sprintf("Save the record of scheduling job [%s], error: %s", job.ID, err.Error()))
+	}
+	snapshot := *job
+	scheJobsMu.Unlock()
+
+	go func() {
+		defer algorithms.ScheMu.Unlock()
+		defer cancel()
+
+		beego.Info(fmt.Sprintf("Scheduling job [%s] starts.", job.ID))
+		createdAppsInfo, err, statusCode := run(ctx, job)
+		var endPhase JobPhase
+		job.update(func(j *ScheduleJob) {
+			j.StatusCode = statusCode
+			switch {
+			case err == nil:
+				j.Phase = JobPhaseSucceeded
+				j.Apps = createdAppsInfo
+			case ctx.Err() != nil:
+				j.Phase = JobPhaseCancelled
+				j.Error = err.Error()
+			default:
+				j.Phase = JobPhaseFailed
+				j.Error = err.Error()
+			}
+			endPhase = j.Phase
+		})
+		beego.Info(fmt.Sprintf("Scheduling job [%s] ends in phase [%s].", job.ID, endPhase))
+	}()
+
+	return snapshot
+}
+
+// get a copy of a scheduling job
+func GetScheduleJob(id string) (ScheduleJob, bool) {
+	scheJobsMu.RLock()
+	defer scheJobsMu.RUnlock()
+	job, exist := scheJobs[id]
+	if !exist {
+		return ScheduleJob{}, false
+	}
+	return *job, true
+}
+
+// list copies of the scheduling jobs in a namespace, the newest first. metav1.NamespaceAll means all namespaces.
+func ListScheduleJobs(namespace string) []ScheduleJob {
+	scheJobsMu.RLock()
+	var jobs []ScheduleJob
+	for _, job := range scheJobs {
+		if namespace != metav1.NamespaceAll && models.AppNamespace(job.Namespace) != namespace {
+			continue
+		}
+		jobs = append(jobs, *job)
+	}
+	scheJobsMu.RUnlock()
+
+	sort.Slice(jobs, func(i, k int) bool {
+		return jobs[i].CreateTime.After(jobs[k].CreateTime)
+	})
+	return jobs
+}
+
+// Cancel a running scheduling job.
+// The phase "Scheduling" is stopped at once. The other phases change the clouds and the Kubernetes cluster, so they are not interrupted, and the job stops before the next phase. The VMs that have been created will be deleted by the periodical cleanup of auto-scheduling VMs, because no applications are deployed on them.
+func CancelScheduleJob(id string) (ScheduleJob, error, int) {
+	scheJobsMu.RLock()
+	job, exist := scheJobs[id]
+	if !exist {
+		scheJobsMu.RUnlock()
+		outErr := fmt.Errorf("Scheduling job [%s] not found", id)
+		beego.Error(outErr)
+		return ScheduleJob{}, outErr, http.StatusNotFound
+	}
+	snapshot := *job
+	scheJobsMu.RUnlock()
+
+	if snapshot.Finished() || job.cancel == nil {
+		outErr := fmt.Errorf("Scheduling job [%s] is already finished in phase [%s]", id, snapshot.Phase)
+		beego.Error(outErr)
+		return snapshot, outErr, http.StatusConflict
+	}
+
+	beego.Info(fmt.Sprintf("Cancel scheduling job [%s] in phase [%s].", id, snapshot.Phase))
+	job.cancel()
+	return snapshot, nil, http.StatusAccepted
+}
+
+// check whether a job is cancelled between 2 phases
+func checkCancelled(ctx context.Context, nextPhase JobPhase) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("the job is cancelled before phase [%s]: %w", nextPhase, err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package executors
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInnerLoadScheduleJobs(t *testing.T) {
+	testCases := []struct {
+		name          string
+		savedJob      ScheduleJob
+		expectedPhase JobPhase
+		expectedCode  int
+	}{
+		{
+			name: "finished job is kept",
+			savedJob: ScheduleJob{
+				ID:         "sj-1-aaaaaaaa",
+				Phase:      JobPhaseSucceeded,
+				StatusCode: http.StatusCreated,
+			},
+			expectedPhase: JobPhaseSucceeded,
+			expectedCode:  http.StatusCreated,
+		},
+		{
+			name: "running job is marked as failed",
+			savedJob: ScheduleJob{
+				ID:         "sj-2-bbbbbbbb",
+				Phase:      JobPhaseDeployingApps,
+				StatusCode: http.StatusAccepted,
+			},
+			expectedPhase: JobPhaseFailed,
+			expectedCode:  http.StatusInternalServerError,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Logf("test: %s", testCase.name)
+		dir := t.TempDir()
+		scheJobs = make(map[string]*ScheduleJob)
+
+		testCase.savedJob.CreateTime = time.Now()
+		if err := saveScheduleJob(dir, testCase.savedJob); err != nil {
+			t.Fatalf("saveScheduleJob error: %s", err.Error())
+		}
+		if err := loadScheduleJobs(dir); err != nil {
+			t.Fatalf("loadScheduleJobs error: %s", err.Error())
+		}
+
+		job, exist := GetScheduleJob(testCase.savedJob.ID)
+		assert.True(t, exist)
+		assert.Equal(t, testCase.expectedPhase, job.Phase)
+		assert.Equal(t, testCase.expectedCode, job.StatusCode)
+		assert.True(t, job.Finished())
+
+		// A job loaded from the disk is not running, so it cannot be cancelled.
+		_, err, statusCode := CancelScheduleJob(testCase.savedJob.ID)
+		assert.NotNil(t, err)
+		assert.Equal(t, http.StatusConflict, statusCode)
+	}
+
+	_, err, statusCode := CancelScheduleJob("sj-not-exist")
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusNotFound, statusCode)
+}
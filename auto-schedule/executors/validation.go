@@ -2,9 +2,12 @@ package executors
 
 import (
 	"fmt"
+	"net/http"
 	"regexp"
 	"strings"
 
+	"github.com/astaxie/beego"
+
 	asmodel "emcontroller/auto-schedule/model"
 	"emcontroller/models"
 )
@@ -20,9 +23,36 @@ func ValidateAutoScheduleApps(apps []models.K8sApp) []error {
 	// validate the dependencies among these applications
 	allErrs = append(allErrs, ValidateAutoScheduleDep(apps)...)
 
+	// the applications depend on each other by their names, so they should be in the same namespace.
+	for _, app := range apps {
+		if namespace := models.AppNamespace(app.Namespace); namespace != AppsNamespace(apps) {
+			allErrs = append(allErrs, fmt.Errorf("Auto-schedule application [%s] is in namespace [%s], but the applications in a group should be in the same namespace [%s].", app.Name, namespace, AppsNamespace(apps)))
+		}
+	}
+
 	return allErrs
 }
 
+// The PVCs of the applications are provided by the storage classes but not the clouds, so the algorithms cannot choose them, and we reject the applications whose storage classes do not exist.
+func checkAppsStorageClasses(apps []models.K8sApp) (error, int) {
+	for _, app := range apps {
+		if err, statusCode := models.CheckAppStorageClasses(models.AppNamespace(app.Namespace), app); err != nil {
+			outErr := fmt.Errorf("Check the storage classes of auto-schedule application [%s], Error: [%w]", app.Name, err)
+			beego.Error(outErr)
+			return outErr, statusCode
+		}
+	}
+	return nil, http.StatusOK
+}
+
+// the namespace of a group of applications, which is the namespace of the first one.
+func AppsNamespace(apps []models.K8sApp) string {
+	if len(apps) == 0 {
+		return models.KubernetesNamespace
+	}
+	return models.AppNamespace(apps[0].Namespace)
+}
+
 // If an application needs to be auto-scheduled, it should meet some requirements.
 func ValidateAutoScheduleApp(app models.K8sApp) []error {
 	var allErrs []error
@@ -39,21 +69,29 @@ func ValidateAutoScheduleApp(app models.K8sApp) []error {
 		allErrs = append(allErrs, fmt.Errorf("Auto-schedule application [%s], AutoScheduled should be [%t], but it is [%t].", app.Name, true, app.AutoScheduled))
 	}
 
-	var allowedReplicas int32 = 1
-	if app.Replicas != allowedReplicas {
-		allErrs = append(allErrs, fmt.Errorf("Auto-schedule application [%s], Replicas should be [%d], but it is [%d].", app.Name, allowedReplicas, app.Replicas))
+	var minReplicas, maxReplicas int32 = 1, int32(asmodel.MaxReplicas)
+	if app.Replicas < minReplicas || app.Replicas > maxReplicas {
+		allErrs = append(allErrs, fmt.Errorf("Auto-schedule application [%s], Replicas should be in [%d, %d], but it is [%d].", app.Name, minReplicas, maxReplicas, app.Replicas))
+	}
+
+	if app.ReplicaSpread != "" && app.ReplicaSpread != asmodel.ReplicaSpreadNode && app.ReplicaSpread != asmodel.ReplicaSpreadCloud {
+		allErrs = append(allErrs, fmt.Errorf("Auto-schedule application [%s], ReplicaSpread should be empty, [%s], or [%s], but it is [%s].", app.Name, asmodel.ReplicaSpreadNode, asmodel.ReplicaSpreadCloud, app.ReplicaSpread))
 	}
 
 	if len(app.NodeName) != 0 {
 		allErrs = append(allErrs, fmt.Errorf("Auto-schedule application [%s] should not be set NodeName, but it is set as [%s].", app.Name, app.NodeName))
 	}
 
+	if len(app.ReplicaNodeNames) != 0 {
+		allErrs = append(allErrs, fmt.Errorf("Auto-schedule application [%s] should not be set ReplicaNodeNames, but it is set as %v.", app.Name, app.ReplicaNodeNames))
+	}
+
 	if len(app.NodeSelector) != 0 {
 		allErrs = append(allErrs, fmt.Errorf("Auto-schedule application [%s] should not have NodeSelector, but it has [%s].", app.Name, app.NodeSelector))
 	}
 
-	if len(app.Containers) != 1 {
-		allErrs = append(allErrs, fmt.Errorf("Auto-schedule application [%s] should only have 1 container, but it has [%d].", app.Name, len(app.Containers)))
+	if len(app.Containers) < 1 {
+		allErrs = append(allErrs, fmt.Errorf("Auto-schedule application [%s] should have at least 1 container, but it has [%d].", app.Name, len(app.Containers)))
 	}
 
 	for _, container := range app.Containers {
@@ -26,6 +26,13 @@ func ValidateAutoScheduleDep(apps []models.K8sApp) []error {
 	// The dependent application should exist in this group of applications.
 	for _, app := range appMap {
 		for _, dependency := range app.Dependencies {
+			// The network requirements of a dependency cannot be negative.
+			if dependency.MinBandwidthMbps < 0 {
+				allErrs = append(allErrs, fmt.Errorf("Auto-schedule application [%s] depends on application [%s] with minBandwidthMbps [%g], but minBandwidthMbps cannot be negative.", app.Name, dependency.AppName, dependency.MinBandwidthMbps))
+			}
+			if dependency.MaxRttMs < 0 {
+				allErrs = append(allErrs, fmt.Errorf("Auto-schedule application [%s] depends on application [%s] with maxRttMs [%g], but maxRttMs cannot be negative.", app.Name, dependency.AppName, dependency.MaxRttMs))
+			}
 			if dependentApp, exist := appMap[dependency.AppName]; exist {
 				if dependentApp.Priority < app.Priority {
 					allErrs = append(allErrs, fmt.Errorf("Auto-schedule application [%s] with priority [%d] depends on application [%s] with priority [%d], but the priority of a dependent application should be greater than or equal to that of the one that depends on it.", app.Name, app.Priority, dependentApp.Name, dependentApp.Priority))
@@ -284,6 +284,39 @@ func TestValidateAutoScheduleDep(t *testing.T) {
 			},
 			expectedErrNum: 1,
 		},
+		{
+			name: "NetRequirementErr",
+			apps: []models.K8sApp{
+				models.K8sApp{
+					Name:     "app1",
+					Priority: 2,
+					Dependencies: []models.Dependency{
+						{
+							AppName:          "app2",
+							MinBandwidthMbps: -1,
+							MaxRttMs:         -1,
+						},
+					},
+				},
+				models.K8sApp{
+					Name:     "app2",
+					Priority: 10,
+					Dependencies: []models.Dependency{
+						{
+							AppName:          "app3",
+							MinBandwidthMbps: 100,
+							MaxRttMs:         50,
+						},
+					},
+				},
+				models.K8sApp{
+					Name:         "app3",
+					Priority:     10,
+					Dependencies: []models.Dependency{},
+				},
+			},
+			expectedErrNum: 2,
+		},
 	}
 	for i, testCase := range testCases {
 		t.Logf("test: %d, %s", i, testCase.name)
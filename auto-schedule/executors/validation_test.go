@@ -128,6 +128,59 @@ func TestValidateAutoScheduleApp(t *testing.T) {
 				Replicas:      2,
 				AutoScheduled: true,
 			},
+			expectedErrNum: 1,
+		},
+		{
+			name: "Replicas11",
+			app: models.K8sApp{
+				Name:          "Replicas11",
+				Priority:      9,
+				Replicas:      11,
+				AutoScheduled: true,
+			},
+			expectedErrNum: 2,
+		},
+		{
+			name: "Replicas0",
+			app: models.K8sApp{
+				Name:          "Replicas0",
+				Priority:      9,
+				Replicas:      0,
+				AutoScheduled: true,
+			},
+			expectedErrNum: 2,
+		},
+		{
+			name: "Replicas3SpreadCloud",
+			app: models.K8sApp{
+				Name:          "Replicas3SpreadCloud",
+				Priority:      9,
+				Replicas:      3,
+				ReplicaSpread: "cloud",
+				AutoScheduled: true,
+			},
+			expectedErrNum: 1,
+		},
+		{
+			name: "Replicas3SpreadInvalid",
+			app: models.K8sApp{
+				Name:          "Replicas3SpreadInvalid",
+				Priority:      9,
+				Replicas:      3,
+				ReplicaSpread: "region",
+				AutoScheduled: true,
+			},
+			expectedErrNum: 2,
+		},
+		{
+			name: "Replicas2WithReplicaNodeNames",
+			app: models.K8sApp{
+				Name:             "Replicas2WithReplicaNodeNames",
+				Priority:         9,
+				Replicas:         2,
+				ReplicaNodeNames: []string{"node1", "node2"},
+				AutoScheduled:    true,
+			},
 			expectedErrNum: 2,
 		},
 		{
@@ -631,7 +684,7 @@ func TestValidateAutoScheduleApp(t *testing.T) {
 					},
 				},
 			},
-			expectedErrNum: 1,
+			expectedErrNum: 0,
 		},
 	}
 	testCases = append(testCases, testCasesMultiContainer...)
@@ -674,3 +727,32 @@ func TestValidateAutoScheduleApp(t *testing.T) {
 		assert.Equal(t, testCase.expectedErrNum, len(errs), fmt.Sprintf("%s: result is not expected", testCase.name))
 	}
 }
+
+func TestAppsNamespace(t *testing.T) {
+	testCases := []struct {
+		name              string
+		apps              []models.K8sApp
+		expectedNamespace string
+	}{
+		{
+			name:              "no apps",
+			apps:              nil,
+			expectedNamespace: models.KubernetesNamespace,
+		},
+		{
+			name:              "no namespace",
+			apps:              []models.K8sApp{{Name: "app1"}, {Name: "app2"}},
+			expectedNamespace: models.KubernetesNamespace,
+		},
+		{
+			name:              "tenant",
+			apps:              []models.K8sApp{{Name: "app1", Namespace: "team-a"}, {Name: "app2", Namespace: "team-a"}},
+			expectedNamespace: "team-a",
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		assert.Equal(t, testCase.expectedNamespace, AppsNamespace(testCase.apps), fmt.Sprintf("%s: result is not expected", testCase.name))
+	}
+}
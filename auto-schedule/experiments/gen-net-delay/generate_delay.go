@@ -60,8 +60,8 @@ func ClearAllDelay() error {
 	// delay every cloud in parallel
 	var wg sync.WaitGroup
 
-	for cloudName, _ := range models.Clouds {
-		if _, ok := models.Clouds[cloudName].(*models.Proxmox); !ok {
+	for cloudName, cloud := range models.ListIaas() {
+		if _, ok := cloud.(*models.Proxmox); !ok {
 			beego.Info(fmt.Sprintf("ClearAllDelay Skip cloud %s, because its type is not %s.", cloudName, models.ProxmoxIaas))
 			continue
 		}
@@ -93,7 +93,7 @@ func ClearAllDelay() error {
 }
 
 func delayOneCloud(cloudName, delay string) error {
-	cloud, exist := models.Clouds[cloudName]
+	cloud, exist := models.GetIaas(cloudName)
 	if !exist {
 		return fmt.Errorf("delayOneCloud, cloud name [%s] not found", cloudName)
 	}
@@ -117,7 +117,7 @@ func delayOneCloud(cloudName, delay string) error {
 }
 
 func clearDelayOneCloud(cloudName string) error {
-	cloud, exist := models.Clouds[cloudName]
+	cloud, exist := models.GetIaas(cloudName)
 	if !exist {
 		return fmt.Errorf("delayOneCloud, cloud name [%s] not found", cloudName)
 	}
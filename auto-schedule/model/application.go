@@ -1,11 +1,14 @@
 package model
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/astaxie/beego"
+	apiv1 "k8s.io/api/core/v1"
 
 	"emcontroller/models"
 )
@@ -21,6 +24,17 @@ type Application struct {
 	Priority     int                 `json:"priority"`
 	Resources    AppResources        `json:"resources"`    // The resources information of this application
 	Dependencies []models.Dependency `json:"dependencies"` // The information of all applications that this application depends on.
+	// The requested CPU cores of every container, in the order of the containers. The CPU cores allocated to this application are split to the containers in proportion to them.
+	ContainerCpus []float64 `json:"containerCpus,omitempty"`
+
+	Replicas      int    `json:"replicas,omitempty"`      // The number of replicas. 0 and 1 both mean a single replica.
+	ReplicaSpread string `json:"replicaSpread,omitempty"` // The anti-affinity rule of the replicas, "node" or "cloud".
+	// Only set in the replicas generated by ExpandReplicas. It is the name of the application that this replica belongs to.
+	ReplicaOf string `json:"replicaOf,omitempty"`
+
+	// Only set for the running applications that are not scheduled but depended on by the scheduled ones, e.g., the applications not migrated in a migration.
+	// Such an application stays at these placements, one for each replica, and the algorithms do not allocate VMs or CPUs to it, because its resources are already in use.
+	FixedPlacements []ReplicaSolution `json:"-"`
 }
 
 func AppCopy(src Application) Application {
@@ -31,9 +45,68 @@ func AppCopy(src Application) Application {
 		dst.Dependencies = make([]models.Dependency, len(src.Dependencies))
 		copy(dst.Dependencies, src.Dependencies)
 	}
+	if src.ContainerCpus != nil {
+		dst.ContainerCpus = make([]float64, len(src.ContainerCpus))
+		copy(dst.ContainerCpus, src.ContainerCpus)
+	}
+	if src.FixedPlacements != nil {
+		dst.FixedPlacements = make([]ReplicaSolution, len(src.FixedPlacements))
+		copy(dst.FixedPlacements, src.FixedPlacements)
+	}
 	return dst
 }
 
+// Whether the application stays at its FixedPlacements and is not scheduled.
+func (app Application) Fixed() bool {
+	return len(app.FixedPlacements) != 0
+}
+
+// Generate an application with fixed placements from its running pods, one replica on every Kubernetes node with its pods.
+// The pods on the nodes that are not in the clouds are ignored. If no pod is in the clouds, the returned bool is false.
+func GenFixedApp(clouds map[string]Cloud, appName string, pods []apiv1.Pod) (Application, bool) {
+	nodeClouds := make(map[string]string)
+	for cloudName, cloud := range clouds {
+		for _, node := range cloud.K8sNodes {
+			nodeClouds[node.Name] = cloudName
+		}
+	}
+
+	var placements []ReplicaSolution
+	usedNodes := make(map[string]struct{})
+	for _, pod := range pods {
+		cloudName, exist := nodeClouds[pod.Spec.NodeName]
+		if !exist {
+			continue
+		}
+		if _, used := usedNodes[pod.Spec.NodeName]; used {
+			continue
+		}
+		usedNodes[pod.Spec.NodeName] = struct{}{}
+		// the CPU only affects the computation part of the fitness of this application, which is the same in all solutions, but it cannot be 0.
+		cpu := GetResOccupiedByPod(pod).CpuCore
+		if cpu <= 0 {
+			cpu = 1
+		}
+		placements = append(placements, ReplicaSolution{TargetCloudName: cloudName, K8sNodeName: pod.Spec.NodeName, AllocatedCpuCore: cpu})
+	}
+	if len(placements) == 0 {
+		return Application{}, false
+	}
+	sort.Slice(placements, func(i, j int) bool {
+		return placements[i].K8sNodeName < placements[j].K8sNodeName
+	})
+
+	app := Application{
+		Name:            appName,
+		Priority:        MinPriority,
+		FixedPlacements: placements,
+	}
+	if len(placements) > 1 {
+		app.Replicas = len(placements)
+	}
+	return app, true
+}
+
 func AppMapCopy(src map[string]Application) map[string]Application {
 	var dst map[string]Application = make(map[string]Application)
 	for name, app := range src {
@@ -49,6 +122,7 @@ func GenerateApplications(inputApps []models.K8sApp) (map[string]Application, er
 
 		// traverse containers to calculate the resources requested by this applications
 		var resources AppResources
+		var containerCpus []float64
 		for _, container := range inApp.Containers {
 			floatCpu, err := strconv.ParseFloat(container.Resources.Requests.CPU, 64)
 			if err != nil {
@@ -78,18 +152,64 @@ func GenerateApplications(inputApps []models.K8sApp) (map[string]Application, er
 			}
 
 			resources.CpuCore += floatCpu
+			containerCpus = append(containerCpus, floatCpu)
 			resources.Memory += floatRamMi
 			resources.Storage += floatStorGi
 		}
 
+		// the PVCs are shared by the containers and replicas of this application
+		persistentStorage, err := models.AppPvcStorageGi(inApp)
+		if err != nil {
+			outErr := fmt.Errorf("Application [%s] calculate the persistent storage, Error: [%w]", inApp.Name, err)
+			beego.Error(outErr)
+			return nil, outErr
+		}
+		resources.PersistentStorage = persistentStorage
+
 		// put the needed information in the output structure
 		var thisOutApp Application
 		thisOutApp.Name = inApp.Name
 		thisOutApp.Priority = inApp.Priority
 		thisOutApp.Resources = resources
 		thisOutApp.Dependencies = inApp.Dependencies
+		thisOutApp.ContainerCpus = containerCpus
+		thisOutApp.Replicas = int(inApp.Replicas)
+		thisOutApp.ReplicaSpread = inApp.ReplicaSpread
 		outApps[thisOutApp.Name] = thisOutApp
 	}
 
 	return outApps, nil
 }
+
+// generate the value of the annotation "AutoScheduleInfoAnno" of an application
+func GenAutoScheduleInfo(app Application) (string, error) {
+	infoJson, err := json.Marshal(app)
+	if err != nil {
+		outErr := fmt.Errorf("json.Marshal the auto-scheduling information of application [%s], Error: [%w]", app.Name, err)
+		beego.Error(outErr)
+		return "", outErr
+	}
+	return string(infoJson), nil
+}
+
+// parse the value of the annotation "AutoScheduleInfoAnno" of an application
+func ParseAutoScheduleInfo(info string) (Application, error) {
+	var app Application
+	if err := json.Unmarshal([]byte(info), &app); err != nil {
+		outErr := fmt.Errorf("json.Unmarshal the auto-scheduling information [%s], Error: [%w]", info, err)
+		beego.Error(outErr)
+		return Application{}, outErr
+	}
+	return app, nil
+}
+
+// The number of containers that request CPU cores. Every one of them needs at least one CPU core.
+func (app Application) CpuContainerNum() int {
+	var num int
+	for _, cpu := range app.ContainerCpus {
+		if cpu > 0 {
+			num++
+		}
+	}
+	return num
+}
@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 
 	"emcontroller/models"
 )
@@ -205,3 +207,110 @@ func TestAppMapCopy(t *testing.T) {
 	}
 
 }
+
+func TestGenerateApplications(t *testing.T) {
+	testCases := []struct {
+		name              string
+		mounts            []models.K8sMount
+		expectedResources AppResources
+		expectError       bool
+	}{
+		{
+			name:   "hostPath only",
+			mounts: []models.K8sMount{{VmPath: "/tmp/app1", ContainerPath: "/data"}},
+			expectedResources: AppResources{
+				GenericResources: GenericResources{CpuCore: 2, Memory: 1024, Storage: 5},
+			},
+		},
+		{
+			name: "PVCs",
+			mounts: []models.K8sMount{
+				{Pvc: &models.K8sPvc{Name: "data", Size: "10Gi"}, ContainerPath: "/data"},
+				{Pvc: &models.K8sPvc{Name: "data", Size: "10Gi"}, ContainerPath: "/logs"},
+				{Pvc: &models.K8sPvc{Name: "backup", Size: "20Gi"}, ContainerPath: "/backup"},
+				{ConfigMap: "conf", ContainerPath: "/etc/app1"},
+			},
+			expectedResources: AppResources{
+				GenericResources:  GenericResources{CpuCore: 2, Memory: 1024, Storage: 5},
+				PersistentStorage: 30,
+			},
+		},
+		{
+			name:        "invalid PVC size",
+			mounts:      []models.K8sMount{{Pvc: &models.K8sPvc{Name: "data", Size: "ten"}, ContainerPath: "/data"}},
+			expectError: true,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		inApp := models.K8sApp{
+			Name:     "app1",
+			Priority: 5,
+			Containers: []models.K8sContainer{
+				{
+					Name:      "c1",
+					Resources: models.K8sResReq{Requests: models.K8sResList{CPU: "2", Memory: "1024Mi", Storage: "5Gi"}},
+					Mounts:    testCase.mounts,
+				},
+			},
+		}
+		apps, err := GenerateApplications([]models.K8sApp{inApp})
+		if testCase.expectError {
+			assert.NotNil(t, err, testCase.name)
+			continue
+		}
+		assert.Nil(t, err, testCase.name)
+		assert.Equal(t, testCase.expectedResources, apps["app1"].Resources, fmt.Sprintf("%s: resources are not expected", testCase.name))
+	}
+}
+
+func TestGenFixedApp(t *testing.T) {
+	clouds := map[string]Cloud{
+		"NOKIA4": {Name: "NOKIA4", K8sNodes: []K8sNode{{Name: "node1"}, {Name: "node2"}}},
+		"NOKIA7": {Name: "NOKIA7", K8sNodes: []K8sNode{{Name: "node3"}}},
+	}
+	genPod := func(nodeName string, cpu string) apiv1.Pod {
+		container := apiv1.Container{Name: "c1"}
+		if len(cpu) > 0 {
+			container.Resources.Requests = apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse(cpu)}
+		}
+		return apiv1.Pod{Spec: apiv1.PodSpec{NodeName: nodeName, Containers: []apiv1.Container{container}}}
+	}
+
+	testCases := []struct {
+		name        string
+		pods        []apiv1.Pod
+		expectedOk  bool
+		expectedApp Application
+	}{
+		{
+			name:        "one pod",
+			pods:        []apiv1.Pod{genPod("node3", "2")},
+			expectedOk:  true,
+			expectedApp: Application{Name: "dep", Priority: MinPriority, FixedPlacements: []ReplicaSolution{{TargetCloudName: "NOKIA7", K8sNodeName: "node3", AllocatedCpuCore: 2}}},
+		},
+		{
+			name:       "replicas on different nodes, one node not in the clouds",
+			pods:       []apiv1.Pod{genPod("node3", "500m"), genPod("node1", ""), genPod("node3", "500m"), genPod("other", "1")},
+			expectedOk: true,
+			expectedApp: Application{Name: "dep", Priority: MinPriority, Replicas: 2, FixedPlacements: []ReplicaSolution{
+				{TargetCloudName: "NOKIA4", K8sNodeName: "node1", AllocatedCpuCore: 1},
+				{TargetCloudName: "NOKIA7", K8sNodeName: "node3", AllocatedCpuCore: 0.5},
+			}},
+		},
+		{
+			name: "pending pod",
+			pods: []apiv1.Pod{genPod("", "1")},
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		app, ok := GenFixedApp(clouds, "dep", testCase.pods)
+		assert.Equal(t, testCase.expectedOk, ok, testCase.name)
+		if ok {
+			assert.Equal(t, testCase.expectedApp, app, fmt.Sprintf("%s: application is not expected", testCase.name))
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/astaxie/beego"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -20,22 +21,56 @@ type Cloud struct {
 	NetState     map[string]models.NetworkState `json:"netState"`  // the network state from this cloud to every cloud
 	K8sNodes     []K8sNode                      `json:"k8sNodes"`  // all existing Kubernetes nodes whose VMs are on this cloud
 	TemperatureC float64                        `json:"temp_c"`
+	Flavors      []flavors.Flavor               `json:"flavors,omitempty"`   // On the clouds with flavors, such as Openstack, new VMs can only be created with the vCPU and RAM of these flavors.
+	ImagePath    string                         `json:"imagePath,omitempty"` // On Local clouds, new VMs are created from this image. Without it, a new VM has an empty disk and cannot join Kubernetes.
 }
 
 // the set of all cloud types that support creating new VMs when auto-scheduling
 var typesCanCreateNewVM map[string]struct{} = map[string]struct{}{
 	models.ProxmoxIaas: struct{}{},
+	models.FakeIaas:    struct{}{},
 }
 
 // Not all cloud types support creating new VMs.
-// For example, CLAAUDIA does not allow users to create flavors in Openstack, so if we want to create new VMs in auto-scheduling, this will be more complicated, so we do not support creating new VMs in auto-scheduling.
+// For example, CLAAUDIA does not allow users to create flavors in Openstack, so on Openstack clouds, new VMs can only be created with the existing flavors, and we do not support creating new VMs if we do not know any flavors.
+// On Local clouds, a VM created without an image has an empty disk, so we only create new VMs when the image is configured.
 func (c Cloud) SupportCreateNewVM() bool {
 	if _, exist := typesCanCreateNewVM[c.Type]; exist {
 		return true
 	}
+	if c.Type == models.OpenstackIaas && len(c.Flavors) > 0 {
+		return true
+	}
+	if c.Type == models.LocalIaas && len(c.ImagePath) > 0 {
+		return true
+	}
+	return false
+}
+
+// check whether a VM with this vCPU and RAM can be created on this cloud. On the clouds without flavors, any size is fine.
+func (c Cloud) VmShapeAvailable(vm models.IaasVm) bool {
+	if len(c.Flavors) == 0 {
+		return true
+	}
+	for _, flavor := range c.Flavors {
+		if float64(flavor.VCPUs) == vm.VCpu && float64(flavor.RAM) == vm.Ram {
+			return true
+		}
+	}
 	return false
 }
 
+// On the clouds with flavors, the vCPU and RAM of the VM to create should be those of the smallest flavor that can meet the requirements within the rest resources, like what Openstack.CreateVM does.
+// If no flavor can meet the requirements, found is false, and the requirements are returned.
+func (c Cloud) FitFlavor(reqCpu, reqRam float64) (float64, float64, bool) {
+	rest := c.GetAllRestRes()
+	flavor, found := models.MinFlavorWithin(c.Flavors, int(math.Ceil(reqCpu)), int(math.Ceil(reqRam)), int(rest.CpuCore), int(rest.Memory))
+	if !found {
+		return reqCpu, reqRam, false
+	}
+	return float64(flavor.VCPUs), float64(flavor.RAM), true
+}
+
 // According to the input resource percentage, this function can generate the information of the shared VM to create.
 func (c Cloud) GetSharedVmToCreate(resPct float64, allRest bool) models.IaasVm {
 	var totalResources GenericResources
@@ -44,6 +79,9 @@ func (c Cloud) GetSharedVmToCreate(resPct float64, allRest bool) models.IaasVm {
 	} else { // when allRest is true, resPct will not be used
 		totalResources = c.GetAllRestRes()
 	}
+	if len(c.Flavors) > 0 {
+		totalResources.CpuCore, totalResources.Memory = c.flavorForSharedVm(totalResources, allRest)
+	}
 	return models.IaasVm{
 		Name:    c.GetNameVmToCreate(),
 		Cloud:   c.Name,
@@ -53,6 +91,23 @@ func (c Cloud) GetSharedVmToCreate(resPct float64, allRest bool) models.IaasVm {
 	}
 }
 
+// On the clouds with flavors, we cannot create a shared VM with any vCPU and RAM.
+// With a resource percentage, we choose the smallest flavor that can meet it. With all rest resources, or if no flavor can meet the percentage, we choose the biggest flavor within the rest resources.
+// If no flavor is within the rest resources, the returned vCPU and RAM are 0, so the VM cannot hold any applications.
+func (c Cloud) flavorForSharedVm(res GenericResources, allRest bool) (float64, float64) {
+	if !allRest {
+		if vcpu, ram, found := c.FitFlavor(res.CpuCore, res.Memory); found {
+			return vcpu, ram
+		}
+	}
+	rest := c.GetAllRestRes()
+	flavor, found := models.MaxFlavorWithin(c.Flavors, int(rest.CpuCore), int(rest.Memory))
+	if !found {
+		return 0, 0
+	}
+	return float64(flavor.VCPUs), float64(flavor.RAM)
+}
+
 // auto-schedule vms should have special prefixes
 func (c Cloud) GetNameVmToCreate() string {
 	var vmName string
@@ -121,9 +176,10 @@ func CloudMapCopy(src map[string]Cloud) map[string]Cloud {
 func GenerateClouds(inputClouds map[string]models.Iaas) (map[string]Cloud, error) {
 	var outputClouds map[string]Cloud = make(map[string]Cloud)
 
-	netStates, err := models.GetNetState()
+	// the network state can be smoothed over the history, configured by "NetSmoothMethod"
+	netStates, err := models.GetSmoothedNetState()
 	if err != nil {
-		outErr := fmt.Errorf("Check network state from MySQL Error: %w", err)
+		outErr := fmt.Errorf("Check smoothed network state from MySQL Error: %w", err)
 		beego.Error(outErr)
 		return nil, outErr
 	}
@@ -215,6 +271,20 @@ func GenerateOneCloud(inCloud models.Iaas, cloudNetStates map[string]models.Netw
 		TemperatureC: temperature,
 	}
 
+	// On Openstack, new VMs can only be created with the existing flavors.
+	if osCloud, ok := inCloud.(*models.Openstack); ok {
+		allFlavors, err := osCloud.ListAllFavors()
+		if err != nil {
+			beego.Warn(fmt.Sprintf("List flavors of cloud [%s], error: %v. Auto-scheduling will not create new VMs on it.", inCloud.ShowName(), err))
+		} else {
+			outCloud.Flavors = allFlavors
+		}
+	}
+	// On Local clouds, new VMs can only be created from an image.
+	if localCloud, ok := inCloud.(*models.Local); ok {
+		outCloud.ImagePath = localCloud.ImagePath
+	}
+
 	return outCloud, nil
 }
 
@@ -259,7 +329,7 @@ func getK8sNodesOnCloud(cloud models.Iaas, allK8sNodes []apiv1.Node) ([]K8sNode,
 			// We use a VM for auto-scheduling only if both its name and IP are the same as those of its Kubernetes node.
 			if vm.IPs[0] == models.GetNodeInternalIp(node) && vm.Name == node.Name {
 				// get all pods on this VM.
-				podsOnNode, err := models.ListPodsOnNode(models.KubernetesNamespace, node.Name)
+				podsOnNode, err := models.ListPodsOnNode(metav1.NamespaceAll, node.Name)
 				if err != nil {
 					outErr := fmt.Errorf("List pods on Kubernetes node [%s], error: %w", node.Name, err)
 					beego.Error(outErr)
@@ -276,3 +346,21 @@ func getK8sNodesOnCloud(cloud models.Iaas, allK8sNodes []apiv1.Node) ([]K8sNode,
 
 	return k8sNodes, nil
 }
+
+// When migrating applications, we simulate to remove them from the clouds, so the resources occupied by their pods are added back to the Kubernetes nodes.
+func FreePodsResources(clouds map[string]Cloud, pods []apiv1.Pod) {
+	for _, pod := range pods {
+		occupied := GetResOccupiedByPod(pod)
+		for cloudName := range clouds {
+			for i := range clouds[cloudName].K8sNodes {
+				node := &clouds[cloudName].K8sNodes[i]
+				if node.Name != pod.Spec.NodeName {
+					continue
+				}
+				node.ResidualResources.CpuCore += occupied.CpuCore
+				node.ResidualResources.Memory += occupied.Memory
+				node.ResidualResources.Storage += occupied.Storage
+			}
+		}
+	}
+}
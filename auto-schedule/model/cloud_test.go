@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
 	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 
 	"emcontroller/models"
 )
@@ -320,6 +323,105 @@ func TestGetSharedVmToCreate(t *testing.T) {
 				Storage: 767,
 			},
 		},
+		{
+			name: "openstack flavors 20%",
+			cloud: Cloud{
+				Name: "CLAAUDIA",
+				Type: models.OpenstackIaas,
+				Resources: models.ResourceStatus{
+					Limit: models.ResSet{VCpu: 40, Ram: 81920, Storage: 1000},
+					InUse: models.ResSet{VCpu: 10, Ram: 20480, Storage: 200},
+				},
+				Flavors: []flavors.Flavor{
+					{Name: "m1.small", VCPUs: 2, RAM: 4096},
+					{Name: "m1.large", VCPUs: 8, RAM: 16384},
+					{Name: "m1.xlarge", VCPUs: 16, RAM: 32768},
+					{Name: "m1.huge", VCPUs: 32, RAM: 65536},
+				},
+			},
+			resPct:  0.2,
+			allRest: false,
+			expectedResult: models.IaasVm{
+				Name:    "auto-sched-claaudia-0",
+				Cloud:   "CLAAUDIA",
+				VCpu:    8,
+				Ram:     16384,
+				Storage: 200,
+			},
+		},
+		{
+			name: "openstack flavors 50%, no flavor can meet it",
+			cloud: Cloud{
+				Name: "CLAAUDIA",
+				Type: models.OpenstackIaas,
+				Resources: models.ResourceStatus{
+					Limit: models.ResSet{VCpu: 40, Ram: 81920, Storage: 1000},
+					InUse: models.ResSet{VCpu: 10, Ram: 20480, Storage: 200},
+				},
+				Flavors: []flavors.Flavor{
+					{Name: "m1.small", VCPUs: 2, RAM: 4096},
+					{Name: "m1.large", VCPUs: 8, RAM: 16384},
+					{Name: "m1.xlarge", VCPUs: 16, RAM: 32768},
+					{Name: "m1.huge", VCPUs: 32, RAM: 65536},
+				},
+			},
+			resPct:  0.5,
+			allRest: false,
+			expectedResult: models.IaasVm{
+				Name:    "auto-sched-claaudia-0",
+				Cloud:   "CLAAUDIA",
+				VCpu:    16,
+				Ram:     32768,
+				Storage: 500,
+			},
+		},
+		{
+			name: "openstack flavors all rest",
+			cloud: Cloud{
+				Name: "CLAAUDIA",
+				Type: models.OpenstackIaas,
+				Resources: models.ResourceStatus{
+					Limit: models.ResSet{VCpu: 40, Ram: 81920, Storage: 1000},
+					InUse: models.ResSet{VCpu: 10, Ram: 20480, Storage: 200},
+				},
+				Flavors: []flavors.Flavor{
+					{Name: "m1.small", VCPUs: 2, RAM: 4096},
+					{Name: "m1.large", VCPUs: 8, RAM: 16384},
+					{Name: "m1.xlarge", VCPUs: 16, RAM: 32768},
+					{Name: "m1.huge", VCPUs: 32, RAM: 65536},
+				},
+			},
+			resPct:  0,
+			allRest: true,
+			expectedResult: models.IaasVm{
+				Name:    "auto-sched-claaudia-0",
+				Cloud:   "CLAAUDIA",
+				VCpu:    16,
+				Ram:     32768,
+				Storage: 800,
+			},
+		},
+		{
+			name: "openstack flavors, no flavor within the rest resources",
+			cloud: Cloud{
+				Name: "CLAAUDIA",
+				Type: models.OpenstackIaas,
+				Resources: models.ResourceStatus{
+					Limit: models.ResSet{VCpu: 40, Ram: 81920, Storage: 1000},
+					InUse: models.ResSet{VCpu: 39, Ram: 20480, Storage: 200},
+				},
+				Flavors: []flavors.Flavor{{Name: "m1.small", VCPUs: 2, RAM: 4096}},
+			},
+			resPct:  0,
+			allRest: true,
+			expectedResult: models.IaasVm{
+				Name:    "auto-sched-claaudia-0",
+				Cloud:   "CLAAUDIA",
+				VCpu:    0,
+				Ram:     0,
+				Storage: 800,
+			},
+		},
 	}
 
 	for i, testCase := range testCases {
@@ -351,6 +453,32 @@ func TestSupportCreateNewVM(t *testing.T) {
 			},
 			expectedResult: false,
 		},
+		{
+			name: "case-openstack-flavors",
+			cloud: Cloud{
+				Name:    "CLAAUDIAweifan",
+				Type:    models.OpenstackIaas,
+				Flavors: []flavors.Flavor{{Name: "m1.small", VCPUs: 2, RAM: 4096}},
+			},
+			expectedResult: true,
+		},
+		{
+			name: "case-local",
+			cloud: Cloud{
+				Name: "LOCAL1",
+				Type: models.LocalIaas,
+			},
+			expectedResult: false,
+		},
+		{
+			name: "case-local-image",
+			cloud: Cloud{
+				Name:      "LOCAL1",
+				Type:      models.LocalIaas,
+				ImagePath: "/var/lib/libvirt/images/ubuntu-22.04.qcow2",
+			},
+			expectedResult: true,
+		},
 		{
 			name: "case-other",
 			cloud: Cloud{
@@ -559,3 +687,77 @@ func TestGetNameVmToCreate(t *testing.T) {
 		assert.Equal(t, testCase.expectedResult, actualResult, fmt.Sprintf("%s: result is not expected", testCase.name))
 	}
 }
+
+func TestFreePodsResources(t *testing.T) {
+	podOnNode := func(nodeName string, cpu, memory, storage string) apiv1.Pod {
+		return apiv1.Pod{
+			Spec: apiv1.PodSpec{
+				NodeName: nodeName,
+				Containers: []apiv1.Container{
+					{
+						Resources: apiv1.ResourceRequirements{
+							Requests: map[apiv1.ResourceName]resource.Quantity{
+								apiv1.ResourceCPU:              resource.MustParse(cpu),
+								apiv1.ResourceMemory:           resource.MustParse(memory),
+								apiv1.ResourceEphemeralStorage: resource.MustParse(storage),
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name           string
+		clouds         map[string]Cloud
+		pods           []apiv1.Pod
+		expectedResult map[string]Cloud
+	}{
+		{
+			name: "pods on 2 clouds and a pod on an unknown node",
+			clouds: map[string]Cloud{
+				"cloud1": Cloud{
+					Name: "cloud1",
+					K8sNodes: []K8sNode{
+						K8sNode{Name: "node1", ResidualResources: GenericResources{CpuCore: 0.5, Memory: 100, Storage: 1}},
+						K8sNode{Name: "node2", ResidualResources: GenericResources{CpuCore: 1, Memory: 200, Storage: 2}},
+					},
+				},
+				"cloud2": Cloud{
+					Name: "cloud2",
+					K8sNodes: []K8sNode{
+						K8sNode{Name: "node3", ResidualResources: GenericResources{CpuCore: 0, Memory: 0, Storage: 0}},
+					},
+				},
+			},
+			pods: []apiv1.Pod{
+				podOnNode("node1", "1", "512Mi", "10Gi"),
+				podOnNode("node1", "500m", "100Mi", "1Gi"),
+				podOnNode("node3", "2", "1Gi", "20Gi"),
+				podOnNode("node4", "2", "1Gi", "20Gi"),
+			},
+			expectedResult: map[string]Cloud{
+				"cloud1": Cloud{
+					Name: "cloud1",
+					K8sNodes: []K8sNode{
+						K8sNode{Name: "node1", ResidualResources: GenericResources{CpuCore: 2, Memory: 712, Storage: 12}},
+						K8sNode{Name: "node2", ResidualResources: GenericResources{CpuCore: 1, Memory: 200, Storage: 2}},
+					},
+				},
+				"cloud2": Cloud{
+					Name: "cloud2",
+					K8sNodes: []K8sNode{
+						K8sNode{Name: "node3", ResidualResources: GenericResources{CpuCore: 2, Memory: 1024, Storage: 20}},
+					},
+				},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Logf("test: %s", testCase.name)
+		FreePodsResources(testCase.clouds, testCase.pods)
+		assert.Equal(t, testCase.expectedResult, testCase.clouds)
+	}
+}
@@ -0,0 +1,148 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+)
+
+/**
+NOTE:
+
+The scheduling algorithms schedule applications one by one. To schedule an application with more than 1 replica, we expand it into replicas before scheduling, and every replica is scheduled as an application, named by ReplicaName. After scheduling, we collapse the solutions of the replicas back into the solution of the application.
+The replicas of an application should not be on the same Kubernetes node, and if ReplicaSpread is "cloud", they should not be on the same cloud either.
+An application is accepted if at least one of its replicas is accepted, and it is deployed with only the accepted replicas.
+*/
+
+const (
+	ReplicaSpreadNode  string = "node"  // different replicas of an application on different Kubernetes nodes
+	ReplicaSpreadCloud string = "cloud" // different replicas of an application on different clouds
+
+	MaxReplicas int = 10
+
+	// Kubernetes names cannot include this character, so the names of replicas will not conflict with the names of applications.
+	replicaNameSep string = "#"
+)
+
+// the name of the replica with the index idx of an application
+func ReplicaName(appName string, idx int) string {
+	return fmt.Sprintf("%s%s%d", appName, replicaNameSep, idx)
+}
+
+// Whether an application needs to be expanded into replicas
+func (app Application) MultiReplica() bool {
+	return app.Replicas > 1 && len(app.ReplicaOf) == 0
+}
+
+// The name of the application that this application belongs to. For an application that is not a replica, it is its own name.
+func (app Application) OwnerName() string {
+	if len(app.ReplicaOf) != 0 {
+		return app.ReplicaOf
+	}
+	return app.Name
+}
+
+// Expand every application with more than 1 replica into its replicas. The applications with 1 replica are not changed.
+// The dependencies are not changed, so they are still the names of applications rather than replicas, and DepReplicaNames can find the replicas of a dependency.
+func ExpandReplicas(apps map[string]Application) map[string]Application {
+	var expanded map[string]Application = make(map[string]Application)
+	for name, app := range apps {
+		if !app.MultiReplica() {
+			expanded[name] = AppCopy(app)
+			continue
+		}
+		for i := 0; i < app.Replicas; i++ {
+			replica := AppCopy(app)
+			replica.Name = ReplicaName(app.Name, i)
+			replica.ReplicaOf = app.Name
+			// every replica of a fixed application stays at its own placement
+			if len(app.FixedPlacements) == app.Replicas {
+				replica.FixedPlacements = []ReplicaSolution{app.FixedPlacements[i]}
+			}
+			expanded[replica.Name] = replica
+		}
+	}
+	return expanded
+}
+
+// Group the applications by the applications that they belong to. key: application name, value: the names of its replicas in order, or its own name if it is not expanded.
+func ReplicaGroups(apps map[string]Application) map[string][]string {
+	var groups map[string][]string = make(map[string][]string)
+	for name, app := range apps {
+		owner := app.OwnerName()
+		groups[owner] = append(groups[owner], name)
+	}
+	for _, names := range groups {
+		sort.Strings(names)
+	}
+	return groups
+}
+
+// Collapse the solutions of the replicas into the solutions of the applications. apps are the applications before expansion.
+func CollapseReplicas(apps map[string]Application, soln Solution) Solution {
+	collapsed := SolutionCopy(soln)
+	for name, app := range apps {
+		if !app.MultiReplica() {
+			continue
+		}
+
+		appSoln := SasCopy(RejSoln)
+		for i := 0; i < app.Replicas; i++ {
+			replicaName := ReplicaName(name, i)
+			replicaSoln := soln.AppsSolution[replicaName]
+			delete(collapsed.AppsSolution, replicaName)
+			if !replicaSoln.Accepted {
+				continue
+			}
+			if !appSoln.Accepted {
+				appSoln.Accepted = true
+				appSoln.TargetCloudName = replicaSoln.TargetCloudName
+				appSoln.K8sNodeName = replicaSoln.K8sNodeName
+				appSoln.AllocatedCpuCore = replicaSoln.AllocatedCpuCore
+			}
+			appSoln.Replicas = append(appSoln.Replicas, ReplicaSolution{
+				TargetCloudName:  replicaSoln.TargetCloudName,
+				K8sNodeName:      replicaSoln.K8sNodeName,
+				AllocatedCpuCore: replicaSoln.AllocatedCpuCore,
+			})
+		}
+		collapsed.AppsSolution[name] = appSoln
+	}
+	return collapsed
+}
+
+// Get the placements of all accepted replicas of an application solution. For an application with 1 replica, it is the placement of the application.
+func (sas SingleAppSolution) ReplicaPlacements() []ReplicaSolution {
+	if !sas.Accepted {
+		return nil
+	}
+	if len(sas.Replicas) != 0 {
+		return sas.Replicas
+	}
+	return []ReplicaSolution{{
+		TargetCloudName:  sas.TargetCloudName,
+		K8sNodeName:      sas.K8sNodeName,
+		AllocatedCpuCore: sas.AllocatedCpuCore,
+	}}
+}
+
+// Whether 2 applications are different replicas of the same application
+func (app Application) IsReplicaSibling(other Application) bool {
+	return len(app.ReplicaOf) != 0 && app.ReplicaOf == other.ReplicaOf && app.Name != other.Name
+}
+
+// The dependencies of applications are the names of applications, and this function finds the names of the applications to schedule that serve a dependency.
+// If the dependent application is expanded, they are its replicas, otherwise it is the dependent application itself.
+func DepReplicaNames(apps map[string]Application, depAppName string) []string {
+	if _, exist := apps[depAppName]; exist {
+		return []string{depAppName}
+	}
+	var names []string
+	for i := 0; ; i++ {
+		replicaName := ReplicaName(depAppName, i)
+		if _, exist := apps[replicaName]; !exist {
+			break
+		}
+		names = append(names, replicaName)
+	}
+	return names
+}
@@ -0,0 +1,121 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"emcontroller/models"
+)
+
+func TestExpandReplicas(t *testing.T) {
+	testCases := []struct {
+		name          string
+		apps          map[string]Application
+		expectedNames []string
+	}{
+		{
+			name: "1 replica not expanded",
+			apps: map[string]Application{
+				"app1": {Name: "app1", Priority: 5, Replicas: 1},
+			},
+			expectedNames: []string{"app1"},
+		},
+		{
+			name: "3 replicas expanded",
+			apps: map[string]Application{
+				"app1": {Name: "app1", Priority: 5, Replicas: 1},
+				"app2": {Name: "app2", Priority: 5, Replicas: 3, ReplicaSpread: ReplicaSpreadCloud, Dependencies: []models.Dependency{{AppName: "app1"}}},
+			},
+			expectedNames: []string{"app1", "app2#0", "app2#1", "app2#2"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Logf("test: %s", testCase.name)
+		expanded := ExpandReplicas(testCase.apps)
+		var actualNames []string
+		for name, app := range expanded {
+			actualNames = append(actualNames, name)
+			assert.Equal(t, name, app.Name, fmt.Sprintf("%s: name of [%s] is not expected", testCase.name, name))
+			ori := testCase.apps[app.OwnerName()]
+			assert.Equal(t, ori.Priority, app.Priority)
+			assert.Equal(t, ori.ReplicaSpread, app.ReplicaSpread)
+			assert.Equal(t, ori.Dependencies, app.Dependencies)
+		}
+		assert.ElementsMatch(t, testCase.expectedNames, actualNames, fmt.Sprintf("%s: result is not expected", testCase.name))
+	}
+}
+
+func TestCollapseReplicas(t *testing.T) {
+	apps := map[string]Application{
+		"app1": {Name: "app1", Replicas: 1},
+		"app2": {Name: "app2", Replicas: 3},
+		"app3": {Name: "app3", Replicas: 2},
+	}
+
+	testCases := []struct {
+		name           string
+		soln           Solution
+		expectedResult Solution
+	}{
+		{
+			name: "some replicas accepted",
+			soln: Solution{
+				AppsSolution: map[string]SingleAppSolution{
+					"app1":   {Accepted: true, TargetCloudName: "cloud1", K8sNodeName: "node1", AllocatedCpuCore: 2},
+					"app2#0": {Accepted: false},
+					"app2#1": {Accepted: true, TargetCloudName: "cloud1", K8sNodeName: "node1", AllocatedCpuCore: 1},
+					"app2#2": {Accepted: true, TargetCloudName: "cloud2", K8sNodeName: "node2", AllocatedCpuCore: 1},
+					"app3#0": {Accepted: false},
+					"app3#1": {Accepted: false},
+				},
+			},
+			expectedResult: Solution{
+				AppsSolution: map[string]SingleAppSolution{
+					"app1": {Accepted: true, TargetCloudName: "cloud1", K8sNodeName: "node1", AllocatedCpuCore: 2},
+					"app2": {
+						Accepted:         true,
+						TargetCloudName:  "cloud1",
+						K8sNodeName:      "node1",
+						AllocatedCpuCore: 1,
+						Replicas: []ReplicaSolution{
+							{TargetCloudName: "cloud1", K8sNodeName: "node1", AllocatedCpuCore: 1},
+							{TargetCloudName: "cloud2", K8sNodeName: "node2", AllocatedCpuCore: 1},
+						},
+					},
+					"app3": {Accepted: false},
+				},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Logf("test: %s", testCase.name)
+		actualResult := CollapseReplicas(apps, testCase.soln)
+		assert.Equal(t, testCase.expectedResult.AppsSolution, actualResult.AppsSolution, fmt.Sprintf("%s: result is not expected", testCase.name))
+	}
+}
+
+func TestDepReplicaNames(t *testing.T) {
+	apps := ExpandReplicas(map[string]Application{
+		"app1": {Name: "app1", Replicas: 1},
+		"app2": {Name: "app2", Replicas: 2},
+	})
+
+	testCases := []struct {
+		name           string
+		depAppName     string
+		expectedResult []string
+	}{
+		{name: "not expanded", depAppName: "app1", expectedResult: []string{"app1"}},
+		{name: "expanded", depAppName: "app2", expectedResult: []string{"app2#0", "app2#1"}},
+		{name: "not exist", depAppName: "app3", expectedResult: nil},
+	}
+
+	for _, testCase := range testCases {
+		t.Logf("test: %s", testCase.name)
+		assert.Equal(t, testCase.expectedResult, DepReplicaNames(apps, testCase.depAppName), fmt.Sprintf("%s: result is not expected", testCase.name))
+	}
+}
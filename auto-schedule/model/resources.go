@@ -9,7 +9,11 @@ There are 2 types of resources: (If no people defined them previously, we can de
 package model
 
 import (
+	"math"
+	"sort"
+
 	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 const (
@@ -22,6 +26,10 @@ const (
 // We use a different Object for the applications resources, in case of some special scenarios.
 type AppResources struct {
 	GenericResources `json:",inline"`
+	// unit Gibibyte (GiB), the sum of the sizes of the PVCs of this application. It is shown in the scheduling plan.
+	// It is provided by the storage classes of Kubernetes but not the VMs, so it is not in GenericResources.Storage (ephemeral storage), and not counted in the storage of nodes and clouds.
+	// The capacity of storage classes is not known by multi-cloud manager, so the algorithms do not check it. If it is not enough, the PVC is pending in Kubernetes and the application cannot start.
+	PersistentStorage float64 `json:"persistentStorage,omitempty"`
 }
 
 type GenericResources struct {
@@ -42,3 +50,81 @@ func GetResOccupiedByPod(pod apiv1.Pod) GenericResources {
 	}
 	return occupied
 }
+
+// The CPU of a container that requests CPU but cannot get 1 core. Without it, the container would get the CPU "0".
+const MinContainerCpu float64 = 0.001 // 1m
+
+// Split the integer CPU cores allocated to an application to its containers in proportion to their requested CPU cores.
+// Every container that requests CPU gets at least 1 core if there are enough cores, otherwise MinContainerCpu, and no container gets more than its request.
+// If no container requests CPU, all cores are given to the first container.
+func SplitCpu(allocatedCpu float64, containerCpus []float64) []float64 {
+	if len(containerCpus) == 0 {
+		return []float64{allocatedCpu}
+	}
+
+	split := make([]float64, len(containerCpus))
+	var sumReq float64
+	for _, cpu := range containerCpus {
+		sumReq += cpu
+	}
+	if sumReq <= 0 {
+		split[0] = allocatedCpu
+		return split
+	}
+
+	total := math.Round(allocatedCpu)
+	// step 1: give every container the floor of its proportional share
+	remainders := make([]int, 0, len(containerCpus))
+	var given float64
+	for i, cpu := range containerCpus {
+		share := total * cpu / sumReq
+		split[i] = math.Floor(share)
+		given += split[i]
+		if share-split[i] > 0 {
+			remainders = append(remainders, i)
+		}
+	}
+
+	// step 2: give the rest cores to the containers with the largest fractional parts
+	sort.SliceStable(remainders, func(a, b int) bool {
+		fracA := total*containerCpus[remainders[a]]/sumReq - split[remainders[a]]
+		fracB := total*containerCpus[remainders[b]]/sumReq - split[remainders[b]]
+		return fracA > fracB
+	})
+	for _, i := range remainders {
+		if given >= total {
+			break
+		}
+		split[i]++
+		given++
+	}
+
+	// step 3: every container requesting CPU needs at least 1 core, which we take from the container with the most cores
+	for i, cpu := range containerCpus {
+		if cpu <= 0 || split[i] >= 1 {
+			continue
+		}
+		richest := 0
+		for j := range split {
+			if split[j] > split[richest] {
+				richest = j
+			}
+		}
+		if split[richest] <= 1 {
+			split[i] = MinContainerCpu // not enough cores for every container
+			continue
+		}
+		split[richest]--
+		split[i]++
+	}
+
+	return split
+}
+
+// The CPU of a container as a Kubernetes quantity, such as "2" or "1m". It is "" for 0, with which the CPU of the container is not set.
+func ContainerCpuQuantity(cpu float64) string {
+	if cpu <= 0 {
+		return ""
+	}
+	return resource.NewMilliQuantity(int64(math.Round(cpu*1000)), resource.DecimalSI).String()
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	apiv1 "k8s.io/api/core/v1"
 
 	"emcontroller/models"
@@ -47,3 +48,83 @@ func TestGetOccupiedResByPod(t *testing.T) {
 		t.Logf("%s/%s, occupied: %+v", pod.Namespace, pod.Name, occupiedRes)
 	}
 }
+
+func TestSplitCpu(t *testing.T) {
+	testCases := []struct {
+		name           string
+		allocatedCpu   float64
+		containerCpus  []float64
+		expectedResult []float64
+	}{
+		{
+			name:           "no container information",
+			allocatedCpu:   3,
+			containerCpus:  nil,
+			expectedResult: []float64{3},
+		},
+		{
+			name:           "one container",
+			allocatedCpu:   3,
+			containerCpus:  []float64{4},
+			expectedResult: []float64{3},
+		},
+		{
+			name:           "as requested",
+			allocatedCpu:   6,
+			containerCpus:  []float64{4, 2},
+			expectedResult: []float64{4, 2},
+		},
+		{
+			name:           "proportional with remainder",
+			allocatedCpu:   4,
+			containerCpus:  []float64{4, 2},
+			expectedResult: []float64{3, 1},
+		},
+		{
+			name:           "at least 1 core for each container",
+			allocatedCpu:   3,
+			containerCpus:  []float64{8, 1, 1},
+			expectedResult: []float64{1, 1, 1},
+		},
+		{
+			name:           "container without CPU request",
+			allocatedCpu:   2,
+			containerCpus:  []float64{2, 0},
+			expectedResult: []float64{2, 0},
+		},
+		{
+			name:           "no container requests CPU",
+			allocatedCpu:   1,
+			containerCpus:  []float64{0, 0},
+			expectedResult: []float64{1, 0},
+		},
+		{
+			name:           "fewer cores than the containers requesting CPU",
+			allocatedCpu:   1,
+			containerCpus:  []float64{2, 2, 0},
+			expectedResult: []float64{1, MinContainerCpu, 0},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Logf("test: %s", testCase.name)
+		actualResult := SplitCpu(testCase.allocatedCpu, testCase.containerCpus)
+		assert.Equal(t, testCase.expectedResult, actualResult, fmt.Sprintf("%s: result is not expected", testCase.name))
+	}
+}
+
+func TestContainerCpuQuantity(t *testing.T) {
+	testCases := []struct {
+		cpu      float64
+		expected string
+	}{
+		{cpu: 2, expected: "2"},
+		{cpu: 1.5, expected: "1500m"},
+		{cpu: MinContainerCpu, expected: "1m"},
+		{cpu: 0, expected: ""},
+	}
+	for i, testCase := range testCases {
+		t.Logf("test: %d, cpu %g", i, testCase.cpu)
+		assert.Equal(t, testCase.expected, ContainerCpuQuantity(testCase.cpu))
+	}
+}
@@ -64,11 +64,26 @@ type SingleAppSolution struct {
 	// CPU core is a soft requirement, which means we do not have to allocate all required CPU cores to an application.
 	// For example, if an application requires 4 CPU cores, but we only allocate 2 CPU cores to it, in the containerSpec of it, we will set the required CPU is 2 and the Limit CPU is 4.
 	AllocatedCpuCore float64 `json:"allocatedCpuCore"`
+
+	// The placement of every accepted replica, only set for the applications with more than 1 replica, after the solutions of their replicas are collapsed by CollapseReplicas.
+	// The above 3 member variables are the same as those of the first accepted replica.
+	Replicas []ReplicaSolution `json:"replicas,omitempty"`
+}
+
+// The scheduling scheme for a replica of an application
+type ReplicaSolution struct {
+	TargetCloudName  string  `json:"targetCloudName"`
+	K8sNodeName      string  `json:"k8sNodeName"`
+	AllocatedCpuCore float64 `json:"allocatedCpuCore"`
 }
 
 // single app solution copy
 func SasCopy(src SingleAppSolution) SingleAppSolution {
 	var dst SingleAppSolution = src
+	if src.Replicas != nil {
+		dst.Replicas = make([]ReplicaSolution, len(src.Replicas))
+		copy(dst.Replicas, src.Replicas)
+	}
 	return dst
 }
 
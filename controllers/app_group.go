@@ -16,27 +16,18 @@ import (
 
 // when deploying an application group, user can use this HTTP header to choose the scheduling algorithm to use.
 const (
-	SAHeaderKey     string = "Mcm-Scheduling-Algorithm"
-	ExTimeOneCpuKey string = "Expected-Time-One-Cpu" // expected application computation time with one CPU core
+	SAHeaderKey       string = "Mcm-Scheduling-Algorithm"
+	SAParamsHeaderKey string = "Mcm-Scheduling-Params" // a json object to override the default parameters of the scheduling algorithm, e.g., {"iterationCount": 1000}
+	ExTimeOneCpuKey   string = "Expected-Time-One-Cpu" // expected application computation time with one CPU core
 )
 
 type AppGroupController struct {
 	beego.Controller
 }
 
+// Deploying an application group may take a long time, so this API only creates a scheduling job and returns it at once.
+// The job can be checked by "GET /scheduleJob/:id" and cancelled by "DELETE /scheduleJob/:id".
 func (c *AppGroupController) DoNewAppGroup() {
-	// scheduling, migration, and cleanup cannot be done at the same time
-	if !algorithms.ScheMu.TryLock() {
-		outErr := fmt.Errorf("Another task of Scheduling, Migration or Cleanup is running. Please try later.")
-		beego.Error(outErr)
-		c.Ctx.ResponseWriter.WriteHeader(http.StatusLocked)
-		if result, err := c.Ctx.ResponseWriter.Write([]byte(outErr.Error())); err != nil {
-			beego.Error(fmt.Sprintf("Write Error to response, error: %s, result: %d", err.Error(), result))
-		}
-		return
-	}
-	defer algorithms.ScheMu.Unlock()
-
 	contentType := c.Ctx.Request.Header.Get("Content-Type")
 	beego.Info(fmt.Sprintf("The header \"Content-Type\" is [%s]", contentType))
 
@@ -44,32 +35,155 @@ func (c *AppGroupController) DoNewAppGroup() {
 	case strings.Contains(strings.ToLower(contentType), JsonContentType):
 		beego.Info(fmt.Sprintf("The input body should be json"))
 		c.DoNewAppGroupJson()
+	case isYamlContentType(contentType):
+		beego.Info(fmt.Sprintf("The input body should be a Kubernetes manifest in YAML"))
+		c.DoNewAppGroupJson()
 	default:
 		beego.Info(fmt.Sprintf("The input body should be form"))
 		c.DoNewAppGroupForm()
 	}
 }
 
-// Used for json request, input is json
+// Used for json request, input is json, or a Kubernetes manifest in YAML with the "Content-Type" "application/yaml", which is converted by "/importAppGroup".
 // test command:
-// curl -i -X POST -H Content-Type:application/json -H Mcm-Scheduling-Algorithm:Mcssga -H Expected-Time-One-Cpu:35 -d '[ { "priority": 2, "autoScheduled": true, "name": "group-printtime", "replicas": 1, "hostNetwork": false, "containers": [ { "name": "printtime", "image": "172.27.15.31:5000/printtime:v1", "workDir": "/printtime", "resources": { "limits": { "memory": "30Mi", "cpu": "2", "storage": "2Gi" }, "requests": { "memory": "30Mi", "cpu": "2", "storage": "2Gi" } }, "commands": [ "bash" ], "args": [ "-c", "python3 -u main.py > $LOGFILE" ], "env": [ { "name": "PARAMETER1", "value": "testRenderenv1" }, { "name": "LOGFILE", "value": "/tmp/234/printtime.log" } ], "mounts": [ { "vmPath": "/tmp/asdff", "containerPath": "/tmp/234" }, { "vmPath": "/tmp/uyyyy", "containerPath": "/tmp/2345" } ] } ], "dependencies": [ { "appName": "group-nginx" }, { "appName": "group-ubuntu" } ] }, { "priority": 4, "autoScheduled": true, "name": "group-nginx", "replicas": 1, "hostNetwork": true, "containers": [ { "name": "nginx", "image": "172.27.15.31:5000/nginx:1.17.1", "workDir": "", "resources": { "limits": { "memory": "1024Mi", "cpu": "2", "storage": "20Gi" }, "requests": { "memory": "1024Mi", "cpu": "2", "storage": "20Gi" } }, "ports": [ { "containerPort": 80, "name": "fsd", "protocol": "tcp", "servicePort": "80", "nodePort": "30001" } ] } ], "dependencies": [ { "appName": "group-ubuntu" } ] }, { "priority": 4, "autoScheduled": true, "name": "group-ubuntu", "replicas": 1, "hostNetwork": true, "containers": [ { "name": "ubuntu", "image": "172.27.15.31:5000/ubuntu:latest", "workDir": "", "resources": { "limits": { "memory": "512Mi", "cpu": "1", "storage": "20Gi" }, "requests": { "memory": "512Mi", "cpu": "1", "storage": "20Gi" } }, "commands": [ "bash", "-c", "while true;do sleep 10;done" ], "args": null, "env": [ { "name": "asfasf", "value": "asfasf" }, { "name": "asdfsdf", "value": "sfsdf" } ], "mounts": [ { "vmPath": "/tmp/asdff", "containerPath": "/tmp/log" } ], "ports": null } ], "dependencies": [] } ]' http://localhost:20000/doNewAppGroup
+// curl -i -X POST -H Content-Type:application/json -H Mcm-Scheduling-Algorithm:Mcssga -H 'Mcm-Scheduling-Params: {"iterationCount": 1000}' -H Expected-Time-One-Cpu:35 -d '[ { "priority": 2, "autoScheduled": true, "name": "group-printtime", "replicas": 1, "hostNetwork": false, "containers": [ { "name": "printtime", "image": "172.27.15.31:5000/printtime:v1", "workDir": "/printtime", "resources": { "limits": { "memory": "30Mi", "cpu": "2", "storage": "2Gi" }, "requests": { "memory": "30Mi", "cpu": "2", "storage": "2Gi" } }, "commands": [ "bash" ], "args": [ "-c", "python3 -u main.py > $LOGFILE" ], "env": [ { "name": "PARAMETER1", "value": "testRenderenv1" }, { "name": "LOGFILE", "value": "/tmp/234/printtime.log" } ], "mounts": [ { "vmPath": "/tmp/asdff", "containerPath": "/tmp/234" }, { "vmPath": "/tmp/uyyyy", "containerPath": "/tmp/2345" } ] } ], "dependencies": [ { "appName": "group-nginx" }, { "appName": "group-ubuntu" } ] }, { "priority": 4, "autoScheduled": true, "name": "group-nginx", "replicas": 1, "hostNetwork": true, "containers": [ { "name": "nginx", "image": "172.27.15.31:5000/nginx:1.17.1", "workDir": "", "resources": { "limits": { "memory": "1024Mi", "cpu": "2", "storage": "20Gi" }, "requests": { "memory": "1024Mi", "cpu": "2", "storage": "20Gi" } }, "ports": [ { "containerPort": 80, "name": "fsd", "protocol": "tcp", "servicePort": "80", "nodePort": "30001" } ] } ], "dependencies": [ { "appName": "group-ubuntu" } ] }, { "priority": 4, "autoScheduled": true, "name": "group-ubuntu", "replicas": 1, "hostNetwork": true, "containers": [ { "name": "ubuntu", "image": "172.27.15.31:5000/ubuntu:latest", "workDir": "", "resources": { "limits": { "memory": "512Mi", "cpu": "1", "storage": "20Gi" }, "requests": { "memory": "512Mi", "cpu": "1", "storage": "20Gi" } }, "commands": [ "bash", "-c", "while true;do sleep 10;done" ], "args": null, "env": [ { "name": "asfasf", "value": "asfasf" }, { "name": "asdfsdf", "value": "sfsdf" } ], "mounts": [ { "vmPath": "/tmp/asdff", "containerPath": "/tmp/log" } ], "ports": null } ], "dependencies": [] } ]' http://localhost:20000/doNewAppGroup
 func (c *AppGroupController) DoNewAppGroupJson() {
+	apps, err := c.parseApps()
+	if err != nil {
+		beego.Error(err)
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		if result, err := c.Ctx.ResponseWriter.Write([]byte(err.Error())); err != nil {
+			beego.Error(fmt.Sprintf("Write Error to response, error: %s, result: %d", err.Error(), result))
+		}
+		return
+	}
+
+	beego.Info(fmt.Sprintf("From json input, we successfully parsed applications [%+v]", apps))
+
+	if err, statusCode := c.setAppsNamespace(apps); err != nil {
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		if result, err := c.Ctx.ResponseWriter.Write([]byte(err.Error())); err != nil {
+			beego.Error(fmt.Sprintf("Write Error to response, error: %s, result: %d", err.Error(), result))
+		}
+		return
+	}
+
+	schedAlgorithm, schedParams, exTimeOneCpu, err := c.getScheHeaders()
+	if err != nil {
+		beego.Error(err)
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		if result, err := c.Ctx.ResponseWriter.Write([]byte(err.Error())); err != nil {
+			beego.Error(fmt.Sprintf("Write Error to response, error: %s, result: %d", err.Error(), result))
+		}
+		return
+	}
+
+	// scheduling, migration, and cleanup cannot be done at the same time, which is checked when starting the job.
+	job, err, statusCode := executors.StartScheduleJob(apps, schedAlgorithm, schedParams, exTimeOneCpu)
+	if err != nil {
+		outErr := fmt.Errorf("executors.StartScheduleJob(apps), error: %w", err)
+		beego.Error(outErr)
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		if result, err := c.Ctx.ResponseWriter.Write([]byte(outErr.Error())); err != nil {
+			beego.Error(fmt.Sprintf("Write Error to response, error: %s, result: %d", err.Error(), result))
+		}
+		return
+	}
+
+	beego.Info(fmt.Sprintf("Scheduling job [%s] is created.", job.ID))
+	c.Ctx.Output.Header("Location", "/scheduleJob/"+job.ID)
+	c.Ctx.Output.Status = statusCode
+	c.Data["json"] = job
+	c.ServeJSON()
+}
+
+// Parse the applications in the request body, which is the json of []models.K8sApp, or a Kubernetes manifest in YAML.
+func (c *AppGroupController) parseApps() ([]models.K8sApp, error) {
+	if isYamlContentType(c.Ctx.Request.Header.Get("Content-Type")) {
+		apps, _, err := c.importManifest()
+		return apps, err
+	}
+
 	var apps []models.K8sApp
 	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &apps); err != nil {
-		outErr := fmt.Errorf("json.Unmarshal the applications in RequestBody, error: %w", err)
-		beego.Error(outErr)
+		return nil, fmt.Errorf("json.Unmarshal the applications in RequestBody, error: %w", err)
+	}
+	return apps, nil
+}
+
+// Convert the manifest in the request body to applications. Under "/tenant/:tenant", the applications are imported into the tenant, whatever namespace they are exported from.
+func (c *AppGroupController) importManifest() ([]models.K8sApp, []string, error) {
+	apps, warnings, err := models.AutoScheduleAppsFromManifest(c.Ctx.Input.RequestBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Import the applications from the manifest in RequestBody, error: %w", err)
+	}
+	if len(c.Ctx.Input.Param(":tenant")) > 0 {
+		for i := range apps {
+			apps[i].Namespace = ""
+		}
+	}
+	return apps, warnings, nil
+}
+
+// The result of importing a manifest
+type importedAppGroup struct {
+	Apps     []models.K8sApp `json:"apps"`
+	Warnings []string        `json:"warnings"` // the fields and objects in the manifest that are dropped
+}
+
+// Convert a Kubernetes manifest (a multi-document YAML or a json "List") with Deployments and Services to the applications for auto-scheduling, which can be checked and sent to "/doNewAppGroup".
+// test command:
+// curl -i -X POST -H Content-Type:application/yaml --data-binary @manifest.yaml http://localhost:20000/importAppGroup
+func (c *AppGroupController) ImportAppGroup() {
+	apps, warnings, err := c.importManifest()
+	if err != nil {
+		beego.Error(err)
 		c.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
-		if result, err := c.Ctx.ResponseWriter.Write([]byte(outErr.Error())); err != nil {
+		if result, err := c.Ctx.ResponseWriter.Write([]byte(err.Error())); err != nil {
 			beego.Error(fmt.Sprintf("Write Error to response, error: %s, result: %d", err.Error(), result))
 		}
 		return
 	}
 
-	beego.Info(fmt.Sprintf("From json input, we successfully parsed applications [%+v]", apps))
+	if err, statusCode := c.setAppsNamespace(apps); err != nil {
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		if result, err := c.Ctx.ResponseWriter.Write([]byte(err.Error())); err != nil {
+			beego.Error(fmt.Sprintf("Write Error to response, error: %s, result: %d", err.Error(), result))
+		}
+		return
+	}
+
+	if warnings == nil {
+		warnings = []string{}
+	}
+	c.Ctx.Output.Status = http.StatusOK
+	c.Data["json"] = importedAppGroup{Apps: apps, Warnings: warnings}
+	c.ServeJSON()
+}
+
+// Put the applications into the namespace of the request. Under "/tenant/:tenant", the applications are auto-scheduled for the tenant.
+func (c *AppGroupController) setAppsNamespace(apps []models.K8sApp) (error, int) {
+	for i := range apps {
+		if err, statusCode := setAppNamespace(c.Ctx, &apps[i]); err != nil {
+			return err, statusCode
+		}
+	}
+	return nil, http.StatusOK
+}
 
+// get the scheduling algorithm, its parameters, and the expected computation time from the HTTP headers
+func (c *AppGroupController) getScheHeaders() (string, map[string]float64, float64, error) {
 	schedAlgorithm := c.Ctx.Request.Header.Get(SAHeaderKey)
 	beego.Info(fmt.Sprintf("The header %s is [%s]", SAHeaderKey, schedAlgorithm))
 
+	var schedParams map[string]float64
+	if schedParamsStr := c.Ctx.Request.Header.Get(SAParamsHeaderKey); len(schedParamsStr) != 0 {
+		beego.Info(fmt.Sprintf("The header %s is [%s]", SAParamsHeaderKey, schedParamsStr))
+		if err := json.Unmarshal([]byte(schedParamsStr), &schedParams); err != nil {
+			return "", nil, 0, fmt.Errorf("json.Unmarshal HTTP header key [%s] value [%s], error: %w", SAParamsHeaderKey, schedParamsStr, err)
+		}
+	}
+
 	exTimeOneCpuStr := c.Ctx.Request.Header.Get(ExTimeOneCpuKey)
 	exTimeOneCpu, err := strconv.ParseFloat(exTimeOneCpuStr, 64)
 	if err != nil {
@@ -80,9 +194,138 @@ func (c *AppGroupController) DoNewAppGroupJson() {
 		beego.Info(fmt.Sprintf("Parse header %s to float [%g]", ExTimeOneCpuKey, exTimeOneCpu))
 	}
 
-	outApps, err, statusCode := executors.CreateAutoScheduleApps(apps, schedAlgorithm, exTimeOneCpu)
+	return schedAlgorithm, schedParams, exTimeOneCpu, nil
+}
+
+// Only run the scheduling algorithm and return the plan, without creating VMs or deploying applications. The input is the same as "/doNewAppGroup".
+// test command:
+// curl -i -X POST -H Content-Type:application/json -H Mcm-Scheduling-Algorithm:Mcssga -H Expected-Time-One-Cpu:35 -d '<the same applications as /doNewAppGroup>' http://localhost:20000/planAppGroup
+func (c *AppGroupController) PlanAppGroup() {
+	apps, err := c.parseApps()
+	if err != nil {
+		beego.Error(err)
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		if result, err := c.Ctx.ResponseWriter.Write([]byte(err.Error())); err != nil {
+			beego.Error(fmt.Sprintf("Write Error to response, error: %s, result: %d", err.Error(), result))
+		}
+		return
+	}
+
+	if err, statusCode := c.setAppsNamespace(apps); err != nil {
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		if result, err := c.Ctx.ResponseWriter.Write([]byte(err.Error())); err != nil {
+			beego.Error(fmt.Sprintf("Write Error to response, error: %s, result: %d", err.Error(), result))
+		}
+		return
+	}
+
+	schedAlgorithm, schedParams, exTimeOneCpu, err := c.getScheHeaders()
+	if err != nil {
+		beego.Error(err)
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		if result, err := c.Ctx.ResponseWriter.Write([]byte(err.Error())); err != nil {
+			beego.Error(fmt.Sprintf("Write Error to response, error: %s, result: %d", err.Error(), result))
+		}
+		return
+	}
+
+	plan, err, statusCode := executors.PlanAutoScheduleApps(apps, schedAlgorithm, schedParams, exTimeOneCpu)
+	if err != nil {
+		outErr := fmt.Errorf("executors.PlanAutoScheduleApps(apps), error: %w", err)
+		beego.Error(outErr)
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		if result, err := c.Ctx.ResponseWriter.Write([]byte(outErr.Error())); err != nil {
+			beego.Error(fmt.Sprintf("Write Error to response, error: %s, result: %d", err.Error(), result))
+		}
+		return
+	}
+
+	beego.Info(fmt.Sprintf("Scheduling plan made by [%s], accepted applications %v, rejected applications %v, fitness value %g.", plan.AlgoName, plan.AcceptedApps, plan.RejectedApps, plan.Fitness))
+	c.Ctx.Output.Status = statusCode
+	c.Data["json"] = plan
+	c.ServeJSON()
+}
+
+// Deploy a plan returned by "/planAppGroup". The plan is deployed exactly as it is, without scheduling again. Like "/doNewAppGroup", this API creates a scheduling job.
+// test command:
+// curl -i -X POST -H Content-Type:application/json -d '<the plan returned by /planAppGroup>' http://localhost:20000/applyAppGroupPlan
+func (c *AppGroupController) ApplyAppGroupPlan() {
+	var plan executors.SchedulePlan
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &plan); err != nil {
+		outErr := fmt.Errorf("json.Unmarshal the plan in RequestBody, error: %w", err)
+		beego.Error(outErr)
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		if result, err := c.Ctx.ResponseWriter.Write([]byte(outErr.Error())); err != nil {
+			beego.Error(fmt.Sprintf("Write Error to response, error: %s, result: %d", err.Error(), result))
+		}
+		return
+	}
+
+	// Under "/tenant/:tenant", the plan should be made for the tenant.
+	if err, statusCode := c.setAppsNamespace(plan.Apps); err != nil {
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		if result, err := c.Ctx.ResponseWriter.Write([]byte(err.Error())); err != nil {
+			beego.Error(fmt.Sprintf("Write Error to response, error: %s, result: %d", err.Error(), result))
+		}
+		return
+	}
+
+	job, err, statusCode := executors.StartApplyPlanJob(plan)
+	if err != nil {
+		outErr := fmt.Errorf("executors.StartApplyPlanJob(plan), error: %w", err)
+		beego.Error(outErr)
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		if result, err := c.Ctx.ResponseWriter.Write([]byte(outErr.Error())); err != nil {
+			beego.Error(fmt.Sprintf("Write Error to response, error: %s, result: %d", err.Error(), result))
+		}
+		return
+	}
+
+	beego.Info(fmt.Sprintf("Scheduling job [%s] is created to apply a plan.", job.ID))
+	c.Ctx.Output.Header("Location", "/scheduleJob/"+job.ID)
+	c.Ctx.Output.Status = statusCode
+	c.Data["json"] = job
+	c.ServeJSON()
+}
+
+// Migrate a group of running auto-scheduling applications. The input is the names of the applications, and the headers are the same as "/doNewAppGroup".
+// Like "/doNewAppGroup", this API creates a scheduling job.
+// test command:
+// curl -i -X POST -H Content-Type:application/json -H Mcm-Scheduling-Algorithm:Mcssga -H Expected-Time-One-Cpu:35 -d '["group-printtime", "group-nginx", "group-ubuntu"]' http://localhost:20000/migrateAppGroup
+func (c *AppGroupController) MigrateAppGroup() {
+	var appNames []string
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &appNames); err != nil {
+		outErr := fmt.Errorf("json.Unmarshal the application names in RequestBody, error: %w", err)
+		beego.Error(outErr)
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		if result, err := c.Ctx.ResponseWriter.Write([]byte(outErr.Error())); err != nil {
+			beego.Error(fmt.Sprintf("Write Error to response, error: %s, result: %d", err.Error(), result))
+		}
+		return
+	}
+
+	schedAlgorithm, schedParams, exTimeOneCpu, err := c.getScheHeaders()
+	if err != nil {
+		beego.Error(err)
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		if result, err := c.Ctx.ResponseWriter.Write([]byte(err.Error())); err != nil {
+			beego.Error(fmt.Sprintf("Write Error to response, error: %s, result: %d", err.Error(), result))
+		}
+		return
+	}
+
+	namespace, err, statusCode := requestNamespace(c.Ctx)
+	if err != nil {
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		if result, err := c.Ctx.ResponseWriter.Write([]byte(err.Error())); err != nil {
+			beego.Error(fmt.Sprintf("Write Error to response, error: %s, result: %d", err.Error(), result))
+		}
+		return
+	}
+
+	job, err, statusCode := executors.StartMigrateJob(namespace, appNames, schedAlgorithm, schedParams, exTimeOneCpu)
 	if err != nil {
-		outErr := fmt.Errorf("executors.CreateAutoScheduleApps(apps), error: %w", err)
+		outErr := fmt.Errorf("executors.StartMigrateJob(%v), error: %w", appNames, err)
 		beego.Error(outErr)
 		c.Ctx.ResponseWriter.WriteHeader(statusCode)
 		if result, err := c.Ctx.ResponseWriter.Write([]byte(outErr.Error())); err != nil {
@@ -91,8 +334,10 @@ func (c *AppGroupController) DoNewAppGroupJson() {
 		return
 	}
 
-	c.Ctx.Output.Status = http.StatusCreated
-	c.Data["json"] = outApps
+	beego.Info(fmt.Sprintf("Scheduling job [%s] is created to migrate applications %v.", job.ID, appNames))
+	c.Ctx.Output.Header("Location", "/scheduleJob/"+job.ID)
+	c.Ctx.Output.Status = statusCode
+	c.Data["json"] = job
 	c.ServeJSON()
 }
 
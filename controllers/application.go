@@ -9,6 +9,7 @@ import (
 
 	"github.com/astaxie/beego"
 
+	"emcontroller/auto-schedule/executors"
 	"emcontroller/models"
 	"emcontroller/weather" // Thêm import weather
 )
@@ -20,11 +21,19 @@ type ApplicationController struct {
 // get all applications
 // test command:
 // curl -i -X GET -H Accept:application/json http://localhost:20000/application
+// curl -i -X GET -H Accept:application/json http://localhost:20000/tenant/team-a/application
 func (c *ApplicationController) Get() {
 	acceptType := c.Ctx.Request.Header.Get("Accept")
 	beego.Info(fmt.Sprintf("The header \"Accept\" is [%s]", acceptType))
 
-	appList, err := models.ListApplications()
+	namespace, err, statusCode := requestNamespace(c.Ctx)
+	if err != nil {
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		c.Ctx.WriteString(err.Error())
+		return
+	}
+
+	appList, err := models.ListApplications(namespace)
 	if err != nil {
 		beego.Error(fmt.Sprintf("ListApplications error: %s", err.Error()))
 	}
@@ -37,6 +46,7 @@ func (c *ApplicationController) Get() {
 	default:
 		beego.Info(fmt.Sprintf("The output should be web"))
 		c.Data["applicationList"] = appList
+		c.Data["appBasePath"] = applicationBasePath(c.Ctx)
 
 		// Fetch weather (lat/lon Hà Nội, đổi nếu cần)
 		temp, err := weather.GetCurrentTemperature("21.0285", "105.8542")
@@ -62,7 +72,14 @@ func (c *ApplicationController) Get() {
 func (c *ApplicationController) DeleteApp() {
 	appName := c.Ctx.Input.Param(":appName")
 
-	err, statusCode := models.DeleteApplication(appName)
+	namespace, err, statusCode := requestNamespace(c.Ctx)
+	if err != nil {
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		c.Ctx.WriteString(err.Error())
+		return
+	}
+
+	err, statusCode = models.DeleteApplication(namespace, appName)
 	if err != nil {
 		beego.Error(err)
 		c.Ctx.ResponseWriter.WriteHeader(statusCode)
@@ -79,6 +96,13 @@ func (c *ApplicationController) DeleteApp() {
 func (c *ApplicationController) DeleteApps() {
 	var appNamesToDelete []string
 
+	namespace, err, statusCode := requestNamespace(c.Ctx)
+	if err != nil {
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		c.Ctx.WriteString(err.Error())
+		return
+	}
+
 	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &appNamesToDelete); err != nil {
 		outErr := fmt.Errorf("json.Unmarshal the vms in RequestBody, error: %w", err)
 		beego.Error(outErr)
@@ -93,7 +117,7 @@ func (c *ApplicationController) DeleteApps() {
 	beego.Info(fmt.Sprintf("Delete Applications %v.", appNamesToDelete))
 
 	// Use the parsed applications as the input information to delete applications
-	if errs := models.DeleteBatchApps(appNamesToDelete); len(errs) != 0 {
+	if errs := models.DeleteBatchApps(namespace, appNamesToDelete); len(errs) != 0 {
 		outErr := models.HandleErrSlice(errs)
 		beego.Error(fmt.Sprintf("DeleteBatchApps Error: %s", outErr.Error()))
 		c.Ctx.ResponseWriter.WriteHeader(http.StatusInternalServerError)
@@ -112,7 +136,14 @@ func (c *ApplicationController) DeleteApps() {
 func (c *ApplicationController) GetApp() {
 	appName := c.Ctx.Input.Param(":appName")
 
-	outApp, err, statusCode := models.GetApplication(appName)
+	namespace, err, statusCode := requestNamespace(c.Ctx)
+	if err != nil {
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		c.Ctx.WriteString(err.Error())
+		return
+	}
+
+	outApp, err, statusCode := models.GetApplication(namespace, appName)
 	if err != nil {
 		beego.Error(err)
 		c.Ctx.ResponseWriter.WriteHeader(statusCode)
@@ -125,6 +156,110 @@ func (c *ApplicationController) GetApp() {
 	c.ServeJSON()
 }
 
+// GetManifest renders the Kubernetes deployment and service of an application, in YAML by default, or in json with the query "format=json".
+// test command:
+// curl -i -X GET http://localhost:20000/application/test/manifest?format=yaml
+func (c *ApplicationController) GetManifest() {
+	appName := c.Ctx.Input.Param(":appName")
+	format := c.GetString("format", models.ManifestFormatYaml)
+
+	namespace, err, statusCode := requestNamespace(c.Ctx)
+	if err != nil {
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		c.Ctx.WriteString(err.Error())
+		return
+	}
+
+	manifest, err, statusCode := models.GetAppManifest(namespace, appName, format)
+	if err != nil {
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		c.Ctx.WriteString(err.Error())
+		return
+	}
+
+	contentType := YamlContentType
+	if format == models.ManifestFormatJson {
+		contentType = JsonContentType
+	}
+	c.Ctx.Output.Header("Content-Type", contentType)
+	c.Ctx.Output.Status = http.StatusOK
+	if err := c.Ctx.Output.Body(manifest); err != nil {
+		beego.Error(fmt.Sprintf("Write the manifest of application [%s] to response, error: %s", appName, err.Error()))
+	}
+}
+
+// UpdateApp updates an application by a rolling update, and waits for it running. The name in the request body can be omitted.
+// test command:
+// curl -i -X PUT -H Content-Type:application/json -d '{"name":"test","replicas":2,"containers":[{"name":"nginx","image":"172.27.15.31:5000/nginx:1.17.2","resources":{"limits":{"cpu":"200m"},"requests":{"cpu":"100m"}},"ports":[{"containerPort":80,"name":"fsd","protocol":"tcp","servicePort":"80","nodePort":"30001"}]}]}' http://localhost:20000/application/test
+func (c *ApplicationController) UpdateApp() {
+	appName := c.Ctx.Input.Param(":appName")
+
+	var app models.K8sApp
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &app); err != nil {
+		outErr := fmt.Errorf("json.Unmarshal the application in RequestBody, error: %w", err)
+		beego.Error(outErr)
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		c.Ctx.WriteString(outErr.Error())
+		return
+	}
+	if len(app.Name) == 0 {
+		app.Name = appName
+	}
+	if app.Name != appName {
+		outErr := fmt.Errorf("the application name [%s] in the request body is not the one [%s] in the path", app.Name, appName)
+		beego.Error(outErr)
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		c.Ctx.WriteString(outErr.Error())
+		return
+	}
+
+	if err, statusCode := setAppNamespace(c.Ctx, &app); err != nil {
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		c.Ctx.WriteString(err.Error())
+		return
+	}
+
+	result, err, statusCode := executors.UpdateAppAndWait(app)
+	if err != nil {
+		outErr := fmt.Errorf("Update application [%s], error: %w", appName, err)
+		beego.Error(outErr)
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		c.Ctx.WriteString(outErr.Error())
+		return
+	}
+
+	c.Ctx.Output.Status = http.StatusOK
+	c.Data["json"] = result
+	c.ServeJSON()
+}
+
+// RollbackApp rolls an application back to its previous revision, and waits for it running.
+// test command:
+// curl -i -X POST http://localhost:20000/application/test/rollback
+func (c *ApplicationController) RollbackApp() {
+	appName := c.Ctx.Input.Param(":appName")
+
+	namespace, err, statusCode := requestNamespace(c.Ctx)
+	if err != nil {
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		c.Ctx.WriteString(err.Error())
+		return
+	}
+
+	result, err, statusCode := executors.RollbackAppAndWait(namespace, appName)
+	if err != nil {
+		outErr := fmt.Errorf("Roll back application [%s], error: %w", appName, err)
+		beego.Error(outErr)
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		c.Ctx.WriteString(outErr.Error())
+		return
+	}
+
+	c.Ctx.Output.Status = http.StatusOK
+	c.Data["json"] = result
+	c.ServeJSON()
+}
+
 func (c *ApplicationController) NewApplication() {
 	mode := c.GetString("mode")
 	beego.Info("New application mode:", mode)
@@ -208,6 +343,7 @@ func (c *ApplicationController) DoNewAppForm() {
 	beego.Info(fmt.Sprintf("Application [%s] has [%d] pods. Each pod has [%d] containers.", appName, replicas, containerNum))
 
 	app.Name = appName
+	app.Namespace = c.GetString("namespace")
 	app.Replicas = replicas
 	app.NodeName = nodeName
 	app.NodeSelector = nodeSelector
@@ -352,6 +488,13 @@ func (c *ApplicationController) DoNewAppForm() {
 	}
 	beego.Info(fmt.Sprintf("App json is\n%s", string(appJson)))
 
+	if err, _ := setAppNamespace(c.Ctx, &app); err != nil {
+		c.Ctx.ResponseWriter.Header().Set("Content-Type", "text/plain")
+		c.Data["errorMessage"] = err.Error()
+		c.TplName = "error.tpl"
+		return
+	}
+
 	// Use the parsed app to create an application
 	if err := models.CreateApplication(app); err != nil {
 		outErr := fmt.Errorf("Create application %+v, error: %w", app, err)
@@ -383,6 +526,12 @@ func (c *ApplicationController) DoNewAppJson() {
 
 	beego.Info(fmt.Sprintf("From json input, we successfully parsed application [%+v]", app))
 
+	if err, statusCode := setAppNamespace(c.Ctx, &app); err != nil {
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		c.Ctx.WriteString(err.Error())
+		return
+	}
+
 	// Use the parsed app to create an application
 	// Here, we wait until the app status becomes running.
 	// We only do this behavior for json input, because for the form input, users can check the status on the web
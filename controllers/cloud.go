@@ -1,7 +1,10 @@
 package controllers
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/astaxie/beego"
 
@@ -13,6 +16,12 @@ type CloudController struct {
 	beego.Controller
 }
 
+// the JSON output of GET /cloud/:cloudName
+type SingleCloudResp struct {
+	CloudInfo models.CloudInfo `json:"cloudInfo"`
+	Vms       []models.IaasVm  `json:"vms"`
+}
+
 // ================== LIST TẤT CẢ CLOUDS (/cloud) ==================
 
 func (c *CloudController) Get() {
@@ -58,9 +67,12 @@ func (c *CloudController) Get() {
 
 // =============== SINGLE CLOUD (/cloud/:cloudName) =================
 
+// test command:
+// curl -i -X GET -H Accept:application/json http://localhost:20000/cloud/NOKIA7
 func (c *CloudController) GetSingleCloud() {
 	// Lấy tên cloud từ URL: /cloud/:cloudName
 	cloudName := c.Ctx.Input.Param(":cloudName")
+	acceptJson := strings.Contains(strings.ToLower(c.Ctx.Request.Header.Get("Accept")), JsonContentType)
 
 	// Lấy thông tin cloud + danh sách VM
 	cloudInfo, vmList, _, err := models.GetCloud(cloudName)
@@ -95,7 +107,83 @@ func (c *CloudController) GetSingleCloud() {
 		}
 	}
 
+	if acceptJson {
+		c.Data["json"] = SingleCloudResp{CloudInfo: cloudInfo, Vms: vmList}
+		c.ServeJSON()
+		return
+	}
+
 	c.Data["cloudInfo"] = cloudInfo
 	c.Data["vmList"] = vmList
 	c.TplName = "singleCloud.tpl"
 }
+
+// =============== CLOUD REGISTRY (POST/PUT/DELETE /cloud) =================
+
+// parse the declaration of a cloud in the request body, which is the same as an item of "iaas" in conf/iaas.json
+func (c *CloudController) parseCloudConfig() (map[string]interface{}, bool) {
+	var paras map[string]interface{}
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &paras); err != nil || paras == nil {
+		outErr := fmt.Errorf("json.Unmarshal the cloud in RequestBody, error: %v", err)
+		beego.Error(outErr)
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		c.Ctx.WriteString(outErr.Error())
+		return nil, false
+	}
+	return paras, true
+}
+
+// test command:
+// curl -i -X POST -H Content-Type:application/json -d '{"type":"fake","name":"FAKE2","vcpu":"32","ram":"65536","storage":"1000","vm":"20"}' http://localhost:20000/cloud
+func (c *CloudController) AddCloud() {
+	paras, ok := c.parseCloudConfig()
+	if !ok {
+		return
+	}
+
+	err, statusCode := models.AddCloud(paras)
+	if err != nil {
+		beego.Error(err)
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		c.Ctx.WriteString(err.Error())
+		return
+	}
+
+	c.Ctx.ResponseWriter.WriteHeader(statusCode)
+}
+
+// test command:
+// curl -i -X PUT -H Content-Type:application/json -d '{"type":"fake","name":"FAKE2","vcpu":"64","ram":"65536","storage":"1000","vm":"20"}' http://localhost:20000/cloud/FAKE2
+func (c *CloudController) UpdateCloud() {
+	cloudName := c.Ctx.Input.Param(":cloudName")
+	paras, ok := c.parseCloudConfig()
+	if !ok {
+		return
+	}
+
+	err, statusCode := models.UpdateCloud(cloudName, paras)
+	if err != nil {
+		beego.Error(err)
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		c.Ctx.WriteString(err.Error())
+		return
+	}
+
+	c.Ctx.ResponseWriter.WriteHeader(statusCode)
+}
+
+// test command:
+// curl -i -X DELETE http://localhost:20000/cloud/FAKE2
+func (c *CloudController) DeleteCloud() {
+	cloudName := c.Ctx.Input.Param(":cloudName")
+
+	err, statusCode := models.DeleteCloud(cloudName)
+	if err != nil {
+		beego.Error(err)
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		c.Ctx.WriteString(err.Error())
+		return
+	}
+
+	c.Ctx.ResponseWriter.WriteHeader(statusCode)
+}
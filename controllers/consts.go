@@ -1,5 +1,13 @@
 package controllers
 
+import "strings"
+
 const (
 	JsonContentType = "application/json"
+	YamlContentType = "application/yaml"
 )
+
+// "application/yaml", "application/x-yaml" and "text/yaml" are all used for YAML.
+func isYamlContentType(contentType string) bool {
+	return strings.Contains(strings.ToLower(contentType), "yaml")
+}
@@ -1,11 +1,13 @@
 package controllers
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/astaxie/beego"
-	"golang.org/x/crypto/ssh"
 
 	"emcontroller/models"
 )
@@ -14,114 +16,289 @@ type ImageController struct {
 	beego.Controller
 }
 
-func (c *ImageController) Get() {
-	repositories, err := models.GetCatalog()
-	if err != nil {
-		beego.Error(fmt.Sprintf("GetCatalog error: %s", err.Error()))
-	}
+// the images in one registry, for image.tpl
+type registryImages struct {
+	Registry models.ImageRegistryInfo
+	Images   map[string][]models.ImageManifest
+}
 
-	var repoTags map[string][]string = make(map[string][]string)
-	for _, repo := range repositories {
-		tags, err := models.ListTags(repo)
+func (c *ImageController) Get() {
+	var allRegistryImages []registryImages
+	for _, registry := range models.ListImageRegistries() {
+		repositories, err := registry.GetCatalog()
 		if err != nil {
-			beego.Error(fmt.Sprintf("Repository %s, ListTags error: %s", repo, err.Error()))
+			beego.Error(fmt.Sprintf("Registry %s, GetCatalog error: %s", registry.Name, err.Error()))
+		}
+
+		var repoTags map[string][]models.ImageManifest = make(map[string][]models.ImageManifest)
+		for _, repo := range repositories {
+			tags, err := registry.ListTags(repo)
+			if err != nil {
+				beego.Error(fmt.Sprintf("Registry %s, repository %s, ListTags error: %s", registry.Name, repo, err.Error()))
+			}
+			for _, tag := range tags {
+				manifest, err, _ := registry.GetManifest(repo, tag)
+				if err != nil {
+					beego.Error(fmt.Sprintf("Registry %s, repository %s, tag %s, GetManifest error: %s", registry.Name, repo, tag, err.Error()))
+					manifest = models.ImageManifest{Repository: repo, Tag: tag}
+				}
+				repoTags[repo] = append(repoTags[repo], manifest)
+			}
 		}
-		repoTags[repo] = tags
+		allRegistryImages = append(allRegistryImages, registryImages{Registry: registry.Info(), Images: repoTags})
 	}
 
-	c.Data["dockerRegistry"] = models.DockerRegistry
-	c.Data["imageList"] = repoTags
+	c.Data["registryImages"] = allRegistryImages
 	c.TplName = "image.tpl"
 }
 
-// DeleteRepo delete a repository
-func (c *ImageController) DeleteRepo() {
-	encodedRepo := c.Ctx.Input.Param(":repo")
+// ListRegistries lists the Docker Registries without their credentials.
+// test command:
+// curl -i -X GET http://localhost:20000/image/registries
+func (c *ImageController) ListRegistries() {
+	var infos []models.ImageRegistryInfo
+	for _, registry := range models.ListImageRegistries() {
+		infos = append(infos, registry.Info())
+	}
+	c.Ctx.Output.Status = http.StatusOK
+	c.Data["json"] = infos
+	c.ServeJSON()
+}
+
+// the registry chosen by the parameter "registry". Without it, the default registry is used.
+func (c *ImageController) imageRegistry() (*models.ImageRegistry, error) {
+	registryName := c.GetString("registry")
+	registry, found := models.GetImageRegistry(registryName)
+	if !found {
+		outErr := fmt.Errorf("Docker Registry [%s] is not found", registryName)
+		beego.Error(outErr)
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusNotFound)
+		c.Ctx.WriteString(outErr.Error())
+		return nil, outErr
+	}
+	return registry, nil
+}
 
-	// In the name of repository there may be the symbol '/', which should be encoded, or else HTTP can not split the URL correctly.
-	// Therefore, here we need to decode the repository name.
-	repo, err := url.QueryUnescape(encodedRepo)
+// In the name of repository there may be the symbol '/', which should be encoded, or else HTTP can not split the URL correctly.
+// Therefore, here we need to decode the repository name.
+func (c *ImageController) repoParam() (string, error) {
+	repo, err := url.QueryUnescape(c.Ctx.Input.Param(":repo"))
 	if err != nil {
-		beego.Error(fmt.Sprintf("Decode HTTP URL error: [%s]", err.Error()))
-		return
+		outErr := fmt.Errorf("Decode HTTP URL error: [%w]", err)
+		beego.Error(outErr)
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		c.Ctx.WriteString(outErr.Error())
+		return "", outErr
 	}
+	return repo, nil
+}
 
-	beego.Info(fmt.Sprintf("Delete repository [%s]", repo))
+// write the result of a deletion
+func (c *ImageController) writeResult(err error, statusCode int) {
+	c.Ctx.ResponseWriter.WriteHeader(statusCode)
+	if err != nil {
+		c.Ctx.WriteString(err.Error())
+	}
+}
 
-	// use ssh to delete repository on docker registry
-	dockerRegistryIP := beego.AppConfig.String("dockerRegistryIP")
-	sshPort := models.SshPort
-	sshUser := models.SshRootUser
-	sshPassword := beego.AppConfig.String("dockerRegiRootPasswd")
-	sshPrivateKey := beego.AppConfig.String("dockerRegiSshPrivateKey")
+// GetCatalog lists one page of the repositories. The "next" in the response is the "last" of the next page.
+// test command:
+// curl -i -X GET "http://localhost:20000/image/catalog?registry=default&n=100&last=helloworld"
+func (c *ImageController) GetCatalog() {
+	registry, err := c.imageRegistry()
+	if err != nil {
+		return
+	}
+	n, _ := c.GetInt("n", 0)
+	page, err := registry.GetCatalogPage(n, c.GetString("last"))
+	if err != nil {
+		beego.Error(fmt.Sprintf("GetCatalogPage error: %s", err.Error()))
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusBadGateway)
+		c.Ctx.WriteString(err.Error())
+		return
+	}
+	c.Ctx.Output.Status = http.StatusOK
+	c.Data["json"] = page
+	c.ServeJSON()
+}
 
-	var sshClient *ssh.Client
-	if len(sshPrivateKey) == 0 {
-		beego.Info("Config \"dockerRegiSshPrivateKey\" is not provided, so we use password to SSH.")
-		sshClient, err = models.SshClientWithPasswd(sshUser, sshPassword, dockerRegistryIP, sshPort)
-		if err != nil {
-			beego.Error(fmt.Sprintf("Create ssh client with password fail: error: %s", err.Error()))
-			return
-		}
-	} else {
-		beego.Info("Config \"dockerRegiSshPrivateKey\" is provided, so we use SSH key to SSH.")
-		sshClient, err = models.SshClientWithPem(sshPrivateKey, sshUser, dockerRegistryIP, sshPort)
-		if err != nil {
-			beego.Error(fmt.Sprintf("Create ssh client with SSH key fail: error: %s", err.Error()))
-			return
-		}
+// GetTag shows the digest and the size of the manifest of a tag.
+// test command:
+// curl -i -X GET "http://localhost:20000/image/helloworld12345/tag/latest?registry=default"
+func (c *ImageController) GetTag() {
+	registry, err := c.imageRegistry()
+	if err != nil {
+		return
 	}
-	defer sshClient.Close()
+	repo, err := c.repoParam()
+	if err != nil {
+		return
+	}
+	tag := c.Ctx.Input.Param(":tag")
+	manifest, err, statusCode := registry.GetManifest(repo, tag)
+	if err != nil {
+		c.writeResult(err, statusCode)
+		return
+	}
+	c.Ctx.Output.Status = http.StatusOK
+	c.Data["json"] = manifest
+	c.ServeJSON()
+}
 
-	// delete repository folder
-	if _, err := models.SshOneCommand(sshClient, fmt.Sprintf("docker exec registry rm -rf /var/lib/registry/docker/registry/v2/repositories/%s", repo)); err != nil {
-		beego.Error("ssh error: %s, exit", err.Error())
+// DeleteTag deletes a tag of a repository.
+// test command:
+// curl -i -X DELETE "http://localhost:20000/image/helloworld12345/tag/latest?registry=default"
+func (c *ImageController) DeleteTag() {
+	registry, err := c.imageRegistry()
+	if err != nil {
 		return
 	}
+	repo, err := c.repoParam()
+	if err != nil {
+		return
+	}
+	tag := c.Ctx.Input.Param(":tag")
+	beego.Info(fmt.Sprintf("Delete tag [%s:%s] in registry [%s]", repo, tag, registry.Name))
+	c.writeResult(registry.DeleteTag(repo, tag))
+}
 
-	// collect garbage
-	if _, err := models.SshOneCommand(sshClient, "docker exec registry bin/registry garbage-collect /etc/docker/registry/config.yml"); err != nil {
-		beego.Error("ssh error: %s, exit", err.Error())
+// DeleteManifest deletes a manifest by its digest, with all its tags.
+// test command:
+// curl -i -X DELETE "http://localhost:20000/image/helloworld12345/manifest/sha256:xxxxxx?registry=default"
+func (c *ImageController) DeleteManifest() {
+	registry, err := c.imageRegistry()
+	if err != nil {
+		return
+	}
+	repo, err := c.repoParam()
+	if err != nil {
 		return
 	}
+	digest := c.Ctx.Input.Param(":digest")
+	beego.Info(fmt.Sprintf("Delete manifest [%s@%s] in registry [%s]", repo, digest, registry.Name))
+	c.writeResult(registry.DeleteManifest(repo, digest))
+}
 
-	// restart docker
-	if _, err := models.SshOneCommand(sshClient, "docker restart registry"); err != nil {
-		beego.Error("ssh error: %s, exit", err.Error())
+// DeleteRepo delete a repository
+// test command:
+// curl -i -X DELETE "http://localhost:20000/image/helloworld12345?registry=default"
+func (c *ImageController) DeleteRepo() {
+	registry, err := c.imageRegistry()
+	if err != nil {
+		return
+	}
+	repo, err := c.repoParam()
+	if err != nil {
 		return
 	}
 
-	beego.Info(fmt.Sprintf("Successful! Delete repository [%s]", repo))
+	beego.Info(fmt.Sprintf("Delete repository [%s] in registry [%s]", repo, registry.Name))
+	err, statusCode := registry.DeleteRepository(repo)
+	if err == nil {
+		beego.Info(fmt.Sprintf("Successful! Delete repository [%s]", repo))
+	}
+	c.writeResult(err, statusCode)
+}
+
+// GarbageCollect frees the storage of the deleted images in the Docker Registry.
+// test command:
+// curl -i -X POST http://localhost:20000/image/gc
+func (c *ImageController) GarbageCollect() {
+	if err := models.RegistryGarbageCollect(); err != nil {
+		c.writeResult(err, http.StatusInternalServerError)
+		return
+	}
+	c.writeResult(nil, http.StatusOK)
+}
+
+// the ways to show the progress of uploading an image
+const (
+	uploadOutputHtml   = "html"   // render uploadSuccess.tpl after pushing
+	uploadOutputSse    = "sse"    // Server-Sent Events, used by image.js
+	uploadOutputNdjson = "ndjson" // one JSON object per line
+
+	SseContentType    = "text/event-stream"
+	NdjsonContentType = "application/x-ndjson"
+)
+
+// the last message of a streamed upload
+type uploadResult struct {
+	RepoTag string `json:"repoTag,omitempty"`
+	Digest  string `json:"digest,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+func (c *ImageController) uploadOutput() string {
+	accept := strings.ToLower(c.Ctx.Request.Header.Get("Accept"))
+	switch {
+	case strings.Contains(accept, SseContentType):
+		return uploadOutputSse
+	case strings.Contains(accept, NdjsonContentType), strings.Contains(accept, JsonContentType):
+		return uploadOutputNdjson
+	default:
+		return uploadOutputHtml
+	}
+}
+
+// write one message of a streamed upload and flush it to the client
+func (c *ImageController) writeStreamMsg(output string, event string, msg interface{}) {
+	msgJson, err := json.Marshal(msg)
+	if err != nil {
+		beego.Error(fmt.Sprintf("Marshal the upload message error: %s", err.Error()))
+		return
+	}
+	switch output {
+	case uploadOutputSse:
+		fmt.Fprintf(c.Ctx.ResponseWriter, "event: %s\ndata: %s\n\n", event, msgJson)
+	case uploadOutputNdjson:
+		fmt.Fprintf(c.Ctx.ResponseWriter, "%s\n", msgJson)
+	}
+	c.Ctx.ResponseWriter.Flush()
+}
 
-	c.Ctx.ResponseWriter.WriteHeader(200)
+// the error before pushing
+func (c *ImageController) uploadError(output string, statusCode int, err error) {
+	if output == uploadOutputHtml {
+		c.Ctx.Output.Header("Content-Type", "text/plain")
+	}
+	c.Ctx.ResponseWriter.WriteHeader(statusCode)
+	c.Ctx.WriteString(err.Error())
 }
 
+// Upload loads the image file into the Docker Engine, tags it, and pushes it to the chosen registry.
+// With the header "Accept: text/event-stream" or "Accept: application/x-ndjson", the progress of pushing is streamed to the client.
+// test command:
+// curl -N -X POST -H "Accept: application/x-ndjson" -F "imageFile=@helloworld.tar" -F "imageName=helloworld" -F "imageTag=latest" -F "registry=default" http://localhost:20000/upload
 func (c *ImageController) Upload() {
+	output := c.uploadOutput()
+
+	registryName := c.GetString("registry")
+	registry, found := models.GetImageRegistry(registryName)
+	if !found {
+		outErr := fmt.Errorf("Docker Registry [%s] is not found", registryName)
+		beego.Error(outErr)
+		c.uploadError(output, http.StatusBadRequest, outErr)
+		return
+	}
+
 	// get the file name
 	f, fileHead, err := c.GetFile("imageFile")
 	if err != nil {
-		beego.Error(fmt.Sprintf("Open file error: %s", err.Error()))
+		outErr := fmt.Errorf("Open file error: %w", err)
+		beego.Error(outErr)
+		c.uploadError(output, http.StatusBadRequest, outErr)
 		return
 	}
 	defer f.Close()
 	fileName := fileHead.Filename
 	beego.Info(fmt.Sprintf("filename: %s", fileName))
 
-	// It seems that we do not need to save file to the server
-	//// upload the file to server
-	//var filePath string = models.UploadDir + fileName
-	//err = c.SaveToFile("imageFile", filePath)
-	//if err != nil {
-	//	beego.Error(fmt.Sprintf("Upload file to server error: %s", err.Error()))
-	//	c.Ctx.WriteString(fmt.Sprintf("Upload file to server error: %s", err.Error()))
-	//	return
-	//}
-	//beego.Info(fmt.Sprintf("filename: %s, upload to the server successful.", fileName))
-
 	// load the image file to the docker engine
 	imageIdOrRepoTag, err := models.LoadImage(f)
 	if err != nil {
-		beego.Error(fmt.Sprintf("Load image error: %s", err.Error()))
+		outErr := fmt.Errorf("Load image error: %w", err)
+		beego.Error(outErr)
+		c.uploadError(output, http.StatusInternalServerError, outErr)
 		return
 	}
 	beego.Info(fmt.Sprintf("Load image to docker engine successfully, ID or RepoTag: %s", imageIdOrRepoTag))
@@ -129,20 +306,46 @@ func (c *ImageController) Upload() {
 	// add the tag to the image
 	imageName := c.GetString("imageName")
 	imageTag := c.GetString("imageTag")
-	beego.Info(fmt.Sprintf("Add %s a new tag, name: %s, tag: %s", imageIdOrRepoTag, imageName, imageTag))
-	repoTag, err := models.TagImage(imageIdOrRepoTag, imageName, imageTag)
+	beego.Info(fmt.Sprintf("Add %s a new tag, registry: %s, name: %s, tag: %s", imageIdOrRepoTag, registry.Name, imageName, imageTag))
+	repoTag, err := models.TagImage(imageIdOrRepoTag, registry, imageName, imageTag)
 	if err != nil {
-		beego.Error(fmt.Sprintf("Tag image error: %s", err.Error()))
+		outErr := fmt.Errorf("Tag image error: %w", err)
+		beego.Error(outErr)
+		c.uploadError(output, http.StatusBadRequest, outErr)
 		return
 	}
 
 	// push the image to the Docker Registry
-	resp, err := models.PushImage(repoTag)
+	if output == uploadOutputHtml {
+		digest, err := models.PushImage(c.Ctx.Request.Context(), repoTag, registry, nil)
+		if err != nil {
+			outErr := fmt.Errorf("Push image error: %w", err)
+			beego.Error(outErr)
+			c.uploadError(output, http.StatusBadGateway, outErr)
+			return
+		}
+		beego.Info(fmt.Sprintf("Push image successfully, digest: %s", digest))
+		c.TplName = "uploadSuccess.tpl"
+		return
+	}
+
+	// stream the progress. Once the stream starts, the status code is 200, and an error is in the last message.
+	c.EnableRender = false
+	if output == uploadOutputSse {
+		c.Ctx.Output.Header("Content-Type", SseContentType)
+		c.Ctx.Output.Header("Cache-Control", "no-cache")
+	} else {
+		c.Ctx.Output.Header("Content-Type", NdjsonContentType)
+	}
+	c.Ctx.ResponseWriter.WriteHeader(http.StatusOK)
+	digest, err := models.PushImage(c.Ctx.Request.Context(), repoTag, registry, func(progress models.PushProgress) {
+		c.writeStreamMsg(output, "progress", progress)
+	})
 	if err != nil {
 		beego.Error(fmt.Sprintf("Push image error: %s", err.Error()))
+		c.writeStreamMsg(output, "error", uploadResult{RepoTag: repoTag, Error: err.Error()})
 		return
 	}
-	beego.Info(fmt.Sprintf("Push image successfully, resp: %s", resp))
-
-	c.TplName = "uploadSuccess.tpl"
+	beego.Info(fmt.Sprintf("Push image successfully, digest: %s", digest))
+	c.writeStreamMsg(output, "done", uploadResult{RepoTag: repoTag, Digest: digest})
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"emcontroller/models"
 	"github.com/astaxie/beego"
@@ -79,3 +80,63 @@ func (c *NetStateController) GetForm() {
 	c.Data["NetTestFuncOn"] = models.NetTestFuncOn
 	c.TplName = "netState.tpl"
 }
+
+// the default time range of the network state history API when "from" is not set
+const defaultNetHistRange time.Duration = time.Hour
+
+// test command:
+// curl -i -X GET "http://172.27.15.31:20000/netState/history?from=2024-01-01T00:00:00Z&to=2024-01-02T00:00:00Z&src=NOKIA7&dst=NOKIA8"
+// "from" and "to" are in RFC3339 format, and "to" is now and "from" is 1 hour before "to" by default. "src" and "dst" are optional filters.
+func (c *NetStateController) GetHistory() {
+	if !models.NetTestFuncOn {
+		beego.Info(models.NetTestFuncOffMsg)
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusServiceUnavailable)
+		c.Ctx.WriteString(models.NetTestFuncOffMsg)
+		return
+	}
+
+	to := time.Now()
+	if toStr := c.GetString("to"); len(toStr) != 0 {
+		parsed, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			outErr := fmt.Errorf("Parse \"to\" [%s] in RFC3339 format, error: %w", toStr, err)
+			beego.Error(outErr)
+			c.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+			c.Ctx.WriteString(outErr.Error())
+			return
+		}
+		to = parsed
+	}
+	from := to.Add(-defaultNetHistRange)
+	if fromStr := c.GetString("from"); len(fromStr) != 0 {
+		parsed, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			outErr := fmt.Errorf("Parse \"from\" [%s] in RFC3339 format, error: %w", fromStr, err)
+			beego.Error(outErr)
+			c.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+			c.Ctx.WriteString(outErr.Error())
+			return
+		}
+		from = parsed
+	}
+	if from.After(to) {
+		outErr := fmt.Errorf("\"from\" [%s] is after \"to\" [%s].", from.Format(time.RFC3339), to.Format(time.RFC3339))
+		beego.Error(outErr)
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		c.Ctx.WriteString(outErr.Error())
+		return
+	}
+
+	samples, err := models.GetNetStateHistory(from, to, c.GetString("src"), c.GetString("dst"))
+	if err != nil {
+		outErr := fmt.Errorf("Check network state history from MySQL Error: %w", err)
+		beego.Error(outErr)
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+		c.Ctx.WriteString(outErr.Error())
+		return
+	}
+
+	c.Ctx.Output.Status = http.StatusOK
+	c.Data["json"] = samples
+	c.ServeJSON()
+}
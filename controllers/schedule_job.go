@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/astaxie/beego"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"emcontroller/auto-schedule/executors"
+	"emcontroller/models"
+)
+
+// ScheduleJobController is for the asynchronous jobs created by the auto-schedule function.
+type ScheduleJobController struct {
+	beego.Controller
+}
+
+// Under "/tenant/:tenant", only the jobs of the tenant are visible.
+func (c *ScheduleJobController) tenantOwnsJob(jobID string) (error, int) {
+	tenant := c.Ctx.Input.Param(":tenant")
+	if len(tenant) == 0 {
+		return nil, http.StatusOK
+	}
+	if err, statusCode := models.CheckTenant(tenant); err != nil {
+		return err, statusCode
+	}
+	if job, exist := executors.GetScheduleJob(jobID); exist && models.AppNamespace(job.Namespace) != tenant {
+		return fmt.Errorf("Scheduling job [%s] not found in tenant [%s]", jobID, tenant), http.StatusNotFound
+	}
+	return nil, http.StatusOK
+}
+
+// test command:
+// curl -i -X GET http://localhost:20000/scheduleJob
+// curl -i -X GET http://localhost:20000/tenant/team-a/scheduleJob
+func (c *ScheduleJobController) List() {
+	namespace := metav1.NamespaceAll
+	if tenant := c.Ctx.Input.Param(":tenant"); len(tenant) > 0 {
+		if err, statusCode := models.CheckTenant(tenant); err != nil {
+			c.Ctx.ResponseWriter.WriteHeader(statusCode)
+			c.Ctx.WriteString(err.Error())
+			return
+		}
+		namespace = tenant
+	}
+
+	c.Ctx.Output.Status = http.StatusOK
+	c.Data["json"] = executors.ListScheduleJobs(namespace)
+	c.ServeJSON()
+}
+
+// Get the phase, partial results, and final results of a scheduling job
+// test command:
+// curl -i -X GET http://localhost:20000/scheduleJob/sj-1700000000000000000-0a1b2c3d
+func (c *ScheduleJobController) Get() {
+	jobID := c.Ctx.Input.Param(":id")
+	if err, statusCode := c.tenantOwnsJob(jobID); err != nil {
+		beego.Error(err)
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		c.Ctx.WriteString(err.Error())
+		return
+	}
+
+	job, exist := executors.GetScheduleJob(jobID)
+	if !exist {
+		outErr := fmt.Errorf("Scheduling job [%s] not found", jobID)
+		beego.Error(outErr)
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusNotFound)
+		c.Ctx.WriteString(outErr.Error())
+		return
+	}
+
+	c.Ctx.Output.Status = http.StatusOK
+	c.Data["json"] = job
+	c.ServeJSON()
+}
+
+// Cancel a scheduling job
+// test command:
+// curl -i -X DELETE http://localhost:20000/scheduleJob/sj-1700000000000000000-0a1b2c3d
+func (c *ScheduleJobController) Cancel() {
+	jobID := c.Ctx.Input.Param(":id")
+	if err, statusCode := c.tenantOwnsJob(jobID); err != nil {
+		beego.Error(err)
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		c.Ctx.WriteString(err.Error())
+		return
+	}
+
+	job, err, statusCode := executors.CancelScheduleJob(jobID)
+	if err != nil {
+		beego.Error(err)
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		c.Ctx.WriteString(err.Error())
+		return
+	}
+
+	c.Ctx.Output.Status = statusCode
+	c.Data["json"] = job
+	c.ServeJSON()
+}
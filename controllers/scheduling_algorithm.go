@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/astaxie/beego"
+
+	"emcontroller/auto-schedule/algorithms"
+)
+
+// SchedulingAlgorithmController shows the algorithms that can be chosen by the header "Mcm-Scheduling-Algorithm", and the parameters that can be set by the header "Mcm-Scheduling-Params".
+type SchedulingAlgorithmController struct {
+	beego.Controller
+}
+
+// test command:
+// curl -i -X GET http://localhost:20000/schedulingAlgorithms
+func (c *SchedulingAlgorithmController) List() {
+	c.Ctx.Output.Status = http.StatusOK
+	c.Data["json"] = algorithms.ListAlgorithms()
+	c.ServeJSON()
+}
@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/context"
+
+	"emcontroller/models"
+)
+
+// The namespace of the applications in a request.
+// The routes under "/tenant/:tenant" are for the applications of a tenant, and the other routes are for the applications in the namespace in the query "namespace", which is KubernetesNamespace by default.
+func requestNamespace(ctx *context.Context) (string, error, int) {
+	if tenant := ctx.Input.Param(":tenant"); len(tenant) > 0 {
+		if err, statusCode := models.CheckTenant(tenant); err != nil {
+			return "", err, statusCode
+		}
+		return tenant, nil, http.StatusOK
+	}
+	return models.AppNamespace(ctx.Input.Query("namespace")), nil, http.StatusOK
+}
+
+// the path of the application APIs of a request, "/application" or "/tenant/:tenant/application"
+func applicationBasePath(ctx *context.Context) string {
+	if tenant := ctx.Input.Param(":tenant"); len(tenant) > 0 {
+		return "/tenant/" + tenant + "/application"
+	}
+	return "/application"
+}
+
+type TenantController struct {
+	beego.Controller
+}
+
+func (c *TenantController) writeError(err error, statusCode int) {
+	c.Ctx.ResponseWriter.WriteHeader(statusCode)
+	if result, err := c.Ctx.ResponseWriter.Write([]byte(err.Error())); err != nil {
+		beego.Error(fmt.Sprintf("Write Error to response, error: %s, result: %d", err.Error(), result))
+	}
+}
+
+// test command:
+// curl -i -X GET http://localhost:20000/tenant
+func (c *TenantController) List() {
+	tenants, err := models.ListTenants()
+	if err != nil {
+		c.writeError(err, http.StatusInternalServerError)
+		return
+	}
+	c.Ctx.Output.Status = http.StatusOK
+	c.Data["json"] = tenants
+	c.ServeJSON()
+}
+
+// test command:
+// curl -i -X GET http://localhost:20000/tenant/team-a
+func (c *TenantController) Get() {
+	tenant, err, statusCode := models.GetTenant(c.Ctx.Input.Param(":tenant"))
+	if err != nil {
+		c.writeError(err, statusCode)
+		return
+	}
+	c.Ctx.Output.Status = statusCode
+	c.Data["json"] = tenant
+	c.ServeJSON()
+}
+
+// Create a tenant, whose quota is optional.
+// test command:
+// curl -i -X POST -H Content-Type:application/json -d '{"name":"team-a","quota":{"cpu":"8","memory":"16Gi","storage":"100Gi","pods":"50"}}' http://localhost:20000/tenant
+func (c *TenantController) Create() {
+	var tenant models.Tenant
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &tenant); err != nil {
+		outErr := fmt.Errorf("json.Unmarshal the tenant in RequestBody, error: %w", err)
+		beego.Error(outErr)
+		c.writeError(outErr, http.StatusBadRequest)
+		return
+	}
+
+	if err, statusCode := models.CreateTenant(tenant); err != nil {
+		c.writeError(err, statusCode)
+		return
+	}
+
+	created, err, statusCode := models.GetTenant(tenant.Name)
+	if err != nil {
+		c.writeError(err, statusCode)
+		return
+	}
+	c.Ctx.Output.Status = http.StatusCreated
+	c.Data["json"] = created
+	c.ServeJSON()
+}
+
+// Set the quota of a tenant, and an empty quota removes it.
+// test command:
+// curl -i -X PUT -H Content-Type:application/json -d '{"cpu":"16","memory":"32Gi"}' http://localhost:20000/tenant/team-a/quota
+func (c *TenantController) UpdateQuota() {
+	tenantName := c.Ctx.Input.Param(":tenant")
+	var quota models.TenantQuota
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &quota); err != nil {
+		outErr := fmt.Errorf("json.Unmarshal the quota in RequestBody, error: %w", err)
+		beego.Error(outErr)
+		c.writeError(outErr, http.StatusBadRequest)
+		return
+	}
+
+	if err, statusCode := models.UpdateTenantQuota(tenantName, &quota); err != nil {
+		c.writeError(err, statusCode)
+		return
+	}
+
+	tenant, err, statusCode := models.GetTenant(tenantName)
+	if err != nil {
+		c.writeError(err, statusCode)
+		return
+	}
+	c.Ctx.Output.Status = http.StatusOK
+	c.Data["json"] = tenant
+	c.ServeJSON()
+}
+
+// Delete a tenant with all its applications.
+// test command:
+// curl -i -X DELETE http://localhost:20000/tenant/team-a
+func (c *TenantController) Delete() {
+	err, statusCode := models.DeleteTenant(c.Ctx.Input.Param(":tenant"))
+	if err != nil {
+		c.writeError(err, statusCode)
+		return
+	}
+	c.Ctx.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Put an application into the namespace of a request. The application in the request body can have its namespace, but under "/tenant/:tenant" it can only be the tenant.
+func setAppNamespace(ctx *context.Context, app *models.K8sApp) (error, int) {
+	namespace, err, statusCode := requestNamespace(ctx)
+	if err != nil {
+		return err, statusCode
+	}
+	if len(app.Namespace) == 0 {
+		app.Namespace = namespace
+		return nil, http.StatusOK
+	}
+	if tenant := ctx.Input.Param(":tenant"); len(tenant) > 0 && app.Namespace != tenant {
+		outErr := fmt.Errorf("application [%s] is in namespace [%s], but it is created for tenant [%s]", app.Name, app.Namespace, tenant)
+		beego.Error(outErr)
+		return outErr, http.StatusBadRequest
+	}
+	return nil, http.StatusOK
+}
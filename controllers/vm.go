@@ -20,7 +20,13 @@ func (c *VmController) DeleteVM() {
 	vmID := c.Ctx.Input.Param(":vmID")
 
 	beego.Info(fmt.Sprintf("Delete VM %s on cloud %s.", vmID, cloudName))
-	err := models.Clouds[cloudName].DeleteVM(vmID)
+	cloud, exist := models.GetIaas(cloudName)
+	if !exist {
+		beego.Error(fmt.Sprintf("Delete VM %s on cloud %s, error: cloud not found.", vmID, cloudName))
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+	err := cloud.DeleteVM(vmID)
 	if err != nil {
 		beego.Error(fmt.Sprintf("Delete VM %s on cloud %s, error: %s.", vmID, cloudName, err.Error()))
 		c.Ctx.ResponseWriter.WriteHeader(500)
@@ -70,7 +76,13 @@ func (c *VmController) GetVM() {
 	cloudName := c.Ctx.Input.Param(":cloudName")
 	vmID := c.Ctx.Input.Param(":vmID")
 
-	vm, err := models.Clouds[cloudName].GetVM(vmID)
+	cloud, exist := models.GetIaas(cloudName)
+	if !exist {
+		beego.Error(fmt.Sprintf("Get VM %s on cloud %s, error: cloud not found.", vmID, cloudName))
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+	vm, err := cloud.GetVM(vmID)
 	if err != nil {
 		beego.Error(fmt.Sprintf("Get VM %s on cloud %s, error: %s.", vmID, cloudName, err.Error()))
 		c.Ctx.ResponseWriter.WriteHeader(500)
@@ -81,6 +93,62 @@ func (c *VmController) GetVM() {
 	c.ServeJSON()
 }
 
+// list the actions that can be done on a VM
+// test command:
+// curl -i -X GET http://localhost:20000/cloud/NOKIA7/vm/102/actions
+func (c *VmController) ListVMActions() {
+	cloudName := c.Ctx.Input.Param(":cloudName")
+
+	cloud, exist := models.GetIaas(cloudName)
+	if !exist {
+		outErr := fmt.Errorf("cloud [%s] not found", cloudName)
+		beego.Error(outErr)
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusNotFound)
+		c.Ctx.WriteString(outErr.Error())
+		return
+	}
+
+	c.Ctx.Output.Status = http.StatusOK
+	c.Data["json"] = models.CloudVmActions(cloud)
+	c.ServeJSON()
+}
+
+// do an action on a VM, such as start, stop, reboot, resize and snapshot
+// test command:
+// curl -i -X POST -H Content-Type:application/json http://localhost:20000/cloud/NOKIA7/vm/102/actions -d '{"action":"resize","vcpu":4,"ram":8192}'
+// curl -i -X POST -H Content-Type:application/json http://localhost:20000/cloud/NOKIA7/vm/102/actions -d '{"action":"createSnapshot","snapshot":"before-upgrade"}'
+// curl -i -X POST -H Content-Type:application/json http://localhost:20000/cloud/NOKIA7/vm/102/actions -d '{"action":"reboot","force":true}'
+func (c *VmController) DoVMAction() {
+	cloudName := c.Ctx.Input.Param(":cloudName")
+	vmID := c.Ctx.Input.Param(":vmID")
+
+	var action models.VmAction
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &action); err != nil {
+		outErr := fmt.Errorf("json.Unmarshal the action in RequestBody, error: %w", err)
+		beego.Error(outErr)
+		c.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		c.Ctx.WriteString(outErr.Error())
+		return
+	}
+
+	beego.Info(fmt.Sprintf("Do action %+v on VM %s on cloud %s.", action, vmID, cloudName))
+	result, err, statusCode := models.DoVmAction(cloudName, vmID, action)
+	if err != nil {
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		c.Ctx.WriteString(err.Error())
+		return
+	}
+	beego.Info(fmt.Sprintf("Successful! Do action [%s] on VM %s on cloud %s.", action.Action, vmID, cloudName))
+
+	if result == nil {
+		c.Ctx.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	c.Ctx.Output.Status = statusCode
+	c.Data["json"] = result
+	c.ServeJSON()
+}
+
 func (c *VmController) CreateVM() {
 	cloudName := c.Ctx.Input.Param(":cloudName")
 
@@ -101,8 +169,13 @@ func (c *VmController) CreateVM() {
 		return
 	}
 
+	cloud, exist := models.GetIaas(cloudName)
+	if !exist {
+		beego.Error(fmt.Sprintf("Create vm error: cloud %s not found.", cloudName))
+		return
+	}
 	beego.Info(fmt.Sprintf("Start to create vm."))
-	createdVM, err := models.Clouds[cloudName].CreateVM(vmName, vcpu, ram, storage)
+	createdVM, err := cloud.CreateVM(vmName, vcpu, ram, storage)
 	if err != nil {
 		beego.Error(fmt.Sprintf("Create vm error %s.", err.Error()))
 		return
@@ -198,7 +271,7 @@ func (c *VmController) DoNewVmsForm() {
 			return
 		}
 		if vms[i].Storage, err = c.GetFloat(fmt.Sprintf("vm%dStorage", i)); err != nil {
-			outErr := fmt.Errorf("Get vms[%d].Storage, error: %w", err)
+			outErr := fmt.Errorf("Get vms[%d].Storage, error: %w", i, err)
 			beego.Error(outErr)
 			c.Data["errorMessage"] = outErr.Error()
 			c.TplName = "error.tpl"
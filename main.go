@@ -3,13 +3,17 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
+	"os"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/astaxie/beego"
 
 	"emcontroller/auto-schedule/algorithms"
+	"emcontroller/auto-schedule/executors"
 	"emcontroller/models"
 	_ "emcontroller/routers"
 )
@@ -20,22 +24,71 @@ func printVersion() {
 	fmt.Printf("Build time: [%s]. Git commit: [%s]\n", models.BuildDate, models.GitCommit)
 }
 
+// save a secret read from stdin in the encrypted secret file
+func setSecret(secretName string) error {
+	secretFile, err := models.ConfiguredSecretFile()
+	if err != nil {
+		return err
+	}
+	value, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("read stdin, error: %w", err)
+	}
+	return secretFile.SetSecret(secretName, strings.TrimRight(string(value), "\r\n"))
+}
+
 func main() {
 	models.BuildDate = buildDate
 	models.GitCommit = gitCommit
 
 	versionFlag := flag.Bool("v", false, "Print the current version and exit.")
+	setSecretFlag := flag.String("set-secret", "", "Read a value from stdin, save it as the secret with this name in the encrypted secret file, and exit.")
 	flag.Parse()
 	if *versionFlag {
 		printVersion()
 		return
 	}
+	if len(*setSecretFlag) > 0 {
+		if err := setSecret(*setSecretFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Set secret [%s], error: %s\n", *setSecretFlag, err.Error())
+			os.Exit(1)
+		}
+		fmt.Printf("Secret [%s] is saved. Reference it as \"%s%s\".\n", *setSecretFlag, models.SecretRefPrefix, *setSecretFlag)
+		return
+	}
 
 	// ===============================
 	// BỎ QUA INIT CLOUD / OPENSTACK
 	// ===============================
 	models.InitSomeThing()
 
+	// reload conf/iaas.json periodically, so that the clouds can be changed without restarting
+	iaasReloadSec, err := beego.AppConfig.Int("IaasReloadSec")
+	if err != nil {
+		beego.Info(fmt.Sprintf("Read config \"IaasReloadSec\" error: %s, set the period as the DefaultIaasReloadSec", err.Error()))
+		iaasReloadSec = models.DefaultIaasReloadSec
+	}
+	if iaasReloadSec > 0 {
+		beego.Info(fmt.Sprintf("The period of reloading the clouds in iaas.json is %d seconds.", iaasReloadSec))
+		go models.CronTaskTimer(models.ReloadClouds, time.Duration(iaasReloadSec)*time.Second)
+	} else {
+		beego.Info("Reloading the clouds in iaas.json is off.")
+	}
+
+	// the garbage collection of the Docker Registry is an optional maintenance task
+	if gcPeriodHour, err := beego.AppConfig.Int("dockerRegiGcPeriodHour"); err == nil && gcPeriodHour > 0 {
+		beego.Info(fmt.Sprintf("The period of the garbage collection of the Docker Registry is %d hours.", gcPeriodHour))
+		go models.CronTaskTimer(models.CronRegistryGarbageCollect, time.Duration(gcPeriodHour)*time.Hour)
+	} else {
+		beego.Info("The scheduled garbage collection of the Docker Registry is off.")
+	}
+
+	if err := executors.InitScheduleJobs(); err != nil {
+		outErr := fmt.Errorf("Initialize the scheduling jobs, error: [%w]", err)
+		beego.Error(outErr)
+		panic(outErr)
+	}
+
 	numCpuToUse := runtime.NumCPU()
 	beego.Info(fmt.Sprintf("Using %d CPU cores for goroutines.", numCpuToUse))
 	runtime.GOMAXPROCS(numCpuToUse)
@@ -43,7 +96,7 @@ func main() {
 	if netTestOn, err := beego.AppConfig.Bool("TurnOnNetTest"); err == nil && netTestOn {
 		beego.Info("Network performance test function is on.")
 		if err := models.InitNetPerfDB(); err != nil {
-			outErr := fmt.Errorf("Initialize the database [%s] in MySQL failed, error: [%w]", models.NetPerfDbName, err)
+			outErr := fmt.Errorf("Initialize the network state store failed, error: [%w]", err)
 			beego.Error(outErr)
 			panic(outErr)
 		}
@@ -0,0 +1,541 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/astaxie/beego"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+/**
+NOTE:
+
+The manifest of an application is its live deployment, service and PVCs, without the fields set by Kubernetes (status, uid, resourceVersion, clusterIP, etc.), so that it can be applied to another cluster or namespace.
+It is rendered as a multi-document YAML, or a JSON object of the kind "List" like "kubectl get -o json".
+
+A manifest (a multi-document YAML or a JSON "List") can be imported as applications for auto-scheduling, e.g., through "/doNewAppGroup":
+1. every Deployment is an application, whose name is the name of the Deployment without DeploymentSuffix;
+2. a Service belongs to the Deployment whose pod labels match its selector, and its ports are put into the ports of the containers;
+3. the placement (nodeName, nodeSelector and node affinity) is dropped, because auto-scheduling decides it;
+4. the hostPath, PVC, ConfigMap and Secret volumes are imported as mounts, and a PVC in the manifest gives the storage class, size and access mode to create it;
+5. the memory is converted to the unit "Mi" and the storage to "Gi", which auto-scheduling needs;
+6. the priority is read from the annotation PriorityAnno, and the dependencies from AutoScheduleInfoAnno, which are in the manifests exported from auto-scheduled applications.
+The fields that K8sApp cannot express are dropped with warnings, and the objects of other kinds are skipped with warnings.
+*/
+
+const (
+	ManifestFormatYaml string = "yaml"
+	ManifestFormatJson string = "json"
+
+	// the priority of an imported application without the annotation PriorityAnno, which is the lowest priority of auto-scheduling
+	ImportedAppPriority int = 1
+
+	pvcKind string = "PersistentVolumeClaim"
+)
+
+// the annotations set by Kubernetes or kubectl, which are not in the manifests
+var manifestSkippedAnnos = []string{
+	DeploymentRevisionAnno,
+	corev1.LastAppliedConfigAnnotation,
+	// set on PVCs when they are bound or provisioned
+	"pv.kubernetes.io/bind-completed",
+	"pv.kubernetes.io/bound-by-controller",
+	"volume.beta.kubernetes.io/storage-provisioner",
+	"volume.kubernetes.io/storage-provisioner",
+	"volume.kubernetes.io/selected-node",
+}
+
+// only keep the metadata set by users
+func manifestObjectMeta(meta metav1.ObjectMeta) metav1.ObjectMeta {
+	out := metav1.ObjectMeta{
+		Name:      meta.Name,
+		Namespace: meta.Namespace,
+		Labels:    meta.Labels,
+	}
+	for key, value := range meta.Annotations {
+		if stringInSlice(key, manifestSkippedAnnos) {
+			continue
+		}
+		if out.Annotations == nil {
+			out.Annotations = make(map[string]string)
+		}
+		out.Annotations[key] = value
+	}
+	return out
+}
+
+// the live deployment, service and PVCs of an application as manifests. The service is not included if the application does not have one.
+// The PVCs are before the deployment, because they should exist before the pods mount them.
+func appManifestObjects(namespace, appName string) ([]runtime.Object, error, int) {
+	deployName, svcName := appName+DeploymentSuffix, appName+ServiceSuffix
+	deployment, err := GetDeployment(namespace, deployName)
+	if err != nil {
+		outErr := fmt.Errorf("Get deployment %s/%s error: %w", namespace, deployName, err)
+		beego.Error(outErr)
+		return nil, outErr, http.StatusInternalServerError
+	}
+	if deployment == nil {
+		outErr := fmt.Errorf("Deployment %s/%s not found", namespace, deployName)
+		beego.Error(outErr)
+		return nil, outErr, http.StatusNotFound
+	}
+	service, err := GetService(namespace, svcName)
+	if err != nil {
+		outErr := fmt.Errorf("Get service %s/%s error: %w", namespace, svcName, err)
+		beego.Error(outErr)
+		return nil, outErr, http.StatusInternalServerError
+	}
+
+	var objects []runtime.Object
+	for _, volume := range deployment.Spec.Template.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+		claimName := volume.PersistentVolumeClaim.ClaimName
+		pvc, err := GetPvc(namespace, claimName)
+		if err != nil {
+			outErr := fmt.Errorf("Get PVC %s/%s error: %w", namespace, claimName, err)
+			beego.Error(outErr)
+			return nil, outErr, http.StatusInternalServerError
+		}
+		if pvc == nil {
+			beego.Warn(fmt.Sprintf("PVC %s/%s mounted by application [%s] is not found, so it is not in the manifest.", namespace, claimName, appName))
+			continue
+		}
+		// the PersistentVolume bound to the claim is in this cluster
+		spec := *pvc.Spec.DeepCopy()
+		spec.VolumeName = ""
+		objects = append(objects, &corev1.PersistentVolumeClaim{
+			TypeMeta:   metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: pvcKind},
+			ObjectMeta: manifestObjectMeta(pvc.ObjectMeta),
+			Spec:       spec,
+		})
+	}
+	objects = append(objects, &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: appsv1.SchemeGroupVersion.String(), Kind: deploymentKind},
+		ObjectMeta: manifestObjectMeta(deployment.ObjectMeta),
+		Spec:       deployment.Spec,
+	})
+	if service != nil {
+		// the cluster IPs are allocated by Kubernetes
+		spec := *service.Spec.DeepCopy()
+		spec.ClusterIP = ""
+		spec.ClusterIPs = nil
+		objects = append(objects, &corev1.Service{
+			TypeMeta:   metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Service"},
+			ObjectMeta: manifestObjectMeta(service.ObjectMeta),
+			Spec:       spec,
+		})
+	}
+	return objects, nil, http.StatusOK
+}
+
+// GetAppManifest renders the deployment, service and PVCs of an application in the format ManifestFormatYaml or ManifestFormatJson.
+func GetAppManifest(namespace, appName, format string) ([]byte, error, int) {
+	if format != ManifestFormatYaml && format != ManifestFormatJson {
+		outErr := fmt.Errorf("manifest format [%s] is not supported, it should be [%s] or [%s]", format, ManifestFormatYaml, ManifestFormatJson)
+		beego.Error(outErr)
+		return nil, outErr, http.StatusBadRequest
+	}
+
+	objects, err, statusCode := appManifestObjects(namespace, appName)
+	if err != nil {
+		return nil, err, statusCode
+	}
+
+	var out bytes.Buffer
+	switch format {
+	case ManifestFormatYaml:
+		for i, obj := range objects {
+			doc, err := yaml.Marshal(obj)
+			if err != nil {
+				outErr := fmt.Errorf("yaml.Marshal the manifest of application [%s/%s], error: %w", namespace, appName, err)
+				beego.Error(outErr)
+				return nil, outErr, http.StatusInternalServerError
+			}
+			if i > 0 {
+				out.WriteString("---\n")
+			}
+			out.Write(doc)
+		}
+	case ManifestFormatJson:
+		list := corev1.List{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "List"}}
+		for _, obj := range objects {
+			raw, err := json.Marshal(obj)
+			if err != nil {
+				outErr := fmt.Errorf("json.Marshal the manifest of application [%s/%s], error: %w", namespace, appName, err)
+				beego.Error(outErr)
+				return nil, outErr, http.StatusInternalServerError
+			}
+			list.Items = append(list.Items, runtime.RawExtension{Raw: raw})
+		}
+		listJson, err := json.MarshalIndent(list, "", "    ")
+		if err != nil {
+			outErr := fmt.Errorf("json.Marshal the manifest of application [%s/%s], error: %w", namespace, appName, err)
+			beego.Error(outErr)
+			return nil, outErr, http.StatusInternalServerError
+		}
+		out.Write(listJson)
+	}
+	return out.Bytes(), nil, http.StatusOK
+}
+
+// split a manifest to the JSON of its objects. The objects in a "List" are split too.
+func splitManifest(data []byte) ([][]byte, error) {
+	var objects [][]byte
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read a YAML document, error: %w", err)
+		}
+		doc, err = yaml.YAMLToJSON(doc)
+		if err != nil {
+			return nil, fmt.Errorf("convert a YAML document to JSON, error: %w", err)
+		}
+		if trimmed := bytes.TrimSpace(doc); len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+			continue // an empty document
+		}
+
+		var typeMeta metav1.TypeMeta
+		if err := json.Unmarshal(doc, &typeMeta); err != nil {
+			return nil, fmt.Errorf("get the kind of object [%s], error: %w", string(doc), err)
+		}
+		if typeMeta.Kind != "List" {
+			objects = append(objects, doc)
+			continue
+		}
+		var list corev1.List
+		if err := json.Unmarshal(doc, &list); err != nil {
+			return nil, fmt.Errorf("unmarshal a List, error: %w", err)
+		}
+		for _, item := range list.Items {
+			objects = append(objects, item.Raw)
+		}
+	}
+	return objects, nil
+}
+
+// a quantity in a unit, rounded up, e.g., "1Gi" in "Mi" is "1024Mi".
+func quantityInUnit(q resource.Quantity, unitBytes int64, suffix string) string {
+	value := q.Value()
+	units := value / unitBytes
+	if value%unitBytes != 0 {
+		units++
+	}
+	return strconv.FormatInt(units, 10) + suffix
+}
+
+// the resources of a container in K8sResList, with the units that auto-scheduling needs
+func manifestResList(list corev1.ResourceList) K8sResList {
+	var out K8sResList
+	if cpu, exist := list[corev1.ResourceCPU]; exist {
+		out.CPU = cpu.String()
+	}
+	if memory, exist := list[corev1.ResourceMemory]; exist {
+		out.Memory = quantityInUnit(memory, 1<<20, "Mi")
+	}
+	if storage, exist := list[corev1.ResourceEphemeralStorage]; exist {
+		out.Storage = quantityInUnit(storage, 1<<30, "Gi")
+	}
+	return out
+}
+
+// whether the selector of a service selects the pods with these labels
+func selectorMatches(selector, podLabels map[string]string) bool {
+	if len(selector) == 0 {
+		return false
+	}
+	for key, value := range selector {
+		if podLabels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// the K8sPvc of a claim mounted by a Deployment. If the claim is in the manifest, it gives the storage class, size and access mode to create the claim.
+func manifestPvc(claimName string, pvcs []corev1.PersistentVolumeClaim) (K8sPvc, bool) {
+	for _, pvc := range pvcs {
+		if pvc.Name != claimName {
+			continue
+		}
+		out := K8sPvc{Name: claimName}
+		if pvc.Spec.StorageClassName != nil {
+			out.StorageClass = *pvc.Spec.StorageClassName
+		}
+		if size, exist := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; exist {
+			out.Size = size.String()
+		}
+		if len(pvc.Spec.AccessModes) > 0 {
+			out.AccessMode = string(pvc.Spec.AccessModes[0])
+		}
+		return out, true
+	}
+	return K8sPvc{Name: claimName}, false
+}
+
+// whether the files of a ConfigMap or Secret volume are mounted in the way of K8sMount, i.e., all keys with the default mode
+func manifestDefaultFiles(items []corev1.KeyToPath, defaultMode *int32) bool {
+	return len(items) == 0 && (defaultMode == nil || *defaultMode == volumeDefaultMode)
+}
+
+// convert a Deployment and its Services and PVCs to an application for auto-scheduling, and return the warnings about the fields dropped.
+func manifestApp(d appsv1.Deployment, services []corev1.Service, pvcs []corev1.PersistentVolumeClaim) (K8sApp, []string) {
+	var warnings []string
+	warn := func(format string, a ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf("deployment [%s]: ", d.Name)+fmt.Sprintf(format, a...))
+	}
+
+	app := K8sApp{
+		Name:          strings.TrimSuffix(d.Name, DeploymentSuffix),
+		Namespace:     d.Namespace,
+		Replicas:      int32Value(d.Spec.Replicas),
+		AutoScheduled: true,
+		Priority:      ImportedAppPriority,
+	}
+	podSpec := d.Spec.Template.Spec
+	app.HostNetwork = podSpec.HostNetwork
+	app.Tolerations = podSpec.Tolerations
+
+	if priority, exist := d.Annotations[PriorityAnno]; exist {
+		if p, err := strconv.Atoi(priority); err != nil {
+			warn("annotation [%s] is [%s], not an integer, so the priority is [%d]", PriorityAnno, priority, ImportedAppPriority)
+		} else {
+			app.Priority = p
+		}
+	}
+	if info, exist := d.Annotations[AutoScheduleInfoAnno]; exist {
+		// only the fields in K8sApp are read from the auto-scheduling information
+		var scheduleInfo struct {
+			Dependencies  []Dependency `json:"dependencies"`
+			ReplicaSpread string       `json:"replicaSpread,omitempty"`
+		}
+		if err := json.Unmarshal([]byte(info), &scheduleInfo); err != nil {
+			warn("annotation [%s] cannot be parsed, so the dependencies are dropped, error: %s", AutoScheduleInfoAnno, err.Error())
+		} else {
+			app.Dependencies = scheduleInfo.Dependencies
+			app.ReplicaSpread = scheduleInfo.ReplicaSpread
+		}
+	}
+
+	if len(podSpec.NodeName) > 0 || len(podSpec.NodeSelector) > 0 || (podSpec.Affinity != nil && podSpec.Affinity.NodeAffinity != nil) {
+		warn("nodeName, nodeSelector and node affinity are dropped, because the nodes are chosen by auto-scheduling")
+	}
+	if podSpec.Affinity != nil && podSpec.Affinity.PodAffinity != nil {
+		warn("pod affinity is dropped")
+	}
+	if len(podSpec.InitContainers) > 0 {
+		warn("init containers are dropped")
+	}
+
+	// the volumes that K8sApp can mount, key: volume name
+	volumeMounts := make(map[string]K8sMount)
+	for _, volume := range podSpec.Volumes {
+		switch {
+		case volume.HostPath != nil:
+			volumeMounts[volume.Name] = K8sMount{VmPath: volume.HostPath.Path}
+		case volume.PersistentVolumeClaim != nil:
+			pvc, found := manifestPvc(volume.PersistentVolumeClaim.ClaimName, pvcs)
+			if !found {
+				warn("PVC [%s] is not in the manifest, so it should exist before the application is created", pvc.Name)
+			}
+			volumeMounts[volume.Name] = K8sMount{Pvc: &pvc}
+		case volume.ConfigMap != nil:
+			if !manifestDefaultFiles(volume.ConfigMap.Items, volume.ConfigMap.DefaultMode) {
+				warn("the items and defaultMode of ConfigMap volume [%s] are dropped", volume.Name)
+			}
+			volumeMounts[volume.Name] = K8sMount{ConfigMap: volume.ConfigMap.Name}
+		case volume.Secret != nil:
+			if !manifestDefaultFiles(volume.Secret.Items, volume.Secret.DefaultMode) {
+				warn("the items and defaultMode of Secret volume [%s] are dropped", volume.Name)
+			}
+			volumeMounts[volume.Name] = K8sMount{Secret: volume.Secret.SecretName}
+		default:
+			warn("volume [%s] is dropped, because it is not a hostPath, PVC, ConfigMap or Secret volume", volume.Name)
+		}
+	}
+
+	for _, c := range podSpec.Containers {
+		container := K8sContainer{
+			Name:     c.Name,
+			Image:    c.Image,
+			WorkDir:  c.WorkingDir,
+			Commands: c.Command,
+			Args:     c.Args,
+			Resources: K8sResReq{
+				Limits:   manifestResList(c.Resources.Limits),
+				Requests: manifestResList(c.Resources.Requests),
+			},
+		}
+		for _, env := range c.Env {
+			if env.ValueFrom != nil {
+				warn("container [%s] env [%s] is dropped, because it is not a value", c.Name, env.Name)
+				continue
+			}
+			container.Env = append(container.Env, K8sEnv{Name: env.Name, Value: env.Value})
+		}
+		if len(c.EnvFrom) > 0 {
+			warn("container [%s] envFrom is dropped", c.Name)
+		}
+		for _, mount := range c.VolumeMounts {
+			if volumeMount, exist := volumeMounts[mount.Name]; exist {
+				volumeMount.ContainerPath = mount.MountPath
+				volumeMount.SubPath = mount.SubPath
+				volumeMount.ReadOnly = mount.ReadOnly
+				container.Mounts = append(container.Mounts, volumeMount)
+			}
+		}
+		if c.LivenessProbe != nil || c.ReadinessProbe != nil || c.StartupProbe != nil {
+			warn("container [%s] probes are dropped", c.Name)
+		}
+		for _, port := range c.Ports {
+			container.Ports = append(container.Ports, PortInfo{
+				ContainerPort: int(port.ContainerPort),
+				Name:          port.Name,
+				Protocol:      strings.ToLower(string(port.Protocol)),
+			})
+		}
+		app.Containers = append(app.Containers, container)
+	}
+
+	// put the ports of the services into the container ports that they target
+	for _, svc := range services {
+		if svc.Spec.Type != "" && svc.Spec.Type != corev1.ServiceTypeClusterIP && svc.Spec.Type != corev1.ServiceTypeNodePort {
+			warn("service [%s] is of type [%s], and it is imported as [%s]", svc.Name, svc.Spec.Type, corev1.ServiceTypeNodePort)
+		}
+		for _, svcPort := range svc.Spec.Ports {
+			target := svcPort.TargetPort
+			if target.Type == intstr.Int && target.IntVal == 0 {
+				target = intstr.FromInt(int(svcPort.Port)) // without targetPort, the target is the same as the port
+			}
+			var found bool
+			for i := range app.Containers {
+				for j := range app.Containers[i].Ports {
+					port := &app.Containers[i].Ports[j]
+					if (target.Type == intstr.Int && port.ContainerPort == target.IntValue()) || (target.Type == intstr.String && port.Name == target.StrVal) {
+						port.ServicePort = strconv.Itoa(int(svcPort.Port))
+						if svcPort.NodePort != 0 {
+							port.NodePort = strconv.Itoa(int(svcPort.NodePort))
+						}
+						found = true
+						break
+					}
+				}
+				if found {
+					break
+				}
+			}
+			if !found {
+				warn("port [%d] of service [%s] is dropped, because no container port is its target [%s]", svcPort.Port, svc.Name, target.String())
+			}
+		}
+	}
+
+	return app, warnings
+}
+
+// AutoScheduleAppsFromManifest converts the Deployments, Services and PVCs in a manifest to applications for auto-scheduling, and returns the warnings about the fields and objects dropped.
+func AutoScheduleAppsFromManifest(data []byte) ([]K8sApp, []string, error) {
+	objects, err := splitManifest(data)
+	if err != nil {
+		outErr := fmt.Errorf("Split the manifest, error: %w", err)
+		beego.Error(outErr)
+		return nil, nil, outErr
+	}
+
+	var deployments []appsv1.Deployment
+	var services []corev1.Service
+	var pvcs []corev1.PersistentVolumeClaim
+	var warnings []string
+	for _, obj := range objects {
+		var typeMeta metav1.TypeMeta
+		if err := json.Unmarshal(obj, &typeMeta); err != nil {
+			outErr := fmt.Errorf("Get the kind of object [%s], error: %w", string(obj), err)
+			beego.Error(outErr)
+			return nil, nil, outErr
+		}
+		switch typeMeta.Kind {
+		case deploymentKind:
+			var d appsv1.Deployment
+			if err := json.Unmarshal(obj, &d); err != nil {
+				outErr := fmt.Errorf("Unmarshal a Deployment, error: %w", err)
+				beego.Error(outErr)
+				return nil, nil, outErr
+			}
+			deployments = append(deployments, d)
+		case "Service":
+			var s corev1.Service
+			if err := json.Unmarshal(obj, &s); err != nil {
+				outErr := fmt.Errorf("Unmarshal a Service, error: %w", err)
+				beego.Error(outErr)
+				return nil, nil, outErr
+			}
+			services = append(services, s)
+		case pvcKind:
+			var pvc corev1.PersistentVolumeClaim
+			if err := json.Unmarshal(obj, &pvc); err != nil {
+				outErr := fmt.Errorf("Unmarshal a PersistentVolumeClaim, error: %w", err)
+				beego.Error(outErr)
+				return nil, nil, outErr
+			}
+			pvcs = append(pvcs, pvc)
+		default:
+			warnings = append(warnings, fmt.Sprintf("object of kind [%s] is skipped, only Deployments, Services and PersistentVolumeClaims are imported", typeMeta.Kind))
+		}
+	}
+	if len(deployments) == 0 {
+		outErr := fmt.Errorf("There is no Deployment in the manifest")
+		beego.Error(outErr)
+		return nil, warnings, outErr
+	}
+
+	var apps []K8sApp
+	usedServices := make(map[int]bool)
+	for _, d := range deployments {
+		var appServices []corev1.Service
+		for i, s := range services {
+			if s.Namespace == d.Namespace && selectorMatches(s.Spec.Selector, d.Spec.Template.Labels) {
+				appServices = append(appServices, s)
+				usedServices[i] = true
+			}
+		}
+		var nsPvcs []corev1.PersistentVolumeClaim
+		for _, pvc := range pvcs {
+			if pvc.Namespace == d.Namespace {
+				nsPvcs = append(nsPvcs, pvc)
+			}
+		}
+		app, appWarnings := manifestApp(d, appServices, nsPvcs)
+		apps = append(apps, app)
+		warnings = append(warnings, appWarnings...)
+	}
+	for i, s := range services {
+		if !usedServices[i] {
+			warnings = append(warnings, fmt.Sprintf("service [%s] is skipped, because it does not select the pods of any Deployment", s.Name))
+		}
+	}
+
+	for _, warning := range warnings {
+		beego.Warn(fmt.Sprintf("Import manifest: %s", warning))
+	}
+	return apps, warnings, nil
+}
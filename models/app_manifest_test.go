@@ -0,0 +1,194 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAppManifest(t *testing.T) {
+	simulator := useSimulatedK8s(t)
+	assert.Nil(t, AddNode(IaasVm{Name: "n1", IPs: []string{"10.0.0.1"}, VCpu: 4, Ram: 8192, Storage: 50}, ""))
+
+	app := K8sApp{
+		Name:          "web",
+		Replicas:      1,
+		AutoScheduled: true,
+		Priority:      5,
+		Containers: []K8sContainer{
+			{
+				Name:  "nginx",
+				Image: "nginx:1.25",
+				Resources: K8sResReq{
+					Limits:   K8sResList{CPU: "1", Memory: "100Mi", Storage: "1Gi"},
+					Requests: K8sResList{CPU: "1", Memory: "100Mi", Storage: "1Gi"},
+				},
+				Env: []K8sEnv{{Name: "MODE", Value: "test"}},
+				Mounts: []K8sMount{
+					{VmPath: "/tmp/web", ContainerPath: "/data"},
+					{Pvc: &K8sPvc{Name: "web-data", StorageClass: "nfs", Size: "5Gi", AccessMode: "ReadWriteOnce"}, ContainerPath: "/var/lib/web", SubPath: "web"},
+					{ConfigMap: "web-conf", ContainerPath: "/etc/web", ReadOnly: true},
+				},
+				Ports: []PortInfo{{ContainerPort: 80, Name: "http", Protocol: "tcp", ServicePort: "8080", NodePort: "30080"}},
+			},
+		},
+	}
+	assert.Nil(t, CreateApplication(app))
+	assert.Nil(t, simulator.SyncOnce())
+
+	testCases := []struct {
+		name               string
+		appName            string
+		format             string
+		expectedStatusCode int
+	}{
+		{name: "yaml", appName: "web", format: ManifestFormatYaml, expectedStatusCode: http.StatusOK},
+		{name: "json", appName: "web", format: ManifestFormatJson, expectedStatusCode: http.StatusOK},
+		{name: "unknown format", appName: "web", format: "xml", expectedStatusCode: http.StatusBadRequest},
+		{name: "not found", appName: "app2", format: ManifestFormatYaml, expectedStatusCode: http.StatusNotFound},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		manifest, err, statusCode := GetAppManifest(KubernetesNamespace, testCase.appName, testCase.format)
+		assert.Equal(t, testCase.expectedStatusCode, statusCode, fmt.Sprintf("%s: status code is not expected, error: %v", testCase.name, err))
+		if statusCode != http.StatusOK {
+			continue
+		}
+		// the fields set by Kubernetes are not in the manifest
+		assert.NotContains(t, string(manifest), DeploymentRevisionAnno, testCase.name)
+		assert.NotContains(t, string(manifest), "clusterIP", testCase.name)
+		// the PVC is in the manifest, so that it can be created with the application
+		assert.Contains(t, string(manifest), "PersistentVolumeClaim", testCase.name)
+
+		// a manifest exported can be imported as the same application
+		apps, warnings, err := AutoScheduleAppsFromManifest(manifest)
+		assert.Nil(t, err, testCase.name)
+		assert.Empty(t, warnings, testCase.name)
+		expectedApp := app
+		expectedApp.Namespace = KubernetesNamespace
+		assert.Equal(t, []K8sApp{expectedApp}, apps, fmt.Sprintf("%s: imported applications are not expected", testCase.name))
+	}
+}
+
+func TestAutoScheduleAppsFromManifest(t *testing.T) {
+	manifest := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+  annotations:
+    auto-schedule/info: '{"name":"api","priority":3,"dependencies":[{"appName":"db"}]}'
+    priority: "3"
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      app: api
+  template:
+    metadata:
+      labels:
+        app: api
+        tier: backend
+    spec:
+      nodeSelector:
+        disk: ssd
+      containers:
+      - name: api
+        image: example/api:v2
+        envFrom:
+        - configMapRef:
+            name: api-config
+        env:
+        - name: LEVEL
+          value: debug
+        - name: PASSWORD
+          valueFrom:
+            secretKeyRef:
+              name: api-secret
+              key: password
+        ports:
+        - name: http
+          containerPort: 8080
+        resources:
+          requests:
+            cpu: "2"
+            memory: 1Gi
+            ephemeral-storage: 1500Mi
+          limits:
+            cpu: "2"
+            memory: 1Gi
+            ephemeral-storage: 1500Mi
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: api
+spec:
+  selector:
+    app: api
+  ports:
+  - port: 80
+    targetPort: http
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: api-config
+data:
+  key: value
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: other
+spec:
+  selector:
+    app: other
+  ports:
+  - port: 80
+`
+
+	apps, warnings, err := AutoScheduleAppsFromManifest([]byte(manifest))
+	assert.Nil(t, err)
+	expectedApps := []K8sApp{
+		{
+			Name:          "api",
+			Replicas:      2,
+			AutoScheduled: true,
+			Priority:      3,
+			Dependencies:  []Dependency{{AppName: "db"}},
+			Containers: []K8sContainer{
+				{
+					Name:  "api",
+					Image: "example/api:v2",
+					Resources: K8sResReq{
+						Limits:   K8sResList{CPU: "2", Memory: "1024Mi", Storage: "2Gi"},
+						Requests: K8sResList{CPU: "2", Memory: "1024Mi", Storage: "2Gi"},
+					},
+					Env:   []K8sEnv{{Name: "LEVEL", Value: "debug"}},
+					Ports: []PortInfo{{ContainerPort: 8080, Name: "http", ServicePort: "80"}},
+				},
+			},
+		},
+	}
+	assert.Equal(t, expectedApps, apps)
+	// nodeSelector, secret env, envFrom, ConfigMap, and the service of another application
+	assert.Len(t, warnings, 5, strings.Join(warnings, "\n"))
+
+	testCases := []struct {
+		name     string
+		manifest string
+	}{
+		{name: "no deployment", manifest: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: c\n"},
+		{name: "invalid yaml", manifest: "kind: [Deployment\n"},
+	}
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		_, _, err := AutoScheduleAppsFromManifest([]byte(testCase.manifest))
+		assert.NotNil(t, err, testCase.name)
+	}
+}
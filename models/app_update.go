@@ -0,0 +1,506 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/astaxie/beego"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+/**
+NOTE:
+
+An application is updated in place, without deleting it:
+1. the new K8sApp is converted to a deployment and a service in the same way as CreateApplication;
+2. they are compared with the live deployment and service, and only the fields set by multi-cloud manager are compared, because Kubernetes adds default values to the live objects;
+3. if something is different, the live objects are updated, and Kubernetes replaces the pods by a rolling update, which is seamless because of the RollingUpdate strategy and the preStop hook set in CreateApplication.
+Every pod template of a deployment is kept by Kubernetes in a ReplicaSet with a revision number, so an application can be rolled back to the pod template of its previous revision, like "kubectl rollout undo".
+Only the pod template is rolled back, and the replicas and the service are not changed.
+*/
+
+// the annotation of the revision of deployments and ReplicaSets, set by the deployment controller of Kubernetes
+const DeploymentRevisionAnno string = "deployment.kubernetes.io/revision"
+
+// the annotations of a deployment managed by multi-cloud manager
+var appDeployAnnos = []string{AutoScheduledAnno, PriorityAnno, AutoScheduleInfoAnno}
+
+type AppUpdateResult struct {
+	Changes []string `json:"changes"` // the changes of the application, empty if nothing is changed
+	App     AppInfo  `json:"app"`
+}
+
+func int32Value(p *int32) int32 {
+	if p == nil {
+		return 1 // the default replicas of a deployment
+	}
+	return *p
+}
+
+// the revision of a deployment or a ReplicaSet, 0 if it does not have a valid one.
+func revisionOf(obj metav1.Object) int64 {
+	revision, err := strconv.ParseInt(obj.GetAnnotations()[DeploymentRevisionAnno], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return revision
+}
+
+// the container ports with the default protocol set, so that we can compare the ports in a new deployment with the ones in the live deployment.
+func containerPortsWithProtocol(ports []corev1.ContainerPort) []corev1.ContainerPort {
+	var out []corev1.ContainerPort
+	for _, port := range ports {
+		if len(port.Protocol) == 0 {
+			port.Protocol = corev1.ProtocolTCP
+		}
+		out = append(out, port)
+	}
+	return out
+}
+
+// the changes from a live deployment to a new deployment generated by genAppObjects
+func appDeployChanges(live, desired *appsv1.Deployment) []string {
+	var changes []string
+
+	if int32Value(live.Spec.Replicas) != int32Value(desired.Spec.Replicas) {
+		changes = append(changes, fmt.Sprintf("replicas: %d -> %d", int32Value(live.Spec.Replicas), int32Value(desired.Spec.Replicas)))
+	}
+	if !equality.Semantic.DeepEqual(live.Spec.Strategy, desired.Spec.Strategy) {
+		changes = append(changes, "rolling update strategy changed")
+	}
+	for _, key := range appDeployAnnos {
+		if live.Annotations[key] != desired.Annotations[key] {
+			changes = append(changes, fmt.Sprintf("annotation [%s]: [%s] -> [%s]", key, live.Annotations[key], desired.Annotations[key]))
+		}
+	}
+
+	liveSpec, desiredSpec := live.Spec.Template.Spec, desired.Spec.Template.Spec
+	var liveContainerNames, desiredContainerNames []string
+	for _, container := range liveSpec.Containers {
+		liveContainerNames = append(liveContainerNames, container.Name)
+	}
+	for _, container := range desiredSpec.Containers {
+		desiredContainerNames = append(desiredContainerNames, container.Name)
+	}
+	if !equality.Semantic.DeepEqual(liveContainerNames, desiredContainerNames) {
+		changes = append(changes, fmt.Sprintf("containers: %v -> %v", liveContainerNames, desiredContainerNames))
+	} else {
+		for i := range desiredSpec.Containers {
+			lc, dc := liveSpec.Containers[i], desiredSpec.Containers[i]
+			if lc.Image != dc.Image {
+				changes = append(changes, fmt.Sprintf("container [%s] image: [%s] -> [%s]", dc.Name, lc.Image, dc.Image))
+			}
+			fields := []struct {
+				name     string
+				old, new interface{}
+			}{
+				{name: "workDir", old: lc.WorkingDir, new: dc.WorkingDir},
+				{name: "commands", old: lc.Command, new: dc.Command},
+				{name: "args", old: lc.Args, new: dc.Args},
+				{name: "env", old: lc.Env, new: dc.Env},
+				{name: "resources", old: lc.Resources, new: dc.Resources},
+				{name: "mounts", old: lc.VolumeMounts, new: dc.VolumeMounts},
+				{name: "ports", old: containerPortsWithProtocol(lc.Ports), new: containerPortsWithProtocol(dc.Ports)},
+			}
+			for _, field := range fields {
+				if !equality.Semantic.DeepEqual(field.old, field.new) {
+					changes = append(changes, fmt.Sprintf("container [%s] %s changed", dc.Name, field.name))
+				}
+			}
+		}
+	}
+
+	podFields := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{name: "hostNetwork", old: liveSpec.HostNetwork, new: desiredSpec.HostNetwork},
+		{name: "nodeName", old: liveSpec.NodeName, new: desiredSpec.NodeName},
+		{name: "nodeSelector", old: liveSpec.NodeSelector, new: desiredSpec.NodeSelector},
+		{name: "tolerations", old: liveSpec.Tolerations, new: desiredSpec.Tolerations},
+		{name: "affinity", old: liveSpec.Affinity, new: desiredSpec.Affinity},
+		{name: "volumes", old: liveSpec.Volumes, new: desiredSpec.Volumes},
+		{name: "imagePullSecrets", old: liveSpec.ImagePullSecrets, new: desiredSpec.ImagePullSecrets},
+	}
+	for _, field := range podFields {
+		if !equality.Semantic.DeepEqual(field.old, field.new) {
+			changes = append(changes, fmt.Sprintf("%s changed", field.name))
+		}
+	}
+
+	return changes
+}
+
+// the ports of a service that we compare, without the fields set by Kubernetes
+type appSvcPort struct {
+	Name       string
+	Protocol   corev1.Protocol
+	Port       int32
+	TargetPort string
+	NodePort   int32
+}
+
+func appSvcPorts(ports []corev1.ServicePort) []appSvcPort {
+	var out []appSvcPort
+	for _, port := range ports {
+		protocol := port.Protocol
+		if len(protocol) == 0 {
+			protocol = corev1.ProtocolTCP
+		}
+		out = append(out, appSvcPort{Name: port.Name, Protocol: protocol, Port: port.Port, TargetPort: port.TargetPort.String(), NodePort: port.NodePort})
+	}
+	return out
+}
+
+// If a port of the new service does not set its node port, Kubernetes would allocate a random one, so we keep the node port of the same port in the live service.
+func keepNodePorts(live, desired *corev1.Service) {
+	if live == nil || desired == nil || desired.Spec.Type != corev1.ServiceTypeNodePort {
+		return
+	}
+	for i := range desired.Spec.Ports {
+		if desired.Spec.Ports[i].NodePort != 0 {
+			continue
+		}
+		for _, livePort := range live.Spec.Ports {
+			if livePort.Name == desired.Spec.Ports[i].Name && livePort.Port == desired.Spec.Ports[i].Port {
+				desired.Spec.Ports[i].NodePort = livePort.NodePort
+				break
+			}
+		}
+	}
+}
+
+// the changes from a live service to a new service generated by genAppObjects. nil means no service.
+func appSvcChanges(live, desired *corev1.Service) []string {
+	switch {
+	case live == nil && desired == nil:
+		return nil
+	case live == nil:
+		return []string{"service is created"}
+	case desired == nil:
+		return []string{"service is deleted"}
+	}
+
+	var changes []string
+	if live.Spec.Type != desired.Spec.Type {
+		changes = append(changes, fmt.Sprintf("service type: [%s] -> [%s]", live.Spec.Type, desired.Spec.Type))
+	}
+	if !equality.Semantic.DeepEqual(appSvcPorts(live.Spec.Ports), appSvcPorts(desired.Spec.Ports)) {
+		changes = append(changes, "service ports changed")
+	}
+	return changes
+}
+
+// The placement of an auto-scheduled application is decided by auto-scheduling or migration, so if the new application does not set it, we keep the live one.
+func keepAutoSchedulePlacement(app K8sApp, live, desired *appsv1.Deployment) {
+	if live.Annotations[AutoScheduledAnno] != strconv.FormatBool(true) || !app.AutoScheduled {
+		return
+	}
+	if len(app.AutoScheduleInfo) == 0 && len(live.Annotations[AutoScheduleInfoAnno]) > 0 {
+		desired.Annotations[AutoScheduleInfoAnno] = live.Annotations[AutoScheduleInfoAnno]
+	}
+	if len(app.NodeName) > 0 || len(app.ReplicaNodeNames) > 0 {
+		return
+	}
+	desired.Spec.Template.Spec.NodeName = live.Spec.Template.Spec.NodeName
+	if live.Spec.Template.Spec.Affinity != nil {
+		desired.Spec.Template.Spec.Affinity.NodeAffinity = live.Spec.Template.Spec.Affinity.NodeAffinity
+	}
+}
+
+// The placement and the CPU of the containers of an auto-scheduled application are decided by auto-scheduling or migration, and they are recorded in AutoScheduleInfoAnno, which is not rolled back.
+// Therefore, rolling back only restores the other fields of the pod template, and the template keeps the current placement and CPU.
+func keepRollbackPlacement(current *appsv1.Deployment, template *corev1.PodTemplateSpec) {
+	if current.Annotations[AutoScheduledAnno] != strconv.FormatBool(true) {
+		return
+	}
+	currentSpec := current.Spec.Template.Spec
+	template.Spec.NodeName = currentSpec.NodeName
+	switch {
+	case currentSpec.Affinity != nil:
+		if template.Spec.Affinity == nil {
+			template.Spec.Affinity = &corev1.Affinity{}
+		}
+		template.Spec.Affinity.NodeAffinity = currentSpec.Affinity.NodeAffinity
+	case template.Spec.Affinity != nil:
+		template.Spec.Affinity.NodeAffinity = nil
+	}
+
+	currentCpus := make(map[string]corev1.ResourceRequirements)
+	for _, container := range currentSpec.Containers {
+		currentCpus[container.Name] = container.Resources
+	}
+	for i := range template.Spec.Containers {
+		container := &template.Spec.Containers[i]
+		resources, exist := currentCpus[container.Name]
+		if !exist {
+			continue
+		}
+		container.Resources.Requests = keepResource(container.Resources.Requests, resources.Requests, corev1.ResourceCPU)
+		container.Resources.Limits = keepResource(container.Resources.Limits, resources.Limits, corev1.ResourceCPU)
+	}
+}
+
+// set the resource in the list to the one in the current list, and delete it if the current list does not have it
+func keepResource(list, current corev1.ResourceList, name corev1.ResourceName) corev1.ResourceList {
+	quantity, exist := current[name]
+	if !exist {
+		delete(list, name)
+		return list
+	}
+	if list == nil {
+		list = make(corev1.ResourceList)
+	}
+	list[name] = quantity
+	return list
+}
+
+// UpdateApplication updates a running application to the new K8sApp, and returns the changes.
+// The function returns when the deployment and service are updated, and the caller should wait for the application running.
+func UpdateApplication(app K8sApp) ([]string, error, int) {
+	if err := ValidateK8sApp(app); err != nil {
+		outErr := fmt.Errorf("Validate app [%s] error: %w", app.Name, err)
+		beego.Error(outErr)
+		return nil, outErr, http.StatusBadRequest
+	}
+
+	namespace := AppNamespace(app.Namespace)
+	deployName, svcName := app.Name+DeploymentSuffix, app.Name+ServiceSuffix
+	liveDeploy, err := GetDeployment(namespace, deployName)
+	if err != nil {
+		outErr := fmt.Errorf("Get deployment %s/%s error: %w", namespace, deployName, err)
+		beego.Error(outErr)
+		return nil, outErr, http.StatusInternalServerError
+	}
+	if liveDeploy == nil {
+		outErr := fmt.Errorf("Deployment %s/%s not found", namespace, deployName)
+		beego.Error(outErr)
+		return nil, outErr, http.StatusNotFound
+	}
+	liveSvc, err := GetService(namespace, svcName)
+	if err != nil {
+		outErr := fmt.Errorf("Get service %s/%s error: %w", namespace, svcName, err)
+		beego.Error(outErr)
+		return nil, outErr, http.StatusInternalServerError
+	}
+
+	desiredDeploy, desiredSvc, err := genAppObjects(app)
+	if err != nil {
+		outErr := fmt.Errorf("Generate the deployment and service of app [%s] error: %w", app.Name, err)
+		beego.Error(outErr)
+		return nil, outErr, http.StatusInternalServerError
+	}
+	if desiredDeploy.Annotations == nil {
+		desiredDeploy.Annotations = make(map[string]string)
+	}
+	keepAutoSchedulePlacement(app, liveDeploy, desiredDeploy)
+	keepNodePorts(liveSvc, desiredSvc)
+
+	deployChanges := appDeployChanges(liveDeploy, desiredDeploy)
+	svcChanges := appSvcChanges(liveSvc, desiredSvc)
+	if len(deployChanges)+len(svcChanges) == 0 {
+		beego.Info(fmt.Sprintf("Application [%s/%s] is not changed.", namespace, app.Name))
+		return []string{}, nil, http.StatusOK
+	}
+	beego.Info(fmt.Sprintf("Update application [%s/%s], changes: %v %v", namespace, app.Name, deployChanges, svcChanges))
+
+	if len(deployChanges) > 0 {
+		// the new PVCs should exist before the new pods mount them
+		if err, statusCode := ensureAppPvcs(namespace, app); err != nil {
+			outErr := fmt.Errorf("Create the PVCs of app [%s] error: %w", app.Name, err)
+			beego.Error(outErr)
+			return nil, outErr, statusCode
+		}
+		if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			deployment, err := GetDeployment(namespace, deployName)
+			if err != nil {
+				return err
+			}
+			if deployment == nil {
+				return fmt.Errorf("deployment %s/%s not found", namespace, deployName)
+			}
+			deployment.Spec.Replicas = desiredDeploy.Spec.Replicas
+			deployment.Spec.Strategy = desiredDeploy.Spec.Strategy
+			deployment.Spec.Template = desiredDeploy.Spec.Template
+			for _, key := range appDeployAnnos {
+				if value, exist := desiredDeploy.Annotations[key]; exist {
+					if deployment.Annotations == nil {
+						deployment.Annotations = make(map[string]string)
+					}
+					deployment.Annotations[key] = value
+				} else {
+					delete(deployment.Annotations, key)
+				}
+			}
+			_, err = UpdateDeployment(deployment)
+			return err
+		}); err != nil {
+			outErr := fmt.Errorf("Update deployment %s/%s error: %w", namespace, deployName, err)
+			beego.Error(outErr)
+			return nil, outErr, http.StatusInternalServerError
+		}
+		beego.Info(fmt.Sprintf("Deployment %s/%s updated successful.", namespace, deployName))
+	}
+
+	if len(svcChanges) > 0 {
+		switch {
+		case desiredSvc == nil:
+			err = DeleteService(namespace, svcName)
+		case liveSvc == nil:
+			_, err = CreateService(desiredSvc)
+		default:
+			err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+				service, err := GetService(namespace, svcName)
+				if err != nil {
+					return err
+				}
+				if service == nil {
+					return fmt.Errorf("service %s/%s not found", namespace, svcName)
+				}
+				service.Spec.Type = desiredSvc.Spec.Type
+				service.Spec.Ports = desiredSvc.Spec.Ports
+				_, err = UpdateService(service)
+				return err
+			})
+		}
+		if err != nil {
+			outErr := fmt.Errorf("deployment %s/%s is updated, but the service %s/%s is not, error: %w", namespace, deployName, namespace, svcName, err)
+			beego.Error(outErr)
+			return nil, outErr, http.StatusInternalServerError
+		}
+		beego.Info(fmt.Sprintf("Service %s/%s updated successful.", namespace, svcName))
+	}
+
+	return append(deployChanges, svcChanges...), nil, http.StatusOK
+}
+
+// the ReplicaSets of a deployment, sorted by revision
+func listDeployReplicaSets(deployment *appsv1.Deployment) ([]appsv1.ReplicaSet, error) {
+	replicaSets, err := ListReplicaSets(deployment.Namespace, metav1.ListOptions{LabelSelector: metav1.FormatLabelSelector(deployment.Spec.Selector)})
+	if err != nil {
+		return nil, err
+	}
+	var owned []appsv1.ReplicaSet
+	for _, rs := range replicaSets {
+		if owner := metav1.GetControllerOf(&rs); owner != nil && owner.Kind == deploymentKind && owner.Name == deployment.Name {
+			owned = append(owned, rs)
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool {
+		return revisionOf(&owned[i]) < revisionOf(&owned[j])
+	})
+	return owned, nil
+}
+
+// RollbackApplication sets the pod template of an application to the one of its previous revision, and returns the changes.
+// The placement and the CPU of an auto-scheduled application are not rolled back, see keepRollbackPlacement.
+// The function returns when the deployment is updated, and the caller should wait for the application running.
+func RollbackApplication(namespace, appName string) ([]string, error, int) {
+	deployName := appName + DeploymentSuffix
+	deployment, err := GetDeployment(namespace, deployName)
+	if err != nil {
+		outErr := fmt.Errorf("Get deployment %s/%s error: %w", namespace, deployName, err)
+		beego.Error(outErr)
+		return nil, outErr, http.StatusInternalServerError
+	}
+	if deployment == nil {
+		outErr := fmt.Errorf("Deployment %s/%s not found", namespace, deployName)
+		beego.Error(outErr)
+		return nil, outErr, http.StatusNotFound
+	}
+
+	replicaSets, err := listDeployReplicaSets(deployment)
+	if err != nil {
+		outErr := fmt.Errorf("List the ReplicaSets of deployment %s/%s error: %w", namespace, deployName, err)
+		beego.Error(outErr)
+		return nil, outErr, http.StatusInternalServerError
+	}
+	currentRevision := revisionOf(deployment)
+	if currentRevision == 0 && len(replicaSets) > 0 {
+		currentRevision = revisionOf(&replicaSets[len(replicaSets)-1])
+	}
+	// the previous revision is the largest one before the current revision
+	var previous *appsv1.ReplicaSet
+	for i := range replicaSets {
+		if revision := revisionOf(&replicaSets[i]); revision > 0 && revision < currentRevision {
+			previous = &replicaSets[i]
+		}
+	}
+	if previous == nil {
+		outErr := fmt.Errorf("Application [%s/%s] at revision [%d] has no previous revision to roll back to", namespace, appName, currentRevision)
+		beego.Error(outErr)
+		return nil, outErr, http.StatusBadRequest
+	}
+	previousRevision := revisionOf(previous)
+
+	// the pod-template-hash label is added to the template of a ReplicaSet by Kubernetes, and it should not be in the template of a deployment.
+	template := previous.Spec.Template.DeepCopy()
+	delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+
+	if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := GetDeployment(namespace, deployName)
+		if err != nil {
+			return err
+		}
+		if current == nil {
+			return fmt.Errorf("deployment %s/%s not found", namespace, deployName)
+		}
+		rollbackTemplate := template.DeepCopy()
+		keepRollbackPlacement(current, rollbackTemplate)
+		current.Spec.Template = *rollbackTemplate
+		_, err = UpdateDeployment(current)
+		return err
+	}); err != nil {
+		outErr := fmt.Errorf("Roll back deployment %s/%s to revision [%d], error: %w", namespace, deployName, previousRevision, err)
+		beego.Error(outErr)
+		return nil, outErr, http.StatusInternalServerError
+	}
+
+	beego.Info(fmt.Sprintf("Application [%s/%s] is rolled back from revision [%d] to revision [%d].", namespace, appName, currentRevision, previousRevision))
+	return []string{fmt.Sprintf("pod template is rolled back from revision [%d] to revision [%d]", currentRevision, previousRevision)}, nil, http.StatusOK
+}
+
+// WaitForAppUpdated waits for an updated application running, and returns its information with the changes.
+func WaitForAppUpdated(namespace, appName string, changes []string) (AppUpdateResult, error, int) {
+	if len(changes) > 0 {
+		beego.Info(fmt.Sprintf("Start to wait for the application [%s/%s] running", namespace, appName))
+		if err := WaitForAppRunning(WaitForTimeOut, 10, namespace, appName); err != nil {
+			outErr := fmt.Errorf("Wait for application [%s/%s] running after the changes %v, error: %w. The application can be rolled back.", namespace, appName, changes, err)
+			beego.Error(outErr)
+			return AppUpdateResult{Changes: changes}, outErr, http.StatusInternalServerError
+		}
+		beego.Info(fmt.Sprintf("The application [%s/%s] is already running", namespace, appName))
+	}
+
+	appInfo, err, statusCode := GetApplication(namespace, appName)
+	if err != nil {
+		outErr := fmt.Errorf("After waiting, get application [%s/%s], error: %w", namespace, appName, err)
+		beego.Error(outErr)
+		return AppUpdateResult{Changes: changes}, outErr, statusCode
+	}
+	return AppUpdateResult{Changes: changes, App: appInfo}, nil, http.StatusOK
+}
+
+// This function updates an application, waits for it running, and returns the changes and its information.
+func UpdateAppAndWait(app K8sApp) (AppUpdateResult, error, int) {
+	changes, err, statusCode := UpdateApplication(app)
+	if err != nil {
+		return AppUpdateResult{}, err, statusCode
+	}
+	return WaitForAppUpdated(AppNamespace(app.Namespace), app.Name, changes)
+}
+
+// This function rolls back an application, waits for it running, and returns the changes and its information.
+func RollbackAppAndWait(namespace, appName string) (AppUpdateResult, error, int) {
+	changes, err, statusCode := RollbackApplication(namespace, appName)
+	if err != nil {
+		return AppUpdateResult{}, err, statusCode
+	}
+	return WaitForAppUpdated(namespace, appName, changes)
+}
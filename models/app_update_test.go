@@ -0,0 +1,226 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func simUpdateTestApp(image string, replicas int32, env []K8sEnv, ports []PortInfo) K8sApp {
+	app := simTestApp("app1", replicas, "500m")
+	app.Containers[0].Image = image
+	app.Containers[0].Env = env
+	app.Containers[0].Ports = ports
+	return app
+}
+
+func appImages(t *testing.T, appName string) []string {
+	pods, err := ListPods(KubernetesNamespace, metav1.ListOptions{LabelSelector: "app=" + appName})
+	assert.Nil(t, err)
+	var images []string
+	for _, pod := range pods {
+		images = append(images, pod.Spec.Containers[0].Image)
+	}
+	return images
+}
+
+func appRevision(t *testing.T, appName string) int64 {
+	deployment, err := GetDeployment(KubernetesNamespace, appName+DeploymentSuffix)
+	assert.Nil(t, err)
+	return revisionOf(deployment)
+}
+
+func TestUpdateApplication(t *testing.T) {
+	simulator := useSimulatedK8s(t)
+	assert.Nil(t, AddNode(IaasVm{Name: "n1", IPs: []string{"10.0.0.1"}, VCpu: 4, Ram: 8192, Storage: 50}, ""))
+	assert.Nil(t, AddNode(IaasVm{Name: "n2", IPs: []string{"10.0.0.2"}, VCpu: 4, Ram: 8192, Storage: 50}, ""))
+	assert.Nil(t, CreateApplication(simUpdateTestApp("nginx", 1, nil, nil)))
+	assert.Nil(t, simulator.SyncOnce())
+
+	env := []K8sEnv{{Name: "MODE", Value: "test"}}
+	ports := []PortInfo{{ContainerPort: 80, Name: "http", Protocol: "tcp", ServicePort: "80"}}
+	invalidApp := simUpdateTestApp("nginx", 1, nil, nil)
+	invalidApp.ReplicaNodeNames = []string{"n1", "n2"}
+	missingApp := simUpdateTestApp("nginx", 1, nil, nil)
+	missingApp.Name = "app2"
+
+	testCases := []struct {
+		name               string
+		app                K8sApp
+		expectedChanges    []string
+		expectedStatusCode int
+		expectedImages     []string
+		expectedRevision   int64
+	}{
+		{
+			name:               "no change",
+			app:                simUpdateTestApp("nginx", 1, nil, nil),
+			expectedChanges:    []string{},
+			expectedStatusCode: http.StatusOK,
+			expectedImages:     []string{"nginx"},
+			expectedRevision:   1,
+		},
+		{
+			name:               "image",
+			app:                simUpdateTestApp("nginx:1.25", 1, nil, nil),
+			expectedChanges:    []string{"container [c1] image: [nginx] -> [nginx:1.25]"},
+			expectedStatusCode: http.StatusOK,
+			expectedImages:     []string{"nginx:1.25"},
+			expectedRevision:   2,
+		},
+		{
+			name:               "replicas and env",
+			app:                simUpdateTestApp("nginx:1.25", 2, env, nil),
+			expectedChanges:    []string{"replicas: 1 -> 2", "rolling update strategy changed", "container [c1] env changed"},
+			expectedStatusCode: http.StatusOK,
+			expectedImages:     []string{"nginx:1.25", "nginx:1.25"},
+			expectedRevision:   3,
+		},
+		{
+			name:               "service",
+			app:                simUpdateTestApp("nginx:1.25", 2, env, ports),
+			expectedChanges:    []string{"container [c1] ports changed", "service is created"},
+			expectedStatusCode: http.StatusOK,
+			expectedImages:     []string{"nginx:1.25", "nginx:1.25"},
+			expectedRevision:   4,
+		},
+		{
+			name:               "invalid",
+			app:                invalidApp,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedImages:     []string{"nginx:1.25", "nginx:1.25"},
+			expectedRevision:   4,
+		},
+		{
+			name:               "not found",
+			app:                missingApp,
+			expectedStatusCode: http.StatusNotFound,
+			expectedImages:     []string{"nginx:1.25", "nginx:1.25"},
+			expectedRevision:   4,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		changes, err, statusCode := UpdateApplication(testCase.app)
+		assert.Equal(t, testCase.expectedStatusCode, statusCode, fmt.Sprintf("%s: status code is not expected, error: %v", testCase.name, err))
+		assert.Equal(t, testCase.expectedChanges, changes, fmt.Sprintf("%s: changes are not expected", testCase.name))
+		assert.Nil(t, simulator.SyncOnce())
+		assert.Equal(t, testCase.expectedImages, appImages(t, "app1"), fmt.Sprintf("%s: images are not expected", testCase.name))
+		assert.Equal(t, testCase.expectedRevision, appRevision(t, "app1"), fmt.Sprintf("%s: revision is not expected", testCase.name))
+	}
+
+	app, err, _ := GetApplication(KubernetesNamespace, "app1")
+	assert.Nil(t, err)
+	assert.Equal(t, RunningStatus, app.Status)
+	assert.Equal(t, []string{"80"}, app.SvcPort)
+}
+
+func TestRollbackApplication(t *testing.T) {
+	simulator := useSimulatedK8s(t)
+	assert.Nil(t, AddNode(IaasVm{Name: "n1", IPs: []string{"10.0.0.1"}, VCpu: 4, Ram: 8192, Storage: 50}, ""))
+	assert.Nil(t, CreateApplication(simUpdateTestApp("nginx", 1, nil, nil)))
+	assert.Nil(t, simulator.SyncOnce())
+
+	testCases := []struct {
+		name               string
+		do                 func() ([]string, error, int)
+		expectedStatusCode int
+		expectedImages     []string
+		expectedRevision   int64
+	}{
+		{
+			name:               "no previous revision",
+			do:                 func() ([]string, error, int) { return RollbackApplication(KubernetesNamespace, "app1") },
+			expectedStatusCode: http.StatusBadRequest,
+			expectedImages:     []string{"nginx"},
+			expectedRevision:   1,
+		},
+		{
+			name:               "update",
+			do:                 func() ([]string, error, int) { return UpdateApplication(simUpdateTestApp("nginx:1.25", 1, nil, nil)) },
+			expectedStatusCode: http.StatusOK,
+			expectedImages:     []string{"nginx:1.25"},
+			expectedRevision:   2,
+		},
+		{
+			name:               "rollback",
+			do:                 func() ([]string, error, int) { return RollbackApplication(KubernetesNamespace, "app1") },
+			expectedStatusCode: http.StatusOK,
+			expectedImages:     []string{"nginx"},
+			expectedRevision:   3,
+		},
+		{
+			// like "kubectl rollout undo", rolling back again goes to the template before the rollback
+			name:               "rollback again",
+			do:                 func() ([]string, error, int) { return RollbackApplication(KubernetesNamespace, "app1") },
+			expectedStatusCode: http.StatusOK,
+			expectedImages:     []string{"nginx:1.25"},
+			expectedRevision:   4,
+		},
+		{
+			name:               "not found",
+			do:                 func() ([]string, error, int) { return RollbackApplication(KubernetesNamespace, "app2") },
+			expectedStatusCode: http.StatusNotFound,
+			expectedImages:     []string{"nginx:1.25"},
+			expectedRevision:   4,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		_, err, statusCode := testCase.do()
+		assert.Equal(t, testCase.expectedStatusCode, statusCode, fmt.Sprintf("%s: status code is not expected, error: %v", testCase.name, err))
+		assert.Nil(t, simulator.SyncOnce())
+		assert.Equal(t, testCase.expectedImages, appImages(t, "app1"), fmt.Sprintf("%s: images are not expected", testCase.name))
+		assert.Equal(t, testCase.expectedRevision, appRevision(t, "app1"), fmt.Sprintf("%s: revision is not expected", testCase.name))
+	}
+
+	// the old ReplicaSets are kept as the history, and the ones of a deleted application are deleted
+	replicaSets, err := ListReplicaSets(KubernetesNamespace, metav1.ListOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, replicaSets, 2)
+	assert.Nil(t, DeleteDeployment(KubernetesNamespace, "app1"+DeploymentSuffix))
+	assert.Nil(t, simulator.SyncOnce())
+	replicaSets, err = ListReplicaSets(KubernetesNamespace, metav1.ListOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, replicaSets, 0)
+}
+
+func TestRollbackAutoScheduledApp(t *testing.T) {
+	simulator := useSimulatedK8s(t)
+	assert.Nil(t, AddNode(IaasVm{Name: "n1", IPs: []string{"10.0.0.1"}, VCpu: 4, Ram: 8192, Storage: 50}, ""))
+	assert.Nil(t, AddNode(IaasVm{Name: "n2", IPs: []string{"10.0.0.2"}, VCpu: 4, Ram: 8192, Storage: 50}, ""))
+	app := simUpdateTestApp("nginx", 1, nil, nil)
+	app.AutoScheduled = true
+	app.NodeName = "n1"
+	app.AutoScheduleInfo = `{"name":"app1"}`
+	assert.Nil(t, CreateApplication(app))
+	assert.Nil(t, simulator.SyncOnce())
+
+	// update the image, and then migration moves the application and changes its CPU
+	app.Containers[0].Image = "nginx:1.25"
+	_, err, statusCode := UpdateApplication(app)
+	assert.Equal(t, http.StatusOK, statusCode, fmt.Sprintf("update error: %v", err))
+	assert.Nil(t, simulator.SyncOnce())
+	err, statusCode = MigrateApplication(KubernetesNamespace, "app1", []string{"n2"}, []string{"1"})
+	assert.Equal(t, http.StatusOK, statusCode, fmt.Sprintf("migrate error: %v", err))
+	assert.Nil(t, simulator.SyncOnce())
+
+	// the previous revision is the one before migration, and rolling back to it keeps the node and the CPU given by migration
+	_, err, statusCode = RollbackApplication(KubernetesNamespace, "app1")
+	assert.Equal(t, http.StatusOK, statusCode, fmt.Sprintf("rollback error: %v", err))
+	assert.Nil(t, simulator.SyncOnce())
+	assert.Equal(t, []string{"nginx:1.25"}, appImages(t, "app1"))
+	assert.Equal(t, []string{"n2"}, appHostNames(t, "app1"))
+	deployment, err := GetDeployment(KubernetesNamespace, "app1"+DeploymentSuffix)
+	assert.Nil(t, err)
+	podSpec := deployment.Spec.Template.Spec
+	assert.Equal(t, "n2", podSpec.NodeName)
+	assert.Equal(t, "1", podSpec.Containers[0].Resources.Requests.Cpu().String())
+	assert.Equal(t, "1", podSpec.Containers[0].Resources.Limits.Cpu().String())
+	assert.Equal(t, `{"name":"app1"}`, deployment.Annotations[AutoScheduleInfoAnno])
+}
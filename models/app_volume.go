@@ -0,0 +1,266 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/astaxie/beego"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+/**
+NOTE:
+
+Besides VM paths (hostPath volumes), the containers of an application can mount PersistentVolumeClaims (PVCs), ConfigMaps and Secrets (see K8sMount).
+The data in a hostPath volume is on one VM, so it is lost when auto-scheduling moves the application to another VM or GcASVms deletes the VM, but the data in a PVC is not.
+1. The PVCs that do not exist are created before the deployment, with the label AppPvcLabel whose value is the application name.
+2. The PVCs are not deleted with the application, so that an application created later can use the data again. Users delete them in Kubernetes if the data is not needed.
+3. A ReadWriteOnce PVC can only be mounted on one node, but the replicas of an application run on different nodes, so an application with multiple replicas should use ReadWriteMany or ReadOnlyMany PVCs.
+4. The ConfigMaps and Secrets should exist in the namespace of the application, and multi-cloud manager does not create them.
+5. In auto-scheduling, the sizes of the PVCs of an application are its persistent storage (AppResources.PersistentStorage). It is provided by the storage classes but not the VMs, so it is not counted in the storage of the VMs.
+   Auto-scheduling rejects the applications whose new PVCs need storage classes that do not exist (see CheckAppStorageClasses), and shows the persistent storage of every application in the scheduling plan.
+   Multi-cloud manager does not know the capacity of the storage classes. If a storage class does not have enough capacity, the PVC is pending in Kubernetes, and the pods of the application are pending too.
+*/
+
+const (
+	AppPvcLabel string = McmKey + "/app"
+
+	// Kubernetes sets this mode for the files of ConfigMap and Secret volumes if it is not specified. We set it too, so that the volumes we generate are the same as the live ones.
+	volumeDefaultMode int32 = 0644
+)
+
+// the mounts with the same key share a volume in the pod
+func (m K8sMount) volumeKey() string {
+	switch {
+	case m.Pvc != nil:
+		return "pvc:" + m.Pvc.Name
+	case len(m.ConfigMap) > 0:
+		return "configMap:" + m.ConfigMap
+	case len(m.Secret) > 0:
+		return "secret:" + m.Secret
+	default:
+		return "hostPath:" + m.VmPath
+	}
+}
+
+// the description of the source of a mount in logs and errors
+func (m K8sMount) sourceString() string {
+	switch {
+	case m.Pvc != nil:
+		return fmt.Sprintf("PVC [%s]", m.Pvc.Name)
+	case len(m.ConfigMap) > 0:
+		return fmt.Sprintf("ConfigMap [%s]", m.ConfigMap)
+	case len(m.Secret) > 0:
+		return fmt.Sprintf("Secret [%s]", m.Secret)
+	default:
+		return fmt.Sprintf("VM Path [%s]", m.VmPath)
+	}
+}
+
+func (m K8sMount) volumeSource() corev1.VolumeSource {
+	defaultMode := volumeDefaultMode
+	switch {
+	case m.Pvc != nil:
+		return corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: m.Pvc.Name},
+		}
+	case len(m.ConfigMap) > 0:
+		return corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: m.ConfigMap},
+				DefaultMode:          &defaultMode,
+			},
+		}
+	case len(m.Secret) > 0:
+		return corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName:  m.Secret,
+				DefaultMode: &defaultMode,
+			},
+		}
+	default:
+		var hostPathType corev1.HostPathType = corev1.HostPathDirectoryOrCreate
+		return corev1.VolumeSource{
+			HostPath: &corev1.HostPathVolumeSource{
+				Path: m.VmPath,
+				Type: &hostPathType,
+			},
+		}
+	}
+}
+
+func pvcAccessMode(pvc K8sPvc) corev1.PersistentVolumeAccessMode {
+	if len(pvc.AccessMode) == 0 {
+		return corev1.ReadWriteOnce
+	}
+	return corev1.PersistentVolumeAccessMode(pvc.AccessMode)
+}
+
+// the PVCs mounted by an application, without repetition
+func appPvcs(app K8sApp) []K8sPvc {
+	var pvcs []K8sPvc
+	found := make(map[string]struct{})
+	for _, container := range app.Containers {
+		for _, mount := range container.Mounts {
+			if mount.Pvc == nil {
+				continue
+			}
+			if _, exist := found[mount.Pvc.Name]; exist {
+				continue
+			}
+			found[mount.Pvc.Name] = struct{}{}
+			pvcs = append(pvcs, *mount.Pvc)
+		}
+	}
+	return pvcs
+}
+
+func validateAppMounts(app K8sApp) error {
+	pvcs := make(map[string]K8sPvc)
+	for _, container := range app.Containers {
+		for _, mount := range container.Mounts {
+			var sourceNum int
+			for _, set := range []bool{len(mount.VmPath) > 0, mount.Pvc != nil, len(mount.ConfigMap) > 0, len(mount.Secret) > 0} {
+				if set {
+					sourceNum++
+				}
+			}
+			if sourceNum != 1 {
+				return fmt.Errorf("application [%s], container [%s], the mount to [%s] should have one of vmPath, pvc, configMap and secret, but it has [%d]", app.Name, container.Name, mount.ContainerPath, sourceNum)
+			}
+			if len(mount.ContainerPath) == 0 {
+				return fmt.Errorf("application [%s], container [%s], the mount of %s does not have containerPath", app.Name, container.Name, mount.sourceString())
+			}
+			if mount.Pvc == nil {
+				continue
+			}
+
+			pvc := *mount.Pvc
+			if errs := validation.IsDNS1123Subdomain(pvc.Name); len(errs) != 0 {
+				return fmt.Errorf("application [%s], PVC name [%s] is invalid: %v", app.Name, pvc.Name, errs)
+			}
+			if len(pvc.Size) > 0 {
+				if _, err := resource.ParseQuantity(pvc.Size); err != nil {
+					return fmt.Errorf("application [%s], PVC [%s] size [%s] is invalid: %w", app.Name, pvc.Name, pvc.Size, err)
+				}
+			}
+			switch accessMode := pvcAccessMode(pvc); accessMode {
+			case corev1.ReadWriteOnce, corev1.ReadWriteMany, corev1.ReadOnlyMany:
+				// the pods of an application run on different nodes, so a ReadWriteOnce PVC cannot be mounted by multiple replicas
+				if accessMode == corev1.ReadWriteOnce && app.Replicas > 1 {
+					return fmt.Errorf("application [%s] has [%d] replicas on different nodes, but PVC [%s] is [%s], which can only be mounted on one node, please use [%s] or [%s]", app.Name, app.Replicas, pvc.Name, accessMode, corev1.ReadWriteMany, corev1.ReadOnlyMany)
+				}
+			default:
+				return fmt.Errorf("application [%s], PVC [%s] access mode [%s] is not supported, it should be [%s], [%s] or [%s]", app.Name, pvc.Name, pvc.AccessMode, corev1.ReadWriteOnce, corev1.ReadWriteMany, corev1.ReadOnlyMany)
+			}
+			if existing, exist := pvcs[pvc.Name]; exist && existing != pvc {
+				return fmt.Errorf("application [%s], PVC [%s] is mounted more than once with different storageClass, size or accessMode", app.Name, pvc.Name)
+			}
+			pvcs[pvc.Name] = pvc
+		}
+	}
+	return nil
+}
+
+// create the PVCs of an application that do not exist
+func ensureAppPvcs(namespace string, app K8sApp) (error, int) {
+	for _, pvc := range appPvcs(app) {
+		existing, err := GetPvc(namespace, pvc.Name)
+		if err != nil {
+			outErr := fmt.Errorf("Get PVC %s/%s error: %w", namespace, pvc.Name, err)
+			beego.Error(outErr)
+			return outErr, http.StatusInternalServerError
+		}
+		if existing != nil {
+			beego.Info(fmt.Sprintf("PVC %s/%s exists, application [%s] uses it.", namespace, pvc.Name, app.Name))
+			continue
+		}
+		if len(pvc.Size) == 0 {
+			outErr := fmt.Errorf("PVC %s/%s does not exist, and application [%s] does not set its size to create it", namespace, pvc.Name, app.Name)
+			beego.Error(outErr)
+			return outErr, http.StatusBadRequest
+		}
+
+		size, err := resource.ParseQuantity(pvc.Size)
+		if err != nil {
+			outErr := fmt.Errorf("PVC %s/%s size [%s] is invalid: %w", namespace, pvc.Name, pvc.Size, err)
+			beego.Error(outErr)
+			return outErr, http.StatusBadRequest
+		}
+		claim := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      pvc.Name,
+				Namespace: namespace,
+				Labels:    map[string]string{AppPvcLabel: app.Name},
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{pvcAccessMode(pvc)},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: size},
+				},
+			},
+		}
+		if len(pvc.StorageClass) > 0 {
+			storageClass := pvc.StorageClass
+			claim.Spec.StorageClassName = &storageClass
+		}
+		if _, err := CreatePvc(claim); err != nil {
+			outErr := fmt.Errorf("Create PVC %s/%s error: %w", namespace, pvc.Name, err)
+			beego.Error(outErr)
+			return outErr, http.StatusInternalServerError
+		}
+		beego.Info(fmt.Sprintf("PVC %s/%s created successful.", namespace, pvc.Name))
+	}
+	return nil, http.StatusOK
+}
+
+// CheckAppStorageClasses checks that the storage classes of the PVCs that an application will create exist in Kubernetes, so that the PVCs will not be pending forever.
+// The PVCs that already exist are not created, so their storage classes are not checked.
+func CheckAppStorageClasses(namespace string, app K8sApp) (error, int) {
+	for _, pvc := range appPvcs(app) {
+		if len(pvc.StorageClass) == 0 {
+			continue
+		}
+		existing, err := GetPvc(namespace, pvc.Name)
+		if err != nil {
+			outErr := fmt.Errorf("Get PVC %s/%s error: %w", namespace, pvc.Name, err)
+			beego.Error(outErr)
+			return outErr, http.StatusInternalServerError
+		}
+		if existing != nil {
+			continue
+		}
+		storageClass, err := GetStorageClass(pvc.StorageClass)
+		if err != nil {
+			outErr := fmt.Errorf("Get StorageClass [%s] of PVC %s/%s error: %w", pvc.StorageClass, namespace, pvc.Name, err)
+			beego.Error(outErr)
+			return outErr, http.StatusInternalServerError
+		}
+		if storageClass == nil {
+			outErr := fmt.Errorf("application [%s], PVC %s/%s needs StorageClass [%s], which does not exist in Kubernetes", app.Name, namespace, pvc.Name, pvc.StorageClass)
+			beego.Error(outErr)
+			return outErr, http.StatusBadRequest
+		}
+	}
+	return nil, http.StatusOK
+}
+
+// AppPvcStorageGi is the sum of the sizes of the PVCs of an application, unit Gibibyte (GiB).
+// The PVCs without size already exist, and their sizes are not known from the application, so they are not counted.
+func AppPvcStorageGi(app K8sApp) (float64, error) {
+	var sum float64
+	for _, pvc := range appPvcs(app) {
+		if len(pvc.Size) == 0 {
+			continue
+		}
+		size, err := resource.ParseQuantity(pvc.Size)
+		if err != nil {
+			return 0, fmt.Errorf("PVC [%s] size [%s] is invalid: %w", pvc.Name, pvc.Size, err)
+		}
+		sum += float64(size.Value()) / 1024 / 1024 / 1024
+	}
+	return sum, nil
+}
@@ -0,0 +1,279 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func volumeTestApp(replicas int32, mounts ...K8sMount) K8sApp {
+	app := simTestApp("app1", replicas, "500m")
+	app.Containers[0].Mounts = mounts
+	return app
+}
+
+func TestValidateAppMounts(t *testing.T) {
+	testCases := []struct {
+		name        string
+		app         K8sApp
+		expectError bool
+	}{
+		{
+			name: "all sources",
+			app: volumeTestApp(1,
+				K8sMount{VmPath: "/tmp/app1", ContainerPath: "/tmp"},
+				K8sMount{Pvc: &K8sPvc{Name: "data", Size: "10Gi"}, ContainerPath: "/data"},
+				K8sMount{ConfigMap: "conf", ContainerPath: "/etc/app1", ReadOnly: true},
+				K8sMount{Secret: "cert", ContainerPath: "/etc/cert"},
+			),
+		},
+		{
+			name: "a PVC mounted twice",
+			app: volumeTestApp(1,
+				K8sMount{Pvc: &K8sPvc{Name: "data", Size: "10Gi"}, ContainerPath: "/data", SubPath: "a"},
+				K8sMount{Pvc: &K8sPvc{Name: "data", Size: "10Gi"}, ContainerPath: "/logs", SubPath: "b"},
+			),
+		},
+		{
+			name: "ReadWriteMany PVC with replicas",
+			app:  volumeTestApp(2, K8sMount{Pvc: &K8sPvc{Name: "data", Size: "10Gi", AccessMode: "ReadWriteMany"}, ContainerPath: "/data"}),
+		},
+		{
+			name:        "ReadWriteOnce PVC with replicas",
+			app:         volumeTestApp(2, K8sMount{Pvc: &K8sPvc{Name: "data", Size: "10Gi"}, ContainerPath: "/data"}),
+			expectError: true,
+		},
+		{
+			name:        "no source",
+			app:         volumeTestApp(1, K8sMount{ContainerPath: "/data"}),
+			expectError: true,
+		},
+		{
+			name:        "two sources",
+			app:         volumeTestApp(1, K8sMount{VmPath: "/tmp/app1", ConfigMap: "conf", ContainerPath: "/data"}),
+			expectError: true,
+		},
+		{
+			name:        "no container path",
+			app:         volumeTestApp(1, K8sMount{VmPath: "/tmp/app1"}),
+			expectError: true,
+		},
+		{
+			name:        "invalid PVC name",
+			app:         volumeTestApp(1, K8sMount{Pvc: &K8sPvc{Name: "Data_1", Size: "10Gi"}, ContainerPath: "/data"}),
+			expectError: true,
+		},
+		{
+			name:        "invalid size",
+			app:         volumeTestApp(1, K8sMount{Pvc: &K8sPvc{Name: "data", Size: "ten"}, ContainerPath: "/data"}),
+			expectError: true,
+		},
+		{
+			name:        "invalid access mode",
+			app:         volumeTestApp(1, K8sMount{Pvc: &K8sPvc{Name: "data", Size: "10Gi", AccessMode: "ReadWriteSometimes"}, ContainerPath: "/data"}),
+			expectError: true,
+		},
+		{
+			name: "a PVC mounted twice differently",
+			app: volumeTestApp(1,
+				K8sMount{Pvc: &K8sPvc{Name: "data", Size: "10Gi"}, ContainerPath: "/data"},
+				K8sMount{Pvc: &K8sPvc{Name: "data", Size: "20Gi"}, ContainerPath: "/logs"},
+			),
+			expectError: true,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		err := ValidateK8sApp(testCase.app)
+		if testCase.expectError {
+			assert.NotNil(t, err, testCase.name)
+		} else {
+			assert.Nil(t, err, fmt.Sprintf("%s: error: %v", testCase.name, err))
+		}
+	}
+}
+
+func TestAppPvcStorageGi(t *testing.T) {
+	testCases := []struct {
+		name            string
+		app             K8sApp
+		expectedStorage float64
+		expectError     bool
+	}{
+		{
+			name:            "no PVC",
+			app:             volumeTestApp(1, K8sMount{VmPath: "/tmp/app1", ContainerPath: "/tmp"}),
+			expectedStorage: 0,
+		},
+		{
+			name: "PVCs mounted more than once are counted once",
+			app: volumeTestApp(1,
+				K8sMount{Pvc: &K8sPvc{Name: "data", Size: "10Gi"}, ContainerPath: "/data"},
+				K8sMount{Pvc: &K8sPvc{Name: "data", Size: "10Gi"}, ContainerPath: "/logs"},
+				K8sMount{Pvc: &K8sPvc{Name: "cache", Size: "512Mi"}, ContainerPath: "/cache"},
+				K8sMount{Pvc: &K8sPvc{Name: "existing"}, ContainerPath: "/existing"},
+			),
+			expectedStorage: 10.5,
+		},
+		{
+			name:        "invalid size",
+			app:         volumeTestApp(1, K8sMount{Pvc: &K8sPvc{Name: "data", Size: "ten"}, ContainerPath: "/data"}),
+			expectError: true,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		storage, err := AppPvcStorageGi(testCase.app)
+		if testCase.expectError {
+			assert.NotNil(t, err, testCase.name)
+			continue
+		}
+		assert.Nil(t, err, testCase.name)
+		assert.Equal(t, testCase.expectedStorage, storage, testCase.name)
+	}
+}
+
+func TestCheckAppStorageClasses(t *testing.T) {
+	useSimulatedK8s(t)
+	_, err := kubernetesClient.StorageV1().StorageClasses().Create(context.Background(), &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "nfs"}, Provisioner: "nfs"}, metav1.CreateOptions{})
+	assert.Nil(t, err)
+	_, err = CreatePvc(&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "existing", Namespace: KubernetesNamespace}})
+	assert.Nil(t, err)
+
+	testCases := []struct {
+		name               string
+		app                K8sApp
+		expectedStatusCode int
+	}{
+		{
+			name:               "storage class exists",
+			app:                volumeTestApp(1, K8sMount{Pvc: &K8sPvc{Name: "data", StorageClass: "nfs", Size: "10Gi"}, ContainerPath: "/data"}),
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "default storage class",
+			app:                volumeTestApp(1, K8sMount{Pvc: &K8sPvc{Name: "data", Size: "10Gi"}, ContainerPath: "/data"}),
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "storage class does not exist",
+			app:                volumeTestApp(1, K8sMount{Pvc: &K8sPvc{Name: "data", StorageClass: "ceph", Size: "10Gi"}, ContainerPath: "/data"}),
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "existing PVC is not checked",
+			app:                volumeTestApp(1, K8sMount{Pvc: &K8sPvc{Name: "existing", StorageClass: "ceph"}, ContainerPath: "/data"}),
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		err, statusCode := CheckAppStorageClasses(KubernetesNamespace, testCase.app)
+		assert.Equal(t, testCase.expectedStatusCode, statusCode, fmt.Sprintf("%s: status code is not expected, error: %v", testCase.name, err))
+		assert.Equal(t, testCase.expectedStatusCode != http.StatusOK, err != nil, testCase.name)
+	}
+}
+
+func TestCreateAppWithVolumes(t *testing.T) {
+	simulator := useSimulatedK8s(t)
+	assert.Nil(t, AddNode(IaasVm{Name: "n1", IPs: []string{"10.0.0.1"}, VCpu: 4, Ram: 8192, Storage: 50}, ""))
+
+	app := volumeTestApp(1,
+		K8sMount{VmPath: "/tmp/app1", ContainerPath: "/tmp"},
+		K8sMount{Pvc: &K8sPvc{Name: "app1-data", StorageClass: "nfs", Size: "10Gi"}, ContainerPath: "/data"},
+		K8sMount{ConfigMap: "app1-conf", ContainerPath: "/etc/app1", ReadOnly: true},
+		K8sMount{Secret: "app1-cert", ContainerPath: "/etc/cert"},
+		K8sMount{Pvc: &K8sPvc{Name: "app1-data", StorageClass: "nfs", Size: "10Gi"}, ContainerPath: "/logs", SubPath: "logs"},
+	)
+	assert.Nil(t, CreateApplication(app))
+	assert.Nil(t, simulator.SyncOnce())
+
+	// the PVC is created with the storage class and size
+	pvc, err := GetPvc(KubernetesNamespace, "app1-data")
+	assert.Nil(t, err)
+	if assert.NotNil(t, pvc) {
+		assert.Equal(t, "app1", pvc.Labels[AppPvcLabel])
+		assert.Equal(t, "nfs", *pvc.Spec.StorageClassName)
+		assert.Equal(t, []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}, pvc.Spec.AccessModes)
+		assert.Equal(t, "10Gi", pvc.Spec.Resources.Requests.Storage().String())
+	}
+
+	// the mounts of the same source share a volume
+	deployment, err := GetDeployment(KubernetesNamespace, "app1"+DeploymentSuffix)
+	assert.Nil(t, err)
+	podSpec := deployment.Spec.Template.Spec
+	assert.Len(t, podSpec.Volumes, 4)
+	assert.Equal(t, "/tmp/app1", podSpec.Volumes[0].HostPath.Path)
+	assert.Equal(t, "app1-data", podSpec.Volumes[1].PersistentVolumeClaim.ClaimName)
+	assert.Equal(t, "app1-conf", podSpec.Volumes[2].ConfigMap.Name)
+	assert.Equal(t, "app1-cert", podSpec.Volumes[3].Secret.SecretName)
+	assert.Equal(t, []corev1.VolumeMount{
+		{Name: "volume0", MountPath: "/tmp"},
+		{Name: "volume1", MountPath: "/data"},
+		{Name: "volume2", MountPath: "/etc/app1", ReadOnly: true},
+		{Name: "volume3", MountPath: "/etc/cert"},
+		{Name: "volume1", MountPath: "/logs", SubPath: "logs"},
+	}, podSpec.Containers[0].VolumeMounts)
+
+	testCases := []struct {
+		name               string
+		app                K8sApp
+		expectedChanges    []string
+		expectedStatusCode int
+		expectedPvcs       []string
+	}{
+		{
+			name:               "no change",
+			app:                app,
+			expectedChanges:    []string{},
+			expectedStatusCode: http.StatusOK,
+			expectedPvcs:       []string{"app1-data"},
+		},
+		{
+			name:               "a PVC not existing without size",
+			app:                volumeTestApp(1, K8sMount{Pvc: &K8sPvc{Name: "app1-old"}, ContainerPath: "/data"}),
+			expectedStatusCode: http.StatusBadRequest,
+			expectedPvcs:       []string{"app1-data"},
+		},
+		{
+			// the PVC not used any more is not deleted
+			name:               "another PVC",
+			app:                volumeTestApp(1, K8sMount{Pvc: &K8sPvc{Name: "app1-new", Size: "1Gi"}, ContainerPath: "/data"}),
+			expectedChanges:    []string{"container [c1] mounts changed", "volumes changed"},
+			expectedStatusCode: http.StatusOK,
+			expectedPvcs:       []string{"app1-data", "app1-new"},
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		changes, err, statusCode := UpdateApplication(testCase.app)
+		assert.Equal(t, testCase.expectedStatusCode, statusCode, fmt.Sprintf("%s: status code is not expected, error: %v", testCase.name, err))
+		assert.Equal(t, testCase.expectedChanges, changes, fmt.Sprintf("%s: changes are not expected", testCase.name))
+		assert.Nil(t, simulator.SyncOnce())
+
+		pvcs, err := kubernetesClient.CoreV1().PersistentVolumeClaims(KubernetesNamespace).List(context.Background(), metav1.ListOptions{})
+		assert.Nil(t, err)
+		var pvcNames []string
+		for _, pvc := range pvcs.Items {
+			pvcNames = append(pvcNames, pvc.Name)
+		}
+		assert.Equal(t, testCase.expectedPvcs, pvcNames, fmt.Sprintf("%s: PVCs are not expected", testCase.name))
+	}
+
+	// the PVCs are kept after the application is deleted
+	assert.Nil(t, DeleteDeployment(KubernetesNamespace, "app1"+DeploymentSuffix))
+	assert.Nil(t, simulator.SyncOnce())
+	pvc, err = GetPvc(KubernetesNamespace, "app1-new")
+	assert.Nil(t, err)
+	assert.NotNil(t, pvc)
+}
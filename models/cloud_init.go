@@ -0,0 +1,187 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/astaxie/beego"
+	"sigs.k8s.io/yaml"
+)
+
+/**
+NOTE:
+
+Without user-data, a new VM is initialized by multi-cloud manager through SSH: WaitForSshPem runs DiskInitCmd to extend the disk, and AddNode SSHes into the VM to configure containerd and run "kubeadm join".
+With user-data, cloud-init does all these inside the VM when it boots for the first time, and every driver delivers the user-data in its native way:
+1. Local: a NoCloud seed ISO attached to the VM as a cdrom;
+2. Proxmox: a snippet used by the "cicustom" option of the cloud-init drive of the VM template;
+3. Openstack: the "UserData" of servers.CreateOpts.
+Then, the controller does not need to hold an SSH session to a new VM or a new node.
+The VMs created by auto-scheduling join Kubernetes by cloud-init if their clouds support user-data (see JoinByCloudInitIfSupported), and the others still join through SSH.
+*/
+
+const cloudConfigHeader string = "#cloud-config\n"
+
+// UserData is what a VM does when it boots for the first time.
+type UserData struct {
+	SshAuthorizedKeys []string `json:"sshAuthorizedKeys,omitempty"`
+	Packages          []string `json:"packages,omitempty"`
+	RunCmd            []string `json:"runCmd,omitempty"` // shell commands run as root after the packages are installed
+	// If it is true, the VM joins the Kubernetes cluster by itself when it boots.
+	// JoinCmd is the output of "kubeadm token create --print-join-command". If it is empty, multi-cloud manager gets one before creating the VM.
+	// JoinCmd has the token of the cluster, so it can be set in requests, but it is never marshaled (see MarshalJSON).
+	JoinKubernetes bool   `json:"joinKubernetes,omitempty"`
+	JoinCmd        string `json:"joinCmd,omitempty"`
+}
+
+// MarshalJSON omits JoinCmd, so that the token of the cluster is not put in API responses or the records of scheduling jobs.
+func (u UserData) MarshalJSON() ([]byte, error) {
+	type userDataNoMethods UserData // without this method, to avoid the recursion
+	out := userDataNoMethods(u)
+	out.JoinCmd = ""
+	return json.Marshal(out)
+}
+
+// userDataCreator is an optional interface for the cloud drivers that can deliver user-data to a new VM.
+// userData is the rendered cloud-config.
+type userDataCreator interface {
+	CreateVMWithUserData(name string, vcpu, ram, storage int, userData string) (*IaasVm, error)
+}
+
+// JoinedByCloudInit returns whether the VM joins Kubernetes by cloud-init, in which case AddNode should not SSH to it.
+func (vm IaasVm) JoinedByCloudInit() bool {
+	return vm.UserData != nil && vm.UserData.JoinKubernetes
+}
+
+// JoinByCloudInitIfSupported returns a copy of the VMs, in which the VMs without user-data get the user-data to join Kubernetes by cloud-init if their clouds support user-data.
+// The VMs on the clouds that do not support user-data are not changed, so they join Kubernetes through SSH.
+func JoinByCloudInitIfSupported(vms []IaasVm) []IaasVm {
+	out := make([]IaasVm, len(vms))
+	copy(out, vms)
+	for i := range out {
+		if out[i].UserData != nil {
+			continue
+		}
+		cloud, exist := GetIaas(out[i].Cloud)
+		if !exist {
+			continue
+		}
+		if _, ok := cloud.(userDataCreator); ok {
+			out[i].UserData = &UserData{JoinKubernetes: true}
+		}
+	}
+	return out
+}
+
+// the fields of cloud-config that we use
+type cloudConfig struct {
+	Hostname          string     `json:"hostname,omitempty"`
+	SshAuthorizedKeys []string   `json:"ssh_authorized_keys,omitempty"`
+	Users             []string   `json:"users,omitempty"`
+	Growpart          *growpart  `json:"growpart,omitempty"`
+	Packages          []string   `json:"packages,omitempty"`
+	PackageUpdate     bool       `json:"package_update,omitempty"`
+	RunCmd            [][]string `json:"runcmd,omitempty"`
+}
+
+type growpart struct {
+	Mode    string   `json:"mode"`
+	Devices []string `json:"devices"`
+}
+
+// the commands to configure containerd and join Kubernetes, which are run on the node either by SSH or by cloud-init.
+func nodeJoinCmds(nodeName, joinCmd string) []string {
+	k8sMasterIP := beego.AppConfig.String("k8sMasterIP")
+	return []string{
+		"sudo mkdir -p /etc/containerd",
+		"sudo test -f /etc/containerd/config.toml || sudo containerd config default | sudo tee /etc/containerd/config.toml",
+		fmt.Sprintf("sudo sed -i 's/<IP>/%s/g' /etc/containerd/config.toml", k8sMasterIP),
+		"sudo systemctl restart containerd",
+		"sudo systemctl enable kubelet",
+		fmt.Sprintf("sudo %s --node-name=%s", joinCmd, nodeName),
+	}
+}
+
+// RenderCloudConfig renders the user-data of a VM to cloud-config.
+// The root partition is always extended by growpart, which replaces DiskInitCmd.
+func RenderCloudConfig(hostname string, userData UserData) (string, error) {
+	config := cloudConfig{
+		Hostname:          hostname,
+		SshAuthorizedKeys: userData.SshAuthorizedKeys,
+		Growpart:          &growpart{Mode: "auto", Devices: []string{"/"}},
+		Packages:          userData.Packages,
+		PackageUpdate:     len(userData.Packages) > 0,
+	}
+	if len(userData.SshAuthorizedKeys) > 0 {
+		// keep the default user of the image, and also add the keys to it
+		config.Users = []string{"default"}
+	}
+	for _, cmd := range userData.RunCmd {
+		config.RunCmd = append(config.RunCmd, []string{"sh", "-c", cmd})
+	}
+	if userData.JoinKubernetes {
+		if strings.TrimSpace(userData.JoinCmd) == "" {
+			return "", fmt.Errorf("VM [%s] should join Kubernetes, but the join command is empty", hostname)
+		}
+		for _, cmd := range nodeJoinCmds(hostname, strings.TrimSpace(userData.JoinCmd)) {
+			config.RunCmd = append(config.RunCmd, []string{"sh", "-c", cmd})
+		}
+	}
+
+	content, err := yaml.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("marshal cloud-config of VM [%s], error: %w", hostname, err)
+	}
+	return cloudConfigHeader + string(content), nil
+}
+
+// fill the join command into the user-data of the VMs that should join Kubernetes by cloud-init. We get the join command only once for all VMs.
+func prepareUserData(vms []IaasVm) error {
+	var joinCmd string
+	for i := range vms {
+		if !vms[i].JoinedByCloudInit() || strings.TrimSpace(vms[i].UserData.JoinCmd) != "" {
+			continue
+		}
+		if joinCmd == "" {
+			if K8sSimulated() {
+				joinCmd = "kubeadm join simulated"
+			} else {
+				var err error
+				if joinCmd, err = GetJoinCmd(); err != nil {
+					outErr := fmt.Errorf("get the join command for the user-data of VMs, error: %w", err)
+					beego.Error(outErr)
+					return outErr
+				}
+			}
+		}
+		// copy the user-data, because the same pointer may be shared by several VMs
+		userData := *vms[i].UserData
+		userData.JoinCmd = joinCmd
+		vms[i].UserData = &userData
+	}
+	return nil
+}
+
+// create a VM with its user-data on a cloud
+func createVMWithUserData(cloud Iaas, v IaasVm) (*IaasVm, error) {
+	creator, ok := cloud.(userDataCreator)
+	if !ok {
+		outErr := fmt.Errorf("cloud [%s] type [%s] does not support user-data", cloud.ShowName(), cloud.ShowType())
+		beego.Error(outErr)
+		return nil, outErr
+	}
+	userData, err := RenderCloudConfig(v.Name, *v.UserData)
+	if err != nil {
+		outErr := fmt.Errorf("render the user-data of VM [%s], error: %w", v.Name, err)
+		beego.Error(outErr)
+		return nil, outErr
+	}
+	createdVM, err := creator.CreateVMWithUserData(v.Name, int(v.VCpu), int(v.Ram), int(v.Storage), userData)
+	if err != nil {
+		return nil, err
+	}
+	// AddNode needs the user-data to know whether the VM joins Kubernetes by itself.
+	createdVM.UserData = v.UserData
+	return createdVM, nil
+}
@@ -0,0 +1,179 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/yaml"
+)
+
+func TestRenderCloudConfig(t *testing.T) {
+	testCases := []struct {
+		name           string
+		userData       UserData
+		expectedErr    bool
+		expectedKeys   []string
+		expectedRunCmd []string // the substrings of the commands in order
+	}{
+		{
+			name:         "only growpart",
+			userData:     UserData{},
+			expectedKeys: []string{"growpart", "hostname"},
+		},
+		{
+			name: "keys, packages and commands",
+			userData: UserData{
+				SshAuthorizedKeys: []string{"ssh-ed25519 AAAA test"},
+				Packages:          []string{"nfs-common"},
+				RunCmd:            []string{"echo hello > /tmp/hello"},
+			},
+			expectedKeys:   []string{"growpart", "hostname", "package_update", "packages", "runcmd", "ssh_authorized_keys", "users"},
+			expectedRunCmd: []string{"echo hello > /tmp/hello"},
+		},
+		{
+			name: "join Kubernetes",
+			userData: UserData{
+				RunCmd:         []string{"echo before join"},
+				JoinKubernetes: true,
+				JoinCmd:        "kubeadm join 10.0.0.1:6443 --token abc --discovery-token-ca-cert-hash sha256:123",
+			},
+			expectedKeys:   []string{"growpart", "hostname", "runcmd"},
+			expectedRunCmd: []string{"echo before join", "mkdir -p /etc/containerd", "systemctl restart containerd", "kubeadm join 10.0.0.1:6443 --token abc --discovery-token-ca-cert-hash sha256:123 --node-name=vm1"},
+		},
+		{
+			name:        "join Kubernetes without join command",
+			userData:    UserData{JoinKubernetes: true},
+			expectedErr: true,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		content, err := RenderCloudConfig("vm1", testCase.userData)
+		if testCase.expectedErr {
+			assert.NotNil(t, err, fmt.Sprintf("%s: error is expected", testCase.name))
+			continue
+		}
+		assert.Nil(t, err, fmt.Sprintf("%s: error is not expected", testCase.name))
+		assert.True(t, strings.HasPrefix(content, "#cloud-config\n"), fmt.Sprintf("%s: header is not expected", testCase.name))
+
+		var config map[string]interface{}
+		assert.Nil(t, yaml.Unmarshal([]byte(content), &config), fmt.Sprintf("%s: cloud-config cannot be parsed", testCase.name))
+		var keys []string
+		for key := range config {
+			keys = append(keys, key)
+		}
+		assert.ElementsMatch(t, testCase.expectedKeys, keys, fmt.Sprintf("%s: keys are not expected", testCase.name))
+		assert.Equal(t, "vm1", config["hostname"])
+
+		// every expected command is found after the previous one
+		var runCmd []string
+		if cmds, ok := config["runcmd"].([]interface{}); ok {
+			for _, cmd := range cmds {
+				parts := cmd.([]interface{})
+				runCmd = append(runCmd, parts[len(parts)-1].(string))
+			}
+		}
+		next := 0
+		for _, expectedCmd := range testCase.expectedRunCmd {
+			for next < len(runCmd) && !strings.Contains(runCmd[next], expectedCmd) {
+				next++
+			}
+			assert.Less(t, next, len(runCmd), fmt.Sprintf("%s: command [%s] is not found in order", testCase.name, expectedCmd))
+		}
+	}
+}
+
+func TestPrepareUserData(t *testing.T) {
+	useSimulatedK8s(t)
+
+	shared := &UserData{JoinKubernetes: true}
+	vms := []IaasVm{
+		{Name: "vm1", UserData: shared},
+		{Name: "vm2", UserData: shared},
+		{Name: "vm3", UserData: &UserData{JoinKubernetes: true, JoinCmd: "kubeadm join given"}},
+		{Name: "vm4", UserData: &UserData{Packages: []string{"vim"}}},
+		{Name: "vm5"},
+	}
+	assert.Nil(t, prepareUserData(vms))
+	assert.NotEmpty(t, vms[0].UserData.JoinCmd)
+	assert.Equal(t, vms[0].UserData.JoinCmd, vms[1].UserData.JoinCmd)
+	assert.Equal(t, "kubeadm join given", vms[2].UserData.JoinCmd)
+	assert.Empty(t, vms[3].UserData.JoinCmd)
+	assert.Nil(t, vms[4].UserData)
+	// the shared input is not changed
+	assert.Empty(t, shared.JoinCmd)
+
+	assert.True(t, vms[0].JoinedByCloudInit())
+	assert.False(t, vms[3].JoinedByCloudInit())
+	assert.False(t, vms[4].JoinedByCloudInit())
+}
+
+func TestUserDataMarshalJSON(t *testing.T) {
+	// the join command can be set in requests
+	var vm IaasVm
+	assert.Nil(t, json.Unmarshal([]byte(`{"name":"vm1","userData":{"joinKubernetes":true,"joinCmd":"kubeadm join 10.0.0.1:6443 --token abc"}}`), &vm))
+	if assert.NotNil(t, vm.UserData) {
+		assert.Equal(t, "kubeadm join 10.0.0.1:6443 --token abc", vm.UserData.JoinCmd)
+	}
+
+	// but it is not in responses
+	content, err := json.Marshal([]IaasVm{vm})
+	assert.Nil(t, err)
+	assert.NotContains(t, string(content), "joinCmd")
+	assert.NotContains(t, string(content), "--token")
+	assert.Contains(t, string(content), `"joinKubernetes":true`)
+	// and the VM is not changed by marshaling
+	assert.Equal(t, "kubeadm join 10.0.0.1:6443 --token abc", vm.UserData.JoinCmd)
+}
+
+// a cloud whose driver cannot deliver user-data
+type noUserDataCloud struct {
+	Iaas
+}
+
+func TestJoinByCloudInitIfSupported(t *testing.T) {
+	fake := NewFake("fake-join", ResSet{VCpu: 8, Ram: 8192, Storage: 100})
+	noUserData := noUserDataCloud{Iaas: NewFake("fake-no-user-data", ResSet{VCpu: 8, Ram: 8192, Storage: 100})}
+	Clouds[fake.Name] = fake
+	Clouds["fake-no-user-data"] = noUserData
+	t.Cleanup(func() {
+		delete(Clouds, fake.Name)
+		delete(Clouds, "fake-no-user-data")
+	})
+
+	given := &UserData{Packages: []string{"vim"}}
+	vms := []IaasVm{
+		{Name: "vm1", Cloud: fake.Name},
+		{Name: "vm2", Cloud: "fake-no-user-data"},
+		{Name: "vm3", Cloud: fake.Name, UserData: given},
+		{Name: "vm4", Cloud: "not-exist"},
+	}
+	out := JoinByCloudInitIfSupported(vms)
+	assert.True(t, out[0].JoinedByCloudInit())
+	assert.Nil(t, out[1].UserData)
+	assert.Same(t, given, out[2].UserData)
+	assert.Nil(t, out[3].UserData)
+	// the input is not changed
+	assert.Nil(t, vms[0].UserData)
+}
+
+func TestCreateVMWithUserData(t *testing.T) {
+	fake := NewFake("fake-user-data", ResSet{VCpu: 8, Ram: 8192, Storage: 100})
+	userData := &UserData{JoinKubernetes: true, JoinCmd: "kubeadm join 10.0.0.1:6443"}
+
+	vm, err := createVMWithUserData(fake, IaasVm{Name: "vm1", VCpu: 2, Ram: 2048, Storage: 20, UserData: userData})
+	assert.Nil(t, err)
+	assert.Equal(t, "vm1", vm.Name)
+	assert.True(t, vm.JoinedByCloudInit())
+
+	// the rendering error is returned before creating the VM
+	_, err = createVMWithUserData(fake, IaasVm{Name: "vm2", VCpu: 2, Ram: 2048, Storage: 20, UserData: &UserData{JoinKubernetes: true}})
+	assert.NotNil(t, err)
+	vms, err := fake.ListAllVMs()
+	assert.Nil(t, err)
+	assert.Len(t, vms, 1)
+}
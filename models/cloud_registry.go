@@ -0,0 +1,551 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/spf13/viper"
+)
+
+/**
+NOTE:
+
+The clouds are declared in conf/iaas.json, and the registry keeps every declared cloud in the global map Clouds. The registry can be changed in two ways:
+1. The APIs POST /cloud, PUT /cloud/:cloudName, and DELETE /cloud/:cloudName change the registry and write the change into conf/iaas.json.
+2. If conf/iaas.json is edited, the registry reloads it every "IaasReloadSec" seconds, and adds, updates, or removes the clouds according to it.
+The APIs and the reloading can change Clouds when other goroutines are reading it, so Clouds should be read by GetIaas, ListIaas, and IaasNames.
+
+A wrong declaration of a cloud is a CloudConfigError, and it does not stop emcontroller. The declarations whose type is not supported are kept in conf/iaas.json but not registered, so that a cloud can be disabled by changing its type, such as "proxmox (excluded)".
+
+Other modules can register a CloudHook to be called when a cloud is added or removed. For example, the network test function creates and drops the network state of the cloud.
+*/
+
+const DefaultIaasReloadSec int = 30
+
+// the file that declares the clouds
+var IaasConfigPath string = "conf/iaas.json"
+
+// the fields that every type of cloud needs in its declaration. The values of these fields should be strings.
+var cloudRequiredFields map[string][]string = map[string][]string{
+	OpenstackIaas: {"authurl", "applicationcredentialid", "applicationcredentialsecret", "region", "weburl", "project_id", "imageid", "networkid", "securitygroup", "keyname", "sshpempath", "root_password"},
+	ProxmoxIaas:   {"ip", "port", "proxmox_user", "proxmox_password", "token_name", "token_secret", "sshpempath", "root_password", "template_id"},
+	LocalIaas:     {},
+	FakeIaas:      {},
+}
+
+var (
+	cloudsMu          sync.RWMutex                                                                // protects Clouds, cloudConfigs, and iaasConfig
+	cloudConfigs      map[string]map[string]interface{} = make(map[string]map[string]interface{}) // the declaration of every cloud in Clouds
+	registryMu        sync.Mutex                                                                  // only one change of the registry at a time, because initializing clouds and hooks can be slow
+	iaasConfigModTime time.Time                                                                   // the modification time of conf/iaas.json when it was loaded
+	cloudHooks        []CloudHook
+)
+
+// CloudConfigError is the error in the declaration of a cloud.
+type CloudConfigError struct {
+	Cloud    string
+	Problems []string
+}
+
+func (e *CloudConfigError) Error() string {
+	return fmt.Sprintf("invalid declaration of cloud [%s]: %s", e.Cloud, strings.Join(e.Problems, "; "))
+}
+
+// CloudHook is called after a cloud is added into or removed from the registry. A nil function is skipped.
+type CloudHook struct {
+	Name     string
+	OnAdd    func(cloud Iaas) error
+	OnRemove func(cloud Iaas) error
+}
+
+// RegisterCloudHook registers a hook. The hook with the same name is replaced.
+func RegisterCloudHook(hook CloudHook) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for i := range cloudHooks {
+		if cloudHooks[i].Name == hook.Name {
+			cloudHooks[i] = hook
+			return
+		}
+	}
+	cloudHooks = append(cloudHooks, hook)
+}
+
+// GetIaas gets a registered cloud
+func GetIaas(cloudName string) (Iaas, bool) {
+	cloudsMu.RLock()
+	defer cloudsMu.RUnlock()
+	cloud, exist := Clouds[cloudName]
+	return cloud, exist
+}
+
+// ListIaas returns a copy of the registered clouds, which will not be changed by the registry.
+func ListIaas() map[string]Iaas {
+	cloudsMu.RLock()
+	defer cloudsMu.RUnlock()
+	clouds := make(map[string]Iaas, len(Clouds))
+	for name, cloud := range Clouds {
+		clouds[name] = cloud
+	}
+	return clouds
+}
+
+// IaasNames returns the sorted names of the registered clouds
+func IaasNames() []string {
+	cloudsMu.RLock()
+	defer cloudsMu.RUnlock()
+	names := make([]string, 0, len(Clouds))
+	for name := range Clouds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func cloudTypeSupported(cloudType string) bool {
+	_, supported := cloudRequiredFields[cloudType]
+	return supported
+}
+
+// ValidateCloudConfig checks the declaration of a cloud, and returns a CloudConfigError with all problems in it.
+func ValidateCloudConfig(paras map[string]interface{}) error {
+	var problems []string
+	checkString := func(field string) {
+		value, exist := paras[field]
+		if !exist || value == nil {
+			problems = append(problems, fmt.Sprintf("field [%s] is missing", field))
+		} else if _, ok := value.(string); !ok {
+			problems = append(problems, fmt.Sprintf("field [%s] should be a string, but it is [%v]", field, value))
+		}
+	}
+
+	checkString("name")
+	checkString("type")
+	cloudName, _ := paras["name"].(string)
+	cloudType, _ := paras["type"].(string)
+	if _, isString := paras["name"].(string); isString {
+		// the name of a cloud is also the name of its table of network state
+		if _, err := mySqlIdent(cloudName); err != nil {
+			problems = append(problems, fmt.Sprintf("field [name] is invalid: %s", err.Error()))
+		}
+	}
+	if _, isString := paras["type"].(string); isString {
+		if requiredFields, supported := cloudRequiredFields[cloudType]; !supported {
+			problems = append(problems, fmt.Sprintf("type [%s] is not supported", cloudType))
+		} else {
+			for _, field := range requiredFields {
+				checkString(field)
+			}
+		}
+		if cloudType == FakeIaas {
+			problems = append(problems, validateFakeConfig(paras)...)
+		}
+	}
+
+	if len(problems) != 0 {
+		return &CloudConfigError{Cloud: cloudName, Problems: problems}
+	}
+	return nil
+}
+
+// initialize a cloud from its declaration. The panics in the initialization, such as the failure of Openstack authentication, are returned as errors.
+func newCloudFromConfig(paras map[string]interface{}) (cloud Iaas, err error) {
+	if err := ValidateCloudConfig(paras); err != nil {
+		return nil, err
+	}
+	paras, err = resolveCloudSecrets(paras)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			cloud = nil
+			err = fmt.Errorf("initialize cloud [%s] type [%s], error: %v", paras["name"], paras["type"], r)
+		}
+	}()
+
+	switch paras["type"].(string) {
+	case OpenstackIaas:
+		return InitOpenstack(paras), nil
+	case ProxmoxIaas:
+		return InitProxmox(paras), nil
+	case LocalIaas: // ✅ local VM
+		return InitLocal(paras), nil
+	case FakeIaas:
+		return InitFake(paras), nil
+	}
+	return nil, fmt.Errorf("cloud type [%s] is not supported", paras["type"])
+}
+
+// read the declarations of clouds in conf/iaas.json
+func readCloudConfigs() ([]map[string]interface{}, time.Time, error) {
+	info, err := os.Stat(IaasConfigPath)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("stat [%s], error: %w", IaasConfigPath, err)
+	}
+	content, err := os.ReadFile(IaasConfigPath)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("read [%s], error: %w", IaasConfigPath, err)
+	}
+	var iaasFile struct {
+		Iaas []map[string]interface{} `json:"iaas"`
+	}
+	if err := json.Unmarshal(content, &iaasFile); err != nil {
+		return nil, time.Time{}, fmt.Errorf("unmarshal [%s], error: %w", IaasConfigPath, err)
+	}
+	return iaasFile.Iaas, info.ModTime(), nil
+}
+
+// put a cloud into the registry and call the OnAdd hooks. The caller should hold registryMu.
+func registerCloud(cloud Iaas, paras map[string]interface{}) []error {
+	cloudsMu.Lock()
+	Clouds[cloud.ShowName()] = cloud
+	cloudConfigs[cloud.ShowName()] = paras
+	cloudsMu.Unlock()
+	beego.Info(fmt.Sprintf("Cloud [%s] type [%s] is registered.", cloud.ShowName(), cloud.ShowType()))
+
+	var errs []error
+	for _, hook := range cloudHooks {
+		if hook.OnAdd == nil {
+			continue
+		}
+		if err := hook.OnAdd(cloud); err != nil {
+			outErr := fmt.Errorf("hook [%s] after adding cloud [%s], error: %w", hook.Name, cloud.ShowName(), err)
+			beego.Error(outErr)
+			errs = append(errs, outErr)
+		}
+	}
+	return errs
+}
+
+// replace a registered cloud with the same name. The hooks are not called, because the cloud is still there. The caller should hold registryMu.
+func replaceCloud(cloud Iaas, paras map[string]interface{}) {
+	cloudsMu.Lock()
+	Clouds[cloud.ShowName()] = cloud
+	cloudConfigs[cloud.ShowName()] = paras
+	cloudsMu.Unlock()
+	beego.Info(fmt.Sprintf("Cloud [%s] type [%s] is updated.", cloud.ShowName(), cloud.ShowType()))
+}
+
+// remove a cloud from the registry and call the OnRemove hooks. The caller should hold registryMu.
+func unregisterCloud(cloudName string) []error {
+	cloudsMu.Lock()
+	cloud, exist := Clouds[cloudName]
+	delete(Clouds, cloudName)
+	delete(cloudConfigs, cloudName)
+	cloudsMu.Unlock()
+	if !exist {
+		return nil
+	}
+	beego.Info(fmt.Sprintf("Cloud [%s] type [%s] is unregistered.", cloud.ShowName(), cloud.ShowType()))
+
+	var errs []error
+	for _, hook := range cloudHooks {
+		if hook.OnRemove == nil {
+			continue
+		}
+		if err := hook.OnRemove(cloud); err != nil {
+			outErr := fmt.Errorf("hook [%s] after removing cloud [%s], error: %w", hook.Name, cloudName, err)
+			beego.Error(outErr)
+			errs = append(errs, outErr)
+		}
+	}
+	return errs
+}
+
+// make the registry consistent with the declarations. A cloud with a wrong declaration is not changed. The caller should hold registryMu.
+func reconcileClouds(iaasParas []map[string]interface{}) []error {
+	var errs []error
+	declared := make(map[string]bool)
+	for _, paras := range iaasParas {
+		cloudName := getStr(paras, "name")
+		cloudType := getStr(paras, "type")
+		if _, isString := paras["type"].(string); isString && !cloudTypeSupported(cloudType) {
+			beego.Info(fmt.Sprintf("Multi-cloud manager does not support cloud type [%s] of cloud [%s]", cloudType, cloudName))
+			continue
+		}
+		if declared[cloudName] {
+			outErr := &CloudConfigError{Cloud: cloudName, Problems: []string{"the name is declared more than once"}}
+			beego.Error(outErr)
+			errs = append(errs, outErr)
+			continue
+		}
+		declared[cloudName] = true
+
+		cloudsMu.RLock()
+		oldParas, exist := cloudConfigs[cloudName]
+		cloudsMu.RUnlock()
+		if exist && reflect.DeepEqual(oldParas, paras) {
+			continue
+		}
+
+		cloud, err := newCloudFromConfig(paras)
+		if err != nil {
+			beego.Error(err)
+			errs = append(errs, err)
+			continue
+		}
+		if exist {
+			replaceCloud(cloud, paras)
+		} else {
+			errs = append(errs, registerCloud(cloud, paras)...)
+		}
+	}
+
+	for _, cloudName := range IaasNames() {
+		if !declared[cloudName] {
+			errs = append(errs, unregisterCloud(cloudName)...)
+		}
+	}
+	return errs
+}
+
+// read conf/iaas.json with viper, which is used by getImagePathForCloud
+func readIaasConfig() error {
+	newConfig := viper.New()
+	newConfig.SetConfigFile(IaasConfigPath)
+	newConfig.SetConfigType("json")
+	if err := newConfig.ReadInConfig(); err != nil {
+		return fmt.Errorf("parse [%s], error: %w", IaasConfigPath, err)
+	}
+	cloudsMu.Lock()
+	iaasConfig = newConfig
+	cloudsMu.Unlock()
+	return nil
+}
+
+// ===== Init Clouds =====
+func InitClouds() {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if err := readIaasConfig(); err != nil {
+		panic(fmt.Errorf("fatal error: %w", err))
+	}
+	iaasParas, modTime, err := readCloudConfigs()
+	if err != nil {
+		panic(fmt.Errorf("fatal error: %w", err))
+	}
+	iaasConfigModTime = modTime
+
+	if errs := reconcileClouds(iaasParas); len(errs) != 0 {
+		beego.Error(fmt.Sprintf("Some clouds are not initialized, error: %s", HandleErrSlice(errs).Error()))
+	}
+
+	beego.Info(fmt.Sprintf("All %d clouds are initialized.", len(IaasNames())))
+}
+
+// ReloadClouds reloads conf/iaas.json if it is changed after the last loading.
+func ReloadClouds() {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	info, err := os.Stat(IaasConfigPath)
+	if err != nil {
+		beego.Error(fmt.Sprintf("Reload clouds, stat [%s], error: %s", IaasConfigPath, err.Error()))
+		return
+	}
+	if info.ModTime().Equal(iaasConfigModTime) {
+		return
+	}
+
+	beego.Info(fmt.Sprintf("[%s] is changed, so we reload the clouds.", IaasConfigPath))
+	iaasParas, modTime, err := readCloudConfigs()
+	if err != nil {
+		beego.Error(fmt.Sprintf("Reload clouds, error: %s", err.Error()))
+		return
+	}
+	if err := readIaasConfig(); err != nil {
+		beego.Error(fmt.Sprintf("Reload clouds, error: %s", err.Error()))
+	}
+	iaasConfigModTime = modTime
+
+	if errs := reconcileClouds(iaasParas); len(errs) != 0 {
+		beego.Error(fmt.Sprintf("Reload clouds, error: %s", HandleErrSlice(errs).Error()))
+		return
+	}
+	beego.Info(fmt.Sprintf("Clouds are reloaded, now there are %d clouds.", len(IaasNames())))
+}
+
+// write the declaration of a cloud into conf/iaas.json. If paras is nil, the declaration is removed.
+// The other declarations and the other fields in the file are not changed. The caller should hold registryMu.
+func writeCloudConfig(cloudName string, paras map[string]interface{}) error {
+	info, err := os.Stat(IaasConfigPath)
+	if err != nil {
+		return fmt.Errorf("stat [%s], error: %w", IaasConfigPath, err)
+	}
+	content, err := os.ReadFile(IaasConfigPath)
+	if err != nil {
+		return fmt.Errorf("read [%s], error: %w", IaasConfigPath, err)
+	}
+	var iaasFile map[string]json.RawMessage
+	if err := json.Unmarshal(content, &iaasFile); err != nil {
+		return fmt.Errorf("unmarshal [%s], error: %w", IaasConfigPath, err)
+	}
+	var declarations []json.RawMessage
+	if rawIaas, exist := iaasFile["iaas"]; exist {
+		if err := json.Unmarshal(rawIaas, &declarations); err != nil {
+			return fmt.Errorf("unmarshal \"iaas\" in [%s], error: %w", IaasConfigPath, err)
+		}
+	}
+
+	var newDeclaration json.RawMessage
+	if paras != nil {
+		if newDeclaration, err = json.Marshal(paras); err != nil {
+			return fmt.Errorf("marshal the declaration of cloud [%s], error: %w", cloudName, err)
+		}
+	}
+
+	var newDeclarations []json.RawMessage
+	replaced := false
+	for _, declaration := range declarations {
+		var nameOnly struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(declaration, &nameOnly); err == nil && nameOnly.Name == cloudName {
+			if newDeclaration != nil && !replaced {
+				newDeclarations = append(newDeclarations, newDeclaration)
+				replaced = true
+			}
+			continue
+		}
+		newDeclarations = append(newDeclarations, declaration)
+	}
+	if newDeclaration != nil && !replaced {
+		newDeclarations = append(newDeclarations, newDeclaration)
+	}
+	if newDeclarations == nil {
+		newDeclarations = []json.RawMessage{}
+	}
+
+	if iaasFile == nil {
+		iaasFile = make(map[string]json.RawMessage)
+	}
+	if iaasFile["iaas"], err = json.Marshal(newDeclarations); err != nil {
+		return fmt.Errorf("marshal the declarations of clouds, error: %w", err)
+	}
+	newContent, err := json.MarshalIndent(iaasFile, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal [%s], error: %w", IaasConfigPath, err)
+	}
+
+	// write a temporary file and rename it, so that the file will not be broken if emcontroller stops when writing.
+	tmpPath := filepath.Join(filepath.Dir(IaasConfigPath), "."+filepath.Base(IaasConfigPath)+".tmp")
+	if err := os.WriteFile(tmpPath, append(newContent, '\n'), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("write [%s], error: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, IaasConfigPath); err != nil {
+		return fmt.Errorf("rename [%s] to [%s], error: %w", tmpPath, IaasConfigPath, err)
+	}
+
+	// we have applied the change, so the reloading does not need to do it again.
+	if info, err := os.Stat(IaasConfigPath); err == nil {
+		iaasConfigModTime = info.ModTime()
+	}
+	return nil
+}
+
+// AddCloud adds a new cloud into the registry and conf/iaas.json.
+func AddCloud(paras map[string]interface{}) (error, int) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if err := ValidateCloudConfig(paras); err != nil {
+		beego.Error(err)
+		return err, http.StatusBadRequest
+	}
+	cloudName := paras["name"].(string)
+	if _, exist := GetIaas(cloudName); exist {
+		outErr := fmt.Errorf("cloud [%s] already exists", cloudName)
+		beego.Error(outErr)
+		return outErr, http.StatusConflict
+	}
+
+	cloud, err := newCloudFromConfig(paras)
+	if err != nil {
+		outErr := fmt.Errorf("add cloud [%s], error: %w", cloudName, err)
+		beego.Error(outErr)
+		return outErr, http.StatusBadRequest
+	}
+	if err := writeCloudConfig(cloudName, paras); err != nil {
+		outErr := fmt.Errorf("add cloud [%s], error: %w", cloudName, err)
+		beego.Error(outErr)
+		return outErr, http.StatusInternalServerError
+	}
+
+	if errs := registerCloud(cloud, paras); len(errs) != 0 {
+		outErr := fmt.Errorf("cloud [%s] is added, but the hooks failed: %w", cloudName, HandleErrSlice(errs))
+		beego.Error(outErr)
+		return outErr, http.StatusInternalServerError
+	}
+	return nil, http.StatusCreated
+}
+
+// UpdateCloud replaces the declaration of a cloud. The name of a cloud cannot be changed, but its type can.
+func UpdateCloud(cloudName string, paras map[string]interface{}) (error, int) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exist := GetIaas(cloudName); !exist {
+		outErr := fmt.Errorf("cloud [%s] not found", cloudName)
+		beego.Error(outErr)
+		return outErr, http.StatusNotFound
+	}
+	if err := ValidateCloudConfig(paras); err != nil {
+		beego.Error(err)
+		return err, http.StatusBadRequest
+	}
+	if paras["name"].(string) != cloudName {
+		outErr := fmt.Errorf("the name of cloud [%s] cannot be changed to [%s]", cloudName, paras["name"])
+		beego.Error(outErr)
+		return outErr, http.StatusBadRequest
+	}
+
+	cloud, err := newCloudFromConfig(paras)
+	if err != nil {
+		outErr := fmt.Errorf("update cloud [%s], error: %w", cloudName, err)
+		beego.Error(outErr)
+		return outErr, http.StatusBadRequest
+	}
+	if err := writeCloudConfig(cloudName, paras); err != nil {
+		outErr := fmt.Errorf("update cloud [%s], error: %w", cloudName, err)
+		beego.Error(outErr)
+		return outErr, http.StatusInternalServerError
+	}
+
+	replaceCloud(cloud, paras)
+	return nil, http.StatusOK
+}
+
+// DeleteCloud removes a cloud from the registry and conf/iaas.json. The VMs on the cloud are not deleted, except the network test VMs.
+func DeleteCloud(cloudName string) (error, int) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exist := GetIaas(cloudName); !exist {
+		outErr := fmt.Errorf("cloud [%s] not found", cloudName)
+		beego.Error(outErr)
+		return outErr, http.StatusNotFound
+	}
+	if err := writeCloudConfig(cloudName, nil); err != nil {
+		outErr := fmt.Errorf("delete cloud [%s], error: %w", cloudName, err)
+		beego.Error(outErr)
+		return outErr, http.StatusInternalServerError
+	}
+
+	if errs := unregisterCloud(cloudName); len(errs) != 0 {
+		outErr := fmt.Errorf("cloud [%s] is deleted, but the hooks failed: %w", cloudName, HandleErrSlice(errs))
+		beego.Error(outErr)
+		return outErr, http.StatusInternalServerError
+	}
+	return nil, http.StatusOK
+}
@@ -0,0 +1,264 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCloudConfig(t *testing.T) {
+	testCases := []struct {
+		name             string
+		paras            map[string]interface{}
+		expectedProblems int
+	}{
+		{
+			name:             "fake",
+			paras:            map[string]interface{}{"type": FakeIaas, "name": "FAKE1", "vcpu": "8"},
+			expectedProblems: 0,
+		},
+		{
+			name:             "fake with number quotas",
+			paras:            map[string]interface{}{"type": FakeIaas, "name": "FAKE1", "vcpu": 8.0, "ram": 8192.0, "storage": "", "vm": "0"},
+			expectedProblems: 0,
+		},
+		{
+			name:             "fake with invalid quotas",
+			paras:            map[string]interface{}{"type": FakeIaas, "name": "FAKE1", "vcpu": "eight", "ram": "0", "storage": -1.0, "vm": "-1", "fail_rate": "-0.1"},
+			expectedProblems: 5,
+		},
+		{
+			name:             "fake with fail_rate 1",
+			paras:            map[string]interface{}{"type": FakeIaas, "name": "FAKE1", "fail_rate": 1.0},
+			expectedProblems: 0,
+		},
+		{
+			name:             "fake with fail_rate greater than 1",
+			paras:            map[string]interface{}{"type": FakeIaas, "name": "FAKE1", "fail_rate": "1.5"},
+			expectedProblems: 1,
+		},
+		{
+			name:             "no name",
+			paras:            map[string]interface{}{"type": FakeIaas},
+			expectedProblems: 1,
+		},
+		{
+			name:             "name is not a string",
+			paras:            map[string]interface{}{"type": FakeIaas, "name": 12},
+			expectedProblems: 1,
+		},
+		{
+			name:             "name is too long",
+			paras:            map[string]interface{}{"type": LocalIaas, "name": fmt.Sprintf("%070d", 0)},
+			expectedProblems: 1,
+		},
+		{
+			name:             "unsupported type",
+			paras:            map[string]interface{}{"type": "vmware", "name": "V1"},
+			expectedProblems: 1,
+		},
+		{
+			name: "proxmox without token and with a number port",
+			paras: map[string]interface{}{
+				"type":             ProxmoxIaas,
+				"name":             "NOKIA1",
+				"ip":               "192.168.100.11",
+				"port":             8006,
+				"proxmox_user":     "root",
+				"proxmox_password": "x",
+				"sshpempath":       "/root/.ssh/id_rsa",
+				"root_password":    "x",
+				"template_id":      "103",
+			},
+			expectedProblems: 3,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		err := ValidateCloudConfig(testCase.paras)
+		if testCase.expectedProblems == 0 {
+			assert.Nil(t, err, fmt.Sprintf("%s: error is not expected", testCase.name))
+			continue
+		}
+		configErr, ok := err.(*CloudConfigError)
+		if assert.True(t, ok, fmt.Sprintf("%s: error should be a CloudConfigError, but it is %v", testCase.name, err)) {
+			t.Logf("error: %s", configErr.Error())
+			assert.Len(t, configErr.Problems, testCase.expectedProblems, fmt.Sprintf("%s: problems are not expected", testCase.name))
+		}
+	}
+}
+
+// use a temporary iaas.json and an empty registry in a test
+func useTempCloudRegistry(t *testing.T, content string) string {
+	oldPath, oldClouds, oldConfigs, oldHooks, oldIaasConfig := IaasConfigPath, Clouds, cloudConfigs, cloudHooks, iaasConfig
+	t.Cleanup(func() {
+		IaasConfigPath, Clouds, cloudConfigs, cloudHooks, iaasConfig = oldPath, oldClouds, oldConfigs, oldHooks, oldIaasConfig
+	})
+
+	IaasConfigPath = filepath.Join(t.TempDir(), "iaas.json")
+	Clouds = make(map[string]Iaas)
+	cloudConfigs = make(map[string]map[string]interface{})
+	cloudHooks = nil
+	if err := os.WriteFile(IaasConfigPath, []byte(content), 0644); err != nil {
+		t.Fatalf("write %s, error: %s", IaasConfigPath, err.Error())
+	}
+	return IaasConfigPath
+}
+
+// the names of the clouds declared in iaas.json
+func declaredCloudNames(t *testing.T, path string) []string {
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	var iaasFile struct {
+		Iaas []struct {
+			Name string `json:"name"`
+		} `json:"iaas"`
+	}
+	assert.Nil(t, json.Unmarshal(content, &iaasFile))
+	var names []string
+	for _, declaration := range iaasFile.Iaas {
+		names = append(names, declaration.Name)
+	}
+	return names
+}
+
+func TestCloudRegistry(t *testing.T) {
+	path := useTempCloudRegistry(t, `{
+  "iaas": [
+    {"type": "fake", "name": "FAKE1", "vcpu": "8", "ram": "8192", "storage": "100"},
+    {"type": "proxmox (excluded)", "name": "HPE1"},
+    {"type": "proxmox", "name": "BROKEN"}
+  ],
+  "imagePathMap": {"LOCAL1": "/var/lib/libvirt/images/ubuntu.qcow2"}
+}`)
+
+	var added, removed []string
+	RegisterCloudHook(CloudHook{
+		Name:     "test",
+		OnAdd:    func(cloud Iaas) error { added = append(added, cloud.ShowName()); return nil },
+		OnRemove: func(cloud Iaas) error { removed = append(removed, cloud.ShowName()); return nil },
+	})
+
+	// the wrong declaration does not stop the initialization
+	InitClouds()
+	assert.Equal(t, []string{"FAKE1"}, IaasNames())
+	assert.Equal(t, []string{"FAKE1"}, added)
+	assert.Equal(t, "/var/lib/libvirt/images/ubuntu.qcow2", getImagePathForCloud(IaasVm{Cloud: "LOCAL1"}))
+
+	fake2 := map[string]interface{}{"type": FakeIaas, "name": "FAKE2", "vcpu": "4"}
+	testCases := []struct {
+		name               string
+		do                 func() (error, int)
+		expectedStatusCode int
+		expectedClouds     []string
+	}{
+		{
+			name:               "add",
+			do:                 func() (error, int) { return AddCloud(fake2) },
+			expectedStatusCode: http.StatusCreated,
+			expectedClouds:     []string{"FAKE1", "FAKE2"},
+		},
+		{
+			name:               "add existing",
+			do:                 func() (error, int) { return AddCloud(fake2) },
+			expectedStatusCode: http.StatusConflict,
+			expectedClouds:     []string{"FAKE1", "FAKE2"},
+		},
+		{
+			name:               "add invalid",
+			do:                 func() (error, int) { return AddCloud(map[string]interface{}{"type": ProxmoxIaas, "name": "NOKIA1"}) },
+			expectedStatusCode: http.StatusBadRequest,
+			expectedClouds:     []string{"FAKE1", "FAKE2"},
+		},
+		{
+			name: "update",
+			do: func() (error, int) {
+				return UpdateCloud("FAKE2", map[string]interface{}{"type": FakeIaas, "name": "FAKE2", "vcpu": "16"})
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedClouds:     []string{"FAKE1", "FAKE2"},
+		},
+		{
+			name: "update name",
+			do: func() (error, int) {
+				return UpdateCloud("FAKE2", map[string]interface{}{"type": FakeIaas, "name": "FAKE3"})
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedClouds:     []string{"FAKE1", "FAKE2"},
+		},
+		{
+			name: "update not found",
+			do: func() (error, int) {
+				return UpdateCloud("FAKE3", map[string]interface{}{"type": FakeIaas, "name": "FAKE3"})
+			},
+			expectedStatusCode: http.StatusNotFound,
+			expectedClouds:     []string{"FAKE1", "FAKE2"},
+		},
+		{
+			name:               "delete",
+			do:                 func() (error, int) { return DeleteCloud("FAKE1") },
+			expectedStatusCode: http.StatusOK,
+			expectedClouds:     []string{"FAKE2"},
+		},
+		{
+			name:               "delete not found",
+			do:                 func() (error, int) { return DeleteCloud("FAKE1") },
+			expectedStatusCode: http.StatusNotFound,
+			expectedClouds:     []string{"FAKE2"},
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		err, statusCode := testCase.do()
+		assert.Equal(t, testCase.expectedStatusCode, statusCode, fmt.Sprintf("%s: status code is not expected, error: %v", testCase.name, err))
+		assert.Equal(t, testCase.expectedClouds, IaasNames(), fmt.Sprintf("%s: clouds are not expected", testCase.name))
+	}
+
+	// the changes are in iaas.json, and the other declarations and fields are kept
+	assert.Equal(t, []string{"HPE1", "BROKEN", "FAKE2"}, declaredCloudNames(t, path))
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "imagePathMap")
+	fake, _ := GetIaas("FAKE2")
+	assert.Equal(t, float64(16), fake.(*Fake).Limit.VCpu)
+	assert.Equal(t, []string{"FAKE1", "FAKE2"}, added)
+	assert.Equal(t, []string{"FAKE1"}, removed)
+}
+
+func TestReloadClouds(t *testing.T) {
+	path := useTempCloudRegistry(t, `{"iaas": [
+    {"type": "fake", "name": "FAKE1", "vcpu": "8"},
+    {"type": "fake", "name": "FAKE2", "vcpu": "8"}
+  ]}`)
+	InitClouds()
+	assert.Equal(t, []string{"FAKE1", "FAKE2"}, IaasNames())
+	oldFake2, _ := GetIaas("FAKE2")
+
+	// not changed, not reloaded
+	ReloadClouds()
+	sameFake2, _ := GetIaas("FAKE2")
+	assert.Same(t, oldFake2, sameFake2)
+
+	// FAKE1 is changed, FAKE2 is removed, FAKE3 is added, and the wrong FAKE4 is skipped
+	err := os.WriteFile(path, []byte(`{"iaas": [
+    {"type": "fake", "name": "FAKE1", "vcpu": "32"},
+    {"type": "fake", "name": "FAKE3", "vcpu": "8"},
+    {"type": 4, "name": "FAKE4"}
+  ]}`), 0644)
+	assert.Nil(t, err)
+	future := time.Now().Add(time.Minute)
+	assert.Nil(t, os.Chtimes(path, future, future))
+
+	ReloadClouds()
+	assert.Equal(t, []string{"FAKE1", "FAKE3"}, IaasNames())
+	fake1, _ := GetIaas("FAKE1")
+	assert.Equal(t, float64(32), fake1.(*Fake).Limit.VCpu)
+}
@@ -1,6 +1,10 @@
 package models
 
 func InitSomeThing() {
+	// the secrets should be ready before the clouds and clients use them
+	InitSecrets()
+	InitImageRegistries()
+
 	// viper is case-insensitive, so all keys in iaas.json should be lowercase
 	InitClouds()
 
@@ -7,17 +7,17 @@ import (
 )
 
 const (
-	ControllerName        string        = "Multi-Cloud Manager"
-	UploadDir             string        = "upload/"
-	RequestTimeout        time.Duration = 5 * time.Minute
-	KubernetesNamespace   string        = "default"
-	defaultKubeConfigPath string        = "/root/.kube/config"
-	DeploymentSuffix      string        = "-deployment"
-	ServiceSuffix         string        = "-service"
+	ControllerName      string        = "Multi-Cloud Manager"
+	UploadDir           string        = "upload/"
+	RequestTimeout      time.Duration = 5 * time.Minute
+	KubernetesNamespace string        = "default"
+	DeploymentSuffix    string        = "-deployment"
+	ServiceSuffix       string        = "-service"
 
 	// type of clouds
 	OpenstackIaas string = "openstack"
 	ProxmoxIaas   string = "proxmox"
+	FakeIaas      string = "fake" // in-memory cloud for tests and demos
 
 	McmSign        string = "mcmcreated" // add this sign something, meaning that it is created by multi-cloud manager
 	WaitForTimeOut int    = 1800         // unit second. wait for 30 minutes when creating or deleting something. At first, it was 10 minutes, but Proxmox often use more than 10 minutes to clone a VM, so I changed this to 30 minutes.
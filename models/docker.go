@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
@@ -10,6 +11,7 @@ import (
 	"github.com/astaxie/beego"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
 )
 
 var cli *client.Client
@@ -92,11 +94,11 @@ func LoadImage(imageFile io.Reader) (string, error) {
 	return idOrRepoTag, nil
 }
 
-// user should give a imageName and a targetTag to tag the image
+// user should give a registry, an imageName and a targetTag to tag the image
 // curl -v -X POST http://192.168.100.36:19998/v1.41/images/192.168.100.36:5000/ubuntu:latest/tag?repo=ubuntu1:vds123456
-func TagImage(idOrRepoTag, imageName, targetTag string) (string, error) {
+func TagImage(idOrRepoTag string, registry *ImageRegistry, imageName, targetTag string) (string, error) {
 	ctx := context.Background()
-	targetRepoTag := DockerRegistry + "/" + imageName + ":" + targetTag
+	targetRepoTag := registry.Address + "/" + imageName + ":" + targetTag
 	err := cli.ImageTag(ctx, idOrRepoTag, targetRepoTag)
 	if err != nil {
 		beego.Error(fmt.Sprintf("error: %s", err.Error()))
@@ -106,22 +108,90 @@ func TagImage(idOrRepoTag, imageName, targetTag string) (string, error) {
 	return targetRepoTag, nil
 }
 
-// push the image from the Docker Engine to the Docker Registry
+// PushProgress is one message of the progress of pushing an image.
+type PushProgress struct {
+	ID       string `json:"id,omitempty"` // the layer
+	Status   string `json:"status,omitempty"`
+	Current  int64  `json:"current,omitempty"`
+	Total    int64  `json:"total,omitempty"`
+	Progress string `json:"progress,omitempty"` // the progress bar made by the Docker Engine
+	Digest   string `json:"digest,omitempty"`   // only in the last message
+	Error    string `json:"error,omitempty"`
+}
+
+// push the image from the Docker Engine to the Docker Registry, call onProgress for every progress message, and return the digest of the pushed image.
 // curl  -v -X POST http://192.168.100.36:19998/v1.41/images/192.168.100.36:5000/helloworld:latest/push -H "X-Registry-Auth: eyJ1c2VybmFtZSI6InN0cmluZyIsInBhc3N3b3JkIjoic3RyaW5nIiwiZW1haWwiOiJzdHJpbmciLCJzZXJ2ZXJhZGRyZXNzIjoic3RyaW5nIn0K"
 // the auth is the base64 of '{"username":"string","password":"string","email":"string","serveraddress":"string"}'
-func PushImage(repoTag string) (string, error) {
-	ctx := context.Background()
-	respBody, err := cli.ImagePush(ctx, repoTag, types.ImagePushOptions{RegistryAuth: "eyJ1c2VybmFtZSI6InN0cmluZyIsInBhc3N3b3JkIjoic3RyaW5nIiwiZW1haWwiOiJzdHJpbmciLCJzZXJ2ZXJhZGRyZXNzIjoic3RyaW5nIn0K"})
+func PushImage(ctx context.Context, repoTag string, registry *ImageRegistry, onProgress func(PushProgress)) (string, error) {
+	auth, err := registry.EncodedAuth()
+	if err != nil {
+		outErr := fmt.Errorf("Encode the auth of registry [%s], error: %w", registry.Name, err)
+		beego.Error(outErr)
+		return "", outErr
+	}
+	// the garbage collection only runs on the default registry, so only the pushes to it wait for it.
+	if registry.Name == DefaultImageRegistry().Name {
+		registryGcMu.RLock()
+		defer registryGcMu.RUnlock()
+	}
+	respBody, err := cli.ImagePush(ctx, repoTag, types.ImagePushOptions{RegistryAuth: auth})
 	if err != nil {
 		beego.Error(fmt.Sprintf("Push image error: %s", err.Error()))
 		return "", err
 	}
-	b, err := io.ReadAll(respBody)
+	defer respBody.Close()
+
+	digest, err := readPushProgress(respBody, onProgress)
 	if err != nil {
-		beego.Error(fmt.Sprintf("Read response error: %s", err.Error()))
-		return "", err
+		outErr := fmt.Errorf("Push image [%s] to registry [%s], error: %w", repoTag, registry.Name, err)
+		beego.Error(outErr)
+		return "", outErr
+	}
+	beego.Info(fmt.Sprintf("Push image [%s] to registry [%s], digest: %s", repoTag, registry.Name, digest))
+	return digest, nil
+}
+
+// the response of pushing is a stream of JSON messages, like:
+// {"status":"Pushing","progressDetail":{"current":512,"total":1024},"progress":"[====>   ]","id":"e07ee1baac5f"}
+// {"progressDetail":{},"aux":{"Tag":"latest","Digest":"sha256:xxx","Size":525}}
+// an error is also a message, like {"errorDetail":{"message":"xxx"},"error":"xxx"}
+func readPushProgress(stream io.Reader, onProgress func(PushProgress)) (string, error) {
+	var digest string
+	decoder := json.NewDecoder(stream)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); err == io.EOF {
+			return digest, nil
+		} else if err != nil {
+			return "", fmt.Errorf("decode the push progress, error: %w", err)
+		}
+
+		progress := PushProgress{ID: msg.ID, Status: msg.Status, Progress: msg.ProgressMessage}
+		if msg.Progress != nil {
+			progress.Current, progress.Total = msg.Progress.Current, msg.Progress.Total
+			if len(progress.Progress) == 0 && progress.Total > 0 {
+				progress.Progress = msg.Progress.String()
+			}
+		}
+		if msg.Aux != nil {
+			var aux struct {
+				Digest string `json:"Digest"`
+			}
+			if err := json.Unmarshal(*msg.Aux, &aux); err == nil && len(aux.Digest) > 0 {
+				digest = aux.Digest
+				progress.Digest = aux.Digest
+			}
+		}
+		if msg.Error != nil {
+			progress.Error = msg.Error.Message
+		} else if len(msg.ErrorMessage) > 0 {
+			progress.Error = msg.ErrorMessage
+		}
+		if onProgress != nil {
+			onProgress(progress)
+		}
+		if len(progress.Error) > 0 {
+			return "", fmt.Errorf("%s", progress.Error)
+		}
 	}
-	sb := string(b)
-	beego.Info("Push image response: " + sb)
-	return sb, nil
 }
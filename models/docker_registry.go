@@ -3,11 +3,56 @@ package models
 import (
 	"encoding/json"
 	"fmt"
-	"github.com/astaxie/beego"
 	"io"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego"
+	"golang.org/x/crypto/ssh"
+)
+
+/**
+NOTE:
+
+The images in every Docker Registry (see image_registry.go) are managed through the Registry HTTP API v2 (https://distribution.github.io/distribution/spec/api/):
+1. A manifest is deleted by its digest with "DELETE /v2/<name>/manifests/<digest>", so the registry should allow deleting, i.e., REGISTRY_STORAGE_DELETE_ENABLED=true, otherwise, it responds 405.
+2. A tag is deleted by "DELETE /v2/<name>/manifests/<tag>" if the registry supports it. Otherwise, the manifest of the tag is deleted by its digest, which also deletes the other tags of the same manifest, so we refuse it if the manifest has other tags.
+3. Deleting a repository is deleting all its manifests. The Registry API cannot delete the repository itself, so an empty repository may be still in the catalog.
+
+Deleting manifests does not free the storage. The storage of the deleted manifests is freed by the garbage collection of the registry, which is an optional maintenance task run every "dockerRegiGcPeriodHour" hours or by POST /image/gc. It runs "registry garbage-collect" in the registry container "dockerRegiContainer" on "dockerRegistryIP" through SSH, so it is only for the registry that multi-cloud manager deploys.
+
+The garbage collection runs against the live registry, and it deletes the layers that are not referenced by any manifest when it scans. A layer uploaded during the garbage collection whose manifest is not pushed yet is not referenced, so it may be deleted, and the pushed image is broken. Therefore:
+1. The garbage collection and the pushes of multi-cloud manager (PushImage) to the default registry are serialized by registryGcMu. The garbage collection waits for the running pushes, and the new pushes wait for the garbage collection.
+2. The pushes that do not go through multi-cloud manager, e.g., "docker push" by users, are not serialized. If there are such pushes, the garbage collection should be run when nobody pushes, or the registry should be in read-only mode (REGISTRY_STORAGE_MAINTENANCE_READONLY) during it.
+3. "--delete-untagged" also deletes the manifests without tags, which include the manifests of the platforms of a multi-platform image in some registry versions, so it breaks such images. It is only used if "dockerRegiGcDeleteUntagged" is true.
+*/
+
+const (
+	registryPageSize int           = 100
+	registryTimeout  time.Duration = 30 * time.Second
+
+	defaultRegistryContainer  string = "registry"
+	registryConfigInContainer string = "/etc/docker/registry/config.yml"
+
+	mediaTypeDockerManifest     string = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerManifestList string = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeOciManifest        string = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOciIndex           string = "application/vnd.oci.image.index.v1+json"
 )
 
+// the manifest types that we accept, so that the registry returns the digest of the real manifest, not of a converted one
+var manifestAcceptTypes []string = []string{mediaTypeDockerManifest, mediaTypeDockerManifestList, mediaTypeOciManifest, mediaTypeOciIndex}
+
+var registryHttpClient *http.Client = &http.Client{Timeout: registryTimeout}
+
+// The pushes to the default registry hold the read lock, and the garbage collection holds the write lock, so the garbage collection does not delete the layers being pushed.
+var registryGcMu sync.RWMutex
+
 type RegistryCatalog struct {
 	Repositories []string `json:"repositories"`
 }
@@ -17,81 +62,459 @@ type imageTags struct {
 	Tags []string `json:"tags"`
 }
 
-// get catalog from the docker registry.
-// http://192.168.100.36:5000/v2/_catalog
-func GetCatalog() ([]string, error) {
-	url := "http://" + DockerRegistry + "/v2/_catalog"
-	resp, err := http.Get(url)
-	if err != nil {
-		if err != nil {
-			beego.Error(fmt.Sprintf("Get catalog error: %s", err.Error()))
-			return []string{}, err
+// one page of the catalog. If Next is not empty, it is the "last" of the next page.
+type CatalogPage struct {
+	Repositories []string `json:"repositories"`
+	Next         string   `json:"next,omitempty"`
+}
+
+// the details of the manifest of a tag
+type ImageManifest struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	Digest     string `json:"digest"`
+	MediaType  string `json:"mediaType"`
+	// the size of the config and all layers. For a manifest list, it is the size of all manifests in it.
+	Size      int64    `json:"size"`
+	Layers    int      `json:"layers"`
+	Platforms []string `json:"platforms,omitempty"`
+}
+
+type registryDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+	Platform  *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+		Variant      string `json:"variant"`
+	} `json:"platform,omitempty"`
+}
+
+type registryManifest struct {
+	MediaType string               `json:"mediaType"`
+	Config    registryDescriptor   `json:"config"`
+	Layers    []registryDescriptor `json:"layers"`
+	Manifests []registryDescriptor `json:"manifests"`
+}
+
+// the errors in the response of the Registry API
+type registryErrors struct {
+	Errors []struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// the error of an unexpected response
+func registryRespError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	var regErrs registryErrors
+	if err := json.Unmarshal(body, &regErrs); err == nil && len(regErrs.Errors) > 0 {
+		var msgs []string
+		for _, e := range regErrs.Errors {
+			msgs = append(msgs, fmt.Sprintf("%s: %s", e.Code, e.Message))
 		}
+		return fmt.Errorf("status code [%d], errors: [%s]", resp.StatusCode, strings.Join(msgs, "; "))
 	}
-	defer resp.Body.Close()
-	b, err := io.ReadAll(resp.Body)
-	if err != nil {
-		beego.Error(fmt.Sprintf("Read resp.Body error: %s", err.Error()))
-		return []string{}, err
+	return fmt.Errorf("status code [%d], body: [%s]", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// the "last" of the next page in the Link header, e.g., </v2/_catalog?last=b&n=100>; rel="next"
+func nextPageLast(linkHeader string) string {
+	if !strings.Contains(linkHeader, `rel="next"`) {
+		return ""
 	}
-	beego.Info("Get catalog response: " + string(b))
-	var catalog RegistryCatalog
-	err = json.Unmarshal(b, &catalog)
+	start, end := strings.Index(linkHeader, "<"), strings.Index(linkHeader, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	nextUrl, err := url.Parse(linkHeader[start+1 : end])
 	if err != nil {
-		beego.Error(fmt.Sprintf("Unmarshal error: %s", err.Error()))
-		return []string{}, err
+		return ""
 	}
-	return catalog.Repositories, nil
+	return nextUrl.Query().Get("last")
 }
 
-// get tags of one image.
-// curl http://192.168.100.36:5000/v2/helloworld12345/tags/list
-func ListTags(imageName string) ([]string, error) {
-	client := &http.Client{}
-	url := fmt.Sprintf("http://%s/v2/%s/tags/list", DockerRegistry, imageName)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		beego.Error(fmt.Sprintf("Create http request error: %s", err.Error()))
-		return []string{}, err
+// get one page of the catalog, with at most n repositories after "last".
+// curl http://192.168.100.36:5000/v2/_catalog?n=100&last=helloworld
+func (r *ImageRegistry) GetCatalogPage(n int, last string) (CatalogPage, error) {
+	if n <= 0 {
+		n = registryPageSize
 	}
-	resp, err := client.Do(req)
+	query := url.Values{}
+	query.Set("n", strconv.Itoa(n))
+	if len(last) > 0 {
+		query.Set("last", last)
+	}
+	resp, err := r.request(http.MethodGet, "/v2/_catalog?"+query.Encode(), nil)
 	if err != nil {
-		beego.Error(fmt.Sprintf("Http request error: %s", err.Error()))
-		return []string{}, err
+		beego.Error(fmt.Sprintf("Get catalog error: %s", err.Error()))
+		return CatalogPage{}, err
 	}
 	defer resp.Body.Close()
-	b, err := io.ReadAll(resp.Body)
-	if err != nil {
-		beego.Error(fmt.Sprintf("Read resp.Body error: %s", err.Error()))
-		return []string{}, err
+	if resp.StatusCode != http.StatusOK {
+		outErr := fmt.Errorf("Get catalog, %w", registryRespError(resp))
+		beego.Error(outErr)
+		return CatalogPage{}, outErr
 	}
-	beego.Info("get tags of image [" + imageName + "] response: " + string(b))
-	var tags imageTags
-	err = json.Unmarshal(b, &tags)
-	if err != nil {
+	var catalog RegistryCatalog
+	if err := json.NewDecoder(resp.Body).Decode(&catalog); err != nil {
 		beego.Error(fmt.Sprintf("Unmarshal error: %s", err.Error()))
-		return []string{}, err
+		return CatalogPage{}, err
 	}
-	return tags.Tags, nil
+	return CatalogPage{Repositories: catalog.Repositories, Next: nextPageLast(resp.Header.Get("Link"))}, nil
+}
+
+// get catalog from the docker registry, all pages.
+// http://192.168.100.36:5000/v2/_catalog
+func (r *ImageRegistry) GetCatalog() ([]string, error) {
+	var repositories []string = []string{}
+	var last string
+	for {
+		page, err := r.GetCatalogPage(registryPageSize, last)
+		if err != nil {
+			return []string{}, err
+		}
+		repositories = append(repositories, page.Repositories...)
+		if len(page.Next) == 0 || page.Next == last {
+			break
+		}
+		last = page.Next
+	}
+	beego.Info(fmt.Sprintf("Get catalog, %d repositories.", len(repositories)))
+	return repositories, nil
+}
+
+// get tags of one image, all pages.
+// curl http://192.168.100.36:5000/v2/helloworld12345/tags/list
+func (r *ImageRegistry) ListTags(imageName string) ([]string, error) {
+	var allTags []string = []string{}
+	var last string
+	for {
+		query := url.Values{}
+		query.Set("n", strconv.Itoa(registryPageSize))
+		if len(last) > 0 {
+			query.Set("last", last)
+		}
+		resp, err := r.request(http.MethodGet, fmt.Sprintf("/v2/%s/tags/list?%s", imageName, query.Encode()), nil)
+		if err != nil {
+			beego.Error(fmt.Sprintf("Http request error: %s", err.Error()))
+			return []string{}, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			outErr := fmt.Errorf("get tags of image [%s], %w", imageName, registryRespError(resp))
+			resp.Body.Close()
+			beego.Error(outErr)
+			return []string{}, outErr
+		}
+		var tags imageTags
+		err = json.NewDecoder(resp.Body).Decode(&tags)
+		resp.Body.Close()
+		if err != nil {
+			beego.Error(fmt.Sprintf("Unmarshal error: %s", err.Error()))
+			return []string{}, err
+		}
+		allTags = append(allTags, tags.Tags...)
+
+		next := nextPageLast(resp.Header.Get("Link"))
+		if len(next) == 0 || next == last {
+			break
+		}
+		last = next
+	}
+	return allTags, nil
 }
 
 // list all RepoTags in the Docker Registry
-func ListRepoTags() ([]string, error) {
-	repositories, err := GetCatalog()
+func (r *ImageRegistry) ListRepoTags() ([]string, error) {
+	repositories, err := r.GetCatalog()
 	if err != nil {
 		beego.Error(fmt.Sprintf("GetCatalog error: %s", err.Error()))
 		return []string{}, err
 	}
 	var repoTags []string
 	for _, repo := range repositories {
-		tags, err := ListTags(repo)
+		tags, err := r.ListTags(repo)
 		if err != nil {
 			beego.Error(fmt.Sprintf("Repository %s, ListTags error: %s", repo, err.Error()))
 			return []string{}, err
 		}
 		for _, tag := range tags {
-			repoTags = append(repoTags, fmt.Sprintf("%s/%s:%s", DockerRegistry, repo, tag))
+			repoTags = append(repoTags, fmt.Sprintf("%s/%s:%s", r.Address, repo, tag))
 		}
 	}
 	return repoTags, nil
 }
+
+// get the details of the manifest of a tag or a digest.
+// curl -H "Accept: application/vnd.docker.distribution.manifest.v2+json" http://192.168.100.36:5000/v2/helloworld12345/manifests/latest
+func (r *ImageRegistry) GetManifest(repo, reference string) (ImageManifest, error, int) {
+	resp, err := r.request(http.MethodGet, fmt.Sprintf("/v2/%s/manifests/%s", repo, reference), manifestAcceptTypes)
+	if err != nil {
+		outErr := fmt.Errorf("Get manifest [%s:%s], error: %w", repo, reference, err)
+		beego.Error(outErr)
+		return ImageManifest{}, outErr, http.StatusInternalServerError
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		outErr := fmt.Errorf("Get manifest [%s:%s], %w", repo, reference, registryRespError(resp))
+		beego.Error(outErr)
+		if resp.StatusCode == http.StatusNotFound {
+			return ImageManifest{}, outErr, http.StatusNotFound
+		}
+		return ImageManifest{}, outErr, http.StatusBadGateway
+	}
+
+	var manifest registryManifest
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		outErr := fmt.Errorf("Unmarshal manifest [%s:%s], error: %w", repo, reference, err)
+		beego.Error(outErr)
+		return ImageManifest{}, outErr, http.StatusBadGateway
+	}
+	mediaType := manifest.MediaType
+	if len(mediaType) == 0 {
+		mediaType = resp.Header.Get("Content-Type")
+	}
+
+	imageManifest := ImageManifest{
+		Repository: repo,
+		Tag:        reference,
+		Digest:     resp.Header.Get("Docker-Content-Digest"),
+		MediaType:  mediaType,
+	}
+	if strings.HasPrefix(reference, "sha256:") {
+		imageManifest.Tag = ""
+		if len(imageManifest.Digest) == 0 {
+			imageManifest.Digest = reference
+		}
+	}
+	if len(manifest.Manifests) > 0 {
+		// a manifest list has a manifest for every platform
+		for _, m := range manifest.Manifests {
+			imageManifest.Size += m.Size
+			if m.Platform != nil {
+				platform := m.Platform.OS + "/" + m.Platform.Architecture
+				if len(m.Platform.Variant) > 0 {
+					platform += "/" + m.Platform.Variant
+				}
+				imageManifest.Platforms = append(imageManifest.Platforms, platform)
+			}
+		}
+	} else {
+		imageManifest.Size = manifest.Config.Size
+		for _, layer := range manifest.Layers {
+			imageManifest.Size += layer.Size
+		}
+		imageManifest.Layers = len(manifest.Layers)
+	}
+	return imageManifest, nil, http.StatusOK
+}
+
+// delete a manifest by its digest. All tags of this manifest are deleted.
+// curl -X DELETE http://192.168.100.36:5000/v2/helloworld12345/manifests/sha256:xxxxxx
+func (r *ImageRegistry) DeleteManifest(repo, digest string) (error, int) {
+	if !strings.Contains(digest, ":") {
+		outErr := fmt.Errorf("[%s] is not a digest, a digest is like \"sha256:<hex>\"", digest)
+		beego.Error(outErr)
+		return outErr, http.StatusBadRequest
+	}
+	resp, err := r.request(http.MethodDelete, fmt.Sprintf("/v2/%s/manifests/%s", repo, digest), nil)
+	if err != nil {
+		outErr := fmt.Errorf("Delete manifest [%s@%s], error: %w", repo, digest, err)
+		beego.Error(outErr)
+		return outErr, http.StatusInternalServerError
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusAccepted, http.StatusOK:
+		beego.Info(fmt.Sprintf("Manifest [%s@%s] is deleted.", repo, digest))
+		return nil, http.StatusOK
+	case http.StatusNotFound:
+		outErr := fmt.Errorf("Delete manifest [%s@%s], it is not found, %w", repo, digest, registryRespError(resp))
+		beego.Error(outErr)
+		return outErr, http.StatusNotFound
+	case http.StatusMethodNotAllowed:
+		outErr := fmt.Errorf("Delete manifest [%s@%s], the Docker Registry does not allow deleting, please set REGISTRY_STORAGE_DELETE_ENABLED=true, %w", repo, digest, registryRespError(resp))
+		beego.Error(outErr)
+		return outErr, http.StatusMethodNotAllowed
+	default:
+		outErr := fmt.Errorf("Delete manifest [%s@%s], %w", repo, digest, registryRespError(resp))
+		beego.Error(outErr)
+		return outErr, http.StatusBadGateway
+	}
+}
+
+// get the digest of every tag in a repository
+func (r *ImageRegistry) tagDigests(repo string) (map[string]string, error) {
+	tags, err := r.ListTags(repo)
+	if err != nil {
+		return nil, err
+	}
+	digests := make(map[string]string)
+	for _, tag := range tags {
+		manifest, err, _ := r.GetManifest(repo, tag)
+		if err != nil {
+			return nil, err
+		}
+		digests[tag] = manifest.Digest
+	}
+	return digests, nil
+}
+
+// delete a tag. The registries following the OCI distribution spec 1.1 can delete a tag directly. For other registries, we delete the manifest of the tag by its digest, if the manifest does not have other tags.
+func (r *ImageRegistry) DeleteTag(repo, tag string) (error, int) {
+	manifest, err, statusCode := r.GetManifest(repo, tag)
+	if err != nil {
+		outErr := fmt.Errorf("Delete tag [%s:%s], error: %w", repo, tag, err)
+		beego.Error(outErr)
+		return outErr, statusCode
+	}
+
+	// try to delete the tag directly
+	resp, err := r.request(http.MethodDelete, fmt.Sprintf("/v2/%s/manifests/%s", repo, tag), nil)
+	if err != nil {
+		outErr := fmt.Errorf("Delete tag [%s:%s], error: %w", repo, tag, err)
+		beego.Error(outErr)
+		return outErr, http.StatusInternalServerError
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusAccepted || resp.StatusCode == http.StatusOK {
+		beego.Info(fmt.Sprintf("Tag [%s:%s] is deleted.", repo, tag))
+		return nil, http.StatusOK
+	}
+	beego.Info(fmt.Sprintf("The Docker Registry cannot delete tag [%s:%s] directly, status code [%d], so we delete its manifest [%s].", repo, tag, resp.StatusCode, manifest.Digest))
+
+	digests, err := r.tagDigests(repo)
+	if err != nil {
+		outErr := fmt.Errorf("Delete tag [%s:%s], get the digests of the tags, error: %w", repo, tag, err)
+		beego.Error(outErr)
+		return outErr, http.StatusBadGateway
+	}
+	var sharingTags []string
+	for otherTag, digest := range digests {
+		if otherTag != tag && digest == manifest.Digest {
+			sharingTags = append(sharingTags, otherTag)
+		}
+	}
+	if len(sharingTags) > 0 {
+		sort.Strings(sharingTags)
+		outErr := fmt.Errorf("Delete tag [%s:%s], its manifest [%s] also has the tags %v, and the Docker Registry cannot delete only one tag, so please delete the manifest by its digest if you want to delete all these tags", repo, tag, manifest.Digest, sharingTags)
+		beego.Error(outErr)
+		return outErr, http.StatusConflict
+	}
+	return r.DeleteManifest(repo, manifest.Digest)
+}
+
+// delete all manifests in a repository
+func (r *ImageRegistry) DeleteRepository(repo string) (error, int) {
+	digests, err := r.tagDigests(repo)
+	if err != nil {
+		outErr := fmt.Errorf("Delete repository [%s], get the digests of the tags, error: %w", repo, err)
+		beego.Error(outErr)
+		return outErr, http.StatusBadGateway
+	}
+
+	deleted := make(map[string]bool)
+	var errs []error
+	var statusCode int = http.StatusOK
+	for _, digest := range digests {
+		if deleted[digest] {
+			continue
+		}
+		deleted[digest] = true
+		if err, code := r.DeleteManifest(repo, digest); err != nil {
+			errs = append(errs, err)
+			statusCode = code
+		}
+	}
+	if len(errs) != 0 {
+		sumErr := HandleErrSlice(errs)
+		outErr := fmt.Errorf("Delete repository [%s], Error: %w", repo, sumErr)
+		beego.Error(outErr)
+		return outErr, statusCode
+	}
+	beego.Info(fmt.Sprintf("Repository [%s] is deleted, %d manifests.", repo, len(deleted)))
+	return nil, http.StatusOK
+}
+
+// get catalog from the default Docker Registry
+func GetCatalog() ([]string, error) {
+	return DefaultImageRegistry().GetCatalog()
+}
+
+// get tags of one image in the default Docker Registry
+func ListTags(imageName string) ([]string, error) {
+	return DefaultImageRegistry().ListTags(imageName)
+}
+
+// list all RepoTags in the default Docker Registry
+func ListRepoTags() ([]string, error) {
+	return DefaultImageRegistry().ListRepoTags()
+}
+
+// create an SSH client to the host of the Docker Registry
+func registrySshClient() (*ssh.Client, error) {
+	dockerRegistryIP := beego.AppConfig.String("dockerRegistryIP")
+	sshPrivateKey := beego.AppConfig.String("dockerRegiSshPrivateKey")
+	if len(sshPrivateKey) > 0 {
+		beego.Info("Config \"dockerRegiSshPrivateKey\" is provided, so we use SSH key to SSH.")
+		return SshClientWithPem(sshPrivateKey, SshRootUser, dockerRegistryIP, SshPort)
+	}
+	beego.Info("Config \"dockerRegiSshPrivateKey\" is not provided, so we use password to SSH.")
+	sshPassword, err := ResolveSecret(beego.AppConfig.String("dockerRegiRootPasswd"))
+	if err != nil {
+		return nil, fmt.Errorf("resolve config \"dockerRegiRootPasswd\", error: %w", err)
+	}
+	return SshClientWithPasswd(SshRootUser, sshPassword, dockerRegistryIP, SshPort)
+}
+
+// RegistryGarbageCollect frees the storage of the deleted manifests, and also the untagged manifests if "dockerRegiGcDeleteUntagged" is true.
+// The pushes to the registry through multi-cloud manager wait until it finishes.
+func RegistryGarbageCollect() error {
+	container := beego.AppConfig.DefaultString("dockerRegiContainer", defaultRegistryContainer)
+	gcArgs := registryConfigInContainer
+	if deleteUntagged, err := beego.AppConfig.Bool("dockerRegiGcDeleteUntagged"); err == nil && deleteUntagged {
+		gcArgs = "--delete-untagged " + gcArgs
+	}
+
+	registryGcMu.Lock()
+	defer registryGcMu.Unlock()
+	beego.Info(fmt.Sprintf("Start the garbage collection of the Docker Registry in container [%s].", container))
+
+	sshClient, err := registrySshClient()
+	if err != nil {
+		outErr := fmt.Errorf("Create ssh client to the Docker Registry, error: %w", err)
+		beego.Error(outErr)
+		return outErr
+	}
+	defer sshClient.Close()
+
+	output, err := SshOneCommand(sshClient, fmt.Sprintf("docker exec %s bin/registry garbage-collect %s", container, gcArgs))
+	if err != nil {
+		outErr := fmt.Errorf("Garbage collection of the Docker Registry, error: %w", err)
+		beego.Error(outErr)
+		return outErr
+	}
+	beego.Info(fmt.Sprintf("Garbage collection of the Docker Registry, output: %s", output))
+
+	// The registry caches the blob descriptors in memory by default, so after the garbage collection it may think that a deleted layer still exists. Restarting it clears the cache. It is not needed if the cache is off.
+	if restart, err := beego.AppConfig.Bool("dockerRegiGcRestart"); err == nil && restart {
+		if _, err := SshOneCommand(sshClient, fmt.Sprintf("docker restart %s", container)); err != nil {
+			outErr := fmt.Errorf("Restart the Docker Registry container [%s], error: %w", container, err)
+			beego.Error(outErr)
+			return outErr
+		}
+		beego.Info(fmt.Sprintf("The Docker Registry container [%s] is restarted.", container))
+	}
+
+	beego.Info("Finish the garbage collection of the Docker Registry.")
+	return nil
+}
+
+// the function for CronTaskTimer
+func CronRegistryGarbageCollect() {
+	if err := RegistryGarbageCollect(); err != nil {
+		beego.Error(fmt.Sprintf("Scheduled garbage collection of the Docker Registry, error: %s", err.Error()))
+	}
+}
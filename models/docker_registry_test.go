@@ -1,7 +1,15 @@
 package models
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -72,3 +80,333 @@ func TestListRepoTags(t *testing.T) {
 		assert.Equal(t, testCase.expectedError, actualError, fmt.Sprintf("%s: Error is not expected", testCase.name))
 	}
 }
+
+func TestNextPageLast(t *testing.T) {
+	testCases := []struct {
+		name         string
+		linkHeader   string
+		expectedLast string
+	}{
+		{
+			name:         "next page",
+			linkHeader:   `</v2/_catalog?last=helloworld&n=100>; rel="next"`,
+			expectedLast: "helloworld",
+		},
+		{
+			name:         "encoded repository",
+			linkHeader:   `</v2/_catalog?last=library%2Fubuntu&n=2>; rel="next"`,
+			expectedLast: "library/ubuntu",
+		},
+		{
+			name:         "no next page",
+			linkHeader:   "",
+			expectedLast: "",
+		},
+	}
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		assert.Equal(t, testCase.expectedLast, nextPageLast(testCase.linkHeader), fmt.Sprintf("%s: last is not expected", testCase.name))
+	}
+}
+
+// a Docker Registry in memory, which supports the APIs that we use
+type fakeRegistry struct {
+	mu            sync.Mutex
+	repos         map[string]map[string]string // repo -> tag -> digest
+	deleteEnabled bool
+	tagDeletable  bool
+	deleted       []string
+	username      string // if it is set, the requests need the Basic authentication
+	password      string
+	token         string // if it is set, the requests need this Bearer token, which is got from /token with the Basic authentication
+}
+
+// check the authentication of a request, and ask for it if it is wrong
+func (f *fakeRegistry) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if len(f.token) > 0 {
+		if r.Header.Get("Authorization") == "Bearer "+f.token {
+			return true
+		}
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="http://%s/token",service="fake",scope="registry:catalog:*"`, r.Host))
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+	if len(f.username) > 0 {
+		if username, password, ok := r.BasicAuth(); ok && username == f.username && password == f.password {
+			return true
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="fake"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path == "/token" {
+		if username, password, ok := r.BasicAuth(); !ok || username != f.username || password != f.password || r.URL.Query().Get("service") != "fake" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"token": f.token})
+		return
+	}
+	if !f.authorized(w, r) {
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+
+	if path == "_catalog" {
+		var repos []string
+		for repo := range f.repos {
+			repos = append(repos, repo)
+		}
+		sort.Strings(repos)
+		n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+		last := r.URL.Query().Get("last")
+		var page []string
+		for _, repo := range repos {
+			if repo > last && len(page) < n {
+				page = append(page, repo)
+			}
+		}
+		if len(page) == n && page[n-1] != repos[len(repos)-1] {
+			w.Header().Set("Link", fmt.Sprintf(`</v2/_catalog?last=%s&n=%d>; rel="next"`, url.QueryEscape(page[n-1]), n))
+		}
+		json.NewEncoder(w).Encode(RegistryCatalog{Repositories: page})
+		return
+	}
+
+	if repo, found := strings.CutSuffix(path, "/tags/list"); found {
+		var tags []string
+		for tag := range f.repos[repo] {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		json.NewEncoder(w).Encode(imageTags{Name: repo, Tags: tags})
+		return
+	}
+
+	idx := strings.LastIndex(path, "/manifests/")
+	repo, reference := path[:idx], path[idx+len("/manifests/"):]
+	switch r.Method {
+	case http.MethodGet:
+		digest, found := f.repos[repo][reference]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[{"code":"MANIFEST_UNKNOWN","message":"manifest unknown"}]}`))
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.Write([]byte(`{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.v2+json","config":{"size":1000},"layers":[{"size":2000},{"size":3000}]}`))
+	case http.MethodDelete:
+		if !f.deleteEnabled {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte(`{"errors":[{"code":"UNSUPPORTED","message":"The operation is unsupported."}]}`))
+			return
+		}
+		if !strings.HasPrefix(reference, "sha256:") {
+			if !f.tagDeletable {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			delete(f.repos[repo], reference)
+			f.deleted = append(f.deleted, repo+":"+reference)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		var found bool
+		for tag, digest := range f.repos[repo] {
+			if digest == reference {
+				delete(f.repos[repo], tag)
+				found = true
+			}
+		}
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		f.deleted = append(f.deleted, repo+"@"+reference)
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func useFakeRegistry(t *testing.T, registry *fakeRegistry) *ImageRegistry {
+	server := httptest.NewServer(registry)
+	t.Cleanup(server.Close)
+	return &ImageRegistry{Name: "test", Address: strings.TrimPrefix(server.URL, "http://"), Insecure: true}
+}
+
+func TestRegistryApi(t *testing.T) {
+	registry := &fakeRegistry{deleteEnabled: true, repos: map[string]map[string]string{}}
+	for i := 0; i < 250; i++ {
+		registry.repos[fmt.Sprintf("repo%03d", i)] = map[string]string{"latest": "sha256:aaa"}
+	}
+	registry.repos["library/ubuntu"] = map[string]string{"22.04": "sha256:u1", "latest": "sha256:u1", "20.04": "sha256:u2"}
+	imageRegistry := useFakeRegistry(t, registry)
+
+	// all pages of the catalog
+	catalog, err := imageRegistry.GetCatalog()
+	assert.Nil(t, err)
+	assert.Len(t, catalog, 251)
+	page, err := imageRegistry.GetCatalogPage(2, "repo247")
+	assert.Nil(t, err)
+	assert.Equal(t, CatalogPage{Repositories: []string{"repo248", "repo249"}}, page)
+
+	manifest, err, statusCode := imageRegistry.GetManifest("library/ubuntu", "20.04")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, ImageManifest{Repository: "library/ubuntu", Tag: "20.04", Digest: "sha256:u2", MediaType: mediaTypeDockerManifest, Size: 6000, Layers: 2}, manifest)
+	_, _, statusCode = imageRegistry.GetManifest("library/ubuntu", "18.04")
+	assert.Equal(t, http.StatusNotFound, statusCode)
+
+	testCases := []struct {
+		name               string
+		do                 func() (error, int)
+		expectedStatusCode int
+		expectedTags       []string
+	}{
+		{
+			name:               "the manifest of the tag has other tags",
+			do:                 func() (error, int) { return imageRegistry.DeleteTag("library/ubuntu", "latest") },
+			expectedStatusCode: http.StatusConflict,
+			expectedTags:       []string{"20.04", "22.04", "latest"},
+		},
+		{
+			name:               "the manifest of the tag has only this tag",
+			do:                 func() (error, int) { return imageRegistry.DeleteTag("library/ubuntu", "20.04") },
+			expectedStatusCode: http.StatusOK,
+			expectedTags:       []string{"22.04", "latest"},
+		},
+		{
+			name:               "not a digest",
+			do:                 func() (error, int) { return imageRegistry.DeleteManifest("library/ubuntu", "latest") },
+			expectedStatusCode: http.StatusBadRequest,
+			expectedTags:       []string{"22.04", "latest"},
+		},
+		{
+			name:               "by digest",
+			do:                 func() (error, int) { return imageRegistry.DeleteManifest("library/ubuntu", "sha256:u1") },
+			expectedStatusCode: http.StatusOK,
+			expectedTags:       []string{},
+		},
+		{
+			name:               "digest not found",
+			do:                 func() (error, int) { return imageRegistry.DeleteManifest("library/ubuntu", "sha256:u1") },
+			expectedStatusCode: http.StatusNotFound,
+			expectedTags:       []string{},
+		},
+	}
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		err, statusCode := testCase.do()
+		assert.Equal(t, testCase.expectedStatusCode, statusCode, fmt.Sprintf("%s: status code is not expected, error: %v", testCase.name, err))
+		tags, err := imageRegistry.ListTags("library/ubuntu")
+		assert.Nil(t, err)
+		assert.Equal(t, testCase.expectedTags, tags, fmt.Sprintf("%s: tags are not expected", testCase.name))
+	}
+	assert.Equal(t, []string{"library/ubuntu@sha256:u2", "library/ubuntu@sha256:u1"}, registry.deleted)
+}
+
+func TestDeleteRepository(t *testing.T) {
+	testCases := []struct {
+		name               string
+		registry           *fakeRegistry
+		expectedStatusCode int
+		expectedDeleted    []string
+	}{
+		{
+			name:               "every manifest is deleted once",
+			registry:           &fakeRegistry{deleteEnabled: true, repos: map[string]map[string]string{"app": {"v1": "sha256:a1", "latest": "sha256:a1"}}},
+			expectedStatusCode: http.StatusOK,
+			expectedDeleted:    []string{"app@sha256:a1"},
+		},
+		{
+			name:               "deleting is not enabled",
+			registry:           &fakeRegistry{repos: map[string]map[string]string{"app": {"v1": "sha256:a1"}}},
+			expectedStatusCode: http.StatusMethodNotAllowed,
+		},
+		{
+			name:               "a tag can be deleted directly",
+			registry:           &fakeRegistry{deleteEnabled: true, tagDeletable: true, repos: map[string]map[string]string{"app": {"v1": "sha256:a1", "latest": "sha256:a1"}}},
+			expectedStatusCode: http.StatusOK,
+			expectedDeleted:    []string{"app@sha256:a1"},
+		},
+	}
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		imageRegistry := useFakeRegistry(t, testCase.registry)
+		err, statusCode := imageRegistry.DeleteRepository("app")
+		assert.Equal(t, testCase.expectedStatusCode, statusCode, fmt.Sprintf("%s: status code is not expected, error: %v", testCase.name, err))
+		assert.Equal(t, testCase.expectedDeleted, testCase.registry.deleted, fmt.Sprintf("%s: deleted manifests are not expected", testCase.name))
+	}
+
+	// with tag deletion, only the tag is deleted
+	registry := &fakeRegistry{deleteEnabled: true, tagDeletable: true, repos: map[string]map[string]string{"app": {"v1": "sha256:a1", "latest": "sha256:a1"}}}
+	imageRegistry := useFakeRegistry(t, registry)
+	err, statusCode := imageRegistry.DeleteTag("app", "latest")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, []string{"app:latest"}, registry.deleted)
+}
+
+func TestRegistryAuth(t *testing.T) {
+	repos := map[string]map[string]string{"app": {"v1": "sha256:a1"}}
+	testCases := []struct {
+		name        string
+		registry    *fakeRegistry
+		username    string
+		password    string
+		expectedErr bool
+	}{
+		{
+			name:     "no authentication",
+			registry: &fakeRegistry{repos: repos},
+		},
+		{
+			name:     "basic",
+			registry: &fakeRegistry{repos: repos, username: "mcm", password: "pass"},
+			username: "mcm",
+			password: "pass",
+		},
+		{
+			name:        "basic with wrong password",
+			registry:    &fakeRegistry{repos: repos, username: "mcm", password: "pass"},
+			username:    "mcm",
+			password:    "wrong",
+			expectedErr: true,
+		},
+		{
+			name:        "basic without credentials",
+			registry:    &fakeRegistry{repos: repos, username: "mcm", password: "pass"},
+			expectedErr: true,
+		},
+		{
+			name:     "bearer token",
+			registry: &fakeRegistry{repos: repos, username: "mcm", password: "pass", token: "token123"},
+			username: "mcm",
+			password: "pass",
+		},
+		{
+			name:        "bearer token with wrong password",
+			registry:    &fakeRegistry{repos: repos, username: "mcm", password: "pass", token: "token123"},
+			username:    "mcm",
+			password:    "wrong",
+			expectedErr: true,
+		},
+	}
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		imageRegistry := useFakeRegistry(t, testCase.registry)
+		imageRegistry.Username, imageRegistry.Password = testCase.username, testCase.password
+		catalog, err := imageRegistry.GetCatalog()
+		if testCase.expectedErr {
+			assert.NotNil(t, err, fmt.Sprintf("%s: error is expected", testCase.name))
+			continue
+		}
+		assert.Nil(t, err, fmt.Sprintf("%s: error is not expected", testCase.name))
+		assert.Equal(t, []string{"app"}, catalog, fmt.Sprintf("%s: catalog is not expected", testCase.name))
+	}
+}
@@ -0,0 +1,425 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego"
+)
+
+// ===================== Fake driver (in memory) =====================
+
+// The operations of the Fake driver in which failures can be injected
+const (
+	FakeOpGet    string = "get"
+	FakeOpList   string = "list"
+	FakeOpCreate string = "create"
+	FakeOpDelete string = "delete"
+	FakeOpCheck  string = "check"
+
+	FakeOpAction string = "action" // the lifecycle actions, such as start, resize and snapshot
+
+	fakeVmStatus        string = "running"
+	fakeVmStatusStopped string = "stopped"
+)
+
+// The quota of a Fake cloud whose declaration does not set it. A zero quota would make every VM creation fail.
+const (
+	FakeDefaultVCpu    float64 = 32
+	FakeDefaultRam     float64 = 65536 // MB
+	FakeDefaultStorage float64 = 1000  // GB
+)
+
+// Fake is a cloud whose VMs and quotas are only in memory, used for tests and demos without a hypervisor.
+// Every operation waits for Latency, and the operations in FailOps fail with the probability FailRate.
+type Fake struct {
+	Name     string
+	WebUrl   string
+	IPPrefix string // the IPs of VMs are IPPrefix + a number
+	Limit    ResSet // the quota of this cloud. Only for the number of VMs, 0 means unlimited.
+
+	Latency  time.Duration
+	FailRate float64
+	FailOps  map[string]bool
+
+	// coordinates for the weather API
+	Latitude  string
+	Longitude string
+
+	mu        sync.Mutex
+	vms       map[string]IaasVm
+	snapshots map[string][]fakeSnapshot // key is VM ID
+	nextID    int
+	rand      *rand.Rand
+}
+
+type fakeSnapshot struct {
+	VmSnapshot
+	vm IaasVm // the VM when the snapshot is created
+}
+
+func NewFake(name string, limit ResSet) *Fake {
+	return &Fake{
+		Name:      name,
+		IPPrefix:  "10.254.0.",
+		Limit:     limit,
+		FailOps:   make(map[string]bool),
+		vms:       make(map[string]IaasVm),
+		snapshots: make(map[string][]fakeSnapshot),
+		nextID:    1,
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func InitFake(params map[string]interface{}) *Fake {
+	beego.Info(fmt.Sprintf("Start to initialize cloud name [%s] type [%s]", getStr(params, "name"), getStr(params, "type")))
+
+	// the values are checked by validateFakeConfig, so the fields not set get the default values.
+	getFloat := func(key string, defaultValue float64) float64 {
+		value, err := strconv.ParseFloat(getStr(params, key), 64)
+		if err != nil {
+			return defaultValue
+		}
+		return value
+	}
+
+	f := NewFake(getStr(params, "name"), ResSet{
+		VCpu:    getFloat("vcpu", FakeDefaultVCpu),
+		Ram:     getFloat("ram", FakeDefaultRam),
+		Storage: getFloat("storage", FakeDefaultStorage),
+		Vm:      getFloat("vm", 0),
+	})
+	f.WebUrl = getStr(params, "weburl")
+	if prefix := getStr(params, "ip_prefix"); len(prefix) != 0 {
+		f.IPPrefix = prefix
+	}
+	f.Latency = time.Duration(getFloat("latency_ms", 0)) * time.Millisecond
+	f.FailRate = getFloat("fail_rate", 0)
+	// fail_ops is like "create,delete". By default, failures are injected in the operations that change the cloud.
+	failOps := getStr(params, "fail_ops")
+	if len(failOps) == 0 {
+		failOps = FakeOpCreate + "," + FakeOpDelete
+	}
+	for _, op := range strings.Split(failOps, ",") {
+		if op = strings.TrimSpace(op); len(op) != 0 {
+			f.FailOps[op] = true
+		}
+	}
+	f.Latitude = getStr(params, "latitude", "lat")
+	f.Longitude = getStr(params, "longitude", "lon")
+
+	return f
+}
+
+// check the numbers in the declaration of a Fake cloud. The numbers can be strings or JSON numbers, and the fields not set get the default values.
+func validateFakeConfig(params map[string]interface{}) []string {
+	var problems []string
+	checkNumber := func(key string, min float64, minIncluded bool, max float64) {
+		if value, exist := params[key]; !exist || value == nil || len(getStr(params, key)) == 0 {
+			return
+		}
+		value, err := strconv.ParseFloat(getStr(params, key), 64)
+		switch {
+		case err != nil || math.IsNaN(value):
+			problems = append(problems, fmt.Sprintf("field [%s] should be a number, but it is [%s]", key, getStr(params, key)))
+		case minIncluded && value < min:
+			problems = append(problems, fmt.Sprintf("field [%s] should not be less than %g, but it is [%s]", key, min, getStr(params, key)))
+		case !minIncluded && value <= min:
+			problems = append(problems, fmt.Sprintf("field [%s] should be greater than %g, but it is [%s]", key, min, getStr(params, key)))
+		case value > max:
+			problems = append(problems, fmt.Sprintf("field [%s] should not be greater than %g, but it is [%s]", key, max, getStr(params, key)))
+		}
+	}
+	checkNumber("vcpu", 0, false, math.Inf(1))
+	checkNumber("ram", 0, false, math.Inf(1))
+	checkNumber("storage", 0, false, math.Inf(1))
+	checkNumber("vm", 0, true, math.Inf(1))
+	checkNumber("latency_ms", 0, true, math.Inf(1))
+	checkNumber("fail_rate", 0, true, 1) // a probability
+	return problems
+}
+
+func (f *Fake) ShowName() string   { return f.Name }
+func (f *Fake) ShowType() string   { return FakeIaas }
+func (f *Fake) ShowWebUrl() string { return f.WebUrl }
+
+// GetLatitude implements the locator interface for Fake
+func (f *Fake) GetLatitude() string {
+	return f.Latitude
+}
+
+// GetLongitude implements the locator interface for Fake
+func (f *Fake) GetLongitude() string {
+	return f.Longitude
+}
+
+// wait for the latency, and return an error if a failure is injected in this operation. the caller should not hold the lock.
+func (f *Fake) simulate(op string) error {
+	if f.Latency > 0 {
+		time.Sleep(f.Latency)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.FailOps[op] && f.FailRate > 0 && f.rand.Float64() < f.FailRate {
+		return fmt.Errorf("fake cloud [%s]: injected failure in operation [%s]", f.Name, op)
+	}
+	return nil
+}
+
+func (f *Fake) GetVM(vmID string) (*IaasVm, error) {
+	if err := f.simulate(FakeOpGet); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	vm, exist := f.vms[vmID]
+	if !exist {
+		return nil, fmt.Errorf("fake cloud [%s]: VM [%s] not found", f.Name, vmID)
+	}
+	return &vm, nil
+}
+
+func (f *Fake) ListAllVMs() ([]IaasVm, error) {
+	if err := f.simulate(FakeOpList); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var vms []IaasVm = []IaasVm{}
+	for _, vm := range f.vms {
+		vms = append(vms, vm)
+	}
+	sort.Slice(vms, func(i, j int) bool {
+		return vms[i].Name < vms[j].Name
+	})
+	return vms, nil
+}
+
+func (f *Fake) CreateVM(name string, vcpu, ram, storage int) (*IaasVm, error) {
+	if err := f.simulate(FakeOpCreate); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, vm := range f.vms {
+		if vm.Name == name {
+			return nil, fmt.Errorf("fake cloud [%s]: VM name [%s] already exists", f.Name, name)
+		}
+	}
+
+	inUse := f.inUse()
+	if inUse.VCpu+float64(vcpu) > f.Limit.VCpu ||
+		inUse.Ram+float64(ram) > f.Limit.Ram ||
+		inUse.Storage+float64(storage) > f.Limit.Storage ||
+		(f.Limit.Vm > 0 && inUse.Vm+1 > f.Limit.Vm) {
+		return nil, fmt.Errorf("fake cloud [%s]: quota exceeded, limit [%+v], in use [%+v], VM [%s] needs vcpu [%d] ram [%d] storage [%d]", f.Name, f.Limit, inUse, name, vcpu, ram, storage)
+	}
+
+	id := fmt.Sprintf("fake-%d", f.nextID)
+	vm := IaasVm{
+		ID:        id,
+		Name:      name,
+		IPs:       []string{f.IPPrefix + strconv.Itoa(f.nextID)},
+		VCpu:      float64(vcpu),
+		Ram:       float64(ram),
+		Storage:   float64(storage),
+		Status:    fakeVmStatus,
+		Cloud:     f.Name,
+		CloudType: FakeIaas,
+		McmCreate: true,
+	}
+	f.nextID++
+	f.vms[id] = vm
+	return &vm, nil
+}
+
+// CreateVMWithUserData creates a VM like CreateVM. The user-data is only checked, because there is no VM to run it.
+func (f *Fake) CreateVMWithUserData(name string, vcpu, ram, storage int, userData string) (*IaasVm, error) {
+	if !strings.HasPrefix(userData, cloudConfigHeader) {
+		return nil, fmt.Errorf("fake cloud [%s]: the user-data of VM [%s] is not cloud-config", f.Name, name)
+	}
+	return f.CreateVM(name, vcpu, ram, storage)
+}
+
+// AddVM adds an existing VM that is not created by multi-cloud manager, for example, a VM of Kubernetes master in a demo.
+func (f *Fake) AddVM(name string, vcpu, ram, storage float64) IaasVm {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := fmt.Sprintf("fake-%d", f.nextID)
+	vm := IaasVm{
+		ID:        id,
+		Name:      name,
+		IPs:       []string{f.IPPrefix + strconv.Itoa(f.nextID)},
+		VCpu:      vcpu,
+		Ram:       ram,
+		Storage:   storage,
+		Status:    fakeVmStatus,
+		Cloud:     f.Name,
+		CloudType: FakeIaas,
+		McmCreate: false,
+	}
+	f.nextID++
+	f.vms[id] = vm
+	return vm
+}
+
+func (f *Fake) DeleteVM(vmID string) error {
+	if err := f.simulate(FakeOpDelete); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, exist := f.vms[vmID]; !exist {
+		return fmt.Errorf("fake cloud [%s]: VM [%s] not found", f.Name, vmID)
+	}
+	delete(f.vms, vmID)
+	delete(f.snapshots, vmID)
+	return nil
+}
+
+// the resources used by all VMs. the caller should hold the lock.
+func (f *Fake) inUse() ResSet {
+	var inUse ResSet
+	for _, vm := range f.vms {
+		inUse.VCpu += vm.VCpu
+		inUse.Ram += vm.Ram
+		inUse.Storage += vm.Storage
+		inUse.Vm++
+	}
+	return inUse
+}
+
+func (f *Fake) CheckResources() (ResourceStatus, error) {
+	if err := f.simulate(FakeOpCheck); err != nil {
+		return ResourceStatus{}, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return ResourceStatus{Limit: f.Limit, InUse: f.inUse()}, nil
+}
+
+func (f *Fake) IsCreatedByMcm(vmID string) (bool, error) {
+	vm, err := f.GetVM(vmID)
+	if err != nil {
+		return false, err
+	}
+	return vm.McmCreate, nil
+}
+
+// ===================== lifecycle capabilities =====================
+
+// change a VM with the lock held. change returns the error of the action.
+func (f *Fake) updateVM(vmID string, change func(vm *IaasVm) error) error {
+	if err := f.simulate(FakeOpAction); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	vm, exist := f.vms[vmID]
+	if !exist {
+		return fmt.Errorf("fake cloud [%s]: VM [%s] not found", f.Name, vmID)
+	}
+	if err := change(&vm); err != nil {
+		return err
+	}
+	f.vms[vmID] = vm
+	return nil
+}
+
+func (f *Fake) setStatus(vmID, status string) error {
+	return f.updateVM(vmID, func(vm *IaasVm) error {
+		vm.Status = status
+		return nil
+	})
+}
+
+func (f *Fake) StartVM(vmID string) error    { return f.setStatus(vmID, fakeVmStatus) }
+func (f *Fake) StopVM(vmID string) error     { return f.setStatus(vmID, fakeVmStatusStopped) }
+func (f *Fake) ShutdownVM(vmID string) error { return f.setStatus(vmID, fakeVmStatusStopped) }
+func (f *Fake) RebootVM(vmID string) error   { return f.setStatus(vmID, fakeVmStatus) }
+
+func (f *Fake) ResizeVM(vmID string, vcpu, ram int, live bool) error {
+	return f.updateVM(vmID, func(vm *IaasVm) error {
+		if live && vm.Status != fakeVmStatus {
+			return fmt.Errorf("fake cloud [%s]: VM [%s] is not running, so it cannot be resized live", f.Name, vmID)
+		}
+		inUse := f.inUse()
+		if inUse.VCpu-vm.VCpu+float64(vcpu) > f.Limit.VCpu || inUse.Ram-vm.Ram+float64(ram) > f.Limit.Ram {
+			return fmt.Errorf("fake cloud [%s]: quota exceeded, limit [%+v], in use [%+v], VM [%s] resized to vcpu [%d] ram [%d]", f.Name, f.Limit, inUse, vmID, vcpu, ram)
+		}
+		vm.VCpu, vm.Ram = float64(vcpu), float64(ram)
+		return nil
+	})
+}
+
+func (f *Fake) ResizeVMDisk(vmID string, storage int) error {
+	return f.updateVM(vmID, func(vm *IaasVm) error {
+		if float64(storage) < vm.Storage {
+			return fmt.Errorf("fake cloud [%s]: the disk of VM [%s] cannot shrink from [%g] to [%d]", f.Name, vmID, vm.Storage, storage)
+		}
+		inUse := f.inUse()
+		if inUse.Storage-vm.Storage+float64(storage) > f.Limit.Storage {
+			return fmt.Errorf("fake cloud [%s]: quota exceeded, limit [%+v], in use [%+v], VM [%s] disk resized to [%d]", f.Name, f.Limit, inUse, vmID, storage)
+		}
+		vm.Storage = float64(storage)
+		return nil
+	})
+}
+
+func (f *Fake) CreateSnapshot(vmID, snapshotName string) error {
+	return f.updateVM(vmID, func(vm *IaasVm) error {
+		for _, snapshot := range f.snapshots[vmID] {
+			if snapshot.Name == snapshotName {
+				return fmt.Errorf("fake cloud [%s]: snapshot [%s] of VM [%s] already exists", f.Name, snapshotName, vmID)
+			}
+		}
+		f.snapshots[vmID] = append(f.snapshots[vmID], fakeSnapshot{
+			VmSnapshot: VmSnapshot{Name: snapshotName, CreatedAt: time.Now().Format(time.RFC3339)},
+			vm:         *vm,
+		})
+		return nil
+	})
+}
+
+func (f *Fake) ListSnapshots(vmID string) ([]VmSnapshot, error) {
+	snapshots := []VmSnapshot{}
+	err := f.updateVM(vmID, func(vm *IaasVm) error {
+		for _, snapshot := range f.snapshots[vmID] {
+			snapshots = append(snapshots, snapshot.VmSnapshot)
+		}
+		return nil
+	})
+	return snapshots, err
+}
+
+func (f *Fake) RevertSnapshot(vmID, snapshotName string) error {
+	return f.updateVM(vmID, func(vm *IaasVm) error {
+		for _, snapshot := range f.snapshots[vmID] {
+			if snapshot.Name == snapshotName {
+				*vm = snapshot.vm
+				return nil
+			}
+		}
+		return fmt.Errorf("fake cloud [%s]: snapshot [%s] of VM [%s] not found", f.Name, snapshotName, vmID)
+	})
+}
+
+func (f *Fake) DeleteSnapshot(vmID, snapshotName string) error {
+	return f.updateVM(vmID, func(vm *IaasVm) error {
+		snapshots := f.snapshots[vmID]
+		for i, snapshot := range snapshots {
+			if snapshot.Name == snapshotName {
+				f.snapshots[vmID] = append(snapshots[:i], snapshots[i+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("fake cloud [%s]: snapshot [%s] of VM [%s] not found", f.Name, snapshotName, vmID)
+	})
+}
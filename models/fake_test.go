@@ -0,0 +1,186 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitFake(t *testing.T) {
+	f := InitFake(map[string]interface{}{
+		"type":       FakeIaas,
+		"name":       "FAKE1",
+		"weburl":     "http://fake1",
+		"vcpu":       "8",
+		"ram":        16384.0,
+		"storage":    "100",
+		"vm":         "3",
+		"ip_prefix":  "10.1.1.",
+		"latency_ms": "20",
+		"fail_rate":  "0.5",
+		"fail_ops":   "create, list",
+		"lat":        "57.0",
+		"lon":        "9.9",
+	})
+	assert.Equal(t, "FAKE1", f.ShowName())
+	assert.Equal(t, FakeIaas, f.ShowType())
+	assert.Equal(t, "http://fake1", f.ShowWebUrl())
+	assert.Equal(t, ResSet{VCpu: 8, Ram: 16384, Storage: 100, Vm: 3}, f.Limit)
+	assert.Equal(t, "10.1.1.", f.IPPrefix)
+	assert.Equal(t, 20*time.Millisecond, f.Latency)
+	assert.Equal(t, 0.5, f.FailRate)
+	assert.Equal(t, map[string]bool{FakeOpCreate: true, FakeOpList: true}, f.FailOps)
+	assert.Equal(t, "57.0", f.GetLatitude())
+	assert.Equal(t, "9.9", f.GetLongitude())
+
+	// by default, failures are injected in create and delete, and the quota is not zero
+	f = InitFake(map[string]interface{}{"type": FakeIaas, "name": "FAKE2"})
+	assert.Equal(t, map[string]bool{FakeOpCreate: true, FakeOpDelete: true}, f.FailOps)
+	assert.Equal(t, "10.254.0.", f.IPPrefix)
+	assert.Equal(t, ResSet{VCpu: FakeDefaultVCpu, Ram: FakeDefaultRam, Storage: FakeDefaultStorage}, f.Limit)
+	_, err := f.CreateVM("vm1", 2, 2048, 20)
+	assert.Nil(t, err)
+}
+
+func TestFakeCreateVM(t *testing.T) {
+	testCases := []struct {
+		name        string
+		existing    [][3]int // vcpu, ram, storage of the VMs created before
+		limit       ResSet
+		vmName      string
+		vcpu        int
+		ram         int
+		storage     int
+		expectedErr bool
+	}{
+		{
+			name:    "enough",
+			limit:   ResSet{VCpu: 4, Ram: 4096, Storage: 50},
+			vmName:  "vm1",
+			vcpu:    2,
+			ram:     2048,
+			storage: 20,
+		},
+		{
+			name:     "exactly full",
+			existing: [][3]int{{2, 2048, 30}},
+			limit:    ResSet{VCpu: 4, Ram: 4096, Storage: 50},
+			vmName:   "vm2",
+			vcpu:     2,
+			ram:      2048,
+			storage:  20,
+		},
+		{
+			name:        "cpu exceeded",
+			existing:    [][3]int{{3, 1024, 10}},
+			limit:       ResSet{VCpu: 4, Ram: 4096, Storage: 50},
+			vmName:      "vm2",
+			vcpu:        2,
+			ram:         1024,
+			storage:     10,
+			expectedErr: true,
+		},
+		{
+			name:        "storage exceeded",
+			limit:       ResSet{VCpu: 4, Ram: 4096, Storage: 50},
+			vmName:      "vm1",
+			vcpu:        1,
+			ram:         1024,
+			storage:     60,
+			expectedErr: true,
+		},
+		{
+			name:        "vm number exceeded",
+			existing:    [][3]int{{1, 1024, 10}},
+			limit:       ResSet{VCpu: 4, Ram: 4096, Storage: 50, Vm: 1},
+			vmName:      "vm2",
+			vcpu:        1,
+			ram:         1024,
+			storage:     10,
+			expectedErr: true,
+		},
+		{
+			name:        "duplicate name",
+			existing:    [][3]int{{1, 1024, 10}},
+			limit:       ResSet{VCpu: 4, Ram: 4096, Storage: 50},
+			vmName:      "existing-0",
+			vcpu:        1,
+			ram:         1024,
+			storage:     10,
+			expectedErr: true,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		f := NewFake("FAKE1", testCase.limit)
+		for j, res := range testCase.existing {
+			_, err := f.CreateVM(fmt.Sprintf("existing-%d", j), res[0], res[1], res[2])
+			assert.Nil(t, err, fmt.Sprintf("%s: create existing VM", testCase.name))
+		}
+		vm, err := f.CreateVM(testCase.vmName, testCase.vcpu, testCase.ram, testCase.storage)
+		assert.Equal(t, testCase.expectedErr, err != nil, fmt.Sprintf("%s: error is not expected", testCase.name))
+		vms, _ := f.ListAllVMs()
+		if testCase.expectedErr {
+			assert.Len(t, vms, len(testCase.existing), fmt.Sprintf("%s: no VM should be added", testCase.name))
+			continue
+		}
+		assert.Len(t, vms, len(testCase.existing)+1, fmt.Sprintf("%s: the VM should be added", testCase.name))
+		assert.Equal(t, testCase.vmName, vm.Name)
+		assert.Equal(t, "FAKE1", vm.Cloud)
+		assert.Equal(t, FakeIaas, vm.CloudType)
+		assert.True(t, vm.McmCreate)
+	}
+}
+
+func TestFakeLifecycle(t *testing.T) {
+	f := NewFake("FAKE1", ResSet{VCpu: 8, Ram: 8192, Storage: 100})
+	master := f.AddVM("master", 2, 2048, 20)
+
+	vm, err := f.CreateVM("worker", 2, 2048, 20)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"10.254.0.2"}, vm.IPs)
+
+	gotVM, err := f.GetVM(vm.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, *vm, *gotVM)
+
+	createdByMcm, err := f.IsCreatedByMcm(master.ID)
+	assert.Nil(t, err)
+	assert.False(t, createdByMcm)
+	createdByMcm, err = f.IsCreatedByMcm(vm.ID)
+	assert.Nil(t, err)
+	assert.True(t, createdByMcm)
+
+	status, err := f.CheckResources()
+	assert.Nil(t, err)
+	assert.Equal(t, ResourceStatus{Limit: ResSet{VCpu: 8, Ram: 8192, Storage: 100}, InUse: ResSet{VCpu: 4, Ram: 4096, Storage: 40, Vm: 2}}, status)
+
+	assert.Nil(t, f.DeleteVM(vm.ID))
+	assert.NotNil(t, f.DeleteVM(vm.ID))
+	_, err = f.GetVM(vm.ID)
+	assert.NotNil(t, err)
+
+	status, err = f.CheckResources()
+	assert.Nil(t, err)
+	assert.Equal(t, ResSet{VCpu: 2, Ram: 2048, Storage: 20, Vm: 1}, status.InUse)
+}
+
+func TestFakeFailureInjection(t *testing.T) {
+	f := NewFake("FAKE1", ResSet{VCpu: 8, Ram: 8192, Storage: 100})
+	f.FailRate = 1
+	f.FailOps[FakeOpCreate] = true
+
+	_, err := f.CreateVM("vm1", 1, 1024, 10)
+	assert.NotNil(t, err)
+	// the operations without injected failures still work
+	vms, err := f.ListAllVMs()
+	assert.Nil(t, err)
+	assert.Len(t, vms, 0)
+
+	f.FailRate = 0
+	_, err = f.CreateVM("vm1", 1, 1024, 10)
+	assert.Nil(t, err)
+}
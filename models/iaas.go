@@ -94,6 +94,8 @@ type IaasVm struct {
 	McmCreate     bool     `json:"mcmCreate"`
 	OsVariant     string   `json:"osVariant,omitempty"`
 	InstallMethod string   `json:"installMethod,omitempty"`
+	// If it is set, the VM is initialized by cloud-init instead of SSH.
+	UserData *UserData `json:"userData,omitempty"`
 }
 
 type ResSet struct {
@@ -129,48 +131,10 @@ func (r1 ResSet) AllMoreThan(r2 ResSet) bool {
 }
 
 // ===== Global Variables =====
+// Clouds is the registry of clouds, which should be read by GetIaas, ListIaas, and IaasNames, because it can be changed at runtime.
 var Clouds map[string]Iaas = make(map[string]Iaas)
 var iaasConfig *viper.Viper
 
-// ===== Configuration =====
-func readIaasConfig() {
-	iaasConfig = viper.New()
-	iaasConfig.SetConfigFile("conf/iaas.json")
-	iaasConfig.SetConfigType("json")
-	if err := iaasConfig.ReadInConfig(); err != nil {
-		panic(fmt.Errorf("fatal error parse iaas.json: %w", err))
-	}
-}
-
-// ===== Init Clouds =====
-func InitClouds() {
-	readIaasConfig()
-	var iaasParas []map[string]interface{}
-	if err := iaasConfig.UnmarshalKey("iaas", &iaasParas); err != nil {
-		panic(fmt.Errorf("UnmarshalKey \"iaas\" of iaas.json error: %w", err))
-	}
-
-	for i := 0; i < len(iaasParas); i++ {
-		switch iaasParas[i]["type"].(string) {
-		case OpenstackIaas:
-			osCloud := InitOpenstack(iaasParas[i])
-			Clouds[osCloud.Name] = osCloud
-		case ProxmoxIaas:
-			pCloud := InitProxmox(iaasParas[i])
-			Clouds[pCloud.Name] = pCloud
-		case LocalIaas: // ✅ local VM
-			lc := InitLocal(iaasParas[i])
-			Clouds[lc.Name] = lc
-		default:
-			beego.Info(fmt.Sprintf("Multi-cloud manager does not support cloud type [%s] of cloud [%s]",
-				iaasParas[i]["type"].(string),
-				iaasParas[i]["name"].(string)))
-		}
-	}
-
-	beego.Info(fmt.Sprintf("All %d clouds are initialized.", len(Clouds)))
-}
-
 // ===== SSH Waiters =====
 
 func WaitForSshPem(user string, pemFilePath string, sshIP string, sshPort int, secs int) error {
@@ -222,10 +186,13 @@ func getImagePathForCloud(v IaasVm) string {
 		return v.OsVariant
 	}
 	// Nếu không, thử lấy từ cấu hình
-	if iaasConfig != nil {
+	cloudsMu.RLock()
+	config := iaasConfig
+	cloudsMu.RUnlock()
+	if config != nil {
 		key := fmt.Sprintf("imagePathMap.%s", v.Cloud)
-		if iaasConfig.IsSet(key) {
-			return iaasConfig.GetString(key)
+		if config.IsSet(key) {
+			return config.GetString(key)
 		}
 	}
 	return ""
@@ -247,6 +214,9 @@ func needInstallMethodFallback(err error) bool {
 // ===== VM Management =====
 
 func CreateVms(vms []IaasVm) ([]IaasVm, error) {
+	if err := prepareUserData(vms); err != nil {
+		return nil, err
+	}
 	vmGroups := GroupVmsByCloud(vms)
 
 	var errs []error
@@ -261,7 +231,7 @@ func CreateVms(vms []IaasVm) ([]IaasVm, error) {
 			defer wg.Done()
 			for _, v := range vg {
 				beego.Info(fmt.Sprintf("Start create VM [%s] Cloud [%s]", v.Name, v.Cloud))
-				cloud, exist := Clouds[v.Cloud]
+				cloud, exist := GetIaas(v.Cloud)
 				if !exist {
 					outErr := fmt.Errorf("Create vm %s error: cloud [%s] not found.", v.Name, v.Cloud)
 					beego.Error(outErr)
@@ -271,6 +241,24 @@ func CreateVms(vms []IaasVm) ([]IaasVm, error) {
 					return
 				}
 
+				// the VMs with user-data are created by the drivers in their native ways
+				if v.UserData != nil {
+					createdVM, err := createVMWithUserData(cloud, v)
+					if err != nil {
+						outErr := fmt.Errorf("Create vm %s with user-data error: %w", v.Name, err)
+						beego.Error(outErr)
+						errsMu.Lock()
+						errs = append(errs, outErr)
+						errsMu.Unlock()
+						continue
+					}
+					beego.Info(fmt.Sprintf("Created vm with user-data:\n%+v\n", createdVM))
+					createdVmsMu.Lock()
+					createdVms = append(createdVms, *createdVM)
+					createdVmsMu.Unlock()
+					continue
+				}
+
 				// --- NEW: honor InstallMethod when explicitly set ---
 				switch strings.ToLower(strings.TrimSpace(v.InstallMethod)) {
 				case "import":
@@ -397,7 +385,16 @@ func DeleteBatchVms(vms []IaasVm) []error {
 		wg.Add(1)
 		go func(v IaasVm) {
 			defer wg.Done()
-			if err := Clouds[v.Cloud].DeleteVM(v.ID); err != nil {
+			cloud, exist := GetIaas(v.Cloud)
+			if !exist {
+				outErr := fmt.Errorf("Delete vm [%s:%s] on [%s] failed: cloud not found", v.Name, v.ID, v.Cloud)
+				beego.Error(outErr)
+				errsMu.Lock()
+				errs = append(errs, outErr)
+				errsMu.Unlock()
+				return
+			}
+			if err := cloud.DeleteVM(v.ID); err != nil {
 				outErr := fmt.Errorf("Delete vm [%s:%s] on [%s] failed: %v", v.Name, v.ID, v.Cloud, err)
 				beego.Error(outErr)
 				errsMu.Lock()
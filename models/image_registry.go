@@ -0,0 +1,445 @@
+package models
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/astaxie/beego"
+	"github.com/docker/docker/api/types"
+	corev1 "k8s.io/api/core/v1"
+)
+
+/**
+NOTE:
+
+Multi-cloud manager can manage images in several Docker Registries, which are declared in conf/registries.json like:
+{
+  "registries": [
+    {"name": "local", "address": "192.168.100.36:5000", "insecure": true, "default": true},
+    {"name": "harbor", "address": "harbor.example.com", "username": "mcm", "password": "secret://harbor-password", "caFile": "/etc/emcontroller/harbor-ca.crt"}
+  ]
+}
+- "insecure" means plain HTTP. Otherwise, HTTPS is used, and "caFile" is the CA of the registry if it is not signed by a public CA. "insecureSkipVerify" skips the verification of the certificate.
+- "username" and "password" are used for the Basic authentication or to get a Bearer token from the token server of the registry. The password can be a secret reference (see secret.go).
+- The "default" registry is used when a request does not choose a registry. If no registry is "default", the first one is the default.
+If conf/registries.json does not exist, there is only the registry "default" at "dockerRegistryIP:dockerRegistryPort" through HTTP, as before.
+
+The Docker Engine pushes the images by itself, so it should also trust the registries, i.e., "insecure-registries" or /etc/docker/certs.d/<address>/ca.crt.
+
+When an application uses an image in a registry that has credentials, a Secret with the credentials is created in Kubernetes, and it is added to the imagePullSecrets of the pods.
+*/
+
+const (
+	DefaultImageRegistryName string = "default"
+	imagePullSecretPrefix    string = "mcm-regcred-"
+)
+
+// the file that declares the Docker Registries
+var RegistryConfigPath string = "conf/registries.json"
+
+var (
+	imageRegistriesMu sync.RWMutex
+	imageRegistries   []*ImageRegistry // empty means that conf/registries.json is not used
+)
+
+// ImageRegistry is a Docker Registry.
+type ImageRegistry struct {
+	Name               string `json:"name"`
+	Address            string `json:"address"` // host:port, without scheme
+	Insecure           bool   `json:"insecure"`
+	Username           string `json:"username,omitempty"`
+	Password           string `json:"password,omitempty"`
+	CaFile             string `json:"caFile,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+	Default            bool   `json:"default,omitempty"`
+
+	httpClient *http.Client
+}
+
+// the information of a registry that can be shown to users, without the password
+type ImageRegistryInfo struct {
+	Name     string `json:"name"`
+	Address  string `json:"address"`
+	Insecure bool   `json:"insecure"`
+	HasAuth  bool   `json:"hasAuth"`
+	Default  bool   `json:"default"`
+}
+
+func (r *ImageRegistry) Info() ImageRegistryInfo {
+	return ImageRegistryInfo{
+		Name:     r.Name,
+		Address:  r.Address,
+		Insecure: r.Insecure,
+		HasAuth:  r.HasAuth(),
+		Default:  r.Default,
+	}
+}
+
+func (r *ImageRegistry) HasAuth() bool {
+	return len(r.Username) > 0
+}
+
+func (r *ImageRegistry) scheme() string {
+	if r.Insecure {
+		return "http"
+	}
+	return "https"
+}
+
+func (r *ImageRegistry) client() *http.Client {
+	if r.httpClient == nil {
+		return registryHttpClient
+	}
+	return r.httpClient
+}
+
+// set up the http client with the TLS settings
+func (r *ImageRegistry) initHttpClient() error {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !r.Insecure {
+		tlsConfig := &tls.Config{InsecureSkipVerify: r.InsecureSkipVerify}
+		if len(r.CaFile) > 0 {
+			caPem, err := os.ReadFile(r.CaFile)
+			if err != nil {
+				return fmt.Errorf("read CA file [%s], error: %w", r.CaFile, err)
+			}
+			caPool, err := x509.SystemCertPool()
+			if err != nil || caPool == nil {
+				caPool = x509.NewCertPool()
+			}
+			if !caPool.AppendCertsFromPEM(caPem) {
+				return fmt.Errorf("no certificate in CA file [%s]", r.CaFile)
+			}
+			tlsConfig.RootCAs = caPool
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	r.httpClient = &http.Client{Timeout: registryTimeout, Transport: transport}
+	return nil
+}
+
+// the Bearer challenge in the WWW-Authenticate header, like: Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/ubuntu:pull"
+var bearerParamRegex = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// get a token from the token server of the registry
+func (r *ImageRegistry) bearerToken(challenge string) (string, error) {
+	params := make(map[string]string)
+	for _, match := range bearerParamRegex.FindAllStringSubmatch(challenge, -1) {
+		params[match[1]] = match[2]
+	}
+	realm := params["realm"]
+	if len(realm) == 0 {
+		return "", fmt.Errorf("no realm in the challenge [%s]", challenge)
+	}
+	query := url.Values{}
+	if len(params["service"]) > 0 {
+		query.Set("service", params["service"])
+	}
+	if len(params["scope"]) > 0 {
+		query.Set("scope", params["scope"])
+	}
+	req, err := http.NewRequest(http.MethodGet, realm+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	if r.HasAuth() {
+		req.SetBasicAuth(r.Username, r.Password)
+	}
+	resp, err := r.client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("get token from [%s], %w", realm, registryRespError(resp))
+	}
+	var tokenResp struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", fmt.Errorf("unmarshal token from [%s], error: %w", realm, err)
+	}
+	if len(tokenResp.Token) > 0 {
+		return tokenResp.Token, nil
+	}
+	return tokenResp.AccessToken, nil
+}
+
+// send a request to the registry, and return the response whose body should be closed by the caller.
+// If the registry asks for authentication, we send the request again with the Basic authentication or a Bearer token.
+func (r *ImageRegistry) request(method, path string, accept []string) (*http.Response, error) {
+	newReq := func() (*http.Request, error) {
+		req, err := http.NewRequest(method, r.scheme()+"://"+r.Address+path, nil)
+		if err != nil {
+			return nil, fmt.Errorf("create http request %s %s, error: %w", method, path, err)
+		}
+		if len(accept) > 0 {
+			req.Header.Set("Accept", strings.Join(accept, ", "))
+		}
+		return req, nil
+	}
+
+	req, err := newReq()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request %s %s to registry [%s], error: %w", method, path, r.Name, err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+	req, err = newReq()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasPrefix(strings.ToLower(challenge), "bearer"):
+		token, err := r.bearerToken(challenge)
+		if err != nil {
+			return nil, fmt.Errorf("registry [%s] asks for a token, error: %w", r.Name, err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case r.HasAuth():
+		req.SetBasicAuth(r.Username, r.Password)
+	default:
+		return nil, fmt.Errorf("registry [%s] asks for authentication, but it has no credentials", r.Name)
+	}
+	resp, err = r.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request %s %s to registry [%s], error: %w", method, path, r.Name, err)
+	}
+	return resp, nil
+}
+
+// the X-Registry-Auth for the Docker Engine. The Docker Engine needs this header even if the registry has no credentials.
+func (r *ImageRegistry) EncodedAuth() (string, error) {
+	authJson, err := json.Marshal(types.AuthConfig{
+		Username:      r.Username,
+		Password:      r.Password,
+		ServerAddress: r.Address,
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(authJson), nil
+}
+
+// the name of the Kubernetes Secret with the credentials of this registry
+func (r *ImageRegistry) PullSecretName() string {
+	return imagePullSecretPrefix + strings.Trim(nonDnsLabelChars.ReplaceAllString(strings.ToLower(r.Name), "-"), "-")
+}
+
+var nonDnsLabelChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// the content of the Kubernetes Secret of the type kubernetes.io/dockerconfigjson
+func (r *ImageRegistry) DockerConfigJson() ([]byte, error) {
+	type authEntry struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Auth     string `json:"auth"`
+	}
+	return json.Marshal(map[string]map[string]authEntry{
+		"auths": {
+			r.Address: {
+				Username: r.Username,
+				Password: r.Password,
+				Auth:     base64.StdEncoding.EncodeToString([]byte(r.Username + ":" + r.Password)),
+			},
+		},
+	})
+}
+
+// read and check the registries in conf/registries.json
+func loadImageRegistries(path string) ([]*ImageRegistry, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var registryFile struct {
+		Registries []*ImageRegistry `json:"registries"`
+	}
+	if err := json.Unmarshal(content, &registryFile); err != nil {
+		return nil, fmt.Errorf("unmarshal [%s], error: %w", path, err)
+	}
+
+	var problems []string
+	names := make(map[string]bool)
+	var hasDefault bool
+	for i, registry := range registryFile.Registries {
+		if len(registry.Name) == 0 || len(registry.Address) == 0 {
+			problems = append(problems, fmt.Sprintf("registry %d: name and address are required", i))
+			continue
+		}
+		if names[registry.Name] {
+			problems = append(problems, fmt.Sprintf("registry [%s] is declared more than once", registry.Name))
+			continue
+		}
+		names[registry.Name] = true
+		if registry.Default {
+			if hasDefault {
+				problems = append(problems, fmt.Sprintf("registry [%s]: only one registry can be default", registry.Name))
+			}
+			hasDefault = true
+		}
+		password, err := ResolveSecret(registry.Password)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("registry [%s]: password, %s", registry.Name, err.Error()))
+			continue
+		}
+		registry.Password = password
+		if err := registry.initHttpClient(); err != nil {
+			problems = append(problems, fmt.Sprintf("registry [%s]: %s", registry.Name, err.Error()))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("wrong registries in [%s]: %s", path, strings.Join(problems, "; "))
+	}
+	if len(registryFile.Registries) > 0 && !hasDefault {
+		registryFile.Registries[0].Default = true
+	}
+	return registryFile.Registries, nil
+}
+
+// InitImageRegistries reads conf/registries.json. Without this file, only the registry in app.conf is used.
+func InitImageRegistries() {
+	registries, err := loadImageRegistries(RegistryConfigPath)
+	if errors.Is(err, os.ErrNotExist) {
+		beego.Info(fmt.Sprintf("[%s] does not exist, so we only use the Docker Registry [%s].", RegistryConfigPath, DockerRegistry))
+		return
+	}
+	if err != nil {
+		outErr := fmt.Errorf("Initialize the Docker Registries, error: %w", err)
+		beego.Error(outErr)
+		panic(outErr)
+	}
+	SetImageRegistries(registries)
+	beego.Info(fmt.Sprintf("%d Docker Registries are initialized.", len(registries)))
+}
+
+// SetImageRegistries replaces the registries.
+func SetImageRegistries(registries []*ImageRegistry) {
+	imageRegistriesMu.Lock()
+	defer imageRegistriesMu.Unlock()
+	imageRegistries = registries
+}
+
+// the registry in app.conf, used when conf/registries.json does not exist
+func appConfImageRegistry() *ImageRegistry {
+	return &ImageRegistry{
+		Name:       DefaultImageRegistryName,
+		Address:    DockerRegistry,
+		Insecure:   true,
+		Default:    true,
+		httpClient: registryHttpClient,
+	}
+}
+
+// ListImageRegistries lists all registries, the default one first, and others sorted by name.
+func ListImageRegistries() []*ImageRegistry {
+	imageRegistriesMu.RLock()
+	defer imageRegistriesMu.RUnlock()
+	if len(imageRegistries) == 0 {
+		return []*ImageRegistry{appConfImageRegistry()}
+	}
+	registries := append([]*ImageRegistry{}, imageRegistries...)
+	sort.SliceStable(registries, func(i, j int) bool {
+		if registries[i].Default != registries[j].Default {
+			return registries[i].Default
+		}
+		return registries[i].Name < registries[j].Name
+	})
+	return registries
+}
+
+// DefaultImageRegistry is the registry used when no registry is chosen.
+func DefaultImageRegistry() *ImageRegistry {
+	return ListImageRegistries()[0]
+}
+
+// GetImageRegistry gets a registry by name. An empty name means the default registry.
+func GetImageRegistry(name string) (*ImageRegistry, bool) {
+	if len(name) == 0 {
+		return DefaultImageRegistry(), true
+	}
+	for _, registry := range ListImageRegistries() {
+		if registry.Name == name {
+			return registry, true
+		}
+	}
+	return nil, false
+}
+
+// the registry host of an image, e.g., "192.168.100.36:5000" of "192.168.100.36:5000/ubuntu:22.04". Images without a registry host are in Docker Hub.
+func imageRegistryAddress(image string) string {
+	slash := strings.Index(image, "/")
+	if slash < 0 {
+		return ""
+	}
+	host := image[:slash]
+	if strings.ContainsAny(host, ".:") || host == "localhost" {
+		return host
+	}
+	return ""
+}
+
+// the registry that has the image, if it is a registry of multi-cloud manager
+func registryOfImage(image string) (*ImageRegistry, bool) {
+	address := imageRegistryAddress(image)
+	if len(address) == 0 {
+		return nil, false
+	}
+	for _, registry := range ListImageRegistries() {
+		if registry.Address == address {
+			return registry, true
+		}
+	}
+	return nil, false
+}
+
+// the registries with credentials that have these images
+func registriesNeedPullSecret(images []string) []*ImageRegistry {
+	var registries []*ImageRegistry
+	added := make(map[string]bool)
+	for _, image := range images {
+		registry, found := registryOfImage(image)
+		if !found || !registry.HasAuth() || added[registry.Name] {
+			continue
+		}
+		added[registry.Name] = true
+		registries = append(registries, registry)
+	}
+	return registries
+}
+
+// ImagePullSecretsFor makes sure that the Secrets with the credentials of the registries of these images exist in the namespace, and returns them for the imagePullSecrets of pods.
+func ImagePullSecretsFor(namespace string, images ...string) ([]corev1.LocalObjectReference, error) {
+	var refs []corev1.LocalObjectReference
+	for _, registry := range registriesNeedPullSecret(images) {
+		dockerConfigJson, err := registry.DockerConfigJson()
+		if err != nil {
+			return nil, fmt.Errorf("make the docker config json of registry [%s], error: %w", registry.Name, err)
+		}
+		if err := ApplyDockerConfigSecret(namespace, registry.PullSecretName(), dockerConfigJson); err != nil {
+			return nil, fmt.Errorf("apply the image pull Secret of registry [%s], error: %w", registry.Name, err)
+		}
+		refs = append(refs, corev1.LocalObjectReference{Name: registry.PullSecretName()})
+	}
+	return refs, nil
+}
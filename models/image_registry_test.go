@@ -0,0 +1,204 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// use the given registries in a test
+func useImageRegistries(t *testing.T, registries ...*ImageRegistry) {
+	imageRegistriesMu.RLock()
+	oldRegistries := imageRegistries
+	imageRegistriesMu.RUnlock()
+	t.Cleanup(func() {
+		SetImageRegistries(oldRegistries)
+	})
+	SetImageRegistries(registries)
+}
+
+func TestLoadImageRegistries(t *testing.T) {
+	t.Setenv("MCM_TEST_SECRET_HARBOR_PASSWORD", "harbor-pass")
+	useSecretProviders(t, EnvSecretProvider{Prefix: "MCM_TEST_SECRET_"})
+
+	testCases := []struct {
+		name             string
+		content          string
+		expectedDefault  string
+		expectedPassword string
+		expectedErr      bool
+	}{
+		{
+			name:             "the first one is default",
+			content:          `{"registries": [{"name": "harbor", "address": "harbor.example.com", "username": "mcm", "password": "secret://harbor-password"}, {"name": "local", "address": "192.168.100.36:5000", "insecure": true}]}`,
+			expectedDefault:  "harbor",
+			expectedPassword: "harbor-pass",
+		},
+		{
+			name:            "default",
+			content:         `{"registries": [{"name": "harbor", "address": "harbor.example.com"}, {"name": "local", "address": "192.168.100.36:5000", "insecure": true, "default": true}]}`,
+			expectedDefault: "local",
+		},
+		{
+			name:        "2 default",
+			content:     `{"registries": [{"name": "harbor", "address": "harbor.example.com", "default": true}, {"name": "local", "address": "192.168.100.36:5000", "default": true}]}`,
+			expectedErr: true,
+		},
+		{
+			name:        "duplicate name",
+			content:     `{"registries": [{"name": "local", "address": "harbor.example.com"}, {"name": "local", "address": "192.168.100.36:5000"}]}`,
+			expectedErr: true,
+		},
+		{
+			name:        "no address",
+			content:     `{"registries": [{"name": "local"}]}`,
+			expectedErr: true,
+		},
+		{
+			name:        "missing secret",
+			content:     `{"registries": [{"name": "harbor", "address": "harbor.example.com", "username": "mcm", "password": "secret://nothing"}]}`,
+			expectedErr: true,
+		},
+		{
+			name:        "missing CA file",
+			content:     `{"registries": [{"name": "harbor", "address": "harbor.example.com", "caFile": "/nothing/ca.crt"}]}`,
+			expectedErr: true,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		path := filepath.Join(t.TempDir(), "registries.json")
+		assert.Nil(t, os.WriteFile(path, []byte(testCase.content), 0644))
+		registries, err := loadImageRegistries(path)
+		if testCase.expectedErr {
+			assert.NotNil(t, err, fmt.Sprintf("%s: error is expected", testCase.name))
+			continue
+		}
+		assert.Nil(t, err, fmt.Sprintf("%s: error is not expected", testCase.name))
+
+		useImageRegistries(t, registries...)
+		assert.Equal(t, testCase.expectedDefault, DefaultImageRegistry().Name, fmt.Sprintf("%s: default registry is not expected", testCase.name))
+		registry, found := GetImageRegistry("harbor")
+		if assert.True(t, found, fmt.Sprintf("%s: harbor should be found", testCase.name)) {
+			assert.Equal(t, testCase.expectedPassword, registry.Password, fmt.Sprintf("%s: password is not expected", testCase.name))
+		}
+	}
+
+	// without conf/registries.json, there is only the registry in app.conf
+	useImageRegistries(t)
+	registries := ListImageRegistries()
+	assert.Len(t, registries, 1)
+	assert.Equal(t, DockerRegistry, registries[0].Address)
+	_, found := GetImageRegistry("harbor")
+	assert.False(t, found)
+}
+
+func TestImageRegistryAddress(t *testing.T) {
+	testCases := []struct {
+		image           string
+		expectedAddress string
+	}{
+		{image: "192.168.100.36:5000/mcnettest:latest", expectedAddress: "192.168.100.36:5000"},
+		{image: "harbor.example.com/team/app:v1", expectedAddress: "harbor.example.com"},
+		{image: "localhost/app", expectedAddress: "localhost"},
+		{image: "library/ubuntu:22.04", expectedAddress: ""},
+		{image: "nginx", expectedAddress: ""},
+	}
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.image)
+		assert.Equal(t, testCase.expectedAddress, imageRegistryAddress(testCase.image), fmt.Sprintf("%s: address is not expected", testCase.image))
+	}
+}
+
+func TestImagePullSecrets(t *testing.T) {
+	useSimulatedK8s(t)
+	harbor := &ImageRegistry{Name: "Harbor_1", Address: "harbor.example.com", Username: "mcm", Password: "pass"}
+	useImageRegistries(t,
+		&ImageRegistry{Name: "local", Address: "192.168.100.36:5000", Insecure: true, Default: true},
+		harbor,
+	)
+	assert.Equal(t, "mcm-regcred-harbor-1", harbor.PullSecretName())
+
+	app := simTestApp("pullsecret", 1, "100m")
+	app.Containers = append(app.Containers,
+		K8sContainer{Name: "c2", Image: "harbor.example.com/team/app:v1"},
+		K8sContainer{Name: "c3", Image: "harbor.example.com/team/sidecar:v1"},
+		K8sContainer{Name: "c4", Image: "192.168.100.36:5000/mcnettest:latest"},
+	)
+	assert.Nil(t, CreateApplication(app))
+
+	// only the registry with credentials needs a Secret, and only one Secret for it
+	deployment, err := GetDeployment(KubernetesNamespace, app.Name+DeploymentSuffix)
+	assert.Nil(t, err)
+	assert.Equal(t, []apiv1.LocalObjectReference{{Name: "mcm-regcred-harbor-1"}}, deployment.Spec.Template.Spec.ImagePullSecrets)
+
+	secret, err := kubernetesClient.CoreV1().Secrets(KubernetesNamespace).Get(context.Background(), "mcm-regcred-harbor-1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, apiv1.SecretTypeDockerConfigJson, secret.Type)
+	var dockerConfig struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	assert.Nil(t, json.Unmarshal(secret.Data[apiv1.DockerConfigJsonKey], &dockerConfig))
+	assert.Equal(t, "bWNtOnBhc3M=", dockerConfig.Auths["harbor.example.com"].Auth)
+
+	// the changed password updates the Secret
+	harbor.Password = "new-pass"
+	refs, err := ImagePullSecretsFor(KubernetesNamespace, "harbor.example.com/team/app:v2", "nginx")
+	assert.Nil(t, err)
+	assert.Len(t, refs, 1)
+	secret, err = kubernetesClient.CoreV1().Secrets(KubernetesNamespace).Get(context.Background(), "mcm-regcred-harbor-1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(secret.Data[apiv1.DockerConfigJsonKey]), "new-pass"))
+}
+
+func TestReadPushProgress(t *testing.T) {
+	testCases := []struct {
+		name             string
+		stream           string
+		expectedDigest   string
+		expectedMessages int
+		expectedErr      bool
+	}{
+		{
+			name: "pushed",
+			stream: `{"status":"The push refers to repository [192.168.100.36:5000/helloworld]"}
+{"status":"Pushing","progressDetail":{"current":512,"total":1024},"progress":"[=====>    ]","id":"e07ee1baac5f"}
+{"status":"Pushed","progressDetail":{},"id":"e07ee1baac5f"}
+{"status":"latest: digest: sha256:abc size: 525"}
+{"progressDetail":{},"aux":{"Tag":"latest","Digest":"sha256:abc","Size":525}}
+`,
+			expectedDigest:   "sha256:abc",
+			expectedMessages: 5,
+		},
+		{
+			name: "error",
+			stream: `{"status":"Pushing","progressDetail":{"current":512,"total":1024},"id":"e07ee1baac5f"}
+{"errorDetail":{"message":"unauthorized: authentication required"},"error":"unauthorized: authentication required"}
+{"status":"not read"}
+`,
+			expectedMessages: 2,
+			expectedErr:      true,
+		},
+	}
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		var messages []PushProgress
+		digest, err := readPushProgress(strings.NewReader(testCase.stream), func(progress PushProgress) {
+			messages = append(messages, progress)
+		})
+		assert.Equal(t, testCase.expectedErr, err != nil, fmt.Sprintf("%s: error is not expected: %v", testCase.name, err))
+		assert.Equal(t, testCase.expectedDigest, digest, fmt.Sprintf("%s: digest is not expected", testCase.name))
+		assert.Len(t, messages, testCase.expectedMessages, fmt.Sprintf("%s: messages are not expected", testCase.name))
+	}
+}
@@ -16,6 +16,7 @@ import (
 	v1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	apiv1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -24,9 +25,14 @@ import (
 	"k8s.io/client-go/util/retry"
 )
 
-var kubernetesClient *kubernetes.Clientset
+// kubernetes.Interface can be a real clientset or the fake clientset of client-go.
+var kubernetesClient kubernetes.Interface
 
 func InitKubernetesClient() {
+	if beego.AppConfig.String("CloudType") == K8sSimulationCloudType {
+		initSimulatedKubernetes()
+		return
+	}
 	// default kubeconfig path
 	if KubeConfigPath == "" {
 		KubeConfigPath = resolveKubeConfigPath()
@@ -124,6 +130,15 @@ func CreateDeployment(d *v1.Deployment) (*v1.Deployment, error) {
 	return createdDeployment, err
 }
 
+func UpdateDeployment(d *v1.Deployment) (*v1.Deployment, error) {
+	ctx := context.Background()
+	updatedDeployment, err := kubernetesClient.AppsV1().Deployments(d.Namespace).Update(ctx, d, metav1.UpdateOptions{})
+	if err != nil {
+		beego.Error(fmt.Sprintf("Update deployment %s/%s error: %s", d.Namespace, d.Name, err.Error()))
+	}
+	return updatedDeployment, err
+}
+
 func DeleteDeployment(namespace, name string) error {
 	ctx := context.Background()
 	//deletePolicy := metav1.DeletePropagationForeground
@@ -141,6 +156,16 @@ func DeleteDeployment(namespace, name string) error {
 	return nil
 }
 
+func ListReplicaSets(namespace string, listOptions metav1.ListOptions) ([]v1.ReplicaSet, error) {
+	ctx := context.Background()
+	replicaSets, err := kubernetesClient.AppsV1().ReplicaSets(namespace).List(ctx, listOptions)
+	if err != nil {
+		beego.Error(fmt.Sprintf("List replicaSets in namespace [%s] error: %s", namespace, err.Error()))
+		return []v1.ReplicaSet{}, err
+	}
+	return replicaSets.Items, nil
+}
+
 func WaitForDeployDeleted(timeout int, checkInterval int, deploy *v1.Deployment) error {
 	return MyWaitFor(timeout, checkInterval, func() (bool, error) {
 		if deploy == nil {
@@ -165,6 +190,51 @@ func CreateService(s *apiv1.Service) (*apiv1.Service, error) {
 	return createdService, err
 }
 
+func UpdateService(s *apiv1.Service) (*apiv1.Service, error) {
+	ctx := context.Background()
+	updatedService, err := kubernetesClient.CoreV1().Services(s.Namespace).Update(ctx, s, metav1.UpdateOptions{})
+	if err != nil {
+		beego.Error(fmt.Sprintf("Update service %s/%s error: %s", s.Namespace, s.Name, err.Error()))
+	}
+	return updatedService, err
+}
+
+// ApplyDockerConfigSecret creates or updates a Secret of the type kubernetes.io/dockerconfigjson, which can be used as an imagePullSecret.
+func ApplyDockerConfigSecret(namespace, name string, dockerConfigJson []byte) error {
+	ctx := context.Background()
+	secret := &apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Type: apiv1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{apiv1.DockerConfigJsonKey: dockerConfigJson},
+	}
+	existing, err := kubernetesClient.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		if _, err := kubernetesClient.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+			beego.Error(fmt.Sprintf("Create secret %s/%s error: %s", namespace, name, err.Error()))
+			return err
+		}
+		beego.Info(fmt.Sprintf("Secret %s/%s is created.", namespace, name))
+		return nil
+	}
+	if err != nil {
+		beego.Error(fmt.Sprintf("Get secret %s/%s error: %s", namespace, name, err.Error()))
+		return err
+	}
+	if existing.Type == secret.Type && bytes.Equal(existing.Data[apiv1.DockerConfigJsonKey], dockerConfigJson) {
+		return nil
+	}
+	secret.ResourceVersion = existing.ResourceVersion
+	if _, err := kubernetesClient.CoreV1().Secrets(namespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		beego.Error(fmt.Sprintf("Update secret %s/%s error: %s", namespace, name, err.Error()))
+		return err
+	}
+	beego.Info(fmt.Sprintf("Secret %s/%s is updated.", namespace, name))
+	return nil
+}
+
 func DeleteService(namespace, name string) error {
 	ctx := context.Background()
 	//deletePolicy := metav1.DeletePropagationForeground
@@ -196,6 +266,43 @@ func GetService(namespace, name string) (*apiv1.Service, error) {
 	return service, nil
 }
 
+func GetPvc(namespace, name string) (*apiv1.PersistentVolumeClaim, error) {
+	ctx := context.Background()
+	pvc, err := kubernetesClient.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil && errors.IsNotFound(err) {
+		beego.Info(fmt.Sprintf("PVC %s/%s not found: %s", namespace, name, err.Error()))
+		return nil, nil
+	}
+	if err != nil {
+		beego.Error(fmt.Sprintf("Get PVC %s/%s error: %s", namespace, name, err.Error()))
+		return nil, err
+	}
+	return pvc, nil
+}
+
+func GetStorageClass(name string) (*storagev1.StorageClass, error) {
+	ctx := context.Background()
+	storageClass, err := kubernetesClient.StorageV1().StorageClasses().Get(ctx, name, metav1.GetOptions{})
+	if err != nil && errors.IsNotFound(err) {
+		beego.Info(fmt.Sprintf("StorageClass %s not found: %s", name, err.Error()))
+		return nil, nil
+	}
+	if err != nil {
+		beego.Error(fmt.Sprintf("Get StorageClass %s error: %s", name, err.Error()))
+		return nil, err
+	}
+	return storageClass, nil
+}
+
+func CreatePvc(pvc *apiv1.PersistentVolumeClaim) (*apiv1.PersistentVolumeClaim, error) {
+	ctx := context.Background()
+	createdPvc, err := kubernetesClient.CoreV1().PersistentVolumeClaims(pvc.Namespace).Create(ctx, pvc, metav1.CreateOptions{})
+	if err != nil {
+		beego.Error(fmt.Sprintf("Create PVC %s/%s error: %s", pvc.Namespace, pvc.Name, err.Error()))
+	}
+	return createdPvc, err
+}
+
 func GetJob(namespace, name string) (*batchv1.Job, error) {
 	ctx := context.Background()
 	job, err := kubernetesClient.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
@@ -247,6 +354,33 @@ func WaitForJobCompleted(timeout int, checkInterval int, jobNamespace string, jo
 	})
 }
 
+// get the logs of the succeeded pod of a job. Kubernetes adds the label "job-name" to the pods of a job.
+func GetJobLogs(namespace, jobName string) (string, error) {
+	pods, err := ListPods(namespace, metav1.ListOptions{LabelSelector: fmt.Sprintf("job-name=%s", jobName)})
+	if err != nil {
+		outErr := fmt.Errorf("List pods of Job [%s/%s], error: %w", namespace, jobName, err)
+		beego.Error(outErr)
+		return "", outErr
+	}
+	for _, pod := range pods {
+		if pod.Status.Phase != apiv1.PodSucceeded {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), ReqShortTimeout)
+		logs, err := kubernetesClient.CoreV1().Pods(namespace).GetLogs(pod.Name, &apiv1.PodLogOptions{}).DoRaw(ctx)
+		cancel()
+		if err != nil {
+			outErr := fmt.Errorf("Get logs of pod [%s/%s], error: %w", namespace, pod.Name, err)
+			beego.Error(outErr)
+			return "", outErr
+		}
+		return string(logs), nil
+	}
+	outErr := fmt.Errorf("Job [%s/%s] does not have a succeeded pod.", namespace, jobName)
+	beego.Error(outErr)
+	return "", outErr
+}
+
 func DeleteJob(namespace, name string) error {
 	ctx := context.Background()
 	deletePolicy := metav1.DeletePropagationForeground
@@ -288,7 +422,14 @@ func ListPodsOnNode(namespace string, nodeName string) ([]apiv1.Pod, error) {
 		beego.Error(ourErr)
 		return []apiv1.Pod{}, ourErr
 	}
-	return pods.Items, nil
+	// the fake clientset ignores the field selector, so we filter the pods again.
+	var podsOnNode []apiv1.Pod = []apiv1.Pod{}
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName == nodeName {
+			podsOnNode = append(podsOnNode, pod)
+		}
+	}
+	return podsOnNode, nil
 }
 
 func ListNodes(listOptions metav1.ListOptions) ([]apiv1.Node, error) {
@@ -428,30 +569,6 @@ func GetJoinCmd() (string, error) {
 	return join, nil
 }
 
-// kubectl drain <nodeName> --ignore-daemonsets
-func DrainNode(nodeName string) error {
-	K8sMasterIP := beego.AppConfig.String("k8sMasterIP")
-	sshPrivateKey := beego.AppConfig.String("k8sVmSshPrivateKey")
-	sshPort := SshPort
-	sshUser := SshRootUser
-
-	sshClient, err := SshClientWithPem(sshPrivateKey, sshUser, K8sMasterIP, sshPort)
-	if err != nil {
-		outErr := fmt.Errorf("Create ssh client with SSH key fail: error: %w", err)
-		beego.Error(outErr)
-		return outErr
-	}
-	defer sshClient.Close()
-
-	if _, err := SshOneCommand(sshClient, fmt.Sprintf("kubectl drain %s --ignore-daemonsets", nodeName)); err != nil {
-		outErr := fmt.Errorf("ssh error: %w", err)
-		beego.Error(outErr)
-		return outErr
-	}
-
-	return nil
-}
-
 // Add or update a taint to a node
 func TaintNode(nodeName string, taint *apiv1.Taint) error {
 	// When we use the update api, Kubernetes will compare the resource version of the node in our request and that of the node in etcd. If they are different, there will be a conflict error.
@@ -525,6 +642,22 @@ func NodeHasTaint(node *apiv1.Node, taint *apiv1.Taint) bool {
 
 // add a new node into Kubernetes cluster
 func AddNode(vm IaasVm, joinCmd string) error {
+	if K8sSimulated() {
+		return SimulateNodeJoin(vm)
+	}
+
+	// the VM runs the join command by cloud-init, so we only need to wait for it.
+	// We do not SSH to it, so it does not need an IP, and a VM just created may not have got its IP yet.
+	if vm.JoinedByCloudInit() {
+		beego.Info(fmt.Sprintf("VM [%s] joins Kubernetes by cloud-init, wait for it.", vm.Name))
+		if err := WaitForNodeJoin(WaitForTimeOut, 5, vm.Name); err != nil {
+			outErr := fmt.Errorf("Wait for node %s join by cloud-init, error: %w", vm.Name, err)
+			beego.Error(outErr)
+			return outErr
+		}
+		return nil
+	}
+
 	if len(vm.IPs) == 0 {
 		outErr := fmt.Errorf("the input vm [%s] has no ip address", vm.Name)
 		beego.Error(outErr)
@@ -559,16 +692,7 @@ func AddNode(vm IaasVm, joinCmd string) error {
 
 	// === FIX CONTAINERD CONFIG ===
 
-	cmds := []string{
-		"sudo mkdir -p /etc/containerd",
-		"sudo test -f /etc/containerd/config.toml || sudo containerd config default | sudo tee /etc/containerd/config.toml",
-		fmt.Sprintf("sudo sed -i 's/<IP>/%s/g' /etc/containerd/config.toml", k8sMasterIP),
-		"sudo systemctl restart containerd",
-		"sudo systemctl enable kubelet",
-		fmt.Sprintf("sudo %s --node-name=%s", joinCmd, name),
-	}
-
-	for _, cmd := range cmds {
+	for _, cmd := range nodeJoinCmds(name, joinCmd) {
 		out, err := SshOneCommand(sshClient, cmd)
 		if err != nil {
 			outErr := fmt.Errorf("ssh error at [%s]: %v, output: %s", cmd, err, string(out))
@@ -612,8 +736,19 @@ func AddNodes(vms []IaasVm) []error {
 		return nil
 	}
 
-	// use one joinCmd to add all nodes
-	joinCmd, err := GetJoinCmd()
+	// use one joinCmd to add all nodes. the simulated cluster and the nodes joining by cloud-init do not need it.
+	needJoinCmd := false
+	for _, vm := range vms {
+		if !vm.JoinedByCloudInit() {
+			needJoinCmd = true
+			break
+		}
+	}
+	var joinCmd string
+	var err error
+	if !K8sSimulated() && needJoinCmd {
+		joinCmd, err = GetJoinCmd()
+	}
 	if err != nil {
 		outErr := fmt.Errorf("AddNodes, GetJoinCmd error: %w", err)
 		beego.Error(outErr)
@@ -643,84 +778,6 @@ func AddNodes(vms []IaasVm) []error {
 	return errs
 }
 
-// delete a node from the Kubernetes cluster
-func UninstallNode(name string) error {
-	// // kubectl drain xxxxxx --ignore-daemonsets
-	if err := DrainNode(name); err != nil {
-		outErr := fmt.Errorf("DrainNode %s: error: %w", name, err)
-		beego.Error(outErr)
-		return outErr
-	}
-
-	// // put kubeconfig in /root/.kube
-	sshPrivateKey := beego.AppConfig.String("k8sVmSshPrivateKey")
-	sshPort := SshPort
-	sshUser := SshRootUser
-
-	node, err := GetNode(name, metav1.GetOptions{})
-	if err != nil {
-		outErr := fmt.Errorf("Kubernetes get node: error: %w", err)
-		beego.Error(outErr)
-		return outErr
-	}
-	nodeIp := GetNodeInternalIp(*node)
-
-	// we try to do these behaviors, if not successful, we continue to do the following behaviors, to enable deleting node with problems
-	func() {
-		sshClient, err := SshClientWithPem(sshPrivateKey, sshUser, nodeIp, sshPort)
-		if err != nil {
-			outErr := fmt.Errorf("Create ssh client with SSH key fail: error: %w", err)
-			beego.Error(outErr)
-			return
-		}
-		defer sshClient.Close()
-
-		// create the folder at the destination VM
-		if _, err := SshOneCommand(sshClient, fmt.Sprintf("mkdir -p /root/.kube")); err != nil {
-			outErr := fmt.Errorf("ssh error: %w", err)
-			beego.Error(outErr)
-			return
-		}
-
-		// put the kubeConfig into the folder of the destination VM
-		var kubeConfigPath string
-		if KubeConfigPath == "" {
-			kubeConfigPath = defaultKubeConfigPath
-		} else {
-			kubeConfigPath = KubeConfigPath
-		}
-		if err := SftpCopyFile(kubeConfigPath, defaultKubeConfigPath, sshClient); err != nil {
-			outErr := fmt.Errorf("SFTP error: %w", err)
-			beego.Error(outErr)
-			return
-		}
-
-		// // kubeadm reset the destination VM
-		if _, err := SshOneCommand(sshClient, fmt.Sprintf("kubeadm reset -f")); err != nil {
-			outErr := fmt.Errorf("ssh error: %w", err)
-			beego.Error(outErr)
-			return
-		}
-
-		// // Delete the folder /root/.kube
-		if _, err := SshOneCommand(sshClient, fmt.Sprintf("rm -rf /root/.kube")); err != nil {
-			outErr := fmt.Errorf("ssh error: %w", err)
-			beego.Error(outErr)
-			return
-		}
-	}()
-
-	// // delete the node from the Kubernetes Cluster
-	beego.Info(fmt.Sprintf("Use client-go API to delete node %s in Kubernetes cluster", name))
-	if err := DeleteNode(name, metav1.DeleteOptions{}); err != nil {
-		outErr := fmt.Errorf("use client-go API to delete node error: %w", err)
-		beego.Error(outErr)
-		return outErr
-	}
-
-	return nil
-}
-
 // delete nodes from the Kubernetes cluster concurrently
 func UninstallBatchNodes(nodeNames []string) []error {
 	var errs []error
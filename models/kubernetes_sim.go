@@ -0,0 +1,666 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+/**
+NOTE:
+
+In the "simulation" CloudType (configured by "CloudType" in app.conf), multi-cloud manager does not connect to a real Kubernetes cluster.
+It uses the fake clientset of client-go, which only saves objects in memory, and K8sSimulator does what the Kubernetes controllers, scheduler, and kubelets do:
+1. create and delete the pods of every Deployment according to its replicas and pod template, and delete the pods whose Deployment is deleted;
+   every pod template of a Deployment is recorded in a ReplicaSet with a revision number, which is only the history for rollbacks, and the pods are owned by the Deployment directly;
+2. bind every pending pod to a Ready node that fits its nodeSelector, required node affinity, required pod anti-affinity on hostname, taints, and resource requests;
+3. mark the bound pods as running and ready, and update the status of every Deployment.
+The VMs added as Kubernetes nodes become Ready nodes immediately, without SSH or kubeadm.
+*/
+
+const (
+	K8sSimulationCloudType string = "simulation"
+
+	DefaultK8sSimIntervalMs int = 1000
+
+	simPodIPPrefix string = "10.244"
+	deploymentKind string = "Deployment"
+)
+
+// the simulator of the fake Kubernetes cluster, nil when multi-cloud manager uses a real cluster
+var k8sSimulator *K8sSimulator
+
+// K8sSimulated shows whether multi-cloud manager is using a simulated Kubernetes cluster.
+func K8sSimulated() bool {
+	return k8sSimulator != nil
+}
+
+// SetKubernetesClient replaces the Kubernetes client, for example, with the fake clientset in tests.
+func SetKubernetesClient(client kubernetes.Interface) {
+	kubernetesClient = client
+}
+
+// use the fake clientset and start the simulator
+func initSimulatedKubernetes() {
+	intervalMs, err := beego.AppConfig.Int("K8sSimIntervalMs")
+	if err != nil || intervalMs <= 0 {
+		intervalMs = DefaultK8sSimIntervalMs
+	}
+	client := fake.NewSimpleClientset()
+	kubernetesClient = client
+	k8sSimulator = NewK8sSimulator(client)
+	go k8sSimulator.Run(time.Duration(intervalMs)*time.Millisecond, nil)
+	beego.Info(fmt.Sprintf("CloudType is [%s], so multi-cloud manager uses a simulated Kubernetes cluster, synchronized every %d ms.", K8sSimulationCloudType, intervalMs))
+}
+
+// K8sSimulator imitates the Kubernetes controllers, scheduler, and kubelets on a clientset without a real cluster.
+type K8sSimulator struct {
+	client kubernetes.Interface
+	mu     sync.Mutex // only one synchronization at a time
+	nextIP int
+}
+
+func NewK8sSimulator(client kubernetes.Interface) *K8sSimulator {
+	return &K8sSimulator{client: client, nextIP: 1}
+}
+
+// Run synchronizes the cluster periodically until stopCh is closed. If stopCh is nil, it runs forever.
+func (s *K8sSimulator) Run(interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.SyncOnce(); err != nil {
+			beego.Error(fmt.Sprintf("Kubernetes simulator synchronization, error: %s", err.Error()))
+		}
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SyncOnce makes the pods match the Deployments, binds the pending pods, and updates the status of the Deployments.
+func (s *K8sSimulator) SyncOnce() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx := context.Background()
+	deployments, err := s.client.AppsV1().Deployments(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("list deployments, error: %w", err)
+	}
+	pods, err := s.client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("list pods, error: %w", err)
+	}
+
+	// delete the pods whose Deployment does not exist
+	var deployExist map[string]bool = make(map[string]bool)
+	for _, d := range deployments.Items {
+		deployExist[d.Namespace+"/"+d.Name] = true
+	}
+	for _, pod := range pods.Items {
+		owner := metav1.GetControllerOf(&pod)
+		if owner != nil && owner.Kind == deploymentKind && !deployExist[pod.Namespace+"/"+owner.Name] {
+			if err := s.client.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{}); err != nil {
+				return fmt.Errorf("delete pod [%s/%s] of a deleted deployment, error: %w", pod.Namespace, pod.Name, err)
+			}
+		}
+	}
+
+	// delete the ReplicaSets whose Deployment does not exist
+	replicaSets, err := s.client.AppsV1().ReplicaSets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("list replicaSets, error: %w", err)
+	}
+	for _, rs := range replicaSets.Items {
+		owner := metav1.GetControllerOf(&rs)
+		if owner != nil && owner.Kind == deploymentKind && !deployExist[rs.Namespace+"/"+owner.Name] {
+			if err := s.client.AppsV1().ReplicaSets(rs.Namespace).Delete(ctx, rs.Name, metav1.DeleteOptions{}); err != nil {
+				return fmt.Errorf("delete replicaSet [%s/%s] of a deleted deployment, error: %w", rs.Namespace, rs.Name, err)
+			}
+		}
+	}
+
+	for i := range deployments.Items {
+		if err := s.syncDeployRevision(&deployments.Items[i]); err != nil {
+			return err
+		}
+		if err := s.syncDeployPods(&deployments.Items[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := s.bindPendingPods(); err != nil {
+		return err
+	}
+
+	for i := range deployments.Items {
+		if err := s.updateDeployStatus(&deployments.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the hash of the pod template, with which we know whether a pod is created from the current template of its Deployment.
+func podTemplateHash(template apiv1.PodTemplateSpec) string {
+	content, _ := json.Marshal(template)
+	hasher := fnv.New32a()
+	hasher.Write(content)
+	return fmt.Sprintf("%x", hasher.Sum32())
+}
+
+// the pods owned by this deployment. the fake clientset does not set UIDs, so the owner is found by kind and name.
+func (s *K8sSimulator) listDeployPods(d *appsv1.Deployment) ([]apiv1.Pod, error) {
+	pods, err := s.client.CoreV1().Pods(d.Namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list pods in namespace [%s], error: %w", d.Namespace, err)
+	}
+	var owned []apiv1.Pod
+	for _, pod := range pods.Items {
+		if owner := metav1.GetControllerOf(&pod); owner != nil && owner.Kind == deploymentKind && owner.Name == d.Name {
+			owned = append(owned, pod)
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool {
+		return owned[i].Name < owned[j].Name
+	})
+	return owned, nil
+}
+
+// Record the current pod template of a deployment in a ReplicaSet. A new template gets a new revision, and an old template used again, e.g., after a rollback, moves to a new revision.
+func (s *K8sSimulator) syncDeployRevision(d *appsv1.Deployment) error {
+	ctx := context.Background()
+	hash := podTemplateHash(d.Spec.Template)
+	rsName := d.Name + "-" + hash
+
+	replicaSets, err := s.client.AppsV1().ReplicaSets(d.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("list replicaSets in namespace [%s], error: %w", d.Namespace, err)
+	}
+	var current *appsv1.ReplicaSet
+	var old []appsv1.ReplicaSet
+	var maxRevision int64
+	for i, rs := range replicaSets.Items {
+		if owner := metav1.GetControllerOf(&rs); owner == nil || owner.Kind != deploymentKind || owner.Name != d.Name {
+			continue
+		}
+		if revision := revisionOf(&rs); revision > maxRevision {
+			maxRevision = revision
+		}
+		if rs.Name == rsName {
+			current = &replicaSets.Items[i]
+		} else {
+			old = append(old, rs)
+		}
+	}
+
+	revision := maxRevision + 1
+	switch {
+	case current == nil:
+		template := d.Spec.Template.DeepCopy()
+		if template.Labels == nil {
+			template.Labels = make(map[string]string)
+		}
+		template.Labels[appsv1.DefaultDeploymentUniqueLabelKey] = hash
+		rs := &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        rsName,
+				Namespace:   d.Namespace,
+				Labels:      template.Labels,
+				Annotations: map[string]string{DeploymentRevisionAnno: strconv.FormatInt(revision, 10)},
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(d, appsv1.SchemeGroupVersion.WithKind(deploymentKind)),
+				},
+			},
+			Spec: appsv1.ReplicaSetSpec{
+				Replicas: d.Spec.Replicas,
+				Selector: d.Spec.Selector,
+				Template: *template,
+			},
+		}
+		if _, err := s.client.AppsV1().ReplicaSets(d.Namespace).Create(ctx, rs, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("create replicaSet [%s/%s], error: %w", d.Namespace, rsName, err)
+		}
+	case revisionOf(current) < maxRevision:
+		current.Annotations[DeploymentRevisionAnno] = strconv.FormatInt(revision, 10)
+		if _, err := s.client.AppsV1().ReplicaSets(d.Namespace).Update(ctx, current, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("update the revision of replicaSet [%s/%s], error: %w", d.Namespace, rsName, err)
+		}
+	default:
+		revision = revisionOf(current)
+	}
+
+	// only keep the latest old ReplicaSets as the history
+	var historyLimit int = 10
+	if d.Spec.RevisionHistoryLimit != nil {
+		historyLimit = int(*d.Spec.RevisionHistoryLimit)
+	}
+	sort.Slice(old, func(i, j int) bool {
+		return revisionOf(&old[i]) < revisionOf(&old[j])
+	})
+	for i := 0; i < len(old)-historyLimit; i++ {
+		if err := s.client.AppsV1().ReplicaSets(d.Namespace).Delete(ctx, old[i].Name, metav1.DeleteOptions{}); err != nil {
+			return fmt.Errorf("delete old replicaSet [%s/%s], error: %w", d.Namespace, old[i].Name, err)
+		}
+	}
+
+	// only the annotation is patched, so that the spec updated at the same time is not overwritten.
+	if revisionOf(d) == revision {
+		return nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{DeploymentRevisionAnno: strconv.FormatInt(revision, 10)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("marshal the revision of deployment [%s/%s], error: %w", d.Namespace, d.Name, err)
+	}
+	if _, err := s.client.AppsV1().Deployments(d.Namespace).Patch(ctx, d.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("patch the revision of deployment [%s/%s], error: %w", d.Namespace, d.Name, err)
+	}
+	return nil
+}
+
+// Replace the pods created from old templates, and create or delete pods to match the replicas. The rolling update finishes at once.
+func (s *K8sSimulator) syncDeployPods(d *appsv1.Deployment) error {
+	ctx := context.Background()
+	hash := podTemplateHash(d.Spec.Template)
+	var replicas int = 1
+	if d.Spec.Replicas != nil {
+		replicas = int(*d.Spec.Replicas)
+	}
+
+	pods, err := s.listDeployPods(d)
+	if err != nil {
+		return err
+	}
+	var current []apiv1.Pod
+	for _, pod := range pods {
+		if pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey] == hash && len(current) < replicas {
+			current = append(current, pod)
+			continue
+		}
+		if err := s.client.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{}); err != nil {
+			return fmt.Errorf("delete pod [%s/%s], error: %w", pod.Namespace, pod.Name, err)
+		}
+	}
+
+	var usedNames map[string]bool = make(map[string]bool)
+	for _, pod := range current {
+		usedNames[pod.Name] = true
+	}
+	for i := 0; len(current) < replicas; i++ {
+		name := fmt.Sprintf("%s-%s-%d", d.Name, hash, i)
+		if usedNames[name] {
+			continue
+		}
+		pod := &apiv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   d.Namespace,
+				Labels:      map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: hash},
+				Annotations: d.Spec.Template.Annotations,
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(d, appsv1.SchemeGroupVersion.WithKind(deploymentKind)),
+				},
+			},
+			Spec: *d.Spec.Template.Spec.DeepCopy(),
+			Status: apiv1.PodStatus{
+				Phase: apiv1.PodPending,
+			},
+		}
+		for key, value := range d.Spec.Template.Labels {
+			pod.Labels[key] = value
+		}
+		createdPod, err := s.client.CoreV1().Pods(d.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("create pod [%s/%s], error: %w", d.Namespace, name, err)
+		}
+		usedNames[name] = true
+		current = append(current, *createdPod)
+	}
+	return nil
+}
+
+// Bind every pending pod to a node, and mark it as running.
+func (s *K8sSimulator) bindPendingPods() error {
+	ctx := context.Background()
+	nodes, err := s.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("list nodes, error: %w", err)
+	}
+	sort.Slice(nodes.Items, func(i, j int) bool {
+		return nodes.Items[i].Name < nodes.Items[j].Name
+	})
+	podList, err := s.client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("list pods, error: %w", err)
+	}
+	pods := podList.Items
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Namespace+"/"+pods[i].Name < pods[j].Namespace+"/"+pods[j].Name
+	})
+
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.Phase != apiv1.PodPending && pod.Status.Phase != "" {
+			continue
+		}
+		nodeName := pod.Spec.NodeName
+		if len(nodeName) == 0 {
+			nodeName = simSelectNode(pod, nodes.Items, pods)
+			if len(nodeName) == 0 {
+				beego.Info(fmt.Sprintf("Kubernetes simulator: no node fits pod [%s/%s], it stays pending.", pod.Namespace, pod.Name))
+				continue
+			}
+		}
+		var node *apiv1.Node
+		for j := range nodes.Items {
+			if nodes.Items[j].Name == nodeName {
+				node = &nodes.Items[j]
+				break
+			}
+		}
+		if node == nil {
+			// the same as a real cluster, a pod with the name of a node that does not exist stays pending.
+			continue
+		}
+
+		pod.Spec.NodeName = nodeName
+		pod.Status = s.runningPodStatus(pod, node)
+		updatedPod, err := s.client.CoreV1().Pods(pod.Namespace).Update(ctx, pod, metav1.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("bind pod [%s/%s] to node [%s], error: %w", pod.Namespace, pod.Name, nodeName, err)
+		}
+		*pod = *updatedPod
+	}
+	return nil
+}
+
+func (s *K8sSimulator) runningPodStatus(pod *apiv1.Pod, node *apiv1.Node) apiv1.PodStatus {
+	hostIP := GetNodeInternalIp(*node)
+	podIP := hostIP
+	if !pod.Spec.HostNetwork {
+		podIP = fmt.Sprintf("%s.%d.%d", simPodIPPrefix, s.nextIP/250, s.nextIP%250+1)
+		s.nextIP++
+	}
+	now := metav1.Now()
+	status := apiv1.PodStatus{
+		Phase:     apiv1.PodRunning,
+		HostIP:    hostIP,
+		PodIP:     podIP,
+		PodIPs:    []apiv1.PodIP{{IP: podIP}},
+		StartTime: &now,
+		Conditions: []apiv1.PodCondition{
+			{Type: apiv1.PodScheduled, Status: apiv1.ConditionTrue, LastTransitionTime: now},
+			{Type: apiv1.PodReady, Status: apiv1.ConditionTrue, LastTransitionTime: now},
+		},
+	}
+	for _, container := range pod.Spec.Containers {
+		status.ContainerStatuses = append(status.ContainerStatuses, apiv1.ContainerStatus{
+			Name:    container.Name,
+			Image:   container.Image,
+			Ready:   true,
+			Started: &[]bool{true}[0],
+			State:   apiv1.ContainerState{Running: &apiv1.ContainerStateRunning{StartedAt: now}},
+		})
+	}
+	return status
+}
+
+// Choose the node with the most free CPU among the nodes fitting the pod. Return "" if no node fits it.
+func simSelectNode(pod *apiv1.Pod, nodes []apiv1.Node, pods []apiv1.Pod) string {
+	var chosen string
+	var chosenFreeCpu int64 = -1
+	for _, node := range nodes {
+		if !simNodeFits(pod, node, pods) {
+			continue
+		}
+		freeCpu := node.Status.Allocatable.Cpu().MilliValue()
+		for _, p := range pods {
+			if p.Spec.NodeName == node.Name && p.Status.Phase == apiv1.PodRunning {
+				freeCpu -= int64(GetResOccupiedByPod(p).CpuCore * 1000)
+			}
+		}
+		if freeCpu > chosenFreeCpu {
+			chosen, chosenFreeCpu = node.Name, freeCpu
+		}
+	}
+	return chosen
+}
+
+// whether the pod can run on the node, checked like the Kubernetes scheduler but simplified
+func simNodeFits(pod *apiv1.Pod, node apiv1.Node, pods []apiv1.Pod) bool {
+	if node.Spec.Unschedulable || ExtractNodeStatus(node) != string(apiv1.NodeReady) {
+		return false
+	}
+
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == apiv1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for j := range pod.Spec.Tolerations {
+			if pod.Spec.Tolerations[j].ToleratesTaint(taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+
+	if !labels.SelectorFromSet(pod.Spec.NodeSelector).Matches(labels.Set(node.Labels)) {
+		return false
+	}
+	if affinity := pod.Spec.Affinity; affinity != nil && affinity.NodeAffinity != nil && affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil {
+		if !simMatchNodeSelectorTerms(affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms, node.Labels) {
+			return false
+		}
+	}
+
+	// the pods already on this node
+	var podsOnNode []apiv1.Pod
+	for _, p := range pods {
+		if p.Spec.NodeName == node.Name && p.Status.Phase == apiv1.PodRunning && !(p.Namespace == pod.Namespace && p.Name == pod.Name) {
+			podsOnNode = append(podsOnNode, p)
+		}
+	}
+
+	// only the anti-affinity on hostname is simulated, which is what multi-cloud manager uses.
+	if affinity := pod.Spec.Affinity; affinity != nil && affinity.PodAntiAffinity != nil {
+		for _, term := range affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+			if term.TopologyKey != apiv1.LabelHostname {
+				continue
+			}
+			selector, err := metav1.LabelSelectorAsSelector(term.LabelSelector)
+			if err != nil {
+				return false
+			}
+			for _, p := range podsOnNode {
+				if p.Namespace == pod.Namespace && selector.Matches(labels.Set(p.Labels)) {
+					return false
+				}
+			}
+		}
+	}
+
+	var used K8sNodeRes
+	for _, p := range podsOnNode {
+		occupied := GetResOccupiedByPod(p)
+		used.CpuCore += occupied.CpuCore
+		used.Memory += occupied.Memory
+		used.Storage += occupied.Storage
+	}
+	need := GetResOccupiedByPod(*pod)
+	allocatable := node.Status.Allocatable
+	if used.CpuCore+need.CpuCore > float64(allocatable.Cpu().MilliValue())/1000 ||
+		used.Memory+need.Memory > float64(allocatable.Memory().Value())/1024/1024 ||
+		used.Storage+need.Storage > float64(allocatable.StorageEphemeral().Value())/1024/1024/1024 {
+		return false
+	}
+	return true
+}
+
+// the terms are ORed, and the expressions in a term are ANDed.
+func simMatchNodeSelectorTerms(terms []apiv1.NodeSelectorTerm, nodeLabels map[string]string) bool {
+	for _, term := range terms {
+		matched := true
+		for _, expr := range term.MatchExpressions {
+			value, exist := nodeLabels[expr.Key]
+			switch expr.Operator {
+			case apiv1.NodeSelectorOpIn:
+				matched = exist && stringInSlice(value, expr.Values)
+			case apiv1.NodeSelectorOpNotIn:
+				matched = !exist || !stringInSlice(value, expr.Values)
+			case apiv1.NodeSelectorOpExists:
+				matched = exist
+			case apiv1.NodeSelectorOpDoesNotExist:
+				matched = !exist
+			default:
+				matched = false
+			}
+			if !matched {
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func stringInSlice(s string, list []string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// set the status of the deployment by its pods. only the status is patched, so that the spec updated at the same time is not overwritten.
+func (s *K8sSimulator) updateDeployStatus(d *appsv1.Deployment) error {
+	pods, err := s.listDeployPods(d)
+	if err != nil {
+		return err
+	}
+	var status appsv1.DeploymentStatus
+	for _, pod := range pods {
+		status.Replicas++
+		status.UpdatedReplicas++
+		if pod.Status.Phase == apiv1.PodRunning {
+			status.ReadyReplicas++
+			status.AvailableReplicas++
+		}
+	}
+	status.UnavailableReplicas = status.Replicas - status.AvailableReplicas
+	status.ObservedGeneration = d.Generation
+
+	var available apiv1.ConditionStatus = apiv1.ConditionFalse
+	if d.Spec.Replicas == nil || status.AvailableReplicas >= *d.Spec.Replicas {
+		available = apiv1.ConditionTrue
+	}
+	now := metav1.Now()
+	status.Conditions = []appsv1.DeploymentCondition{
+		{Type: appsv1.DeploymentAvailable, Status: available, LastUpdateTime: now, LastTransitionTime: now},
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{"status": status})
+	if err != nil {
+		return fmt.Errorf("marshal the status of deployment [%s/%s], error: %w", d.Namespace, d.Name, err)
+	}
+	if _, err := s.client.AppsV1().Deployments(d.Namespace).Patch(context.Background(), d.Name, types.MergePatchType, patch, metav1.PatchOptions{}, "status"); err != nil {
+		return fmt.Errorf("patch the status of deployment [%s/%s], error: %w", d.Namespace, d.Name, err)
+	}
+	return nil
+}
+
+// SimulateNodeJoin registers a VM as a Ready node in the simulated cluster, with the resources of the VM as the allocatable resources.
+func SimulateNodeJoin(vm IaasVm) error {
+	if len(vm.IPs) == 0 {
+		outErr := fmt.Errorf("the input vm [%s] has no ip address", vm.Name)
+		beego.Error(outErr)
+		return outErr
+	}
+	allocatable := apiv1.ResourceList{
+		apiv1.ResourceCPU:              *resource.NewMilliQuantity(int64(CalcVmAvailVcpu(vm.VCpu)*1000), resource.DecimalSI),
+		apiv1.ResourceMemory:           *resource.NewQuantity(int64(CalcVmAvailRamMiB(vm.Ram))*1024*1024, resource.BinarySI),
+		apiv1.ResourceEphemeralStorage: *resource.NewQuantity(int64(CalcVmAvailStorGiB(vm.Storage))*1024*1024*1024, resource.BinarySI),
+		apiv1.ResourcePods:             *resource.NewQuantity(110, resource.DecimalSI),
+	}
+	now := metav1.Now()
+	node := &apiv1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: vm.Name,
+			Labels: map[string]string{
+				apiv1.LabelHostname: vm.Name,
+				apiv1.LabelOSStable: "linux",
+			},
+		},
+		Status: apiv1.NodeStatus{
+			Capacity:    allocatable,
+			Allocatable: allocatable,
+			Addresses: []apiv1.NodeAddress{
+				{Type: apiv1.NodeInternalIP, Address: vm.IPs[0]},
+				{Type: apiv1.NodeHostName, Address: vm.Name},
+			},
+			Conditions: []apiv1.NodeCondition{
+				{Type: apiv1.NodeReady, Status: apiv1.ConditionTrue, LastHeartbeatTime: now, LastTransitionTime: now},
+			},
+		},
+	}
+	if _, err := kubernetesClient.CoreV1().Nodes().Create(context.Background(), node, metav1.CreateOptions{}); err != nil {
+		outErr := fmt.Errorf("simulated cluster, create node [%s], error: %w", vm.Name, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	beego.Info(fmt.Sprintf("VM [%s] joins the simulated Kubernetes cluster as a node.", vm.Name))
+	return nil
+}
+
+// SimulateNodeRemove evicts all pods on a node, and deletes the node from the simulated cluster.
+func SimulateNodeRemove(name string) error {
+	ctx := context.Background()
+	pods, err := ListPodsOnNode(metav1.NamespaceAll, name)
+	if err != nil {
+		outErr := fmt.Errorf("simulated cluster, list pods on node [%s], error: %w", name, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	for _, pod := range pods {
+		if err := kubernetesClient.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{}); err != nil {
+			outErr := fmt.Errorf("simulated cluster, evict pod [%s/%s] on node [%s], error: %w", pod.Namespace, pod.Name, name, err)
+			beego.Error(outErr)
+			return outErr
+		}
+	}
+	if err := DeleteNode(name, metav1.DeleteOptions{}); err != nil {
+		outErr := fmt.Errorf("simulated cluster, delete node [%s], error: %w", name, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// use a simulated cluster in a test, and restore the original client after the test.
+func useSimulatedK8s(t *testing.T) *K8sSimulator {
+	oldClient, oldSimulator := kubernetesClient, k8sSimulator
+	t.Cleanup(func() {
+		kubernetesClient, k8sSimulator = oldClient, oldSimulator
+	})
+	client := fake.NewSimpleClientset()
+	SetKubernetesClient(client)
+	k8sSimulator = NewK8sSimulator(client)
+	return k8sSimulator
+}
+
+func simTestApp(name string, replicas int32, cpu string) K8sApp {
+	return K8sApp{
+		Name:     name,
+		Replicas: replicas,
+		Containers: []K8sContainer{
+			{
+				Name:  "c1",
+				Image: "nginx",
+				Resources: K8sResReq{
+					Requests: K8sResList{CPU: cpu, Memory: "100Mi"},
+				},
+			},
+		},
+	}
+}
+
+func appHostNames(t *testing.T, appName string) []string {
+	app, err, _ := GetApplication(KubernetesNamespace, appName)
+	assert.Nil(t, err)
+	var hostNames []string
+	for _, host := range app.Hosts {
+		hostNames = append(hostNames, host.HostName)
+	}
+	sort.Strings(hostNames)
+	return hostNames
+}
+
+func TestK8sSimulatorApplication(t *testing.T) {
+	simulator := useSimulatedK8s(t)
+
+	assert.Nil(t, AddNode(IaasVm{Name: "n1", IPs: []string{"10.0.0.1"}, VCpu: 4, Ram: 8192, Storage: 50}, ""))
+	assert.Nil(t, AddNode(IaasVm{Name: "n2", IPs: []string{"10.0.0.2"}, VCpu: 4, Ram: 8192, Storage: 50}, ""))
+	nodes, err := ListNodes(metav1.ListOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, nodes, 2)
+	assert.Equal(t, string(apiv1.NodeReady), ExtractNodeStatus(nodes[0]))
+
+	// 2 replicas on 2 different nodes because of the pod anti-affinity
+	assert.Nil(t, CreateApplication(simTestApp("app1", 2, "1")))
+	app, err, _ := GetApplication(KubernetesNamespace, "app1")
+	assert.Nil(t, err)
+	assert.Equal(t, NotStableStatus, app.Status)
+
+	assert.Nil(t, simulator.SyncOnce())
+	app, err, _ = GetApplication(KubernetesNamespace, "app1")
+	assert.Nil(t, err)
+	assert.Equal(t, RunningStatus, app.Status)
+	assert.Equal(t, []string{"n1", "n2"}, appHostNames(t, "app1"))
+
+	podsOnN1, err := ListPodsOnNode(KubernetesNamespace, "n1")
+	assert.Nil(t, err)
+	assert.Len(t, podsOnN1, 1)
+
+	// the third replica cannot run, because every node already has one
+	assert.Nil(t, CreateApplication(simTestApp("app2", 3, "1")))
+	assert.Nil(t, simulator.SyncOnce())
+	app, err, _ = GetApplication(KubernetesNamespace, "app2")
+	assert.Nil(t, err)
+	assert.Equal(t, NotStableStatus, app.Status)
+	assert.Len(t, app.Hosts, 2)
+
+	// migrate to one node
+	err, _ = MigrateApplication(KubernetesNamespace, "app1", []string{"n2"}, []string{"500m"})
+	assert.Nil(t, err)
+	assert.Nil(t, simulator.SyncOnce())
+	app, err, _ = GetApplication(KubernetesNamespace, "app1")
+	assert.Nil(t, err)
+	assert.Equal(t, RunningStatus, app.Status)
+	assert.Equal(t, []string{"n2"}, appHostNames(t, "app1"))
+
+	// the pods are deleted with the deployment. DeleteApplication is not used here, because it waits for 10 seconds at least.
+	assert.Nil(t, DeleteDeployment(KubernetesNamespace, "app1"+DeploymentSuffix))
+	assert.Nil(t, simulator.SyncOnce())
+	pods, err := ListPods(KubernetesNamespace, metav1.ListOptions{LabelSelector: "app=app1"})
+	assert.Nil(t, err)
+	assert.Len(t, pods, 0)
+
+	// the node is removed with its pods
+	assert.Nil(t, UninstallNode("n1"))
+	nodes, err = ListNodes(metav1.ListOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, nodes, 1)
+	podsOnN1, err = ListPodsOnNode(KubernetesNamespace, "n1")
+	assert.Nil(t, err)
+	assert.Len(t, podsOnN1, 0)
+}
+
+func TestInnerSimNodeFits(t *testing.T) {
+	readyNode := func(name string, cpu string) apiv1.Node {
+		node := apiv1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{apiv1.LabelHostname: name, "zone": "a"}},
+			Status: apiv1.NodeStatus{
+				Allocatable: apiv1.ResourceList{
+					apiv1.ResourceCPU:              resource.MustParse(cpu),
+					apiv1.ResourceMemory:           resource.MustParse("4Gi"),
+					apiv1.ResourceEphemeralStorage: resource.MustParse("20Gi"),
+				},
+				Conditions: []apiv1.NodeCondition{{Type: apiv1.NodeReady, Status: apiv1.ConditionTrue}},
+			},
+		}
+		return node
+	}
+	pendingPod := func(cpu string) apiv1.Pod {
+		return apiv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "p", Namespace: "default", Labels: map[string]string{"app": "a1"}},
+			Spec: apiv1.PodSpec{Containers: []apiv1.Container{{
+				Name:      "c1",
+				Resources: apiv1.ResourceRequirements{Requests: apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse(cpu)}},
+			}}},
+		}
+	}
+
+	testCases := []struct {
+		name           string
+		pod            func() apiv1.Pod
+		node           func() apiv1.Node
+		existingPods   []apiv1.Pod
+		expectedResult bool
+	}{
+		{
+			name:           "fit",
+			pod:            func() apiv1.Pod { return pendingPod("1") },
+			node:           func() apiv1.Node { return readyNode("n1", "2") },
+			expectedResult: true,
+		},
+		{
+			name:           "cpu not enough",
+			pod:            func() apiv1.Pod { return pendingPod("3") },
+			node:           func() apiv1.Node { return readyNode("n1", "2") },
+			expectedResult: false,
+		},
+		{
+			name: "cpu used by other pods",
+			pod:  func() apiv1.Pod { return pendingPod("1") },
+			node: func() apiv1.Node { return readyNode("n1", "2") },
+			existingPods: func() []apiv1.Pod {
+				p := pendingPod("1500m")
+				p.Name, p.Labels = "other", nil
+				p.Spec.NodeName, p.Status.Phase = "n1", apiv1.PodRunning
+				return []apiv1.Pod{p}
+			}(),
+			expectedResult: false,
+		},
+		{
+			name: "not ready",
+			pod:  func() apiv1.Pod { return pendingPod("1") },
+			node: func() apiv1.Node {
+				n := readyNode("n1", "2")
+				n.Status.Conditions[0].Status = apiv1.ConditionFalse
+				return n
+			},
+			expectedResult: false,
+		},
+		{
+			name: "taint not tolerated",
+			pod:  func() apiv1.Pod { return pendingPod("1") },
+			node: func() apiv1.Node {
+				n := readyNode("n1", "2")
+				n.Spec.Taints = []apiv1.Taint{*NetTestTaint}
+				return n
+			},
+			expectedResult: false,
+		},
+		{
+			name: "taint tolerated",
+			pod: func() apiv1.Pod {
+				p := pendingPod("1")
+				p.Spec.Tolerations = []apiv1.Toleration{NetTestToleration}
+				return p
+			},
+			node: func() apiv1.Node {
+				n := readyNode("n1", "2")
+				n.Spec.Taints = []apiv1.Taint{*NetTestTaint}
+				return n
+			},
+			expectedResult: true,
+		},
+		{
+			name: "node selector not matched",
+			pod: func() apiv1.Pod {
+				p := pendingPod("1")
+				p.Spec.NodeSelector = map[string]string{"zone": "b"}
+				return p
+			},
+			node:           func() apiv1.Node { return readyNode("n1", "2") },
+			expectedResult: false,
+		},
+		{
+			name: "node affinity matched",
+			pod: func() apiv1.Pod {
+				p := pendingPod("1")
+				p.Spec.Affinity = &apiv1.Affinity{NodeAffinity: replicaNodeAffinity([]string{"n0", "n1"})}
+				return p
+			},
+			node:           func() apiv1.Node { return readyNode("n1", "2") },
+			expectedResult: true,
+		},
+		{
+			name: "node affinity not matched",
+			pod: func() apiv1.Pod {
+				p := pendingPod("1")
+				p.Spec.Affinity = &apiv1.Affinity{NodeAffinity: replicaNodeAffinity([]string{"n0"})}
+				return p
+			},
+			node:           func() apiv1.Node { return readyNode("n1", "2") },
+			expectedResult: false,
+		},
+		{
+			name: "pod anti-affinity",
+			pod: func() apiv1.Pod {
+				p := pendingPod("100m")
+				p.Spec.Affinity = &apiv1.Affinity{PodAntiAffinity: &apiv1.PodAntiAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: []apiv1.PodAffinityTerm{{
+						TopologyKey:   apiv1.LabelHostname,
+						LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "a1"}},
+					}},
+				}}
+				return p
+			},
+			node: func() apiv1.Node { return readyNode("n1", "2") },
+			existingPods: func() []apiv1.Pod {
+				p := pendingPod("100m")
+				p.Name = "replica"
+				p.Spec.NodeName, p.Status.Phase = "n1", apiv1.PodRunning
+				return []apiv1.Pod{p}
+			}(),
+			expectedResult: false,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		pod := testCase.pod()
+		actualResult := simNodeFits(&pod, testCase.node(), testCase.existingPods)
+		assert.Equal(t, testCase.expectedResult, actualResult, fmt.Sprintf("%s: result is not expected", testCase.name))
+	}
+}
@@ -29,6 +29,10 @@ type Local struct {
 	ImageInstallMethod string
 	LibvirtURI         string
 
+	// Backend is "virsh" (default) or "libvirt". With "libvirt", the disks are volumes in StoragePool.
+	Backend     string
+	StoragePool string
+
 	Password   string
 	SshPubKey  string
 	StaticIP   string
@@ -313,6 +317,13 @@ func (l *Local) ShowWebUrl() string { return l.IP }
 
 // GetVM: trả chi tiết 1 VM
 func (l *Local) GetVM(vmID string) (*IaasVm, error) {
+	if l.useLibvirt() {
+		vm, err := l.libvirtGetVM(vmID)
+		if !l.fallBackToVirsh("get VM", err) {
+			return vm, err
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -406,6 +417,13 @@ func (l *Local) GetVM(vmID string) (*IaasVm, error) {
 
 // ListAllVMs: gộp qemu:///system và qemu:///session, dùng getVMFromURI
 func (l *Local) ListAllVMs() ([]IaasVm, error) {
+	if l.useLibvirt() {
+		vms, err := l.libvirtListAllVMs()
+		if !l.fallBackToVirsh("list VMs", err) {
+			return vms, err
+		}
+	}
+
 	vms := []IaasVm{}
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -455,6 +473,15 @@ func (l *Local) ListAllVMs() ([]IaasVm, error) {
 
 // CreateVM: tạo VM cơ bản qua virt-install (import nếu có ImagePath)
 func (l *Local) CreateVM(name string, vcpu, ramMB, storageGB int) (*IaasVm, error) {
+	return l.createVM(name, vcpu, ramMB, storageGB, "")
+}
+
+// CreateVMWithUserData: như CreateVM, user-data được đưa vào VM bằng một NoCloud seed ISO
+func (l *Local) CreateVMWithUserData(name string, vcpu, ramMB, storageGB int, userData string) (*IaasVm, error) {
+	return l.createVM(name, vcpu, ramMB, storageGB, userData)
+}
+
+func (l *Local) createVM(name string, vcpu, ramMB, storageGB int, userData string) (*IaasVm, error) {
 	if vcpu <= 0 {
 		vcpu = 2
 	}
@@ -471,6 +498,26 @@ func (l *Local) CreateVM(name string, vcpu, ramMB, storageGB int) (*IaasVm, erro
 		l.PoolDir = "/var/lib/libvirt/images"
 	}
 
+	seedISO := ""
+	if userData != "" {
+		var err error
+		if seedISO, err = l.makeSeedISO(name, userData); err != nil {
+			return nil, err
+		}
+		// the ISO is uploaded as a volume of the storage pool, so the local file is not needed after the VM is created
+		defer os.Remove(seedISO)
+	}
+
+	if l.useLibvirt() {
+		vm, err := l.libvirtCreateVM(name, vcpu, ramMB, storageGB, seedISO)
+		if !l.fallBackToVirsh("create VM", err) {
+			if err != nil {
+				return nil, err
+			}
+			return l.waitForVmIPs(vm)
+		}
+	}
+
 	diskPath, err := l.makeDiskFor(name, storageGB, l.ImagePath)
 	if err != nil {
 		return nil, err
@@ -486,6 +533,13 @@ func (l *Local) CreateVM(name string, vcpu, ramMB, storageGB int) (*IaasVm, erro
 		"--noautoconsole",
 		"--os-variant", "generic",
 	}
+	if seedISO != "" {
+		seedVol, err := l.virshUploadSeedISO(name, seedISO)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--disk", fmt.Sprintf("vol=%s,device=cdrom", seedVol))
+	}
 	if l.ImagePath != "" {
 		args = append(args, "--import", "--boot", "hd")
 	}
@@ -493,6 +547,9 @@ func (l *Local) CreateVM(name string, vcpu, ramMB, storageGB int) (*IaasVm, erro
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
 	if _, err := runCmd(ctx, "virt-install", args...); err != nil {
+		if seedISO != "" {
+			l.virshRemoveSeedVolume(name)
+		}
 		return nil, err
 	}
 
@@ -502,7 +559,7 @@ func (l *Local) CreateVM(name string, vcpu, ramMB, storageGB int) (*IaasVm, erro
 		ip = v
 	}
 
-	return &IaasVm{
+	return l.waitForVmIPs(&IaasVm{
 		ID:        name,
 		Name:      name,
 		IPs:       condIP(ip),
@@ -513,22 +570,61 @@ func (l *Local) CreateVM(name string, vcpu, ramMB, storageGB int) (*IaasVm, erro
 		Cloud:     l.Name,
 		CloudType: LocalIaas,
 		McmCreate: true,
-	}, nil
+	})
+}
+
+// A new VM has no IP until DHCP gives it one. Like Proxmox, we wait for the IP, because the VM cannot be added to Kubernetes without it.
+var (
+	localVmIpRetryTimes    int           = 10
+	localVmIpRetryInterval time.Duration = 30 * time.Second
+)
+
+func (l *Local) waitForVmIPs(vm *IaasVm) (*IaasVm, error) {
+	for i := 0; len(vm.IPs) == 0; i++ {
+		if i >= localVmIpRetryTimes {
+			return nil, fmt.Errorf("Cloud name [%s], type [%s], CreateVM [%s], no IPs are got after %d tries", l.Name, LocalIaas, vm.Name, localVmIpRetryTimes)
+		}
+		beego.Info(fmt.Sprintf("VM [%s] has no IP yet, we wait for %v and try again.", vm.Name, localVmIpRetryInterval))
+		time.Sleep(localVmIpRetryInterval)
+		got, err := l.GetVM(vm.Name)
+		if err != nil {
+			beego.Warn(fmt.Sprintf("Get the IPs of VM [%s], error: %s", vm.Name, err.Error()))
+			continue
+		}
+		vm.IPs = got.IPs
+	}
+	beego.Info(fmt.Sprintf("found IPs [%v] of VM [%s]", vm.IPs, vm.Name))
+	return vm, nil
 }
 
 // DeleteVM: destroy + undefine --remove-all-storage
 func (l *Local) DeleteVM(vmID string) error {
+	if l.useLibvirt() {
+		err := l.libvirtDeleteVM(vmID)
+		if !l.fallBackToVirsh("delete VM", err) {
+			return err
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 	_, _ = virshCmd(ctx, l.LibvirtURIOrDefault(), "destroy", vmID) // ignore nếu không chạy
 	if _, err := virshCmd(ctx, l.LibvirtURIOrDefault(), "undefine", vmID, "--remove-all-storage"); err != nil {
 		return fmt.Errorf("delete VM %s failed: %v", vmID, err)
 	}
+	l.virshRemoveSeedVolume(vmID)
 	return nil
 }
 
 // CheckResources: tổng hợp limit/in-use (CPU, RAM, Storage, VM)
 func (l *Local) CheckResources() (ResourceStatus, error) {
+	if l.useLibvirt() {
+		rs, err := l.libvirtCheckResources()
+		if !l.fallBackToVirsh("check resources", err) {
+			return rs, err
+		}
+	}
+
 	rs := ResourceStatus{Limit: ResSet{}, InUse: ResSet{}}
 
 	// 1) Limit host
@@ -736,6 +832,86 @@ func (l *Local) makeDiskFor(name string, sizeGB int, backingImg string) (string,
 	return dst, nil
 }
 
+// makeSeedISO: tạo NoCloud seed ISO (nhãn "cidata") chứa user-data và meta-data trong một file tạm.
+// The caller uploads it as a volume of the storage pool, which works also when libvirtd is on another host, and then removes the file.
+func (l *Local) makeSeedISO(name, userData string) (string, error) {
+	workDir, err := os.MkdirTemp("", "mcm-seed-"+name)
+	if err != nil {
+		return "", fmt.Errorf("create temp dir for seed ISO of %s failed: %w", name, err)
+	}
+	defer os.RemoveAll(workDir)
+
+	userDataPath := filepath.Join(workDir, "user-data")
+	metaDataPath := filepath.Join(workDir, "meta-data")
+	if err := os.WriteFile(userDataPath, []byte(userData), 0600); err != nil {
+		return "", fmt.Errorf("write user-data of %s failed: %w", name, err)
+	}
+	if err := os.WriteFile(metaDataPath, []byte(noCloudMetaData(name)), 0600); err != nil {
+		return "", fmt.Errorf("write meta-data of %s failed: %w", name, err)
+	}
+
+	isoFile, err := os.CreateTemp("", fmt.Sprintf("mcm-%s-seed-*.iso", name))
+	if err != nil {
+		return "", fmt.Errorf("create temp file for seed ISO of %s failed: %w", name, err)
+	}
+	isoFile.Close()
+	dst := isoFile.Name()
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	// ưu tiên cloud-localds (cloud-image-utils), nếu không có thì dùng genisoimage
+	if _, err := exec.LookPath("cloud-localds"); err == nil {
+		if _, err := runCmd(ctx, "cloud-localds", dst, userDataPath, metaDataPath); err != nil {
+			os.Remove(dst)
+			return "", err
+		}
+		return dst, nil
+	}
+	if _, err := runCmd(ctx, "genisoimage", "-output", dst, "-volid", "cidata", "-joliet", "-rock", userDataPath, metaDataPath); err != nil {
+		os.Remove(dst)
+		return "", err
+	}
+	return dst, nil
+}
+
+// the name of the volume of the seed ISO of a VM in the storage pool
+func localSeedVolume(name string) string {
+	return fmt.Sprintf("%s-seed.iso", name)
+}
+
+// virshUploadSeedISO: upload seed ISO thành một volume "raw" trong storage pool, trả về "pool/volume" cho virt-install
+func (l *Local) virshUploadSeedISO(name, isoPath string) (string, error) {
+	info, err := os.Stat(isoPath)
+	if err != nil {
+		return "", fmt.Errorf("stat seed ISO of %s failed: %w", name, err)
+	}
+	pool, vol := l.storagePoolOrDefault(), localSeedVolume(name)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	if _, err := virshCmd(ctx, l.LibvirtURIOrDefault(), "vol-create-as", pool, vol, fmt.Sprintf("%db", info.Size()), "--format", "raw"); err != nil {
+		return "", fmt.Errorf("create seed volume %s/%s failed: %w", pool, vol, err)
+	}
+	if _, err := virshCmd(ctx, l.LibvirtURIOrDefault(), "vol-upload", "--pool", pool, vol, isoPath); err != nil {
+		l.virshRemoveSeedVolume(name)
+		return "", fmt.Errorf("upload seed ISO to volume %s/%s failed: %w", pool, vol, err)
+	}
+	return pool + "/" + vol, nil
+}
+
+// virshRemoveSeedVolume: xoá volume seed ISO của VM nếu có (best-effort, most VMs do not have it)
+func (l *Local) virshRemoveSeedVolume(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	pool, vol := l.storagePoolOrDefault(), localSeedVolume(name)
+	if _, err := virshCmd(ctx, l.LibvirtURIOrDefault(), "vol-delete", "--pool", pool, vol); err != nil {
+		beego.Info(fmt.Sprintf("Remove seed volume %s/%s of VM %s: %v", pool, vol, name, err))
+	}
+}
+
+// noCloudMetaData: meta-data của NoCloud, instance-id khác nhau thì cloud-init mới chạy lại
+func noCloudMetaData(name string) string {
+	return fmt.Sprintf("instance-id: %s\nlocal-hostname: %s\n", name, name)
+}
+
 func (l *Local) getMAC(ctx context.Context, name string) (string, string, error) {
 	out, err := virshCmd(ctx, l.LibvirtURIOrDefault(), "domiflist", name)
 	if err != nil {
@@ -810,6 +986,14 @@ func InitLocal(params map[string]interface{}) *Local {
 	if poolDir == "" {
 		poolDir = "/var/lib/libvirt/images"
 	}
+	backend := strings.ToLower(strings.TrimSpace(getStr(params, "backend")))
+	if backend == "" {
+		backend = LocalBackendVirsh
+	}
+	storagePool := strings.TrimSpace(getStr(params, "storage_pool", "storagePool"))
+	if storagePool == "" {
+		storagePool = DefaultLocalStoragePool
+	}
 	return &Local{
 		Name:               getStr(params, "name"),
 		IP:                 getStr(params, "ip"),
@@ -825,6 +1009,8 @@ func InitLocal(params map[string]interface{}) *Local {
 		FixedMAC:           strings.ToLower(getStr(params, "fixed_mac", "fixedMAC")),
 		DHCPStatic:         strings.EqualFold(getStr(params, "dhcp_static", "dhcpStatic"), "true"),
 		LibvirtURI:         strings.TrimSpace(getStr(params, "libvirt_uri", "libvirtURI")),
+		Backend:            backend,
+		StoragePool:        storagePool,
 
 		Latitude:  getStr(params, "latitude", "lat"),
 		Longitude: getStr(params, "longitude", "lon", "longitude"),
@@ -0,0 +1,719 @@
+package models
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/astaxie/beego"
+	libvirt "github.com/digitalocean/go-libvirt"
+)
+
+// ===================== Local driver (libvirt RPC) =====================
+
+/**
+NOTE:
+
+The "libvirt" backend of the Local driver talks to libvirtd over its RPC socket (the same as virsh does inside), so the data is structured and does not depend on the version or the locale of virsh.
+The disks of VMs are volumes in the libvirt storage pool StoragePool, rather than qcow2 files created by qemu-img under PoolDir.
+If libvirtd cannot be connected, the Local driver falls back to the "virsh" backend.
+*/
+
+const (
+	LocalBackendVirsh   string = "virsh"
+	LocalBackendLibvirt string = "libvirt"
+
+	DefaultLocalStoragePool string = "default"
+)
+
+// returned when libvirtd cannot be connected, with which the caller knows that it can fall back to virsh
+var errLibvirtUnavailable = errors.New("libvirt RPC unavailable")
+
+func (l *Local) useLibvirt() bool {
+	return l.Backend == LocalBackendLibvirt
+}
+
+// whether the libvirt backend fails because libvirtd cannot be connected, and the virsh backend should be tried.
+func (l *Local) fallBackToVirsh(op string, err error) bool {
+	if !errors.Is(err, errLibvirtUnavailable) {
+		return false
+	}
+	beego.Warn(fmt.Sprintf("Local cloud [%s] %s with libvirt RPC, error: %s. Fall back to virsh.", l.Name, op, err.Error()))
+	return true
+}
+
+func (l *Local) storagePoolOrDefault() string {
+	if strings.TrimSpace(l.StoragePool) != "" {
+		return l.StoragePool
+	}
+	return DefaultLocalStoragePool
+}
+
+// connect to libvirtd by the libvirt URI of this cloud. The caller should disconnect it.
+func (l *Local) libvirtConnect() (*libvirt.Libvirt, error) {
+	return libvirtConnectURI(l.LibvirtURIOrDefault())
+}
+
+// connect to libvirtd by a libvirt URI. The caller should disconnect it.
+func libvirtConnectURI(uriStr string) (*libvirt.Libvirt, error) {
+	uri, err := url.Parse(uriStr)
+	if err != nil {
+		return nil, fmt.Errorf("parse libvirt URI [%s], error: %w", uriStr, err)
+	}
+	conn, err := libvirt.ConnectToURI(uri)
+	if err != nil {
+		return nil, fmt.Errorf("%w: connect to [%s], error: %s", errLibvirtUnavailable, uri.String(), err.Error())
+	}
+	return conn, nil
+}
+
+// Like the virsh backend, which lists qemu:///system and qemu:///session, the VMs are listed from the libvirt URI of this cloud and, if it is a system URI, also from its session URI.
+func (l *Local) libvirtListURIs() []string {
+	uriStr := l.LibvirtURIOrDefault()
+	uris := []string{uriStr}
+	if uri, err := url.Parse(uriStr); err == nil && uri.Path == "/system" {
+		uri.Path = "/session"
+		uris = append(uris, uri.String())
+	}
+	return uris
+}
+
+func libvirtDisconnect(conn *libvirt.Libvirt) {
+	if err := conn.Disconnect(); err != nil {
+		beego.Warn(fmt.Sprintf("Disconnect from libvirt, error: %s", err.Error()))
+	}
+}
+
+// ===================== Domain and volume XML =====================
+
+// the parts of the libvirt domain XML used by multi-cloud manager
+type libvirtDomainXML struct {
+	XMLName xml.Name `xml:"domain"`
+	Type    string   `xml:"type,attr"`
+	Name    string   `xml:"name"`
+	Memory  struct {
+		Unit  string `xml:"unit,attr"`
+		Value uint64 `xml:",chardata"`
+	} `xml:"memory"`
+	VCpu int `xml:"vcpu"`
+	OS   struct {
+		Type struct {
+			Arch  string `xml:"arch,attr,omitempty"`
+			Value string `xml:",chardata"`
+		} `xml:"type"`
+		Boot struct {
+			Dev string `xml:"dev,attr"`
+		} `xml:"boot"`
+	} `xml:"os"`
+	Devices struct {
+		Disks      []libvirtDiskXML      `xml:"disk"`
+		Interfaces []libvirtInterfaceXML `xml:"interface"`
+		Serials    []libvirtSerialXML    `xml:"serial"`
+		Consoles   []libvirtSerialXML    `xml:"console"`
+	} `xml:"devices"`
+}
+
+type libvirtDiskXML struct {
+	Type   string `xml:"type,attr"`   // file or volume
+	Device string `xml:"device,attr"` // disk or cdrom
+	Driver *struct {
+		Name string `xml:"name,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"driver"`
+	Source struct {
+		File   string `xml:"file,attr,omitempty"`
+		Pool   string `xml:"pool,attr,omitempty"`
+		Volume string `xml:"volume,attr,omitempty"`
+	} `xml:"source"`
+	Target struct {
+		Dev string `xml:"dev,attr"`
+		Bus string `xml:"bus,attr,omitempty"`
+	} `xml:"target"`
+}
+
+type libvirtInterfaceXML struct {
+	Type string `xml:"type,attr"`
+	MAC  *struct {
+		Address string `xml:"address,attr"`
+	} `xml:"mac"`
+	Source struct {
+		Network string `xml:"network,attr,omitempty"`
+	} `xml:"source"`
+	Model *struct {
+		Type string `xml:"type,attr"`
+	} `xml:"model"`
+}
+
+type libvirtSerialXML struct {
+	Type   string `xml:"type,attr"`
+	Target *struct {
+		Type string `xml:"type,attr,omitempty"`
+		Port int    `xml:"port,attr"`
+	} `xml:"target"`
+}
+
+// the first disk that is not a cdrom, which is the system disk of a VM created by multi-cloud manager
+func (d libvirtDomainXML) systemDisk() (libvirtDiskXML, bool) {
+	for _, disk := range d.Devices.Disks {
+		if disk.Device != "disk" {
+			continue
+		}
+		if disk.Type == "file" && (disk.Source.File == "" || strings.HasSuffix(strings.ToLower(disk.Source.File), ".iso")) {
+			continue
+		}
+		return disk, true
+	}
+	return libvirtDiskXML{}, false
+}
+
+// the network name and the MAC address of the first interface on a libvirt network
+func (d libvirtDomainXML) networkInterface() (string, string, bool) {
+	for _, iface := range d.Devices.Interfaces {
+		if iface.Type == "network" && iface.MAC != nil {
+			return iface.Source.Network, strings.ToLower(iface.MAC.Address), true
+		}
+	}
+	return "", "", false
+}
+
+func parseLibvirtDomainXML(content string) (libvirtDomainXML, error) {
+	var d libvirtDomainXML
+	if err := xml.Unmarshal([]byte(content), &d); err != nil {
+		return libvirtDomainXML{}, fmt.Errorf("unmarshal libvirt domain XML, error: %w", err)
+	}
+	return d, nil
+}
+
+// the XML of a KVM domain booting from a volume in a storage pool, with a serial console instead of graphics.
+// If seedVolume is set, this volume in the same pool is attached as a cdrom for cloud-init.
+func localDomainXML(name string, vcpu, ramMB int, pool, volume, network, mac, seedVolume string) (string, error) {
+	var d libvirtDomainXML
+	d.Type = "kvm"
+	d.Name = name
+	d.Memory.Unit = "MiB"
+	d.Memory.Value = uint64(ramMB)
+	d.VCpu = vcpu
+	d.OS.Type.Arch = "x86_64"
+	d.OS.Type.Value = "hvm"
+	d.OS.Boot.Dev = "hd"
+
+	disk := libvirtDiskXML{Type: "volume", Device: "disk"}
+	disk.Driver = &struct {
+		Name string `xml:"name,attr"`
+		Type string `xml:"type,attr"`
+	}{Name: "qemu", Type: "qcow2"}
+	disk.Source.Pool = pool
+	disk.Source.Volume = volume
+	disk.Target.Dev = "vda"
+	disk.Target.Bus = "virtio"
+	d.Devices.Disks = []libvirtDiskXML{disk}
+	if seedVolume != "" {
+		cdrom := libvirtDiskXML{Type: "volume", Device: "cdrom"}
+		cdrom.Driver = &struct {
+			Name string `xml:"name,attr"`
+			Type string `xml:"type,attr"`
+		}{Name: "qemu", Type: "raw"}
+		cdrom.Source.Pool = pool
+		cdrom.Source.Volume = seedVolume
+		cdrom.Target.Dev = "sda"
+		cdrom.Target.Bus = "sata"
+		d.Devices.Disks = append(d.Devices.Disks, cdrom)
+	}
+
+	iface := libvirtInterfaceXML{Type: "network"}
+	iface.Source.Network = network
+	iface.Model = &struct {
+		Type string `xml:"type,attr"`
+	}{Type: "virtio"}
+	if mac != "" {
+		iface.MAC = &struct {
+			Address string `xml:"address,attr"`
+		}{Address: mac}
+	}
+	d.Devices.Interfaces = []libvirtInterfaceXML{iface}
+
+	d.Devices.Serials = []libvirtSerialXML{{Type: "pty"}}
+	d.Devices.Consoles = []libvirtSerialXML{{Type: "pty", Target: &struct {
+		Type string `xml:"type,attr,omitempty"`
+		Port int    `xml:"port,attr"`
+	}{Type: "serial", Port: 0}}}
+
+	content, err := xml.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshal libvirt domain XML of [%s], error: %w", name, err)
+	}
+	return string(content), nil
+}
+
+type libvirtVolumeXML struct {
+	XMLName  xml.Name `xml:"volume"`
+	Name     string   `xml:"name"`
+	Capacity struct {
+		Unit  string `xml:"unit,attr"`
+		Value uint64 `xml:",chardata"`
+	} `xml:"capacity"`
+	Target struct {
+		Format struct {
+			Type string `xml:"type,attr"`
+		} `xml:"format"`
+	} `xml:"target"`
+	BackingStore *struct {
+		Path   string `xml:"path"`
+		Format struct {
+			Type string `xml:"type,attr"`
+		} `xml:"format"`
+	} `xml:"backingStore"`
+}
+
+// the XML of a qcow2 volume, which uses backingImg as the backing file if it is set.
+func localVolumeXML(name string, sizeGB int, backingImg string) (string, error) {
+	var v libvirtVolumeXML
+	v.Name = name
+	v.Capacity.Unit = "GiB"
+	v.Capacity.Value = uint64(sizeGB)
+	v.Target.Format.Type = "qcow2"
+	if strings.TrimSpace(backingImg) != "" {
+		v.BackingStore = &struct {
+			Path   string `xml:"path"`
+			Format struct {
+				Type string `xml:"type,attr"`
+			} `xml:"format"`
+		}{Path: backingImg}
+		v.BackingStore.Format.Type = "qcow2"
+	}
+	content, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshal libvirt volume XML of [%s], error: %w", name, err)
+	}
+	return string(content), nil
+}
+
+// the XML of a raw volume of sizeBytes, to which a file such as the seed ISO is uploaded
+func localRawVolumeXML(name string, sizeBytes uint64) (string, error) {
+	var v libvirtVolumeXML
+	v.Name = name
+	v.Capacity.Unit = "bytes"
+	v.Capacity.Value = sizeBytes
+	v.Target.Format.Type = "raw"
+	content, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshal libvirt volume XML of [%s], error: %w", name, err)
+	}
+	return string(content), nil
+}
+
+// Upload the seed ISO as a raw volume in the storage pool. Unlike a file under PoolDir, the volume also works when libvirtd is on another host.
+func libvirtUploadSeedISO(conn *libvirt.Libvirt, pool libvirt.StoragePool, volName, isoPath string) (libvirt.StorageVol, error) {
+	isoFile, err := os.Open(isoPath)
+	if err != nil {
+		return libvirt.StorageVol{}, fmt.Errorf("open seed ISO [%s], error: %w", isoPath, err)
+	}
+	defer isoFile.Close()
+	info, err := isoFile.Stat()
+	if err != nil {
+		return libvirt.StorageVol{}, fmt.Errorf("stat seed ISO [%s], error: %w", isoPath, err)
+	}
+
+	volXML, err := localRawVolumeXML(volName, uint64(info.Size()))
+	if err != nil {
+		return libvirt.StorageVol{}, err
+	}
+	vol, err := conn.StorageVolCreateXML(pool, volXML, 0)
+	if err != nil {
+		return libvirt.StorageVol{}, fmt.Errorf("create volume [%s], error: %w", volName, err)
+	}
+	if err := conn.StorageVolUpload(vol, isoFile, 0, uint64(info.Size()), 0); err != nil {
+		if delErr := conn.StorageVolDelete(vol, libvirt.StorageVolDeleteNormal); delErr != nil {
+			beego.Warn(fmt.Sprintf("Delete volume [%s] after failing to upload the seed ISO, error: %s", volName, delErr.Error()))
+		}
+		return libvirt.StorageVol{}, fmt.Errorf("upload seed ISO [%s] to volume [%s], error: %w", isoPath, volName, err)
+	}
+	return vol, nil
+}
+
+func libvirtDomainStatus(state int32) string {
+	switch libvirt.DomainState(state) {
+	case libvirt.DomainRunning, libvirt.DomainBlocked:
+		return "Running"
+	case libvirt.DomainShutoff, libvirt.DomainShutdown, libvirt.DomainCrashed:
+		return "Shut Off"
+	case libvirt.DomainPaused, libvirt.DomainPmsuspended:
+		return "Paused"
+	default:
+		return "Pending"
+	}
+}
+
+// ===================== Operations =====================
+
+// the volume of a disk, found by the pool and volume in the XML, or by the path of the file.
+func libvirtDiskVolume(conn *libvirt.Libvirt, disk libvirtDiskXML) (libvirt.StorageVol, error) {
+	if disk.Type == "volume" {
+		pool, err := conn.StoragePoolLookupByName(disk.Source.Pool)
+		if err != nil {
+			return libvirt.StorageVol{}, fmt.Errorf("look up storage pool [%s], error: %w", disk.Source.Pool, err)
+		}
+		return conn.StorageVolLookupByName(pool, disk.Source.Volume)
+	}
+	return conn.StorageVolLookupByPath(disk.Source.File)
+}
+
+// the capacity of the system disk in bytes. 0 if it cannot be got.
+func libvirtDiskCapacity(conn *libvirt.Libvirt, dom libvirt.Domain, d libvirtDomainXML) uint64 {
+	disk, found := d.systemDisk()
+	if !found {
+		return 0
+	}
+	if vol, err := libvirtDiskVolume(conn, disk); err == nil {
+		if _, capacity, _, err := conn.StorageVolGetInfo(vol); err == nil && capacity > 0 {
+			return capacity
+		}
+	}
+	// the disk is not in any storage pool, so we ask the domain.
+	if _, capacity, _, err := conn.DomainGetBlockInfo(dom, disk.Target.Dev, 0); err == nil {
+		return capacity
+	}
+	return 0
+}
+
+// the IPs reported by the guest agent, or the DHCP leases of libvirt if the agent is not running.
+func libvirtDomainIPs(conn *libvirt.Libvirt, dom libvirt.Domain) []string {
+	var ips []string
+	for _, source := range []libvirt.DomainInterfaceAddressesSource{libvirt.DomainInterfaceAddressesSrcAgent, libvirt.DomainInterfaceAddressesSrcLease} {
+		ifaces, err := conn.DomainInterfaceAddresses(dom, uint32(source), 0)
+		if err != nil {
+			continue
+		}
+		seen := map[string]bool{}
+		for _, iface := range ifaces {
+			if iface.Name == "lo" {
+				continue
+			}
+			for _, addr := range iface.Addrs {
+				if !seen[addr.Addr] {
+					seen[addr.Addr] = true
+					ips = append(ips, addr.Addr)
+				}
+			}
+		}
+		if len(ips) > 0 {
+			break
+		}
+	}
+	return ips
+}
+
+func (l *Local) libvirtGetVMOnConn(conn *libvirt.Libvirt, name string) (*IaasVm, error) {
+	dom, err := conn.DomainLookupByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("look up domain [%s], error: %w", name, err)
+	}
+	state, _, err := conn.DomainGetState(dom, 0)
+	if err != nil {
+		return nil, fmt.Errorf("get state of domain [%s], error: %w", name, err)
+	}
+	_, maxMemKiB, memKiB, vcpu, _, err := conn.DomainGetInfo(dom)
+	if err != nil {
+		return nil, fmt.Errorf("get info of domain [%s], error: %w", name, err)
+	}
+	ramKiB := maxMemKiB
+	if memKiB > 0 {
+		ramKiB = memKiB
+	}
+	xmlDesc, err := conn.DomainGetXMLDesc(dom, 0)
+	if err != nil {
+		return nil, fmt.Errorf("get XML of domain [%s], error: %w", name, err)
+	}
+	d, err := parseLibvirtDomainXML(xmlDesc)
+	if err != nil {
+		return nil, err
+	}
+	capacity := libvirtDiskCapacity(conn, dom, d)
+
+	ips := libvirtDomainIPs(conn, dom)
+	if len(ips) == 0 {
+		if ip, err := libvirtLeaseIP(conn, d); err == nil {
+			ips = []string{ip}
+		}
+	}
+
+	return &IaasVm{
+		ID:        name,
+		Name:      name,
+		IPs:       condIPs(ips),
+		VCpu:      float64(vcpu),
+		Ram:       float64(ramKiB) / 1024.0,                  // KiB → MB
+		Storage:   float64((capacity + (1 << 30) - 1) >> 30), // bytes → GB (ceil)
+		Status:    libvirtDomainStatus(state),
+		Cloud:     l.Name,
+		CloudType: LocalIaas,
+		McmCreate: true,
+	}, nil
+}
+
+// the IPv4 in the DHCP leases of the libvirt network for the MAC of the domain
+func libvirtLeaseIP(conn *libvirt.Libvirt, d libvirtDomainXML) (string, error) {
+	netName, mac, found := d.networkInterface()
+	if !found {
+		return "", fmt.Errorf("cannot find NIC for %s", d.Name)
+	}
+	network, err := conn.NetworkLookupByName(netName)
+	if err != nil {
+		return "", fmt.Errorf("look up network [%s], error: %w", netName, err)
+	}
+	leases, _, err := conn.NetworkGetDhcpLeases(network, libvirt.OptString{mac}, 1, 0)
+	if err != nil {
+		return "", fmt.Errorf("get DHCP leases of network [%s], error: %w", netName, err)
+	}
+	for _, lease := range leases {
+		if lease.Type == int32(libvirt.IPAddrTypeIpv4) {
+			return lease.Ipaddr, nil
+		}
+	}
+	return "", fmt.Errorf("no DHCP lease for %s", d.Name)
+}
+
+func condIPs(ips []string) []string {
+	if ips == nil {
+		return []string{}
+	}
+	return ips
+}
+
+func (l *Local) libvirtGetVM(name string) (*IaasVm, error) {
+	conn, err := l.libvirtConnect()
+	if err != nil {
+		return nil, err
+	}
+	defer libvirtDisconnect(conn)
+	return l.libvirtGetVMOnConn(conn, name)
+}
+
+func (l *Local) libvirtListAllVMs() ([]IaasVm, error) {
+	vms := []IaasVm{}
+	seen := map[string]bool{}
+	var listErrs []error
+	uris := l.libvirtListURIs()
+	for _, uri := range uris {
+		uriVms, err := l.libvirtListVMsOnURI(uri)
+		if err != nil {
+			beego.Warn(fmt.Sprintf("Local cloud [%s] list VMs on [%s], error: %s", l.Name, uri, err.Error()))
+			listErrs = append(listErrs, err)
+			continue
+		}
+		// a VM in more than one URI is the one in the first URI
+		for _, vm := range uriVms {
+			if seen[vm.Name] {
+				continue
+			}
+			seen[vm.Name] = true
+			vms = append(vms, vm)
+		}
+	}
+	// The first error is returned only if no URI can be listed. It is errLibvirtUnavailable if libvirtd cannot be connected, so that the caller can fall back to virsh.
+	if len(listErrs) == len(uris) {
+		return nil, listErrs[0]
+	}
+	return vms, nil
+}
+
+func (l *Local) libvirtListVMsOnURI(uri string) ([]IaasVm, error) {
+	conn, err := libvirtConnectURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer libvirtDisconnect(conn)
+
+	doms, _, err := conn.ConnectListAllDomains(1, libvirt.ConnectListDomainsActive|libvirt.ConnectListDomainsInactive)
+	if err != nil {
+		return nil, fmt.Errorf("list all domains, error: %w", err)
+	}
+	vms := []IaasVm{}
+	for _, dom := range doms {
+		vm, err := l.libvirtGetVMOnConn(conn, dom.Name)
+		if err != nil {
+			beego.Warn(fmt.Sprintf("Skip VM %s: %v", dom.Name, err))
+			continue
+		}
+		vms = append(vms, *vm)
+	}
+	return vms, nil
+}
+
+// Create a volume in the storage pool, define a domain booting from it, and start the domain.
+func (l *Local) libvirtCreateVM(name string, vcpu, ramMB, storageGB int, seedISO string) (*IaasVm, error) {
+	conn, err := l.libvirtConnect()
+	if err != nil {
+		return nil, err
+	}
+	defer libvirtDisconnect(conn)
+
+	poolName := l.storagePoolOrDefault()
+	pool, err := conn.StoragePoolLookupByName(poolName)
+	if err != nil {
+		return nil, fmt.Errorf("look up storage pool [%s], error: %w", poolName, err)
+	}
+	volName := fmt.Sprintf("%s.qcow2", name)
+	volXML, err := localVolumeXML(volName, storageGB, l.ImagePath)
+	if err != nil {
+		return nil, err
+	}
+	vol, err := conn.StorageVolCreateXML(pool, volXML, 0)
+	if err != nil {
+		return nil, fmt.Errorf("create volume [%s] in storage pool [%s], error: %w", volName, poolName, err)
+	}
+	// the volumes created are deleted if the VM cannot be created
+	createdVols := map[string]libvirt.StorageVol{volName: vol}
+	deleteCreatedVols := func() {
+		for createdName, createdVol := range createdVols {
+			if delErr := conn.StorageVolDelete(createdVol, libvirt.StorageVolDeleteNormal); delErr != nil {
+				beego.Warn(fmt.Sprintf("Delete volume [%s] after failing to create domain [%s], error: %s", createdName, name, delErr.Error()))
+			}
+		}
+	}
+
+	seedVolName := ""
+	if seedISO != "" {
+		seedVolName = localSeedVolume(name)
+		seedVol, err := libvirtUploadSeedISO(conn, pool, seedVolName, seedISO)
+		if err != nil {
+			deleteCreatedVols()
+			return nil, err
+		}
+		createdVols[seedVolName] = seedVol
+	}
+
+	domXML, err := localDomainXML(name, vcpu, ramMB, poolName, volName, l.Network, l.FixedMAC, seedVolName)
+	if err != nil {
+		deleteCreatedVols()
+		return nil, err
+	}
+	dom, err := conn.DomainDefineXML(domXML)
+	if err != nil {
+		deleteCreatedVols()
+		return nil, fmt.Errorf("define domain [%s], error: %w", name, err)
+	}
+	if err := conn.DomainCreate(dom); err != nil {
+		// the domain that cannot start is not left behind
+		if undefErr := conn.DomainUndefineFlags(dom, libvirt.DomainUndefineManagedSave|libvirt.DomainUndefineNvram); undefErr != nil {
+			beego.Warn(fmt.Sprintf("Undefine domain [%s] after failing to start it, error: %s", name, undefErr.Error()))
+		}
+		deleteCreatedVols()
+		return nil, fmt.Errorf("start domain [%s], error: %w", name, err)
+	}
+
+	// Lấy IP best-effort
+	ips := libvirtDomainIPs(conn, dom)
+	if len(ips) == 0 {
+		if xmlDesc, err := conn.DomainGetXMLDesc(dom, 0); err == nil {
+			if d, err := parseLibvirtDomainXML(xmlDesc); err == nil {
+				if ip, err := libvirtLeaseIP(conn, d); err == nil {
+					ips = []string{ip}
+				}
+			}
+		}
+	}
+
+	return &IaasVm{
+		ID:        name,
+		Name:      name,
+		IPs:       condIPs(ips),
+		VCpu:      float64(vcpu),
+		Ram:       float64(ramMB),
+		Storage:   float64(storageGB),
+		Status:    "Running",
+		Cloud:     l.Name,
+		CloudType: LocalIaas,
+		McmCreate: true,
+	}, nil
+}
+
+// Stop and undefine the domain, and delete the volumes of its disks.
+func (l *Local) libvirtDeleteVM(name string) error {
+	conn, err := l.libvirtConnect()
+	if err != nil {
+		return err
+	}
+	defer libvirtDisconnect(conn)
+
+	dom, err := conn.DomainLookupByName(name)
+	if err != nil {
+		return fmt.Errorf("look up domain [%s], error: %w", name, err)
+	}
+	xmlDesc, err := conn.DomainGetXMLDesc(dom, 0)
+	if err != nil {
+		return fmt.Errorf("get XML of domain [%s], error: %w", name, err)
+	}
+	d, err := parseLibvirtDomainXML(xmlDesc)
+	if err != nil {
+		return err
+	}
+
+	_ = conn.DomainDestroy(dom) // ignore nếu không chạy
+	if err := conn.DomainUndefineFlags(dom, libvirt.DomainUndefineManagedSave|libvirt.DomainUndefineNvram); err != nil {
+		return fmt.Errorf("undefine domain [%s], error: %w", name, err)
+	}
+
+	for _, disk := range d.Devices.Disks {
+		// the seed ISO of cloud-init is a cdrom volume, which is deleted with the VM
+		isSeed := disk.Device == "cdrom" && disk.Type == "volume" && disk.Source.Volume == localSeedVolume(name)
+		if disk.Device != "disk" && !isSeed {
+			continue
+		}
+		vol, err := libvirtDiskVolume(conn, disk)
+		if err != nil {
+			beego.Warn(fmt.Sprintf("The disk [%s] of domain [%s] is not a volume in any storage pool, so it is not deleted: %s", disk.Target.Dev, name, err.Error()))
+			continue
+		}
+		if err := conn.StorageVolDelete(vol, libvirt.StorageVolDeleteNormal); err != nil {
+			return fmt.Errorf("delete volume [%s] of domain [%s], error: %w", vol.Name, name, err)
+		}
+	}
+	return nil
+}
+
+// The limit is the CPU and memory of the host and the capacity of the storage pool. The in-use is the sum of all domains.
+func (l *Local) libvirtCheckResources() (ResourceStatus, error) {
+	conn, err := l.libvirtConnect()
+	if err != nil {
+		return ResourceStatus{}, err
+	}
+	defer libvirtDisconnect(conn)
+
+	rs := ResourceStatus{Limit: ResSet{}, InUse: ResSet{}}
+	if _, memKiB, cpus, _, _, _, _, _, err := conn.NodeGetInfo(); err == nil {
+		rs.Limit.VCpu = float64(cpus)
+		rs.Limit.Ram = float64(memKiB) / 1024.0 // KiB → MB
+	} else {
+		beego.Warn(fmt.Sprintf("Local cloud [%s] get node info, error: %s", l.Name, err.Error()))
+	}
+	poolName := l.storagePoolOrDefault()
+	if pool, err := conn.StoragePoolLookupByName(poolName); err == nil {
+		if _, capacity, _, _, err := conn.StoragePoolGetInfo(pool); err == nil {
+			rs.Limit.Storage = float64(capacity) / (1 << 30)
+		}
+	} else {
+		beego.Warn(fmt.Sprintf("Local cloud [%s] look up storage pool [%s], error: %s", l.Name, poolName, err.Error()))
+	}
+
+	doms, _, err := conn.ConnectListAllDomains(1, libvirt.ConnectListDomainsActive|libvirt.ConnectListDomainsInactive)
+	if err != nil {
+		return rs, fmt.Errorf("list all domains, error: %w", err)
+	}
+	for _, dom := range doms {
+		if _, maxMemKiB, _, vcpu, _, err := conn.DomainGetInfo(dom); err == nil {
+			rs.InUse.VCpu += float64(vcpu)
+			rs.InUse.Ram += float64(maxMemKiB) / 1024.0 // KiB → MB
+		}
+		if xmlDesc, err := conn.DomainGetXMLDesc(dom, 0); err == nil {
+			if d, err := parseLibvirtDomainXML(xmlDesc); err == nil {
+				rs.InUse.Storage += float64(libvirtDiskCapacity(conn, dom, d)) / (1 << 30)
+			}
+		}
+		rs.InUse.Vm++
+	}
+	return rs, nil
+}
@@ -0,0 +1,196 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLibvirtDomainXML(t *testing.T) {
+	testCases := []struct {
+		name            string
+		content         string
+		expectedDisk    libvirtDiskXML
+		expectedHasDisk bool
+		expectedNetwork string
+		expectedMAC     string
+		expectedHasNIC  bool
+	}{
+		{
+			name: "volume disk and network interface",
+			content: `<domain type='kvm'>
+  <name>vm1</name>
+  <memory unit='KiB'>2097152</memory>
+  <vcpu placement='static'>2</vcpu>
+  <devices>
+    <disk type='file' device='cdrom'>
+      <source file='/var/lib/libvirt/images/seed.iso'/>
+      <target dev='sda' bus='sata'/>
+    </disk>
+    <disk type='volume' device='disk'>
+      <driver name='qemu' type='qcow2'/>
+      <source pool='default' volume='vm1.qcow2'/>
+      <target dev='vda' bus='virtio'/>
+    </disk>
+    <interface type='network'>
+      <mac address='52:54:00:AB:CD:EF'/>
+      <source network='default'/>
+      <model type='virtio'/>
+    </interface>
+  </devices>
+</domain>`,
+			expectedDisk: func() libvirtDiskXML {
+				disk := libvirtDiskXML{Type: "volume", Device: "disk"}
+				disk.Source.Pool, disk.Source.Volume = "default", "vm1.qcow2"
+				disk.Target.Dev, disk.Target.Bus = "vda", "virtio"
+				return disk
+			}(),
+			expectedHasDisk: true,
+			expectedNetwork: "default",
+			expectedMAC:     "52:54:00:ab:cd:ef",
+			expectedHasNIC:  true,
+		},
+		{
+			name: "file disk created by virt-install",
+			content: `<domain type='kvm'>
+  <name>vm2</name>
+  <devices>
+    <disk type='file' device='disk'>
+      <source file='/var/lib/libvirt/images/vm2.qcow2'/>
+      <target dev='vda' bus='virtio'/>
+    </disk>
+    <interface type='bridge'>
+      <mac address='52:54:00:00:00:01'/>
+    </interface>
+  </devices>
+</domain>`,
+			expectedDisk: func() libvirtDiskXML {
+				disk := libvirtDiskXML{Type: "file", Device: "disk"}
+				disk.Source.File = "/var/lib/libvirt/images/vm2.qcow2"
+				disk.Target.Dev, disk.Target.Bus = "vda", "virtio"
+				return disk
+			}(),
+			expectedHasDisk: true,
+			expectedHasNIC:  false,
+		},
+		{
+			name: "no disk",
+			content: `<domain type='kvm'>
+  <name>vm3</name>
+  <devices/>
+</domain>`,
+			expectedHasDisk: false,
+			expectedHasNIC:  false,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		d, err := parseLibvirtDomainXML(testCase.content)
+		assert.Nil(t, err, fmt.Sprintf("%s: parse error", testCase.name))
+
+		disk, hasDisk := d.systemDisk()
+		assert.Equal(t, testCase.expectedHasDisk, hasDisk, fmt.Sprintf("%s: whether there is a system disk is not expected", testCase.name))
+		if hasDisk {
+			disk.Driver = nil
+			assert.Equal(t, testCase.expectedDisk, disk, fmt.Sprintf("%s: system disk is not expected", testCase.name))
+		}
+
+		network, mac, hasNIC := d.networkInterface()
+		assert.Equal(t, testCase.expectedHasNIC, hasNIC, fmt.Sprintf("%s: whether there is a NIC is not expected", testCase.name))
+		assert.Equal(t, testCase.expectedNetwork, network, fmt.Sprintf("%s: network is not expected", testCase.name))
+		assert.Equal(t, testCase.expectedMAC, mac, fmt.Sprintf("%s: MAC is not expected", testCase.name))
+	}
+
+	_, err := parseLibvirtDomainXML("not xml")
+	assert.NotNil(t, err)
+}
+
+func TestLocalDomainXML(t *testing.T) {
+	content, err := localDomainXML("vm1", 2, 2048, "default", "vm1.qcow2", "default", "52:54:00:00:00:01", localSeedVolume("vm1"))
+	assert.Nil(t, err)
+
+	// the generated XML can be parsed back
+	d, err := parseLibvirtDomainXML(content)
+	assert.Nil(t, err)
+	assert.Equal(t, "kvm", d.Type)
+	assert.Equal(t, "vm1", d.Name)
+	assert.Equal(t, "MiB", d.Memory.Unit)
+	assert.Equal(t, uint64(2048), d.Memory.Value)
+	assert.Equal(t, 2, d.VCpu)
+	disk, hasDisk := d.systemDisk()
+	assert.True(t, hasDisk)
+	assert.Equal(t, "vm1.qcow2", disk.Source.Volume)
+	network, mac, hasNIC := d.networkInterface()
+	assert.True(t, hasNIC)
+	assert.Equal(t, "default", network)
+	assert.Equal(t, "52:54:00:00:00:01", mac)
+	assert.Len(t, d.Devices.Disks, 2)
+	assert.Equal(t, "cdrom", d.Devices.Disks[1].Device)
+	// the seed ISO is a volume in the same storage pool
+	assert.Equal(t, "volume", d.Devices.Disks[1].Type)
+	assert.Equal(t, "default", d.Devices.Disks[1].Source.Pool)
+	assert.Equal(t, "vm1-seed.iso", d.Devices.Disks[1].Source.Volume)
+
+	// without a fixed MAC, libvirt generates one
+	content, err = localDomainXML("vm2", 1, 512, "default", "vm2.qcow2", "default", "", "")
+	assert.Nil(t, err)
+	assert.False(t, strings.Contains(content, "<mac"))
+	assert.False(t, strings.Contains(content, "cdrom"))
+}
+
+func TestLocalVolumeXML(t *testing.T) {
+	testCases := []struct {
+		name            string
+		backingImg      string
+		expectedBacking bool
+	}{
+		{name: "with backing image", backingImg: "/var/lib/libvirt/images/base/jammy.qcow2", expectedBacking: true},
+		{name: "empty disk", backingImg: "  ", expectedBacking: false},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		content, err := localVolumeXML("vm1.qcow2", 20, testCase.backingImg)
+		assert.Nil(t, err, fmt.Sprintf("%s: marshal error", testCase.name))
+		assert.Contains(t, content, "<name>vm1.qcow2</name>")
+		assert.Contains(t, content, `<capacity unit="GiB">20</capacity>`)
+		assert.Contains(t, content, `<format type="qcow2"></format>`)
+		assert.Equal(t, testCase.expectedBacking, strings.Contains(content, "<path>"+testCase.backingImg+"</path>"), fmt.Sprintf("%s: backing store is not expected", testCase.name))
+	}
+}
+
+func TestLibvirtDomainStatus(t *testing.T) {
+	testCases := []struct {
+		state    int32
+		expected string
+	}{
+		{state: 1, expected: "Running"},
+		{state: 3, expected: "Paused"},
+		{state: 5, expected: "Shut Off"},
+		{state: 0, expected: "Pending"},
+	}
+	for i, testCase := range testCases {
+		t.Logf("test: %d, state %d", i, testCase.state)
+		assert.Equal(t, testCase.expected, libvirtDomainStatus(testCase.state))
+	}
+}
+
+func TestLibvirtListURIs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		uri      string
+		expected []string
+	}{
+		{name: "default", uri: "", expected: []string{"qemu:///system", "qemu:///session"}},
+		{name: "remote system", uri: "qemu+ssh://root@10.0.0.1/system", expected: []string{"qemu+ssh://root@10.0.0.1/system", "qemu+ssh://root@10.0.0.1/session"}},
+		{name: "session", uri: "qemu:///session", expected: []string{"qemu:///session"}},
+	}
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		l := &Local{LibvirtURI: testCase.uri}
+		assert.Equal(t, testCase.expected, l.libvirtListURIs(), testCase.name)
+	}
+}
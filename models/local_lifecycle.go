@@ -0,0 +1,179 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ===================== Local driver: lifecycle (virsh) =====================
+// Các thao tác vòng đời dùng virsh với cùng LibvirtURI cho cả hai backend.
+
+const localLifecycleTimeout = 2 * time.Minute
+
+func (l *Local) virsh(args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), localLifecycleTimeout)
+	defer cancel()
+	return virshCmd(ctx, l.LibvirtURIOrDefault(), args...)
+}
+
+func (l *Local) StartVM(vmID string) error {
+	if _, err := l.virsh("start", vmID); err != nil {
+		return fmt.Errorf("start VM %s failed: %w", vmID, err)
+	}
+	return nil
+}
+
+// StopVM: tắt ngay (virsh destroy)
+func (l *Local) StopVM(vmID string) error {
+	if _, err := l.virsh("destroy", vmID); err != nil {
+		return fmt.Errorf("stop VM %s failed: %w", vmID, err)
+	}
+	return nil
+}
+
+// ShutdownVM: tắt qua ACPI, guest OS tự tắt
+func (l *Local) ShutdownVM(vmID string) error {
+	if _, err := l.virsh("shutdown", vmID); err != nil {
+		return fmt.Errorf("shutdown VM %s failed: %w", vmID, err)
+	}
+	return nil
+}
+
+func (l *Local) RebootVM(vmID string) error {
+	if _, err := l.virsh("reboot", vmID); err != nil {
+		return fmt.Errorf("reboot VM %s failed: %w", vmID, err)
+	}
+	return nil
+}
+
+func (l *Local) domState(vmID string) (string, error) {
+	out, err := l.virsh("domstate", vmID)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// ResizeVM: live → setvcpus/setmem --live --config (không vượt quá maximum của domain);
+// offline → shutdown, đổi cả maximum, rồi start lại
+func (l *Local) ResizeVM(vmID string, vcpu, ramMB int, live bool) error {
+	ramKiB := fmt.Sprint(ramMB * 1024)
+	if live {
+		if _, err := l.virsh("setvcpus", vmID, fmt.Sprint(vcpu), "--live", "--config"); err != nil {
+			return fmt.Errorf("set vcpus of VM %s live failed: %w", vmID, err)
+		}
+		if _, err := l.virsh("setmem", vmID, ramKiB, "--live", "--config"); err != nil {
+			return fmt.Errorf("set memory of VM %s live failed: %w", vmID, err)
+		}
+		return nil
+	}
+
+	state, err := l.domState(vmID)
+	if err != nil {
+		return fmt.Errorf("get state of VM %s failed: %w", vmID, err)
+	}
+	wasRunning := state == "running"
+	if wasRunning {
+		if err := l.ShutdownVM(vmID); err != nil {
+			return err
+		}
+		if err := MyWaitFor(WaitForTimeOut, 5, func() (bool, error) {
+			s, err := l.domState(vmID)
+			return err == nil && s == "shut off", nil
+		}); err != nil {
+			return fmt.Errorf("wait for VM %s shut off failed: %w", vmID, err)
+		}
+	}
+
+	steps := [][]string{
+		{"setvcpus", vmID, fmt.Sprint(vcpu), "--config", "--maximum"},
+		{"setvcpus", vmID, fmt.Sprint(vcpu), "--config"},
+		{"setmaxmem", vmID, ramKiB, "--config"},
+		{"setmem", vmID, ramKiB, "--config"},
+	}
+	for _, args := range steps {
+		if _, err := l.virsh(args...); err != nil {
+			return fmt.Errorf("resize VM %s at [virsh %s] failed: %w", vmID, strings.Join(args, " "), err)
+		}
+	}
+
+	if wasRunning {
+		return l.StartVM(vmID)
+	}
+	return nil
+}
+
+// ResizeVMDisk: đang chạy → virsh blockresize; đã tắt → qemu-img resize. Chỉ hỗ trợ tăng dung lượng.
+func (l *Local) ResizeVMDisk(vmID string, storageGB int) error {
+	blk, err := l.virsh("domblklist", "--details", vmID)
+	if err != nil {
+		return fmt.Errorf("list disks of VM %s failed: %w", vmID, err)
+	}
+	_, src := resolveTargetFromDomBlkList(blk)
+	if src == "" {
+		return fmt.Errorf("cannot find the disk of VM %s", vmID)
+	}
+	if sizeGB, err := l.readDiskSizeGBByPath(src); err == nil && float64(storageGB) < sizeGB {
+		return fmt.Errorf("the disk of VM %s cannot shrink from %gG to %dG", vmID, sizeGB, storageGB)
+	}
+
+	state, err := l.domState(vmID)
+	if err != nil {
+		return fmt.Errorf("get state of VM %s failed: %w", vmID, err)
+	}
+	size := fmt.Sprintf("%dG", storageGB)
+	if state == "running" {
+		if _, err := l.virsh("blockresize", vmID, src, size); err != nil {
+			return fmt.Errorf("resize disk %s of VM %s failed: %w", src, vmID, err)
+		}
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), localLifecycleTimeout)
+	defer cancel()
+	if _, err := runCmd(ctx, "qemu-img", "resize", src, size); err != nil {
+		return fmt.Errorf("resize disk %s of VM %s failed: %w", src, vmID, err)
+	}
+	return nil
+}
+
+func (l *Local) CreateSnapshot(vmID, snapshotName string) error {
+	if _, err := l.virsh("snapshot-create-as", vmID, snapshotName); err != nil {
+		return fmt.Errorf("create snapshot %s of VM %s failed: %w", snapshotName, vmID, err)
+	}
+	return nil
+}
+
+func (l *Local) ListSnapshots(vmID string) ([]VmSnapshot, error) {
+	out, err := l.virsh("snapshot-list", vmID, "--name")
+	if err != nil {
+		return nil, fmt.Errorf("list snapshots of VM %s failed: %w", vmID, err)
+	}
+	return parseSnapshotNames(out), nil
+}
+
+// parseSnapshotNames: mỗi dòng của "virsh snapshot-list --name" là một tên
+func parseSnapshotNames(out string) []VmSnapshot {
+	snapshots := []VmSnapshot{}
+	for _, ln := range strings.Split(out, "\n") {
+		if name := strings.TrimSpace(ln); name != "" {
+			snapshots = append(snapshots, VmSnapshot{Name: name})
+		}
+	}
+	return snapshots
+}
+
+func (l *Local) RevertSnapshot(vmID, snapshotName string) error {
+	if _, err := l.virsh("snapshot-revert", vmID, snapshotName); err != nil {
+		return fmt.Errorf("revert VM %s to snapshot %s failed: %w", vmID, snapshotName, err)
+	}
+	return nil
+}
+
+func (l *Local) DeleteSnapshot(vmID, snapshotName string) error {
+	if _, err := l.virsh("snapshot-delete", vmID, snapshotName); err != nil {
+		return fmt.Errorf("delete snapshot %s of VM %s failed: %w", snapshotName, vmID, err)
+	}
+	return nil
+}
@@ -18,25 +18,35 @@ import (
 
 // used for the input of creating applications, so we need to define the json
 type K8sApp struct {
-	Name          string              `json:"name"`
-	Replicas      int32               `json:"replicas"`
-	HostNetwork   bool                `json:"hostNetwork"`
-	NodeName      string              `json:"nodeName,omitempty"`
-	NodeSelector  map[string]string   `json:"nodeSelector,omitempty"`
-	Tolerations   []corev1.Toleration `json:"tolerations,omitempty"`
-	Containers    []K8sContainer      `json:"containers"`
-	Priority      int                 `json:"priority"`
-	AutoScheduled bool                `json:"autoScheduled"`
-	Dependencies  []Dependency        `json:"dependencies,omitempty"` // The information of all applications that this application depends on, only useful for
+	Name             string              `json:"name"`
+	Namespace        string              `json:"namespace,omitempty"` // the namespace of the tenant of this application. Empty means KubernetesNamespace.
+	Replicas         int32               `json:"replicas"`
+	HostNetwork      bool                `json:"hostNetwork"`
+	NodeName         string              `json:"nodeName,omitempty"`
+	ReplicaNodeNames []string            `json:"replicaNodeNames,omitempty"` // one replica on each of these nodes. If it is set, its length should be equal to Replicas, and NodeName should not be set.
+	NodeSelector     map[string]string   `json:"nodeSelector,omitempty"`
+	Tolerations      []corev1.Toleration `json:"tolerations,omitempty"`
+	Containers       []K8sContainer      `json:"containers"`
+	Priority         int                 `json:"priority"`
+	AutoScheduled    bool                `json:"autoScheduled"`
+	ReplicaSpread    string              `json:"replicaSpread,omitempty"` // only for auto-scheduling, "node" (default) means different replicas on different nodes, and "cloud" means different replicas on different clouds.
+	Dependencies     []Dependency        `json:"dependencies,omitempty"`  // The information of all applications that this application depends on, only useful for
+
+	// The information needed to schedule this application again, which is put into the annotation "AutoScheduleInfoAnno" of the deployment.
+	// It is set by auto-scheduling rather than users, so it is not in the json.
+	AutoScheduleInfo string `json:"-"`
 }
 
 // This is for the functionality of auto-schedule
-// In Dependency, only AppName is enough, because:
-// 1. Bandwidth is not considered in this model;
+// In Dependency, only AppName is necessary, because by default:
+// 1. Bandwidth is not considered;
 // 2. RTT is not a hard requirement, but soft, which means that the smaller RTT the better, but high RTT is also OK.
 // A high RTT will only make the response slow, but the application will still work.
+// If an application has hard network requirements for a dependency, users can set MinBandwidthMbps and MaxRttMs, and auto-scheduling will only accept the solutions meeting them.
 type Dependency struct {
-	AppName string `json:"appName"` // the name of the dependent application
+	AppName          string  `json:"appName"`                    // the name of the dependent application
+	MinBandwidthMbps float64 `json:"minBandwidthMbps,omitempty"` // the minimum bandwidth to the dependent application, unit Mbps. 0 means no requirement.
+	MaxRttMs         float64 `json:"maxRttMs,omitempty"`         // the maximum RTT to the dependent application, unit millisecond (ms). 0 means using the default limit of auto-scheduling.
 }
 
 type K8sContainer struct {
@@ -72,10 +82,26 @@ type K8sEnv struct {
 	Value string `json:"value"`
 }
 
-// Mount VM paths into the container
+// Mount a volume into the container. A mount has one of the following sources:
+// 1. VmPath: a path on the VM where the pod runs (a hostPath volume), so the data is lost when the pod is moved to another VM;
+// 2. Pvc: a PersistentVolumeClaim, whose data is kept when the pod is moved or the application is deleted;
+// 3. ConfigMap or Secret: a ConfigMap or Secret in the namespace of the application, mounted as files.
 type K8sMount struct {
-	VmPath        string `json:"vmPath"`
-	ContainerPath string `json:"containerPath"`
+	VmPath        string  `json:"vmPath"`
+	Pvc           *K8sPvc `json:"pvc,omitempty"`
+	ConfigMap     string  `json:"configMap,omitempty"`
+	Secret        string  `json:"secret,omitempty"`
+	ContainerPath string  `json:"containerPath"`
+	SubPath       string  `json:"subPath,omitempty"`
+	ReadOnly      bool    `json:"readOnly,omitempty"`
+}
+
+// A PersistentVolumeClaim mounted by an application. If it does not exist, it is created with StorageClass, Size and AccessMode.
+type K8sPvc struct {
+	Name         string `json:"name"`
+	StorageClass string `json:"storageClass,omitempty"` // empty means the default storage class of the cluster
+	Size         string `json:"size,omitempty"`         // e.g., "10Gi", necessary to create the claim
+	AccessMode   string `json:"accessMode,omitempty"`   // "ReadWriteOnce" (default), "ReadWriteMany" or "ReadOnlyMany"
 }
 
 // PortInfo can store the port information from the web form
@@ -89,6 +115,7 @@ type PortInfo struct {
 
 type AppInfo struct {
 	AppName       string    `json:"appName"`
+	Namespace     string    `json:"namespace"`
 	SvcName       string    `json:"svcName"`
 	DeployName    string    `json:"deployName"`
 	ClusterIP     string    `json:"clusterIP"`
@@ -110,6 +137,10 @@ type PodHost struct {
 
 // a method to check whether the application is running
 func appRunning(app appsv1.Deployment) bool {
+	// after the deployment is updated, its status is about the old pods until Kubernetes observes the update
+	if app.Status.ObservedGeneration < app.Generation {
+		return false
+	}
 	if *app.Spec.Replicas != app.Status.Replicas {
 		return false
 	}
@@ -192,8 +223,9 @@ func getNodePortIP(app appsv1.Deployment, pods []corev1.Pod) []string {
 	return nodePortIPs
 }
 
-func ListApplications() ([]AppInfo, error) {
-	applications, err := ListDeployment(KubernetesNamespace)
+// list the applications in a namespace, and metav1.NamespaceAll means all namespaces.
+func ListApplications(namespace string) ([]AppInfo, error) {
+	applications, err := ListDeployment(namespace)
 	if err != nil {
 		beego.Error(fmt.Sprintf("ListDeployment error: %s", err.Error()))
 		return []AppInfo{}, err
@@ -224,10 +256,10 @@ func ListApplications() ([]AppInfo, error) {
 	return appList, nil
 }
 
-func GetApplication(appName string) (AppInfo, error, int) {
+func GetApplication(namespace, appName string) (AppInfo, error, int) {
 	deployName := appName + DeploymentSuffix
 
-	deploy, err := GetDeployment(KubernetesNamespace, deployName)
+	deploy, err := GetDeployment(namespace, deployName)
 	if err != nil {
 		outErr := fmt.Errorf("Get the deployment of app [%s], error: %w", appName, err)
 		beego.Error(outErr)
@@ -259,6 +291,7 @@ func getAppInfoDeploy(d appsv1.Deployment) (AppInfo, error) {
 	pods := getAllPods(d)
 
 	thisApp.AppName = appName
+	thisApp.Namespace = d.Namespace
 	thisApp.SvcName = svcName
 	thisApp.DeployName = d.Name
 	thisApp.Hosts = getHosts(d, pods)
@@ -285,9 +318,9 @@ func getAppInfoDeploy(d appsv1.Deployment) (AppInfo, error) {
 		thisApp.Priority = 0
 	}
 
-	svc, err := GetService(KubernetesNamespace, svcName)
+	svc, err := GetService(d.Namespace, svcName)
 	if err != nil {
-		outErr := fmt.Errorf("GetService %s/%s error: %w", KubernetesNamespace, svcName, err)
+		outErr := fmt.Errorf("GetService %s/%s error: %w", d.Namespace, svcName, err)
 		beego.Error(outErr)
 
 		thisApp.ClusterIP = ""
@@ -320,47 +353,47 @@ func getAppInfoDeploy(d appsv1.Deployment) (AppInfo, error) {
 	return thisApp, nil
 }
 
-func DeleteApplication(appName string) (error, int) {
+func DeleteApplication(namespace, appName string) (error, int) {
 	deployName := appName + DeploymentSuffix
 	svcName := appName + ServiceSuffix
 
-	deploy, err := GetDeployment(KubernetesNamespace, deployName)
+	deploy, err := GetDeployment(namespace, deployName)
 	if err != nil {
 		outErr := fmt.Errorf("Get the deployment of app [%s], error: %w", appName, err)
 		beego.Error(outErr)
 		return outErr, http.StatusInternalServerError
 	}
 
-	beego.Info(fmt.Sprintf("Delete deployment [%s/%s]", KubernetesNamespace, deployName))
-	if err := DeleteDeployment(KubernetesNamespace, deployName); err != nil {
-		outErr := fmt.Errorf("Delete deployment [%s/%s] error: %s", KubernetesNamespace, deployName, err.Error())
+	beego.Info(fmt.Sprintf("Delete deployment [%s/%s]", namespace, deployName))
+	if err := DeleteDeployment(namespace, deployName); err != nil {
+		outErr := fmt.Errorf("Delete deployment [%s/%s] error: %s", namespace, deployName, err.Error())
 		beego.Error(outErr)
 		return outErr, http.StatusInternalServerError
 	}
-	beego.Info(fmt.Sprintf("Successfully sent request to delete deployment [%s/%s]", KubernetesNamespace, deployName))
+	beego.Info(fmt.Sprintf("Successfully sent request to delete deployment [%s/%s]", namespace, deployName))
 
-	beego.Info(fmt.Sprintf("Delete service [%s/%s]", KubernetesNamespace, svcName))
-	if err := DeleteService(KubernetesNamespace, svcName); err != nil {
-		outErr := fmt.Errorf("Delete deployment [%s/%s] error: %s", KubernetesNamespace, svcName, err.Error())
+	beego.Info(fmt.Sprintf("Delete service [%s/%s]", namespace, svcName))
+	if err := DeleteService(namespace, svcName); err != nil {
+		outErr := fmt.Errorf("Delete deployment [%s/%s] error: %s", namespace, svcName, err.Error())
 		beego.Error(outErr)
 		return outErr, http.StatusInternalServerError
 	}
-	beego.Info(fmt.Sprintf("Successful! Delete service [%s/%s]", KubernetesNamespace, svcName))
+	beego.Info(fmt.Sprintf("Successful! Delete service [%s/%s]", namespace, svcName))
 
-	beego.Info(fmt.Sprintf("Start to wait for the deployment [%s/%s] deleted.", KubernetesNamespace, deployName))
+	beego.Info(fmt.Sprintf("Start to wait for the deployment [%s/%s] deleted.", namespace, deployName))
 	if err := WaitForDeployDeleted(WaitForTimeOut, 10, deploy); err != nil {
-		outErr := fmt.Errorf("Wait for the deployment [%s/%s] deleted, error: %w", KubernetesNamespace, deployName, err)
+		outErr := fmt.Errorf("Wait for the deployment [%s/%s] deleted, error: %w", namespace, deployName, err)
 		beego.Error(outErr)
 		return outErr, http.StatusInternalServerError
 	}
-	beego.Info(fmt.Sprintf("The deployment [%s/%s] is already deleted.", KubernetesNamespace, deployName))
+	beego.Info(fmt.Sprintf("The deployment [%s/%s] is already deleted.", namespace, deployName))
 
-	beego.Info(fmt.Sprintf("Successful! Deleted deployment [%s/%s]", KubernetesNamespace, deployName))
+	beego.Info(fmt.Sprintf("Successful! Deleted deployment [%s/%s]", namespace, deployName))
 	return nil, http.StatusOK
 }
 
 // delete a batch of applications concurrently
-func DeleteBatchApps(appNames []string) []error {
+func DeleteBatchApps(namespace string, appNames []string) []error {
 	var errs []error
 	var errsMu sync.Mutex // the slice in golang is not safe for concurrent read/write
 
@@ -371,7 +404,7 @@ func DeleteBatchApps(appNames []string) []error {
 		wg.Add(1)
 		go func(an string) {
 			defer wg.Done()
-			err, _ := DeleteApplication(an)
+			err, _ := DeleteApplication(namespace, an)
 			if err != nil {
 				outErr := fmt.Errorf("delete application [%s], error %w.", an, err)
 				beego.Error(outErr)
@@ -394,6 +427,61 @@ func CreateApplication(app K8sApp) error {
 		return outErr
 	}
 
+	deployment, service, err := genAppObjects(app)
+	if err != nil {
+		outErr := fmt.Errorf("Generate the deployment and service of app [%s] error: %w", app.Name, err)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	// the PVCs should exist before the pods mount them
+	if err, _ := ensureAppPvcs(deployment.Namespace, app); err != nil {
+		outErr := fmt.Errorf("Create the PVCs of app [%s] error: %w", app.Name, err)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	beego.Info(fmt.Sprintf("Create deployment [%+v]", deployment))
+	beego.Info(fmt.Sprintf(""))
+	deploymentJson, err := json.Marshal(deployment)
+	if err != nil {
+		beego.Error(fmt.Sprintf("Json Marshal error: %s", err.Error()))
+	}
+	beego.Info(fmt.Sprintf("Create deployment (json) [%s]", string(deploymentJson)))
+
+	createdDeployment, err := CreateDeployment(deployment)
+	if err != nil {
+		outErr := fmt.Errorf("Create deployment [%+v] error: %w", deployment, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	beego.Info(fmt.Sprintf("Deployment %s/%s created successful.", createdDeployment.Namespace, createdDeployment.Name))
+
+	if service != nil {
+		beego.Info(fmt.Sprintf("Create service [%+v]", service))
+		beego.Info(fmt.Sprintf(""))
+		serviceJson, err := json.Marshal(service)
+		if err != nil {
+			outErr := fmt.Errorf("Json Marshal error: %w", err)
+			beego.Error(outErr)
+			return outErr
+		}
+		beego.Info(fmt.Sprintf("Create service (json) [%s]", string(serviceJson)))
+
+		createdService, err := CreateService(service)
+		if err != nil {
+			outErr := fmt.Errorf("Create service [%+v] error: %w", service, err)
+			beego.Error(outErr)
+			return outErr
+		}
+		beego.Info(fmt.Sprintf("Service %s/%s created successful.", createdService.Namespace, createdService.Name))
+	}
+
+	return nil
+}
+
+// generate the Kubernetes deployment and service of an application. The service is nil if the application has no service ports or node ports.
+func genAppObjects(app K8sApp) (*appsv1.Deployment, *corev1.Service, error) {
 	// Kubernetes labels of the pods of this application
 	labels := map[string]string{
 		"app": app.Name,
@@ -406,27 +494,21 @@ func CreateApplication(app K8sApp) error {
 
 	// make volume configuration in a pod
 	beego.Info("make volume configuration in a pod")
-	var volumeP2N map[string]string = make(map[string]string) // a map from VM paths to volume names
+	var volumeK2N map[string]string = make(map[string]string) // a map from the volume keys of mounts to volume names
 	var volumes []corev1.Volume                               // put this slice into deployment template
 	for i := 0; i < len(app.Containers); i++ {
 		beego.Info(fmt.Sprintf("make volume configuration, Container [%d] has [%d] mount items.", i, len(app.Containers[i].Mounts)))
 
 		for j := 0; j < len(app.Containers[i].Mounts); j++ {
-			thisVMPath := app.Containers[i].Mounts[j].VmPath
-			if _, exist := volumeP2N[thisVMPath]; !exist {
-				thisVolumeName := "volume" + strconv.Itoa(len(volumeP2N))
-				beego.Info(fmt.Sprintf("add volume name: [%s], VM path: [%s]", thisVolumeName, thisVMPath))
-				volumeP2N[thisVMPath] = thisVolumeName
+			thisMount := app.Containers[i].Mounts[j]
+			if _, exist := volumeK2N[thisMount.volumeKey()]; !exist {
+				thisVolumeName := "volume" + strconv.Itoa(len(volumeK2N))
+				beego.Info(fmt.Sprintf("add volume name: [%s], %s", thisVolumeName, thisMount.sourceString()))
+				volumeK2N[thisMount.volumeKey()] = thisVolumeName
 
-				var hostPathType corev1.HostPathType = corev1.HostPathDirectoryOrCreate
 				volumes = append(volumes, corev1.Volume{
-					Name: thisVolumeName,
-					VolumeSource: corev1.VolumeSource{
-						HostPath: &corev1.HostPathVolumeSource{
-							Path: thisVMPath,
-							Type: &hostPathType,
-						},
-					},
+					Name:         thisVolumeName,
+					VolumeSource: thisMount.volumeSource(),
 				})
 			}
 		}
@@ -512,18 +594,19 @@ func CreateApplication(app K8sApp) error {
 
 		// get mount items
 		for j := 0; j < len(app.Containers[i].Mounts); j++ {
-			thisVMPath := app.Containers[i].Mounts[j].VmPath
-			thisContainerPath := app.Containers[i].Mounts[j].ContainerPath
-			volumeName, found := volumeP2N[thisVMPath]
+			thisMount := app.Containers[i].Mounts[j]
+			volumeName, found := volumeK2N[thisMount.volumeKey()]
 			if !found {
-				beego.Error(fmt.Sprintf("Container [%d], mount [%d]: VM Path [%s], Container Path [%s], cannot found volume name.", i, j, thisVMPath, thisContainerPath))
+				beego.Error(fmt.Sprintf("Container [%d], mount [%d]: %s, Container Path [%s], cannot found volume name.", i, j, thisMount.sourceString(), thisMount.ContainerPath))
 			} else {
-				beego.Info(fmt.Sprintf("Container [%d], mount [%d]: VM Path [%s], Container Path [%s], volume name [%s].", i, j, thisVMPath, thisContainerPath, volumeName))
+				beego.Info(fmt.Sprintf("Container [%d], mount [%d]: %s, Container Path [%s], volume name [%s].", i, j, thisMount.sourceString(), thisMount.ContainerPath, volumeName))
 			}
 
 			thisContainer.VolumeMounts = append(thisContainer.VolumeMounts, corev1.VolumeMount{
 				Name:      volumeName,
-				MountPath: thisContainerPath,
+				MountPath: thisMount.ContainerPath,
+				SubPath:   thisMount.SubPath,
+				ReadOnly:  thisMount.ReadOnly,
 			})
 		}
 
@@ -552,7 +635,7 @@ func CreateApplication(app K8sApp) error {
 					if err != nil {
 						outErr := fmt.Errorf("Atoi ServicePort error: %w", err)
 						beego.Error(outErr)
-						return outErr
+						return nil, nil, outErr
 					}
 					thisServicePort.Port = int32(sp)
 				}
@@ -563,7 +646,7 @@ func CreateApplication(app K8sApp) error {
 					if err != nil {
 						outErr := fmt.Errorf("Atoi NodePort error: %w", err)
 						beego.Error(outErr)
-						return outErr
+						return nil, nil, outErr
 					}
 					hasNodePort = true
 					thisServicePort.NodePort = int32(np)
@@ -588,10 +671,23 @@ func CreateApplication(app K8sApp) error {
 	}
 	maxSurge := intstr.FromInt(1)
 
+	// the images in the registries with credentials need imagePullSecrets
+	var images []string
+	for _, container := range app.Containers {
+		images = append(images, container.Image)
+	}
+	namespace := AppNamespace(app.Namespace)
+	imagePullSecrets, err := ImagePullSecretsFor(namespace, images...)
+	if err != nil {
+		outErr := fmt.Errorf("Get the imagePullSecrets of app [%s], error: %w", app.Name, err)
+		beego.Error(outErr)
+		return nil, nil, outErr
+	}
+
 	deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      app.Name + DeploymentSuffix,
-			Namespace: KubernetesNamespace,
+			Namespace: namespace,
 		},
 		Spec: appsv1.DeploymentSpec{
 			Replicas: &app.Replicas,
@@ -610,10 +706,11 @@ func CreateApplication(app K8sApp) error {
 					Labels: labels,
 				},
 				Spec: corev1.PodSpec{
-					HostNetwork: app.HostNetwork,
-					DNSPolicy:   corev1.DNSClusterFirstWithHostNet, // without this, pods with HostNetwork cannot access coredns
-					Containers:  containers,
-					Volumes:     volumes,
+					HostNetwork:      app.HostNetwork,
+					DNSPolicy:        corev1.DNSClusterFirstWithHostNet, // without this, pods with HostNetwork cannot access coredns
+					Containers:       containers,
+					Volumes:          volumes,
+					ImagePullSecrets: imagePullSecrets,
 					Affinity: &corev1.Affinity{
 						PodAntiAffinity: &corev1.PodAntiAffinity{
 							RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
@@ -635,6 +732,10 @@ func CreateApplication(app K8sApp) error {
 	if len(app.NodeName) > 0 {
 		deployment.Spec.Template.Spec.NodeName = app.NodeName
 	}
+	// If the app in the request body has replica node names, we only allow the pods to run on these nodes. The pod anti-affinity above ensures one replica on each node.
+	if len(app.ReplicaNodeNames) > 0 {
+		deployment.Spec.Template.Spec.Affinity.NodeAffinity = replicaNodeAffinity(app.ReplicaNodeNames)
+	}
 	// if the app in the request body has node selectors, we set them in K8s deployment
 	if len(app.NodeSelector) > 0 {
 		deployment.Spec.Template.Spec.NodeSelector = app.NodeSelector
@@ -652,66 +753,35 @@ func CreateApplication(app K8sApp) error {
 		}
 		deployment.Annotations[AutoScheduledAnno] = strconv.FormatBool(app.AutoScheduled)
 		deployment.Annotations[PriorityAnno] = strconv.Itoa(app.Priority)
+		if len(app.AutoScheduleInfo) > 0 {
+			deployment.Annotations[AutoScheduleInfoAnno] = app.AutoScheduleInfo
+		}
 	}
 
-	beego.Info(fmt.Sprintf("Create deployment [%+v]", deployment))
-	beego.Info(fmt.Sprintf(""))
-	deploymentJson, err := json.Marshal(deployment)
-	if err != nil {
-		beego.Error(fmt.Sprintf("Json Marshal error: %s", err.Error()))
+	// service of this application, only for the application with ports
+	if len(servicePorts) == 0 {
+		return deployment, nil, nil
 	}
-	beego.Info(fmt.Sprintf("Create deployment (json) [%s]", string(deploymentJson)))
-
-	createdDeployment, err := CreateDeployment(deployment)
-	if err != nil {
-		outErr := fmt.Errorf("Create deployment [%+v] error: %w", deployment, err)
-		beego.Error(outErr)
-		return outErr
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      app.Name + ServiceSuffix,
+			Namespace: namespace,
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: labels,
+			Type:     corev1.ServiceTypeClusterIP,
+			Ports:    servicePorts,
+		},
 	}
-	beego.Info(fmt.Sprintf("Deployment %s/%s created successful.", createdDeployment.Namespace, createdDeployment.Name))
-
-	// service of this application
-	if len(servicePorts) != 0 {
-		service := &corev1.Service{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      app.Name + ServiceSuffix,
-				Namespace: KubernetesNamespace,
-			},
-			Spec: corev1.ServiceSpec{
-				Selector: labels,
-				Type:     corev1.ServiceTypeClusterIP,
-				Ports:    servicePorts,
-			},
-		}
-		if hasNodePort {
-			service.Spec.Type = corev1.ServiceTypeNodePort
-		}
-
-		beego.Info(fmt.Sprintf("Create service [%+v]", service))
-		beego.Info(fmt.Sprintf(""))
-		serviceJson, err := json.Marshal(service)
-		if err != nil {
-			outErr := fmt.Errorf("Json Marshal error: %w", err)
-			beego.Error(outErr)
-			return outErr
-		}
-		beego.Info(fmt.Sprintf("Create service (json) [%s]", string(serviceJson)))
-
-		createdService, err := CreateService(service)
-		if err != nil {
-			outErr := fmt.Errorf("Create service [%+v] error: %w", service, err)
-			beego.Error(outErr)
-			return outErr
-		}
-		beego.Info(fmt.Sprintf("Service %s/%s created successful.", createdService.Namespace, createdService.Name))
+	if hasNodePort {
+		service.Spec.Type = corev1.ServiceTypeNodePort
 	}
-
-	return nil
+	return deployment, service, nil
 }
 
-func WaitForAppRunning(timeout int, checkInterval int, appName string) error {
+func WaitForAppRunning(timeout int, checkInterval int, namespace, appName string) error {
 	return MyWaitFor(timeout, checkInterval, func() (bool, error) {
-		app, err, statusCode := GetApplication(appName)
+		app, err, statusCode := GetApplication(namespace, appName)
 		if err != nil {
 			if statusCode == http.StatusNotFound {
 				return false, err
@@ -726,6 +796,93 @@ func WaitForAppRunning(timeout int, checkInterval int, appName string) error {
 	})
 }
 
+// only allow the pods to run on these nodes
+func replicaNodeAffinity(nodeNames []string) *corev1.NodeAffinity {
+	return &corev1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{
+				{
+					MatchExpressions: []corev1.NodeSelectorRequirement{
+						{
+							Key:      corev1.LabelHostname,
+							Operator: corev1.NodeSelectorOpIn,
+							Values:   nodeNames,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// Move a running application to other Kubernetes nodes with the new CPU cores of its containers, by a rolling update of its deployment.
+// With 1 node name, the application runs 1 replica on that node; with more node names, the application runs 1 replica on each of them.
+// The function returns when the deployment is updated, and the caller should wait for the application running.
+func MigrateApplication(namespace, appName string, nodeNames []string, containerCpus []string) (error, int) {
+	if len(nodeNames) == 0 {
+		outErr := fmt.Errorf("No nodes to migrate application [%s] to", appName)
+		beego.Error(outErr)
+		return outErr, http.StatusBadRequest
+	}
+
+	deployName := appName + DeploymentSuffix
+	deployment, err := GetDeployment(namespace, deployName)
+	if err != nil {
+		outErr := fmt.Errorf("Get deployment %s/%s error: %w", namespace, deployName, err)
+		beego.Error(outErr)
+		return outErr, http.StatusInternalServerError
+	}
+	if deployment == nil {
+		outErr := fmt.Errorf("Deployment %s/%s not found", namespace, deployName)
+		beego.Error(outErr)
+		return outErr, http.StatusNotFound
+	}
+
+	replicas := int32(len(nodeNames))
+	deployment.Spec.Replicas = &replicas
+	if replicas == 1 {
+		deployment.Spec.Template.Spec.NodeName = nodeNames[0]
+		if deployment.Spec.Template.Spec.Affinity != nil {
+			deployment.Spec.Template.Spec.Affinity.NodeAffinity = nil
+		}
+	} else {
+		deployment.Spec.Template.Spec.NodeName = ""
+		if deployment.Spec.Template.Spec.Affinity == nil {
+			deployment.Spec.Template.Spec.Affinity = &corev1.Affinity{}
+		}
+		deployment.Spec.Template.Spec.Affinity.NodeAffinity = replicaNodeAffinity(nodeNames)
+	}
+	// the CPU of every container is set in order, and the containers without the CPU in the input are not changed.
+	for i, cpu := range containerCpus {
+		if i >= len(deployment.Spec.Template.Spec.Containers) || len(cpu) == 0 {
+			break
+		}
+		cpuQuantity, err := resource.ParseQuantity(cpu)
+		if err != nil {
+			outErr := fmt.Errorf("Parse CPU [%s] of container [%d] of application [%s] error: %w", cpu, i, appName, err)
+			beego.Error(outErr)
+			return outErr, http.StatusBadRequest
+		}
+		container := &deployment.Spec.Template.Spec.Containers[i]
+		if container.Resources.Requests == nil {
+			container.Resources.Requests = make(corev1.ResourceList)
+		}
+		if container.Resources.Limits == nil {
+			container.Resources.Limits = make(corev1.ResourceList)
+		}
+		container.Resources.Requests[corev1.ResourceCPU] = cpuQuantity
+		container.Resources.Limits[corev1.ResourceCPU] = cpuQuantity
+	}
+
+	beego.Info(fmt.Sprintf("Migrate application [%s] to nodes %v with container CPUs %v.", appName, nodeNames, containerCpus))
+	if _, err := UpdateDeployment(deployment); err != nil {
+		outErr := fmt.Errorf("Update deployment %s/%s error: %w", namespace, deployName, err)
+		beego.Error(outErr)
+		return outErr, http.StatusInternalServerError
+	}
+	return nil, http.StatusOK
+}
+
 // Create a group of applications and wait for them running.
 func CreateAppsWait(appsToCreate []K8sApp) ([]AppInfo, error) {
 
@@ -781,14 +938,14 @@ func CreateAppAndWait(appToCreate K8sApp) (AppInfo, error) {
 	}
 
 	beego.Info(fmt.Sprintf("Start to wait for the application [%s] running", appToCreate.Name))
-	if err := WaitForAppRunning(WaitForTimeOut, 10, appToCreate.Name); err != nil {
+	if err := WaitForAppRunning(WaitForTimeOut, 10, AppNamespace(appToCreate.Namespace), appToCreate.Name); err != nil {
 		outErr := fmt.Errorf("Wait for application [%s] running, error: %w", appToCreate.Name, err)
 		beego.Error(outErr)
 		return AppInfo{}, outErr
 	}
 	beego.Info(fmt.Sprintf("The application [%s] is already running", appToCreate.Name))
 
-	outAppInfo, err, _ := GetApplication(appToCreate.Name)
+	outAppInfo, err, _ := GetApplication(AppNamespace(appToCreate.Namespace), appToCreate.Name)
 	if err != nil {
 		outErr := fmt.Errorf("After waiting, get application [%s], error: %w", appToCreate.Name, err)
 		beego.Error(outErr)
@@ -801,7 +958,7 @@ func CreateAppAndWait(appToCreate K8sApp) (AppInfo, error) {
 
 func ListAppsNamePrefix(prefix string) ([]AppInfo, error) {
 	// get all applications of multi-cloud manager
-	allApps, err := ListApplications()
+	allApps, err := ListApplications(KubernetesNamespace)
 	if err != nil {
 		outErr := fmt.Errorf("ListApplications error: %w", err)
 		beego.Error(outErr)
@@ -205,7 +205,7 @@ func TestDeleteBatchApps(t *testing.T) {
 
 	t.Log("apps to uninstall:", appsNamesToDelete)
 
-	errs := DeleteBatchApps(appsNamesToDelete)
+	errs := DeleteBatchApps(KubernetesNamespace, appsNamesToDelete)
 	if errs != nil {
 		t.Errorf("Delete applications, error: [%s]", HandleErrSlice(errs).Error())
 	} else {
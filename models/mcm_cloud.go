@@ -47,6 +47,10 @@ type CloudInfo struct {
 
 	TemperatureC   float64
 	HasTemperature bool
+
+	// the optional capabilities of this cloud and the actions that can be done on its VMs
+	Capabilities []string
+	VmActions    []string
 }
 
 // ===== Helpers =====
@@ -88,6 +92,9 @@ func toCloudInfo(cloud Iaas, rs ResourceStatus) CloudInfo {
 		VmPercent:      safeDiv(rs.InUse.Vm, rs.Limit.Vm),
 		VolumePercent:  safeDiv(rs.InUse.Volume, rs.Limit.Volume),
 		PortPercent:    safeDiv(rs.InUse.Port, rs.Limit.Port),
+
+		Capabilities: CloudCapabilities(cloud),
+		VmActions:    CloudVmActions(cloud),
 	}
 
 	// ⭐ Lấy toạ độ từ cloud nếu có hỗ trợ
@@ -116,7 +123,7 @@ func ListClouds() ([]CloudInfo, []error) {
 	var errsMu sync.Mutex
 
 	var wg sync.WaitGroup
-	for _, cloud := range Clouds {
+	for _, cloud := range ListIaas() {
 		wg.Add(1)
 		go func(cloud Iaas) {
 			defer wg.Done()
@@ -161,7 +168,7 @@ func ListClouds() ([]CloudInfo, []error) {
 
 // GetCloud trả thông tin 1 cloud + danh sách VM của nó
 func GetCloud(cloudName string) (CloudInfo, []IaasVm, error, error) {
-	cloud, ok := Clouds[cloudName]
+	cloud, ok := GetIaas(cloudName)
 	if !ok || cloud == nil {
 		err := fmt.Errorf("cloud %q not found", cloudName)
 		return CloudInfo{}, nil, err, nil
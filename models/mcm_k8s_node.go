@@ -85,7 +85,7 @@ func ListK8sNodes() []K8sNodeInfo {
 
 		// calculate the resources occupied by pods
 		var resInUse K8sNodeRes
-		podsOnNode, err := ListPodsOnNode(KubernetesNamespace, node.Name)
+		podsOnNode, err := ListPodsOnNode(metav1.NamespaceAll, node.Name)
 		if err != nil {
 			outErr := fmt.Errorf("List pods on Kubernetes node [%s], error: %w", node.Name, err)
 			beego.Error(outErr)
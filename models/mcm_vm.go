@@ -19,7 +19,7 @@ func ListVMsAllClouds() ([]IaasVm, []error) {
 	// List VMs in every cloud in parallel
 	var wg sync.WaitGroup
 
-	for _, cloud := range Clouds {
+	for _, cloud := range ListIaas() {
 		wg.Add(1)
 		go func(c Iaas) {
 			defer wg.Done()
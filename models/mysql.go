@@ -64,7 +64,13 @@ func DeleteDb(dbName string) error {
 	}
 	defer db.Close()
 
-	query := fmt.Sprintf("drop database %s", dbName)
+	quotedDb, err := mySqlIdent(dbName)
+	if err != nil {
+		outErr := fmt.Errorf("Database name [%s], error [%w].", dbName, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	query := fmt.Sprintf("drop database %s", quotedDb)
 
 	// I set a timeout for this delete database request,
 	// because I find a problem:
@@ -93,7 +99,13 @@ func CreateDb(dbName string) error {
 	}
 	defer db.Close()
 
-	query := fmt.Sprintf("create database %s", dbName)
+	quotedDb, err := mySqlIdent(dbName)
+	if err != nil {
+		outErr := fmt.Errorf("Database name [%s], error [%w].", dbName, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	query := fmt.Sprintf("create database %s", quotedDb)
 
 	result, err := db.Query(query)
 	if err != nil {
@@ -108,7 +120,13 @@ func CreateDb(dbName string) error {
 }
 
 func UseDb(db *sql.DB, dbName string) error {
-	query := fmt.Sprintf("use %s", dbName)
+	quotedDb, err := mySqlIdent(dbName)
+	if err != nil {
+		outErr := fmt.Errorf("Database name [%s], error [%w].", dbName, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	query := fmt.Sprintf("use %s", quotedDb)
 
 	result, err := db.Query(query)
 	if err != nil {
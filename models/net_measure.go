@@ -1,11 +1,12 @@
 package models
 
 import (
-	"context"
 	"fmt"
-	"math"
+	"net/http"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/astaxie/beego"
 	_ "github.com/go-sql-driver/mysql"
@@ -39,8 +40,15 @@ const (
 	NetPerfDbName    string = "multi_cloud"
 	DbFieldCloudName string = "target_cloud_name" // field in the tables of network performance database
 	DbFieldRtt       string = "rtt_ms"            // field in the tables of network performance database
+	DbFieldBandwidth string = "bandwidth_mbps"    // field in the tables of network performance database
 
-	UnreachableRttMs float64 = 250000 // unit: millisecond (ms). when a cloud is unreachable from another, in the database, we set this value as the RTT between them. This value should be consistent with that in "net-perf-container-image/client.sh"
+	netTestResultPrefix string = "MCM_NET_RESULT" // the prefix of the result line printed by "net-perf-container-image/client.sh"
+
+	UnreachableRttMs  float64 = 250000 // unit: millisecond (ms). when a cloud is unreachable from another, in the database, we set this value as the RTT between them. This value should be consistent with that in "net-perf-container-image/client.sh"
+	UnreachableBwMbps float64 = 0      // unit: Mbps. when a cloud is unreachable from another, in the database, we set this value as the bandwidth between them. This value should be consistent with that in "net-perf-container-image/client.sh"
+	NotMeasuredBwMbps float64 = -1     // unit: Mbps. "net-perf-container-image/client.sh" reports this value when the cloud is reachable but the throughput cannot be measured, and then we keep the previous bandwidth in the database. This value should be consistent with that in "net-perf-container-image/client.sh"
+
+	netTestIperfServers int = 4 // the number of iperf3 servers in a network test server, each of which runs one test at a time. This value should be consistent with that in "net-perf-container-image/server.sh"
 )
 
 var (
@@ -65,31 +73,58 @@ var NetTestToleration apiv1.Toleration = apiv1.Toleration{
 // The function to measure network performance between every two clouds
 // This function should be executed every time period
 func MeasNetPerf() {
+	// the clouds can be added or removed during a round, so we use the clouds at the beginning of this round.
+	clouds := ListIaas()
+	var cloudNames []string
+	for name := range clouds {
+		cloudNames = append(cloudNames, name)
+	}
+	sort.Strings(cloudNames)
+
+	// the network state of newly added clouds should exist in the store before measurement
+	if netTestRecords.updateClouds(cloudNames) {
+		if err := netStateStore.EnsureClouds(cloudNames); err != nil {
+			outErr := fmt.Errorf("Cannot ensure the network state of clouds %v in the store, Error: %w", cloudNames, err)
+			beego.Error(outErr)
+			return
+		}
+	}
+
+	// keep the results of the pairs measured in this round in the history. The other pairs are not measured, so their states in the store are old and not recorded again.
+	var measured map[string]map[string]NetworkState
 	defer func() {
-		// Delete server Deployments
-		if err := deleteNetTestServers(); err != nil {
-			outErr := fmt.Errorf("Cannot delete network performance test servers, Error: %w", err)
+		if err := recordNetHist(measured); err != nil {
+			outErr := fmt.Errorf("Cannot record the network state history, Error: %w", err)
 			beego.Error(outErr)
 			return
 		}
 	}()
 
+	pairs := netTestRecords.pairsToMeasure(cloudNames, time.Now(), time.Duration(NetTestStaleSec)*time.Second)
+	if len(pairs) == 0 {
+		beego.Info("No pair of clouds is stale, unreachable, or new, so no network measurement is needed in this round.")
+		return
+	}
+	involvedClouds := netTestPairClouds(pairs)
+	beego.Info(fmt.Sprintf("%d pairs of clouds need to be measured in this round, involving clouds %v.", len(pairs), involvedClouds))
+
+	// The server Deployments keep running between rounds, so we only delete the client Jobs.
 	defer func() {
 		// Delete client Jobs
-		if err := deleteNetTestClients(); err != nil {
+		if err := deleteNetTestClients(clouds, pairs); err != nil {
 			outErr := fmt.Errorf("Cannot delete network performance test clients, Error: %w", err)
 			beego.Error(outErr)
 			return
 		}
 	}()
 
-	beego.Info("Start to ensure the network test preconditions for each cloud.")
+	beego.Info("Start to ensure the network test preconditions for each involved cloud.")
 
-	// Ensure the network test preconditions for each cloud in parallel
+	// Ensure the network test preconditions for each involved cloud in parallel
 	var wg sync.WaitGroup
 	var errsMu sync.Mutex // the slice in golang is not safe for concurrent read/write
 	var errs []error
-	for name, cloud := range Clouds {
+	for _, name := range involvedClouds {
 		beego.Info(fmt.Sprintf("Ensure the network test preconditions for cloud %s", name))
 		wg.Add(1)
 		go func(c Iaas) {
@@ -102,7 +137,7 @@ func MeasNetPerf() {
 				errsMu.Unlock()
 				return
 			}
-		}(cloud)
+		}(clouds[name])
 	}
 	wg.Wait()
 
@@ -113,26 +148,36 @@ func MeasNetPerf() {
 		return
 	}
 
-	beego.Info("Finish ensuring the network test preconditions for each cloud.")
+	beego.Info("Finish ensuring the network test preconditions for each involved cloud.")
 
-	// Execute network test between every two clouds
-	beego.Info("Start to measure network performance between every two clouds.")
+	// Execute network test between the pairs of clouds
+	beego.Info("Start to measure network performance between the pairs of clouds.")
 
-	// Run server Deployments
-	if err := runNetTestServers(); err != nil {
+	// Run server Deployments on the target clouds if they are not running
+	var targetClouds []string
+	for _, name := range involvedClouds {
+		for _, pair := range pairs {
+			if pair.To == name {
+				targetClouds = append(targetClouds, name)
+				break
+			}
+		}
+	}
+	if err := runNetTestServers(clouds, targetClouds); err != nil {
 		outErr := fmt.Errorf("Cannot run network performance test servers, Error: %w", err)
 		beego.Error(outErr)
 		return
 	}
 
 	// Execute client Jobs
-	if err := executeNetTestClients(); err != nil {
+	var err error
+	if measured, err = executeNetTestClients(clouds, pairs); err != nil {
 		outErr := fmt.Errorf("Cannot run network performance test servers, Error: %w", err)
 		beego.Error(outErr)
 		return
 	}
 
-	beego.Info("Finish measuring network performance between every two clouds. Then we will clean up the environment.")
+	beego.Info("Finish measuring network performance between the pairs of clouds. Then we will clean up the client Jobs.")
 }
 
 // Ensure the preconditions for network test, including VMs, K8s nodes, and K8s taints.
@@ -298,164 +343,119 @@ func ensureTaints(vmMap map[string]*IaasVm) error {
 	return nil
 }
 
-// Create the database, tables, and columns in MySQL.
+// Initialize the NetStateStore chosen by the configuration, in which every cloud is unreachable from every cloud at first.
 func InitNetPerfDB() error {
-	beego.Info("Create the database, tables, and columns in MySQL.")
-
-	beego.Info(fmt.Sprintf("Check existing database"))
+	initNetHistConfig()
+	initNetTestSchedConfig()
 
-	dbs, err := ListDbs()
+	store, err := newNetStateStoreFromConfig()
 	if err != nil {
-		outErr := fmt.Errorf("Check existing database, error %w.", err)
+		outErr := fmt.Errorf("Choose the network state store, error %w.", err)
 		beego.Error(outErr)
 		return outErr
 	}
 
-	beego.Info(fmt.Sprintf("Existing databases: %v", dbs))
-
-	var oldDbExist bool
-	for _, db := range dbs {
-		if db == NetPerfDbName {
-			oldDbExist = true
-			break
-		}
-	}
-
-	if oldDbExist {
-		beego.Info(fmt.Sprintf("Database [%s] exists, so we delete it.", NetPerfDbName))
-		if err := DeleteDb(NetPerfDbName); err != nil {
-			outErr := fmt.Errorf("Delete database [%s], error %w.", NetPerfDbName, err)
-			beego.Error(outErr)
-			return outErr
-		}
-	} else {
-		beego.Info(fmt.Sprintf("Database [%s] does not exist, so we do not need to delete it.", NetPerfDbName))
-	}
-
-	beego.Info(fmt.Sprintf("Create new database: %s", NetPerfDbName))
-	if err := CreateDb(NetPerfDbName); err != nil {
-		outErr := fmt.Errorf("Create database [%s], error %w.", NetPerfDbName, err)
+	if err := store.Init(IaasNames()); err != nil {
+		outErr := fmt.Errorf("Initialize the network state store, error %w.", err)
 		beego.Error(outErr)
 		return outErr
 	}
+	netStateStore = store
+	// every pair is unreachable in the new store, so all of them should be measured again.
+	netTestRecords.reset()
+	RegisterCloudHook(netTestCloudHook)
 
-	// In MySQL, we can use the command "use" to select a database.
-	// We can also specify the database name in every of our commands.
-	// For the purpose of learning, I implement both of the 2 ways, either of which is OK to use.
+	return nil
+}
 
-	//if err := initDbTables(); err != nil {
-	if err := initDbTablesWithUse(); err != nil {
-		outErr := fmt.Errorf("Create and initialize tables for network performance in database [%s], error %w.", NetPerfDbName, err)
+// keep the network state and the network test VMs consistent with the registry of clouds
+var netTestCloudHook CloudHook = CloudHook{
+	Name:     "network test",
+	OnAdd:    onNetTestCloudAdded,
+	OnRemove: onNetTestCloudRemoved,
+}
+
+// The new cloud is unreachable from and to every cloud at first.
+// Creating the network test VMs can take minutes, so it is done in the background, and MeasNetPerf will also create them if they are not ready.
+func onNetTestCloudAdded(cloud Iaas) error {
+	if err := netStateStore.EnsureClouds(IaasNames()); err != nil {
+		outErr := fmt.Errorf("Add the network state of cloud [%s], Error: %w", cloud.ShowName(), err)
 		beego.Error(outErr)
 		return outErr
 	}
-
+	go func() {
+		if err := ensureTestPreC(cloud); err != nil {
+			beego.Error(fmt.Sprintf("Prepare the network test VMs of the new cloud [%s], Error: %s", cloud.ShowName(), err.Error()))
+		}
+	}()
 	return nil
 }
 
-// Initialize the tables by specifying the name of the database in every command.
-func initDbTables() error {
-	beego.Info(fmt.Sprintf("Create and initialize tables for network performance in database [%s].", NetPerfDbName))
-	db, err := NewMySqlCli()
-	if err != nil {
-		outErr := fmt.Errorf("Create MySQL client, error [%w].", err)
+// Deleting the network test VMs can take minutes, so it is done in the background.
+func onNetTestCloudRemoved(cloud Iaas) error {
+	if err := netStateStore.RemoveCloud(cloud.ShowName(), IaasNames()); err != nil {
+		outErr := fmt.Errorf("Remove the network state of cloud [%s], Error: %w", cloud.ShowName(), err)
 		beego.Error(outErr)
 		return outErr
 	}
-	defer db.Close()
-
-	for cloudName, _ := range Clouds {
-		tableName := cloudName
-		query := fmt.Sprintf("create table %s.%s(%s varchar(768) not null,%s double not null, primary key(%s))", NetPerfDbName, tableName, DbFieldCloudName, DbFieldRtt, DbFieldCloudName)
-		result, err := db.Query(query)
-		if err != nil {
-			outErr := fmt.Errorf("Query [%s], error [%w].", query, err)
-			beego.Error(outErr)
-			return outErr
-		}
-		result.Close()
-		beego.Info(fmt.Sprintf("Query [%s] successfully.", query))
-
-		for targetCloudName, _ := range Clouds {
-			query := fmt.Sprintf("insert into %s.%s (%s, %s) values (?, ?)", NetPerfDbName, tableName, DbFieldCloudName, DbFieldRtt)
-			result, err := db.Query(query, targetCloudName, math.MaxFloat64)
-			if err != nil {
-				outErr := fmt.Errorf("Query [%s], args: [%s, %g], error [%w].", query, targetCloudName, math.MaxFloat64, err)
-				beego.Error(outErr)
-				return outErr
-			}
-			result.Close()
-			beego.Info(fmt.Sprintf("Query [%s], args: [%s, %g] successfully.", query, targetCloudName, math.MaxFloat64))
+	go func() {
+		if err := deleteNetTestVms(cloud); err != nil {
+			beego.Error(fmt.Sprintf("Delete the network test VMs of the removed cloud [%s], Error: %s", cloud.ShowName(), err.Error()))
 		}
-	}
-	beego.Info(fmt.Sprintf("Successful! Create and initialize tables for network performance in database [%s].", NetPerfDbName))
-
+	}()
 	return nil
 }
 
-// Initialize the tables after executing the command `use <database name>;` without specifying the name of the database in every command.
-func initDbTablesWithUse() error {
-	beego.Info(fmt.Sprintf("Create and initialize tables for network performance in database [%s].", NetPerfDbName))
-	db, err := NewMySqlCli()
-	if err != nil {
-		outErr := fmt.Errorf("Create MySQL client, error [%w].", err)
-		beego.Error(outErr)
-		return outErr
-	}
-	defer db.Close()
+// delete the network test server application, and remove the network test VMs from Kubernetes and the cloud
+func deleteNetTestVms(cloud Iaas) error {
+	var errs []error
 
-	if err := UseDb(db, NetPerfDbName); err != nil {
-		outErr := fmt.Errorf("UseDb [%s], error %w.", NetPerfDbName, err)
-		beego.Error(outErr)
-		return outErr
+	if _, err, statusCode := GetApplication(KubernetesNamespace, getNetTestServerAppName(cloud)); err == nil {
+		if err := deleteNetTestServer(cloud); err != nil {
+			errs = append(errs, err)
+		}
+	} else if statusCode != http.StatusNotFound {
+		errs = append(errs, fmt.Errorf("Get network test server application [%s], error: [%w].", getNetTestServerAppName(cloud), err))
 	}
 
-	curDb, err := ShowCurUsedDb(db)
+	vms, err := cloud.ListAllVMs()
 	if err != nil {
-		outErr := fmt.Errorf("ShowCurUsedDb(db), error %w.", err)
-		beego.Error(outErr)
-		return outErr
+		errs = append(errs, fmt.Errorf("List vms in cloud [%s] type [%s], error %w.", cloud.ShowName(), cloud.ShowType(), err))
 	}
-	beego.Info(fmt.Sprintf("Currently database [%s] is used.", curDb))
-
-	for cloudName, _ := range Clouds {
-		tableName := cloudName
-		query := fmt.Sprintf("create table %s(%s varchar(768) not null,%s double not null, primary key(%s))", tableName, DbFieldCloudName, DbFieldRtt, DbFieldCloudName)
-		result, err := db.Query(query)
-		if err != nil {
-			outErr := fmt.Errorf("Query [%s], error [%w].", query, err)
-			beego.Error(outErr)
-			return outErr
+	for _, vmName := range getNetTestVMNames(cloud) {
+		if _, err := GetNode(vmName, metav1.GetOptions{}); err == nil {
+			if err := UninstallNode(vmName); err != nil {
+				errs = append(errs, fmt.Errorf("Remove Kubernetes node [%s], error: %w", vmName, err))
+			}
+		} else if !errors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("Get Kubernetes node [%s], error: %w", vmName, err))
 		}
-		result.Close()
-		beego.Info(fmt.Sprintf("Query [%s] successfully.", query))
-
-		for targetCloudName, _ := range Clouds {
-			query := fmt.Sprintf("insert into %s (%s, %s) values (?, ?)", tableName, DbFieldCloudName, DbFieldRtt)
-			result, err := db.Query(query, targetCloudName, UnreachableRttMs)
-			if err != nil {
-				outErr := fmt.Errorf("Query [%s], args: [%s, %g], error [%w].", query, targetCloudName, math.MaxFloat64, err)
-				beego.Error(outErr)
-				return outErr
+		if vm, found := FindVm(vmName, vms); found {
+			if err := cloud.DeleteVM(vm.ID); err != nil {
+				errs = append(errs, fmt.Errorf("Delete vm [%s] in cloud [%s], error: %w", vmName, cloud.ShowName(), err))
 			}
-			result.Close()
-			beego.Info(fmt.Sprintf("Query [%s], args: [%s, %g] successfully.", query, targetCloudName, math.MaxFloat64))
 		}
 	}
-	beego.Info(fmt.Sprintf("Successful! Create and initialize tables for network performance in database [%s].", NetPerfDbName))
 
+	if len(errs) != 0 {
+		outErr := HandleErrSlice(errs)
+		beego.Error(outErr)
+		return outErr
+	}
+	beego.Info(fmt.Sprintf("The network test VMs of cloud [%s] are deleted.", cloud.ShowName()))
 	return nil
 }
 
 // run all network performance test servers
-func runNetTestServers() error {
-	beego.Info("Start to run the network performance test server Deployment on every net test server VM.")
+func runNetTestServers(clouds map[string]Iaas, cloudNames []string) error {
+	beego.Info(fmt.Sprintf("Start to run the network performance test server Deployment on the net test server VMs of clouds %v.", cloudNames))
 
 	// Do it in parallel
 	var wg sync.WaitGroup
 	var errsMu sync.Mutex // the slice in golang is not safe for concurrent read/write
 	var errs []error
-	for name, cloud := range Clouds {
+	for _, name := range cloudNames {
+		cloud := clouds[name]
 		beego.Info(fmt.Sprintf("Run the network test server for cloud %s", name))
 		wg.Add(1)
 		go func(c Iaas) {
@@ -494,6 +494,21 @@ func runNetTestServer(cloud Iaas) error {
 		hostNetwork = hostNetTest
 	}
 
+	// the servers keep running between rounds, so we only need to create it if it does not exist.
+	if _, err, statusCode := GetApplication(KubernetesNamespace, serverAppName); err == nil {
+		beego.Info(fmt.Sprintf("The network test server application [%s] already exists.", serverAppName))
+		if err := WaitForAppRunning(WaitForTimeOut, 10, KubernetesNamespace, serverAppName); err != nil {
+			outErr := fmt.Errorf("Wait for application [%s] running, error: %w", serverAppName, err)
+			beego.Error(outErr)
+			return outErr
+		}
+		return nil
+	} else if statusCode != http.StatusNotFound {
+		outErr := fmt.Errorf("Get network test server application [%s], error: [%w].", serverAppName, err)
+		beego.Error(outErr)
+		return outErr
+	}
+
 	var app K8sApp = K8sApp{
 		Name:        serverAppName,
 		Replicas:    1,
@@ -515,7 +530,7 @@ func runNetTestServer(cloud Iaas) error {
 	}
 
 	beego.Info(fmt.Sprintf("Start to wait for the application [%s] running", app.Name))
-	if err := WaitForAppRunning(WaitForTimeOut, 10, app.Name); err != nil {
+	if err := WaitForAppRunning(WaitForTimeOut, 10, KubernetesNamespace, app.Name); err != nil {
 		outErr := fmt.Errorf("Wait for application [%s] running, error: %w", app.Name, err)
 		beego.Error(outErr)
 		return outErr
@@ -525,7 +540,7 @@ func runNetTestServer(cloud Iaas) error {
 	return nil
 }
 
-// delete all network performance test servers
+// delete all network performance test servers. MeasNetPerf does not call it, because the servers keep running between rounds.
 func deleteNetTestServers() error {
 	beego.Info("Start to delete the network performance test server Deployment on every net test server VM.")
 
@@ -533,7 +548,7 @@ func deleteNetTestServers() error {
 	var wg sync.WaitGroup
 	var errsMu sync.Mutex // the slice in golang is not safe for concurrent read/write
 	var errs []error
-	for name, cloud := range Clouds {
+	for name, cloud := range ListIaas() {
 		beego.Info(fmt.Sprintf("Delete the network test server for cloud %s", name))
 		wg.Add(1)
 		go func(c Iaas) {
@@ -565,7 +580,7 @@ func deleteNetTestServers() error {
 func deleteNetTestServer(cloud Iaas) error {
 	serverAppName := getNetTestServerAppName(cloud)
 
-	if err, _ := DeleteApplication(serverAppName); err != nil {
+	if err, _ := DeleteApplication(KubernetesNamespace, serverAppName); err != nil {
 		outErr := fmt.Errorf("Cloud [%s] type [%s], Delete network test server application [%s], error: [%w].", cloud.ShowName(), cloud.ShowType(), serverAppName, err)
 		beego.Error(outErr)
 		return outErr
@@ -577,29 +592,46 @@ func deleteNetTestServer(cloud Iaas) error {
 }
 
 // From each cloud to each cloud, we run a Kubernetes Job to measure the RTT and write the RTT in the database.
-func executeNetTestClients() error {
-	beego.Info("Start to execute the network performance test client Job on every net test client VM.")
+// It returns the network states of the pairs whose RTT and bandwidth are both measured, even if the other pairs fail.
+func executeNetTestClients(clouds map[string]Iaas, pairs []netTestPair) (map[string]map[string]NetworkState, error) {
+	beego.Info(fmt.Sprintf("Start to execute the network performance test client Jobs of %d pairs of clouds.", len(pairs)))
+
+	limiter := newCloudLimiter(netTestPairClouds(pairs), NetTestCloudConcurrency)
 
 	// Do it in parallel
 	var wg sync.WaitGroup
 	var errsMu sync.Mutex // the slice in golang is not safe for concurrent read/write
 	var errs []error
-	for nameFrom, cloudFrom := range Clouds {
-		for nameTo, cloudTo := range Clouds {
-			beego.Info(fmt.Sprintf("Execute the network performance test client Job from cloud [%s] to cloud [%s]", nameFrom, nameTo))
-			wg.Add(1)
-			go func(cF Iaas, cT Iaas) {
-				defer wg.Done()
-				if err := executeNetTestClient(cF, cT); err != nil {
-					outErr := fmt.Errorf("Cannot execute the network performance test client Job from cloud [%s] to cloud [%s], error: [%w]", cF.ShowName(), cT.ShowName(), err)
-					beego.Error(outErr)
-					errsMu.Lock()
-					errs = append(errs, outErr)
-					errsMu.Unlock()
-					return
+	var measuredMu sync.Mutex // the map in golang is not safe for concurrent read/write
+	var measured map[string]map[string]NetworkState = make(map[string]map[string]NetworkState)
+	for _, pair := range pairs {
+		beego.Info(fmt.Sprintf("Execute the network performance test client Job from cloud [%s] to cloud [%s]", pair.From, pair.To))
+		wg.Add(1)
+		go func(p netTestPair) {
+			defer wg.Done()
+			limiter.acquire(p)
+			defer limiter.release(p)
+
+			netState, bwMeasured, err := executeNetTestClient(clouds[p.From], clouds[p.To])
+			// if there is an error, the unreachable network state is already set, so this pair will be measured again in the next round, and so will it if the bandwidth is not measured.
+			netTestRecords.record(p, time.Now(), err != nil || netState.Rtt >= UnreachableRttMs || !bwMeasured)
+			if err != nil {
+				outErr := fmt.Errorf("Cannot execute the network performance test client Job from cloud [%s] to cloud [%s], error: [%w]", p.From, p.To, err)
+				beego.Error(outErr)
+				errsMu.Lock()
+				errs = append(errs, outErr)
+				errsMu.Unlock()
+				return
+			}
+			if bwMeasured {
+				measuredMu.Lock()
+				if _, exist := measured[p.From]; !exist {
+					measured[p.From] = make(map[string]NetworkState)
 				}
-			}(cloudFrom, cloudTo)
-		}
+				measured[p.From][p.To] = netState
+				measuredMu.Unlock()
+			}
+		}(pair)
 	}
 	wg.Wait()
 
@@ -607,15 +639,16 @@ func executeNetTestClients() error {
 		sumErr := HandleErrSlice(errs)
 		outErr := fmt.Errorf("Failed to execute the network performance test client Jobs, Error: %w", sumErr)
 		beego.Error(outErr)
-		return outErr
+		return measured, outErr
 	}
 
-	beego.Info("Finish executing the network performance test client Job on every net test client VM.")
-	return nil
+	beego.Info("Finish executing the network performance test client Jobs.")
+	return measured, nil
 }
 
 // Run a network performance test Job on cloudFrom to measure the RTT from cloudFrom to cloudTo, and write the RTT value in the MySQL database.
-func executeNetTestClient(cloudFrom, cloudTo Iaas) error {
+// The returned bool is false if the bandwidth is not measured, in which case the previous bandwidth is kept.
+func executeNetTestClient(cloudFrom, cloudTo Iaas) (NetworkState, bool, error) {
 	var errExist bool = false
 	defer func() {
 		if errExist {
@@ -630,24 +663,24 @@ func executeNetTestClient(cloudFrom, cloudTo Iaas) error {
 	dstK8sAppName := getNetTestServerAppName(cloudTo)
 	cliK8sJobName := getNetTestClientAppName(cloudFrom, cloudTo)
 
-	dstK8sApp, err, _ := GetApplication(dstK8sAppName)
+	dstK8sApp, err, _ := GetApplication(KubernetesNamespace, dstK8sAppName)
 	if err != nil {
 		outErr := fmt.Errorf("Get dstK8sApp [%s], error: %w", dstK8sAppName, err)
 		beego.Error(outErr)
 		errExist = true
-		return outErr
+		return NetworkState{}, false, outErr
 	}
 	if len(dstK8sApp.Hosts) == 0 {
 		outErr := fmt.Errorf("len(dstK8sApp.Hosts) is [%d], so we cannot get the IP of the target pod", len(dstK8sApp.Hosts))
 		beego.Error(outErr)
 		errExist = true
-		return outErr
+		return NetworkState{}, false, outErr
 	}
 	if len(dstK8sApp.Hosts[0].PodIP) == 0 {
 		outErr := fmt.Errorf("len(dstK8sApp.Hosts[0].PodIP) is [%d], so we cannot get the IP of the target pod", len(dstK8sApp.Hosts))
 		beego.Error(outErr)
 		errExist = true
-		return outErr
+		return NetworkState{}, false, outErr
 	}
 	dstPodIp := dstK8sApp.Hosts[0].PodIP
 
@@ -657,6 +690,14 @@ func executeNetTestClient(cloudFrom, cloudTo Iaas) error {
 		hostNetwork = hostNetTest
 	}
 
+	imagePullSecrets, err := ImagePullSecretsFor(KubernetesNamespace, NtContainerImage)
+	if err != nil {
+		outErr := fmt.Errorf("Get the imagePullSecrets of image [%s], error: %w", NtContainerImage, err)
+		beego.Error(outErr)
+		errExist = true
+		return NetworkState{}, false, outErr
+	}
+
 	// For a job I do not need to set the labels and selectors. If I want to do it, I can set `.spec.manualSelector: true` in the job's spec
 	var job *batchv1.Job = &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
@@ -666,9 +707,10 @@ func executeNetTestClient(cloudFrom, cloudTo Iaas) error {
 		Spec: batchv1.JobSpec{
 			Template: apiv1.PodTemplateSpec{
 				Spec: apiv1.PodSpec{
-					HostNetwork:   hostNetwork,
-					RestartPolicy: apiv1.RestartPolicyOnFailure,
-					NodeName:      srcK8sNodeName, // execute this job on the network test client VM of the cloudFrom
+					HostNetwork:      hostNetwork,
+					RestartPolicy:    apiv1.RestartPolicyOnFailure,
+					NodeName:         srcK8sNodeName, // execute this job on the network test client VM of the cloudFrom
+					ImagePullSecrets: imagePullSecrets,
 					// Tolerations:   []apiv1.Toleration{NetTestToleration}, // toleration is not necessary, I think because our taint is NoSchedule, not NoExecute, and we specify the NodeName.
 					Containers: []apiv1.Container{
 						apiv1.Container{
@@ -676,10 +718,10 @@ func executeNetTestClient(cloudFrom, cloudTo Iaas) error {
 							Image:           NtContainerImage,
 							ImagePullPolicy: apiv1.PullIfNotPresent,
 							// My test docker run command is:
-							// docker run -d --entrypoint "bash"  mcnettest:latest client.sh "192.168.100.136" "NOKIA7" "192.168.100.136" "3306" "multicloud" 'AAUproxmox1234!@#' "multi_cloud" "NOKIA8"
-							// The client.sh is `net-perf-container-image/client.sh`
+							// docker run -d --entrypoint "bash"  mcnettest:latest client.sh "192.168.100.136" "NOKIA7" "NOKIA8"
+							// The client.sh is `net-perf-container-image/client.sh`. It prints the result, and we read it from the logs and save it in the NetStateStore, so the client does not need to access the store.
 							Command: []string{"bash", "client.sh"},
-							Args:    []string{dstPodIp, cloudTo.ShowName(), MySqlIp, MySqlPort, MySqlUser, MySqlPasswd, NetPerfDbName, cloudFrom.ShowName()},
+							Args:    []string{dstPodIp, cloudTo.ShowName(), cloudFrom.ShowName()},
 						},
 					},
 				},
@@ -693,7 +735,7 @@ func executeNetTestClient(cloudFrom, cloudTo Iaas) error {
 		outErr := fmt.Errorf("CreateJob [%v], error: [%w]", job, err)
 		beego.Error(outErr)
 		errExist = true
-		return outErr
+		return NetworkState{}, false, outErr
 	}
 
 	beego.Info(fmt.Sprintf("Job [%s/%s] is created.", createdJob.Namespace, createdJob.Name))
@@ -703,36 +745,95 @@ func executeNetTestClient(cloudFrom, cloudTo Iaas) error {
 		outErr := fmt.Errorf("Wait for the Job [%s/%s] completed, error: %w", createdJob.Namespace, createdJob.Name, err)
 		beego.Error(outErr)
 		errExist = true
-		return outErr
+		return NetworkState{}, false, outErr
 	}
 	beego.Info(fmt.Sprintf("The Job [%s/%s] is already completed.", createdJob.Namespace, createdJob.Name))
 
-	return nil
+	logs, err := GetJobLogs(createdJob.Namespace, createdJob.Name)
+	if err != nil {
+		outErr := fmt.Errorf("Get the logs of the Job [%s/%s], error: %w", createdJob.Namespace, createdJob.Name, err)
+		beego.Error(outErr)
+		errExist = true
+		return NetworkState{}, false, outErr
+	}
+	netState, err := parseNetTestResult(logs)
+	if err != nil {
+		outErr := fmt.Errorf("Parse the result of the Job [%s/%s], error: %w", createdJob.Namespace, createdJob.Name, err)
+		beego.Error(outErr)
+		errExist = true
+		return NetworkState{}, false, outErr
+	}
+	bwMeasured := netState.BandwidthMbps != NotMeasuredBwMbps
+	if !bwMeasured {
+		netState.BandwidthMbps = previousBandwidth(cloudFrom.ShowName(), cloudTo.ShowName())
+		beego.Warn(fmt.Sprintf("The bandwidth from [%s] to [%s] is not measured, so we keep the previous bandwidth [%g] Mbps.", cloudFrom.ShowName(), cloudTo.ShowName(), netState.BandwidthMbps))
+	}
+	if err := netStateStore.SetNetState(cloudFrom.ShowName(), cloudTo.ShowName(), netState); err != nil {
+		outErr := fmt.Errorf("Save the network state from [%s] to [%s], error: %w", cloudFrom.ShowName(), cloudTo.ShowName(), err)
+		beego.Error(outErr)
+		errExist = true
+		return NetworkState{}, false, outErr
+	}
+	beego.Info(fmt.Sprintf("The network state from [%s] to [%s] is %+v.", cloudFrom.ShowName(), cloudTo.ShowName(), netState))
+
+	return netState, bwMeasured, nil
+}
+
+// the bandwidth from a cloud to another in the NetStateStore, which is UnreachableBwMbps if it cannot be read.
+func previousBandwidth(nameCloudFrom, nameCloudTo string) float64 {
+	netStates, err := GetNetStateOneCloud(nameCloudFrom)
+	if err != nil {
+		beego.Error(fmt.Sprintf("Get the network state from cloud [%s], error: %s", nameCloudFrom, err.Error()))
+		return UnreachableBwMbps
+	}
+	if netState, exist := netStates[nameCloudTo]; exist {
+		return netState.BandwidthMbps
+	}
+	return UnreachableBwMbps
+}
+
+// The last line starting with netTestResultPrefix in the logs of client.sh is the result, like:
+// MCM_NET_RESULT rtt_ms=0.458 bandwidth_mbps=933
+func parseNetTestResult(logs string) (NetworkState, error) {
+	var resultLine string
+	for _, line := range strings.Split(logs, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, netTestResultPrefix) {
+			resultLine = line
+		}
+	}
+	if len(resultLine) == 0 {
+		return NetworkState{}, fmt.Errorf("no line starts with [%s] in the logs", netTestResultPrefix)
+	}
+
+	var netState NetworkState
+	if _, err := fmt.Sscanf(resultLine, netTestResultPrefix+" rtt_ms=%g bandwidth_mbps=%g", &netState.Rtt, &netState.BandwidthMbps); err != nil {
+		return NetworkState{}, fmt.Errorf("parse the result line [%s], error: %w", resultLine, err)
+	}
+	return netState, nil
 }
 
 // delete all network performance test clients
-func deleteNetTestClients() error {
-	beego.Info("Start to delete the network performance test client Job on every net test client VM.")
+func deleteNetTestClients(clouds map[string]Iaas, pairs []netTestPair) error {
+	beego.Info("Start to delete the network performance test client Jobs of the measured pairs of clouds.")
 
 	// Do it in parallel
 	var wg sync.WaitGroup
 	var errsMu sync.Mutex // the slice in golang is not safe for concurrent read/write
 	var errs []error
-	for _, cloudFrom := range Clouds {
-		for _, cloudTo := range Clouds {
-			wg.Add(1)
-			go func(cF Iaas, cT Iaas) {
-				defer wg.Done()
-				if err := deleteNetTestClient(cF, cT); err != nil {
-					outErr := fmt.Errorf("Cannot delete the network test client from cloud [%s] to cloud [%s], error: [%w]", cF.ShowName(), cT.ShowName(), err)
-					beego.Error(outErr)
-					errsMu.Lock()
-					errs = append(errs, outErr)
-					errsMu.Unlock()
-					return
-				}
-			}(cloudFrom, cloudTo)
-		}
+	for _, pair := range pairs {
+		wg.Add(1)
+		go func(cF Iaas, cT Iaas) {
+			defer wg.Done()
+			if err := deleteNetTestClient(cF, cT); err != nil {
+				outErr := fmt.Errorf("Cannot delete the network test client from cloud [%s] to cloud [%s], error: [%w]", cF.ShowName(), cT.ShowName(), err)
+				beego.Error(outErr)
+				errsMu.Lock()
+				errs = append(errs, outErr)
+				errsMu.Unlock()
+				return
+			}
+		}(clouds[pair.From], clouds[pair.To])
 	}
 	wg.Wait()
 
@@ -743,7 +844,7 @@ func deleteNetTestClients() error {
 		return outErr
 	}
 
-	beego.Info("Finish deleting the network performance test client Job on every net test client VM.")
+	beego.Info("Finish deleting the network performance test client Jobs of the measured pairs of clouds.")
 	return nil
 }
 
@@ -759,29 +860,8 @@ func deleteNetTestClient(cloudFrom, cloudTo Iaas) error {
 	return nil
 }
 
-// when a cloud is cannot access another, in the database, we set a very big RTT value between them.
+// when a cloud is cannot access another, we set a very big RTT value and a zero bandwidth between them.
 // we only need to do it in executeNetTestClient, no need for runNetTestServer, because if the server does not work, the client will fail, so doing it only for clients is enough.
 func setUnreachableRtt(nameCloudFrom, nameCloudTo string) error {
-	db, err := NewMySqlCli()
-	if err != nil {
-		outErr := fmt.Errorf("Create MySQL client, error [%w].", err)
-		beego.Error(outErr)
-		return outErr
-	}
-	defer db.Close()
-
-	query := fmt.Sprintf("update %s.%s set rtt_ms=%g where %s='%s'", NetPerfDbName, nameCloudFrom, UnreachableRttMs, DbFieldCloudName, nameCloudTo)
-
-	// without timeout, this request may be stuck forever if there are some problems
-	ctx, cancel := context.WithTimeout(context.Background(), ReqShortTimeout)
-	defer cancel()
-	result, err := db.QueryContext(ctx, query)
-	if err != nil {
-		outErr := fmt.Errorf("Query [%s], error [%w].", query, err)
-		beego.Error(outErr)
-		return outErr
-	}
-	defer result.Close()
-
-	return nil
+	return netStateStore.SetNetState(nameCloudFrom, nameCloudTo, NetworkState{Rtt: UnreachableRttMs, BandwidthMbps: UnreachableBwMbps})
 }
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego"
+)
+
+/**
+NOTE:
+
+Measuring every pair of clouds in every period is expensive, because it needs N*N client Jobs. Therefore, MeasNetPerf only measures the following pairs:
+1. the pairs that have never been measured, including the pairs involving newly added clouds;
+2. the pairs whose last result was unreachable;
+3. the pairs measured more than NetTestStaleSec ago.
+The network test servers keep running between rounds, and the number of client Jobs running at the same time involving a cloud is limited by NetTestCloudConcurrency.
+*/
+
+const (
+	DefaultNetTestStaleSec         int = 1800
+	DefaultNetTestCloudConcurrency int = 2
+)
+
+var (
+	NetTestStaleSec         int = DefaultNetTestStaleSec
+	NetTestCloudConcurrency int = DefaultNetTestCloudConcurrency
+)
+
+// a pair of clouds to measure, from the client on cloud From to the server on cloud To
+type netTestPair struct {
+	From string
+	To   string
+}
+
+type netTestRecord struct {
+	measuredAt  time.Time
+	unreachable bool
+}
+
+// netTestTracker remembers when every pair of clouds was measured last time and whether it was reachable.
+type netTestTracker struct {
+	mu          sync.Mutex
+	records     map[netTestPair]netTestRecord
+	knownClouds []string // the clouds in the last round, sorted
+}
+
+func newNetTestTracker() *netTestTracker {
+	return &netTestTracker{records: make(map[netTestPair]netTestRecord)}
+}
+
+var netTestRecords *netTestTracker = newNetTestTracker()
+
+// read the configurations of the incremental network measurement
+func initNetTestSchedConfig() {
+	if sec, err := beego.AppConfig.Int("NetTestStaleSec"); err == nil && sec > 0 {
+		NetTestStaleSec = sec
+	}
+	if concurrency, err := beego.AppConfig.Int("NetTestCloudConcurrency"); err == nil && concurrency > 0 {
+		NetTestCloudConcurrency = concurrency
+	}
+	beego.Info(fmt.Sprintf("Incremental network measurement: a pair of clouds is stale after %d seconds, and at most %d client Jobs involving a cloud run at the same time.", NetTestStaleSec, NetTestCloudConcurrency))
+	// every client Job to a cloud needs an idle iperf3 server on it, otherwise it waits and retries
+	if NetTestCloudConcurrency > netTestIperfServers {
+		beego.Warn(fmt.Sprintf("NetTestCloudConcurrency [%d] is more than the [%d] iperf3 servers in a network test server, so some client Jobs may find all servers busy and report the bandwidth as not measured.", NetTestCloudConcurrency, netTestIperfServers))
+	}
+}
+
+// forget all records, used when the NetStateStore is reset.
+func (t *netTestTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.records = make(map[netTestPair]netTestRecord)
+	t.knownClouds = nil
+}
+
+func (t *netTestTracker) record(pair netTestPair, measuredAt time.Time, unreachable bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.records[pair] = netTestRecord{measuredAt: measuredAt, unreachable: unreachable}
+}
+
+// Update the known clouds, and return whether they are changed. The records of the removed clouds are deleted.
+func (t *netTestTracker) updateClouds(cloudNames []string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sorted := append([]string{}, cloudNames...)
+	sort.Strings(sorted)
+
+	changed := len(sorted) != len(t.knownClouds)
+	for i := 0; !changed && i < len(sorted); i++ {
+		changed = sorted[i] != t.knownClouds[i]
+	}
+	if !changed {
+		return false
+	}
+
+	var exist map[string]bool = make(map[string]bool)
+	for _, name := range sorted {
+		exist[name] = true
+	}
+	for pair := range t.records {
+		if !exist[pair.From] || !exist[pair.To] {
+			delete(t.records, pair)
+		}
+	}
+	t.knownClouds = sorted
+	return true
+}
+
+// Get the pairs among the clouds that need to be measured, sorted by the names of clouds.
+func (t *netTestTracker) pairsToMeasure(cloudNames []string, now time.Time, staleAfter time.Duration) []netTestPair {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var pairs []netTestPair
+	for _, from := range cloudNames {
+		for _, to := range cloudNames {
+			pair := netTestPair{From: from, To: to}
+			record, measured := t.records[pair]
+			if !measured || record.unreachable || now.Sub(record.measuredAt) >= staleAfter {
+				pairs = append(pairs, pair)
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].From != pairs[j].From {
+			return pairs[i].From < pairs[j].From
+		}
+		return pairs[i].To < pairs[j].To
+	})
+	return pairs
+}
+
+// the clouds involved in the pairs, sorted
+func netTestPairClouds(pairs []netTestPair) []string {
+	var exist map[string]bool = make(map[string]bool)
+	var cloudNames []string
+	for _, pair := range pairs {
+		for _, name := range []string{pair.From, pair.To} {
+			if !exist[name] {
+				exist[name] = true
+				cloudNames = append(cloudNames, name)
+			}
+		}
+	}
+	sort.Strings(cloudNames)
+	return cloudNames
+}
+
+// cloudLimiter limits the number of client Jobs involving every cloud running at the same time.
+type cloudLimiter struct {
+	slots map[string]chan struct{}
+}
+
+func newCloudLimiter(cloudNames []string, concurrency int) *cloudLimiter {
+	var slots map[string]chan struct{} = make(map[string]chan struct{})
+	for _, name := range cloudNames {
+		slots[name] = make(chan struct{}, concurrency)
+	}
+	return &cloudLimiter{slots: slots}
+}
+
+// the clouds of a pair, in which the order is fixed to avoid deadlocks
+func (l *cloudLimiter) pairClouds(pair netTestPair) []string {
+	if pair.From == pair.To {
+		return []string{pair.From}
+	}
+	if pair.From < pair.To {
+		return []string{pair.From, pair.To}
+	}
+	return []string{pair.To, pair.From}
+}
+
+func (l *cloudLimiter) acquire(pair netTestPair) {
+	for _, name := range l.pairClouds(pair) {
+		l.slots[name] <- struct{}{}
+	}
+}
+
+func (l *cloudLimiter) release(pair netTestPair) {
+	for _, name := range l.pairClouds(pair) {
+		<-l.slots[name]
+	}
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInnerPairsToMeasure(t *testing.T) {
+	now := time.Now()
+	staleAfter := 30 * time.Minute
+
+	tracker := newNetTestTracker()
+	tracker.updateClouds([]string{"c1", "c2"})
+	tracker.record(netTestPair{From: "c1", To: "c1"}, now.Add(-time.Minute), false)
+	tracker.record(netTestPair{From: "c1", To: "c2"}, now.Add(-time.Hour), false)
+	tracker.record(netTestPair{From: "c2", To: "c1"}, now.Add(-time.Minute), true)
+	tracker.record(netTestPair{From: "c2", To: "c2"}, now.Add(-time.Minute), false)
+
+	testCases := []struct {
+		name           string
+		cloudNames     []string
+		expectedResult []netTestPair
+	}{
+		{
+			name:       "stale and unreachable",
+			cloudNames: []string{"c1", "c2"},
+			expectedResult: []netTestPair{
+				{From: "c1", To: "c2"},
+				{From: "c2", To: "c1"},
+			},
+		},
+		{
+			name:       "new cloud",
+			cloudNames: []string{"c1", "c2", "c3"},
+			expectedResult: []netTestPair{
+				{From: "c1", To: "c2"},
+				{From: "c1", To: "c3"},
+				{From: "c2", To: "c1"},
+				{From: "c2", To: "c3"},
+				{From: "c3", To: "c1"},
+				{From: "c3", To: "c2"},
+				{From: "c3", To: "c3"},
+			},
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		actualResult := tracker.pairsToMeasure(testCase.cloudNames, now, staleAfter)
+		assert.Equal(t, testCase.expectedResult, actualResult, fmt.Sprintf("%s: result is not expected", testCase.name))
+	}
+}
+
+func TestInnerUpdateClouds(t *testing.T) {
+	tracker := newNetTestTracker()
+	assert.True(t, tracker.updateClouds([]string{"c2", "c1"}))
+	assert.False(t, tracker.updateClouds([]string{"c1", "c2"}))
+
+	tracker.record(netTestPair{From: "c1", To: "c2"}, time.Now(), false)
+	tracker.record(netTestPair{From: "c1", To: "c1"}, time.Now(), false)
+
+	// the records of the removed cloud are deleted
+	assert.True(t, tracker.updateClouds([]string{"c1"}))
+	assert.Equal(t, 1, len(tracker.records))
+	_, exist := tracker.records[netTestPair{From: "c1", To: "c1"}]
+	assert.True(t, exist)
+}
+
+func TestInnerCloudLimiter(t *testing.T) {
+	cloudNames := []string{"c1", "c2", "c3"}
+	concurrency := 2
+	limiter := newCloudLimiter(cloudNames, concurrency)
+
+	var running map[string]*int32 = make(map[string]*int32)
+	for _, name := range cloudNames {
+		running[name] = new(int32)
+	}
+	var exceeded int32
+
+	var wg sync.WaitGroup
+	for _, from := range cloudNames {
+		for _, to := range cloudNames {
+			wg.Add(1)
+			go func(p netTestPair) {
+				defer wg.Done()
+				limiter.acquire(p)
+				defer limiter.release(p)
+				for _, name := range limiter.pairClouds(p) {
+					if atomic.AddInt32(running[name], 1) > int32(concurrency) {
+						atomic.StoreInt32(&exceeded, 1)
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				for _, name := range limiter.pairClouds(p) {
+					atomic.AddInt32(running[name], -1)
+				}
+			}(netTestPair{From: from, To: to})
+		}
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(0), exceeded, "the concurrency of a cloud is exceeded")
+}
@@ -1,7 +1,6 @@
 package models
 
 import (
-	"context"
 	"fmt"
 	"sync"
 
@@ -9,10 +8,11 @@ import (
 )
 
 type NetworkState struct {
-	Rtt float64 `json:"rtt"` // Round-Trip Time, unit millisecond (ms)
+	Rtt           float64 `json:"rtt"`           // Round-Trip Time, unit millisecond (ms)
+	BandwidthMbps float64 `json:"bandwidthMbps"` // measured TCP throughput, unit Mbps. 0 means unreachable or never measured.
 }
 
-// Check network state from MySQL and return the result as a matrix.
+// Check network state from the NetStateStore and return the result as a matrix.
 func GetNetState() (map[string]map[string]NetworkState, error) {
 	// TODO: Do not add this if, because without this, multi-cloud manager is more flexible.
 	//if !NetTestFuncOn {
@@ -30,7 +30,7 @@ func GetNetState() (map[string]map[string]NetworkState, error) {
 	var allNetStMu sync.Mutex // the map in golang is not safe for concurrent read/write
 	var errsMu sync.Mutex     // the slice in golang is not safe for concurrent read/write
 	var errs []error
-	for name, _ := range Clouds {
+	for _, name := range IaasNames() {
 		beego.Info(fmt.Sprintf("check network state from cloud %s", name))
 		wg.Add(1)
 		go func(cloudName string) {
@@ -62,42 +62,7 @@ func GetNetState() (map[string]map[string]NetworkState, error) {
 	return allNetSt, nil
 }
 
-// Check network state in the MySQL database from the table of one cloud.
+// Check network state from one cloud to every cloud in the NetStateStore.
 func GetNetStateOneCloud(cloudName string) (map[string]NetworkState, error) {
-	db, err := NewMySqlCli()
-	if err != nil {
-		outErr := fmt.Errorf("Create MySQL client, error [%w].", err)
-		beego.Error(outErr)
-		return nil, outErr
-	}
-	defer db.Close()
-
-	query := fmt.Sprintf("select * from %s.%s", NetPerfDbName, cloudName)
-
-	// without timeout, this request may be stuck forever if there are some problems
-	ctx, cancel := context.WithTimeout(context.Background(), ReqShortTimeout)
-	defer cancel()
-	result, err := db.QueryContext(ctx, query)
-	if err != nil {
-		outErr := fmt.Errorf("Query [%s], error [%w].", query, err)
-		beego.Error(outErr)
-		return nil, outErr
-	}
-	defer result.Close()
-
-	var netStates map[string]NetworkState = make(map[string]NetworkState)
-	for result.Next() {
-		var targetCloudName string
-		var rtt float64
-		if err := result.Scan(&targetCloudName, &rtt); err != nil {
-			outErr := fmt.Errorf("Query [%s], result.Scan, error [%w].", query, err)
-			beego.Error(outErr)
-			beego.Error(fmt.Sprintf("Current netStates: %v", netStates))
-			return nil, outErr
-		}
-		netStates[targetCloudName] = NetworkState{Rtt: rtt}
-	}
-
-	beego.Info(fmt.Sprintf("Query [%s] successfully.", query))
-	return netStates, nil
+	return netStateStore.GetNetStateOneCloud(cloudName)
 }
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/astaxie/beego"
+)
+
+/**
+NOTE:
+
+The NetStateStore only keeps the latest network state between every two clouds, and it is overwritten by every measurement.
+To see the trends of the network state, after every measurement round, we append the network states of the pairs of clouds measured in the round with the timestamp into the history. The pairs that are not measured, fail, or whose bandwidth is not measured are not appended, so the samples in the history are all real measurements.
+In MySQL, the history is in its own database NetHistDbName, because NetPerfDbName is deleted every time emcontroller starts, but the history should be kept.
+The raw samples older than NetHistRawHours are downsampled into hourly averages, and all samples older than NetHistRetentionHours are deleted.
+*/
+
+const (
+	NetHistDbName    string = "multi_cloud_history"
+	NetHistTableName string = "net_state_history"
+
+	DbFieldSrcCloudName string = "src_cloud_name"
+	DbFieldDstCloudName string = "dst_cloud_name"
+	DbFieldMeasuredAt   string = "measured_at_ms" // unix timestamp, unit millisecond (ms)
+	DbFieldDownsampled  string = "downsampled"    // 1 means that this row is the average of the raw samples in an hour
+
+	DefaultNetHistRetentionHours int = 168 // 7 days
+	DefaultNetHistRawHours       int = 24
+
+	// methods to smooth the network state in a time window
+	NetSmoothLast string = "last" // the latest measurement, same as not using the history
+	NetSmoothP95  string = "p95"  // the 95th percentile of RTT and the 5th percentile of bandwidth, i.e., the bad network state in 95% of time
+	NetSmoothEwma string = "ewma" // exponentially weighted moving average
+
+	DefaultNetSmoothWindowSec int     = 3600
+	DefaultNetSmoothEwmaAlpha float64 = 0.3
+
+	downsampleIntervalMs  int64         = 3600 * 1000
+	netHistCompactTimeout time.Duration = time.Minute // downsampling may take longer than ReqShortTimeout
+)
+
+var (
+	NetHistRetentionHours int     = DefaultNetHistRetentionHours
+	NetHistRawHours       int     = DefaultNetHistRawHours
+	NetSmoothMethod       string  = NetSmoothLast
+	NetSmoothWindowSec    int     = DefaultNetSmoothWindowSec
+	NetSmoothEwmaAlpha    float64 = DefaultNetSmoothEwmaAlpha
+)
+
+// one sample of the network state from a cloud to another
+type NetStateSample struct {
+	SrcCloudName string       `json:"srcCloudName"`
+	DstCloudName string       `json:"dstCloudName"`
+	MeasuredAt   time.Time    `json:"measuredAt"`
+	Downsampled  bool         `json:"downsampled"`
+	NetState     NetworkState `json:"netState"`
+}
+
+// read the configurations of the network state history
+func initNetHistConfig() {
+	if hours, err := beego.AppConfig.Int("NetHistRetentionHours"); err == nil && hours > 0 {
+		NetHistRetentionHours = hours
+	}
+	if hours, err := beego.AppConfig.Int("NetHistRawHours"); err == nil && hours > 0 {
+		NetHistRawHours = hours
+	}
+	if method := beego.AppConfig.String("NetSmoothMethod"); len(method) != 0 {
+		switch method {
+		case NetSmoothLast, NetSmoothP95, NetSmoothEwma:
+			NetSmoothMethod = method
+		default:
+			beego.Error(fmt.Sprintf("Unknown NetSmoothMethod [%s], use [%s].", method, NetSmoothLast))
+		}
+	}
+	if sec, err := beego.AppConfig.Int("NetSmoothWindowSec"); err == nil && sec > 0 {
+		NetSmoothWindowSec = sec
+	}
+	if alpha, err := beego.AppConfig.Float("NetSmoothEwmaAlpha"); err == nil && alpha > 0 && alpha <= 1 {
+		NetSmoothEwmaAlpha = alpha
+	}
+	beego.Info(fmt.Sprintf("Network state history: retention %d hours, raw samples %d hours, smoothing method [%s], window %d seconds, EWMA alpha %g.", NetHistRetentionHours, NetHistRawHours, NetSmoothMethod, NetSmoothWindowSec, NetSmoothEwmaAlpha))
+}
+
+// append the network states measured in this round into the history, and then apply the downsampling and retention.
+// Only the measured pairs are appended, so that a pair not measured in a round does not get a repeated sample of its old state.
+func recordNetHist(measured map[string]map[string]NetworkState) error {
+	now := time.Now()
+	if len(measured) == 0 {
+		beego.Info("No pair of clouds is measured in this round, so no network state history is recorded.")
+	} else {
+		if err := netStateStore.AppendHistory(now, measured); err != nil {
+			outErr := fmt.Errorf("Append the network state history, Error: %w", err)
+			beego.Error(outErr)
+			return outErr
+		}
+		beego.Info(fmt.Sprintf("Recorded the network state history of the pairs measured at [%s].", now.Format(time.RFC3339)))
+	}
+
+	// only the complete hours are downsampled, so that an hour will not be downsampled twice.
+	rawBefore := time.UnixMilli(now.Add(-time.Duration(NetHistRawHours)*time.Hour).UnixMilli() / downsampleIntervalMs * downsampleIntervalMs)
+	retainAfter := now.Add(-time.Duration(NetHistRetentionHours) * time.Hour)
+	if err := netStateStore.CompactHistory(rawBefore, retainAfter); err != nil {
+		outErr := fmt.Errorf("Compact the network state history, Error: %w", err)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	return nil
+}
+
+// Get the network state history in the time range [from, to]. If srcCloudName or dstCloudName is empty, it is not used as a filter. The samples are in time order.
+func GetNetStateHistory(from, to time.Time, srcCloudName, dstCloudName string) ([]NetStateSample, error) {
+	return netStateStore.GetHistory(from, to, srcCloudName, dstCloudName)
+}
+
+// Get the network state smoothed by NetSmoothMethod in the latest NetSmoothWindowSec. The pairs of clouds without history use the latest measurement.
+func GetSmoothedNetState() (map[string]map[string]NetworkState, error) {
+	netStates, err := GetNetState()
+	if err != nil {
+		outErr := fmt.Errorf("Check network state Error: %w", err)
+		beego.Error(outErr)
+		return nil, outErr
+	}
+	if NetSmoothMethod == NetSmoothLast {
+		return netStates, nil
+	}
+
+	to := time.Now()
+	from := to.Add(-time.Duration(NetSmoothWindowSec) * time.Second)
+	samples, err := GetNetStateHistory(from, to, "", "")
+	if err != nil {
+		outErr := fmt.Errorf("Get network state history from [%s] to [%s], error: %w", from.Format(time.RFC3339), to.Format(time.RFC3339), err)
+		beego.Error(outErr)
+		return nil, outErr
+	}
+
+	var pairSamples map[string]map[string][]NetworkState = make(map[string]map[string][]NetworkState)
+	for _, sample := range samples {
+		if _, exist := pairSamples[sample.SrcCloudName]; !exist {
+			pairSamples[sample.SrcCloudName] = make(map[string][]NetworkState)
+		}
+		pairSamples[sample.SrcCloudName][sample.DstCloudName] = append(pairSamples[sample.SrcCloudName][sample.DstCloudName], sample.NetState)
+	}
+
+	for srcCloudName, dsts := range netStates {
+		for dstCloudName := range dsts {
+			history := pairSamples[srcCloudName][dstCloudName]
+			if len(history) == 0 {
+				continue
+			}
+			dsts[dstCloudName] = SmoothNetState(history, NetSmoothMethod, NetSmoothEwmaAlpha)
+		}
+	}
+
+	return netStates, nil
+}
+
+// Smooth the network state samples in time order by the method. alpha is only used by EWMA.
+func SmoothNetState(samples []NetworkState, method string, alpha float64) NetworkState {
+	if len(samples) == 0 {
+		return NetworkState{Rtt: UnreachableRttMs, BandwidthMbps: UnreachableBwMbps}
+	}
+
+	switch method {
+	case NetSmoothP95:
+		rtts := make([]float64, len(samples))
+		bandwidths := make([]float64, len(samples))
+		for i, sample := range samples {
+			rtts[i] = sample.Rtt
+			bandwidths[i] = sample.BandwidthMbps
+		}
+		// large RTT and small bandwidth are bad, so we use the 95th percentile of RTT and the 5th percentile of bandwidth.
+		return NetworkState{Rtt: percentile(rtts, 95), BandwidthMbps: percentile(bandwidths, 5)}
+	case NetSmoothEwma:
+		smoothed := samples[0]
+		for _, sample := range samples[1:] {
+			smoothed.Rtt = alpha*sample.Rtt + (1-alpha)*smoothed.Rtt
+			smoothed.BandwidthMbps = alpha*sample.BandwidthMbps + (1-alpha)*smoothed.BandwidthMbps
+		}
+		return smoothed
+	default:
+		return samples[len(samples)-1]
+	}
+}
+
+// the p-th percentile of values by the nearest-rank method
+func percentile(values []float64, p float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
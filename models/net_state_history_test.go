@@ -0,0 +1,55 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSmoothNetState(t *testing.T) {
+	var samples []NetworkState
+	for i := 1; i <= 20; i++ {
+		samples = append(samples, NetworkState{Rtt: float64(i), BandwidthMbps: float64(100 * i)})
+	}
+
+	testCases := []struct {
+		name           string
+		samples        []NetworkState
+		method         string
+		alpha          float64
+		expectedResult NetworkState
+	}{
+		{
+			name:           "last",
+			samples:        samples,
+			method:         NetSmoothLast,
+			expectedResult: NetworkState{Rtt: 20, BandwidthMbps: 2000},
+		},
+		{
+			name:           "p95",
+			samples:        samples,
+			method:         NetSmoothP95,
+			expectedResult: NetworkState{Rtt: 19, BandwidthMbps: 100},
+		},
+		{
+			name:           "ewma",
+			samples:        []NetworkState{{Rtt: 10, BandwidthMbps: 100}, {Rtt: 20, BandwidthMbps: 200}, {Rtt: 40, BandwidthMbps: 0}},
+			method:         NetSmoothEwma,
+			alpha:          0.5,
+			expectedResult: NetworkState{Rtt: 27.5, BandwidthMbps: 75},
+		},
+		{
+			name:           "no samples",
+			samples:        nil,
+			method:         NetSmoothP95,
+			expectedResult: NetworkState{Rtt: UnreachableRttMs, BandwidthMbps: UnreachableBwMbps},
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		actualResult := SmoothNetState(testCase.samples, testCase.method, testCase.alpha)
+		assert.Equal(t, testCase.expectedResult, actualResult, fmt.Sprintf("%s: result is not expected", testCase.name))
+	}
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/astaxie/beego"
+)
+
+const (
+	NetStateStoreMySql string = "mysql"
+	NetStateStoreFile  string = "file"
+)
+
+// NetStateStore saves the latest network state between every two clouds and its history.
+type NetStateStore interface {
+	// Reset the latest network state among these clouds, in which every cloud is unreachable from every cloud at first. The history is kept.
+	Init(cloudNames []string) error
+	// Add the network state among these clouds that does not exist, which is unreachable at first. The existing network state is not changed.
+	EnsureClouds(cloudNames []string) error
+	// Remove the latest network state from a cloud, and the latest network state from the rest clouds to it. The history is kept.
+	RemoveCloud(cloudName string, restCloudNames []string) error
+	// Get the latest network state from a cloud to every cloud. key: target cloud name.
+	GetNetStateOneCloud(cloudName string) (map[string]NetworkState, error)
+	// Set the latest network state from a cloud to another.
+	SetNetState(srcCloudName, dstCloudName string, netState NetworkState) error
+	// Append the network state between every two clouds measured at a time into the history.
+	AppendHistory(measuredAt time.Time, netStates map[string]map[string]NetworkState) error
+	// Downsample the raw samples before rawBefore into hourly averages, and delete the samples before retainAfter.
+	CompactHistory(rawBefore, retainAfter time.Time) error
+	// Get the history in the time range [from, to] in time order. If srcCloudName or dstCloudName is empty, it is not used as a filter.
+	GetHistory(from, to time.Time, srcCloudName, dstCloudName string) ([]NetStateSample, error)
+}
+
+// MySQL is the default, and it can be changed by the configuration "NetStateStore".
+var netStateStore NetStateStore = &MySqlNetStateStore{}
+
+// Choose the NetStateStore by the configuration "NetStateStore", which is "mysql" by default or "file". The file is set by "NetStateFilePath".
+func newNetStateStoreFromConfig() (NetStateStore, error) {
+	storeType := beego.AppConfig.String("NetStateStore")
+	switch storeType {
+	case "", NetStateStoreMySql:
+		beego.Info("The network state is saved in MySQL.")
+		return &MySqlNetStateStore{}, nil
+	case NetStateStoreFile:
+		path := beego.AppConfig.String("NetStateFilePath")
+		if len(path) == 0 {
+			path = DefaultNetStateFilePath
+		}
+		beego.Info(fmt.Sprintf("The network state is saved in the file [%s].", path))
+		return NewFileNetStateStore(path), nil
+	default:
+		return nil, fmt.Errorf("unsupported NetStateStore [%s], it should be [%s] or [%s]", storeType, NetStateStoreMySql, NetStateStoreFile)
+	}
+}
@@ -0,0 +1,398 @@
+package models
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego"
+)
+
+const DefaultNetStateFilePath string = "data/net_state.json"
+
+// FileNetStateStore saves the network state in files, so that small deployments and tests do not need a MySQL server.
+// All data is kept in memory. The latest network state is small, and it is written into the JSON file after every change.
+// The history grows in every measurement round, so it is in another file with one JSON sample per line, to which new samples are appended,
+// and this file is only rewritten when the history is compacted.
+type FileNetStateStore struct {
+	path        string
+	historyPath string
+	mu          sync.Mutex
+	data        netStateFileData
+}
+
+type netStateFileData struct {
+	Latest  map[string]map[string]NetworkState `json:"latest"`            // key 1: source cloud name, key 2: target cloud name
+	History []NetStateSample                   `json:"history,omitempty"` // in time order. Only in memory, and in the files of old versions, which are moved into the history file.
+}
+
+// The history file is beside the file of the latest network state, e.g., data/net_state.json and data/net_state_history.jsonl.
+func NewFileNetStateStore(path string) *FileNetStateStore {
+	return &FileNetStateStore{
+		path:        path,
+		historyPath: strings.TrimSuffix(path, filepath.Ext(path)) + "_history.jsonl",
+	}
+}
+
+// Load the history in the files if they exist, and reset the latest network state, in which every cloud is unreachable from every cloud at first.
+func (s *FileNetStateStore) Init(cloudNames []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data = netStateFileData{}
+	content, err := os.ReadFile(s.path)
+	if err == nil {
+		if err := json.Unmarshal(content, &s.data); err != nil {
+			outErr := fmt.Errorf("Unmarshal the network state file [%s], error [%w].", s.path, err)
+			beego.Error(outErr)
+			return outErr
+		}
+	} else if !os.IsNotExist(err) {
+		outErr := fmt.Errorf("Read the network state file [%s], error [%w].", s.path, err)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	history, broken, err := readNetHistFile(s.historyPath)
+	if err != nil {
+		return err
+	}
+	// the history in the file of an old version is moved into the history file, and the history file with broken lines is rewritten, so that new samples are not appended after a broken line.
+	needRewrite := len(s.data.History) > 0 || broken
+	s.data.History = append(s.data.History, history...)
+	sortNetHist(s.data.History)
+	if needRewrite {
+		if err := s.saveHistory(s.data.History); err != nil {
+			return err
+		}
+	}
+
+	s.data.Latest = make(map[string]map[string]NetworkState)
+	for _, cloudName := range cloudNames {
+		s.data.Latest[cloudName] = make(map[string]NetworkState)
+		for _, targetCloudName := range cloudNames {
+			s.data.Latest[cloudName][targetCloudName] = NetworkState{Rtt: UnreachableRttMs, BandwidthMbps: UnreachableBwMbps}
+		}
+	}
+
+	return s.save()
+}
+
+// read the samples in the history file. If emcontroller stopped when appending, the last line may be broken, so the broken lines are skipped with a warning.
+func readNetHistFile(path string) ([]NetStateSample, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		outErr := fmt.Errorf("Open the network state history file [%s], error [%w].", path, err)
+		beego.Error(outErr)
+		return nil, false, outErr
+	}
+	defer file.Close()
+
+	var samples []NetStateSample
+	var broken bool
+	scanner := bufio.NewScanner(file)
+	var lineNum int
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var sample NetStateSample
+		if err := json.Unmarshal(line, &sample); err != nil {
+			beego.Warning(fmt.Sprintf("Skip line [%d] of the network state history file [%s], error [%s].", lineNum, path, err.Error()))
+			broken = true
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	if err := scanner.Err(); err != nil {
+		outErr := fmt.Errorf("Read the network state history file [%s], error [%w].", path, err)
+		beego.Error(outErr)
+		return nil, false, outErr
+	}
+	return samples, broken, nil
+}
+
+func (s *FileNetStateStore) EnsureClouds(cloudNames []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.Latest == nil {
+		s.data.Latest = make(map[string]map[string]NetworkState)
+	}
+	for _, cloudName := range cloudNames {
+		if _, exist := s.data.Latest[cloudName]; !exist {
+			s.data.Latest[cloudName] = make(map[string]NetworkState)
+		}
+		for _, targetCloudName := range cloudNames {
+			if _, exist := s.data.Latest[cloudName][targetCloudName]; !exist {
+				s.data.Latest[cloudName][targetCloudName] = NetworkState{Rtt: UnreachableRttMs, BandwidthMbps: UnreachableBwMbps}
+			}
+		}
+	}
+
+	return s.save()
+}
+
+func (s *FileNetStateStore) RemoveCloud(cloudName string, restCloudNames []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.data.Latest, cloudName)
+	for _, srcCloudName := range restCloudNames {
+		delete(s.data.Latest[srcCloudName], cloudName)
+	}
+
+	return s.save()
+}
+
+// write the latest network state into the file. The history is not in it.
+// the caller should hold the lock.
+func (s *FileNetStateStore) save() error {
+	content, err := json.Marshal(netStateFileData{Latest: s.data.Latest})
+	if err != nil {
+		outErr := fmt.Errorf("Marshal the network state, error [%w].", err)
+		beego.Error(outErr)
+		return outErr
+	}
+	return writeNetStateFile(s.path, content)
+}
+
+// rewrite the history file with these samples.
+// the caller should hold the lock.
+func (s *FileNetStateStore) saveHistory(samples []NetStateSample) error {
+	content, err := marshalNetHistLines(samples)
+	if err != nil {
+		return err
+	}
+	return writeNetStateFile(s.historyPath, content)
+}
+
+// every sample is a line of JSON
+func marshalNetHistLines(samples []NetStateSample) ([]byte, error) {
+	var content []byte
+	for _, sample := range samples {
+		line, err := json.Marshal(sample)
+		if err != nil {
+			outErr := fmt.Errorf("Marshal the network state sample %+v, error [%w].", sample, err)
+			beego.Error(outErr)
+			return nil, outErr
+		}
+		content = append(content, line...)
+		content = append(content, '\n')
+	}
+	return content, nil
+}
+
+// write the content into a temporary file and rename it, so that the file will not be broken if emcontroller stops when writing.
+func writeNetStateFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		outErr := fmt.Errorf("Create the directory of the network state file [%s], error [%w].", path, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0644); err != nil {
+		outErr := fmt.Errorf("Write the network state file [%s], error [%w].", tmpPath, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		outErr := fmt.Errorf("Rename [%s] to [%s], error [%w].", tmpPath, path, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	return nil
+}
+
+func (s *FileNetStateStore) GetNetStateOneCloud(cloudName string) (map[string]NetworkState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dsts, exist := s.data.Latest[cloudName]
+	if !exist {
+		outErr := fmt.Errorf("The network state from cloud [%s] does not exist.", cloudName)
+		beego.Error(outErr)
+		return nil, outErr
+	}
+	var netStates map[string]NetworkState = make(map[string]NetworkState)
+	for targetCloudName, netState := range dsts {
+		netStates[targetCloudName] = netState
+	}
+	return netStates, nil
+}
+
+func (s *FileNetStateStore) SetNetState(srcCloudName, dstCloudName string, netState NetworkState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// same as the "update" in MySQL, only the existing pairs of clouds are set.
+	if _, exist := s.data.Latest[srcCloudName][dstCloudName]; !exist {
+		outErr := fmt.Errorf("The network state from cloud [%s] to cloud [%s] does not exist.", srcCloudName, dstCloudName)
+		beego.Error(outErr)
+		return outErr
+	}
+	s.data.Latest[srcCloudName][dstCloudName] = netState
+	return s.save()
+}
+
+func (s *FileNetStateStore) AppendHistory(measuredAt time.Time, netStates map[string]map[string]NetworkState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var samples []NetStateSample
+	for srcCloudName, dsts := range netStates {
+		for dstCloudName, netState := range dsts {
+			samples = append(samples, NetStateSample{
+				SrcCloudName: srcCloudName,
+				DstCloudName: dstCloudName,
+				MeasuredAt:   measuredAt,
+				NetState:     netState,
+			})
+		}
+	}
+	sortNetHist(samples)
+
+	// only the new samples are written, appended to the history file.
+	content, err := marshalNetHistLines(samples)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.historyPath), 0755); err != nil {
+		outErr := fmt.Errorf("Create the directory of the network state history file [%s], error [%w].", s.historyPath, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	file, err := os.OpenFile(s.historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		outErr := fmt.Errorf("Open the network state history file [%s], error [%w].", s.historyPath, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	defer file.Close()
+	if _, err := file.Write(content); err != nil {
+		outErr := fmt.Errorf("Append to the network state history file [%s], error [%w].", s.historyPath, err)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	s.data.History = append(s.data.History, samples...)
+	sortNetHist(s.data.History)
+	return nil
+}
+
+func (s *FileNetStateStore) CompactHistory(rawBefore, retainAfter time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// CompactHistory is called after every measurement round, but there is something to downsample or delete only about once an hour, and the history file is only rewritten then.
+	if !netHistNeedsCompaction(s.data.History, rawBefore, retainAfter) {
+		return nil
+	}
+	compacted := compactNetHistSamples(s.data.History, rawBefore, retainAfter)
+	if err := s.saveHistory(compacted); err != nil {
+		return err
+	}
+	s.data.History = compacted
+	return nil
+}
+
+func (s *FileNetStateStore) GetHistory(from, to time.Time, srcCloudName, dstCloudName string) ([]NetStateSample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var samples []NetStateSample = []NetStateSample{}
+	for _, sample := range s.data.History {
+		if sample.MeasuredAt.Before(from) || sample.MeasuredAt.After(to) {
+			continue
+		}
+		if len(srcCloudName) != 0 && sample.SrcCloudName != srcCloudName {
+			continue
+		}
+		if len(dstCloudName) != 0 && sample.DstCloudName != dstCloudName {
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+// sort the samples in time order, and the samples at the same time are sorted by the names of clouds.
+func sortNetHist(samples []NetStateSample) {
+	sort.SliceStable(samples, func(i, j int) bool {
+		if !samples[i].MeasuredAt.Equal(samples[j].MeasuredAt) {
+			return samples[i].MeasuredAt.Before(samples[j].MeasuredAt)
+		}
+		if samples[i].SrcCloudName != samples[j].SrcCloudName {
+			return samples[i].SrcCloudName < samples[j].SrcCloudName
+		}
+		return samples[i].DstCloudName < samples[j].DstCloudName
+	})
+}
+
+// whether compactNetHistSamples will downsample or delete any of the samples.
+func netHistNeedsCompaction(samples []NetStateSample, rawBefore, retainAfter time.Time) bool {
+	for _, sample := range samples {
+		if sample.MeasuredAt.Before(retainAfter) || (!sample.Downsampled && sample.MeasuredAt.Before(rawBefore)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Downsample the raw samples before rawBefore into hourly averages of every pair of clouds, and delete the samples before retainAfter.
+// It does the same as the SQL in MySqlNetStateStore.CompactHistory.
+func compactNetHistSamples(samples []NetStateSample, rawBefore, retainAfter time.Time) []NetStateSample {
+	type hourKey struct {
+		src, dst string
+		hourMs   int64
+	}
+	type hourSum struct {
+		rtt, bandwidth float64
+		num            int
+	}
+
+	var kept []NetStateSample
+	var sums map[hourKey]*hourSum = make(map[hourKey]*hourSum)
+	for _, sample := range samples {
+		if sample.Downsampled || !sample.MeasuredAt.Before(rawBefore) {
+			kept = append(kept, sample)
+			continue
+		}
+		key := hourKey{src: sample.SrcCloudName, dst: sample.DstCloudName, hourMs: sample.MeasuredAt.UnixMilli() / downsampleIntervalMs * downsampleIntervalMs}
+		if _, exist := sums[key]; !exist {
+			sums[key] = &hourSum{}
+		}
+		sums[key].rtt += sample.NetState.Rtt
+		sums[key].bandwidth += sample.NetState.BandwidthMbps
+		sums[key].num++
+	}
+	for key, sum := range sums {
+		kept = append(kept, NetStateSample{
+			SrcCloudName: key.src,
+			DstCloudName: key.dst,
+			MeasuredAt:   time.UnixMilli(key.hourMs),
+			Downsampled:  true,
+			NetState:     NetworkState{Rtt: sum.rtt / float64(sum.num), BandwidthMbps: sum.bandwidth / float64(sum.num)},
+		})
+	}
+
+	var retained []NetStateSample = []NetStateSample{}
+	for _, sample := range kept {
+		if !sample.MeasuredAt.Before(retainAfter) {
+			retained = append(retained, sample)
+		}
+	}
+	sortNetHist(retained)
+	return retained
+}
@@ -0,0 +1,475 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego"
+)
+
+const mySqlMaxIdentLen int = 64
+
+// MySqlNetStateStore saves the network state in MySQL.
+// The latest network state from every cloud is in the table named by the cloud in the database NetPerfDbName, and the history is in the table NetHistTableName in the database NetHistDbName.
+type MySqlNetStateStore struct{}
+
+// Quote a name of a MySQL database or table, so that the names of clouds cannot be used to inject SQL.
+func mySqlIdent(name string) (string, error) {
+	if len(name) == 0 || len(name) > mySqlMaxIdentLen {
+		return "", fmt.Errorf("the length of MySQL identifier [%s] is [%d], but it should be in [1, %d]", name, len(name), mySqlMaxIdentLen)
+	}
+	if strings.ContainsRune(name, 0) || strings.HasSuffix(name, " ") {
+		return "", fmt.Errorf("MySQL identifier [%s] cannot include the NUL character or end with a space", name)
+	}
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`", nil
+}
+
+// the quoted "database.table"
+func mySqlTable(dbName, tableName string) (string, error) {
+	quotedDb, err := mySqlIdent(dbName)
+	if err != nil {
+		return "", err
+	}
+	quotedTable, err := mySqlIdent(tableName)
+	if err != nil {
+		return "", err
+	}
+	return quotedDb + "." + quotedTable, nil
+}
+
+// Recreate the database NetPerfDbName with a table for every cloud, and create the history database and table if they do not exist.
+func (s *MySqlNetStateStore) Init(cloudNames []string) error {
+	beego.Info("Create the database, tables, and columns in MySQL.")
+
+	beego.Info(fmt.Sprintf("Check existing database"))
+
+	dbs, err := ListDbs()
+	if err != nil {
+		outErr := fmt.Errorf("Check existing database, error %w.", err)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	beego.Info(fmt.Sprintf("Existing databases: %v", dbs))
+
+	var oldDbExist bool
+	for _, db := range dbs {
+		if db == NetPerfDbName {
+			oldDbExist = true
+			break
+		}
+	}
+
+	if oldDbExist {
+		beego.Info(fmt.Sprintf("Database [%s] exists, so we delete it.", NetPerfDbName))
+		if err := DeleteDb(NetPerfDbName); err != nil {
+			outErr := fmt.Errorf("Delete database [%s], error %w.", NetPerfDbName, err)
+			beego.Error(outErr)
+			return outErr
+		}
+	} else {
+		beego.Info(fmt.Sprintf("Database [%s] does not exist, so we do not need to delete it.", NetPerfDbName))
+	}
+
+	beego.Info(fmt.Sprintf("Create new database: %s", NetPerfDbName))
+	if err := CreateDb(NetPerfDbName); err != nil {
+		outErr := fmt.Errorf("Create database [%s], error %w.", NetPerfDbName, err)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	if err := s.initTables(cloudNames); err != nil {
+		outErr := fmt.Errorf("Create and initialize tables for network performance in database [%s], error %w.", NetPerfDbName, err)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	if err := s.initHistory(); err != nil {
+		outErr := fmt.Errorf("Create the database [%s] for network state history, error %w.", NetHistDbName, err)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	return nil
+}
+
+// create a table for every cloud, and every cloud is unreachable from it at first
+func (s *MySqlNetStateStore) initTables(cloudNames []string) error {
+	beego.Info(fmt.Sprintf("Create and initialize tables for network performance in database [%s].", NetPerfDbName))
+	db, err := NewMySqlCli()
+	if err != nil {
+		outErr := fmt.Errorf("Create MySQL client, error [%w].", err)
+		beego.Error(outErr)
+		return outErr
+	}
+	defer db.Close()
+
+	for _, cloudName := range cloudNames {
+		table, err := mySqlTable(NetPerfDbName, cloudName)
+		if err != nil {
+			outErr := fmt.Errorf("Table name of cloud [%s], error [%w].", cloudName, err)
+			beego.Error(outErr)
+			return outErr
+		}
+		query := fmt.Sprintf("create table %s(%s varchar(768) not null,%s double not null,%s double not null, primary key(%s))", table, DbFieldCloudName, DbFieldRtt, DbFieldBandwidth, DbFieldCloudName)
+		result, err := db.Query(query)
+		if err != nil {
+			outErr := fmt.Errorf("Query [%s], error [%w].", query, err)
+			beego.Error(outErr)
+			return outErr
+		}
+		result.Close()
+		beego.Info(fmt.Sprintf("Query [%s] successfully.", query))
+
+		for _, targetCloudName := range cloudNames {
+			query := fmt.Sprintf("insert into %s (%s, %s, %s) values (?, ?, ?)", table, DbFieldCloudName, DbFieldRtt, DbFieldBandwidth)
+			result, err := db.Query(query, targetCloudName, UnreachableRttMs, UnreachableBwMbps)
+			if err != nil {
+				outErr := fmt.Errorf("Query [%s], args: [%s, %g, %g], error [%w].", query, targetCloudName, UnreachableRttMs, UnreachableBwMbps, err)
+				beego.Error(outErr)
+				return outErr
+			}
+			result.Close()
+			beego.Info(fmt.Sprintf("Query [%s], args: [%s, %g, %g] successfully.", query, targetCloudName, UnreachableRttMs, UnreachableBwMbps))
+		}
+	}
+	beego.Info(fmt.Sprintf("Successful! Create and initialize tables for network performance in database [%s].", NetPerfDbName))
+
+	return nil
+}
+
+// create the tables of the clouds and the rows of the target clouds that do not exist.
+func (s *MySqlNetStateStore) EnsureClouds(cloudNames []string) error {
+	db, err := NewMySqlCli()
+	if err != nil {
+		outErr := fmt.Errorf("Create MySQL client, error [%w].", err)
+		beego.Error(outErr)
+		return outErr
+	}
+	defer db.Close()
+
+	for _, cloudName := range cloudNames {
+		table, err := mySqlTable(NetPerfDbName, cloudName)
+		if err != nil {
+			outErr := fmt.Errorf("Table name of cloud [%s], error [%w].", cloudName, err)
+			beego.Error(outErr)
+			return outErr
+		}
+		query := fmt.Sprintf("create table if not exists %s(%s varchar(768) not null,%s double not null,%s double not null, primary key(%s))", table, DbFieldCloudName, DbFieldRtt, DbFieldBandwidth, DbFieldCloudName)
+		if _, err := db.Exec(query); err != nil {
+			outErr := fmt.Errorf("Query [%s], error [%w].", query, err)
+			beego.Error(outErr)
+			return outErr
+		}
+
+		for _, targetCloudName := range cloudNames {
+			query := fmt.Sprintf("insert ignore into %s (%s, %s, %s) values (?, ?, ?)", table, DbFieldCloudName, DbFieldRtt, DbFieldBandwidth)
+			if _, err := db.Exec(query, targetCloudName, UnreachableRttMs, UnreachableBwMbps); err != nil {
+				outErr := fmt.Errorf("Query [%s], args: [%s, %g, %g], error [%w].", query, targetCloudName, UnreachableRttMs, UnreachableBwMbps, err)
+				beego.Error(outErr)
+				return outErr
+			}
+		}
+	}
+	beego.Info(fmt.Sprintf("Ensured the network state among clouds %v in database [%s].", cloudNames, NetPerfDbName))
+
+	return nil
+}
+
+// drop the table of the cloud and delete the rows of it in the tables of the rest clouds.
+func (s *MySqlNetStateStore) RemoveCloud(cloudName string, restCloudNames []string) error {
+	db, err := NewMySqlCli()
+	if err != nil {
+		outErr := fmt.Errorf("Create MySQL client, error [%w].", err)
+		beego.Error(outErr)
+		return outErr
+	}
+	defer db.Close()
+
+	table, err := mySqlTable(NetPerfDbName, cloudName)
+	if err != nil {
+		outErr := fmt.Errorf("Table name of cloud [%s], error [%w].", cloudName, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	query := fmt.Sprintf("drop table if exists %s", table)
+	if _, err := db.Exec(query); err != nil {
+		outErr := fmt.Errorf("Query [%s], error [%w].", query, err)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	for _, srcCloudName := range restCloudNames {
+		srcTable, err := mySqlTable(NetPerfDbName, srcCloudName)
+		if err != nil {
+			outErr := fmt.Errorf("Table name of cloud [%s], error [%w].", srcCloudName, err)
+			beego.Error(outErr)
+			return outErr
+		}
+		query := fmt.Sprintf("delete from %s where %s = ?", srcTable, DbFieldCloudName)
+		if _, err := db.Exec(query, cloudName); err != nil {
+			outErr := fmt.Errorf("Query [%s], args: [%s], error [%w].", query, cloudName, err)
+			beego.Error(outErr)
+			return outErr
+		}
+	}
+	beego.Info(fmt.Sprintf("Removed the network state of cloud [%s] in database [%s].", cloudName, NetPerfDbName))
+
+	return nil
+}
+
+// create the database and table of the network state history if they do not exist. Different from the latest network state, we do not delete the old ones.
+func (s *MySqlNetStateStore) initHistory() error {
+	db, err := NewMySqlCli()
+	if err != nil {
+		outErr := fmt.Errorf("Create MySQL client, error [%w].", err)
+		beego.Error(outErr)
+		return outErr
+	}
+	defer db.Close()
+
+	quotedDb, err := mySqlIdent(NetHistDbName)
+	if err != nil {
+		return err
+	}
+	table, err := mySqlTable(NetHistDbName, NetHistTableName)
+	if err != nil {
+		return err
+	}
+
+	queries := []string{
+		fmt.Sprintf("create database if not exists %s", quotedDb),
+		fmt.Sprintf("create table if not exists %s(id bigint not null auto_increment,%s varchar(255) not null,%s varchar(255) not null,%s bigint not null,%s double not null,%s double not null,%s tinyint not null default 0, primary key(id), index idx_pair_time(%s,%s,%s))",
+			table, DbFieldSrcCloudName, DbFieldDstCloudName, DbFieldMeasuredAt, DbFieldRtt, DbFieldBandwidth, DbFieldDownsampled, DbFieldSrcCloudName, DbFieldDstCloudName, DbFieldMeasuredAt),
+	}
+	for _, query := range queries {
+		result, err := db.Query(query)
+		if err != nil {
+			outErr := fmt.Errorf("Query [%s], error [%w].", query, err)
+			beego.Error(outErr)
+			return outErr
+		}
+		result.Close()
+		beego.Info(fmt.Sprintf("Query [%s] successfully.", query))
+	}
+
+	return nil
+}
+
+func (s *MySqlNetStateStore) GetNetStateOneCloud(cloudName string) (map[string]NetworkState, error) {
+	db, err := NewMySqlCli()
+	if err != nil {
+		outErr := fmt.Errorf("Create MySQL client, error [%w].", err)
+		beego.Error(outErr)
+		return nil, outErr
+	}
+	defer db.Close()
+
+	table, err := mySqlTable(NetPerfDbName, cloudName)
+	if err != nil {
+		outErr := fmt.Errorf("Table name of cloud [%s], error [%w].", cloudName, err)
+		beego.Error(outErr)
+		return nil, outErr
+	}
+	query := fmt.Sprintf("select %s, %s, %s from %s", DbFieldCloudName, DbFieldRtt, DbFieldBandwidth, table)
+
+	// without timeout, this request may be stuck forever if there are some problems
+	ctx, cancel := context.WithTimeout(context.Background(), ReqShortTimeout)
+	defer cancel()
+	result, err := db.QueryContext(ctx, query)
+	if err != nil {
+		outErr := fmt.Errorf("Query [%s], error [%w].", query, err)
+		beego.Error(outErr)
+		return nil, outErr
+	}
+	defer result.Close()
+
+	var netStates map[string]NetworkState = make(map[string]NetworkState)
+	for result.Next() {
+		var targetCloudName string
+		var rtt, bandwidth float64
+		if err := result.Scan(&targetCloudName, &rtt, &bandwidth); err != nil {
+			outErr := fmt.Errorf("Query [%s], result.Scan, error [%w].", query, err)
+			beego.Error(outErr)
+			beego.Error(fmt.Sprintf("Current netStates: %v", netStates))
+			return nil, outErr
+		}
+		netStates[targetCloudName] = NetworkState{Rtt: rtt, BandwidthMbps: bandwidth}
+	}
+
+	beego.Info(fmt.Sprintf("Query [%s] successfully.", query))
+	return netStates, nil
+}
+
+func (s *MySqlNetStateStore) SetNetState(srcCloudName, dstCloudName string, netState NetworkState) error {
+	db, err := NewMySqlCli()
+	if err != nil {
+		outErr := fmt.Errorf("Create MySQL client, error [%w].", err)
+		beego.Error(outErr)
+		return outErr
+	}
+	defer db.Close()
+
+	table, err := mySqlTable(NetPerfDbName, srcCloudName)
+	if err != nil {
+		outErr := fmt.Errorf("Table name of cloud [%s], error [%w].", srcCloudName, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	query := fmt.Sprintf("update %s set %s=?, %s=? where %s=?", table, DbFieldRtt, DbFieldBandwidth, DbFieldCloudName)
+
+	// without timeout, this request may be stuck forever if there are some problems
+	ctx, cancel := context.WithTimeout(context.Background(), ReqShortTimeout)
+	defer cancel()
+	if _, err := db.ExecContext(ctx, query, netState.Rtt, netState.BandwidthMbps, dstCloudName); err != nil {
+		outErr := fmt.Errorf("Query [%s], args: [%g, %g, %s], error [%w].", query, netState.Rtt, netState.BandwidthMbps, dstCloudName, err)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	return nil
+}
+
+func (s *MySqlNetStateStore) AppendHistory(measuredAt time.Time, netStates map[string]map[string]NetworkState) error {
+	db, err := NewMySqlCli()
+	if err != nil {
+		outErr := fmt.Errorf("Create MySQL client, error [%w].", err)
+		beego.Error(outErr)
+		return outErr
+	}
+	defer db.Close()
+
+	table, err := mySqlTable(NetHistDbName, NetHistTableName)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("insert into %s (%s, %s, %s, %s, %s) values (?, ?, ?, ?, ?)", table, DbFieldSrcCloudName, DbFieldDstCloudName, DbFieldMeasuredAt, DbFieldRtt, DbFieldBandwidth)
+	for srcCloudName, dsts := range netStates {
+		for dstCloudName, netState := range dsts {
+			ctx, cancel := context.WithTimeout(context.Background(), ReqShortTimeout)
+			_, err := db.ExecContext(ctx, query, srcCloudName, dstCloudName, measuredAt.UnixMilli(), netState.Rtt, netState.BandwidthMbps)
+			cancel()
+			if err != nil {
+				outErr := fmt.Errorf("Query [%s], args: [%s, %s, %d, %g, %g], error [%w].", query, srcCloudName, dstCloudName, measuredAt.UnixMilli(), netState.Rtt, netState.BandwidthMbps, err)
+				beego.Error(outErr)
+				return outErr
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *MySqlNetStateStore) CompactHistory(rawBefore, retainAfter time.Time) error {
+	db, err := NewMySqlCli()
+	if err != nil {
+		outErr := fmt.Errorf("Create MySQL client, error [%w].", err)
+		beego.Error(outErr)
+		return outErr
+	}
+	defer db.Close()
+
+	table, err := mySqlTable(NetHistDbName, NetHistTableName)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), netHistCompactTimeout)
+	defer cancel()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		outErr := fmt.Errorf("Begin transaction, error [%w].", err)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	steps := []struct {
+		query string
+		args  []interface{}
+	}{
+		{
+			query: fmt.Sprintf("insert into %s (%s, %s, %s, %s, %s, %s) select %s, %s, floor(%s/%d)*%d, avg(%s), avg(%s), 1 from %s where %s=0 and %s<? group by %s, %s, floor(%s/%d)",
+				table, DbFieldSrcCloudName, DbFieldDstCloudName, DbFieldMeasuredAt, DbFieldRtt, DbFieldBandwidth, DbFieldDownsampled,
+				DbFieldSrcCloudName, DbFieldDstCloudName, DbFieldMeasuredAt, downsampleIntervalMs, downsampleIntervalMs, DbFieldRtt, DbFieldBandwidth,
+				table, DbFieldDownsampled, DbFieldMeasuredAt, DbFieldSrcCloudName, DbFieldDstCloudName, DbFieldMeasuredAt, downsampleIntervalMs),
+			args: []interface{}{rawBefore.UnixMilli()},
+		},
+		{
+			query: fmt.Sprintf("delete from %s where %s=0 and %s<?", table, DbFieldDownsampled, DbFieldMeasuredAt),
+			args:  []interface{}{rawBefore.UnixMilli()},
+		},
+		{
+			query: fmt.Sprintf("delete from %s where %s<?", table, DbFieldMeasuredAt),
+			args:  []interface{}{retainAfter.UnixMilli()},
+		},
+	}
+	for _, step := range steps {
+		if _, err := tx.ExecContext(ctx, step.query, step.args...); err != nil {
+			tx.Rollback()
+			outErr := fmt.Errorf("Query [%s], args: %v, error [%w].", step.query, step.args, err)
+			beego.Error(outErr)
+			return outErr
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		outErr := fmt.Errorf("Commit transaction, error [%w].", err)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	return nil
+}
+
+func (s *MySqlNetStateStore) GetHistory(from, to time.Time, srcCloudName, dstCloudName string) ([]NetStateSample, error) {
+	db, err := NewMySqlCli()
+	if err != nil {
+		outErr := fmt.Errorf("Create MySQL client, error [%w].", err)
+		beego.Error(outErr)
+		return nil, outErr
+	}
+	defer db.Close()
+
+	table, err := mySqlTable(NetHistDbName, NetHistTableName)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf("select %s, %s, %s, %s, %s, %s from %s where %s>=? and %s<=?", DbFieldSrcCloudName, DbFieldDstCloudName, DbFieldMeasuredAt, DbFieldDownsampled, DbFieldRtt, DbFieldBandwidth, table, DbFieldMeasuredAt, DbFieldMeasuredAt)
+	args := []interface{}{from.UnixMilli(), to.UnixMilli()}
+	if len(srcCloudName) != 0 {
+		query += fmt.Sprintf(" and %s=?", DbFieldSrcCloudName)
+		args = append(args, srcCloudName)
+	}
+	if len(dstCloudName) != 0 {
+		query += fmt.Sprintf(" and %s=?", DbFieldDstCloudName)
+		args = append(args, dstCloudName)
+	}
+	query += fmt.Sprintf(" order by %s", DbFieldMeasuredAt)
+
+	// without timeout, this request may be stuck forever if there are some problems
+	ctx, cancel := context.WithTimeout(context.Background(), ReqShortTimeout)
+	defer cancel()
+	result, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		outErr := fmt.Errorf("Query [%s], args: %v, error [%w].", query, args, err)
+		beego.Error(outErr)
+		return nil, outErr
+	}
+	defer result.Close()
+
+	var samples []NetStateSample = []NetStateSample{}
+	for result.Next() {
+		var sample NetStateSample
+		var measuredAtMs int64
+		if err := result.Scan(&sample.SrcCloudName, &sample.DstCloudName, &measuredAtMs, &sample.Downsampled, &sample.NetState.Rtt, &sample.NetState.BandwidthMbps); err != nil {
+			outErr := fmt.Errorf("Query [%s], result.Scan, error [%w].", query, err)
+			beego.Error(outErr)
+			return nil, outErr
+		}
+		sample.MeasuredAt = time.UnixMilli(measuredAtMs)
+		samples = append(samples, sample)
+	}
+
+	return samples, nil
+}
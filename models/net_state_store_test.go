@@ -0,0 +1,261 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInnerMySqlIdent(t *testing.T) {
+	testCases := []struct {
+		name           string
+		ident          string
+		expectedResult string
+		expectedErr    bool
+	}{
+		{
+			name:           "normal",
+			ident:          "NOKIA7",
+			expectedResult: "`NOKIA7`",
+		},
+		{
+			name:           "hyphen",
+			ident:          "cloud-1",
+			expectedResult: "`cloud-1`",
+		},
+		{
+			name:           "injection",
+			ident:          "c1`; drop database multi_cloud; --",
+			expectedResult: "`c1``; drop database multi_cloud; --`",
+		},
+		{
+			name:        "empty",
+			ident:       "",
+			expectedErr: true,
+		},
+		{
+			name:        "too long",
+			ident:       fmt.Sprintf("%065d", 0),
+			expectedErr: true,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		actualResult, err := mySqlIdent(testCase.ident)
+		assert.Equal(t, testCase.expectedErr, err != nil, fmt.Sprintf("%s: error is not expected", testCase.name))
+		assert.Equal(t, testCase.expectedResult, actualResult, fmt.Sprintf("%s: result is not expected", testCase.name))
+	}
+}
+
+func TestFileNetStateStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "net_state.json")
+	store := NewFileNetStateStore(path)
+	assert.Nil(t, store.Init([]string{"c1", "c2"}))
+
+	netStates, err := store.GetNetStateOneCloud("c1")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]NetworkState{"c1": {Rtt: UnreachableRttMs}, "c2": {Rtt: UnreachableRttMs}}, netStates)
+
+	assert.Nil(t, store.SetNetState("c1", "c2", NetworkState{Rtt: 5, BandwidthMbps: 900}))
+	assert.NotNil(t, store.SetNetState("c1", "c3", NetworkState{Rtt: 5}))
+	_, err = store.GetNetStateOneCloud("c3")
+	assert.NotNil(t, err)
+
+	measuredAt := time.UnixMilli(1700000000000)
+	assert.Nil(t, store.AppendHistory(measuredAt, map[string]map[string]NetworkState{"c1": {"c2": {Rtt: 5, BandwidthMbps: 900}}}))
+
+	// the history is kept after the store is initialized again, but the latest network state is reset.
+	reloaded := NewFileNetStateStore(path)
+	assert.Nil(t, reloaded.Init([]string{"c1", "c2"}))
+	netStates, err = reloaded.GetNetStateOneCloud("c1")
+	assert.Nil(t, err)
+	assert.Equal(t, NetworkState{Rtt: UnreachableRttMs}, netStates["c2"])
+
+	samples, err := reloaded.GetHistory(measuredAt.Add(-time.Minute), measuredAt.Add(time.Minute), "c1", "")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(samples))
+	assert.Equal(t, NetworkState{Rtt: 5, BandwidthMbps: 900}, samples[0].NetState)
+
+	samples, err = reloaded.GetHistory(measuredAt.Add(-time.Minute), measuredAt.Add(time.Minute), "c2", "")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(samples))
+}
+
+func TestFileNetStateStoreHistoryFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "net_state.json")
+	historyPath := filepath.Join(dir, "net_state_history.jsonl")
+	measuredAt := time.UnixMilli(1700000000000)
+
+	// the history in the file of an old version
+	oldContent := `{"latest":{},"history":[{"srcCloudName":"c1","dstCloudName":"c2","measuredAt":"2023-11-14T22:13:20Z","downsampled":false,"netState":{"rtt":5,"bandwidthMbps":900}}]}`
+	assert.Nil(t, os.WriteFile(path, []byte(oldContent), 0644))
+
+	store := NewFileNetStateStore(path)
+	assert.Nil(t, store.Init([]string{"c1", "c2"}))
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(content), "history", "the history should be moved out of the file of the latest network state")
+	content, err = os.ReadFile(historyPath)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, strings.Count(string(content), "\n"))
+
+	// setting the latest network state does not write the history
+	assert.Nil(t, store.SetNetState("c1", "c2", NetworkState{Rtt: 6, BandwidthMbps: 800}))
+	content, err = os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(content), "history")
+
+	// the new samples are appended
+	assert.Nil(t, store.AppendHistory(measuredAt.Add(time.Minute), map[string]map[string]NetworkState{"c1": {"c2": {Rtt: 6, BandwidthMbps: 800}}, "c2": {"c1": {Rtt: 7, BandwidthMbps: 700}}}))
+	content, err = os.ReadFile(historyPath)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, strings.Count(string(content), "\n"))
+
+	// a broken line, e.g., emcontroller stopped when appending, is skipped, and the file is rewritten without it.
+	file, err := os.OpenFile(historyPath, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.Nil(t, err)
+	_, err = file.WriteString(`{"srcCloudName":"c1","dstClo`)
+	assert.Nil(t, err)
+	assert.Nil(t, file.Close())
+
+	reloaded := NewFileNetStateStore(path)
+	assert.Nil(t, reloaded.Init([]string{"c1", "c2"}))
+	samples, err := reloaded.GetHistory(measuredAt.Add(-time.Minute), measuredAt.Add(time.Hour), "", "")
+	assert.Nil(t, err)
+	expectedSamples := []NetStateSample{
+		{SrcCloudName: "c1", DstCloudName: "c2", MeasuredAt: measuredAt, NetState: NetworkState{Rtt: 5, BandwidthMbps: 900}},
+		{SrcCloudName: "c1", DstCloudName: "c2", MeasuredAt: measuredAt.Add(time.Minute), NetState: NetworkState{Rtt: 6, BandwidthMbps: 800}},
+		{SrcCloudName: "c2", DstCloudName: "c1", MeasuredAt: measuredAt.Add(time.Minute), NetState: NetworkState{Rtt: 7, BandwidthMbps: 700}},
+	}
+	if assert.Equal(t, len(expectedSamples), len(samples)) {
+		for i := range expectedSamples {
+			assert.True(t, expectedSamples[i].MeasuredAt.Equal(samples[i].MeasuredAt), fmt.Sprintf("sample %d: time is not expected", i))
+			samples[i].MeasuredAt = expectedSamples[i].MeasuredAt
+		}
+		assert.Equal(t, expectedSamples, samples)
+	}
+	content, err = os.ReadFile(historyPath)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, strings.Count(string(content), "\n"))
+	assert.NotContains(t, string(content), "dstClo\"")
+
+	// compacting without anything to downsample or delete does not rewrite the history file
+	oldTime := time.Now().Add(-time.Hour)
+	assert.Nil(t, os.Chtimes(historyPath, oldTime, oldTime))
+	assert.Nil(t, reloaded.CompactHistory(measuredAt, measuredAt.Add(-time.Hour)))
+	info, err := os.Stat(historyPath)
+	assert.Nil(t, err)
+	assert.True(t, info.ModTime().Equal(oldTime), "the history file should not be rewritten")
+
+	// compacting rewrites the history file
+	assert.Nil(t, reloaded.CompactHistory(measuredAt, measuredAt.Add(30*time.Second)))
+	content, err = os.ReadFile(historyPath)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, strings.Count(string(content), "\n"))
+}
+
+func TestInnerNetHistNeedsCompaction(t *testing.T) {
+	hour := time.UnixMilli(1700000000000 / downsampleIntervalMs * downsampleIntervalMs)
+	testCases := []struct {
+		name           string
+		samples        []NetStateSample
+		expectedResult bool
+	}{
+		{
+			name:           "no samples",
+			expectedResult: false,
+		},
+		{
+			name: "raw samples after rawBefore, downsampled samples after retainAfter",
+			samples: []NetStateSample{
+				{MeasuredAt: hour.Add(-time.Hour), Downsampled: true},
+				{MeasuredAt: hour.Add(time.Minute)},
+			},
+			expectedResult: false,
+		},
+		{
+			name:           "a raw sample before rawBefore",
+			samples:        []NetStateSample{{MeasuredAt: hour.Add(-time.Minute)}},
+			expectedResult: true,
+		},
+		{
+			name:           "a downsampled sample before retainAfter",
+			samples:        []NetStateSample{{MeasuredAt: hour.Add(-3 * time.Hour), Downsampled: true}},
+			expectedResult: true,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		actualResult := netHistNeedsCompaction(testCase.samples, hour, hour.Add(-2*time.Hour))
+		assert.Equal(t, testCase.expectedResult, actualResult, testCase.name)
+	}
+}
+
+func TestInnerCompactNetHistSamples(t *testing.T) {
+	hour := time.UnixMilli(1700000000000 / downsampleIntervalMs * downsampleIntervalMs)
+	samples := []NetStateSample{
+		{SrcCloudName: "c1", DstCloudName: "c2", MeasuredAt: hour.Add(-2 * time.Hour), NetState: NetworkState{Rtt: 100}},
+		{SrcCloudName: "c1", DstCloudName: "c2", MeasuredAt: hour.Add(10 * time.Minute), NetState: NetworkState{Rtt: 10, BandwidthMbps: 100}},
+		{SrcCloudName: "c1", DstCloudName: "c2", MeasuredAt: hour.Add(20 * time.Minute), NetState: NetworkState{Rtt: 20, BandwidthMbps: 300}},
+		{SrcCloudName: "c2", DstCloudName: "c1", MeasuredAt: hour.Add(30 * time.Minute), NetState: NetworkState{Rtt: 30, BandwidthMbps: 50}},
+		{SrcCloudName: "c1", DstCloudName: "c2", MeasuredAt: hour.Add(70 * time.Minute), NetState: NetworkState{Rtt: 70, BandwidthMbps: 70}},
+	}
+
+	actualResult := compactNetHistSamples(samples, hour.Add(time.Hour), hour.Add(-time.Hour))
+	expectedResult := []NetStateSample{
+		{SrcCloudName: "c1", DstCloudName: "c2", MeasuredAt: hour, Downsampled: true, NetState: NetworkState{Rtt: 15, BandwidthMbps: 200}},
+		{SrcCloudName: "c2", DstCloudName: "c1", MeasuredAt: hour, Downsampled: true, NetState: NetworkState{Rtt: 30, BandwidthMbps: 50}},
+		{SrcCloudName: "c1", DstCloudName: "c2", MeasuredAt: hour.Add(70 * time.Minute), NetState: NetworkState{Rtt: 70, BandwidthMbps: 70}},
+	}
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestInnerParseNetTestResult(t *testing.T) {
+	testCases := []struct {
+		name           string
+		logs           string
+		expectedResult NetworkState
+		expectedErr    bool
+	}{
+		{
+			name:           "reachable",
+			logs:           "RTT from NOKIA8 to NOKIA7 is 0.458 ms.\nBandwidth from NOKIA8 to NOKIA7 is 933 Mbps.\nMCM_NET_RESULT rtt_ms=0.458 bandwidth_mbps=933\n",
+			expectedResult: NetworkState{Rtt: 0.458, BandwidthMbps: 933},
+		},
+		{
+			name:           "unreachable",
+			logs:           "Unreachable from NOKIA8 to NOKIA7, so we set the RTT as 250000 ms.\nMCM_NET_RESULT rtt_ms=250000 bandwidth_mbps=0",
+			expectedResult: NetworkState{Rtt: UnreachableRttMs, BandwidthMbps: UnreachableBwMbps},
+		},
+		{
+			name:           "bandwidth not measured",
+			logs:           "Failed to measure the bandwidth from NOKIA8 to NOKIA7, so we report it as not measured.\nMCM_NET_RESULT rtt_ms=0.458 bandwidth_mbps=-1\n",
+			expectedResult: NetworkState{Rtt: 0.458, BandwidthMbps: NotMeasuredBwMbps},
+		},
+		{
+			name:        "no result",
+			logs:        "ping: unknown host\n",
+			expectedErr: true,
+		},
+		{
+			name:        "broken result",
+			logs:        "MCM_NET_RESULT rtt_ms= bandwidth_mbps=0\n",
+			expectedErr: true,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		actualResult, err := parseNetTestResult(testCase.logs)
+		assert.Equal(t, testCase.expectedErr, err != nil, fmt.Sprintf("%s: error is not expected", testCase.name))
+		assert.Equal(t, testCase.expectedResult, actualResult, fmt.Sprintf("%s: result is not expected", testCase.name))
+	}
+}
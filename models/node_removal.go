@@ -0,0 +1,253 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/astaxie/beego"
+	apiv1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+/**
+NOTE:
+
+A node is removed from the Kubernetes cluster by the controller, and the VM of the node never gets any cluster credentials:
+1. cordon the node, drain it with the eviction API, so PodDisruptionBudgets are respected, all through client-go;
+2. SSH to the VM, run "kubeadm reset", which only needs the local files of the node, and remove the kubeconfig files on the VM, including the ones left by old versions of multi-cloud manager, which copied the admin kubeconfig to /root/.kube;
+3. check that there is no kubeconfig left on the VM;
+4. delete the Node object through client-go.
+Step 2 and 3 are skipped with a warning if the VM cannot be reached, to enable deleting nodes with problems.
+*/
+
+// the time to wait for the pods on a node to be evicted, and the interval to check them. Unit: second.
+var (
+	nodeDrainTimeout  int = 600
+	nodeDrainInterval int = 5
+)
+
+// the kubeconfig files that may be on a node, printed by the check command if they exist.
+// kubeadm puts all its kubeconfig files in /etc/kubernetes/*.conf, such as kubelet.conf and admin.conf.
+const nodeKubeconfigCheckCmd string = `sudo sh -c 'for f in /root/.kube/config /home/*/.kube/config /etc/kubernetes/*.conf; do [ -e "$f" ] && echo "$f"; done; true'`
+
+// the commands to reset a node without any cluster credentials.
+func nodeResetCmds() []string {
+	return []string{
+		"sudo kubeadm reset -f",
+		"sudo rm -rf /etc/cni/net.d",
+		"sudo sh -c 'rm -rf /root/.kube /home/*/.kube /etc/kubernetes/*.conf'",
+	}
+}
+
+// the kubeconfig files in the output of nodeKubeconfigCheckCmd
+func leftoverKubeconfigs(output string) []string {
+	var files []string
+	for _, line := range strings.Split(output, "\n") {
+		if file := strings.TrimSpace(line); len(file) > 0 {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// kubectl cordon <nodeName>
+func CordonNode(nodeName string) error {
+	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node, err := GetNode(nodeName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if node.Spec.Unschedulable {
+			return nil
+		}
+		node.Spec.Unschedulable = true
+		_, err = kubernetesClient.CoreV1().Nodes().Update(context.Background(), node, metav1.UpdateOptions{})
+		return err
+	})
+	if retryErr != nil {
+		outErr := fmt.Errorf("Cordon Node [%s], error [%w]", nodeName, retryErr)
+		beego.Error(outErr)
+		return outErr
+	}
+	beego.Info(fmt.Sprintf("Node [%s] is cordoned", nodeName))
+	return nil
+}
+
+// IsNodeBoundPod checks whether a pod belongs to the node rather than an application, i.e., a pod of a DaemonSet or a mirror pod of a static pod.
+// These pods are not evicted when a node is drained, because the DaemonSet controller ignores unschedulable nodes and would create them again, and the mirror pods cannot be evicted through the API server.
+func IsNodeBoundPod(pod apiv1.Pod) bool {
+	if _, isMirror := pod.Annotations[apiv1.MirrorPodAnnotationKey]; isMirror {
+		return true
+	}
+	if controller := metav1.GetControllerOf(&pod); controller != nil && controller.Kind == "DaemonSet" {
+		return true
+	}
+	return false
+}
+
+// kubectl drain <nodeName> --ignore-daemonsets --delete-emptydir-data
+// The node should be cordoned before it is drained.
+func DrainNode(nodeName string) error {
+	if K8sSimulated() {
+		return nil // the pods are evicted when the node is removed from the simulated cluster
+	}
+
+	pods, err := ListPodsOnNode(metav1.NamespaceAll, nodeName)
+	if err != nil {
+		outErr := fmt.Errorf("Drain Node [%s], error: %w", nodeName, err)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	// a pod evicted is gone when it is not found or a new pod with the same name is created
+	evicted := make(map[string]types.UID)
+	for _, pod := range pods {
+		if !IsNodeBoundPod(pod) {
+			evicted[pod.Namespace+"/"+pod.Name] = pod.UID
+		}
+	}
+	beego.Info(fmt.Sprintf("Drain Node [%s], %d pods to evict", nodeName, len(evicted)))
+
+	ctx := context.Background()
+	if err := MyWaitFor(nodeDrainTimeout, nodeDrainInterval, func() (bool, error) {
+		for _, pod := range pods {
+			uid, needEvict := evicted[pod.Namespace+"/"+pod.Name]
+			if !needEvict {
+				continue
+			}
+			current, err := kubernetesClient.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+			if errors.IsNotFound(err) || (err == nil && current.UID != uid) {
+				delete(evicted, pod.Namespace+"/"+pod.Name)
+				continue
+			}
+			if err != nil {
+				return false, fmt.Errorf("get pod [%s/%s], error: %w", pod.Namespace, pod.Name, err)
+			}
+			if current.DeletionTimestamp != nil {
+				continue // evicted, waiting for it to terminate
+			}
+
+			eviction := &policyv1.Eviction{
+				ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+			}
+			err = kubernetesClient.CoreV1().Pods(pod.Namespace).EvictV1(ctx, eviction)
+			switch {
+			case err == nil, errors.IsNotFound(err):
+			case errors.IsTooManyRequests(err):
+				// a PodDisruptionBudget does not allow the eviction now, so we try again later.
+				beego.Info(fmt.Sprintf("Evict pod [%s/%s], not allowed now: %s", pod.Namespace, pod.Name, err.Error()))
+			default:
+				return false, fmt.Errorf("evict pod [%s/%s], error: %w", pod.Namespace, pod.Name, err)
+			}
+		}
+		return len(evicted) == 0, nil
+	}); err != nil {
+		outErr := fmt.Errorf("Drain Node [%s], %d pods are not evicted, error: %w", nodeName, len(evicted), err)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	beego.Info(fmt.Sprintf("Node [%s] is drained", nodeName))
+	return nil
+}
+
+// kubeadm reset the VM of a node, remove the kubeconfig files on it, and check that none is left.
+// The returned bool is false if the VM cannot be reached.
+func resetNodeVm(nodeName, nodeIp string) (bool, error) {
+	sshPrivateKey := beego.AppConfig.String("k8sVmSshPrivateKey")
+	sshUser := beego.AppConfig.DefaultString("k8sVmSshUser", "ubuntu")
+
+	sshClient, err := SshClientWithPem(sshPrivateKey, sshUser, nodeIp, SshPort)
+	if err != nil {
+		beego.Warn(fmt.Sprintf("Create ssh client to node [%s] at [%s] fail, skip the reset of the VM, error: %s", nodeName, nodeIp, err.Error()))
+		return false, nil
+	}
+	defer sshClient.Close()
+
+	// we try all commands, if one is not successful, the check below tells whether the VM is clean.
+	for _, cmd := range nodeResetCmds() {
+		if out, err := SshOneCommand(sshClient, cmd); err != nil {
+			beego.Error(fmt.Sprintf("Reset node [%s], ssh error at [%s]: %s, output: %s", nodeName, cmd, err.Error(), string(out)))
+		}
+	}
+
+	out, err := SshOneCommand(sshClient, nodeKubeconfigCheckCmd)
+	if err != nil {
+		outErr := fmt.Errorf("check the kubeconfig files on node [%s], ssh error: %w, output: %s", nodeName, err, string(out))
+		beego.Error(outErr)
+		return true, outErr
+	}
+	if files := leftoverKubeconfigs(string(out)); len(files) > 0 {
+		outErr := fmt.Errorf("kubeconfig files %v are left on node [%s]", files, nodeName)
+		beego.Error(outErr)
+		return true, outErr
+	}
+
+	beego.Info(fmt.Sprintf("Node [%s] is reset, and no kubeconfig is left on it", nodeName))
+	return true, nil
+}
+
+// delete a node from the Kubernetes cluster
+func UninstallNode(name string) error {
+	if K8sSimulated() {
+		return SimulateNodeRemove(name)
+	}
+
+	node, err := GetNode(name, metav1.GetOptions{})
+	if err != nil {
+		outErr := fmt.Errorf("Kubernetes get node: error: %w", err)
+		beego.Error(outErr)
+		return outErr
+	}
+	nodeIp := GetNodeInternalIp(*node)
+
+	// Prevent users from removing the control plane
+	if _, isMaster := node.Labels[K8sMasterNodeRole]; isMaster || nodeIp == beego.AppConfig.String("k8sMasterIP") {
+		outErr := fmt.Errorf("node [%s] is the control plane of the Kubernetes cluster, so we refuse this risky request", name)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	if err := CordonNode(name); err != nil {
+		outErr := fmt.Errorf("CordonNode %s: error: %w", name, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	if err := DrainNode(name); err != nil {
+		outErr := fmt.Errorf("DrainNode %s: error: %w", name, err)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	// the reset error does not stop the deletion of the Node object, but it is returned at last.
+	var resetErr error
+	if len(nodeIp) == 0 {
+		beego.Warn(fmt.Sprintf("Node [%s] has no internal IP, skip the reset of the VM", name))
+	} else {
+		var reached bool
+		reached, resetErr = resetNodeVm(name, nodeIp)
+		if !reached {
+			beego.Warn(fmt.Sprintf("Node [%s] is not reset, please make sure that its VM is deleted", name))
+		}
+	}
+
+	// // delete the node from the Kubernetes Cluster
+	beego.Info(fmt.Sprintf("Use client-go API to delete node %s in Kubernetes cluster", name))
+	if err := DeleteNode(name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		outErr := fmt.Errorf("use client-go API to delete node error: %w", err)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	if resetErr != nil {
+		outErr := fmt.Errorf("node [%s] is deleted, but its VM is not clean: %w", name, resetErr)
+		beego.Error(outErr)
+		return outErr
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// use the fake clientset of client-go as a real cluster in a test, so the functions do not take the way of the simulated cluster
+func useFakeK8s(t *testing.T) *fake.Clientset {
+	oldClient, oldSimulator := kubernetesClient, k8sSimulator
+	oldTimeout, oldInterval := nodeDrainTimeout, nodeDrainInterval
+	t.Cleanup(func() {
+		kubernetesClient, k8sSimulator = oldClient, oldSimulator
+		nodeDrainTimeout, nodeDrainInterval = oldTimeout, oldInterval
+	})
+	client := fake.NewSimpleClientset()
+	SetKubernetesClient(client)
+	k8sSimulator = nil
+	nodeDrainTimeout, nodeDrainInterval = 10, 0
+	return client
+}
+
+func TestDrainNode(t *testing.T) {
+	client := useFakeK8s(t)
+	ctx := context.Background()
+
+	_, err := client.CoreV1().Nodes().Create(ctx, &apiv1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}, metav1.CreateOptions{})
+	assert.Nil(t, err)
+	isController := true
+	pods := []*apiv1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", UID: "1"}, Spec: apiv1.PodSpec{NodeName: "node1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "protected", Namespace: "default", UID: "2"}, Spec: apiv1.PodSpec{NodeName: "node1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "other-node", Namespace: "default", UID: "3"}, Spec: apiv1.PodSpec{NodeName: "node2"}},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "daemon",
+				Namespace:       "kube-system",
+				UID:             "4",
+				OwnerReferences: []metav1.OwnerReference{{Kind: "DaemonSet", Name: "calico-node", Controller: &isController}},
+			},
+			Spec: apiv1.PodSpec{NodeName: "node1"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "static", Namespace: "kube-system", UID: "5", Annotations: map[string]string{apiv1.MirrorPodAnnotationKey: "x"}},
+			Spec:       apiv1.PodSpec{NodeName: "node1"},
+		},
+	}
+	for _, pod := range pods {
+		_, err := client.CoreV1().Pods(pod.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+		assert.Nil(t, err)
+	}
+
+	// the fake clientset does not evict pods, so we imitate the API server, and the PodDisruptionBudget refuses the first eviction of "protected"
+	evictions := make(map[string]int)
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		eviction := action.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction)
+		evictions[eviction.Name]++
+		if eviction.Name == "protected" && evictions[eviction.Name] == 1 {
+			return true, nil, errors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0)
+		}
+		return true, nil, client.Tracker().Delete(action.GetResource(), eviction.Namespace, eviction.Name)
+	})
+
+	assert.Nil(t, CordonNode("node1"))
+	assert.Nil(t, DrainNode("node1"))
+
+	node, err := GetNode("node1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.True(t, node.Spec.Unschedulable)
+	assert.Equal(t, map[string]int{"app": 1, "protected": 2}, evictions)
+	remainingPods, err := ListPods(metav1.NamespaceAll, metav1.ListOptions{})
+	assert.Nil(t, err)
+	var remaining []string
+	for _, pod := range remainingPods {
+		remaining = append(remaining, pod.Name)
+	}
+	assert.ElementsMatch(t, []string{"other-node", "daemon", "static"}, remaining)
+}
+
+func TestUninstallMasterNode(t *testing.T) {
+	client := useFakeK8s(t)
+	_, err := client.CoreV1().Nodes().Create(context.Background(), &apiv1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "master", Labels: map[string]string{K8sMasterNodeRole: ""}},
+	}, metav1.CreateOptions{})
+	assert.Nil(t, err)
+
+	// the control plane is refused before anything is done
+	assert.NotNil(t, UninstallNode("master"))
+	node, err := GetNode("master", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.False(t, node.Spec.Unschedulable)
+}
+
+func TestLeftoverKubeconfigs(t *testing.T) {
+	testCases := []struct {
+		name          string
+		output        string
+		expectedFiles []string
+	}{
+		{
+			name:          "clean",
+			output:        "",
+			expectedFiles: nil,
+		},
+		{
+			name:          "left",
+			output:        "/root/.kube/config\n/etc/kubernetes/kubelet.conf\n\n",
+			expectedFiles: []string{"/root/.kube/config", "/etc/kubernetes/kubelet.conf"},
+		},
+	}
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		assert.Equal(t, testCase.expectedFiles, leftoverKubeconfigs(testCase.output), fmt.Sprintf("%s: files are not expected", testCase.name))
+	}
+}
@@ -213,6 +213,15 @@ func (os *Openstack) ListAllVMs() ([]IaasVm, error) {
 
 // the unit of vcpu, ram, storage in the input is consistent with ResSet
 func (os *Openstack) CreateVM(name string, vcpu, ram, storage int) (*IaasVm, error) {
+	return os.createVM(name, vcpu, ram, storage, "")
+}
+
+// CreateVMWithUserData creates a VM whose user-data is given to cloud-init by the metadata service of Openstack
+func (os *Openstack) CreateVMWithUserData(name string, vcpu, ram, storage int, userData string) (*IaasVm, error) {
+	return os.createVM(name, vcpu, ram, storage, userData)
+}
+
+func (os *Openstack) createVM(name string, vcpu, ram, storage int, userData string) (*IaasVm, error) {
 	beego.Info(fmt.Sprintf("Cloud name [%s], type [%s], project id [%s], Create VM: %s", os.Name, os.Type, os.ProjectID, name))
 	// 1. create a volume
 	volumeOpts := volumes.CreateOpts{
@@ -270,6 +279,9 @@ func (os *Openstack) CreateVM(name string, vcpu, ram, storage int) (*IaasVm, err
 			{UUID: os.NetworkID},
 		},
 	}
+	if userData != "" {
+		baseVmOpts.UserData = []byte(userData)
+	}
 	vmOptsWithKeyPair := keypairs.CreateOptsExt{
 		CreateOptsBuilder: baseVmOpts,
 	}
@@ -313,9 +325,10 @@ func (os *Openstack) CreateVM(name string, vcpu, ram, storage int) (*IaasVm, err
 	sshIP := os.ExtractIPs(curVM)[0]
 
 	// Then, wait for SSH enabled. Then, SSH to the VM and execute commands to extend the disk partition.
-	beego.Info(fmt.Sprintf("Wait for VM %s able to be SSHed, ip %s", name, sshIP))
-
-	if len(os.SshPemPath) > 0 { // If the SSH private key is provided, we use it to SSH, otherwise, we use password to SSH
+	// With user-data, cloud-init extends the disk partition, so we do not need to SSH to the VM.
+	if userData != "" {
+		beego.Info(fmt.Sprintf("VM %s is initialized by cloud-init, so we do not SSH to it.", name))
+	} else if len(os.SshPemPath) > 0 { // If the SSH private key is provided, we use it to SSH, otherwise, we use password to SSH
 		beego.Info("use PEM SSH identity file to test SSH")
 		if err := WaitForSshPem(SshRootUser, os.SshPemPath, sshIP, SshPort, WaitForTimeOut); err != nil {
 			outErr := fmt.Errorf("wait for VM %s able to be SSHed, ip %s, error: %w", name, sshIP, err)
@@ -490,16 +503,22 @@ func IsOs404(err error) bool {
 
 // Choose the smallest flavor that can meet the requirements of RAM and vCPU
 func (os *Openstack) ChooseMinFlavor(allFlavors []flavors.Flavor, reqCpu, reqRam int) (flavors.Flavor, bool) {
-	var minFlavor flavors.Flavor
-	var found bool = false
 	computeQuota, err := os.GetComputeQuota()
 	if err != nil {
 		beego.Error(fmt.Sprintf("Get comput quota error: %s", err.Error()))
-		return minFlavor, found
+		return flavors.Flavor{}, false
 	}
 	beego.Info(fmt.Sprintf("Try to find a flavor to meet vCPU %d, RAM %d MiB. The vCPU Limit quota is %d, in use is %d. The RAM Limit quota is %d MiB, in use is %d MiB", reqCpu, reqRam, computeQuota.Cores.Limit, computeQuota.Cores.InUse, computeQuota.RAM.Limit, computeQuota.RAM.InUse))
 	remainingVcpu := computeQuota.Cores.Limit - computeQuota.Cores.InUse
 	remainingRam := computeQuota.RAM.Limit - computeQuota.RAM.InUse
+	return MinFlavorWithin(allFlavors, reqCpu, reqRam, remainingVcpu, remainingRam)
+}
+
+// Choose the smallest flavor that can meet the requirements of RAM and vCPU and does not exceed the remaining vCPU and RAM.
+// This function does not access Openstack, so auto-scheduling can also use it to simulate ChooseMinFlavor.
+func MinFlavorWithin(allFlavors []flavors.Flavor, reqCpu, reqRam, remainingVcpu, remainingRam int) (flavors.Flavor, bool) {
+	var minFlavor flavors.Flavor
+	var found bool = false
 	for i := 0; i < len(allFlavors); i++ {
 		if allFlavors[i].VCPUs < reqCpu || allFlavors[i].RAM < reqRam || allFlavors[i].VCPUs > remainingVcpu || allFlavors[i].RAM > remainingRam { // count meet the requirements
 			continue
@@ -509,7 +528,7 @@ func (os *Openstack) ChooseMinFlavor(allFlavors []flavors.Flavor, reqCpu, reqRam
 			found = true
 			continue
 		}
-		if os.overflowFlavor(allFlavors[i], reqCpu, reqRam) < os.overflowFlavor(minFlavor, reqCpu, reqRam) {
+		if overflowFlavor(allFlavors[i], reqCpu, reqRam) < overflowFlavor(minFlavor, reqCpu, reqRam) {
 			minFlavor = allFlavors[i]
 		}
 
@@ -517,8 +536,24 @@ func (os *Openstack) ChooseMinFlavor(allFlavors []flavors.Flavor, reqCpu, reqRam
 	return minFlavor, found
 }
 
+// Choose the biggest flavor that does not exceed the remaining vCPU and RAM
+func MaxFlavorWithin(allFlavors []flavors.Flavor, remainingVcpu, remainingRam int) (flavors.Flavor, bool) {
+	var maxFlavor flavors.Flavor
+	var found bool = false
+	for i := 0; i < len(allFlavors); i++ {
+		if allFlavors[i].VCPUs > remainingVcpu || allFlavors[i].RAM > remainingRam {
+			continue
+		}
+		if !found || allFlavors[i].RAM > maxFlavor.RAM || (allFlavors[i].RAM == maxFlavor.RAM && allFlavors[i].VCPUs > maxFlavor.VCPUs) {
+			maxFlavor = allFlavors[i]
+			found = true
+		}
+	}
+	return maxFlavor, found
+}
+
 // Calculate the amount that a flavor overflows the required RAM and vCPU
-func (os *Openstack) overflowFlavor(flavor flavors.Flavor, reqCpu, reqRam int) float64 {
+func overflowFlavor(flavor flavors.Flavor, reqCpu, reqRam int) float64 {
 	cpuOverflow := (float64(flavor.VCPUs) - float64(reqCpu)) / float64(reqCpu)
 	ramOverflow := (float64(flavor.RAM) - float64(reqRam)) / float64(reqRam)
 	return ramOverflow + cpuOverflow
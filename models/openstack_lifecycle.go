@@ -0,0 +1,125 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/astaxie/beego"
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/extensions/volumeactions"
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/v1/volumes"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/startstop"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+)
+
+// The lifecycle capabilities of Openstack: PowerController, Resizer.
+// Our VMs boot from volumes, so the snapshots of them are volume snapshots, which are not supported now.
+
+func (os *Openstack) waitForServerStatus(vmID, status, desc string) error {
+	beego.Info(fmt.Sprintf("Wait for VM %s status %s", vmID, status))
+	if err := servers.WaitForStatus(os.ComputeClient, vmID, status, WaitForTimeOut); err != nil {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], %s, wait for VM %s status %s, error: %w", os.Name, os.Type, desc, vmID, status, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	return nil
+}
+
+func (os *Openstack) StartVM(vmID string) error {
+	beego.Info(fmt.Sprintf("Cloud name [%s], type [%s], project id [%s], Start VM: %s", os.Name, os.Type, os.ProjectID, vmID))
+	if err := startstop.Start(os.ComputeClient, vmID).ExtractErr(); err != nil {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], start VM %s, error: %w", os.Name, os.Type, vmID, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	return os.waitForServerStatus(vmID, "ACTIVE", "start VM")
+}
+
+func (os *Openstack) StopVM(vmID string) error {
+	beego.Info(fmt.Sprintf("Cloud name [%s], type [%s], project id [%s], Stop VM: %s", os.Name, os.Type, os.ProjectID, vmID))
+	if err := startstop.Stop(os.ComputeClient, vmID).ExtractErr(); err != nil {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], stop VM %s, error: %w", os.Name, os.Type, vmID, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	return os.waitForServerStatus(vmID, "SHUTOFF", "stop VM")
+}
+
+// Nova asks the guest OS to shut down and stops the VM after a timeout, so it is the same as StopVM.
+func (os *Openstack) ShutdownVM(vmID string) error {
+	return os.StopVM(vmID)
+}
+
+func (os *Openstack) RebootVM(vmID string) error {
+	beego.Info(fmt.Sprintf("Cloud name [%s], type [%s], project id [%s], Reboot VM: %s", os.Name, os.Type, os.ProjectID, vmID))
+	if err := servers.Reboot(os.ComputeClient, vmID, servers.RebootOpts{Type: servers.SoftReboot}).ExtractErr(); err != nil {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], reboot VM %s, error: %w", os.Name, os.Type, vmID, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	return os.waitForServerStatus(vmID, "ACTIVE", "reboot VM")
+}
+
+// Openstack resizes a VM by changing its flavor, which always restarts the VM, so live resize is not supported.
+func (os *Openstack) ResizeVM(vmID string, vcpu, ram int, live bool) error {
+	beego.Info(fmt.Sprintf("Cloud name [%s], type [%s], project id [%s], Resize VM: %s, vcpu %d, ram %d MiB", os.Name, os.Type, os.ProjectID, vmID, vcpu, ram))
+	if live {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], resize VM %s, live resize is not supported by Openstack", os.Name, os.Type, vmID)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	allFlavors, err := os.ListAllFavors()
+	if err != nil {
+		outErr := fmt.Errorf("list flavors error: %w", err)
+		beego.Error(outErr)
+		return outErr
+	}
+	chosenFlavor, found := os.ChooseMinFlavor(allFlavors, vcpu, ram)
+	if !found {
+		outErr := fmt.Errorf("no flavor can meet the vCPU %d and RAM %d", vcpu, ram)
+		beego.Error(outErr)
+		return outErr
+	}
+	beego.Info(fmt.Sprintf("Chosen flavor for vcpu: %d, ram %d MiB is:\n%+v", vcpu, ram, chosenFlavor))
+
+	if err := servers.Resize(os.ComputeClient, vmID, servers.ResizeOpts{FlavorRef: chosenFlavor.ID}).ExtractErr(); err != nil {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], resize VM %s to flavor %s, error: %w", os.Name, os.Type, vmID, chosenFlavor.Name, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	if err := os.waitForServerStatus(vmID, "VERIFY_RESIZE", "resize VM"); err != nil {
+		return err
+	}
+	if err := servers.ConfirmResize(os.ComputeClient, vmID).ExtractErr(); err != nil {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], confirm resize VM %s, error: %w", os.Name, os.Type, vmID, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	return os.waitForServerStatus(vmID, "ACTIVE", "confirm resize VM")
+}
+
+// extend the volume that the VM boots from
+func (os *Openstack) ResizeVMDisk(vmID string, storage int) error {
+	beego.Info(fmt.Sprintf("Cloud name [%s], type [%s], project id [%s], Resize disk of VM: %s, storage %d GiB", os.Name, os.Type, os.ProjectID, vmID, storage))
+	server, err := os.GetServer(vmID)
+	if err != nil {
+		return err
+	}
+	if len(server.AttachedVolumes) == 0 {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], VM %s has no volume", os.Name, os.Type, vmID)
+		beego.Error(outErr)
+		return outErr
+	}
+	volumeID := server.AttachedVolumes[0].ID
+	if err := volumeactions.ExtendSize(os.StorageClient, volumeID, volumeactions.ExtendSizeOpts{NewSize: storage}).ExtractErr(); err != nil {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], extend volume %s of VM %s to %d GiB, error: %w", os.Name, os.Type, volumeID, vmID, storage, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	// the attached volume is "in-use" again after being extended
+	if err := volumes.WaitForStatus(os.StorageClient, volumeID, "in-use", WaitForTimeOut); err != nil {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], wait for extending volume %s of VM %s, error: %w", os.Name, os.Type, volumeID, vmID, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	return nil
+}
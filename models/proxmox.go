@@ -7,12 +7,14 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/astaxie/beego"
+	"golang.org/x/crypto/ssh"
 )
 
 // In Proxmox, the disks are not only used by VMs, but also by the server itself.
@@ -32,6 +34,8 @@ type Proxmox struct {
 	SshPemPath      string // the SSH identity file private key for the VMs on this cloud
 	RootPasswd      string // root password for SSH of VMs.
 	TemplateId      string // the ID of the VM template used to create new VMs
+	SnippetsStorage string // the Proxmox storage with the content type "snippets", where the user-data of VMs is put for cloud-init
+	SnippetsDir     string // the directory of SnippetsStorage on the Proxmox server
 
 	HTTPClient http.Client // used to call the API of proxmox
 }
@@ -45,6 +49,16 @@ func InitProxmox(paras map[string]interface{}) *Proxmox {
 	tokenName := paras["token_name"].(string)
 	tokenSecret := paras["token_secret"].(string)
 
+	// the template should have a cloud-init drive, and the user-data is a snippet in this storage. By default, it is the storage "local" of Proxmox.
+	snippetsStorage := getStr(paras, "snippets_storage")
+	if snippetsStorage == "" {
+		snippetsStorage = "local"
+	}
+	snippetsDir := getStr(paras, "snippets_dir")
+	if snippetsDir == "" {
+		snippetsDir = "/var/lib/vz/snippets"
+	}
+
 	// initialize the http client to call the API of proxmox
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{
@@ -67,6 +81,8 @@ func InitProxmox(paras map[string]interface{}) *Proxmox {
 		SshPemPath:      paras["sshpempath"].(string),
 		RootPasswd:      paras["root_password"].(string),
 		TemplateId:      paras["template_id"].(string),
+		SnippetsStorage: snippetsStorage,
+		SnippetsDir:     snippetsDir,
 		HTTPClient:      client,
 	}
 }
@@ -465,6 +481,105 @@ func (p *Proxmox) ConfigCoreRam(vmid, ramMB, cores int) ([]byte, error) {
 	return body, nil
 }
 
+// Config the custom cloud-init files of a VM, cicustom is like "user=local:snippets/xxx.yaml"
+func (p *Proxmox) ConfigCicustom(vmid int, cicustom string) ([]byte, error) {
+	beego.Info(fmt.Sprintf("Cloud name [%s], type [%s], Config VM [%d], cicustom [%s].", p.Name, p.Type, vmid, cicustom))
+
+	// send HTTP request to config the cloud-init of a VM
+	url := fmt.Sprintf("https://%s/api2/json/nodes/%s/qemu/%d/config", p.Endpoint, p.Name, vmid)
+
+	var reqBody map[string]interface{} = map[string]interface{}{
+		"cicustom": cicustom,
+	}
+	reqBodyJson, err := json.Marshal(reqBody)
+	if err != nil {
+		outErr := fmt.Errorf("json.Marshal: %+v, error: %w", reqBody, err)
+		beego.Error(outErr)
+		return nil, outErr
+	}
+
+	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(reqBodyJson))
+	if err != nil {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], Config VM [%d], cicustom [%s], construct request, error: %w", p.Name, p.Type, vmid, cicustom, err)
+		beego.Error(outErr)
+		return nil, outErr
+	}
+	req.Header.Add("Authorization", p.AuthHeader)
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], Config VM [%d], cicustom [%s], do HTTP request, error: %w", p.Name, p.Type, vmid, cicustom, err)
+		beego.Error(outErr)
+		return nil, outErr
+	}
+	defer resp.Body.Close()
+	beego.Info(fmt.Sprintf("HTTP Status is [%s], HTTP Status Code is [%d]", resp.Status, resp.StatusCode))
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], Config VM [%d], cicustom [%s], read response body, error: %w", p.Name, p.Type, vmid, cicustom, err)
+		beego.Error(outErr)
+		return nil, outErr
+	}
+	beego.Info(fmt.Sprintf("HTTP response body is [%s].", string(body)))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], Config VM [%d], cicustom [%s], HTTP response status code is [%d]", p.Name, p.Type, vmid, cicustom, resp.StatusCode)
+		beego.Error(outErr)
+		return nil, outErr
+	}
+
+	beego.Info(fmt.Sprintf("Successful! Cloud name [%s], type [%s], Config VM [%d], cicustom [%s].", p.Name, p.Type, vmid, cicustom))
+	return body, nil
+}
+
+// the file name of the user-data snippet of a VM
+func proxmoxUserDataSnippet(vmid string) string {
+	return fmt.Sprintf("mcm-%s-user-data.yaml", vmid)
+}
+
+// The Proxmox API cannot upload snippets, so we SSH to the Proxmox server to write the user-data snippet.
+func (p *Proxmox) writeUserDataSnippet(sshClient *ssh.Client, vmid string, userData string) error {
+	snippetPath := path.Join(p.SnippetsDir, proxmoxUserDataSnippet(vmid))
+	// The user-data has the token to join Kubernetes, so we write it through SFTP rather than in a command line, and only root can read it.
+	if err := SftpWriteFile([]byte(userData), snippetPath, 0600, sshClient); err != nil {
+		return fmt.Errorf("write user-data snippet [%s] on Proxmox node %s error: %w", snippetPath, p.IP, err)
+	}
+	return nil
+}
+
+// Whether the VM uses the user-data snippet written by writeUserDataSnippet, which we know by its "cicustom" config.
+func (p *Proxmox) hasUserDataSnippet(vmid string) (bool, error) {
+	qemuConfigBytes, err := p.GetQemuConfig(vmid)
+	if err != nil {
+		return false, fmt.Errorf("get qemu config id [%s], error: %w", vmid, err)
+	}
+	if err := p.CheckErrInResp(qemuConfigBytes); err != nil {
+		return false, fmt.Errorf("get qemu config id [%s], error in resp: %w", vmid, err)
+	}
+	var qemuConfig map[string]interface{}
+	if err := json.Unmarshal(qemuConfigBytes, &qemuConfig); err != nil {
+		return false, fmt.Errorf("get qemu config id [%s], Unmarshal qemuBytes, error: %w", vmid, err)
+	}
+	data, _ := qemuConfig["data"].(map[string]interface{})
+	cicustom, _ := data["cicustom"].(string)
+	return strings.Contains(cicustom, proxmoxUserDataSnippet(vmid)), nil
+}
+
+// Remove the user-data snippet of a VM. It is best-effort, because the VM is already deleted when we call it.
+func (p *Proxmox) removeUserDataSnippet(vmid string) {
+	snippetPath := path.Join(p.SnippetsDir, proxmoxUserDataSnippet(vmid))
+	sshClient, err := SshClientWithPasswd(p.ProxmoxUser, p.ProxmoxPassword, p.IP, SshPort)
+	if err != nil {
+		beego.Warn(fmt.Sprintf("Cloud name [%s], type [%s], remove user-data snippet [%s], SshClientWithPasswd error: %s", p.Name, p.Type, snippetPath, err.Error()))
+		return
+	}
+	defer sshClient.Close()
+	if _, err := SshOneCommand(sshClient, fmt.Sprintf("rm -f %s", snippetPath)); err != nil {
+		beego.Warn(fmt.Sprintf("Cloud name [%s], type [%s], remove user-data snippet [%s], error: %s", p.Name, p.Type, snippetPath, err.Error()))
+	}
+}
+
 // Get the config of a Qemu
 func (p *Proxmox) GetQemuConfig(vmid string) ([]byte, error) {
 	beego.Info(fmt.Sprintf("Cloud name [%s], type [%s], get qemu config ID [%s].", p.Name, p.Type, vmid))
@@ -963,6 +1078,15 @@ func (p *Proxmox) ListAllVMs() ([]IaasVm, error) {
 
 // the unit of vcpu, ram, storage in the input is consistent with ResSet
 func (p *Proxmox) CreateVM(name string, vcpu, ram, storage int) (*IaasVm, error) {
+	return p.createVM(name, vcpu, ram, storage, "")
+}
+
+// CreateVMWithUserData creates a VM whose user-data is given to the cloud-init drive of the template by "cicustom"
+func (p *Proxmox) CreateVMWithUserData(name string, vcpu, ram, storage int, userData string) (*IaasVm, error) {
+	return p.createVM(name, vcpu, ram, storage, userData)
+}
+
+func (p *Proxmox) createVM(name string, vcpu, ram, storage int, userData string) (*IaasVm, error) {
 	beego.Info(fmt.Sprintf("Cloud name [%s], type [%s], Create VM: %s", p.Name, p.Type, name))
 
 	// 1. Find the first available VM ID, and use it to create this VM
@@ -1113,6 +1237,28 @@ func (p *Proxmox) CreateVM(name string, vcpu, ram, storage int) (*IaasVm, error)
 	}
 	beego.Info(fmt.Sprintf("Successful! SSH to the Proxmox node [%s] to run command [%s] to refresh the state to fix the Proxmox bug. output: %s", p.IP, qmRescanCmd, output))
 
+	// With user-data, we put it as a snippet on the Proxmox node, and let the cloud-init drive of the VM use it.
+	if userData != "" {
+		if err := p.writeUserDataSnippet(sshClient, vmidStr, userData); err != nil {
+			outErr := fmt.Errorf("Cloud name [%s], type [%s], CreateVM [%s], error: %w", p.Name, p.Type, name, err)
+			beego.Error(outErr)
+			return nil, outErr
+		}
+		cicustom := fmt.Sprintf("user=%s:snippets/%s", p.SnippetsStorage, proxmoxUserDataSnippet(vmidStr))
+		cicustomRespBytes, err := p.ConfigCicustom(vmid, cicustom)
+		time.Sleep(ProxmoxAPIInterval)
+		if err != nil {
+			outErr := fmt.Errorf("Cloud name [%s], type [%s], CreateVM [%s], config cicustom [%s], error: %w", p.Name, p.Type, name, cicustom, err)
+			beego.Error(outErr)
+			return nil, outErr
+		}
+		if err := p.CheckErrInResp(cicustomRespBytes); err != nil {
+			outErr := fmt.Errorf("Cloud name [%s], type [%s], CreateVM [%s], config cicustom [%s], in resp error: %w", p.Name, p.Type, name, cicustom, err)
+			beego.Error(outErr)
+			return nil, outErr
+		}
+	}
+
 	// 5. Start the VM
 	startResqBytes, err := p.StartQemu(vmid)
 	time.Sleep(ProxmoxAPIInterval)
@@ -1141,7 +1287,10 @@ func (p *Proxmox) CreateVM(name string, vcpu, ram, storage int) (*IaasVm, error)
 	beego.Info(fmt.Sprintf("found IPs [%v], we use ip [%s] to ssh", vmIPs, sshIP))
 
 	// Then, wait for SSH enabled. Then, SSH to the VM and execute commands to extend the disk partition.
-	if len(p.SshPemPath) > 0 {
+	// With user-data, cloud-init extends the disk partition, so we do not need to SSH to the VM.
+	if userData != "" {
+		beego.Info(fmt.Sprintf("VM %s is initialized by cloud-init, so we do not SSH to it.", name))
+	} else if len(p.SshPemPath) > 0 {
 		beego.Info("use PEM SSH identity file to test SSH")
 		if err := WaitForSshPem(SshRootUser, p.SshPemPath, sshIP, SshPort, WaitForTimeOut); err != nil {
 			outErr := fmt.Errorf("wait for VM %s able to be SSHed, ip %s, error: %w", name, sshIP, err)
@@ -1185,6 +1334,14 @@ func (p *Proxmox) DeleteVM(vmid string) error {
 		return outErr
 	}
 
+	// We need to know whether the VM has the user-data snippet before deleting it, because its config is deleted with it.
+	// If we cannot know it, we still try to remove the snippet, because it has the token to join Kubernetes.
+	hasSnippet, err := p.hasUserDataSnippet(vmid)
+	if err != nil {
+		beego.Warn(fmt.Sprintf("Cloud name [%s], type [%s], DeleteVM [%s], check whether the VM has a user-data snippet, error: %s", p.Name, p.Type, vmid, err.Error()))
+		hasSnippet = true
+	}
+
 	// 1. Stop the VM
 	stopRespBytes, err := p.StopQemu(vmid)
 	time.Sleep(ProxmoxAPIInterval)
@@ -1258,6 +1415,11 @@ func (p *Proxmox) DeleteVM(vmid string) error {
 	}
 	beego.Info(fmt.Sprintf("Task [%s] is already finished successfully.", dqUpid))
 
+	// 4. Remove the user-data snippet if the VM was created with user-data
+	if hasSnippet {
+		p.removeUserDataSnippet(vmid)
+	}
+
 	beego.Info(fmt.Sprintf("Successful! Cloud name [%s], type [%s], Delete VM: %s", p.Name, p.Type, vmid))
 
 	return nil
@@ -0,0 +1,313 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/astaxie/beego"
+)
+
+// The lifecycle capabilities of Proxmox: PowerController, Resizer, Snapshotter
+
+var (
+	// the VM IDs are put in the paths of the APIs, so we only accept numbers
+	proxmoxVmidRegexp = regexp.MustCompile(`^[1-9][0-9]*$`)
+	// the format of snapshot names accepted by Proxmox, which can be at most proxmoxSnapshotNameMaxLen characters
+	proxmoxSnapshotNameRegexp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+)
+
+const proxmoxSnapshotNameMaxLen int = 40
+
+func (p *Proxmox) checkVmid(vmid string) (int, error) {
+	if !proxmoxVmidRegexp.MatchString(vmid) {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], invalid VM ID [%s], it should be a positive number", p.Name, p.Type, vmid)
+		beego.Error(outErr)
+		return 0, outErr
+	}
+	vmidInt, err := strconv.Atoi(vmid)
+	if err != nil {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], invalid VM ID [%s]: %w", p.Name, p.Type, vmid, err)
+		beego.Error(outErr)
+		return 0, outErr
+	}
+	return vmidInt, nil
+}
+
+func (p *Proxmox) checkSnapshotName(snapshotName string) error {
+	if !proxmoxSnapshotNameRegexp.MatchString(snapshotName) || len(snapshotName) > proxmoxSnapshotNameMaxLen {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], invalid snapshot name [%s], it should start with a letter, only contain letters, digits, \"_\" and \"-\", and have at most %d characters", p.Name, p.Type, snapshotName, proxmoxSnapshotNameMaxLen)
+		beego.Error(outErr)
+		return outErr
+	}
+	return nil
+}
+
+// send an HTTP request to the Proxmox API. desc describes the request in logs. reqBody can be nil.
+func (p *Proxmox) sendRequest(method, apiPath string, reqBody map[string]interface{}, desc string) ([]byte, error) {
+	beego.Info(fmt.Sprintf("Cloud name [%s], type [%s], %s.", p.Name, p.Type, desc))
+
+	reqUrl := fmt.Sprintf("https://%s/api2/json/nodes/%s/%s", p.Endpoint, p.Name, apiPath)
+
+	var reqBodyReader io.Reader
+	if reqBody != nil {
+		reqBodyJson, err := json.Marshal(reqBody)
+		if err != nil {
+			outErr := fmt.Errorf("json.Marshal: %+v, error: %w", reqBody, err)
+			beego.Error(outErr)
+			return nil, outErr
+		}
+		reqBodyReader = bytes.NewBuffer(reqBodyJson)
+	}
+
+	req, err := http.NewRequest(method, reqUrl, reqBodyReader)
+	if err != nil {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], %s, construct request, error: %w", p.Name, p.Type, desc, err)
+		beego.Error(outErr)
+		return nil, outErr
+	}
+	req.Header.Add("Authorization", p.AuthHeader)
+	if reqBody != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], %s, do HTTP request, error: %w", p.Name, p.Type, desc, err)
+		beego.Error(outErr)
+		return nil, outErr
+	}
+	defer resp.Body.Close()
+	beego.Info(fmt.Sprintf("HTTP Status is [%s], HTTP Status Code is [%d]", resp.Status, resp.StatusCode))
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], %s, read response body, error: %w", p.Name, p.Type, desc, err)
+		beego.Error(outErr)
+		return nil, outErr
+	}
+	beego.Info(fmt.Sprintf("HTTP response body is [%s].", string(body)))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], %s, HTTP response status code is [%d]", p.Name, p.Type, desc, resp.StatusCode)
+		beego.Error(outErr)
+		return nil, outErr
+	}
+
+	beego.Info(fmt.Sprintf("Successful! Cloud name [%s], type [%s], %s.", p.Name, p.Type, desc))
+	return body, nil
+}
+
+// Many Proxmox APIs create a task and return its UPID in "data". This function waits for the task to be finished.
+func (p *Proxmox) waitForTaskInResp(respBytes []byte, reqErr error, desc string) error {
+	time.Sleep(ProxmoxAPIInterval)
+	if reqErr != nil {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], %s, error: %w", p.Name, p.Type, desc, reqErr)
+		beego.Error(outErr)
+		return outErr
+	}
+	if err := p.CheckErrInResp(respBytes); err != nil {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], %s, error in resp: %w", p.Name, p.Type, desc, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	var resp map[string]interface{}
+	if err := json.Unmarshal(respBytes, &resp); err != nil {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], %s, unmarshal resp, error: %w", p.Name, p.Type, desc, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	upid, ok := resp["data"].(string)
+	if !ok || upid == "" { // some APIs finish synchronously without a task
+		return nil
+	}
+	beego.Info(fmt.Sprintf("Wait for Task [%s] is finished.", upid))
+	if err := p.waitForTaskFinished(WaitForTimeOut, 5, upid); err != nil {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], %s, waitForTaskFinished, error: %w", p.Name, p.Type, desc, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	beego.Info(fmt.Sprintf("Task [%s] is already finished successfully.", upid))
+	return nil
+}
+
+func (p *Proxmox) StartVM(vmid string) error {
+	vmidInt, err := p.checkVmid(vmid)
+	if err != nil {
+		return err
+	}
+	respBytes, err := p.StartQemu(vmidInt)
+	return p.waitForTaskInResp(respBytes, err, fmt.Sprintf("start VM [%s]", vmid))
+}
+
+func (p *Proxmox) StopVM(vmid string) error {
+	if _, err := p.checkVmid(vmid); err != nil {
+		return err
+	}
+	respBytes, err := p.StopQemu(vmid)
+	return p.waitForTaskInResp(respBytes, err, fmt.Sprintf("stop VM [%s]", vmid))
+}
+
+func (p *Proxmox) ShutdownVM(vmid string) error {
+	if _, err := p.checkVmid(vmid); err != nil {
+		return err
+	}
+	respBytes, err := p.ShutdownQemu(vmid)
+	return p.waitForTaskInResp(respBytes, err, fmt.Sprintf("shutdown VM [%s]", vmid))
+}
+
+func (p *Proxmox) RebootVM(vmid string) error {
+	if _, err := p.checkVmid(vmid); err != nil {
+		return err
+	}
+	desc := fmt.Sprintf("reboot VM [%s]", vmid)
+	respBytes, err := p.sendRequest("POST", fmt.Sprintf("qemu/%s/status/reboot", vmid), nil, desc)
+	return p.waitForTaskInResp(respBytes, err, desc)
+}
+
+// Without live, the VM is shut down before the change of CPU and memory, and started afterward.
+// With live, the change is applied immediately only if CPU and memory hotplug are enabled in the VM, otherwise Proxmox keeps it pending until the next start.
+func (p *Proxmox) ResizeVM(vmid string, vcpu, ram int, live bool) error {
+	vmidInt, err := p.checkVmid(vmid)
+	if err != nil {
+		return err
+	}
+
+	wasRunning := false
+	if !live {
+		vm, err := p.GetVM(vmid)
+		if err != nil {
+			return err
+		}
+		wasRunning = vm.Status == ProxQSRunning
+	}
+	if wasRunning {
+		if err := p.ShutdownVM(vmid); err != nil {
+			return err
+		}
+		if err := p.waitForQemuStopped(WaitForTimeOut, 5, vmid); err != nil {
+			outErr := fmt.Errorf("Cloud name [%s], type [%s], resize VM [%s], waitForQemuStopped, error: %w", p.Name, p.Type, vmid, err)
+			beego.Error(outErr)
+			return outErr
+		}
+	}
+
+	respBytes, err := p.ConfigCoreRam(vmidInt, ram, vcpu)
+	time.Sleep(ProxmoxAPIInterval)
+	if err != nil {
+		return err
+	}
+	if err := p.CheckErrInResp(respBytes); err != nil {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], resize VM [%s] to ram [%d]MB cpu [%d], in resp error: %w", p.Name, p.Type, vmid, ram, vcpu, err)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	if wasRunning {
+		return p.StartVM(vmid)
+	}
+	return nil
+}
+
+// Proxmox can only grow disks
+func (p *Proxmox) ResizeVMDisk(vmid string, storage int) error {
+	vmidInt, err := p.checkVmid(vmid)
+	if err != nil {
+		return err
+	}
+	diskName, err := p.getDiskName(vmid)
+	time.Sleep(ProxmoxAPIInterval)
+	if err != nil {
+		return err
+	}
+	diskSize := fmt.Sprintf("%dG", storage)
+	if err := p.waitForResizeDisk(WaitForTimeOut, 5, vmidInt, diskName, diskSize); err != nil {
+		outErr := fmt.Errorf("Cloud name [%s], type [%s], resize disk [%s] of VM [%s] to [%s], error: %w", p.Name, p.Type, diskName, vmid, diskSize, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	return nil
+}
+
+func (p *Proxmox) CreateSnapshot(vmid, snapshotName string) error {
+	if _, err := p.checkVmid(vmid); err != nil {
+		return err
+	}
+	if err := p.checkSnapshotName(snapshotName); err != nil {
+		return err
+	}
+	desc := fmt.Sprintf("create snapshot [%s] of VM [%s]", snapshotName, vmid)
+	respBytes, err := p.sendRequest("POST", fmt.Sprintf("qemu/%s/snapshot", vmid), map[string]interface{}{"snapname": snapshotName}, desc)
+	return p.waitForTaskInResp(respBytes, err, desc)
+}
+
+func (p *Proxmox) ListSnapshots(vmid string) ([]VmSnapshot, error) {
+	if _, err := p.checkVmid(vmid); err != nil {
+		return nil, err
+	}
+	desc := fmt.Sprintf("list snapshots of VM [%s]", vmid)
+	respBytes, err := p.sendRequest("GET", fmt.Sprintf("qemu/%s/snapshot", vmid), nil, desc)
+	if err != nil {
+		return nil, err
+	}
+	return parseProxmoxSnapshots(respBytes)
+}
+
+// parse the response of the API to list snapshots. The item "current" is the current state of the VM rather than a snapshot.
+func parseProxmoxSnapshots(respBytes []byte) ([]VmSnapshot, error) {
+	var resp struct {
+		Data []struct {
+			Name        string  `json:"name"`
+			Description string  `json:"description"`
+			SnapTime    float64 `json:"snaptime"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(respBytes, &resp); err != nil {
+		return nil, fmt.Errorf("unmarshal snapshots, error: %w", err)
+	}
+	snapshots := []VmSnapshot{}
+	for _, item := range resp.Data {
+		if item.Name == "current" {
+			continue
+		}
+		snapshot := VmSnapshot{Name: item.Name, Description: item.Description}
+		if item.SnapTime > 0 {
+			snapshot.CreatedAt = time.Unix(int64(item.SnapTime), 0).UTC().Format(time.RFC3339)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt < snapshots[j].CreatedAt
+	})
+	return snapshots, nil
+}
+
+func (p *Proxmox) RevertSnapshot(vmid, snapshotName string) error {
+	if _, err := p.checkVmid(vmid); err != nil {
+		return err
+	}
+	if err := p.checkSnapshotName(snapshotName); err != nil {
+		return err
+	}
+	desc := fmt.Sprintf("rollback VM [%s] to snapshot [%s]", vmid, snapshotName)
+	respBytes, err := p.sendRequest("POST", fmt.Sprintf("qemu/%s/snapshot/%s/rollback", vmid, url.PathEscape(snapshotName)), nil, desc)
+	return p.waitForTaskInResp(respBytes, err, desc)
+}
+
+func (p *Proxmox) DeleteSnapshot(vmid, snapshotName string) error {
+	if _, err := p.checkVmid(vmid); err != nil {
+		return err
+	}
+	if err := p.checkSnapshotName(snapshotName); err != nil {
+		return err
+	}
+	desc := fmt.Sprintf("delete snapshot [%s] of VM [%s]", snapshotName, vmid)
+	respBytes, err := p.sendRequest("DELETE", fmt.Sprintf("qemu/%s/snapshot/%s", vmid, url.PathEscape(snapshotName)), nil, desc)
+	return p.waitForTaskInResp(respBytes, err, desc)
+}
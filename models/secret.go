@@ -0,0 +1,401 @@
+package models
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/astaxie/beego"
+)
+
+/**
+NOTE:
+
+Passwords, tokens, and credentials should not be written in plaintext in conf/app.conf or conf/iaas.json. Instead, a value can reference a secret as "secret://<name>", and the secret is read from the secret providers in the order of "SecretProviders" in app.conf. Three providers are supported:
+1. env: the environment variable "SecretEnvPrefix" + the upper-case name, in which the characters other than letters and digits are replaced by '_'. For example, "secret://mysql-passwd" is MCM_SECRET_MYSQL_PASSWD.
+2. dir: the file "SecretDir"/<name>, such as a Kubernetes Secret or a Docker secret mounted as a directory.
+3. file: the entry <name> in the encrypted file "SecretFile". The entries are encrypted by AES-256-GCM with the master key, which is read from the environment variable MCM_SECRET_MASTER_KEY or from the file "SecretMasterKeyPath". An entry can be set by "emcontroller -set-secret <name>", which reads the value from stdin.
+   The master key is used as the AES key directly, so it should be 32 random bytes encoded in base64 or hex, such as the output of "openssl rand -base64 32". A password chosen by a person could be guessed offline against the encrypted file, so it is rejected.
+A value without the prefix "secret://" is used as it is, so plaintext values still work.
+
+The secrets in iaas.json are read when a cloud is initialized. If only a secret is changed, the cloud should be updated by PUT /cloud/:cloudName or by restarting emcontroller.
+*/
+
+const (
+	SecretRefPrefix string = "secret://"
+
+	SecretProviderEnv  string = "env"
+	SecretProviderDir  string = "dir"
+	SecretProviderFile string = "file"
+
+	DefaultSecretEnvPrefix string      = "MCM_SECRET_"
+	SecretMasterKeyEnv     string      = "MCM_SECRET_MASTER_KEY"
+	DefaultSecretProviders string      = SecretProviderEnv + "," + SecretProviderDir + "," + SecretProviderFile
+	defaultSecretDir       string      = "/etc/emcontroller/secrets"
+	defaultSecretFile      string      = "conf/secrets.enc.json"
+	secretFileMode         os.FileMode = 0600
+	secretMasterKeyLen     int         = 32 // the key of AES-256
+)
+
+// ErrSecretNotFound means that a provider does not have the secret, so the next provider should be tried.
+var ErrSecretNotFound = errors.New("secret not found")
+
+var (
+	secretProvidersMu sync.RWMutex
+	secretProviders   []SecretProvider
+)
+
+// SecretProvider reads secrets from one backend.
+type SecretProvider interface {
+	Name() string
+	// GetSecret returns ErrSecretNotFound if the provider does not have the secret.
+	GetSecret(secretName string) (string, error)
+}
+
+// EnvSecretProvider reads secrets from environment variables.
+type EnvSecretProvider struct {
+	Prefix string
+}
+
+func (p EnvSecretProvider) Name() string {
+	return SecretProviderEnv
+}
+
+// the environment variable of a secret, e.g., "mysql-passwd" is "MCM_SECRET_MYSQL_PASSWD"
+func (p EnvSecretProvider) envName(secretName string) string {
+	return p.Prefix + strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		return '_'
+	}, secretName)
+}
+
+func (p EnvSecretProvider) GetSecret(secretName string) (string, error) {
+	value, found := os.LookupEnv(p.envName(secretName))
+	if !found {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
+
+// DirSecretProvider reads secrets from the files in a directory, one file per secret.
+type DirSecretProvider struct {
+	Dir string
+}
+
+func (p DirSecretProvider) Name() string {
+	return SecretProviderDir
+}
+
+func (p DirSecretProvider) GetSecret(secretName string) (string, error) {
+	if err := checkSecretName(secretName); err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(filepath.Join(p.Dir, secretName))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrSecretNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("read secret [%s] in directory [%s], error: %w", secretName, p.Dir, err)
+	}
+	// the mounted files often end with a newline
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// EncryptedFileSecretProvider reads secrets from a JSON file, in which every value is encrypted by the master key.
+type EncryptedFileSecretProvider struct {
+	Path      string
+	MasterKey string
+}
+
+func (p EncryptedFileSecretProvider) Name() string {
+	return SecretProviderFile
+}
+
+func (p EncryptedFileSecretProvider) GetSecret(secretName string) (string, error) {
+	entries, err := readEncryptedSecrets(p.Path)
+	if err != nil {
+		return "", err
+	}
+	encrypted, found := entries[secretName]
+	if !found {
+		return "", ErrSecretNotFound
+	}
+	if len(p.MasterKey) == 0 {
+		return "", fmt.Errorf("secret [%s] is in [%s], but the master key is not provided", secretName, p.Path)
+	}
+	value, err := decryptSecret(p.MasterKey, encrypted)
+	if err != nil {
+		return "", fmt.Errorf("decrypt secret [%s] in [%s], error: %w", secretName, p.Path, err)
+	}
+	return value, nil
+}
+
+// SetSecret encrypts the value and saves it in the file. Other entries in the file are kept.
+func (p EncryptedFileSecretProvider) SetSecret(secretName, value string) error {
+	if len(p.MasterKey) == 0 {
+		return fmt.Errorf("the master key is not provided")
+	}
+	if err := checkSecretName(secretName); err != nil {
+		return err
+	}
+	entries, err := readEncryptedSecrets(p.Path)
+	if err != nil {
+		return err
+	}
+	encrypted, err := encryptSecret(p.MasterKey, value)
+	if err != nil {
+		return fmt.Errorf("encrypt secret [%s], error: %w", secretName, err)
+	}
+	entries[secretName] = encrypted
+
+	content, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal the secrets, error: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(p.Path), 0700); err != nil {
+		return fmt.Errorf("create the directory of [%s], error: %w", p.Path, err)
+	}
+	// write a temporary file and rename it, so that a reader never gets a half-written file
+	tmpPath := p.Path + ".tmp"
+	if err := os.WriteFile(tmpPath, append(content, '\n'), secretFileMode); err != nil {
+		return fmt.Errorf("write [%s], error: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, p.Path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("rename [%s] to [%s], error: %w", tmpPath, p.Path, err)
+	}
+	return nil
+}
+
+// a missing file has no secrets
+func readEncryptedSecrets(path string) (map[string]string, error) {
+	entries := make(map[string]string)
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read secret file [%s], error: %w", path, err)
+	}
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return nil, fmt.Errorf("unmarshal secret file [%s], error: %w", path, err)
+	}
+	return entries, nil
+}
+
+// parseSecretMasterKey decodes the master key, which should be secretMasterKeyLen random bytes encoded in base64 or hex.
+func parseSecretMasterKey(masterKey string) ([]byte, error) {
+	masterKey = strings.TrimSpace(masterKey)
+	if key, err := hex.DecodeString(masterKey); err == nil && len(key) == secretMasterKeyLen {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(masterKey); err == nil && len(key) == secretMasterKeyLen {
+		return key, nil
+	}
+	return nil, fmt.Errorf("the master key should be %d random bytes encoded in base64 or hex, such as the output of \"openssl rand -base64 %d\"", secretMasterKeyLen, secretMasterKeyLen)
+}
+
+func secretGcm(masterKey string) (cipher.AEAD, error) {
+	key, err := parseSecretMasterKey(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// the result is base64(nonce + ciphertext)
+func encryptSecret(masterKey, value string) (string, error) {
+	gcm, err := secretGcm(masterKey)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(value), nil)), nil
+}
+
+func decryptSecret(masterKey, encrypted string) (string, error) {
+	gcm, err := secretGcm(masterKey)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("the encrypted value is too short")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("wrong master key or broken value: %w", err)
+	}
+	return string(plain), nil
+}
+
+// a secret name is also a file name, so it cannot go out of the directory
+func checkSecretName(secretName string) error {
+	if len(secretName) == 0 || secretName == "." || secretName == ".." || strings.ContainsAny(secretName, `/\`) {
+		return fmt.Errorf("invalid secret name [%s]", secretName)
+	}
+	return nil
+}
+
+// IsSecretRef tells whether a config value references a secret.
+func IsSecretRef(value string) bool {
+	return strings.HasPrefix(value, SecretRefPrefix)
+}
+
+// ResolveSecret returns the secret if the value is "secret://<name>", otherwise, it returns the value itself.
+func ResolveSecret(value string) (string, error) {
+	if !IsSecretRef(value) {
+		return value, nil
+	}
+	secretName := strings.TrimPrefix(value, SecretRefPrefix)
+
+	secretProvidersMu.RLock()
+	providers := secretProviders
+	secretProvidersMu.RUnlock()
+
+	for _, provider := range providers {
+		secret, err := provider.GetSecret(secretName)
+		if errors.Is(err, ErrSecretNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("secret provider [%s], error: %w", provider.Name(), err)
+		}
+		return secret, nil
+	}
+	return "", fmt.Errorf("secret [%s] is not found in the secret providers %v", secretName, secretProviderNames(providers))
+}
+
+func secretProviderNames(providers []SecretProvider) []string {
+	var names []string
+	for _, provider := range providers {
+		names = append(names, provider.Name())
+	}
+	return names
+}
+
+// SetSecretProviders sets the providers used by ResolveSecret, in order.
+func SetSecretProviders(providers ...SecretProvider) {
+	secretProvidersMu.Lock()
+	defer secretProvidersMu.Unlock()
+	secretProviders = providers
+}
+
+// the master key of the encrypted secret file
+func secretMasterKey() (string, error) {
+	if masterKey := os.Getenv(SecretMasterKeyEnv); len(masterKey) > 0 {
+		return masterKey, nil
+	}
+	masterKeyPath := beego.AppConfig.String("SecretMasterKeyPath")
+	if len(masterKeyPath) == 0 {
+		return "", nil
+	}
+	content, err := os.ReadFile(masterKeyPath)
+	if err != nil {
+		return "", fmt.Errorf("read the master key file [%s], error: %w", masterKeyPath, err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// the encrypted secret file configured in app.conf
+func ConfiguredSecretFile() (EncryptedFileSecretProvider, error) {
+	masterKey, err := secretMasterKey()
+	if err != nil {
+		return EncryptedFileSecretProvider{}, err
+	}
+	// an invalid master key is rejected at once, but not when it is not provided, because the secret file may not be used.
+	if len(masterKey) > 0 {
+		if _, err := parseSecretMasterKey(masterKey); err != nil {
+			return EncryptedFileSecretProvider{}, err
+		}
+	}
+	return EncryptedFileSecretProvider{
+		Path:      beego.AppConfig.DefaultString("SecretFile", defaultSecretFile),
+		MasterKey: masterKey,
+	}, nil
+}
+
+// InitSecrets sets the secret providers according to app.conf, and resolves the secrets in app.conf.
+func InitSecrets() {
+	var providers []SecretProvider
+	for _, providerName := range strings.Split(beego.AppConfig.DefaultString("SecretProviders", DefaultSecretProviders), ",") {
+		switch strings.TrimSpace(providerName) {
+		case SecretProviderEnv:
+			providers = append(providers, EnvSecretProvider{Prefix: beego.AppConfig.DefaultString("SecretEnvPrefix", DefaultSecretEnvPrefix)})
+		case SecretProviderDir:
+			providers = append(providers, DirSecretProvider{Dir: beego.AppConfig.DefaultString("SecretDir", defaultSecretDir)})
+		case SecretProviderFile:
+			fileProvider, err := ConfiguredSecretFile()
+			if err != nil {
+				outErr := fmt.Errorf("Initialize the secret provider [%s], error: %w", SecretProviderFile, err)
+				beego.Error(outErr)
+				panic(outErr)
+			}
+			providers = append(providers, fileProvider)
+		case "":
+		default:
+			beego.Warn(fmt.Sprintf("Secret provider [%s] in \"SecretProviders\" is not supported, so we skip it.", providerName))
+		}
+	}
+	SetSecretProviders(providers...)
+	beego.Info(fmt.Sprintf("The secret providers are %v.", secretProviderNames(providers)))
+
+	mySqlPasswd, err := ResolveSecret(MySqlPasswd)
+	if err != nil {
+		outErr := fmt.Errorf("Resolve config \"MySqlPasswd\", error: %w", err)
+		beego.Error(outErr)
+		panic(outErr)
+	}
+	MySqlPasswd = mySqlPasswd
+}
+
+// resolve the secrets in the declaration of a cloud. The declaration itself is not changed, so that the references, not the secrets, are written back to conf/iaas.json.
+func resolveCloudSecrets(paras map[string]interface{}) (map[string]interface{}, error) {
+	resolved := make(map[string]interface{}, len(paras))
+	var problems []string
+	for key, value := range paras {
+		resolved[key] = value
+		strValue, ok := value.(string)
+		if !ok || !IsSecretRef(strValue) {
+			continue
+		}
+		secret, err := ResolveSecret(strValue)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("field [%s]: %s", key, err.Error()))
+			continue
+		}
+		resolved[key] = secret
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		cloudName, _ := paras["name"].(string)
+		return nil, &CloudConfigError{Cloud: cloudName, Problems: problems}
+	}
+	return resolved, nil
+}
@@ -0,0 +1,244 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// the master keys in the tests, 32 bytes in base64
+const (
+	testSecretMasterKey      string = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+	testSecretWrongMasterKey string = "ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA="
+)
+
+// use the given secret providers in a test
+func useSecretProviders(t *testing.T, providers ...SecretProvider) {
+	secretProvidersMu.RLock()
+	oldProviders := secretProviders
+	secretProvidersMu.RUnlock()
+	t.Cleanup(func() {
+		SetSecretProviders(oldProviders...)
+	})
+	SetSecretProviders(providers...)
+}
+
+func TestResolveSecret(t *testing.T) {
+	t.Setenv("MCM_TEST_SECRET_MYSQL_PASSWD", "from-env")
+	t.Setenv("MCM_TEST_SECRET_SHARED", "shared-from-env")
+
+	secretDir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(secretDir, "nokia1-token"), []byte("from-dir\n"), 0600))
+	assert.Nil(t, os.WriteFile(filepath.Join(secretDir, "shared"), []byte("shared-from-dir"), 0600))
+
+	secretFile := EncryptedFileSecretProvider{Path: filepath.Join(t.TempDir(), "secrets.enc.json"), MasterKey: testSecretMasterKey}
+	assert.Nil(t, secretFile.SetSecret("root-password", "from-file"))
+	assert.Nil(t, secretFile.SetSecret("token-secret", "from-file-2"))
+
+	useSecretProviders(t,
+		EnvSecretProvider{Prefix: "MCM_TEST_SECRET_"},
+		DirSecretProvider{Dir: secretDir},
+		secretFile,
+	)
+
+	testCases := []struct {
+		name           string
+		value          string
+		expectedSecret string
+		expectedErr    bool
+	}{
+		{
+			name:           "plaintext",
+			value:          "mcm_pass",
+			expectedSecret: "mcm_pass",
+		},
+		{
+			name:           "env",
+			value:          "secret://mysql-passwd",
+			expectedSecret: "from-env",
+		},
+		{
+			name:           "dir",
+			value:          "secret://nokia1-token",
+			expectedSecret: "from-dir",
+		},
+		{
+			name:           "encrypted file",
+			value:          "secret://token-secret",
+			expectedSecret: "from-file-2",
+		},
+		{
+			name:           "the first provider wins",
+			value:          "secret://shared",
+			expectedSecret: "shared-from-env",
+		},
+		{
+			name:        "not found",
+			value:       "secret://nothing",
+			expectedErr: true,
+		},
+		{
+			name:        "out of the directory",
+			value:       "secret://../secrets.enc.json",
+			expectedErr: true,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		secret, err := ResolveSecret(testCase.value)
+		if testCase.expectedErr {
+			assert.NotNil(t, err, fmt.Sprintf("%s: error is expected", testCase.name))
+			continue
+		}
+		assert.Nil(t, err, fmt.Sprintf("%s: error is not expected", testCase.name))
+		assert.Equal(t, testCase.expectedSecret, secret, fmt.Sprintf("%s: secret is not expected", testCase.name))
+	}
+}
+
+func TestEncryptedFileSecretProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.enc.json")
+	secretFile := EncryptedFileSecretProvider{Path: path, MasterKey: testSecretMasterKey}
+	assert.Nil(t, secretFile.SetSecret("mysql-passwd", "mcm_pass"))
+
+	// the file does not contain the plaintext
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(content), "mcm_pass")
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, secretFileMode, info.Mode().Perm())
+
+	testCases := []struct {
+		name           string
+		provider       EncryptedFileSecretProvider
+		secretName     string
+		expectedSecret string
+		expectedErr    error
+	}{
+		{
+			name:           "right master key",
+			provider:       secretFile,
+			secretName:     "mysql-passwd",
+			expectedSecret: "mcm_pass",
+		},
+		{
+			name:        "not found",
+			provider:    secretFile,
+			secretName:  "nothing",
+			expectedErr: ErrSecretNotFound,
+		},
+		{
+			name:       "wrong master key",
+			provider:   EncryptedFileSecretProvider{Path: path, MasterKey: testSecretWrongMasterKey},
+			secretName: "mysql-passwd",
+		},
+		{
+			name:       "no master key",
+			provider:   EncryptedFileSecretProvider{Path: path},
+			secretName: "mysql-passwd",
+		},
+		{
+			name:       "a password as the master key",
+			provider:   EncryptedFileSecretProvider{Path: path, MasterKey: "master-key"},
+			secretName: "mysql-passwd",
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		secret, err := testCase.provider.GetSecret(testCase.secretName)
+		if len(testCase.expectedSecret) > 0 {
+			assert.Nil(t, err, fmt.Sprintf("%s: error is not expected", testCase.name))
+			assert.Equal(t, testCase.expectedSecret, secret, fmt.Sprintf("%s: secret is not expected", testCase.name))
+			continue
+		}
+		assert.NotNil(t, err, fmt.Sprintf("%s: error is expected", testCase.name))
+		if testCase.expectedErr != nil {
+			assert.ErrorIs(t, err, testCase.expectedErr, fmt.Sprintf("%s: error is not expected", testCase.name))
+		}
+	}
+}
+
+func TestInnerParseSecretMasterKey(t *testing.T) {
+	testCases := []struct {
+		name        string
+		masterKey   string
+		expectedErr bool
+	}{
+		{
+			name:      "base64",
+			masterKey: testSecretMasterKey,
+		},
+		{
+			name:      "hex with a new line",
+			masterKey: "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f\n",
+		},
+		{
+			name:        "password",
+			masterKey:   "my-secret-passphrase",
+			expectedErr: true,
+		},
+		{
+			name:        "16 bytes in base64",
+			masterKey:   "MDEyMzQ1Njc4OWFiY2RlZg==",
+			expectedErr: true,
+		},
+		{
+			name:        "empty",
+			masterKey:   "",
+			expectedErr: true,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		key, err := parseSecretMasterKey(testCase.masterKey)
+		if testCase.expectedErr {
+			assert.NotNil(t, err, fmt.Sprintf("%s: error is expected", testCase.name))
+			continue
+		}
+		assert.Nil(t, err, fmt.Sprintf("%s: error is not expected", testCase.name))
+		assert.Len(t, key, secretMasterKeyLen, testCase.name)
+	}
+
+	// an invalid master key is rejected when the secret file is configured
+	t.Setenv(SecretMasterKeyEnv, "my-secret-passphrase")
+	_, err := ConfiguredSecretFile()
+	assert.NotNil(t, err)
+	t.Setenv(SecretMasterKeyEnv, testSecretMasterKey)
+	secretFile, err := ConfiguredSecretFile()
+	assert.Nil(t, err)
+	assert.Equal(t, testSecretMasterKey, secretFile.MasterKey)
+}
+
+func TestCloudSecrets(t *testing.T) {
+	path := useTempCloudRegistry(t, `{"iaas": []}`)
+	InitClouds()
+	t.Setenv("MCM_TEST_SECRET_LOCAL1_PASSWORD", "libvirt-pass")
+	useSecretProviders(t, EnvSecretProvider{Prefix: "MCM_TEST_SECRET_"})
+
+	// the secret is used by the cloud, but the reference is kept in iaas.json
+	err, statusCode := AddCloud(map[string]interface{}{"type": LocalIaas, "name": "LOCAL1", "password": "secret://local1-password"})
+	assert.Equal(t, http.StatusCreated, statusCode, fmt.Sprintf("error: %v", err))
+	cloud, found := GetIaas("LOCAL1")
+	if assert.True(t, found) {
+		assert.Equal(t, "libvirt-pass", cloud.(*Local).Password)
+	}
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "secret://local1-password")
+	assert.NotContains(t, string(content), "libvirt-pass")
+
+	// a missing secret is a wrong declaration
+	err, statusCode = AddCloud(map[string]interface{}{"type": LocalIaas, "name": "LOCAL2", "password": "secret://local2-password"})
+	assert.Equal(t, http.StatusBadRequest, statusCode)
+	var configErr *CloudConfigError
+	assert.ErrorAs(t, err, &configErr)
+	_, found = GetIaas("LOCAL2")
+	assert.False(t, found)
+}
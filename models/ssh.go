@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
+	"path"
 	"strings"
 
 	"github.com/astaxie/beego"
@@ -80,7 +81,11 @@ func SshClientWithPem(pemFilePath, user, ip string, port int) (*ssh.Client, erro
 		}
 	}
 	if pw := beego.AppConfig.String("k8sVmSshPassword"); pw != "" {
-		auth = append(auth, ssh.Password(pw))
+		if pw, err := ResolveSecret(pw); err != nil {
+			beego.Warn(fmt.Sprintf("[SSH] resolve config \"k8sVmSshPassword\", error: %s, so we do not use password", err.Error()))
+		} else {
+			auth = append(auth, ssh.Password(pw))
+		}
 	}
 
 	config := &ssh.ClientConfig{
@@ -137,3 +142,41 @@ func SftpCopyFile(srcPath, dstPath string, sshClient *ssh.Client) error {
 
 	return nil
 }
+
+// write the content to a file on the remote host through SFTP. The content is not in any command line, so the secrets in it are not shown in the process list of the remote host.
+func SftpWriteFile(content []byte, dstPath string, perm os.FileMode, sshClient *ssh.Client) error {
+	beego.Info(fmt.Sprintf("SFTP write [%d] bytes to [%s:%s].", len(content), sshClient.Conn.RemoteAddr(), dstPath))
+
+	sftpClient, err := sftp.NewClient(sshClient)
+	if err != nil {
+		outErr := fmt.Errorf("create SFTP client, error: %w", err)
+		beego.Error(outErr)
+		return outErr
+	}
+	defer sftpClient.Close()
+
+	if err := sftpClient.MkdirAll(path.Dir(dstPath)); err != nil {
+		outErr := fmt.Errorf("create the directory of %s:%s, error: %w", sshClient.Conn.RemoteAddr(), dstPath, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	dstFile, err := sftpClient.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		outErr := fmt.Errorf("create the destination file %s:%s, error: %w", sshClient.Conn.RemoteAddr(), dstPath, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	defer dstFile.Close()
+	if err := dstFile.Chmod(perm); err != nil {
+		outErr := fmt.Errorf("chmod the destination file %s:%s, error: %w", sshClient.Conn.RemoteAddr(), dstPath, err)
+		beego.Error(outErr)
+		return outErr
+	}
+	if _, err := dstFile.Write(content); err != nil {
+		outErr := fmt.Errorf("write the destination file %s:%s, error: %w", sshClient.Conn.RemoteAddr(), dstPath, err)
+		beego.Error(outErr)
+		return outErr
+	}
+
+	return nil
+}
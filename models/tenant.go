@@ -0,0 +1,296 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/astaxie/beego"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+/**
+NOTE:
+
+A tenant is a Kubernetes namespace created by multi-cloud manager, with the label TenantLabel, so the teams sharing a cluster do not see or delete the applications of each other.
+The applications of a tenant are in its namespace, and the applications without a namespace are in KubernetesNamespace ("default"), which is not a tenant.
+A tenant can have a ResourceQuota named TenantQuotaName, which limits the sum of the resource requests of the pods in its namespace.
+The nodes, VMs and clouds are shared by all tenants, so when calculating the residual resources of nodes, we count the pods in all namespaces.
+*/
+
+const (
+	TenantLabel     string = McmKey + "/tenant"
+	TenantQuotaName string = "mcm-tenant-quota"
+)
+
+type Tenant struct {
+	Name  string       `json:"name"`
+	Quota *TenantQuota `json:"quota,omitempty"`
+	Used  *TenantQuota `json:"used,omitempty"` // the resources used by the tenant and counted by the quota, only in output
+}
+
+// The quota of a tenant. An empty field means no limit.
+// CPU, Memory and Storage limit the sum of the requests of CPU, memory and ephemeral storage of all pods of the tenant.
+type TenantQuota struct {
+	CPU     string `json:"cpu,omitempty"`
+	Memory  string `json:"memory,omitempty"`
+	Storage string `json:"storage,omitempty"`
+	Pods    string `json:"pods,omitempty"`
+}
+
+// the Kubernetes resource names of the quota fields
+var tenantQuotaResources = []struct {
+	name  corev1.ResourceName
+	field func(q *TenantQuota) *string
+}{
+	{name: corev1.ResourceRequestsCPU, field: func(q *TenantQuota) *string { return &q.CPU }},
+	{name: corev1.ResourceRequestsMemory, field: func(q *TenantQuota) *string { return &q.Memory }},
+	{name: corev1.ResourceRequestsEphemeralStorage, field: func(q *TenantQuota) *string { return &q.Storage }},
+	{name: corev1.ResourcePods, field: func(q *TenantQuota) *string { return &q.Pods }},
+}
+
+func (q TenantQuota) resourceList() (corev1.ResourceList, error) {
+	list := make(corev1.ResourceList)
+	for _, res := range tenantQuotaResources {
+		value := strings.TrimSpace(*res.field(&q))
+		if len(value) == 0 {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("quota [%s] is [%s], error: %w", res.name, value, err)
+		}
+		list[res.name] = quantity
+	}
+	return list, nil
+}
+
+func tenantQuotaFromList(list corev1.ResourceList) *TenantQuota {
+	var quota TenantQuota
+	for _, res := range tenantQuotaResources {
+		if quantity, exist := list[res.name]; exist {
+			*res.field(&quota) = quantity.String()
+		}
+	}
+	return &quota
+}
+
+// The namespace of an application. Without a namespace, an application is in KubernetesNamespace.
+func AppNamespace(namespace string) string {
+	if len(namespace) == 0 {
+		return KubernetesNamespace
+	}
+	return namespace
+}
+
+func ValidateTenantName(name string) error {
+	if errs := validation.IsDNS1123Label(name); len(errs) != 0 {
+		return fmt.Errorf("tenant name [%s] is not a valid Kubernetes namespace name: %s", name, strings.Join(errs, "; "))
+	}
+	if name == KubernetesNamespace || strings.HasPrefix(name, "kube-") {
+		return fmt.Errorf("tenant name [%s] is reserved", name)
+	}
+	return nil
+}
+
+func isTenantNamespace(ns corev1.Namespace) bool {
+	_, isTenant := ns.Labels[TenantLabel]
+	return isTenant
+}
+
+func getTenantNamespace(name string) (*corev1.Namespace, error, int) {
+	ns, err := kubernetesClient.CoreV1().Namespaces().Get(context.Background(), name, metav1.GetOptions{})
+	if errors.IsNotFound(err) || (err == nil && !isTenantNamespace(*ns)) {
+		outErr := fmt.Errorf("tenant [%s] not found", name)
+		beego.Error(outErr)
+		return nil, outErr, http.StatusNotFound
+	}
+	if err != nil {
+		outErr := fmt.Errorf("Get namespace [%s], error: %w", name, err)
+		beego.Error(outErr)
+		return nil, outErr, http.StatusInternalServerError
+	}
+	return ns, nil, http.StatusOK
+}
+
+// CheckTenant checks whether a tenant exists, and it is used before operating the applications of a tenant.
+func CheckTenant(name string) (error, int) {
+	_, err, statusCode := getTenantNamespace(name)
+	return err, statusCode
+}
+
+// make the output of a tenant with its quota
+func genTenant(name string) (Tenant, error) {
+	tenant := Tenant{Name: name}
+	quota, err := kubernetesClient.CoreV1().ResourceQuotas(name).Get(context.Background(), TenantQuotaName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return tenant, nil
+	}
+	if err != nil {
+		return tenant, fmt.Errorf("Get ResourceQuota [%s/%s], error: %w", name, TenantQuotaName, err)
+	}
+	tenant.Quota = tenantQuotaFromList(quota.Spec.Hard)
+	tenant.Used = tenantQuotaFromList(quota.Status.Used)
+	return tenant, nil
+}
+
+func ListTenants() ([]Tenant, error) {
+	namespaces, err := kubernetesClient.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{LabelSelector: TenantLabel})
+	if err != nil {
+		outErr := fmt.Errorf("List the namespaces of tenants, error: %w", err)
+		beego.Error(outErr)
+		return []Tenant{}, outErr
+	}
+
+	var tenants []Tenant = []Tenant{}
+	for _, ns := range namespaces.Items {
+		// the fake clientset ignores the label selector, so we check the label again.
+		if !isTenantNamespace(ns) {
+			continue
+		}
+		tenant, err := genTenant(ns.Name)
+		if err != nil {
+			beego.Error(fmt.Sprintf("Get the quota of tenant [%s], error: %s", ns.Name, err.Error()))
+		}
+		tenants = append(tenants, tenant)
+	}
+	sort.Slice(tenants, func(i, j int) bool {
+		return tenants[i].Name < tenants[j].Name
+	})
+	return tenants, nil
+}
+
+func GetTenant(name string) (Tenant, error, int) {
+	if _, err, statusCode := getTenantNamespace(name); err != nil {
+		return Tenant{}, err, statusCode
+	}
+	tenant, err := genTenant(name)
+	if err != nil {
+		outErr := fmt.Errorf("Get the quota of tenant [%s], error: %w", name, err)
+		beego.Error(outErr)
+		return tenant, outErr, http.StatusInternalServerError
+	}
+	return tenant, nil, http.StatusOK
+}
+
+// Create a tenant, which is a namespace, with its ResourceQuota if the quota is set.
+func CreateTenant(tenant Tenant) (error, int) {
+	if err := ValidateTenantName(tenant.Name); err != nil {
+		beego.Error(err)
+		return err, http.StatusBadRequest
+	}
+	var hard corev1.ResourceList
+	if tenant.Quota != nil {
+		var err error
+		if hard, err = tenant.Quota.resourceList(); err != nil {
+			outErr := fmt.Errorf("tenant [%s], %w", tenant.Name, err)
+			beego.Error(outErr)
+			return outErr, http.StatusBadRequest
+		}
+	}
+
+	ctx := context.Background()
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   tenant.Name,
+			Labels: map[string]string{TenantLabel: "true"},
+		},
+	}
+	if _, err := kubernetesClient.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{}); err != nil {
+		outErr := fmt.Errorf("Create namespace [%s] for the tenant, error: %w", tenant.Name, err)
+		beego.Error(outErr)
+		if errors.IsAlreadyExists(err) {
+			return outErr, http.StatusConflict
+		}
+		return outErr, http.StatusInternalServerError
+	}
+	beego.Info(fmt.Sprintf("Namespace [%s] is created for the tenant.", tenant.Name))
+
+	if len(hard) > 0 {
+		if err := applyTenantQuota(tenant.Name, hard); err != nil {
+			outErr := fmt.Errorf("tenant [%s] is created without quota, error: %w", tenant.Name, err)
+			beego.Error(outErr)
+			return outErr, http.StatusInternalServerError
+		}
+	}
+	return nil, http.StatusCreated
+}
+
+// Set the quota of a tenant. A nil or empty quota removes the quota.
+func UpdateTenantQuota(name string, quota *TenantQuota) (error, int) {
+	if _, err, statusCode := getTenantNamespace(name); err != nil {
+		return err, statusCode
+	}
+	var hard corev1.ResourceList
+	if quota != nil {
+		var err error
+		if hard, err = quota.resourceList(); err != nil {
+			outErr := fmt.Errorf("tenant [%s], %w", name, err)
+			beego.Error(outErr)
+			return outErr, http.StatusBadRequest
+		}
+	}
+
+	if len(hard) == 0 {
+		err := kubernetesClient.CoreV1().ResourceQuotas(name).Delete(context.Background(), TenantQuotaName, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			outErr := fmt.Errorf("Delete ResourceQuota [%s/%s], error: %w", name, TenantQuotaName, err)
+			beego.Error(outErr)
+			return outErr, http.StatusInternalServerError
+		}
+		beego.Info(fmt.Sprintf("The quota of tenant [%s] is removed.", name))
+		return nil, http.StatusOK
+	}
+
+	if err := applyTenantQuota(name, hard); err != nil {
+		beego.Error(err)
+		return err, http.StatusInternalServerError
+	}
+	return nil, http.StatusOK
+}
+
+// create or update the ResourceQuota of a tenant
+func applyTenantQuota(namespace string, hard corev1.ResourceList) error {
+	ctx := context.Background()
+	quotas := kubernetesClient.CoreV1().ResourceQuotas(namespace)
+	existing, err := quotas.Get(ctx, TenantQuotaName, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		quota := &corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: TenantQuotaName, Namespace: namespace},
+			Spec:       corev1.ResourceQuotaSpec{Hard: hard},
+		}
+		if _, err := quotas.Create(ctx, quota, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("Create ResourceQuota [%s/%s], error: %w", namespace, TenantQuotaName, err)
+		}
+	case err != nil:
+		return fmt.Errorf("Get ResourceQuota [%s/%s], error: %w", namespace, TenantQuotaName, err)
+	default:
+		existing.Spec.Hard = hard
+		if _, err := quotas.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("Update ResourceQuota [%s/%s], error: %w", namespace, TenantQuotaName, err)
+		}
+	}
+	beego.Info(fmt.Sprintf("The quota of tenant [%s] is set to %v.", namespace, hard))
+	return nil
+}
+
+// Delete a tenant. Kubernetes deletes all applications in its namespace.
+func DeleteTenant(name string) (error, int) {
+	if _, err, statusCode := getTenantNamespace(name); err != nil {
+		return err, statusCode
+	}
+	if err := kubernetesClient.CoreV1().Namespaces().Delete(context.Background(), name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		outErr := fmt.Errorf("Delete namespace [%s] of the tenant, error: %w", name, err)
+		beego.Error(outErr)
+		return outErr, http.StatusInternalServerError
+	}
+	beego.Info(fmt.Sprintf("Successfully sent request to delete tenant [%s].", name))
+	return nil, http.StatusOK
+}
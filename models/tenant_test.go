@@ -0,0 +1,176 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTenant(t *testing.T) {
+	useSimulatedK8s(t)
+
+	// a namespace not created by multi-cloud manager is not a tenant
+	_, err := kubernetesClient.CoreV1().Namespaces().Create(context.Background(), &apiv1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}}, metav1.CreateOptions{})
+	assert.Nil(t, err)
+
+	testCases := []struct {
+		name               string
+		do                 func() (error, int)
+		expectedStatusCode int
+		expectedTenants    []string
+	}{
+		{
+			name:               "create",
+			do:                 func() (error, int) { return CreateTenant(Tenant{Name: "team-a"}) },
+			expectedStatusCode: http.StatusCreated,
+			expectedTenants:    []string{"team-a"},
+		},
+		{
+			name: "create with quota",
+			do: func() (error, int) {
+				return CreateTenant(Tenant{Name: "team-b", Quota: &TenantQuota{CPU: "8", Memory: "16Gi", Pods: "20"}})
+			},
+			expectedStatusCode: http.StatusCreated,
+			expectedTenants:    []string{"team-a", "team-b"},
+		},
+		{
+			name:               "create existing",
+			do:                 func() (error, int) { return CreateTenant(Tenant{Name: "team-a"}) },
+			expectedStatusCode: http.StatusConflict,
+			expectedTenants:    []string{"team-a", "team-b"},
+		},
+		{
+			name:               "invalid name",
+			do:                 func() (error, int) { return CreateTenant(Tenant{Name: "Team_C"}) },
+			expectedStatusCode: http.StatusBadRequest,
+			expectedTenants:    []string{"team-a", "team-b"},
+		},
+		{
+			name:               "reserved name",
+			do:                 func() (error, int) { return CreateTenant(Tenant{Name: KubernetesNamespace}) },
+			expectedStatusCode: http.StatusBadRequest,
+			expectedTenants:    []string{"team-a", "team-b"},
+		},
+		{
+			name:               "invalid quota",
+			do:                 func() (error, int) { return CreateTenant(Tenant{Name: "team-c", Quota: &TenantQuota{CPU: "many"}}) },
+			expectedStatusCode: http.StatusBadRequest,
+			expectedTenants:    []string{"team-a", "team-b"},
+		},
+		{
+			name:               "set quota",
+			do:                 func() (error, int) { return UpdateTenantQuota("team-a", &TenantQuota{CPU: "4"}) },
+			expectedStatusCode: http.StatusOK,
+			expectedTenants:    []string{"team-a", "team-b"},
+		},
+		{
+			name:               "remove quota",
+			do:                 func() (error, int) { return UpdateTenantQuota("team-b", &TenantQuota{}) },
+			expectedStatusCode: http.StatusOK,
+			expectedTenants:    []string{"team-a", "team-b"},
+		},
+		{
+			name:               "quota of not a tenant",
+			do:                 func() (error, int) { return UpdateTenantQuota("other", &TenantQuota{CPU: "4"}) },
+			expectedStatusCode: http.StatusNotFound,
+			expectedTenants:    []string{"team-a", "team-b"},
+		},
+		{
+			name:               "delete",
+			do:                 func() (error, int) { return DeleteTenant("team-b") },
+			expectedStatusCode: http.StatusOK,
+			expectedTenants:    []string{"team-a"},
+		},
+		{
+			name:               "delete not a tenant",
+			do:                 func() (error, int) { return DeleteTenant("other") },
+			expectedStatusCode: http.StatusNotFound,
+			expectedTenants:    []string{"team-a"},
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		err, statusCode := testCase.do()
+		assert.Equal(t, testCase.expectedStatusCode, statusCode, fmt.Sprintf("%s: status code is not expected, error: %v", testCase.name, err))
+		tenants, err := ListTenants()
+		assert.Nil(t, err)
+		var names []string
+		for _, tenant := range tenants {
+			names = append(names, tenant.Name)
+		}
+		assert.Equal(t, testCase.expectedTenants, names, fmt.Sprintf("%s: tenants are not expected", testCase.name))
+	}
+
+	tenant, err, _ := GetTenant("team-a")
+	assert.Nil(t, err)
+	assert.Equal(t, &TenantQuota{CPU: "4"}, tenant.Quota)
+	quota, err := kubernetesClient.CoreV1().ResourceQuotas("team-a").Get(context.Background(), TenantQuotaName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "4", quota.Spec.Hard.Name(apiv1.ResourceRequestsCPU, "").String())
+}
+
+func TestTenantApplications(t *testing.T) {
+	simulator := useSimulatedK8s(t)
+	assert.Nil(t, AddNode(IaasVm{Name: "n1", IPs: []string{"10.0.0.1"}, VCpu: 4, Ram: 8192, Storage: 50}, ""))
+	err, _ := CreateTenant(Tenant{Name: "team-a"})
+	assert.Nil(t, err)
+
+	// the applications with the same name in different namespaces do not affect each other
+	assert.Nil(t, CreateApplication(simTestApp("app1", 1, "1")))
+	tenantApp := simTestApp("app1", 1, "1")
+	tenantApp.Namespace = "team-a"
+	assert.Nil(t, CreateApplication(tenantApp))
+	tenantApp2 := simTestApp("app2", 1, "1")
+	tenantApp2.Namespace = "team-a"
+	assert.Nil(t, CreateApplication(tenantApp2))
+	assert.Nil(t, simulator.SyncOnce())
+
+	testCases := []struct {
+		namespace    string
+		expectedApps []string
+	}{
+		{namespace: KubernetesNamespace, expectedApps: []string{"app1"}},
+		{namespace: "team-a", expectedApps: []string{"app1", "app2"}},
+		{namespace: metav1.NamespaceAll, expectedApps: []string{"app1", "app1", "app2"}},
+	}
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.namespace)
+		apps, err := ListApplications(testCase.namespace)
+		assert.Nil(t, err)
+		var names []string
+		for _, app := range apps {
+			names = append(names, app.AppName)
+			if testCase.namespace != metav1.NamespaceAll {
+				assert.Equal(t, testCase.namespace, app.Namespace)
+			}
+		}
+		assert.ElementsMatch(t, testCase.expectedApps, names, fmt.Sprintf("%s: applications are not expected", testCase.namespace))
+	}
+
+	_, err, statusCode := GetApplication(KubernetesNamespace, "app2")
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusNotFound, statusCode)
+
+	// the resources of a node are occupied by the pods of all tenants
+	podsOnN1, err := ListPodsOnNode(metav1.NamespaceAll, "n1")
+	assert.Nil(t, err)
+	var usedCpu float64
+	for _, pod := range podsOnN1 {
+		usedCpu += GetResOccupiedByPod(pod).CpuCore
+	}
+	assert.Equal(t, float64(3), usedCpu)
+
+	// deleting the application of a tenant does not delete the one with the same name in another namespace
+	assert.Nil(t, DeleteDeployment("team-a", "app1"+DeploymentSuffix))
+	assert.Nil(t, simulator.SyncOnce())
+	_, err, statusCode = GetApplication("team-a", "app1")
+	assert.Equal(t, http.StatusNotFound, statusCode, fmt.Sprintf("error: %v", err))
+	_, err, _ = GetApplication(KubernetesNamespace, "app1")
+	assert.Nil(t, err)
+}
@@ -1,5 +1,25 @@
 package models
 
+import "fmt"
+
 func ValidateK8sApp(app K8sApp) error {
+	if len(app.ReplicaNodeNames) > 0 {
+		if len(app.NodeName) > 0 {
+			return fmt.Errorf("application [%s] should not set both nodeName [%s] and replicaNodeNames %v", app.Name, app.NodeName, app.ReplicaNodeNames)
+		}
+		if int32(len(app.ReplicaNodeNames)) != app.Replicas {
+			return fmt.Errorf("application [%s] has [%d] replicas, but [%d] replicaNodeNames %v", app.Name, app.Replicas, len(app.ReplicaNodeNames), app.ReplicaNodeNames)
+		}
+		nodeNames := make(map[string]struct{})
+		for _, nodeName := range app.ReplicaNodeNames {
+			if _, exist := nodeNames[nodeName]; exist {
+				return fmt.Errorf("application [%s], node [%s] is repeated in replicaNodeNames %v, but only one replica can run on a node", app.Name, nodeName, app.ReplicaNodeNames)
+			}
+			nodeNames[nodeName] = struct{}{}
+		}
+	}
+	if err := validateAppMounts(app); err != nil {
+		return err
+	}
 	return nil
 }
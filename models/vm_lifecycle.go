@@ -0,0 +1,264 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/astaxie/beego"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+/**
+NOTE:
+
+The Iaas interface only has the operations that every cloud supports. The lifecycle operations of VMs are optional capabilities, and a cloud driver supports a capability by implementing its interface.
+The API /cloud/:cloudName/vm/:vmID/actions checks the capability of the cloud before doing an action, and GET /cloud/:cloudName reports the capabilities of the cloud.
+The actions that interrupt a VM (see vmActionInterrupts) also interrupt the pods on it if the VM is a Kubernetes node, so they are refused on Kubernetes nodes unless "force" is set. Users should drain the node before them.
+*/
+
+// the names of the optional capabilities of clouds
+const (
+	CapPower    string = "power"
+	CapResize   string = "resize"
+	CapSnapshot string = "snapshot"
+)
+
+// the actions on a VM
+const (
+	VmActionStart          string = "start"    // power on
+	VmActionStop           string = "stop"     // power off immediately
+	VmActionShutdown       string = "shutdown" // power off gracefully by the guest OS
+	VmActionReboot         string = "reboot"
+	VmActionResize         string = "resize"     // change the vCPU and RAM
+	VmActionResizeDisk     string = "resizeDisk" // grow the system disk
+	VmActionCreateSnapshot string = "createSnapshot"
+	VmActionListSnapshots  string = "listSnapshots"
+	VmActionRevertSnapshot string = "revertSnapshot"
+	VmActionDeleteSnapshot string = "deleteSnapshot"
+)
+
+// PowerController is the capability to change the power state of VMs.
+type PowerController interface {
+	StartVM(vmID string) error
+	StopVM(vmID string) error
+	ShutdownVM(vmID string) error
+	RebootVM(vmID string) error
+}
+
+// Resizer is the capability to resize VMs. The unit of vcpu, ram and storage is consistent with ResSet.
+// If live is true, the VM is resized while running, otherwise it is stopped before resizing and started afterward.
+type Resizer interface {
+	ResizeVM(vmID string, vcpu, ram int, live bool) error
+	ResizeVMDisk(vmID string, storage int) error
+}
+
+// Snapshotter is the capability to manage the snapshots of VMs.
+type Snapshotter interface {
+	CreateSnapshot(vmID, snapshotName string) error
+	ListSnapshots(vmID string) ([]VmSnapshot, error)
+	RevertSnapshot(vmID, snapshotName string) error
+	DeleteSnapshot(vmID, snapshotName string) error
+}
+
+type VmSnapshot struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	CreatedAt   string `json:"createdAt,omitempty"`
+}
+
+// VmAction is the body of the API to do an action on a VM. Only the fields needed by the action are used.
+type VmAction struct {
+	Action   string `json:"action"`
+	VCpu     int    `json:"vcpu,omitempty"`
+	Ram      int    `json:"ram,omitempty"`
+	Storage  int    `json:"storage,omitempty"`
+	Live     bool   `json:"live,omitempty"`
+	Snapshot string `json:"snapshot,omitempty"`
+	Force    bool   `json:"force,omitempty"` // do the action even if it interrupts the Kubernetes node on the VM
+}
+
+// the capability needed by every action
+var actionCapability = map[string]string{
+	VmActionStart:          CapPower,
+	VmActionStop:           CapPower,
+	VmActionShutdown:       CapPower,
+	VmActionReboot:         CapPower,
+	VmActionResize:         CapResize,
+	VmActionResizeDisk:     CapResize,
+	VmActionCreateSnapshot: CapSnapshot,
+	VmActionListSnapshots:  CapSnapshot,
+	VmActionRevertSnapshot: CapSnapshot,
+	VmActionDeleteSnapshot: CapSnapshot,
+}
+
+// CloudCapabilities returns the optional capabilities that a cloud supports
+func CloudCapabilities(cloud Iaas) []string {
+	capabilities := []string{}
+	if _, ok := cloud.(PowerController); ok {
+		capabilities = append(capabilities, CapPower)
+	}
+	if _, ok := cloud.(Resizer); ok {
+		capabilities = append(capabilities, CapResize)
+	}
+	if _, ok := cloud.(Snapshotter); ok {
+		capabilities = append(capabilities, CapSnapshot)
+	}
+	return capabilities
+}
+
+// CloudVmActions returns the actions that can be done on the VMs of a cloud
+func CloudVmActions(cloud Iaas) []string {
+	supported := make(map[string]bool)
+	for _, capability := range CloudCapabilities(cloud) {
+		supported[capability] = true
+	}
+	actions := []string{}
+	for _, action := range []string{VmActionStart, VmActionStop, VmActionShutdown, VmActionReboot, VmActionResize, VmActionResizeDisk, VmActionCreateSnapshot, VmActionListSnapshots, VmActionRevertSnapshot, VmActionDeleteSnapshot} {
+		if supported[actionCapability[action]] {
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
+
+// DoVmAction does an action on a VM. The result is not nil only for the action listSnapshots.
+func DoVmAction(cloudName, vmID string, action VmAction) (interface{}, error, int) {
+	cloud, exist := GetIaas(cloudName)
+	if !exist {
+		outErr := fmt.Errorf("cloud [%s] not found", cloudName)
+		beego.Error(outErr)
+		return nil, outErr, http.StatusNotFound
+	}
+
+	capability, known := actionCapability[action.Action]
+	if !known {
+		outErr := fmt.Errorf("unknown action [%s], the supported actions of cloud [%s] are %v", action.Action, cloudName, CloudVmActions(cloud))
+		beego.Error(outErr)
+		return nil, outErr, http.StatusBadRequest
+	}
+	if err := validateVmAction(action); err != nil {
+		outErr := fmt.Errorf("invalid action [%s] on VM [%s] of cloud [%s]: %w", action.Action, vmID, cloudName, err)
+		beego.Error(outErr)
+		return nil, outErr, http.StatusBadRequest
+	}
+
+	if vmActionInterrupts(action) && !action.Force {
+		isNode, err := isVmK8sNode(cloud, vmID)
+		if err != nil {
+			outErr := fmt.Errorf("check whether VM [%s] of cloud [%s] is a Kubernetes node, error: %w", vmID, cloudName, err)
+			beego.Error(outErr)
+			return nil, outErr, http.StatusInternalServerError
+		}
+		if isNode {
+			outErr := fmt.Errorf("VM [%s] of cloud [%s] is a Kubernetes node, and action [%s] interrupts the pods on it, so we refuse it. Please drain the node first, and set \"force\" to do it anyway", vmID, cloudName, action.Action)
+			beego.Error(outErr)
+			return nil, outErr, http.StatusConflict
+		}
+	}
+
+	var result interface{}
+	var err error
+	switch capability {
+	case CapPower:
+		pc, ok := cloud.(PowerController)
+		if !ok {
+			return nil, unsupportedCapability(cloud, capability), http.StatusNotImplemented
+		}
+		switch action.Action {
+		case VmActionStart:
+			err = pc.StartVM(vmID)
+		case VmActionStop:
+			err = pc.StopVM(vmID)
+		case VmActionShutdown:
+			err = pc.ShutdownVM(vmID)
+		case VmActionReboot:
+			err = pc.RebootVM(vmID)
+		}
+	case CapResize:
+		rs, ok := cloud.(Resizer)
+		if !ok {
+			return nil, unsupportedCapability(cloud, capability), http.StatusNotImplemented
+		}
+		switch action.Action {
+		case VmActionResize:
+			err = rs.ResizeVM(vmID, action.VCpu, action.Ram, action.Live)
+		case VmActionResizeDisk:
+			err = rs.ResizeVMDisk(vmID, action.Storage)
+		}
+	case CapSnapshot:
+		ss, ok := cloud.(Snapshotter)
+		if !ok {
+			return nil, unsupportedCapability(cloud, capability), http.StatusNotImplemented
+		}
+		switch action.Action {
+		case VmActionCreateSnapshot:
+			err = ss.CreateSnapshot(vmID, action.Snapshot)
+		case VmActionListSnapshots:
+			result, err = ss.ListSnapshots(vmID)
+		case VmActionRevertSnapshot:
+			err = ss.RevertSnapshot(vmID, action.Snapshot)
+		case VmActionDeleteSnapshot:
+			err = ss.DeleteSnapshot(vmID, action.Snapshot)
+		}
+	}
+	if err != nil {
+		outErr := fmt.Errorf("do action [%s] on VM [%s] of cloud [%s], error: %w", action.Action, vmID, cloudName, err)
+		beego.Error(outErr)
+		return nil, outErr, http.StatusInternalServerError
+	}
+	return result, nil, http.StatusOK
+}
+
+func unsupportedCapability(cloud Iaas, capability string) error {
+	outErr := fmt.Errorf("cloud [%s] type [%s] does not support the capability [%s]", cloud.ShowName(), cloud.ShowType(), capability)
+	beego.Error(outErr)
+	return outErr
+}
+
+// Whether the action stops or restarts the VM, or reverts its disk.
+func vmActionInterrupts(action VmAction) bool {
+	switch action.Action {
+	case VmActionStop, VmActionShutdown, VmActionReboot, VmActionRevertSnapshot:
+		return true
+	case VmActionResize:
+		return !action.Live // an offline resize stops the VM
+	default:
+		return false
+	}
+}
+
+// The name of a Kubernetes node is the name of its VM.
+func isVmK8sNode(cloud Iaas, vmID string) (bool, error) {
+	vm, err := cloud.GetVM(vmID)
+	if err != nil {
+		return false, fmt.Errorf("get VM [%s], error: %w", vmID, err)
+	}
+	if _, err := GetNode(vm.Name, metav1.GetOptions{}); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("get Kubernetes node [%s], error: %w", vm.Name, err)
+	}
+	return true, nil
+}
+
+// check the fields needed by the action
+func validateVmAction(action VmAction) error {
+	switch action.Action {
+	case VmActionResize:
+		if action.VCpu <= 0 || action.Ram <= 0 {
+			return fmt.Errorf("vcpu [%d] and ram [%d] should be positive", action.VCpu, action.Ram)
+		}
+	case VmActionResizeDisk:
+		if action.Storage <= 0 {
+			return fmt.Errorf("storage [%d] should be positive", action.Storage)
+		}
+	case VmActionCreateSnapshot, VmActionRevertSnapshot, VmActionDeleteSnapshot:
+		if strings.TrimSpace(action.Snapshot) == "" {
+			return fmt.Errorf("snapshot name should not be empty")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloudCapabilities(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		cloud                Iaas
+		expectedCapabilities []string
+	}{
+		{
+			name:                 "fake",
+			cloud:                NewFake("f", ResSet{}),
+			expectedCapabilities: []string{CapPower, CapResize, CapSnapshot},
+		},
+		{
+			name:                 "local",
+			cloud:                &Local{},
+			expectedCapabilities: []string{CapPower, CapResize, CapSnapshot},
+		},
+		{
+			name:                 "proxmox",
+			cloud:                &Proxmox{},
+			expectedCapabilities: []string{CapPower, CapResize, CapSnapshot},
+		},
+		{
+			name:                 "openstack",
+			cloud:                &Openstack{},
+			expectedCapabilities: []string{CapPower, CapResize},
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		assert.Equal(t, testCase.expectedCapabilities, CloudCapabilities(testCase.cloud), fmt.Sprintf("%s: capabilities are not expected", testCase.name))
+	}
+
+	assert.NotContains(t, CloudVmActions(&Openstack{}), VmActionCreateSnapshot)
+	assert.Contains(t, CloudVmActions(&Openstack{}), VmActionResize)
+}
+
+func TestDoVmAction(t *testing.T) {
+	useSimulatedK8s(t)
+	fake := NewFake("fake-actions", ResSet{VCpu: 8, Ram: 8192, Storage: 100})
+	Clouds[fake.Name] = fake
+	t.Cleanup(func() { delete(Clouds, fake.Name) })
+	vm, err := fake.CreateVM("vm1", 2, 2048, 20)
+	assert.Nil(t, err)
+
+	testCases := []struct {
+		name               string
+		cloudName          string
+		action             VmAction
+		expectedStatusCode int
+		check              func(vm *IaasVm)
+	}{
+		{
+			name:               "cloud not found",
+			cloudName:          "not-exist",
+			action:             VmAction{Action: VmActionStart},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "unknown action",
+			cloudName:          fake.Name,
+			action:             VmAction{Action: "fly"},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "stop",
+			cloudName:          fake.Name,
+			action:             VmAction{Action: VmActionStop},
+			expectedStatusCode: http.StatusOK,
+			check:              func(vm *IaasVm) { assert.Equal(t, fakeVmStatusStopped, vm.Status) },
+		},
+		{
+			name:               "live resize of a stopped VM",
+			cloudName:          fake.Name,
+			action:             VmAction{Action: VmActionResize, VCpu: 4, Ram: 4096, Live: true},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:               "offline resize",
+			cloudName:          fake.Name,
+			action:             VmAction{Action: VmActionResize, VCpu: 4, Ram: 4096},
+			expectedStatusCode: http.StatusOK,
+			check: func(vm *IaasVm) {
+				assert.Equal(t, float64(4), vm.VCpu)
+				assert.Equal(t, float64(4096), vm.Ram)
+			},
+		},
+		{
+			name:               "resize without vcpu",
+			cloudName:          fake.Name,
+			action:             VmAction{Action: VmActionResize, Ram: 4096},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "resize over quota",
+			cloudName:          fake.Name,
+			action:             VmAction{Action: VmActionResize, VCpu: 16, Ram: 4096},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:               "start",
+			cloudName:          fake.Name,
+			action:             VmAction{Action: VmActionStart},
+			expectedStatusCode: http.StatusOK,
+			check:              func(vm *IaasVm) { assert.Equal(t, fakeVmStatus, vm.Status) },
+		},
+		{
+			name:               "snapshot",
+			cloudName:          fake.Name,
+			action:             VmAction{Action: VmActionCreateSnapshot, Snapshot: "s1"},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "snapshot without name",
+			cloudName:          fake.Name,
+			action:             VmAction{Action: VmActionCreateSnapshot},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "grow disk",
+			cloudName:          fake.Name,
+			action:             VmAction{Action: VmActionResizeDisk, Storage: 40},
+			expectedStatusCode: http.StatusOK,
+			check:              func(vm *IaasVm) { assert.Equal(t, float64(40), vm.Storage) },
+		},
+		{
+			name:               "shrink disk",
+			cloudName:          fake.Name,
+			action:             VmAction{Action: VmActionResizeDisk, Storage: 10},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:               "revert snapshot",
+			cloudName:          fake.Name,
+			action:             VmAction{Action: VmActionRevertSnapshot, Snapshot: "s1"},
+			expectedStatusCode: http.StatusOK,
+			check: func(vm *IaasVm) {
+				assert.Equal(t, float64(20), vm.Storage)
+				assert.Equal(t, float64(4), vm.VCpu)
+			},
+		},
+		{
+			name:               "delete snapshot",
+			cloudName:          fake.Name,
+			action:             VmAction{Action: VmActionDeleteSnapshot, Snapshot: "s1"},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "delete snapshot not found",
+			cloudName:          fake.Name,
+			action:             VmAction{Action: VmActionDeleteSnapshot, Snapshot: "s1"},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		_, err, statusCode := DoVmAction(testCase.cloudName, vm.ID, testCase.action)
+		assert.Equal(t, testCase.expectedStatusCode, statusCode, fmt.Sprintf("%s: status code is not expected, error: %v", testCase.name, err))
+		assert.Equal(t, testCase.expectedStatusCode == http.StatusOK, err == nil, fmt.Sprintf("%s: error is not expected", testCase.name))
+		if testCase.check != nil {
+			current, err := fake.GetVM(vm.ID)
+			assert.Nil(t, err)
+			testCase.check(current)
+		}
+	}
+
+	// list snapshots returns the result
+	_, err, _ = DoVmAction(fake.Name, vm.ID, VmAction{Action: VmActionCreateSnapshot, Snapshot: "s2"})
+	assert.Nil(t, err)
+	result, err, statusCode := DoVmAction(fake.Name, vm.ID, VmAction{Action: VmActionListSnapshots})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, statusCode)
+	snapshots := result.([]VmSnapshot)
+	assert.Len(t, snapshots, 1)
+	assert.Equal(t, "s2", snapshots[0].Name)
+}
+
+func TestDoVmActionOnK8sNode(t *testing.T) {
+	useSimulatedK8s(t)
+	fake := NewFake("fake-node-actions", ResSet{VCpu: 8, Ram: 8192, Storage: 100})
+	Clouds[fake.Name] = fake
+	t.Cleanup(func() { delete(Clouds, fake.Name) })
+	vm, err := fake.CreateVM("node1", 2, 2048, 20)
+	assert.Nil(t, err)
+	assert.Nil(t, AddNode(*vm, ""))
+
+	testCases := []struct {
+		name               string
+		action             VmAction
+		expectedStatusCode int
+	}{
+		{
+			name:               "reboot",
+			action:             VmAction{Action: VmActionReboot},
+			expectedStatusCode: http.StatusConflict,
+		},
+		{
+			name:               "offline resize",
+			action:             VmAction{Action: VmActionResize, VCpu: 4, Ram: 4096},
+			expectedStatusCode: http.StatusConflict,
+		},
+		{
+			name:               "snapshot does not interrupt the node",
+			action:             VmAction{Action: VmActionCreateSnapshot, Snapshot: "s1"},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "revert snapshot",
+			action:             VmAction{Action: VmActionRevertSnapshot, Snapshot: "s1"},
+			expectedStatusCode: http.StatusConflict,
+		},
+		{
+			name:               "forced reboot",
+			action:             VmAction{Action: VmActionReboot, Force: true},
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Logf("test: %d, %s", i, testCase.name)
+		_, err, statusCode := DoVmAction(fake.Name, vm.ID, testCase.action)
+		assert.Equal(t, testCase.expectedStatusCode, statusCode, fmt.Sprintf("%s: status code is not expected, error: %v", testCase.name, err))
+	}
+}
+
+func TestProxmoxActionInput(t *testing.T) {
+	proxmox := &Proxmox{Name: "nokia4", Type: ProxmoxIaas}
+
+	// the invalid input is refused before any request is sent to Proxmox
+	for _, vmid := range []string{"", "abc", "-1", "0", "101/../102", "101 "} {
+		assert.NotNil(t, proxmox.StopVM(vmid), vmid)
+		assert.NotNil(t, proxmox.ShutdownVM(vmid), vmid)
+		assert.NotNil(t, proxmox.RebootVM(vmid), vmid)
+		_, err := proxmox.ListSnapshots(vmid)
+		assert.NotNil(t, err, vmid)
+	}
+	for _, snapshotName := range []string{"", "1st", "before upgrade", "s1/rollback", "s1?x=1", "a23456789012345678901234567890123456789012"} {
+		assert.NotNil(t, proxmox.CreateSnapshot("101", snapshotName), snapshotName)
+		assert.NotNil(t, proxmox.RevertSnapshot("101", snapshotName), snapshotName)
+		assert.NotNil(t, proxmox.DeleteSnapshot("101", snapshotName), snapshotName)
+	}
+
+	for _, snapshotName := range []string{"s1", "before-upgrade", "Before_Upgrade_2"} {
+		assert.Nil(t, proxmox.checkSnapshotName(snapshotName), snapshotName)
+	}
+	vmid, err := proxmox.checkVmid("101")
+	assert.Nil(t, err)
+	assert.Equal(t, 101, vmid)
+}
+
+func TestParseSnapshots(t *testing.T) {
+	assert.Equal(t, []VmSnapshot{{Name: "s1"}, {Name: "before upgrade"}}, parseSnapshotNames("s1\n before upgrade \n\n"))
+	assert.Equal(t, []VmSnapshot{}, parseSnapshotNames(""))
+
+	snapshots, err := parseProxmoxSnapshots([]byte(`{"data":[{"name":"current","running":1,"digest":"x"},{"name":"s2","snaptime":1700000100},{"name":"s1","snaptime":1700000000,"description":"first"}]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, []VmSnapshot{
+		{Name: "s1", Description: "first", CreatedAt: "2023-11-14T22:13:20Z"},
+		{Name: "s2", CreatedAt: "2023-11-14T22:15:00Z"},
+	}, snapshots)
+
+	_, err = parseProxmoxSnapshots([]byte("not json"))
+	assert.NotNil(t, err)
+}
@@ -1,20 +1,15 @@
 #!/bin/env bash
 
 # example:
-# bash client.sh "192.168.100.136" "NOKIA7" "192.168.100.136" "3306" "multicloud" 'AAUproxmox1234!@#' "multi_cloud" "NOKIA8"
+# bash client.sh "192.168.100.136" "NOKIA7" "NOKIA8"
+# The result is printed as the last line like "MCM_NET_RESULT rtt_ms=0.458 bandwidth_mbps=933", and emcontroller reads it from the logs of this container.
 
 # One simple way to make your script exit on any error is to use the `set -e` option. This will cause your script to immediately exit if any command returns a non-zero exit code. The default value is not sure. `set +e` can cancel it.
 set -e
 
 t_cloud_ip="$1"
 t_cloud_name="$2"
-
-mysql_ip="$3"
-mysql_port="$4"
-mysql_user="$5"
-mysql_passwd="$6"
-mysql_db_name="$7"
-this_cloud_name="$8"
+this_cloud_name="$3"
 
 # measure the rtt between this cloud an the target cloud
 last_line_ping=$(ping -c 10 -w 30 "${t_cloud_ip}" | tail -1)
@@ -35,6 +30,42 @@ else
   echo "Unreachable from ${this_cloud_name} to ${t_cloud_name}, so we set the RTT as ${rtt_ms} ms."
 fi
 
-# write the rtt in to the database
-mysql -u "${mysql_user}" --port "${mysql_port}" -h "${mysql_ip}" --password="${mysql_passwd}" -e "update ${mysql_db_name}.${this_cloud_name} set rtt_ms=${rtt_ms} where target_cloud_name='${t_cloud_name}'"
-echo "Write the rtt value in the database successfully."
\ No newline at end of file
+# measure the bandwidth (TCP throughput) from this cloud to the target cloud by iperf3, whose servers are run by server.sh.
+# the receiver line of the iperf3 result is like:
+# [  5]   0.00-5.04   sec   560 MBytes   933 Mbits/sec                  receiver
+# An iperf3 server only runs one test at a time, so server.sh runs IPERF_SERVER_NUM servers, and we try them one by one from a random one. If all of them are busy, we wait and try again.
+# IPERF_SERVER_NUM and IPERF_FIRST_PORT should be consistent with those in server.sh.
+IPERF_SERVER_NUM=4
+IPERF_FIRST_PORT=5201
+IPERF_ATTEMPTS=5
+
+bandwidth_mbps="0" # 0 means unreachable
+if [[ "${rtt_ms}" != "250000" ]]
+then
+  bandwidth_mbps="-1" # -1 means that the bandwidth is not measured, and emcontroller keeps the previous bandwidth, so a failed measurement is not saved as 0
+  first_server=$((RANDOM % IPERF_SERVER_NUM))
+  for ((attempt = 1; attempt <= IPERF_ATTEMPTS; attempt++))
+  do
+    for ((i = 0; i < IPERF_SERVER_NUM; i++))
+    do
+      port=$((IPERF_FIRST_PORT + (first_server + i) % IPERF_SERVER_NUM))
+      iperf_output=$(iperf3 -c "${t_cloud_ip}" -p "${port}" -t 5 -f m 2>&1 || true)
+      receiver_line=$(echo "${iperf_output}" | grep "receiver" || true)
+      if [[ -n "${receiver_line}" ]]
+      then
+        bandwidth_mbps=$(echo "${receiver_line}" | awk '{print $7}')
+        echo "Bandwidth from ${this_cloud_name} to ${t_cloud_name} is ${bandwidth_mbps} Mbps, measured by the server on port ${port}."
+        break 2
+      fi
+      echo "Attempt ${attempt}, the server on port ${port} cannot measure the bandwidth from ${this_cloud_name} to ${t_cloud_name}: $(echo "${iperf_output}" | tail -1)"
+    done
+    sleep $((attempt * 2))
+  done
+  if [[ "${bandwidth_mbps}" == "-1" ]]
+  then
+    echo "Failed to measure the bandwidth from ${this_cloud_name} to ${t_cloud_name}, so we report it as not measured."
+  fi
+fi
+
+# print the result for emcontroller
+echo "MCM_NET_RESULT rtt_ms=${rtt_ms} bandwidth_mbps=${bandwidth_mbps}"
\ No newline at end of file
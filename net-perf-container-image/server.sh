@@ -1,8 +1,21 @@
 #!/bin/env bash
 
-# The network performance test servers do not need to do anything. Just to keep the container running.
+# The network performance test servers run iperf3 servers for the clients to measure the bandwidth, and they also keep the container running.
+# An iperf3 server only runs one test at a time, and it answers "the server is busy" to the other clients, so we run IPERF_SERVER_NUM servers on the TCP ports from IPERF_FIRST_PORT.
+# IPERF_SERVER_NUM and IPERF_FIRST_PORT should be consistent with those in client.sh and netTestIperfServers in emcontroller.
 
-while true
+IPERF_SERVER_NUM=4
+IPERF_FIRST_PORT=5201
+
+for ((i = 0; i < IPERF_SERVER_NUM; i++))
 do
-  sleep 10
-done
\ No newline at end of file
+  port=$((IPERF_FIRST_PORT + i))
+  (
+    while true
+    do
+      iperf3 -s -p "${port}" || sleep 10
+    done
+  ) &
+done
+
+wait
\ No newline at end of file
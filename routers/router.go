@@ -11,9 +11,14 @@ func init() {
 
 	beego.Router("/cloud", &controllers.CloudController{}, "get:Get")
 	beego.Router("/cloud/:cloudName", &controllers.CloudController{}, "get:GetSingleCloud")
+	beego.Router("/cloud", &controllers.CloudController{}, "post:AddCloud")
+	beego.Router("/cloud/:cloudName", &controllers.CloudController{}, "put:UpdateCloud")
+	beego.Router("/cloud/:cloudName", &controllers.CloudController{}, "delete:DeleteCloud")
 	beego.Router("/cloud/:cloudName/vm/:vmID", &controllers.VmController{}, "delete:DeleteVM")
 	beego.Router("/cloud/:cloudName/vm", &controllers.VmController{}, "post:CreateVM")
 	beego.Router("/cloud/:cloudName/vm/:vmID", &controllers.VmController{}, "get:GetVM")
+	beego.Router("/cloud/:cloudName/vm/:vmID/actions", &controllers.VmController{}, "get:ListVMActions")
+	beego.Router("/cloud/:cloudName/vm/:vmID/actions", &controllers.VmController{}, "post:DoVMAction")
 
 	beego.Router("/vm", &controllers.VmController{}, "get:ListVMsAllClouds")
 	beego.Router("/vm", &controllers.VmController{}, "delete:DeleteVMs")
@@ -21,18 +26,50 @@ func init() {
 	beego.Router("/vm/doNew", &controllers.VmController{}, "post:DoNewVms")
 
 	beego.Router("/image", &controllers.ImageController{}, "get:Get")
+	beego.Router("/image/registries", &controllers.ImageController{}, "get:ListRegistries")
+	beego.Router("/image/catalog", &controllers.ImageController{}, "get:GetCatalog")
+	beego.Router("/image/gc", &controllers.ImageController{}, "post:GarbageCollect")
 	beego.Router("/image/:repo", &controllers.ImageController{}, "delete:DeleteRepo")
+	beego.Router("/image/:repo/tag/:tag", &controllers.ImageController{}, "get:GetTag;delete:DeleteTag")
+	beego.Router("/image/:repo/manifest/:digest", &controllers.ImageController{}, "delete:DeleteManifest")
 	beego.Router("/upload", &controllers.ImageController{}, "post:Upload")
 
 	beego.Router("/application", &controllers.ApplicationController{}, "get:Get")
 	beego.Router("/application", &controllers.ApplicationController{}, "delete:DeleteApps")
 	beego.Router("/application/:appName", &controllers.ApplicationController{}, "delete:DeleteApp")
 	beego.Router("/application/:appName", &controllers.ApplicationController{}, "get:GetApp")
+	beego.Router("/application/:appName", &controllers.ApplicationController{}, "put:UpdateApp")
+	beego.Router("/application/:appName/rollback", &controllers.ApplicationController{}, "post:RollbackApp")
+	beego.Router("/application/:appName/manifest", &controllers.ApplicationController{}, "get:GetManifest")
 	beego.Router("/newApplication", &controllers.ApplicationController{}, "get:NewApplication")
 	beego.Router("/doNewApplication", &controllers.ApplicationController{}, "post:DoNewApplication")
 
 	// AppGroup is for the auto-schedule function.
 	beego.Router("/doNewAppGroup", &controllers.AppGroupController{}, "post:DoNewAppGroup")
+	beego.Router("/planAppGroup", &controllers.AppGroupController{}, "post:PlanAppGroup")
+	beego.Router("/applyAppGroupPlan", &controllers.AppGroupController{}, "post:ApplyAppGroupPlan")
+	beego.Router("/migrateAppGroup", &controllers.AppGroupController{}, "post:MigrateAppGroup")
+	beego.Router("/importAppGroup", &controllers.AppGroupController{}, "post:ImportAppGroup")
+	beego.Router("/schedulingAlgorithms", &controllers.SchedulingAlgorithmController{}, "get:List")
+	beego.Router("/scheduleJob", &controllers.ScheduleJobController{}, "get:List")
+	beego.Router("/scheduleJob/:id", &controllers.ScheduleJobController{}, "get:Get")
+	beego.Router("/scheduleJob/:id", &controllers.ScheduleJobController{}, "delete:Cancel")
+
+	// the applications, auto-scheduling and scheduling jobs of a tenant
+	beego.Router("/tenant", &controllers.TenantController{}, "get:List;post:Create")
+	beego.Router("/tenant/:tenant", &controllers.TenantController{}, "get:Get;delete:Delete")
+	beego.Router("/tenant/:tenant/quota", &controllers.TenantController{}, "put:UpdateQuota")
+	beego.Router("/tenant/:tenant/application", &controllers.ApplicationController{}, "get:Get;post:DoNewApplication;delete:DeleteApps")
+	beego.Router("/tenant/:tenant/application/:appName", &controllers.ApplicationController{}, "get:GetApp;put:UpdateApp;delete:DeleteApp")
+	beego.Router("/tenant/:tenant/application/:appName/rollback", &controllers.ApplicationController{}, "post:RollbackApp")
+	beego.Router("/tenant/:tenant/application/:appName/manifest", &controllers.ApplicationController{}, "get:GetManifest")
+	beego.Router("/tenant/:tenant/doNewAppGroup", &controllers.AppGroupController{}, "post:DoNewAppGroup")
+	beego.Router("/tenant/:tenant/planAppGroup", &controllers.AppGroupController{}, "post:PlanAppGroup")
+	beego.Router("/tenant/:tenant/applyAppGroupPlan", &controllers.AppGroupController{}, "post:ApplyAppGroupPlan")
+	beego.Router("/tenant/:tenant/migrateAppGroup", &controllers.AppGroupController{}, "post:MigrateAppGroup")
+	beego.Router("/tenant/:tenant/importAppGroup", &controllers.AppGroupController{}, "post:ImportAppGroup")
+	beego.Router("/tenant/:tenant/scheduleJob", &controllers.ScheduleJobController{}, "get:List")
+	beego.Router("/tenant/:tenant/scheduleJob/:id", &controllers.ScheduleJobController{}, "get:Get;delete:Cancel")
 
 	beego.Router("/k8sNode", &controllers.K8sNodeController{}, "get:Get")
 	beego.Router("/k8sNode", &controllers.K8sNodeController{}, "delete:DeleteNodes")
@@ -41,6 +78,7 @@ func init() {
 	beego.Router("/k8sNode/doAdd", &controllers.K8sNodeController{}, "post:DoAddNodes")
 
 	beego.Router("/netState", &controllers.NetStateController{}, "get:Get")
+	beego.Router("/netState/history", &controllers.NetStateController{}, "get:GetHistory")
 	// weather API test route
 	beego.Router("/api/weather", &controllers.MainController{}, "get:GetWeather")
 }
@@ -12,6 +12,12 @@ function unlockDeleteApp() {
     location.reload() // after deleting, refresh the page
 }
 
+// "/application", or "/tenant/xxx/application" for the applications of a tenant
+function appBasePath() {
+    let table = document.getElementById("applicationTable");
+    return (table && table.dataset.basePath) || "/application";
+}
+
 // original html does not support to send PUT or DELETE request
 function deleteApp(appName, statusID) {
     if (deleteAppLock) {
@@ -22,7 +28,7 @@ function deleteApp(appName, statusID) {
     let appStatus = document.getElementById(statusID);
     appStatus.innerText = "Deleting";
     let xmlhttp = new XMLHttpRequest();
-    xmlhttp.open("DELETE", `/application/${appName}`);
+    xmlhttp.open("DELETE", `${appBasePath()}/${appName}`);
     xmlhttp.send();
     console.log("delete %s request has been sent", appName);
     xmlhttp.onreadystatechange = function(){
@@ -68,7 +74,7 @@ function deleteBatchApps() {
     }
 
     // send http request to delete applications
-    let resp = fetch("/application",{
+    let resp = fetch(appBasePath(),{
         method: "DELETE",
         headers: {
             "Content-Type": "application/json"
@@ -1,6 +1,6 @@
 'use strict';
 
-// deleting an image should restart the docker registry container, so 2 deleting cannot be executed at one time
+// only one deleting at a time, and the page is refreshed after it
 let deleteRepoLock = false;
 
 function lockDeleteRepo() {
@@ -12,8 +12,8 @@ function unlockDeleteRepo() {
     location.reload() // after deleting, refresh the page
 }
 
-// original html does not support to send PUT or DELETE request
-function deleteRepo(repository, messageID) {
+// send a DELETE request, and show the result in the message
+function sendDelete(url, messageID) {
     if (deleteRepoLock) {
         console.log("Another deleting is executing, please try again after a few seconds");
         return;
@@ -23,23 +23,36 @@ function deleteRepo(repository, messageID) {
     imageMessage.innerText = "Deleting";
     let xmlhttp = new XMLHttpRequest();
 
-    // In the name of repository there may be the symbol '/', which should be encoded, or else HTTP can not split the URL correctly.
-    let encodedRepo = encodeURIComponent(repository);
-    console.log("encoded %s to %s", repository, encodedRepo);
-
-    xmlhttp.open("DELETE", `/image/${encodedRepo}`);
+    xmlhttp.open("DELETE", url);
     xmlhttp.send();
-    console.log("delete %s request has been sent", encodedRepo);
+    console.log("delete %s request has been sent", url);
     xmlhttp.onreadystatechange = function(){
         if(this.readyState==4 && this.status==200) {
-            console.log("delete %s response: %s", encodedRepo, xmlhttp.responseText);
+            console.log("delete %s response: %s", url, xmlhttp.responseText);
             // reserve 1s for deleting
             setTimeout(unlockDeleteRepo, 1000);
+        } else if (this.readyState==4) {
+            imageMessage.innerText = `Delete failed, status ${this.status}: ${xmlhttp.responseText}`;
+            deleteRepoLock = false;
         }
         console.log("onreadystatechange this.readyState: %O, this.status: %O", this.readyState, this.status);
     }
 }
 
+// original html does not support to send PUT or DELETE request
+function deleteRepo(registry, repository, messageID) {
+    // In the name of repository there may be the symbol '/', which should be encoded, or else HTTP can not split the URL correctly.
+    let encodedRepo = encodeURIComponent(repository);
+    console.log("encoded %s to %s", repository, encodedRepo);
+    sendDelete(`/image/${encodedRepo}?registry=${encodeURIComponent(registry)}`, messageID);
+}
+
+// delete one tag of a repository
+function deleteTag(registry, repository, tag, messageID) {
+    let encodedRepo = encodeURIComponent(repository);
+    sendDelete(`/image/${encodedRepo}/tag/${encodeURIComponent(tag)}?registry=${encodeURIComponent(registry)}`, messageID);
+}
+
 // while uploading an image, users cannot operate the upload part of the web
 // can put this function in the onsubmit="whileUploading()" of the form.
 function whileUploading() {
@@ -57,11 +70,84 @@ function whileUploading() {
 // See https://stackoverflow.com/questions/5691054/disable-submit-button-on-form-submit
 function initImagePage() {
     $("form#uploadForm").submit(function (event) {
+        // we send the form by ourselves, to show the progress of pushing
+        event.preventDefault();
+        let formData = new FormData(this);
         $(this).find(':input[type=text]').prop("readonly", "readonly");
         $(this).find(':input[type=file]').prop("readonly", "readonly");
         $(this).find(':input[type=submit]').prop("disabled", "disabled");
+        $(this).find('select').prop("disabled", "disabled");
         let uploadButton = document.getElementById("upload");
         uploadButton.insertAdjacentHTML('afterend',"<p>Uploading, please wait ...</p>");
+        uploadImage(formData);
     });
 }
 
+// upload the image, and show the progress of pushing it, which is streamed as Server-Sent Events
+// EventSource only supports GET, so we read the events from the response of fetch.
+async function uploadImage(formData) {
+    let progressArea = document.getElementById("uploadProgress");
+    let layers = new Map(); // the latest progress of every layer
+    let showProgress = function (lastLine) {
+        let lines = [];
+        layers.forEach((progress, id) => lines.push(`${id}: ${progress.status || ""} ${progress.progress || ""}`));
+        if (lastLine) {
+            lines.push(lastLine);
+        }
+        progressArea.innerText = lines.join("\n");
+    };
+
+    let response;
+    try {
+        response = await fetch("/upload", {method: "POST", body: formData, headers: {"Accept": "text/event-stream"}});
+    } catch (err) {
+        showProgress(`Upload failed: ${err}`);
+        return;
+    }
+    if (!response.ok) {
+        showProgress(`Upload failed, status ${response.status}: ${await response.text()}`);
+        return;
+    }
+
+    let reader = response.body.getReader();
+    let decoder = new TextDecoder();
+    let buffer = "";
+    while (true) {
+        let {value, done} = await reader.read();
+        if (done) {
+            break;
+        }
+        buffer += decoder.decode(value, {stream: true});
+        // events are separated by an empty line
+        let events = buffer.split("\n\n");
+        buffer = events.pop();
+        for (let oneEvent of events) {
+            let eventName = "message";
+            let data = "";
+            for (let line of oneEvent.split("\n")) {
+                if (line.startsWith("event: ")) {
+                    eventName = line.substring("event: ".length);
+                } else if (line.startsWith("data: ")) {
+                    data += line.substring("data: ".length);
+                }
+            }
+            let msg = JSON.parse(data);
+            if (eventName === "progress") {
+                if (msg.id) {
+                    layers.set(msg.id, msg);
+                    showProgress();
+                } else {
+                    showProgress(msg.status);
+                }
+            } else if (eventName === "error") {
+                showProgress(`Push ${msg.repoTag} failed: ${msg.error}`);
+                return;
+            } else if (eventName === "done") {
+                showProgress(`Pushed ${msg.repoTag}, digest ${msg.digest}. Refresh the page after 3 seconds.`);
+                setTimeout(() => location.reload(), 3000);
+                return;
+            }
+        }
+    }
+}
+
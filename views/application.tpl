@@ -100,7 +100,7 @@ button:hover { background:#2ea043; }
 =======
 
 >>>>>>> origin/main
-    <div class="table-container">
+    <div class="table-container" id="applicationTable" data-base-path="{{.appBasePath}}">
         <table>
             <tr>
                 <th></th>
@@ -21,31 +21,41 @@
     <br>
     <h3>Upload a new Image</h3>
     <form id="uploadForm" method="POST" action="/upload" enctype="multipart/form-data">
-        Set the RepoTag:<br>
-        {{config "String" "dockerRegistryIP" ""}}:{{config "String" "dockerRegistryPort" ""}}/<input type="text" id="imageName" name="imageName">:<input type="text" id="imageTag" name="imageTag"><br>
+        Set the Registry and the RepoTag:<br>
+        <select id="registry" name="registry">
+            {{range .registryImages}}
+                <option value="{{.Registry.Name}}" {{if .Registry.Default}}selected{{end}}>{{.Registry.Name}} ({{.Registry.Address}})</option>
+            {{end}}
+        </select>/<input type="text" id="imageName" name="imageName">:<input type="text" id="imageTag" name="imageTag"><br>
         <input id="imageFile" name="imageFile" type="file"/>
         <input id="upload" type="submit" value="Upload">
     </form>
+    <pre id="uploadProgress"></pre>
 
     <br>
     <h3>Existing Images</h3>
-    {{$dockerRepo := .dockerRegistry}}
-    <table border = 1>
-        <tr> <th></th> <th>Repositories</th> <th>RepoTags</th> <th>Messages</th> </tr>
-        {{range $repo, $tags := .imageList}}
-            {{$messageID := printf "imageMessage%s" $repo}}
-            <tr>
-                <td><button type="button" onclick="deleteRepo('{{$repo}}', '{{$messageID}}')">Delete</button></td>
-                <td>{{$repo}}</td>
-                <td>
-                    {{range $idx, $tag := $tags}}
-                        {{$dockerRepo}}/{{$repo}}:{{$tag}} <br>
-                    {{end}}
-                </td>
-                <td id="{{$messageID}}"></td>
-            </tr>
-        {{end}}
-    </table>
+    {{range $regIdx, $regImages := .registryImages}}
+        {{$registry := $regImages.Registry.Name}}
+        {{$dockerRepo := $regImages.Registry.Address}}
+        <h4>Registry {{$registry}} ({{$dockerRepo}})</h4>
+        <table border = 1>
+            <tr> <th></th> <th>Repositories</th> <th>RepoTags (size, digest)</th> <th>Messages</th> </tr>
+            {{range $repo, $tags := $regImages.Images}}
+                {{$messageID := printf "imageMessage%s%s" $registry $repo}}
+                <tr>
+                    <td><button type="button" onclick="deleteRepo('{{$registry}}', '{{$repo}}', '{{$messageID}}')">Delete</button></td>
+                    <td>{{$repo}}</td>
+                    <td>
+                        {{range $idx, $tag := $tags}}
+                            <button type="button" onclick="deleteTag('{{$registry}}', '{{$repo}}', '{{$tag.Tag}}', '{{$messageID}}')">Delete</button>
+                            {{$dockerRepo}}/{{$repo}}:{{$tag.Tag}} {{if $tag.Digest}}({{$tag.Size}} bytes, {{$tag.Digest}}){{end}} <br>
+                        {{end}}
+                    </td>
+                    <td id="{{$messageID}}"></td>
+                </tr>
+            {{end}}
+        </table>
+    {{end}}
 
 </body>
 </html>
\ No newline at end of file
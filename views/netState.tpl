@@ -61,7 +61,45 @@
             {{end}}
         </table>
 
+        <br>
+        <h3>Bandwidth (TCP throughput) with unit Mbps, and 0 means unreachable or not measured</h3>
+
+        <table border = 1>
+            <tr>
+                <th rowspan="2" colspan="2">Bandwidth (Mbps)</th>
+                <th colspan="{{$netStateLen}}">Target Cloud</th>
+            </tr>
+            <tr>
+                {{ range $idx, $cloud := $nsKeys }}
+                    <th>{{$cloud}}</th>
+                {{end}}
+            </tr>
+            <tr>
+                <th rowspan="{{$netStateLen}}">Source Cloud</th>
+                {{ $firstSKey := index $nsKeys 0 }}
+                <th>{{$firstSKey}}</th>
+                {{ range $tIdx, $tKey := $nsKeys }}
+                    {{with $thisNs := index $ns $firstSKey $tKey}}
+                        <td>{{$thisNs.BandwidthMbps}}</td>
+                    {{end}}
+                {{end}}
+            </tr>
+            {{ range $sIdx, $sKey := $nsKeys }}
+                {{ if eq $sIdx 0 }}
+                    {{continue}}
+                {{ end }}
+                <tr>
+                <th>{{$sKey}}</th>
+                {{ range $tIdx, $tKey := $nsKeys }}
+                    {{with $thisNs := index $ns $sKey $tKey}}
+                    <td>{{$thisNs.BandwidthMbps}}</td>
+                    {{end}}
+                {{end}}
+                </tr>
+            {{end}}
+        </table>
+
     {{ end }}
 
 </body>
-</html>
\ No newline at end of file
+</html>
\ No newline at end of file